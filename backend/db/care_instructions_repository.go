@@ -1,40 +1,80 @@
 package db
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
 	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/lib/pq"
+	"succulent-identifier-backend/utils"
 )
 
 // CareInstructionsRepository handles database operations for care instructions cache
 type CareInstructionsRepository struct {
-	db *sql.DB
+	db            *sql.DB
+	maxEntries    int // 0 means unlimited
+	promptVersion int // care prompt version new entries are generated with
+
+	// accessed holds the IDs of entries read since accessed_at was last
+	// written, so cache hits stay reads and LRU times are updated in batches
+	accessMu sync.Mutex
+	accessed map[string]bool
 }
 
+// DefaultCarePromptVersion is the prompt version new entries are generated
+// with when none is set, the same as the CARE_PROMPT_VERSION default
+const DefaultCarePromptVersion = utils.DefaultCarePromptVersion
+
 // NewCareInstructionsRepository creates a new care instructions repository
 func NewCareInstructionsRepository(db *sql.DB) *CareInstructionsRepository {
-	return &CareInstructionsRepository{db: db}
+	return &CareInstructionsRepository{db: db, promptVersion: DefaultCarePromptVersion, accessed: map[string]bool{}}
+}
+
+// SetMaxEntries caps the number of cached entries. When a new entry pushes the
+// cache over the cap, least recently used non-verified entries are evicted.
+// A value of 0 disables the cap.
+func (r *CareInstructionsRepository) SetMaxEntries(maxEntries int) {
+	r.maxEntries = maxEntries
+}
+
+// SetPromptVersion sets the current care prompt version. Entries generated
+// with an older version are treated as cache misses by GetBySpecies so they
+// are regenerated, except verified entries, which are always served.
+func (r *CareInstructionsRepository) SetPromptVersion(version int) {
+	r.promptVersion = version
 }
 
 // GetBySpecies retrieves cached care instructions for a specific genus and species
+// Reading an entry queues a refresh of its accessed_at, written by
+// FlushAccessTimes, so it is kept by LRU eviction.
+// Non-verified entries generated with an older prompt version are misses.
 func (r *CareInstructionsRepository) GetBySpecies(genus, species string) (*CareInstructionsCache, error) {
 	query := `
-		SELECT id, genus, species, care_guide, created_at, updated_at
+		SELECT id, genus, species, care_guide, verified, prompt_version, created_at, updated_at, accessed_at
 		FROM care_instructions
-		WHERE genus = $1 AND species = $2
+		WHERE genus = $1 AND species = $2 AND (verified OR prompt_version >= $3)
 	`
 
 	cache := &CareInstructionsCache{}
 	var careGuideJSON []byte
 
-	err := r.db.QueryRow(query, genus, species).Scan(
-		&cache.ID,
-		&cache.Genus,
-		&cache.Species,
-		&careGuideJSON,
-		&cache.CreatedAt,
-		&cache.UpdatedAt,
-	)
+	err := withRetry(func() error {
+		return r.db.QueryRow(query, genus, species, r.promptVersion).Scan(
+			&cache.ID,
+			&cache.Genus,
+			&cache.Species,
+			&careGuideJSON,
+			&cache.Verified,
+			&cache.PromptVersion,
+			&cache.CreatedAt,
+			&cache.UpdatedAt,
+			&cache.AccessedAt,
+		)
+	})
 
 	if err == sql.ErrNoRows {
 		return nil, nil // Not found in cache, return nil without error
@@ -51,9 +91,78 @@ func (r *CareInstructionsRepository) GetBySpecies(genus, species string) (*CareI
 		}
 	}
 
+	cache.CreatedAt = cache.CreatedAt.UTC()
+	cache.UpdatedAt = cache.UpdatedAt.UTC()
+	cache.AccessedAt = cache.AccessedAt.UTC()
+
+	r.accessMu.Lock()
+	r.accessed[cache.ID] = true
+	r.accessMu.Unlock()
+
 	return cache, nil
 }
 
+// FlushAccessTimes writes accessed_at for the entries read since the last
+// flush in a single update. Entries that failed to flush are kept for the next one.
+func (r *CareInstructionsRepository) FlushAccessTimes() error {
+	r.accessMu.Lock()
+	pending := r.accessed
+	r.accessed = map[string]bool{}
+	r.accessMu.Unlock()
+
+	if len(pending) == 0 {
+		return nil
+	}
+
+	ids := make([]string, 0, len(pending))
+	for id := range pending {
+		ids = append(ids, id)
+	}
+
+	query := `UPDATE care_instructions SET accessed_at = CURRENT_TIMESTAMP WHERE id = ANY($1)`
+
+	// Setting accessed_at is idempotent, so transient failures are retried
+	err := withRetry(func() error {
+		_, err := r.db.Exec(query, pq.Array(ids))
+		return err
+	})
+	if err != nil {
+		r.accessMu.Lock()
+		for _, id := range ids {
+			r.accessed[id] = true
+		}
+		r.accessMu.Unlock()
+		return fmt.Errorf("failed to update care access times: %w", err)
+	}
+
+	return nil
+}
+
+// StartAccessFlush runs FlushAccessTimes every interval until ctx is
+// cancelled, flushing one last time before it returns
+func (r *CareInstructionsRepository) StartAccessFlush(ctx context.Context, interval time.Duration) {
+	flush := func() {
+		if err := r.FlushAccessTimes(); err != nil {
+			log.Printf("Failed to flush care cache access times: %v", err)
+		}
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				flush()
+				return
+			case <-ticker.C:
+				flush()
+			}
+		}
+	}()
+}
+
 // Create saves new care instructions to the cache
 func (r *CareInstructionsRepository) Create(cache *CareInstructionsCache) error {
 	// Marshal care guide to JSON
@@ -63,11 +172,13 @@ func (r *CareInstructionsRepository) Create(cache *CareInstructionsCache) error
 	}
 
 	query := `
-		INSERT INTO care_instructions (id, genus, species, care_guide, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, $5, $6)
+		INSERT INTO care_instructions (id, genus, species, care_guide, prompt_version, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
 		ON CONFLICT (genus, species) DO UPDATE
 		SET care_guide = EXCLUDED.care_guide,
-		    updated_at = EXCLUDED.updated_at
+		    prompt_version = EXCLUDED.prompt_version,
+		    updated_at = EXCLUDED.updated_at,
+		    accessed_at = CURRENT_TIMESTAMP
 		RETURNING id, created_at, updated_at
 	`
 
@@ -77,17 +188,160 @@ func (r *CareInstructionsRepository) Create(cache *CareInstructionsCache) error
 		cache.Genus,
 		cache.Species,
 		careGuideJSON,
+		r.promptVersion,
 		cache.CreatedAt,
 		cache.UpdatedAt,
 	).Scan(&cache.ID, &cache.CreatedAt, &cache.UpdatedAt)
 
+	// Concurrent upserts of the same species can still collide on the unique
+	// index; the other writer has cached care for this species, which is all
+	// the caller wanted
+	if isUniqueViolation(err) {
+		log.Printf("Care instructions for %s %s were cached concurrently", cache.Genus, cache.Species)
+		return nil
+	}
 	if err != nil {
 		return fmt.Errorf("failed to create care instructions: %w", err)
 	}
+	cache.CreatedAt = cache.CreatedAt.UTC()
+	cache.UpdatedAt = cache.UpdatedAt.UTC()
+
+	// The entry is cached; a failed eviction only leaves the cache over its
+	// cap until the next insert evicts
+	if r.maxEntries > 0 {
+		if _, err := r.EvictLRU(r.maxEntries); err != nil {
+			log.Printf("Failed to evict care cache entries: %v", err)
+		}
+	}
 
 	return nil
 }
 
+// EvictLRU deletes the least recently used non-verified entries until at most
+// maxEntries remain. Verified entries count towards the cap but are never
+// deleted, so the cache may stay above the cap if it is mostly verified.
+// Pending access times are flushed first so recently read entries are kept.
+// Returns the number of evicted entries.
+func (r *CareInstructionsRepository) EvictLRU(maxEntries int) (int64, error) {
+	if maxEntries <= 0 {
+		return 0, nil
+	}
+
+	if err := r.FlushAccessTimes(); err != nil {
+		log.Printf("Evicting with stale care cache access times: %v", err)
+	}
+
+	query := `
+		DELETE FROM care_instructions
+		WHERE id IN (
+			SELECT id FROM care_instructions
+			WHERE verified = FALSE
+			ORDER BY accessed_at ASC
+			LIMIT GREATEST((SELECT COUNT(*) FROM care_instructions) - $1, 0)
+		)
+	`
+
+	result, err := r.db.Exec(query, maxEntries)
+	if err != nil {
+		return 0, fmt.Errorf("failed to evict care instructions: %w", err)
+	}
+
+	evicted, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	return evicted, nil
+}
+
+// FlushCache deletes every non-verified cached entry, or only those of genus
+// when it is not empty. Returns the number of deleted entries.
+func (r *CareInstructionsRepository) FlushCache(genus string) (int64, error) {
+	query := `
+		DELETE FROM care_instructions
+		WHERE verified = FALSE AND ($1 = '' OR genus = $1)
+	`
+
+	result, err := r.db.Exec(query, genus)
+	if err != nil {
+		return 0, fmt.Errorf("failed to flush care instructions: %w", err)
+	}
+
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	return deleted, nil
+}
+
+// ListSpecies returns the genus, species and care difficulty of every cached
+// entry, without touching accessed_at so listing does not affect LRU eviction
+func (r *CareInstructionsRepository) ListSpecies() ([]CachedSpecies, error) {
+	query := `SELECT genus, species, COALESCE(care_guide->>'difficulty', '') FROM care_instructions ORDER BY genus, species`
+
+	rows, err := queryWithRetry(r.db, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list cached species: %w", err)
+	}
+	defer rows.Close()
+
+	species := []CachedSpecies{}
+	for rows.Next() {
+		var cached CachedSpecies
+		if err := rows.Scan(&cached.Genus, &cached.Species, &cached.Difficulty); err != nil {
+			return nil, fmt.Errorf("failed to scan cached species: %w", err)
+		}
+		species = append(species, cached)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating cached species: %w", err)
+	}
+
+	return species, nil
+}
+
+// ListCare returns every cached entry GetBySpecies would serve, with its care
+// guide, without touching accessed_at so listing does not affect LRU eviction
+func (r *CareInstructionsRepository) ListCare() ([]CareInstructionsCache, error) {
+	query := `
+		SELECT genus, species, care_guide, verified, prompt_version, updated_at
+		FROM care_instructions
+		WHERE verified OR prompt_version >= $1
+		ORDER BY genus, species
+	`
+
+	rows, err := queryWithRetry(r.db, query, r.promptVersion)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list cached care: %w", err)
+	}
+	defer rows.Close()
+
+	entries := []CareInstructionsCache{}
+	for rows.Next() {
+		var cache CareInstructionsCache
+		var careGuideJSON []byte
+		if err := rows.Scan(&cache.Genus, &cache.Species, &careGuideJSON, &cache.Verified, &cache.PromptVersion, &cache.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan cached care: %w", err)
+		}
+		if len(careGuideJSON) > 0 {
+			cache.CareGuide = &CareGuide{}
+			if err := json.Unmarshal(careGuideJSON, cache.CareGuide); err != nil {
+				return nil, fmt.Errorf("failed to unmarshal care guide: %w", err)
+			}
+		}
+		cache.UpdatedAt = cache.UpdatedAt.UTC()
+		entries = append(entries, cache)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating cached care: %w", err)
+	}
+
+	return entries, nil
+}
+
 // Update updates existing care instructions in the cache
 func (r *CareInstructionsRepository) Update(cache *CareInstructionsCache) error {
 	// Marshal care guide to JSON
@@ -98,11 +352,11 @@ func (r *CareInstructionsRepository) Update(cache *CareInstructionsCache) error
 
 	query := `
 		UPDATE care_instructions
-		SET care_guide = $1, updated_at = $2
-		WHERE genus = $3 AND species = $4
+		SET care_guide = $1, prompt_version = $2, updated_at = $3
+		WHERE genus = $4 AND species = $5
 	`
 
-	result, err := r.db.Exec(query, careGuideJSON, cache.UpdatedAt, cache.Genus, cache.Species)
+	result, err := r.db.Exec(query, careGuideJSON, r.promptVersion, cache.UpdatedAt, cache.Genus, cache.Species)
 	if err != nil {
 		return fmt.Errorf("failed to update care instructions: %w", err)
 	}
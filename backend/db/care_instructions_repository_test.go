@@ -0,0 +1,605 @@
+package db
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/lib/pq"
+)
+
+func TestCareInstructionsRepositoryGetBySpecies(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create mock database: %v", err)
+	}
+	defer db.Close()
+
+	repo := NewCareInstructionsRepository(db)
+
+	columns := []string{"id", "genus", "species", "care_guide", "verified", "prompt_version", "created_at", "updated_at", "accessed_at"}
+
+	tests := []struct {
+		name          string
+		mockBehavior  func()
+		promptVersion int
+		expectError   bool
+		expectFound   bool
+	}{
+		{
+			name: "Cache hit",
+			mockBehavior: func() {
+				now := time.Now()
+				rows := sqlmock.NewRows(columns).
+					AddRow("cache-1", "haworthia", "haworthia_zebrina", []byte(`{"sunlight":"Bright indirect light"}`), true, 1, now, now, now)
+
+				mock.ExpectQuery("SELECT (.+) FROM care_instructions WHERE genus = \\$1 AND species = \\$2 AND \\(verified OR prompt_version >= \\$3\\)").
+					WithArgs("haworthia", "haworthia_zebrina", DefaultCarePromptVersion).
+					WillReturnRows(rows)
+			},
+			expectError: false,
+			expectFound: true,
+		},
+		{
+			name: "Cache miss",
+			mockBehavior: func() {
+				mock.ExpectQuery("SELECT (.+) FROM care_instructions").
+					WithArgs("haworthia", "haworthia_zebrina", DefaultCarePromptVersion).
+					WillReturnError(sql.ErrNoRows)
+			},
+			expectError: false,
+			expectFound: false,
+		},
+		{
+			name:          "Entry from an older prompt version is a miss",
+			promptVersion: 2,
+			mockBehavior: func() {
+				mock.ExpectQuery("SELECT (.+) FROM care_instructions WHERE .* prompt_version >= \\$3").
+					WithArgs("haworthia", "haworthia_zebrina", 2).
+					WillReturnError(sql.ErrNoRows)
+			},
+			expectError: false,
+			expectFound: false,
+		},
+		{
+			name:          "Verified entry from an older prompt version is served",
+			promptVersion: 2,
+			mockBehavior: func() {
+				now := time.Now()
+				rows := sqlmock.NewRows(columns).
+					AddRow("cache-1", "haworthia", "haworthia_zebrina", []byte(`{"sunlight":"Bright indirect light"}`), true, 1, now, now, now)
+
+				mock.ExpectQuery("SELECT (.+) FROM care_instructions WHERE .* \\(verified OR prompt_version >= \\$3\\)").
+					WithArgs("haworthia", "haworthia_zebrina", 2).
+					WillReturnRows(rows)
+			},
+			expectError: false,
+			expectFound: true,
+		},
+		{
+			name: "Database error",
+			mockBehavior: func() {
+				mock.ExpectQuery("SELECT (.+) FROM care_instructions").
+					WithArgs("haworthia", "haworthia_zebrina", DefaultCarePromptVersion).
+					WillReturnError(errDatabase)
+			},
+			expectError: true,
+			expectFound: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mockBehavior()
+
+			repo.SetPromptVersion(DefaultCarePromptVersion)
+			if tt.promptVersion != 0 {
+				repo.SetPromptVersion(tt.promptVersion)
+			}
+			result, err := repo.GetBySpecies("haworthia", "haworthia_zebrina")
+
+			if tt.expectError && err == nil {
+				t.Error("Expected error but got none")
+			}
+
+			if !tt.expectError && err != nil {
+				t.Errorf("Unexpected error: %v", err)
+			}
+
+			if tt.expectFound {
+				if result == nil || result.CareGuide == nil {
+					t.Fatal("Expected cached care instructions")
+				}
+				if !result.Verified {
+					t.Error("Expected verified flag to be loaded")
+				}
+			} else if result != nil {
+				t.Errorf("Expected nil result, got %+v", result)
+			}
+
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Errorf("Unfulfilled expectations: %v", err)
+			}
+		})
+	}
+}
+
+func TestCareInstructionsRepositoryFlushAccessTimes(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create mock database: %v", err)
+	}
+	defer db.Close()
+
+	repo := NewCareInstructionsRepository(db)
+
+	columns := []string{"id", "genus", "species", "care_guide", "verified", "prompt_version", "created_at", "updated_at", "accessed_at"}
+	now := time.Now()
+	for _, id := range []string{"cache-1", "cache-1", "cache-2"} {
+		mock.ExpectQuery("SELECT (.+) FROM care_instructions").
+			WillReturnRows(sqlmock.NewRows(columns).
+				AddRow(id, "haworthia", "zebrina", []byte(`{}`), false, 1, now, now, now))
+		if _, err := repo.GetBySpecies("haworthia", "zebrina"); err != nil {
+			t.Fatalf("GetBySpecies() unexpected error: %v", err)
+		}
+	}
+
+	// Reads are written in one batch; a failed batch is retried on the next flush
+	mock.ExpectExec("UPDATE care_instructions SET accessed_at = CURRENT_TIMESTAMP WHERE id = ANY\\(\\$1\\)").
+		WillReturnError(errDatabase)
+	if err := repo.FlushAccessTimes(); err == nil {
+		t.Error("Expected error but got none")
+	}
+
+	mock.ExpectExec("UPDATE care_instructions SET accessed_at = CURRENT_TIMESTAMP WHERE id = ANY\\(\\$1\\)").
+		WithArgs(accessedIDsArg{expected: []string{"cache-1", "cache-2"}}).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	if err := repo.FlushAccessTimes(); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+
+	// Nothing read since the last flush
+	if err := repo.FlushAccessTimes(); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unfulfilled expectations: %v", err)
+	}
+}
+
+// accessedIDsArg matches the ID array of an access time flush in any order
+type accessedIDsArg struct {
+	expected []string
+}
+
+func (a accessedIDsArg) Match(v driver.Value) bool {
+	var ids pq.StringArray
+	if err := ids.Scan(v); err != nil {
+		return false
+	}
+	slices.Sort(ids)
+	return slices.Equal(ids, a.expected)
+}
+
+// careGuideArg captures the care_guide JSON written by a query
+type careGuideArg struct {
+	written *[]byte
+}
+
+func (a careGuideArg) Match(v driver.Value) bool {
+	data, ok := v.([]byte)
+	*a.written = data
+	return ok
+}
+
+func TestCareInstructionsRepositoryDifficultyRoundTrip(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create mock database: %v", err)
+	}
+	defer db.Close()
+
+	repo := NewCareInstructionsRepository(db)
+
+	var written []byte
+	now := time.Now()
+	mock.ExpectQuery("INSERT INTO care_instructions").
+		WithArgs("cache-1", "lithops", "", careGuideArg{&written}, DefaultCarePromptVersion, sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "created_at", "updated_at"}).AddRow("cache-1", now, now))
+
+	err = repo.Create(&CareInstructionsCache{
+		ID:        "cache-1",
+		Genus:     "lithops",
+		CareGuide: &CareGuide{Sunlight: "Full sun", Difficulty: CareDifficultyHard},
+		CreatedAt: now,
+		UpdatedAt: now,
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	// Read back the JSON exactly as it was written
+	mock.ExpectQuery("SELECT (.+) FROM care_instructions").
+		WithArgs("lithops", "", DefaultCarePromptVersion).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "genus", "species", "care_guide", "verified", "prompt_version", "created_at", "updated_at", "accessed_at"}).
+			AddRow("cache-1", "lithops", "", written, false, DefaultCarePromptVersion, now, now, now))
+
+	cached, err := repo.GetBySpecies("lithops", "")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if cached == nil || cached.CareGuide.Difficulty != CareDifficultyHard {
+		t.Errorf("Expected difficulty %q to round-trip, got %+v", CareDifficultyHard, cached)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unfulfilled expectations: %v", err)
+	}
+}
+
+func TestIsValidCareDifficulty(t *testing.T) {
+	for _, difficulty := range []string{CareDifficultyEasy, CareDifficultyModerate, CareDifficultyHard} {
+		if !IsValidCareDifficulty(difficulty) {
+			t.Errorf("IsValidCareDifficulty(%q) = false, expected true", difficulty)
+		}
+	}
+	for _, difficulty := range []string{"", "Easy", "medium", "impossible"} {
+		if IsValidCareDifficulty(difficulty) {
+			t.Errorf("IsValidCareDifficulty(%q) = true, expected false", difficulty)
+		}
+	}
+}
+
+func TestCareInstructionsRepositoryCreateEvicts(t *testing.T) {
+	tests := []struct {
+		name        string
+		maxEntries  int
+		expectEvict bool
+		evictErr    error
+	}{
+		{
+			name:        "Unlimited cache skips eviction",
+			maxEntries:  0,
+			expectEvict: false,
+		},
+		{
+			name:        "Capped cache evicts after insert",
+			maxEntries:  50,
+			expectEvict: true,
+		},
+		{
+			name:        "Failed eviction does not fail the insert",
+			maxEntries:  50,
+			expectEvict: true,
+			evictErr:    errDatabase,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatalf("Failed to create mock database: %v", err)
+			}
+			defer db.Close()
+
+			repo := NewCareInstructionsRepository(db)
+			repo.SetMaxEntries(tt.maxEntries)
+
+			now := time.Now()
+			mock.ExpectQuery("INSERT INTO care_instructions").
+				WillReturnRows(sqlmock.NewRows([]string{"id", "created_at", "updated_at"}).
+					AddRow("cache-1", now, now))
+			if tt.expectEvict {
+				evict := mock.ExpectExec("DELETE FROM care_instructions").
+					WithArgs(tt.maxEntries)
+				if tt.evictErr != nil {
+					evict.WillReturnError(tt.evictErr)
+				} else {
+					evict.WillReturnResult(sqlmock.NewResult(0, 1))
+				}
+			}
+
+			err = repo.Create(&CareInstructionsCache{
+				ID:        "cache-1",
+				Genus:     "haworthia",
+				Species:   "haworthia_zebrina",
+				CareGuide: &CareGuide{Sunlight: "Bright indirect light"},
+				CreatedAt: now,
+				UpdatedAt: now,
+			})
+			if err != nil {
+				t.Errorf("Unexpected error: %v", err)
+			}
+
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Errorf("Unfulfilled expectations: %v", err)
+			}
+		})
+	}
+}
+
+func TestCareInstructionsRepositoryEvictLRU(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create mock database: %v", err)
+	}
+	defer db.Close()
+
+	repo := NewCareInstructionsRepository(db)
+
+	// Only non-verified entries are candidates, oldest access first
+	evictQuery := "DELETE FROM care_instructions WHERE id IN \\( SELECT id FROM care_instructions " +
+		"WHERE verified = FALSE ORDER BY accessed_at ASC " +
+		"LIMIT GREATEST\\(\\(SELECT COUNT\\(\\*\\) FROM care_instructions\\) - \\$1, 0\\) \\)"
+
+	tests := []struct {
+		name            string
+		maxEntries      int
+		mockBehavior    func()
+		expectError     bool
+		expectedEvicted int64
+	}{
+		{
+			name:       "Over cap evicts non-verified entries",
+			maxEntries: 10,
+			mockBehavior: func() {
+				mock.ExpectExec(evictQuery).
+					WithArgs(10).
+					WillReturnResult(sqlmock.NewResult(0, 3))
+			},
+			expectError:     false,
+			expectedEvicted: 3,
+		},
+		{
+			name:       "Only verified entries over cap evicts nothing",
+			maxEntries: 10,
+			mockBehavior: func() {
+				mock.ExpectExec(evictQuery).
+					WithArgs(10).
+					WillReturnResult(sqlmock.NewResult(0, 0))
+			},
+			expectError:     false,
+			expectedEvicted: 0,
+		},
+		{
+			name:            "Zero cap is a no-op",
+			maxEntries:      0,
+			mockBehavior:    func() {},
+			expectError:     false,
+			expectedEvicted: 0,
+		},
+		{
+			name:       "Database error",
+			maxEntries: 10,
+			mockBehavior: func() {
+				mock.ExpectExec(evictQuery).
+					WithArgs(10).
+					WillReturnError(errDatabase)
+			},
+			expectError:     true,
+			expectedEvicted: 0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mockBehavior()
+
+			evicted, err := repo.EvictLRU(tt.maxEntries)
+
+			if tt.expectError && err == nil {
+				t.Error("Expected error but got none")
+			}
+
+			if !tt.expectError && err != nil {
+				t.Errorf("Unexpected error: %v", err)
+			}
+
+			if evicted != tt.expectedEvicted {
+				t.Errorf("Expected %d evicted, got %d", tt.expectedEvicted, evicted)
+			}
+
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Errorf("Unfulfilled expectations: %v", err)
+			}
+		})
+	}
+}
+
+func TestCareInstructionsRepositoryListSpecies(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create mock database: %v", err)
+	}
+	defer db.Close()
+
+	repo := NewCareInstructionsRepository(db)
+
+	// Listing must not refresh accessed_at, so it is a plain SELECT
+	rows := sqlmock.NewRows([]string{"genus", "species", "difficulty"}).
+		AddRow("echeveria", "elegans", "easy").
+		AddRow("haworthia", "zebrina", "")
+	mock.ExpectQuery("SELECT genus, species, (.+) FROM care_instructions").WillReturnRows(rows)
+
+	species, err := repo.ListSpecies()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	expected := []CachedSpecies{
+		{SpeciesKey: SpeciesKey{Genus: "echeveria", Species: "elegans"}, Difficulty: "easy"},
+		{SpeciesKey: SpeciesKey{Genus: "haworthia", Species: "zebrina"}},
+	}
+	if !slices.Equal(species, expected) {
+		t.Errorf("Unexpected cached species: %+v", species)
+	}
+
+	mock.ExpectQuery("SELECT genus, species, (.+) FROM care_instructions").WillReturnError(errDatabase)
+	if _, err := repo.ListSpecies(); err == nil {
+		t.Error("Expected error but got none")
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unfulfilled expectations: %v", err)
+	}
+}
+
+func TestCareInstructionsRepositoryListCare(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create mock database: %v", err)
+	}
+	defer db.Close()
+
+	repo := NewCareInstructionsRepository(db)
+	repo.SetPromptVersion(2)
+
+	// Stale entries are filtered like GetBySpecies misses, without refreshing accessed_at
+	now := time.Now()
+	rows := sqlmock.NewRows([]string{"genus", "species", "care_guide", "verified", "prompt_version", "updated_at"}).
+		AddRow("echeveria", "elegans", []byte(`{"sunlight":"Full sun","watering":"Sparingly","soil":"Gritty"}`), false, 2, now).
+		AddRow("haworthia", "", []byte(`{"sunlight":"Bright shade"}`), true, 1, now)
+	mock.ExpectQuery("SELECT genus, species, care_guide, (.+) FROM care_instructions").
+		WithArgs(2).
+		WillReturnRows(rows)
+
+	entries, err := repo.ListCare()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("Expected 2 entries, got %d", len(entries))
+	}
+	if entries[0].Genus != "echeveria" || entries[0].Species != "elegans" || entries[0].CareGuide == nil || entries[0].CareGuide.Sunlight != "Full sun" {
+		t.Errorf("Unexpected first entry: %+v", entries[0])
+	}
+	if !entries[1].Verified || entries[1].CareGuide == nil || entries[1].CareGuide.Sunlight != "Bright shade" {
+		t.Errorf("Unexpected second entry: %+v", entries[1])
+	}
+
+	mock.ExpectQuery("SELECT genus, species, care_guide, (.+) FROM care_instructions").WillReturnError(errDatabase)
+	if _, err := repo.ListCare(); err == nil {
+		t.Error("Expected error but got none")
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unfulfilled expectations: %v", err)
+	}
+}
+
+func TestCareInstructionsRepositoryCreateUniqueViolation(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create mock database: %v", err)
+	}
+	defer db.Close()
+
+	repo := NewCareInstructionsRepository(db)
+	repo.SetMaxEntries(50)
+
+	// A concurrent writer cached the same species first; no eviction follows
+	mock.ExpectQuery("INSERT INTO care_instructions").
+		WillReturnError(&pq.Error{Code: "23505", Message: "duplicate key value violates unique constraint"})
+
+	now := time.Now()
+	err = repo.Create(&CareInstructionsCache{
+		ID:        "cache-2",
+		Genus:     "haworthia",
+		Species:   "zebrina",
+		CareGuide: &CareGuide{Sunlight: "Bright indirect light"},
+		CreatedAt: now,
+		UpdatedAt: now,
+	})
+	if err != nil {
+		t.Errorf("Expected unique violation to be treated as cached, got %v", err)
+	}
+
+	// Other insert failures are still reported
+	mock.ExpectQuery("INSERT INTO care_instructions").
+		WillReturnError(&pq.Error{Code: "23502", Message: "null value in column violates not-null constraint"})
+	if err := repo.Create(&CareInstructionsCache{ID: "cache-3", CareGuide: &CareGuide{}}); err == nil {
+		t.Error("Expected other constraint violations to fail")
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unfulfilled expectations: %v", err)
+	}
+}
+
+func TestCareInstructionsRepositoryFlushCache(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create mock database: %v", err)
+	}
+	defer db.Close()
+
+	repo := NewCareInstructionsRepository(db)
+
+	// Verified entries are never flushed; an empty genus flushes every genus
+	flushQuery := "DELETE FROM care_instructions WHERE verified = FALSE AND \\(\\$1 = '' OR genus = \\$1\\)"
+
+	tests := []struct {
+		name            string
+		genus           string
+		mockBehavior    func()
+		expectError     bool
+		expectedDeleted int64
+	}{
+		{
+			name:  "Flush all genera",
+			genus: "",
+			mockBehavior: func() {
+				mock.ExpectExec(flushQuery).
+					WithArgs("").
+					WillReturnResult(sqlmock.NewResult(0, 12))
+			},
+			expectedDeleted: 12,
+		},
+		{
+			name:  "Flush one genus",
+			genus: "haworthia",
+			mockBehavior: func() {
+				mock.ExpectExec(flushQuery).
+					WithArgs("haworthia").
+					WillReturnResult(sqlmock.NewResult(0, 2))
+			},
+			expectedDeleted: 2,
+		},
+		{
+			name:  "Database error",
+			genus: "",
+			mockBehavior: func() {
+				mock.ExpectExec(flushQuery).
+					WithArgs("").
+					WillReturnError(errDatabase)
+			},
+			expectError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mockBehavior()
+
+			deleted, err := repo.FlushCache(tt.genus)
+
+			if tt.expectError && err == nil {
+				t.Error("Expected error but got none")
+			}
+			if !tt.expectError && err != nil {
+				t.Errorf("Unexpected error: %v", err)
+			}
+			if deleted != tt.expectedDeleted {
+				t.Errorf("Expected %d deleted, got %d", tt.expectedDeleted, deleted)
+			}
+
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Errorf("Unfulfilled expectations: %v", err)
+			}
+		})
+	}
+}
@@ -3,6 +3,8 @@ package db
 import (
 	"database/sql"
 	"fmt"
+
+	"github.com/lib/pq"
 )
 
 // ChatRepository handles database operations for chat messages
@@ -18,23 +20,102 @@ func NewChatRepository(db *sql.DB) *ChatRepository {
 // Create saves a new chat message to the database
 func (r *ChatRepository) Create(message *ChatMessage) error {
 	query := `
-		INSERT INTO chat_messages (id, identification_id, message, sender, created_at)
-		VALUES ($1, $2, $3, $4, $5)
+		INSERT INTO chat_messages (id, identification_id, message, sender, model, truncated, care_references, created_at)
+		VALUES ($1, NULLIF($2, '')::uuid, $3, $4, NULLIF($5, ''), $6, $7, $8)
 		RETURNING id, created_at
 	`
 
-	err := r.db.QueryRow(
-		query,
-		message.ID,
-		message.IdentificationID,
-		message.Message,
-		message.Sender,
-		message.CreatedAt,
-	).Scan(&message.ID, &message.CreatedAt)
+	// The ID is generated by the caller, so a retried insert cannot create a duplicate
+	err := withRetry(func() error {
+		return r.db.QueryRow(
+			query,
+			message.ID,
+			message.IdentificationID,
+			message.Message,
+			message.Sender,
+			message.Model,
+			message.Truncated,
+			pq.Array(message.CareReferences),
+			message.CreatedAt,
+		).Scan(&message.ID, &message.CreatedAt)
+	})
 
 	if err != nil {
 		return fmt.Errorf("failed to create chat message: %w", err)
 	}
+	message.CreatedAt = message.CreatedAt.UTC()
+
+	return nil
+}
+
+// GetByID retrieves a single chat message, returning ErrNotFound if it does not exist
+func (r *ChatRepository) GetByID(id string) (*ChatMessage, error) {
+	query := `
+		SELECT id, COALESCE(identification_id::text, ''), message, sender, COALESCE(model, ''), truncated, care_references, created_at
+		FROM chat_messages
+		WHERE id = $1
+	`
+
+	var message ChatMessage
+	err := withRetry(func() error {
+		return r.db.QueryRow(query, id).Scan(
+			&message.ID,
+			&message.IdentificationID,
+			&message.Message,
+			&message.Sender,
+			&message.Model,
+			&message.Truncated,
+			pq.Array(&message.CareReferences),
+			&message.CreatedAt,
+		)
+	})
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get chat message: %w", err)
+	}
+	message.CreatedAt = message.CreatedAt.UTC()
+
+	return &message, nil
+}
+
+// UpdateMessage replaces the content of a chat message
+func (r *ChatRepository) UpdateMessage(id, message string) error {
+	query := `UPDATE chat_messages SET message = $1 WHERE id = $2`
+
+	result, err := r.db.Exec(query, message, id)
+	if err != nil {
+		return fmt.Errorf("failed to update chat message: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// Delete permanently deletes a chat message
+func (r *ChatRepository) Delete(id string) error {
+	query := `DELETE FROM chat_messages WHERE id = $1`
+
+	result, err := r.db.Exec(query, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete chat message: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return ErrNotFound
+	}
 
 	return nil
 }
@@ -42,13 +123,13 @@ func (r *ChatRepository) Create(message *ChatMessage) error {
 // GetByIdentificationID retrieves all chat messages for a specific identification
 func (r *ChatRepository) GetByIdentificationID(identificationID string) ([]ChatMessage, error) {
 	query := `
-		SELECT id, identification_id, message, sender, created_at
+		SELECT id, identification_id, message, sender, COALESCE(model, ''), truncated, care_references, created_at
 		FROM chat_messages
 		WHERE identification_id = $1
 		ORDER BY created_at ASC
 	`
 
-	rows, err := r.db.Query(query, identificationID)
+	rows, err := queryWithRetry(r.db, query, identificationID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get chat messages: %w", err)
 	}
@@ -62,11 +143,15 @@ func (r *ChatRepository) GetByIdentificationID(identificationID string) ([]ChatM
 			&message.IdentificationID,
 			&message.Message,
 			&message.Sender,
+			&message.Model,
+			&message.Truncated,
+			pq.Array(&message.CareReferences),
 			&message.CreatedAt,
 		)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan chat message: %w", err)
 		}
+		message.CreatedAt = message.CreatedAt.UTC()
 		messages = append(messages, message)
 	}
 
@@ -80,14 +165,14 @@ func (r *ChatRepository) GetByIdentificationID(identificationID string) ([]ChatM
 // GetLatestMessages retrieves the N most recent messages for an identification
 func (r *ChatRepository) GetLatestMessages(identificationID string, limit int) ([]ChatMessage, error) {
 	query := `
-		SELECT id, identification_id, message, sender, created_at
+		SELECT id, identification_id, message, sender, COALESCE(model, ''), truncated, care_references, created_at
 		FROM chat_messages
 		WHERE identification_id = $1
 		ORDER BY created_at DESC
 		LIMIT $2
 	`
 
-	rows, err := r.db.Query(query, identificationID, limit)
+	rows, err := queryWithRetry(r.db, query, identificationID, limit)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get latest chat messages: %w", err)
 	}
@@ -101,11 +186,15 @@ func (r *ChatRepository) GetLatestMessages(identificationID string, limit int) (
 			&message.IdentificationID,
 			&message.Message,
 			&message.Sender,
+			&message.Model,
+			&message.Truncated,
+			pq.Array(&message.CareReferences),
 			&message.CreatedAt,
 		)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan chat message: %w", err)
 		}
+		message.CreatedAt = message.CreatedAt.UTC()
 		messages = append(messages, message)
 	}
 
@@ -125,7 +214,9 @@ func (r *ChatRepository) GetLatestMessages(identificationID string, limit int) (
 func (r *ChatRepository) CountByIdentificationID(identificationID string) (int, error) {
 	var count int
 	query := `SELECT COUNT(*) FROM chat_messages WHERE identification_id = $1`
-	err := r.db.QueryRow(query, identificationID).Scan(&count)
+	err := withRetry(func() error {
+		return r.db.QueryRow(query, identificationID).Scan(&count)
+	})
 	if err != nil {
 		return 0, fmt.Errorf("failed to count chat messages: %w", err)
 	}
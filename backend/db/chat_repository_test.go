@@ -2,6 +2,7 @@ package db
 
 import (
 	"database/sql"
+	"errors"
 	"testing"
 	"time"
 
@@ -39,6 +40,9 @@ func TestChatRepositoryCreate(t *testing.T) {
 						sqlmock.AnyArg(), // identification_id
 						sqlmock.AnyArg(), // message
 						sqlmock.AnyArg(), // sender
+						"",               // model
+						false,            // truncated
+						sqlmock.AnyArg(), // care_references
 						sqlmock.AnyArg(), // created_at
 					).
 					WillReturnRows(sqlmock.NewRows([]string{"id", "created_at"}).
@@ -53,6 +57,7 @@ func TestChatRepositoryCreate(t *testing.T) {
 				IdentificationID: "plant-id-1",
 				Message:          "Water once every 2 weeks in summer.",
 				Sender:           "llm",
+				Model:            "gpt-4o-mini",
 				CreatedAt:        time.Now(),
 			},
 			mockBehavior: func() {
@@ -62,6 +67,9 @@ func TestChatRepositoryCreate(t *testing.T) {
 						sqlmock.AnyArg(),
 						sqlmock.AnyArg(),
 						sqlmock.AnyArg(),
+						"gpt-4o-mini",
+						false,
+						sqlmock.AnyArg(),
 						sqlmock.AnyArg(),
 					).
 					WillReturnRows(sqlmock.NewRows([]string{"id", "created_at"}).
@@ -80,7 +88,7 @@ func TestChatRepositoryCreate(t *testing.T) {
 			},
 			mockBehavior: func() {
 				mock.ExpectQuery("INSERT INTO chat_messages").
-					WillReturnError(sql.ErrConnDone)
+					WillReturnError(errDatabase)
 			},
 			expectError: true,
 		},
@@ -128,11 +136,11 @@ func TestChatRepositoryGetByIdentificationID(t *testing.T) {
 			plantID: "plant-id-1",
 			mockBehavior: func() {
 				rows := sqlmock.NewRows([]string{
-					"id", "identification_id", "message", "sender", "created_at",
+					"id", "identification_id", "message", "sender", "model", "truncated", "care_references", "created_at",
 				}).
-					AddRow("chat-1", "plant-id-1", "User question", "user", time.Now()).
-					AddRow("chat-2", "plant-id-1", "LLM response", "llm", time.Now()).
-					AddRow("chat-3", "plant-id-1", "Follow-up question", "user", time.Now())
+					AddRow("chat-1", "plant-id-1", "User question", "user", "", false, nil, time.Now()).
+					AddRow("chat-2", "plant-id-1", "LLM response", "llm", "gpt-4o-mini", true, "{watering,soil}", time.Now()).
+					AddRow("chat-3", "plant-id-1", "Follow-up question", "user", "", false, nil, time.Now())
 
 				mock.ExpectQuery("SELECT (.+) FROM chat_messages WHERE identification_id").
 					WithArgs("plant-id-1").
@@ -146,7 +154,7 @@ func TestChatRepositoryGetByIdentificationID(t *testing.T) {
 			plantID: "plant-id-2",
 			mockBehavior: func() {
 				rows := sqlmock.NewRows([]string{
-					"id", "identification_id", "message", "sender", "created_at",
+					"id", "identification_id", "message", "sender", "model", "truncated", "care_references", "created_at",
 				})
 
 				mock.ExpectQuery("SELECT (.+) FROM chat_messages WHERE identification_id").
@@ -162,7 +170,7 @@ func TestChatRepositoryGetByIdentificationID(t *testing.T) {
 			mockBehavior: func() {
 				mock.ExpectQuery("SELECT (.+) FROM chat_messages WHERE identification_id").
 					WithArgs("plant-id-3").
-					WillReturnError(sql.ErrConnDone)
+					WillReturnError(errDatabase)
 			},
 			expectError: true,
 			expectedLen: 0,
@@ -187,6 +195,20 @@ func TestChatRepositoryGetByIdentificationID(t *testing.T) {
 				t.Errorf("Expected %d messages, got %d", tt.expectedLen, len(result))
 			}
 
+			// Only assistant messages carry the model that produced them
+			for _, message := range result {
+				expectedModel := ""
+				if message.Sender == "llm" {
+					expectedModel = "gpt-4o-mini"
+				}
+				if message.Model != expectedModel {
+					t.Errorf("Message %s model = %q, expected %q", message.ID, message.Model, expectedModel)
+				}
+				if message.Truncated != (message.ID == "chat-2") {
+					t.Errorf("Message %s truncated = %v", message.ID, message.Truncated)
+				}
+			}
+
 			if err := mock.ExpectationsWereMet(); err != nil {
 				t.Errorf("Unfulfilled expectations: %v", err)
 			}
@@ -217,13 +239,13 @@ func TestChatRepositoryGetLatestMessages(t *testing.T) {
 			limit:   5,
 			mockBehavior: func() {
 				rows := sqlmock.NewRows([]string{
-					"id", "identification_id", "message", "sender", "created_at",
+					"id", "identification_id", "message", "sender", "model", "truncated", "care_references", "created_at",
 				}).
-					AddRow("chat-5", "plant-id-1", "Latest", "user", time.Now()).
-					AddRow("chat-4", "plant-id-1", "Message 4", "llm", time.Now()).
-					AddRow("chat-3", "plant-id-1", "Message 3", "user", time.Now()).
-					AddRow("chat-2", "plant-id-1", "Message 2", "llm", time.Now()).
-					AddRow("chat-1", "plant-id-1", "Message 1", "user", time.Now())
+					AddRow("chat-5", "plant-id-1", "Latest", "user", "", false, nil, time.Now()).
+					AddRow("chat-4", "plant-id-1", "Message 4", "llm", "gpt-4o-mini", false, nil, time.Now()).
+					AddRow("chat-3", "plant-id-1", "Message 3", "user", "", false, nil, time.Now()).
+					AddRow("chat-2", "plant-id-1", "Message 2", "llm", "gpt-4o-mini", false, nil, time.Now()).
+					AddRow("chat-1", "plant-id-1", "Message 1", "user", "", false, nil, time.Now())
 
 				mock.ExpectQuery("SELECT (.+) FROM chat_messages WHERE identification_id (.+) ORDER BY created_at DESC LIMIT").
 					WithArgs("plant-id-1", 5).
@@ -238,11 +260,11 @@ func TestChatRepositoryGetLatestMessages(t *testing.T) {
 			limit:   10,
 			mockBehavior: func() {
 				rows := sqlmock.NewRows([]string{
-					"id", "identification_id", "message", "sender", "created_at",
+					"id", "identification_id", "message", "sender", "model", "truncated", "care_references", "created_at",
 				}).
-					AddRow("chat-3", "plant-id-2", "Message 3", "user", time.Now()).
-					AddRow("chat-2", "plant-id-2", "Message 2", "llm", time.Now()).
-					AddRow("chat-1", "plant-id-2", "Message 1", "user", time.Now())
+					AddRow("chat-3", "plant-id-2", "Message 3", "user", "", false, nil, time.Now()).
+					AddRow("chat-2", "plant-id-2", "Message 2", "llm", "gpt-4o-mini", false, nil, time.Now()).
+					AddRow("chat-1", "plant-id-2", "Message 1", "user", "", false, nil, time.Now())
 
 				mock.ExpectQuery("SELECT (.+) FROM chat_messages WHERE identification_id (.+) ORDER BY created_at DESC LIMIT").
 					WithArgs("plant-id-2", 10).
@@ -258,7 +280,7 @@ func TestChatRepositoryGetLatestMessages(t *testing.T) {
 			mockBehavior: func() {
 				mock.ExpectQuery("SELECT (.+) FROM chat_messages WHERE identification_id (.+) ORDER BY created_at DESC LIMIT").
 					WithArgs("plant-id-3", 5).
-					WillReturnError(sql.ErrConnDone)
+					WillReturnError(errDatabase)
 			},
 			expectError: true,
 			expectedLen: 0,
@@ -336,7 +358,7 @@ func TestChatRepositoryCountByIdentificationID(t *testing.T) {
 			mockBehavior: func() {
 				mock.ExpectQuery("SELECT COUNT\\(\\*\\) FROM chat_messages WHERE identification_id").
 					WithArgs("plant-id-3").
-					WillReturnError(sql.ErrConnDone)
+					WillReturnError(errDatabase)
 			},
 			expectError:   true,
 			expectedCount: 0,
@@ -367,3 +389,49 @@ func TestChatRepositoryCountByIdentificationID(t *testing.T) {
 		})
 	}
 }
+
+func TestChatRepositoryEditMessage(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create mock database: %v", err)
+	}
+	defer db.Close()
+
+	repo := NewChatRepository(db)
+	now := time.Now()
+
+	rows := sqlmock.NewRows([]string{"id", "identification_id", "message", "sender", "model", "truncated", "care_references", "created_at"}).
+		AddRow("msg-1", "plant-1", "Is it toxic?", "user", "", false, nil, now)
+	mock.ExpectQuery("SELECT (.+) FROM chat_messages\\s+WHERE id = \\$1").
+		WithArgs("msg-1").
+		WillReturnRows(rows)
+	message, err := repo.GetByID("msg-1")
+	if err != nil || message.Message != "Is it toxic?" || message.Sender != "user" {
+		t.Errorf("GetByID() = %+v, %v", message, err)
+	}
+
+	mock.ExpectQuery("SELECT (.+) FROM chat_messages\\s+WHERE id = \\$1").
+		WithArgs("missing").
+		WillReturnError(sql.ErrNoRows)
+	if _, err := repo.GetByID("missing"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound, got %v", err)
+	}
+
+	mock.ExpectExec("UPDATE chat_messages SET message = \\$1 WHERE id = \\$2").
+		WithArgs("Is it toxic to cats?", "msg-1").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	if err := repo.UpdateMessage("msg-1", "Is it toxic to cats?"); err != nil {
+		t.Errorf("UpdateMessage() unexpected error: %v", err)
+	}
+
+	mock.ExpectExec("DELETE FROM chat_messages WHERE id = \\$1").
+		WithArgs("msg-2").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	if err := repo.Delete("msg-2"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound deleting a missing message, got %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unfulfilled expectations: %v", err)
+	}
+}
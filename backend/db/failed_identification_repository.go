@@ -0,0 +1,43 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// FailedIdentificationRepository handles database operations for failed identification attempts
+type FailedIdentificationRepository struct {
+	db *sql.DB
+}
+
+// NewFailedIdentificationRepository creates a new failed identification repository
+func NewFailedIdentificationRepository(db *sql.DB) *FailedIdentificationRepository {
+	return &FailedIdentificationRepository{db: db}
+}
+
+// Create records a failed identification attempt
+func (r *FailedIdentificationRepository) Create(failure *FailedIdentification) error {
+	query := `
+		INSERT INTO failed_identifications (id, image_path, error, created_at)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id, created_at
+	`
+
+	// The ID is generated by the caller, so a retried insert cannot create a duplicate
+	err := withRetry(func() error {
+		return r.db.QueryRow(
+			query,
+			failure.ID,
+			failure.ImagePath,
+			failure.Error,
+			failure.CreatedAt,
+		).Scan(&failure.ID, &failure.CreatedAt)
+	})
+
+	if err != nil {
+		return fmt.Errorf("failed to create failed identification: %w", err)
+	}
+	failure.CreatedAt = failure.CreatedAt.UTC()
+
+	return nil
+}
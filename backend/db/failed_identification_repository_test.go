@@ -0,0 +1,51 @@
+package db
+
+import (
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestFailedIdentificationRepositoryCreate(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create mock database: %v", err)
+	}
+	defer db.Close()
+
+	repo := NewFailedIdentificationRepository(db)
+
+	t.Run("Successful create", func(t *testing.T) {
+		failure := &FailedIdentification{
+			ID:        "failure-1",
+			ImagePath: "/uploads/corrupt.jpg",
+			Error:     "ML service returned status 500",
+			CreatedAt: time.Now(),
+		}
+		createdAt := time.Date(2026, 3, 1, 12, 0, 0, 0, time.FixedZone("CET", 3600))
+		mock.ExpectQuery("INSERT INTO failed_identifications").
+			WithArgs("failure-1", "/uploads/corrupt.jpg", "ML service returned status 500", sqlmock.AnyArg()).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "created_at"}).AddRow("failure-1", createdAt))
+
+		if err := repo.Create(failure); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if !failure.CreatedAt.Equal(createdAt) || failure.CreatedAt.Location() != time.UTC {
+			t.Errorf("Expected created_at %v in UTC, got %v", createdAt, failure.CreatedAt)
+		}
+	})
+
+	t.Run("Database error", func(t *testing.T) {
+		mock.ExpectQuery("INSERT INTO failed_identifications").
+			WillReturnError(errDatabase)
+
+		if err := repo.Create(&FailedIdentification{ID: "failure-2"}); err == nil {
+			t.Error("Expected error, got nil")
+		}
+	})
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unfulfilled expectations: %v", err)
+	}
+}
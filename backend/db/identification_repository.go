@@ -4,6 +4,9 @@ import (
 	"database/sql"
 	"encoding/json"
 	"fmt"
+	"time"
+
+	"github.com/lib/pq"
 )
 
 // IdentificationRepository handles database operations for identifications
@@ -24,26 +27,59 @@ func (r *IdentificationRepository) Create(identification *Identification) error
 		return fmt.Errorf("failed to marshal care guide: %w", err)
 	}
 
+	careStatus := identification.CareStatus
+	if careStatus == "" {
+		careStatus = CareStatusReady
+	}
+
+	// Metadata is optional, stored as NULL rather than a JSON null when missing
+	var imageMetadataJSON []byte
+	if identification.ImageMetadata != nil {
+		imageMetadataJSON, err = json.Marshal(identification.ImageMetadata)
+		if err != nil {
+			return fmt.Errorf("failed to marshal image metadata: %w", err)
+		}
+	}
+
+	var boundingBoxJSON []byte
+	if identification.BoundingBox != nil {
+		boundingBoxJSON, err = json.Marshal(identification.BoundingBox)
+		if err != nil {
+			return fmt.Errorf("failed to marshal bounding box: %w", err)
+		}
+	}
+
 	query := `
-		INSERT INTO identifications (id, genus, species, confidence, image_path, care_guide, created_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		INSERT INTO identifications (id, genus, species, confidence, image_path, optimized_image_path, care_guide, care_status, image_hash, image_metadata, parent_id, bounding_box, client_id, created_at)
+		VALUES ($1, $2, $3, $4, $5, NULLIF($6, ''), $7, $8, $9, $10, NULLIF($11, '')::uuid, $12, NULLIF($13, ''), $14)
 		RETURNING id, created_at
 	`
 
-	err = r.db.QueryRow(
-		query,
-		identification.ID,
-		identification.Genus,
-		identification.Species,
-		identification.Confidence,
-		identification.ImagePath,
-		careGuideJSON,
-		identification.CreatedAt,
-	).Scan(&identification.ID, &identification.CreatedAt)
+	// The ID is generated by the caller, so a retried insert cannot create a duplicate
+	err = withRetry(func() error {
+		return r.db.QueryRow(
+			query,
+			identification.ID,
+			identification.Genus,
+			identification.Species,
+			identification.Confidence,
+			identification.ImagePath,
+			identification.OptimizedImagePath,
+			careGuideJSON,
+			careStatus,
+			identification.ImageHash,
+			imageMetadataJSON,
+			identification.ParentID,
+			boundingBoxJSON,
+			identification.ClientID,
+			identification.CreatedAt,
+		).Scan(&identification.ID, &identification.CreatedAt)
+	})
 
 	if err != nil {
 		return fmt.Errorf("failed to create identification: %w", err)
 	}
+	identification.CreatedAt = identification.CreatedAt.UTC()
 
 	return nil
 }
@@ -51,23 +87,30 @@ func (r *IdentificationRepository) Create(identification *Identification) error
 // GetByID retrieves an identification by ID (excludes soft-deleted records)
 func (r *IdentificationRepository) GetByID(id string) (*Identification, error) {
 	query := `
-		SELECT id, genus, species, confidence, image_path, care_guide, created_at
+		SELECT id, genus, species, confidence, image_path, COALESCE(optimized_image_path, ''), care_guide, care_status, image_hash, image_metadata, created_at
 		FROM identifications
 		WHERE id = $1 AND deleted_at IS NULL
 	`
 
 	identification := &Identification{}
-	var careGuideJSON []byte
+	var careGuideJSON, imageMetadataJSON []byte
+	var imageHash sql.NullInt64
 
-	err := r.db.QueryRow(query, id).Scan(
-		&identification.ID,
-		&identification.Genus,
-		&identification.Species,
-		&identification.Confidence,
-		&identification.ImagePath,
-		&careGuideJSON,
-		&identification.CreatedAt,
-	)
+	err := withRetry(func() error {
+		return r.db.QueryRow(query, id).Scan(
+			&identification.ID,
+			&identification.Genus,
+			&identification.Species,
+			&identification.Confidence,
+			&identification.ImagePath,
+			&identification.OptimizedImagePath,
+			&careGuideJSON,
+			&identification.CareStatus,
+			&imageHash,
+			&imageMetadataJSON,
+			&identification.CreatedAt,
+		)
+	})
 
 	if err == sql.ErrNoRows {
 		return nil, fmt.Errorf("identification not found")
@@ -84,21 +127,245 @@ func (r *IdentificationRepository) GetByID(id string) (*Identification, error) {
 		}
 	}
 
+	if len(imageMetadataJSON) > 0 {
+		identification.ImageMetadata = &ImageMetadata{}
+		if err := json.Unmarshal(imageMetadataJSON, identification.ImageMetadata); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal image metadata: %w", err)
+		}
+	}
+
+	if imageHash.Valid {
+		identification.ImageHash = &imageHash.Int64
+	}
+
+	identification.CreatedAt = identification.CreatedAt.UTC()
+
 	return identification, nil
 }
 
+// GetByIDs retrieves the non-deleted identifications among the given IDs,
+// newest first. IDs that do not exist or were deleted are skipped.
+func (r *IdentificationRepository) GetByIDs(ids []string) ([]Identification, error) {
+	identifications := []Identification{}
+	if len(ids) == 0 {
+		return identifications, nil
+	}
+
+	query := `
+		SELECT id, genus, species, confidence, image_path, COALESCE(optimized_image_path, ''), care_guide, created_at
+		FROM identifications
+		WHERE id = ANY($1) AND deleted_at IS NULL
+		ORDER BY created_at DESC
+	`
+
+	rows, err := queryWithRetry(r.db, query, pq.Array(ids))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get identifications: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var identification Identification
+		var careGuideJSON []byte
+
+		err := rows.Scan(
+			&identification.ID,
+			&identification.Genus,
+			&identification.Species,
+			&identification.Confidence,
+			&identification.ImagePath,
+			&identification.OptimizedImagePath,
+			&careGuideJSON,
+			&identification.CreatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan identification: %w", err)
+		}
+
+		// Unmarshal care guide from JSON
+		if len(careGuideJSON) > 0 {
+			identification.CareGuide = &CareGuide{}
+			if err := json.Unmarshal(careGuideJSON, identification.CareGuide); err != nil {
+				return nil, fmt.Errorf("failed to unmarshal care guide: %w", err)
+			}
+		}
+
+		identification.CreatedAt = identification.CreatedAt.UTC()
+		identifications = append(identifications, identification)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating identifications: %w", err)
+	}
+
+	return identifications, nil
+}
+
+// GetCare retrieves only the care guide and care status of an identification
+// (excludes soft-deleted records)
+func (r *IdentificationRepository) GetCare(id string) (*Identification, error) {
+	query := `
+		SELECT id, care_guide, care_status
+		FROM identifications
+		WHERE id = $1 AND deleted_at IS NULL
+	`
+
+	identification := &Identification{}
+	var careGuideJSON []byte
+
+	err := withRetry(func() error {
+		return r.db.QueryRow(query, id).Scan(
+			&identification.ID,
+			&careGuideJSON,
+			&identification.CareStatus,
+		)
+	})
+
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("identification not found")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get identification care: %w", err)
+	}
+
+	// Unmarshal care guide from JSON (null while care is still generating)
+	if len(careGuideJSON) > 0 && string(careGuideJSON) != "null" {
+		identification.CareGuide = &CareGuide{}
+		if err := json.Unmarshal(careGuideJSON, identification.CareGuide); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal care guide: %w", err)
+		}
+	}
+
+	return identification, nil
+}
+
+// UpdateCareGuide stores a care guide and care status for an identification
+func (r *IdentificationRepository) UpdateCareGuide(id string, careGuide *CareGuide, careStatus string) error {
+	careGuideJSON, err := json.Marshal(careGuide)
+	if err != nil {
+		return fmt.Errorf("failed to marshal care guide: %w", err)
+	}
+
+	query := `
+		UPDATE identifications
+		SET care_guide = $1, care_status = $2
+		WHERE id = $3 AND deleted_at IS NULL
+	`
+	result, err := r.db.Exec(query, careGuideJSON, careStatus, id)
+	if err != nil {
+		return fmt.Errorf("failed to update care guide: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("identification not found")
+	}
+
+	return nil
+}
+
+// SaveTiming stores the step durations of the request that created an identification
+func (r *IdentificationRepository) SaveTiming(id string, timing *RequestTiming) error {
+	timingJSON, err := json.Marshal(timing)
+	if err != nil {
+		return fmt.Errorf("failed to marshal timing: %w", err)
+	}
+
+	result, err := r.db.Exec(`UPDATE identifications SET timing = $1 WHERE id = $2`, timingJSON, id)
+	if err != nil {
+		return fmt.Errorf("failed to save timing: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("identification not found")
+	}
+
+	return nil
+}
+
+// ListTimings returns the most recent non-deleted identifications with
+// recorded request timing, newest first, with only their ID, plant, timing
+// and creation time set
+func (r *IdentificationRepository) ListTimings(limit int) ([]Identification, error) {
+	query := `
+		SELECT id, genus, species, timing, created_at
+		FROM identifications
+		WHERE deleted_at IS NULL AND timing IS NOT NULL
+		ORDER BY created_at DESC
+		LIMIT $1
+	`
+
+	rows, err := queryWithRetry(r.db, query, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list timings: %w", err)
+	}
+	defer rows.Close()
+
+	identifications := []Identification{}
+	for rows.Next() {
+		var identification Identification
+		var timingJSON []byte
+		if err := rows.Scan(&identification.ID, &identification.Genus, &identification.Species, &timingJSON, &identification.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan timing: %w", err)
+		}
+		identification.Timing = &RequestTiming{}
+		if err := json.Unmarshal(timingJSON, identification.Timing); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal timing: %w", err)
+		}
+		identification.CreatedAt = identification.CreatedAt.UTC()
+		identifications = append(identifications, identification)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating timings: %w", err)
+	}
+
+	return identifications, nil
+}
+
+// Touch moves an identification's timestamp to at, e.g. when re-identifying
+// it produced the same result and no new record was created
+func (r *IdentificationRepository) Touch(id string, at time.Time) error {
+	query := `
+		UPDATE identifications
+		SET created_at = $1
+		WHERE id = $2 AND deleted_at IS NULL
+	`
+	result, err := r.db.Exec(query, at, id)
+	if err != nil {
+		return fmt.Errorf("failed to update identification timestamp: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("identification not found")
+	}
+
+	return nil
+}
+
 // GetAll retrieves all identifications ordered by creation date (newest first)
 // Excludes soft-deleted records
 func (r *IdentificationRepository) GetAll(limit, offset int) ([]Identification, error) {
 	query := `
-		SELECT id, genus, species, confidence, image_path, care_guide, created_at
+		SELECT id, genus, species, confidence, image_path, COALESCE(optimized_image_path, ''), care_guide, created_at
 		FROM identifications
 		WHERE deleted_at IS NULL
 		ORDER BY created_at DESC
 		LIMIT $1 OFFSET $2
 	`
 
-	rows, err := r.db.Query(query, limit, offset)
+	rows, err := queryWithRetry(r.db, query, limit, offset)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get identifications: %w", err)
 	}
@@ -115,6 +382,7 @@ func (r *IdentificationRepository) GetAll(limit, offset int) ([]Identification,
 			&identification.Species,
 			&identification.Confidence,
 			&identification.ImagePath,
+			&identification.OptimizedImagePath,
 			&careGuideJSON,
 			&identification.CreatedAt,
 		)
@@ -130,6 +398,7 @@ func (r *IdentificationRepository) GetAll(limit, offset int) ([]Identification,
 			}
 		}
 
+		identification.CreatedAt = identification.CreatedAt.UTC()
 		identifications = append(identifications, identification)
 	}
 
@@ -140,17 +409,373 @@ func (r *IdentificationRepository) GetAll(limit, offset int) ([]Identification,
 	return identifications, nil
 }
 
+// GetAllByGenus retrieves the non-deleted identifications of a page of genera
+// (in alphabetical order) without their care guides, ordered by genus and
+// newest first within each genus
+func (r *IdentificationRepository) GetAllByGenus(limit, offset int) ([]Identification, error) {
+	query := `
+		WITH genera AS (
+			SELECT DISTINCT genus
+			FROM identifications
+			WHERE deleted_at IS NULL
+			ORDER BY genus
+			LIMIT $1 OFFSET $2
+		)
+		SELECT i.id, i.genus, i.species, i.confidence, i.image_path, COALESCE(i.optimized_image_path, ''), i.created_at
+		FROM identifications AS i
+		JOIN genera ON genera.genus = i.genus
+		WHERE i.deleted_at IS NULL
+		ORDER BY i.genus, i.created_at DESC
+	`
+
+	rows, err := queryWithRetry(r.db, query, limit, offset)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get identifications by genus: %w", err)
+	}
+	defer rows.Close()
+
+	identifications := []Identification{}
+	for rows.Next() {
+		var identification Identification
+		err := rows.Scan(
+			&identification.ID,
+			&identification.Genus,
+			&identification.Species,
+			&identification.Confidence,
+			&identification.ImagePath,
+			&identification.OptimizedImagePath,
+			&identification.CreatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan identification: %w", err)
+		}
+
+		identification.CreatedAt = identification.CreatedAt.UTC()
+		identifications = append(identifications, identification)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating identifications: %w", err)
+	}
+
+	return identifications, nil
+}
+
+// GetAllStream invokes fn for every non-deleted identification, newest first,
+// scanning one row at a time so memory stays flat regardless of history size.
+// Iteration stops at the first error returned by fn, which is returned as-is.
+func (r *IdentificationRepository) GetAllStream(fn func(Identification) error) error {
+	query := `
+		SELECT id, genus, species, confidence, image_path, care_guide, created_at
+		FROM identifications
+		WHERE deleted_at IS NULL
+		ORDER BY created_at DESC
+	`
+
+	rows, err := queryWithRetry(r.db, query)
+	if err != nil {
+		return fmt.Errorf("failed to get identifications: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var identification Identification
+		var careGuideJSON []byte
+
+		err := rows.Scan(
+			&identification.ID,
+			&identification.Genus,
+			&identification.Species,
+			&identification.Confidence,
+			&identification.ImagePath,
+			&careGuideJSON,
+			&identification.CreatedAt,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to scan identification: %w", err)
+		}
+
+		// Unmarshal care guide from JSON
+		if len(careGuideJSON) > 0 {
+			identification.CareGuide = &CareGuide{}
+			if err := json.Unmarshal(careGuideJSON, identification.CareGuide); err != nil {
+				return fmt.Errorf("failed to unmarshal care guide: %w", err)
+			}
+		}
+
+		identification.CreatedAt = identification.CreatedAt.UTC()
+		if err := fn(identification); err != nil {
+			return err
+		}
+	}
+
+	if err = rows.Err(); err != nil {
+		return fmt.Errorf("error iterating identifications: %w", err)
+	}
+
+	return nil
+}
+
+// FindSimilar returns non-deleted identifications whose image hash is within
+// the given Hamming distance of hash, most recent first. The distance cannot
+// use an index, so this scans every hashed identification; bit_count needs
+// PostgreSQL 14 or higher.
+func (r *IdentificationRepository) FindSimilar(hash int64, distance int) ([]Identification, error) {
+	query := `
+		SELECT id, genus, species, confidence, image_path, created_at
+		FROM identifications
+		WHERE deleted_at IS NULL
+		  AND image_hash IS NOT NULL
+		  AND bit_count((image_hash # $1)::bit(64)) <= $2
+		ORDER BY created_at DESC
+		LIMIT 5
+	`
+
+	rows, err := queryWithRetry(r.db, query, hash, distance)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find similar identifications: %w", err)
+	}
+	defer rows.Close()
+
+	identifications := []Identification{}
+	for rows.Next() {
+		var identification Identification
+		err := rows.Scan(
+			&identification.ID,
+			&identification.Genus,
+			&identification.Species,
+			&identification.Confidence,
+			&identification.ImagePath,
+			&identification.CreatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan identification: %w", err)
+		}
+		identification.CreatedAt = identification.CreatedAt.UTC()
+		identifications = append(identifications, identification)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating identifications: %w", err)
+	}
+
+	return identifications, nil
+}
+
+// FindRecentSameSpecies returns the newest non-deleted identification of
+// genus and species made by clientID at or after since, or ErrNotFound
+func (r *IdentificationRepository) FindRecentSameSpecies(clientID, genus, species string, since time.Time) (*Identification, error) {
+	query := `
+		SELECT id, genus, species, confidence, image_path, COALESCE(optimized_image_path, ''), care_guide, care_status, created_at
+		FROM identifications
+		WHERE deleted_at IS NULL
+		  AND client_id = $1
+		  AND genus = $2
+		  AND species = $3
+		  AND created_at >= $4
+		ORDER BY created_at DESC
+		LIMIT 1
+	`
+
+	identification := &Identification{ClientID: clientID}
+	var careGuideJSON []byte
+
+	err := withRetry(func() error {
+		return r.db.QueryRow(query, clientID, genus, species, since).Scan(
+			&identification.ID,
+			&identification.Genus,
+			&identification.Species,
+			&identification.Confidence,
+			&identification.ImagePath,
+			&identification.OptimizedImagePath,
+			&careGuideJSON,
+			&identification.CareStatus,
+			&identification.CreatedAt,
+		)
+	})
+
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to find recent identification: %w", err)
+	}
+
+	// Unmarshal care guide from JSON (null while care is still generating)
+	if len(careGuideJSON) > 0 && string(careGuideJSON) != "null" {
+		identification.CareGuide = &CareGuide{}
+		if err := json.Unmarshal(careGuideJSON, identification.CareGuide); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal care guide: %w", err)
+		}
+	}
+
+	identification.CreatedAt = identification.CreatedAt.UTC()
+
+	return identification, nil
+}
+
 // Count returns the total number of non-deleted identifications
 func (r *IdentificationRepository) Count() (int, error) {
 	var count int
 	query := `SELECT COUNT(*) FROM identifications WHERE deleted_at IS NULL`
-	err := r.db.QueryRow(query).Scan(&count)
+	err := withRetry(func() error {
+		return r.db.QueryRow(query).Scan(&count)
+	})
 	if err != nil {
 		return 0, fmt.Errorf("failed to count identifications: %w", err)
 	}
 	return count, nil
 }
 
+// CountGenera returns the number of distinct genera among non-deleted identifications
+func (r *IdentificationRepository) CountGenera() (int, error) {
+	var count int
+	query := `SELECT COUNT(DISTINCT genus) FROM identifications WHERE deleted_at IS NULL`
+	err := withRetry(func() error {
+		return r.db.QueryRow(query).Scan(&count)
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to count genera: %w", err)
+	}
+	return count, nil
+}
+
+// CountBySpecies returns every distinct genus and species among non-deleted
+// identifications with how often it was identified, most identified first
+func (r *IdentificationRepository) CountBySpecies() ([]SpeciesCount, error) {
+	query := `
+		SELECT genus, species, COUNT(*)
+		FROM identifications
+		WHERE deleted_at IS NULL
+		GROUP BY genus, species
+		ORDER BY COUNT(*) DESC, genus, species
+	`
+
+	rows, err := queryWithRetry(r.db, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count identifications by species: %w", err)
+	}
+	defer rows.Close()
+
+	counts := []SpeciesCount{}
+	for rows.Next() {
+		var count SpeciesCount
+		if err := rows.Scan(&count.Genus, &count.Species, &count.Count); err != nil {
+			return nil, fmt.Errorf("failed to scan species count: %w", err)
+		}
+		counts = append(counts, count)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating species counts: %w", err)
+	}
+
+	return counts, nil
+}
+
+// careClaimTTL is how long an identification claimed by a care backfill is
+// skipped by other backfills
+const careClaimTTL = 15 * time.Minute
+
+// GetMissingCare claims up to limit non-deleted identifications stored
+// without a care guide (null or empty JSON), oldest first, for a care
+// backfill. Rows locked or claimed by another backfill within careClaimTTL are
+// skipped, so concurrent runs do not process the same identifications; a
+// claim that is never filled expires and the row is claimed again later.
+// Uncertain identifications, which intentionally have no care, are not included.
+func (r *IdentificationRepository) GetMissingCare(limit int) ([]Identification, error) {
+	query := `
+		WITH claimed AS (
+			UPDATE identifications
+			SET care_claimed_at = CURRENT_TIMESTAMP
+			WHERE id IN (
+				SELECT id
+				FROM identifications
+				WHERE deleted_at IS NULL
+					AND care_status <> $1
+					AND (care_guide IS NULL OR care_guide = 'null'::jsonb OR care_guide = '{}'::jsonb)
+					AND (care_claimed_at IS NULL OR care_claimed_at < CURRENT_TIMESTAMP - $3 * INTERVAL '1 second')
+				ORDER BY created_at
+				LIMIT $2
+				FOR UPDATE SKIP LOCKED
+			)
+			RETURNING id, genus, species, care_status, created_at
+		)
+		SELECT id, genus, species, care_status
+		FROM claimed
+		ORDER BY created_at
+	`
+
+	rows, err := r.db.Query(query, CareStatusNone, limit, int(careClaimTTL.Seconds()))
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim identifications missing care: %w", err)
+	}
+	defer rows.Close()
+
+	identifications := []Identification{}
+	for rows.Next() {
+		var identification Identification
+		if err := rows.Scan(&identification.ID, &identification.Genus, &identification.Species, &identification.CareStatus); err != nil {
+			return nil, fmt.Errorf("failed to scan identification: %w", err)
+		}
+		identifications = append(identifications, identification)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating identifications: %w", err)
+	}
+
+	return identifications, nil
+}
+
+// DeleteOlderThan soft deletes every identification created before cutoff and
+// returns the image paths (original and optimized) of the affected records so
+// their files can be removed. Paths still used by an identification that stays
+// live, such as a re-identification of the same upload, are left out.
+func (r *IdentificationRepository) DeleteOlderThan(cutoff time.Time) ([]string, error) {
+	// The outer query sees the table as it was before the update, so the rows
+	// staying live are the undeleted ones created at or after cutoff
+	query := `
+		WITH expired AS (
+			UPDATE identifications
+			SET deleted_at = CURRENT_TIMESTAMP
+			WHERE deleted_at IS NULL AND created_at < $1
+			RETURNING image_path, optimized_image_path
+		), paths AS (
+			SELECT image_path AS path FROM expired
+			UNION
+			SELECT optimized_image_path FROM expired WHERE optimized_image_path <> ''
+		)
+		SELECT path FROM paths
+		WHERE NOT EXISTS (
+			SELECT 1 FROM identifications AS live
+			WHERE live.deleted_at IS NULL AND live.created_at >= $1
+			AND (live.image_path = paths.path OR live.optimized_image_path = paths.path)
+		)
+	`
+
+	rows, err := r.db.Query(query, cutoff)
+	if err != nil {
+		return nil, fmt.Errorf("failed to delete expired identifications: %w", err)
+	}
+	defer rows.Close()
+
+	imagePaths := []string{}
+	for rows.Next() {
+		var imagePath string
+		if err := rows.Scan(&imagePath); err != nil {
+			return nil, fmt.Errorf("failed to scan image path: %w", err)
+		}
+		imagePaths = append(imagePaths, imagePath)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating expired identifications: %w", err)
+	}
+
+	return imagePaths, nil
+}
+
 // Delete performs a soft delete by setting deleted_at timestamp
 func (r *IdentificationRepository) Delete(id string) error {
 	query := `
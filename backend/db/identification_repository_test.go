@@ -2,6 +2,9 @@ package db
 
 import (
 	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"testing"
 	"time"
 
@@ -48,7 +51,14 @@ func TestIdentificationRepositoryCreate(t *testing.T) {
 						sqlmock.AnyArg(), // species
 						sqlmock.AnyArg(), // confidence
 						sqlmock.AnyArg(), // image_path
+						sqlmock.AnyArg(), // optimized_image_path
 						sqlmock.AnyArg(), // care_guide JSON
+						sqlmock.AnyArg(), // care_status
+						sqlmock.AnyArg(), // image_hash
+						sqlmock.AnyArg(), // image_metadata JSON
+						sqlmock.AnyArg(), // parent_id
+						sqlmock.AnyArg(), // bounding_box JSON
+						sqlmock.AnyArg(), // client_id
 						sqlmock.AnyArg(), // created_at
 					).
 					WillReturnRows(sqlmock.NewRows([]string{"id", "created_at"}).
@@ -75,7 +85,14 @@ func TestIdentificationRepositoryCreate(t *testing.T) {
 						sqlmock.AnyArg(),
 						sqlmock.AnyArg(),
 						sqlmock.AnyArg(),
+						"",             // no optimized image, stored as NULL by NULLIF
 						[]byte("null"), // JSON null
+						CareStatusReady,
+						sqlmock.AnyArg(),
+						[]byte(nil), // no image metadata is stored as NULL
+						"",          // no parent, stored as NULL by NULLIF
+						[]byte(nil), // no bounding box is stored as NULL
+						"",          // no client, stored as NULL by NULLIF
 						sqlmock.AnyArg(),
 					).
 					WillReturnRows(sqlmock.NewRows([]string{"id", "created_at"}).
@@ -83,6 +100,41 @@ func TestIdentificationRepositoryCreate(t *testing.T) {
 			},
 			expectError: false,
 		},
+		{
+			name: "Create detected plant linked to its parent",
+			identification: &Identification{
+				ID:          "test-uuid-4",
+				Genus:       "Echeveria",
+				Species:     "elegans",
+				Confidence:  0.8,
+				ImagePath:   "/uploads/group.jpg",
+				ParentID:    "test-uuid-1",
+				BoundingBox: &BoundingBox{X: 10, Y: 20, Width: 100, Height: 80},
+				CreatedAt:   time.Now(),
+			},
+			mockBehavior: func() {
+				mock.ExpectQuery("INSERT INTO identifications").
+					WithArgs(
+						"test-uuid-4",
+						sqlmock.AnyArg(),
+						sqlmock.AnyArg(),
+						sqlmock.AnyArg(),
+						sqlmock.AnyArg(),
+						sqlmock.AnyArg(),
+						sqlmock.AnyArg(),
+						sqlmock.AnyArg(),
+						sqlmock.AnyArg(),
+						sqlmock.AnyArg(),
+						"test-uuid-1",
+						[]byte(`{"x":10,"y":20,"width":100,"height":80}`),
+						sqlmock.AnyArg(),
+						sqlmock.AnyArg(),
+					).
+					WillReturnRows(sqlmock.NewRows([]string{"id", "created_at"}).
+						AddRow("test-uuid-4", time.Now()))
+			},
+			expectError: false,
+		},
 		{
 			name: "Database error",
 			identification: &Identification{
@@ -96,7 +148,7 @@ func TestIdentificationRepositoryCreate(t *testing.T) {
 			},
 			mockBehavior: func() {
 				mock.ExpectQuery("INSERT INTO identifications").
-					WillReturnError(sql.ErrConnDone)
+					WillReturnError(errDatabase)
 			},
 			expectError: true,
 		},
@@ -144,14 +196,18 @@ func TestIdentificationRepositoryGetByID(t *testing.T) {
 			id:   "test-uuid-1",
 			mockBehavior: func() {
 				rows := sqlmock.NewRows([]string{
-					"id", "genus", "species", "confidence", "image_path", "care_guide", "created_at",
+					"id", "genus", "species", "confidence", "image_path", "optimized_image_path", "care_guide", "care_status", "image_hash", "image_metadata", "created_at",
 				}).AddRow(
 					"test-uuid-1",
 					"Haworthia",
 					"zebrina",
 					0.95,
 					"/uploads/test.jpg",
+					"/uploads/test_web.jpg",
 					[]byte(`{"sunlight":"Bright indirect light","watering":"Water when dry","soil":"Well-draining","notes":"Easy care"}`),
+					CareStatusGenerating,
+					int64(-8512981244239201365),
+					[]byte(`{"width":640,"height":480,"format":"jpeg","size":52311}`),
 					time.Now(),
 				)
 
@@ -179,7 +235,7 @@ func TestIdentificationRepositoryGetByID(t *testing.T) {
 			mockBehavior: func() {
 				mock.ExpectQuery("SELECT (.+) FROM identifications WHERE id").
 					WithArgs("test-uuid-2").
-					WillReturnError(sql.ErrConnDone)
+					WillReturnError(errDatabase)
 			},
 			expectError: true,
 			expectNil:   false,
@@ -216,6 +272,16 @@ func TestIdentificationRepositoryGetByID(t *testing.T) {
 				if result.Genus == "" {
 					t.Error("Expected non-empty genus")
 				}
+				if result.ImageMetadata == nil || result.ImageMetadata.Width != 640 || result.ImageMetadata.Format != "jpeg" {
+					t.Errorf("Expected image metadata to be loaded, got %+v", result.ImageMetadata)
+				}
+				// Re-identification saves new records with the stored hash and reports the stored status
+				if result.ImageHash == nil || *result.ImageHash != -8512981244239201365 {
+					t.Errorf("Expected image hash to be loaded, got %v", result.ImageHash)
+				}
+				if result.CareStatus != CareStatusGenerating {
+					t.Errorf("Expected care status %q, got %q", CareStatusGenerating, result.CareStatus)
+				}
 			}
 
 			if err := mock.ExpectationsWereMet(); err != nil {
@@ -248,12 +314,12 @@ func TestIdentificationRepositoryGetAll(t *testing.T) {
 			offset: 0,
 			mockBehavior: func() {
 				rows := sqlmock.NewRows([]string{
-					"id", "genus", "species", "confidence", "image_path", "care_guide", "created_at",
+					"id", "genus", "species", "confidence", "image_path", "optimized_image_path", "care_guide", "created_at",
 				}).
-					AddRow("id1", "Haworthia", "zebrina", 0.95, "/uploads/1.jpg", []byte(`{"sunlight":"test"}`), time.Now()).
-					AddRow("id2", "Aloe", "vera", 0.85, "/uploads/2.jpg", []byte(`{"sunlight":"test"}`), time.Now())
+					AddRow("id1", "Haworthia", "zebrina", 0.95, "/uploads/1.jpg", "/uploads/1_web.jpg", []byte(`{"sunlight":"test"}`), time.Now()).
+					AddRow("id2", "Aloe", "vera", 0.85, "/uploads/2.jpg", "", []byte(`{"sunlight":"test"}`), time.Now())
 
-				mock.ExpectQuery("SELECT (.+) FROM identifications ORDER BY created_at DESC LIMIT (.+) OFFSET").
+				mock.ExpectQuery("SELECT (.+) FROM identifications WHERE deleted_at IS NULL ORDER BY created_at DESC LIMIT (.+) OFFSET").
 					WithArgs(10, 0).
 					WillReturnRows(rows)
 			},
@@ -266,10 +332,10 @@ func TestIdentificationRepositoryGetAll(t *testing.T) {
 			offset: 100,
 			mockBehavior: func() {
 				rows := sqlmock.NewRows([]string{
-					"id", "genus", "species", "confidence", "image_path", "care_guide", "created_at",
+					"id", "genus", "species", "confidence", "image_path", "optimized_image_path", "care_guide", "created_at",
 				})
 
-				mock.ExpectQuery("SELECT (.+) FROM identifications ORDER BY created_at DESC LIMIT (.+) OFFSET").
+				mock.ExpectQuery("SELECT (.+) FROM identifications WHERE deleted_at IS NULL ORDER BY created_at DESC LIMIT (.+) OFFSET").
 					WithArgs(10, 100).
 					WillReturnRows(rows)
 			},
@@ -281,9 +347,9 @@ func TestIdentificationRepositoryGetAll(t *testing.T) {
 			limit:  10,
 			offset: 0,
 			mockBehavior: func() {
-				mock.ExpectQuery("SELECT (.+) FROM identifications ORDER BY created_at DESC LIMIT (.+) OFFSET").
+				mock.ExpectQuery("SELECT (.+) FROM identifications WHERE deleted_at IS NULL ORDER BY created_at DESC LIMIT (.+) OFFSET").
 					WithArgs(10, 0).
-					WillReturnError(sql.ErrConnDone)
+					WillReturnError(errDatabase)
 			},
 			expectError: true,
 			expectedLen: 0,
@@ -354,7 +420,7 @@ func TestIdentificationRepositoryCount(t *testing.T) {
 			name: "Database error",
 			mockBehavior: func() {
 				mock.ExpectQuery("SELECT COUNT\\(\\*\\) FROM identifications").
-					WillReturnError(sql.ErrConnDone)
+					WillReturnError(errDatabase)
 			},
 			expectError:   true,
 			expectedCount: 0,
@@ -385,3 +451,791 @@ func TestIdentificationRepositoryCount(t *testing.T) {
 		})
 	}
 }
+
+func TestIdentificationRepositoryFindSimilar(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create mock database: %v", err)
+	}
+	defer db.Close()
+
+	repo := NewIdentificationRepository(db)
+
+	tests := []struct {
+		name         string
+		hash         int64
+		distance     int
+		mockBehavior func()
+		expectError  bool
+		expectedLen  int
+	}{
+		{
+			name:     "Similar images found",
+			hash:     0x0F0F0F0F0F0F0F0F,
+			distance: 10,
+			mockBehavior: func() {
+				rows := sqlmock.NewRows([]string{
+					"id", "genus", "species", "confidence", "image_path", "created_at",
+				}).
+					AddRow("id1", "haworthia", "haworthia_zebrina", 0.95, "/uploads/1.jpg", time.Now())
+
+				mock.ExpectQuery("SELECT (.+) FROM identifications WHERE deleted_at IS NULL (.+) bit_count").
+					WithArgs(int64(0x0F0F0F0F0F0F0F0F), 10).
+					WillReturnRows(rows)
+			},
+			expectError: false,
+			expectedLen: 1,
+		},
+		{
+			name:     "No similar images",
+			hash:     42,
+			distance: 10,
+			mockBehavior: func() {
+				rows := sqlmock.NewRows([]string{
+					"id", "genus", "species", "confidence", "image_path", "created_at",
+				})
+
+				mock.ExpectQuery("SELECT (.+) FROM identifications WHERE deleted_at IS NULL (.+) bit_count").
+					WithArgs(int64(42), 10).
+					WillReturnRows(rows)
+			},
+			expectError: false,
+			expectedLen: 0,
+		},
+		{
+			name:     "Database error",
+			hash:     42,
+			distance: 10,
+			mockBehavior: func() {
+				mock.ExpectQuery("SELECT (.+) FROM identifications WHERE deleted_at IS NULL (.+) bit_count").
+					WithArgs(int64(42), 10).
+					WillReturnError(errDatabase)
+			},
+			expectError: true,
+			expectedLen: 0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mockBehavior()
+
+			result, err := repo.FindSimilar(tt.hash, tt.distance)
+
+			if tt.expectError && err == nil {
+				t.Error("Expected error but got none")
+			}
+
+			if !tt.expectError && err != nil {
+				t.Errorf("Unexpected error: %v", err)
+			}
+
+			if len(result) != tt.expectedLen {
+				t.Errorf("Expected %d results, got %d", tt.expectedLen, len(result))
+			}
+
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Errorf("Unfulfilled expectations: %v", err)
+			}
+		})
+	}
+}
+
+func TestIdentificationRepositoryFindRecentSameSpecies(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create mock database: %v", err)
+	}
+	defer db.Close()
+
+	repo := NewIdentificationRepository(db)
+	since := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	columns := []string{
+		"id", "genus", "species", "confidence", "image_path", "optimized_image_path", "care_guide", "care_status", "created_at",
+	}
+
+	t.Run("Recent identification found", func(t *testing.T) {
+		mock.ExpectQuery("SELECT (.+) FROM identifications WHERE deleted_at IS NULL (.+) client_id = \\$1").
+			WithArgs("203.0.113.7", "Haworthia", "zebrina", since).
+			WillReturnRows(sqlmock.NewRows(columns).
+				AddRow("id1", "Haworthia", "zebrina", 0.95, "/uploads/1.jpg", "", []byte(`{"sunlight":"Bright"}`), CareStatusReady, since.Add(time.Minute)))
+
+		identification, err := repo.FindRecentSameSpecies("203.0.113.7", "Haworthia", "zebrina", since)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if identification.ID != "id1" || identification.CareGuide == nil || identification.CareGuide.Sunlight != "Bright" {
+			t.Errorf("Unexpected identification: %+v", identification)
+		}
+	})
+
+	t.Run("No recent identification", func(t *testing.T) {
+		mock.ExpectQuery("SELECT (.+) FROM identifications WHERE deleted_at IS NULL").
+			WithArgs("203.0.113.7", "Aloe", "vera", since).
+			WillReturnRows(sqlmock.NewRows(columns))
+
+		_, err := repo.FindRecentSameSpecies("203.0.113.7", "Aloe", "vera", since)
+		if !errors.Is(err, ErrNotFound) {
+			t.Errorf("Expected ErrNotFound, got %v", err)
+		}
+	})
+
+	t.Run("Database error", func(t *testing.T) {
+		mock.ExpectQuery("SELECT (.+) FROM identifications WHERE deleted_at IS NULL").
+			WillReturnError(errDatabase)
+
+		if _, err := repo.FindRecentSameSpecies("203.0.113.7", "Aloe", "vera", since); err == nil || errors.Is(err, ErrNotFound) {
+			t.Errorf("Expected database error, got %v", err)
+		}
+	})
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unfulfilled expectations: %v", err)
+	}
+}
+
+func TestIdentificationRepositoryGetCare(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create mock database: %v", err)
+	}
+	defer db.Close()
+
+	repo := NewIdentificationRepository(db)
+
+	tests := []struct {
+		name           string
+		mockBehavior   func()
+		expectError    bool
+		expectedStatus string
+		expectCare     bool
+	}{
+		{
+			name: "Care still generating",
+			mockBehavior: func() {
+				rows := sqlmock.NewRows([]string{"id", "care_guide", "care_status"}).
+					AddRow("id1", []byte("null"), CareStatusGenerating)
+				mock.ExpectQuery("SELECT id, care_guide, care_status FROM identifications WHERE id").
+					WithArgs("id1").
+					WillReturnRows(rows)
+			},
+			expectedStatus: CareStatusGenerating,
+			expectCare:     false,
+		},
+		{
+			name: "Care ready",
+			mockBehavior: func() {
+				rows := sqlmock.NewRows([]string{"id", "care_guide", "care_status"}).
+					AddRow("id1", []byte(`{"sunlight":"test"}`), CareStatusReady)
+				mock.ExpectQuery("SELECT id, care_guide, care_status FROM identifications WHERE id").
+					WithArgs("id1").
+					WillReturnRows(rows)
+			},
+			expectedStatus: CareStatusReady,
+			expectCare:     true,
+		},
+		{
+			name: "Not found",
+			mockBehavior: func() {
+				mock.ExpectQuery("SELECT id, care_guide, care_status FROM identifications WHERE id").
+					WithArgs("id1").
+					WillReturnError(sql.ErrNoRows)
+			},
+			expectError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mockBehavior()
+
+			result, err := repo.GetCare("id1")
+
+			if tt.expectError {
+				if err == nil {
+					t.Error("Expected error but got none")
+				}
+			} else if err != nil {
+				t.Errorf("Unexpected error: %v", err)
+			} else {
+				if result.CareStatus != tt.expectedStatus {
+					t.Errorf("Expected status %s, got %s", tt.expectedStatus, result.CareStatus)
+				}
+				if (result.CareGuide != nil) != tt.expectCare {
+					t.Errorf("Expected care present = %v, got %+v", tt.expectCare, result.CareGuide)
+				}
+			}
+
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Errorf("Unfulfilled expectations: %v", err)
+			}
+		})
+	}
+}
+
+func TestIdentificationRepositoryUpdateCareGuide(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create mock database: %v", err)
+	}
+	defer db.Close()
+
+	repo := NewIdentificationRepository(db)
+
+	tests := []struct {
+		name         string
+		mockBehavior func()
+		expectError  bool
+	}{
+		{
+			name: "Successful update",
+			mockBehavior: func() {
+				mock.ExpectExec("UPDATE identifications SET care_guide").
+					WithArgs(sqlmock.AnyArg(), CareStatusReady, "id1").
+					WillReturnResult(sqlmock.NewResult(0, 1))
+			},
+			expectError: false,
+		},
+		{
+			name: "Identification not found",
+			mockBehavior: func() {
+				mock.ExpectExec("UPDATE identifications SET care_guide").
+					WithArgs(sqlmock.AnyArg(), CareStatusReady, "id1").
+					WillReturnResult(sqlmock.NewResult(0, 0))
+			},
+			expectError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mockBehavior()
+
+			err := repo.UpdateCareGuide("id1", &CareGuide{Sunlight: "test"}, CareStatusReady)
+
+			if tt.expectError && err == nil {
+				t.Error("Expected error but got none")
+			}
+
+			if !tt.expectError && err != nil {
+				t.Errorf("Unexpected error: %v", err)
+			}
+
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Errorf("Unfulfilled expectations: %v", err)
+			}
+		})
+	}
+}
+
+func TestIdentificationRepositoryTouch(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create mock database: %v", err)
+	}
+	defer db.Close()
+
+	repo := NewIdentificationRepository(db)
+	at := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	mock.ExpectExec("UPDATE identifications SET created_at").
+		WithArgs(at, "id1").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	if err := repo.Touch("id1", at); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+
+	mock.ExpectExec("UPDATE identifications SET created_at").
+		WithArgs(at, "missing").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	if err := repo.Touch("missing", at); err == nil {
+		t.Error("Expected error for a missing identification")
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unfulfilled expectations: %v", err)
+	}
+}
+
+func TestIdentificationRepositoryGetByIDs(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create mock database: %v", err)
+	}
+	defer db.Close()
+
+	repo := NewIdentificationRepository(db)
+
+	columns := []string{"id", "genus", "species", "confidence", "image_path", "optimized_image_path", "care_guide", "created_at"}
+
+	tests := []struct {
+		name         string
+		ids          []string
+		mockBehavior func()
+		expectError  bool
+		expectedIDs  []string
+	}{
+		{
+			name: "Mix of present and absent IDs",
+			ids:  []string{"id1", "missing", "id2"},
+			mockBehavior: func() {
+				rows := sqlmock.NewRows(columns).
+					AddRow("id2", "echeveria", "echeveria_elegans", 0.88, "/uploads/2.jpg", "", []byte(`{"sunlight":"Full sun"}`), time.Now()).
+					AddRow("id1", "haworthia", "haworthia_zebrina", 0.95, "/uploads/1.jpg", "", nil, time.Now())
+
+				mock.ExpectQuery("SELECT (.+) FROM identifications WHERE id = ANY\\(\\$1\\) AND deleted_at IS NULL").
+					WithArgs(sqlmock.AnyArg()).
+					WillReturnRows(rows)
+			},
+			expectError: false,
+			expectedIDs: []string{"id2", "id1"},
+		},
+		{
+			name: "No IDs found",
+			ids:  []string{"missing"},
+			mockBehavior: func() {
+				mock.ExpectQuery("SELECT (.+) FROM identifications WHERE id = ANY\\(\\$1\\) AND deleted_at IS NULL").
+					WithArgs(sqlmock.AnyArg()).
+					WillReturnRows(sqlmock.NewRows(columns))
+			},
+			expectError: false,
+			expectedIDs: []string{},
+		},
+		{
+			name:         "Empty ID list skips query",
+			ids:          []string{},
+			mockBehavior: func() {},
+			expectError:  false,
+			expectedIDs:  []string{},
+		},
+		{
+			name: "Database error",
+			ids:  []string{"id1"},
+			mockBehavior: func() {
+				mock.ExpectQuery("SELECT (.+) FROM identifications WHERE id = ANY").
+					WithArgs(sqlmock.AnyArg()).
+					WillReturnError(errDatabase)
+			},
+			expectError: true,
+			expectedIDs: nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mockBehavior()
+
+			result, err := repo.GetByIDs(tt.ids)
+
+			if tt.expectError && err == nil {
+				t.Error("Expected error but got none")
+			}
+
+			if !tt.expectError && err != nil {
+				t.Errorf("Unexpected error: %v", err)
+			}
+
+			if len(result) != len(tt.expectedIDs) {
+				t.Fatalf("Expected %d results, got %d", len(tt.expectedIDs), len(result))
+			}
+			for i, id := range tt.expectedIDs {
+				if result[i].ID != id {
+					t.Errorf("Expected result %d to be %s, got %s", i, id, result[i].ID)
+				}
+			}
+
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Errorf("Unfulfilled expectations: %v", err)
+			}
+		})
+	}
+}
+
+func TestIdentificationRepositoryTimestampsUTC(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create mock database: %v", err)
+	}
+	defer db.Close()
+
+	repo := NewIdentificationRepository(db)
+
+	// The driver returns timestamps in the session time zone
+	jakarta := time.FixedZone("WIB", 7*60*60)
+	stored := time.Date(2026, 3, 1, 19, 30, 0, 0, jakarta)
+	columns := []string{"id", "genus", "species", "confidence", "image_path", "optimized_image_path", "care_guide", "created_at"}
+
+	mock.ExpectQuery("SELECT (.+) FROM identifications WHERE id = \\$1").
+		WithArgs("id1").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "genus", "species", "confidence", "image_path", "optimized_image_path", "care_guide", "care_status", "image_hash", "image_metadata", "created_at"}).
+			AddRow("id1", "haworthia", "haworthia_zebrina", 0.95, "/uploads/1.jpg", "", nil, CareStatusReady, nil, nil, stored))
+	mock.ExpectQuery("SELECT (.+) FROM identifications WHERE deleted_at IS NULL ORDER BY").
+		WithArgs(10, 0).
+		WillReturnRows(sqlmock.NewRows(columns).
+			AddRow("id1", "haworthia", "haworthia_zebrina", 0.95, "/uploads/1.jpg", "", nil, stored))
+
+	byID, err := repo.GetByID("id1")
+	if err != nil {
+		t.Fatalf("GetByID() unexpected error: %v", err)
+	}
+	all, err := repo.GetAll(10, 0)
+	if err != nil || len(all) != 1 {
+		t.Fatalf("GetAll() = %d items, err %v", len(all), err)
+	}
+
+	for name, createdAt := range map[string]time.Time{"GetByID": byID.CreatedAt, "GetAll": all[0].CreatedAt} {
+		if createdAt.Location() != time.UTC {
+			t.Errorf("%s created_at location = %v, expected UTC", name, createdAt.Location())
+		}
+		if !createdAt.Equal(stored) {
+			t.Errorf("%s created_at = %v, expected same instant as %v", name, createdAt, stored)
+		}
+		encoded, _ := json.Marshal(createdAt)
+		if string(encoded) != `"2026-03-01T12:30:00Z"` {
+			t.Errorf("%s created_at serialized as %s, expected RFC3339 with Z", name, encoded)
+		}
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unfulfilled expectations: %v", err)
+	}
+}
+
+func TestIdentificationRepositoryGetAllStream(t *testing.T) {
+	columns := []string{"id", "genus", "species", "confidence", "image_path", "care_guide", "created_at"}
+	const totalRows = 1000
+
+	newRows := func() *sqlmock.Rows {
+		rows := sqlmock.NewRows(columns)
+		for i := 0; i < totalRows; i++ {
+			rows.AddRow(fmt.Sprintf("id%d", i), "haworthia", "haworthia_zebrina", 0.9, "/uploads/x.jpg", nil, time.Now())
+		}
+		return rows
+	}
+
+	t.Run("Callback invoked once per row", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("Failed to create mock database: %v", err)
+		}
+		defer db.Close()
+
+		mock.ExpectQuery("SELECT (.+) FROM identifications WHERE deleted_at IS NULL ORDER BY created_at DESC").
+			WillReturnRows(newRows())
+
+		calls := 0
+		err = NewIdentificationRepository(db).GetAllStream(func(identification Identification) error {
+			if identification.ID != fmt.Sprintf("id%d", calls) {
+				t.Fatalf("Row %d delivered out of order: %s", calls, identification.ID)
+			}
+			calls++
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("GetAllStream() unexpected error: %v", err)
+		}
+		if calls != totalRows {
+			t.Errorf("Expected %d callbacks, got %d", totalRows, calls)
+		}
+	})
+
+	t.Run("Callback error stops iteration early", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("Failed to create mock database: %v", err)
+		}
+		defer db.Close()
+
+		mock.ExpectQuery("SELECT (.+) FROM identifications").WillReturnRows(newRows())
+
+		// Rows are handed over as they are scanned, not after loading the full result
+		stop := errors.New("client disconnected")
+		calls := 0
+		err = NewIdentificationRepository(db).GetAllStream(func(identification Identification) error {
+			calls++
+			if calls == 10 {
+				return stop
+			}
+			return nil
+		})
+		if !errors.Is(err, stop) {
+			t.Errorf("Expected callback error to be returned, got %v", err)
+		}
+		if calls != 10 {
+			t.Errorf("Expected iteration to stop after 10 rows, got %d", calls)
+		}
+	})
+
+	t.Run("Query error", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("Failed to create mock database: %v", err)
+		}
+		defer db.Close()
+
+		mock.ExpectQuery("SELECT (.+) FROM identifications").WillReturnError(errDatabase)
+
+		err = NewIdentificationRepository(db).GetAllStream(func(Identification) error { return nil })
+		if err == nil {
+			t.Error("Expected error but got none")
+		}
+	})
+}
+
+func TestIdentificationRepositoryDeleteOlderThan(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create mock database: %v", err)
+	}
+	defer db.Close()
+
+	repo := NewIdentificationRepository(db)
+	cutoff := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	// Paths shared with an identification that stays live are filtered out in SQL
+	query := "WITH expired AS \\( UPDATE identifications SET deleted_at = CURRENT_TIMESTAMP WHERE deleted_at IS NULL AND created_at < \\$1 " +
+		"RETURNING image_path, optimized_image_path \\).+WHERE NOT EXISTS \\(.+live.deleted_at IS NULL AND live.created_at >= \\$1"
+
+	tests := []struct {
+		name         string
+		mockBehavior func()
+		expectError  bool
+		expectedLen  int
+	}{
+		{
+			name: "Expired identifications soft deleted",
+			mockBehavior: func() {
+				rows := sqlmock.NewRows([]string{"path"}).
+					AddRow("/uploads/1.jpg").
+					AddRow("/uploads/1_web.jpg").
+					AddRow("/uploads/2.jpg")
+				mock.ExpectQuery(query).WithArgs(cutoff).WillReturnRows(rows)
+			},
+			expectError: false,
+			expectedLen: 3,
+		},
+		{
+			name: "Nothing expired",
+			mockBehavior: func() {
+				mock.ExpectQuery(query).WithArgs(cutoff).WillReturnRows(sqlmock.NewRows([]string{"path"}))
+			},
+			expectError: false,
+			expectedLen: 0,
+		},
+		{
+			name: "Database error",
+			mockBehavior: func() {
+				mock.ExpectQuery(query).WithArgs(cutoff).WillReturnError(errDatabase)
+			},
+			expectError: true,
+			expectedLen: 0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mockBehavior()
+
+			imagePaths, err := repo.DeleteOlderThan(cutoff)
+
+			if tt.expectError && err == nil {
+				t.Error("Expected error but got none")
+			}
+
+			if !tt.expectError && err != nil {
+				t.Errorf("Unexpected error: %v", err)
+			}
+
+			if len(imagePaths) != tt.expectedLen {
+				t.Errorf("Expected %d image paths, got %d", tt.expectedLen, len(imagePaths))
+			}
+
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Errorf("Unfulfilled expectations: %v", err)
+			}
+		})
+	}
+}
+
+func TestIdentificationRepositoryCountBySpecies(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create mock database: %v", err)
+	}
+	defer db.Close()
+
+	repo := NewIdentificationRepository(db)
+
+	rows := sqlmock.NewRows([]string{"genus", "species", "count"}).
+		AddRow("haworthia", "haworthia_zebrina", 7).
+		AddRow("conophytum", "", 1)
+	mock.ExpectQuery("SELECT genus, species, COUNT\\(\\*\\)\\s+FROM identifications\\s+WHERE deleted_at IS NULL\\s+GROUP BY genus, species").
+		WillReturnRows(rows)
+
+	counts, err := repo.CountBySpecies()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	expected := []SpeciesCount{
+		{SpeciesKey: SpeciesKey{Genus: "haworthia", Species: "haworthia_zebrina"}, Count: 7},
+		{SpeciesKey: SpeciesKey{Genus: "conophytum", Species: ""}, Count: 1},
+	}
+	if len(counts) != len(expected) {
+		t.Fatalf("Expected %d species counts, got %+v", len(expected), counts)
+	}
+	for i := range expected {
+		if counts[i] != expected[i] {
+			t.Errorf("Species count %d = %+v, expected %+v", i, counts[i], expected[i])
+		}
+	}
+
+	mock.ExpectQuery("SELECT genus, species, COUNT").WillReturnError(errDatabase)
+	if _, err := repo.CountBySpecies(); err == nil {
+		t.Error("Expected error but got none")
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unfulfilled expectations: %v", err)
+	}
+}
+
+func TestIdentificationRepositoryGetMissingCare(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create mock database: %v", err)
+	}
+	defer db.Close()
+
+	repo := NewIdentificationRepository(db)
+
+	rows := sqlmock.NewRows([]string{"id", "genus", "species", "care_status"}).
+		AddRow("ident-1", "haworthia", "haworthia_zebrina", CareStatusReady).
+		AddRow("ident-2", "lithops", "", CareStatusGenerating)
+	// Rows are claimed, skipping those locked or recently claimed by another backfill
+	mock.ExpectQuery("WITH claimed AS \\( UPDATE identifications SET care_claimed_at = CURRENT_TIMESTAMP WHERE id IN \\( SELECT id FROM identifications "+
+		"WHERE deleted_at IS NULL AND care_status <> \\$1 AND \\(care_guide IS NULL OR care_guide = 'null'::jsonb OR care_guide = '\\{\\}'::jsonb\\) "+
+		"AND \\(care_claimed_at IS NULL OR care_claimed_at < CURRENT_TIMESTAMP - \\$3 \\* INTERVAL '1 second'\\) "+
+		"ORDER BY created_at LIMIT \\$2 FOR UPDATE SKIP LOCKED \\)").
+		WithArgs(CareStatusNone, 25, int(careClaimTTL.Seconds())).
+		WillReturnRows(rows)
+
+	identifications, err := repo.GetMissingCare(25)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(identifications) != 2 {
+		t.Fatalf("Expected 2 identifications, got %+v", identifications)
+	}
+	if identifications[0].ID != "ident-1" || identifications[0].Species != "haworthia_zebrina" || identifications[0].CareGuide != nil {
+		t.Errorf("Unexpected first identification: %+v", identifications[0])
+	}
+	if identifications[1].ID != "ident-2" || identifications[1].CareStatus != CareStatusGenerating {
+		t.Errorf("Unexpected second identification: %+v", identifications[1])
+	}
+
+	mock.ExpectQuery("WITH claimed AS").WillReturnError(errDatabase)
+	if _, err := repo.GetMissingCare(25); err == nil {
+		t.Error("Expected error but got none")
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unfulfilled expectations: %v", err)
+	}
+}
+
+func TestIdentificationRepositoryGetAllByGenus(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create mock database: %v", err)
+	}
+	defer db.Close()
+
+	repo := NewIdentificationRepository(db)
+
+	newer := time.Date(2024, 5, 2, 0, 0, 0, 0, time.UTC)
+	older := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	rows := sqlmock.NewRows([]string{"id", "genus", "species", "confidence", "image_path", "optimized_image_path", "created_at"}).
+		AddRow("id1", "aloe", "aloe_vera", 0.9, "/uploads/1.jpg", "", newer).
+		AddRow("id2", "haworthia", "haworthia_zebrina", 0.8, "/uploads/2.jpg", "/uploads/2.jpg.web.jpg", newer).
+		AddRow("id3", "haworthia", "", 0.5, "/uploads/3.jpg", "", older)
+	// The page of genera is selected in SQL, then all their identifications
+	mock.ExpectQuery("WITH genera AS \\( SELECT DISTINCT genus FROM identifications WHERE deleted_at IS NULL ORDER BY genus LIMIT \\$1 OFFSET \\$2 \\)"+
+		"\\s+SELECT i.id, i.genus, i.species, i.confidence, i.image_path, (.+), i.created_at\\s+FROM identifications AS i\\s+JOIN genera ON genera.genus = i.genus"+
+		"\\s+WHERE i.deleted_at IS NULL\\s+ORDER BY i.genus, i.created_at DESC").
+		WithArgs(2, 0).
+		WillReturnRows(rows)
+
+	identifications, err := repo.GetAllByGenus(2, 0)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(identifications) != 3 {
+		t.Fatalf("Expected 3 identifications, got %d", len(identifications))
+	}
+	if identifications[1].OptimizedImagePath != "/uploads/2.jpg.web.jpg" || identifications[2].CreatedAt != older {
+		t.Errorf("Unexpected identifications: %+v", identifications)
+	}
+
+	mock.ExpectQuery("WITH genera AS").WillReturnError(errDatabase)
+	if _, err := repo.GetAllByGenus(2, 0); err == nil {
+		t.Error("Expected error but got none")
+	}
+
+	mock.ExpectQuery("SELECT COUNT\\(DISTINCT genus\\) FROM identifications WHERE deleted_at IS NULL").
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(2))
+	if count, err := repo.CountGenera(); err != nil || count != 2 {
+		t.Errorf("CountGenera() = %d, %v, expected 2", count, err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unfulfilled expectations: %v", err)
+	}
+}
+
+func TestIdentificationRepositoryTiming(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create mock database: %v", err)
+	}
+	defer db.Close()
+
+	repo := NewIdentificationRepository(db)
+	timing := &RequestTiming{UploadMs: 12.5, InferenceMs: 340, CareMs: 1800, SaveMs: 4, TotalMs: 2160}
+
+	mock.ExpectExec("UPDATE identifications SET timing").
+		WithArgs(sqlmock.AnyArg(), "id1").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	if err := repo.SaveTiming("id1", timing); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+
+	mock.ExpectExec("UPDATE identifications SET timing").
+		WithArgs(sqlmock.AnyArg(), "missing").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	if err := repo.SaveTiming("missing", timing); err == nil {
+		t.Error("Expected error for a missing identification")
+	}
+
+	createdAt := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	rows := sqlmock.NewRows([]string{"id", "genus", "species", "timing", "created_at"}).
+		AddRow("id1", "echeveria", "echeveria_elegans", []byte(`{"upload_ms":12.5,"inference_ms":340,"care_ms":1800,"save_ms":4,"total_ms":2160}`), createdAt)
+	mock.ExpectQuery("SELECT id, genus, species, timing, created_at\\s+FROM identifications\\s+WHERE deleted_at IS NULL AND timing IS NOT NULL\\s+ORDER BY created_at DESC\\s+LIMIT \\$1").
+		WithArgs(20).
+		WillReturnRows(rows)
+
+	identifications, err := repo.ListTimings(20)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(identifications) != 1 || identifications[0].ID != "id1" || identifications[0].Timing == nil || *identifications[0].Timing != *timing {
+		t.Errorf("Unexpected timings: %+v", identifications)
+	}
+
+	mock.ExpectQuery("SELECT id, genus, species, timing").WillReturnError(errDatabase)
+	if _, err := repo.ListTimings(20); err == nil {
+		t.Error("Expected error but got none")
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unfulfilled expectations: %v", err)
+	}
+}
@@ -0,0 +1,453 @@
+//go:build integration
+
+// Integration tests against a real PostgreSQL started with testcontainers.
+// They require Docker and are excluded from the default test run:
+//
+//	go test -tags integration ./db/...
+package db
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/testcontainers/testcontainers-go"
+	"github.com/testcontainers/testcontainers-go/modules/postgres"
+
+	"succulent-identifier-backend/utils"
+)
+
+// setupPostgres starts a disposable PostgreSQL container and runs migrations on it
+func setupPostgres(t *testing.T) *sql.DB {
+	t.Helper()
+	ctx := context.Background()
+
+	container, err := postgres.Run(ctx, "postgres:16-alpine",
+		postgres.WithDatabase("succulent_identifier_test"),
+		postgres.WithUsername("postgres"),
+		postgres.WithPassword("postgres"),
+		postgres.BasicWaitStrategies(),
+	)
+	if err != nil {
+		t.Fatalf("Failed to start postgres container: %v", err)
+	}
+	t.Cleanup(func() {
+		if err := testcontainers.TerminateContainer(container); err != nil {
+			t.Logf("Failed to terminate postgres container: %v", err)
+		}
+	})
+
+	connStr, err := container.ConnectionString(ctx, "sslmode=disable")
+	if err != nil {
+		t.Fatalf("Failed to get connection string: %v", err)
+	}
+
+	db, err := sql.Open("postgres", connStr)
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	if err := RunMigrations(db, utils.DefaultLabelDelimiter); err != nil {
+		t.Fatalf("Failed to run migrations: %v", err)
+	}
+	// Migrations must be safe to re-run on startup
+	if err := RunMigrations(db, utils.DefaultLabelDelimiter); err != nil {
+		t.Fatalf("Failed to re-run migrations: %v", err)
+	}
+
+	return db
+}
+
+func TestIntegrationIdentificationRoundTrip(t *testing.T) {
+	db := setupPostgres(t)
+	repo := NewIdentificationRepository(db)
+
+	hash := int64(0x0F0F0F0F0F0F0F0F)
+	identification := &Identification{
+		ID:         uuid.New().String(),
+		Genus:      "haworthia",
+		Species:    "haworthia_zebrina",
+		Confidence: 0.9512,
+		ImagePath:  "/uploads/zebrina.jpg",
+		CareGuide:  &CareGuide{Sunlight: "Bright indirect light", Watering: "Sparingly"},
+		ImageHash:  &hash,
+		CreatedAt:  time.Now(),
+	}
+
+	if err := repo.Create(identification); err != nil {
+		t.Fatalf("Create() error: %v", err)
+	}
+
+	got, err := repo.GetByID(identification.ID)
+	if err != nil {
+		t.Fatalf("GetByID() error: %v", err)
+	}
+	if got.Genus != identification.Genus || got.Species != identification.Species {
+		t.Errorf("GetByID() = %+v, expected %+v", got, identification)
+	}
+	if got.CareGuide == nil || got.CareGuide.Sunlight != "Bright indirect light" {
+		t.Errorf("GetByID() care guide = %+v", got.CareGuide)
+	}
+
+	all, err := repo.GetAll(10, 0)
+	if err != nil || len(all) != 1 {
+		t.Fatalf("GetAll() = %d items, err %v", len(all), err)
+	}
+
+	batch, err := repo.GetByIDs([]string{identification.ID, uuid.New().String()})
+	if err != nil || len(batch) != 1 {
+		t.Fatalf("GetByIDs() = %d items, err %v", len(batch), err)
+	}
+
+	similar, err := repo.FindSimilar(hash^0x3, 10)
+	if err != nil || len(similar) != 1 {
+		t.Fatalf("FindSimilar() = %d items, err %v", len(similar), err)
+	}
+
+	if err := repo.UpdateCareGuide(identification.ID, &CareGuide{Sunlight: "Full sun"}, CareStatusReady); err != nil {
+		t.Fatalf("UpdateCareGuide() error: %v", err)
+	}
+	care, err := repo.GetCare(identification.ID)
+	if err != nil {
+		t.Fatalf("GetCare() error: %v", err)
+	}
+	if care.CareStatus != CareStatusReady || care.CareGuide == nil || care.CareGuide.Sunlight != "Full sun" {
+		t.Errorf("GetCare() = %+v", care)
+	}
+
+	// Soft delete hides the record from every read path
+	if err := repo.Delete(identification.ID); err != nil {
+		t.Fatalf("Delete() error: %v", err)
+	}
+	if _, err := repo.GetByID(identification.ID); err == nil {
+		t.Error("GetByID() expected error after soft delete")
+	}
+	if err := repo.Delete(identification.ID); err == nil {
+		t.Error("Delete() expected error for already deleted record")
+	}
+
+	count, err := repo.Count()
+	if err != nil || count != 0 {
+		t.Errorf("Count() = %d, err %v, expected 0", count, err)
+	}
+
+	var deletedAt sql.NullTime
+	if err := db.QueryRow(`SELECT deleted_at FROM identifications WHERE id = $1`, identification.ID).Scan(&deletedAt); err != nil {
+		t.Fatalf("Failed to read deleted_at: %v", err)
+	}
+	if !deletedAt.Valid {
+		t.Error("Expected deleted_at to be set on soft-deleted row")
+	}
+}
+
+func TestIntegrationCareCacheUpsert(t *testing.T) {
+	db := setupPostgres(t)
+	repo := NewCareInstructionsRepository(db)
+
+	now := time.Now()
+	entry := &CareInstructionsCache{
+		ID:        uuid.New().String(),
+		Genus:     "echeveria",
+		Species:   "echeveria_elegans",
+		CareGuide: &CareGuide{Sunlight: "Full sun"},
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if err := repo.Create(entry); err != nil {
+		t.Fatalf("Create() error: %v", err)
+	}
+	originalID := entry.ID
+
+	// A second create for the same species upserts instead of failing
+	updated := &CareInstructionsCache{
+		ID:        uuid.New().String(),
+		Genus:     "echeveria",
+		Species:   "echeveria_elegans",
+		CareGuide: &CareGuide{Sunlight: "Partial shade"},
+		CreatedAt: now,
+		UpdatedAt: now.Add(time.Minute),
+	}
+	if err := repo.Create(updated); err != nil {
+		t.Fatalf("Create() upsert error: %v", err)
+	}
+	if updated.ID != originalID {
+		t.Errorf("Upsert returned id %s, expected existing id %s", updated.ID, originalID)
+	}
+
+	cached, err := repo.GetBySpecies("echeveria", "echeveria_elegans")
+	if err != nil || cached == nil {
+		t.Fatalf("GetBySpecies() = %+v, err %v", cached, err)
+	}
+	if cached.CareGuide.Sunlight != "Partial shade" {
+		t.Errorf("GetBySpecies() sunlight = %s, expected upserted value", cached.CareGuide.Sunlight)
+	}
+
+	missing, err := repo.GetBySpecies("echeveria", "echeveria_unknown")
+	if err != nil || missing != nil {
+		t.Errorf("GetBySpecies() miss = %+v, err %v", missing, err)
+	}
+}
+
+func TestIntegrationCareCacheEviction(t *testing.T) {
+	db := setupPostgres(t)
+	repo := NewCareInstructionsRepository(db)
+
+	now := time.Now()
+	for _, species := range []string{"aloe_vera", "aloe_aristata", "aloe_juvenna"} {
+		if err := repo.Create(&CareInstructionsCache{
+			ID:        uuid.New().String(),
+			Genus:     "aloe",
+			Species:   species,
+			CareGuide: &CareGuide{Sunlight: "Full sun"},
+			CreatedAt: now,
+			UpdatedAt: now,
+		}); err != nil {
+			t.Fatalf("Create() error: %v", err)
+		}
+	}
+
+	// aloe_vera is the least recently used but verified; aloe_aristata is next
+	if _, err := db.Exec(`UPDATE care_instructions SET verified = TRUE, accessed_at = $1 WHERE species = 'aloe_vera'`, now.Add(-2*time.Hour)); err != nil {
+		t.Fatalf("Failed to mark verified entry: %v", err)
+	}
+	if _, err := db.Exec(`UPDATE care_instructions SET accessed_at = $1 WHERE species = 'aloe_aristata'`, now.Add(-time.Hour)); err != nil {
+		t.Fatalf("Failed to age entry: %v", err)
+	}
+
+	evicted, err := repo.EvictLRU(2)
+	if err != nil {
+		t.Fatalf("EvictLRU() error: %v", err)
+	}
+	if evicted != 1 {
+		t.Errorf("EvictLRU() evicted %d, expected 1", evicted)
+	}
+
+	for species, expectPresent := range map[string]bool{
+		"aloe_vera":     true,
+		"aloe_aristata": false,
+		"aloe_juvenna":  true,
+	} {
+		cached, err := repo.GetBySpecies("aloe", species)
+		if err != nil {
+			t.Fatalf("GetBySpecies(%s) error: %v", species, err)
+		}
+		if (cached != nil) != expectPresent {
+			t.Errorf("GetBySpecies(%s) present = %v, expected %v", species, cached != nil, expectPresent)
+		}
+	}
+}
+
+func TestIntegrationCarePromptVersion(t *testing.T) {
+	db := setupPostgres(t)
+	repo := NewCareInstructionsRepository(db)
+
+	now := time.Now()
+	for _, species := range []string{"vera", "aristata"} {
+		if err := repo.Create(&CareInstructionsCache{
+			ID:        uuid.New().String(),
+			Genus:     "aloe",
+			Species:   species,
+			CareGuide: &CareGuide{Sunlight: "Full sun"},
+			CreatedAt: now,
+			UpdatedAt: now,
+		}); err != nil {
+			t.Fatalf("Create() error: %v", err)
+		}
+	}
+	if _, err := db.Exec(`UPDATE care_instructions SET verified = TRUE WHERE species = 'vera'`); err != nil {
+		t.Fatalf("Failed to mark verified entry: %v", err)
+	}
+
+	// After a prompt change only the verified entry is still served
+	repo.SetPromptVersion(DefaultCarePromptVersion + 1)
+	for species, expectPresent := range map[string]bool{"vera": true, "aristata": false} {
+		cached, err := repo.GetBySpecies("aloe", species)
+		if err != nil {
+			t.Fatalf("GetBySpecies(%s) error: %v", species, err)
+		}
+		if (cached != nil) != expectPresent {
+			t.Errorf("GetBySpecies(%s) present = %v, expected %v", species, cached != nil, expectPresent)
+		}
+	}
+
+	// Regenerating the stale entry stores it with the current version
+	if err := repo.Create(&CareInstructionsCache{
+		ID:        uuid.New().String(),
+		Genus:     "aloe",
+		Species:   "aristata",
+		CareGuide: &CareGuide{Sunlight: "Bright light"},
+		CreatedAt: now,
+		UpdatedAt: now,
+	}); err != nil {
+		t.Fatalf("Create() error: %v", err)
+	}
+	cached, err := repo.GetBySpecies("aloe", "aristata")
+	if err != nil || cached == nil {
+		t.Fatalf("GetBySpecies() after regeneration = %+v, err %v", cached, err)
+	}
+	if cached.PromptVersion != DefaultCarePromptVersion+1 || cached.CareGuide.Sunlight != "Bright light" {
+		t.Errorf("Expected regenerated care with version %d, got %+v", DefaultCarePromptVersion+1, cached)
+	}
+}
+
+func TestIntegrationDeleteOlderThanSharedImage(t *testing.T) {
+	db := setupPostgres(t)
+	repo := NewIdentificationRepository(db)
+
+	cutoff := time.Now().Add(-24 * time.Hour)
+	create := func(imagePath, optimizedPath string, createdAt time.Time) {
+		t.Helper()
+		if err := repo.Create(&Identification{
+			ID:                 uuid.New().String(),
+			Genus:              "echeveria",
+			Confidence:         0.8,
+			ImagePath:          imagePath,
+			OptimizedImagePath: optimizedPath,
+			CreatedAt:          createdAt,
+		}); err != nil {
+			t.Fatalf("Create() error: %v", err)
+		}
+	}
+
+	// A re-identification reuses the files of the upload it re-ran
+	create("/uploads/shared.jpg", "/uploads/shared_web.jpg", cutoff.Add(-time.Hour))
+	create("/uploads/shared.jpg", "/uploads/shared_web.jpg", cutoff.Add(time.Hour))
+	// Two expired rows sharing files report them once
+	create("/uploads/old.jpg", "", cutoff.Add(-2*time.Hour))
+	create("/uploads/old.jpg", "", cutoff.Add(-time.Hour))
+
+	imagePaths, err := repo.DeleteOlderThan(cutoff)
+	if err != nil {
+		t.Fatalf("DeleteOlderThan() error: %v", err)
+	}
+	if len(imagePaths) != 1 || imagePaths[0] != "/uploads/old.jpg" {
+		t.Errorf("Expected only the unshared expired image, got %v", imagePaths)
+	}
+
+	count, err := repo.Count()
+	if err != nil || count != 1 {
+		t.Errorf("Expected the re-identification to stay live, got %d, err %v", count, err)
+	}
+}
+
+func TestIntegrationNormalizeCareCacheKeys(t *testing.T) {
+	db := setupPostgres(t)
+
+	insert := func(genus, species string, updatedAt time.Time) {
+		t.Helper()
+		if _, err := db.Exec(`
+			INSERT INTO care_instructions (genus, species, care_guide, updated_at)
+			VALUES ($1, $2, '{}', $3)
+		`, genus, species, updatedAt); err != nil {
+			t.Fatalf("Insert error: %v", err)
+		}
+	}
+
+	now := time.Now()
+	// Labels from a model using a custom delimiter, stored before keys were normalized
+	insert("Echeveria", "Echeveria-Elegans", now)
+	insert("echeveria", "elegans", now.Add(-time.Hour))
+	insert("haworthia", "haworthia-cooperi", now)
+	insert("Aloe", "aloe", now)
+
+	if err := normalizeCareCacheKeys(db, "-"); err != nil {
+		t.Fatalf("normalizeCareCacheKeys() error: %v", err)
+	}
+
+	rows, err := db.Query(`SELECT genus, species FROM care_instructions ORDER BY genus, species`)
+	if err != nil {
+		t.Fatalf("Query error: %v", err)
+	}
+	defer rows.Close()
+
+	var keys []string
+	for rows.Next() {
+		var genus, species string
+		if err := rows.Scan(&genus, &species); err != nil {
+			t.Fatalf("Scan error: %v", err)
+		}
+		keys = append(keys, genus+"/"+species)
+	}
+
+	expected := []string{"aloe/", "echeveria/elegans", "haworthia/cooperi"}
+	if len(keys) != len(expected) {
+		t.Fatalf("Expected keys %v, got %v", expected, keys)
+	}
+	for i := range expected {
+		if keys[i] != expected[i] {
+			t.Errorf("Expected keys %v, got %v", expected, keys)
+			break
+		}
+	}
+}
+
+func TestIntegrationMigrationsCreateSchema(t *testing.T) {
+	db := setupPostgres(t)
+
+	// Columns and tables of migrations 002 and 003 exist on a fresh database
+	for _, column := range []struct{ table, name string }{
+		{"identifications", "deleted_at"},
+		{"care_instructions", "care_guide"},
+		{"care_instructions", "accessed_at"},
+	} {
+		var exists bool
+		err := db.QueryRow(`
+			SELECT EXISTS (
+				SELECT 1 FROM information_schema.columns
+				WHERE table_name = $1 AND column_name = $2
+			)
+		`, column.table, column.name).Scan(&exists)
+		if err != nil {
+			t.Fatalf("Failed to inspect schema: %v", err)
+		}
+		if !exists {
+			t.Errorf("Expected column %s.%s to exist", column.table, column.name)
+		}
+	}
+}
+
+func TestIntegrationGetMissingCareClaims(t *testing.T) {
+	db := setupPostgres(t)
+	repo := NewIdentificationRepository(db)
+
+	for i := 0; i < 3; i++ {
+		if err := repo.Create(&Identification{
+			ID:         uuid.New().String(),
+			Genus:      "echeveria",
+			Confidence: 0.8,
+			ImagePath:  "/uploads/missing.jpg",
+			CareStatus: CareStatusGenerating,
+			CreatedAt:  time.Now().Add(time.Duration(i) * time.Minute),
+		}); err != nil {
+			t.Fatalf("Create() error: %v", err)
+		}
+	}
+
+	first, err := repo.GetMissingCare(2)
+	if err != nil || len(first) != 2 {
+		t.Fatalf("GetMissingCare() = %d rows, err %v, expected 2", len(first), err)
+	}
+
+	// A concurrent backfill only gets the unclaimed row
+	second, err := repo.GetMissingCare(2)
+	if err != nil || len(second) != 1 {
+		t.Fatalf("GetMissingCare() = %d rows, err %v, expected 1", len(second), err)
+	}
+	for _, claimed := range first {
+		if claimed.ID == second[0].ID {
+			t.Errorf("Identification %s was claimed twice", claimed.ID)
+		}
+	}
+
+	// Claims expire, so unfilled rows are claimed again later
+	if _, err := db.Exec(`UPDATE identifications SET care_claimed_at = CURRENT_TIMESTAMP - INTERVAL '1 hour'`); err != nil {
+		t.Fatalf("Failed to expire claims: %v", err)
+	}
+	again, err := repo.GetMissingCare(10)
+	if err != nil || len(again) != 3 {
+		t.Errorf("GetMissingCare() after expiry = %d rows, err %v, expected 3", len(again), err)
+	}
+}
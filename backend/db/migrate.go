@@ -4,12 +4,27 @@ import (
 	"database/sql"
 	"fmt"
 	"log"
+
+	"succulent-identifier-backend/utils"
 )
 
-// RunMigrations executes the database migrations
-func RunMigrations(db *sql.DB) error {
+// minServerVersion is the oldest supported PostgreSQL, as server_version_num
+const minServerVersion = 140000
+
+// RunMigrations executes the database migrations. labelDelimiter is the
+// separator between genus and species in model labels (LABEL_DELIMITER)
+func RunMigrations(db *sql.DB, labelDelimiter string) error {
 	log.Println("Running database migrations...")
 
+	// Near-duplicate detection compares image hashes with bit_count, added in PostgreSQL 14
+	var serverVersion int
+	if err := db.QueryRow(`SELECT current_setting('server_version_num')::int`).Scan(&serverVersion); err != nil {
+		return fmt.Errorf("failed to get PostgreSQL version: %w", err)
+	}
+	if serverVersion < minServerVersion {
+		return fmt.Errorf("PostgreSQL 14 or higher is required, server version is %d", serverVersion)
+	}
+
 	// Create identifications table
 	_, err := db.Exec(`
 		CREATE TABLE IF NOT EXISTS identifications (
@@ -35,6 +50,48 @@ func RunMigrations(db *sql.DB) error {
 		return fmt.Errorf("failed to create index on identifications: %w", err)
 	}
 
+	// Add soft delete column (migration 002)
+	_, err = db.Exec(`
+		ALTER TABLE identifications ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP WITH TIME ZONE
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to add deleted_at column: %w", err)
+	}
+
+	_, err = db.Exec(`
+		CREATE INDEX IF NOT EXISTS idx_identifications_deleted_at
+		ON identifications(deleted_at)
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to create deleted_at index: %w", err)
+	}
+
+	// Add perceptual image hash column for near-duplicate detection
+	_, err = db.Exec(`
+		ALTER TABLE identifications ADD COLUMN IF NOT EXISTS image_hash BIGINT
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to add image_hash column: %w", err)
+	}
+
+	// Similar images are found by Hamming distance, which no B-tree index on
+	// image_hash can serve, so the index only slowed down writes
+	_, err = db.Exec(`
+		DROP INDEX IF EXISTS idx_identifications_image_hash
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to drop image_hash index: %w", err)
+	}
+
+	// Add care generation status column for async care generation
+	_, err = db.Exec(`
+		ALTER TABLE identifications
+		ADD COLUMN IF NOT EXISTS care_status VARCHAR(20) NOT NULL DEFAULT 'ready'
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to add care_status column: %w", err)
+	}
+
 	// Create chat_messages table
 	_, err = db.Exec(`
 		CREATE TABLE IF NOT EXISTS chat_messages (
@@ -74,6 +131,283 @@ func RunMigrations(db *sql.DB) error {
 		return fmt.Errorf("failed to create composite index on chat_messages: %w", err)
 	}
 
+	// Record which LLM produced each assistant message
+	_, err = db.Exec(`
+		ALTER TABLE chat_messages ADD COLUMN IF NOT EXISTS model VARCHAR(100)
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to add model column to chat_messages: %w", err)
+	}
+
+	// Flag assistant messages cut off by the max token cap
+	_, err = db.Exec(`
+		ALTER TABLE chat_messages ADD COLUMN IF NOT EXISTS truncated BOOLEAN NOT NULL DEFAULT FALSE
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to add truncated column to chat_messages: %w", err)
+	}
+
+	// Care fields an assistant message drew on, for highlighting in the UI
+	_, err = db.Exec(`
+		ALTER TABLE chat_messages ADD COLUMN IF NOT EXISTS care_references TEXT[]
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to add care_references column to chat_messages: %w", err)
+	}
+
+	// Create care_instructions table for caching LLM-generated care data (migration 003)
+	_, err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS care_instructions (
+			id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+			genus VARCHAR(255) NOT NULL,
+			species VARCHAR(255) NOT NULL,
+			care_guide JSONB NOT NULL,
+			created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+			updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+		)
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to create care_instructions table: %w", err)
+	}
+
+	_, err = db.Exec(`
+		CREATE UNIQUE INDEX IF NOT EXISTS idx_care_instructions_genus_species
+		ON care_instructions(genus, species)
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to create unique index on care_instructions: %w", err)
+	}
+
+	_, err = db.Exec(`
+		CREATE INDEX IF NOT EXISTS idx_care_instructions_created_at
+		ON care_instructions(created_at DESC)
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to create index on care_instructions: %w", err)
+	}
+
+	// Add LRU tracking and verified flag for care cache eviction
+	_, err = db.Exec(`
+		ALTER TABLE care_instructions
+		ADD COLUMN IF NOT EXISTS accessed_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+		ADD COLUMN IF NOT EXISTS verified BOOLEAN NOT NULL DEFAULT FALSE
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to add care cache eviction columns: %w", err)
+	}
+
+	_, err = db.Exec(`
+		CREATE INDEX IF NOT EXISTS idx_care_instructions_accessed_at
+		ON care_instructions(accessed_at)
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to create accessed_at index: %w", err)
+	}
+
+	// Record dimensions, format, size and capture time of uploaded images
+	_, err = db.Exec(`
+		ALTER TABLE identifications ADD COLUMN IF NOT EXISTS image_metadata JSONB
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to add image_metadata column: %w", err)
+	}
+
+	// Path of a web-friendly JPEG copy of uploaded images
+	_, err = db.Exec(`
+		ALTER TABLE identifications ADD COLUMN IF NOT EXISTS optimized_image_path TEXT
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to add optimized_image_path column: %w", err)
+	}
+
+	// Track the care prompt version of cached entries so outdated ones regenerate
+	_, err = db.Exec(`
+		ALTER TABLE care_instructions
+		ADD COLUMN IF NOT EXISTS prompt_version INTEGER NOT NULL DEFAULT 1
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to add care prompt version column: %w", err)
+	}
+
+	// Re-key cached care stored under the full label ("echeveria_elegans") to the
+	// canonical key lookups use, dropping rows already present in that form
+	if err := normalizeCareCacheKeys(db, labelDelimiter); err != nil {
+		return fmt.Errorf("failed to normalize care cache species: %w", err)
+	}
+
+	// Link the identifications of plants detected in the same image
+	_, err = db.Exec(`
+		ALTER TABLE identifications
+		ADD COLUMN IF NOT EXISTS parent_id UUID REFERENCES identifications(id) ON DELETE SET NULL,
+		ADD COLUMN IF NOT EXISTS bounding_box JSONB
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to add detection columns: %w", err)
+	}
+
+	_, err = db.Exec(`
+		CREATE INDEX IF NOT EXISTS idx_identifications_parent_id
+		ON identifications(parent_id)
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to create parent_id index: %w", err)
+	}
+
+	// Remember which client made an identification for time-window dedup
+	_, err = db.Exec(`
+		ALTER TABLE identifications ADD COLUMN IF NOT EXISTS client_id VARCHAR(255)
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to add client_id column: %w", err)
+	}
+
+	_, err = db.Exec(`
+		CREATE INDEX IF NOT EXISTS idx_identifications_client_species
+		ON identifications(client_id, genus, species, created_at DESC)
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to create client_id index: %w", err)
+	}
+
+	// Step durations of the identify request, for diagnosing slowness
+	_, err = db.Exec(`
+		ALTER TABLE identifications ADD COLUMN IF NOT EXISTS timing JSONB
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to add timing column: %w", err)
+	}
+
+	// Record failed identification attempts for later analysis
+	_, err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS failed_identifications (
+			id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+			image_path TEXT NOT NULL,
+			error TEXT NOT NULL,
+			created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+		)
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to create failed_identifications table: %w", err)
+	}
+
+	_, err = db.Exec(`
+		CREATE INDEX IF NOT EXISTS idx_failed_identifications_created_at
+		ON failed_identifications(created_at DESC)
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to create index on failed_identifications: %w", err)
+	}
+
+	// Contextless chat messages have no identification instead of sharing the
+	// placeholder identification they used to be stored under
+	_, err = db.Exec(`
+		ALTER TABLE chat_messages ALTER COLUMN identification_id DROP NOT NULL
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to make chat message identification optional: %w", err)
+	}
+
+	_, err = db.Exec(`
+		UPDATE chat_messages SET identification_id = NULL WHERE identification_id = $1
+	`, GeneralConversationID)
+	if err != nil {
+		return fmt.Errorf("failed to detach general chat messages: %w", err)
+	}
+
+	_, err = db.Exec(`
+		DELETE FROM identifications WHERE id = $1
+	`, GeneralConversationID)
+	if err != nil {
+		return fmt.Errorf("failed to remove general conversation: %w", err)
+	}
+
+	// Care backfills claim the identifications they fill
+	_, err = db.Exec(`
+		ALTER TABLE identifications ADD COLUMN IF NOT EXISTS care_claimed_at TIMESTAMP WITH TIME ZONE
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to add care_claimed_at column: %w", err)
+	}
+
 	log.Println("Database migrations completed successfully")
 	return nil
 }
+
+// normalizeCareCacheKeys rewrites every cached care entry to the key
+// utils.CareCacheKey derives for it. When several rows share a key, the one
+// already stored in canonical form (or else the most recently updated) is kept
+func normalizeCareCacheKeys(db *sql.DB, labelDelimiter string) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.Query(`
+		SELECT id, genus, species
+		FROM care_instructions
+		ORDER BY updated_at DESC NULLS LAST
+	`)
+	if err != nil {
+		return err
+	}
+
+	type cacheRow struct {
+		id, genus, species string
+	}
+	type cacheKey struct {
+		genus, species string
+	}
+
+	kept := make(map[cacheKey]cacheRow)
+	var order []cacheKey
+	var duplicates []string
+	for rows.Next() {
+		var row cacheRow
+		if err := rows.Scan(&row.id, &row.genus, &row.species); err != nil {
+			rows.Close()
+			return err
+		}
+
+		genus, species := utils.CareCacheKey(row.genus, row.species, labelDelimiter)
+		key := cacheKey{genus, species}
+		current, ok := kept[key]
+		switch {
+		case !ok:
+			kept[key] = row
+			order = append(order, key)
+		case row.genus == genus && row.species == species:
+			duplicates = append(duplicates, current.id)
+			kept[key] = row
+		default:
+			duplicates = append(duplicates, row.id)
+		}
+	}
+	if err := rows.Close(); err != nil {
+		return err
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, id := range duplicates {
+		if _, err := tx.Exec(`DELETE FROM care_instructions WHERE id = $1`, id); err != nil {
+			return err
+		}
+	}
+
+	for _, key := range order {
+		row := kept[key]
+		if row.genus == key.genus && row.species == key.species {
+			continue
+		}
+		_, err := tx.Exec(`
+			UPDATE care_instructions SET genus = $1, species = $2 WHERE id = $3
+		`, key.genus, key.species, row.id)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
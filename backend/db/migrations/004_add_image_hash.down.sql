@@ -0,0 +1,3 @@
+-- Drop the image_hash column and its index
+DROP INDEX IF EXISTS idx_identifications_image_hash;
+ALTER TABLE identifications DROP COLUMN image_hash;
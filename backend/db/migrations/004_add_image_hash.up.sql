@@ -0,0 +1,5 @@
+-- Add perceptual image hash (64-bit dHash) for near-duplicate detection
+ALTER TABLE identifications ADD COLUMN image_hash BIGINT;
+
+-- Create index on image_hash for faster lookups of hashed records
+CREATE INDEX idx_identifications_image_hash ON identifications(image_hash);
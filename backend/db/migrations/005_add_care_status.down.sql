@@ -0,0 +1,2 @@
+-- Drop the care_status column
+ALTER TABLE identifications DROP COLUMN care_status;
@@ -0,0 +1,2 @@
+-- Track whether an identification's care guide is ready or still being generated
+ALTER TABLE identifications ADD COLUMN care_status VARCHAR(20) NOT NULL DEFAULT 'ready';
@@ -0,0 +1,4 @@
+-- Drop LRU eviction columns and index
+DROP INDEX IF EXISTS idx_care_instructions_accessed_at;
+ALTER TABLE care_instructions DROP COLUMN verified;
+ALTER TABLE care_instructions DROP COLUMN accessed_at;
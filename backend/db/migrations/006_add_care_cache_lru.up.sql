@@ -0,0 +1,8 @@
+-- Track last read time for LRU eviction of cached care instructions
+ALTER TABLE care_instructions ADD COLUMN accessed_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP;
+
+-- Verified entries are curated and never evicted
+ALTER TABLE care_instructions ADD COLUMN verified BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- Create index on accessed_at for eviction ordering
+CREATE INDEX idx_care_instructions_accessed_at ON care_instructions(accessed_at);
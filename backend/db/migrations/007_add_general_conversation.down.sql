@@ -0,0 +1,2 @@
+-- Remove the general conversation and its chat messages
+DELETE FROM identifications WHERE id = '00000000-0000-0000-0000-000000000000';
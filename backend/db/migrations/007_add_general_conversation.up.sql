@@ -0,0 +1,5 @@
+-- Reserved identification that owns contextless (general advice) chat messages.
+-- It is created soft deleted so it never appears in history.
+INSERT INTO identifications (id, genus, species, confidence, image_path, deleted_at)
+VALUES ('00000000-0000-0000-0000-000000000000', 'General', '', 0, '', CURRENT_TIMESTAMP)
+ON CONFLICT (id) DO NOTHING;
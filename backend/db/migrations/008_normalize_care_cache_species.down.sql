@@ -0,0 +1,5 @@
+-- Restore full-label species keys for cached care. Set the delimiter below to
+-- LABEL_DELIMITER.
+UPDATE care_instructions
+SET species = genus || '_' || species
+WHERE species <> '';
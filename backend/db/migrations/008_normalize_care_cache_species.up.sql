@@ -0,0 +1,45 @@
+-- Re-key cached care stored under the full label (e.g. 'echeveria_elegans') to the
+-- canonical key utils.CareCacheKey derives: lowercased, trimmed, and the species
+-- epithet ('elegans') with the genus and label delimiter stripped. Set the
+-- delimiter below to LABEL_DELIMITER; RunMigrations applies it automatically.
+CREATE TEMP TABLE care_cache_keys AS
+WITH params AS (
+    SELECT '_'::text AS delimiter
+),
+trimmed AS (
+    SELECT c.id, c.genus, c.species, c.updated_at,
+           lower(btrim(c.genus)) AS key_genus,
+           lower(btrim(c.species)) AS lowered_species,
+           lower(btrim(c.genus)) || p.delimiter AS prefix
+    FROM care_instructions c, params p
+)
+SELECT id, genus, species, updated_at, key_genus,
+       CASE
+           WHEN lowered_species = key_genus THEN ''
+           WHEN left(lowered_species, length(prefix)) = prefix
+               THEN substr(lowered_species, length(prefix) + 1)
+           ELSE lowered_species
+       END AS key_species
+FROM trimmed;
+
+-- Keep one row per key: the one already in canonical form, else the newest
+DELETE FROM care_instructions
+WHERE id IN (
+    SELECT id FROM (
+        SELECT id, row_number() OVER (
+            PARTITION BY key_genus, key_species
+            ORDER BY (genus = key_genus AND species = key_species) DESC,
+                     updated_at DESC NULLS LAST
+        ) AS rank
+        FROM care_cache_keys
+    ) ranked
+    WHERE rank > 1
+);
+
+UPDATE care_instructions c
+SET genus = k.key_genus, species = k.key_species
+FROM care_cache_keys k
+WHERE c.id = k.id
+AND (c.genus <> k.key_genus OR c.species <> k.key_species);
+
+DROP TABLE care_cache_keys;
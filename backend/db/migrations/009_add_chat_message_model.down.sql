@@ -0,0 +1,2 @@
+-- Drop the model column
+ALTER TABLE chat_messages DROP COLUMN model;
@@ -0,0 +1,2 @@
+-- Record which LLM produced each assistant message (NULL for user messages)
+ALTER TABLE chat_messages ADD COLUMN model VARCHAR(100);
@@ -0,0 +1,2 @@
+-- Drop care prompt version tracking
+ALTER TABLE care_instructions DROP COLUMN prompt_version;
@@ -0,0 +1,3 @@
+-- Track the care prompt version each cached entry was generated with, so
+-- entries from older prompts can be regenerated
+ALTER TABLE care_instructions ADD COLUMN prompt_version INTEGER NOT NULL DEFAULT 1;
@@ -0,0 +1,2 @@
+-- Drop image metadata
+ALTER TABLE identifications DROP COLUMN image_metadata;
@@ -0,0 +1,2 @@
+-- Record dimensions, format, size and EXIF capture time of uploaded images
+ALTER TABLE identifications ADD COLUMN image_metadata JSONB;
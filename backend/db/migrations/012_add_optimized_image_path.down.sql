@@ -0,0 +1,2 @@
+-- Drop optimized image path
+ALTER TABLE identifications DROP COLUMN optimized_image_path;
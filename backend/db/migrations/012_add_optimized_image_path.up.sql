@@ -0,0 +1,2 @@
+-- Path of a web-friendly JPEG copy of the uploaded image
+ALTER TABLE identifications ADD COLUMN optimized_image_path TEXT;
@@ -0,0 +1,2 @@
+-- Drop truncated flag
+ALTER TABLE chat_messages DROP COLUMN truncated;
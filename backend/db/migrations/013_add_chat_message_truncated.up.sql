@@ -0,0 +1,2 @@
+-- Flag assistant messages cut off by the max token cap
+ALTER TABLE chat_messages ADD COLUMN truncated BOOLEAN NOT NULL DEFAULT FALSE;
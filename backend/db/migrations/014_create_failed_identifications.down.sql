@@ -0,0 +1,3 @@
+-- Drop failed_identifications table and its index
+DROP INDEX IF EXISTS idx_failed_identifications_created_at;
+DROP TABLE IF EXISTS failed_identifications;
@@ -0,0 +1,10 @@
+-- Record failed identification attempts for later analysis
+CREATE TABLE IF NOT EXISTS failed_identifications (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    image_path TEXT NOT NULL,
+    error TEXT NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Create index on created_at for browsing recent failures
+CREATE INDEX idx_failed_identifications_created_at ON failed_identifications(created_at DESC);
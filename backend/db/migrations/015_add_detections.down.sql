@@ -0,0 +1,3 @@
+-- Drop detection columns and their index
+DROP INDEX IF EXISTS idx_identifications_parent_id;
+ALTER TABLE identifications DROP COLUMN bounding_box, DROP COLUMN parent_id;
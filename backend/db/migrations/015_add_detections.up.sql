@@ -0,0 +1,7 @@
+-- Link the identifications of plants detected in the same image
+ALTER TABLE identifications
+ADD COLUMN parent_id UUID REFERENCES identifications(id) ON DELETE SET NULL,
+ADD COLUMN bounding_box JSONB;
+
+-- Create index on parent_id for finding the other plants of an image
+CREATE INDEX idx_identifications_parent_id ON identifications(parent_id);
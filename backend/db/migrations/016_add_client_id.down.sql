@@ -0,0 +1,3 @@
+-- Drop client_id column and its index
+DROP INDEX IF EXISTS idx_identifications_client_species;
+ALTER TABLE identifications DROP COLUMN client_id;
@@ -0,0 +1,5 @@
+-- Remember which client made an identification for time-window dedup
+ALTER TABLE identifications ADD COLUMN client_id VARCHAR(255);
+
+-- Create index for finding a client's recent identifications of a species
+CREATE INDEX idx_identifications_client_species ON identifications(client_id, genus, species, created_at DESC);
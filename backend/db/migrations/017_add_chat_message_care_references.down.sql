@@ -0,0 +1,2 @@
+-- Drop care references
+ALTER TABLE chat_messages DROP COLUMN care_references;
@@ -0,0 +1,2 @@
+-- Care fields an assistant message drew on, for highlighting in the UI
+ALTER TABLE chat_messages ADD COLUMN care_references TEXT[];
@@ -0,0 +1,2 @@
+-- Drop request timing
+ALTER TABLE identifications DROP COLUMN timing;
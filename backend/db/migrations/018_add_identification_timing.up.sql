@@ -0,0 +1,2 @@
+-- Step durations of the identify request, recorded with RECORD_REQUEST_TIMINGS
+ALTER TABLE identifications ADD COLUMN timing JSONB;
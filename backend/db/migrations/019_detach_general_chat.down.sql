@@ -0,0 +1,9 @@
+-- Restore the placeholder identification and move contextless messages back under it
+INSERT INTO identifications (id, genus, species, confidence, image_path, deleted_at)
+VALUES ('00000000-0000-0000-0000-000000000000', 'General', '', 0, '', CURRENT_TIMESTAMP)
+ON CONFLICT (id) DO NOTHING;
+
+UPDATE chat_messages SET identification_id = '00000000-0000-0000-0000-000000000000'
+WHERE identification_id IS NULL;
+
+ALTER TABLE chat_messages ALTER COLUMN identification_id SET NOT NULL;
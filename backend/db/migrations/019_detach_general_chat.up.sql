@@ -0,0 +1,8 @@
+-- Contextless (general advice) chat messages have no identification instead of
+-- sharing the placeholder identification created in 007
+ALTER TABLE chat_messages ALTER COLUMN identification_id DROP NOT NULL;
+
+UPDATE chat_messages SET identification_id = NULL
+WHERE identification_id = '00000000-0000-0000-0000-000000000000';
+
+DELETE FROM identifications WHERE id = '00000000-0000-0000-0000-000000000000';
@@ -0,0 +1,2 @@
+-- Restore the image_hash index
+CREATE INDEX idx_identifications_image_hash ON identifications(image_hash);
@@ -0,0 +1,3 @@
+-- Similar images are found by Hamming distance (bit_count of the XOR of two
+-- hashes), which a B-tree index on image_hash cannot serve
+DROP INDEX IF EXISTS idx_identifications_image_hash;
@@ -0,0 +1,2 @@
+-- Drop the care backfill claim column
+ALTER TABLE identifications DROP COLUMN care_claimed_at;
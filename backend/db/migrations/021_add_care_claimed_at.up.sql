@@ -0,0 +1,2 @@
+-- When a care backfill claimed an identification, so concurrent backfills skip it
+ALTER TABLE identifications ADD COLUMN care_claimed_at TIMESTAMP WITH TIME ZONE;
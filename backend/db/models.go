@@ -10,6 +10,39 @@ var (
 	ErrNotFound = errors.New("record not found")
 )
 
+// MaxNameLength is the longest genus or species the VARCHAR(255) columns accept
+const MaxNameLength = 255
+
+// GeneralConversationID is the identification ID under which chat messages
+// without a plant context used to be stored, shared by every client. Such
+// messages now have no identification; the ID stays reserved so it is never
+// served as a conversation.
+const GeneralConversationID = "00000000-0000-0000-0000-000000000000"
+
+// Care generation states for an identification
+const (
+	CareStatusReady      = "ready"      // care guide is populated
+	CareStatusGenerating = "generating" // care guide is being generated in the background
+	CareStatusNone       = "none"       // no care guide, the identification was too uncertain
+)
+
+// Care difficulty ratings, how hard a plant is to keep alive for a beginner
+const (
+	CareDifficultyEasy     = "easy"
+	CareDifficultyModerate = "moderate"
+	CareDifficultyHard     = "hard"
+)
+
+// IsValidCareDifficulty reports whether difficulty is one of the care difficulty ratings
+func IsValidCareDifficulty(difficulty string) bool {
+	switch difficulty {
+	case CareDifficultyEasy, CareDifficultyModerate, CareDifficultyHard:
+		return true
+	default:
+		return false
+	}
+}
+
 // CareGuide represents plant care instructions
 type CareGuide struct {
 	Sunlight string `json:"sunlight"`
@@ -17,41 +50,116 @@ type CareGuide struct {
 	Soil     string `json:"soil"`
 	Notes    string `json:"notes,omitempty"`
 	Trivia   string `json:"trivia,omitempty"`
+
+	// Difficulty is easy, moderate or hard; empty for care generated before it was requested
+	Difficulty string `json:"difficulty,omitempty"`
+
+	// ExtraFields keeps generated fields this struct has no field for yet
+	// (e.g. toxicity after a prompt change) so they are stored, not dropped
+	ExtraFields map[string]any `json:"extra_fields,omitempty"`
+}
+
+// ImageMetadata describes an uploaded image as recorded at upload
+type ImageMetadata struct {
+	Width      int        `json:"width"`
+	Height     int        `json:"height"`
+	Format     string     `json:"format"`
+	Size       int64      `json:"size"`
+	CapturedAt *time.Time `json:"captured_at,omitempty"` // EXIF capture time, camera local time
+}
+
+// BoundingBox locates a detected plant in an image
+type BoundingBox struct {
+	X      float64 `json:"x"`
+	Y      float64 `json:"y"`
+	Width  float64 `json:"width"`
+	Height float64 `json:"height"`
 }
 
 // Identification represents a plant identification record
 type Identification struct {
-	ID         string     `json:"id"`
-	Genus      string     `json:"genus"`
-	Species    string     `json:"species"`
-	Confidence float64    `json:"confidence"`
-	ImagePath  string     `json:"image_path"`
-	CareGuide  *CareGuide `json:"care_guide"` // Stored as JSONB in database
-	CreatedAt  time.Time  `json:"created_at"`
-	DeletedAt  *time.Time `json:"deleted_at,omitempty"` // Soft delete timestamp
+	ID                 string         `json:"id"`
+	Genus              string         `json:"genus"`
+	Species            string         `json:"species"`
+	Confidence         float64        `json:"confidence"`
+	ImagePath          string         `json:"image_path"`
+	OptimizedImagePath string         `json:"optimized_image_path,omitempty"` // Web-friendly JPEG copy of ImagePath, empty when not generated
+	CareGuide          *CareGuide     `json:"care_guide"`                     // Stored as JSONB in database
+	CareStatus         string         `json:"care_status"`
+	ImageHash          *int64         `json:"image_hash,omitempty"`     // Perceptual dHash of the image, bit pattern stored as BIGINT
+	ImageMetadata      *ImageMetadata `json:"image_metadata,omitempty"` // Stored as JSONB in database
+	ParentID           string         `json:"parent_id,omitempty"`      // First identification of the same multi-plant image, empty otherwise
+	BoundingBox        *BoundingBox   `json:"bounding_box,omitempty"`   // Where the plant was detected in a multi-plant image, stored as JSONB
+	ClientID           string         `json:"-"`                        // IP of the client that uploaded the image, empty if not recorded
+	Timing             *RequestTiming `json:"-"`                        // Step durations of the identify request, stored as JSONB when recorded
+	CreatedAt          time.Time      `json:"created_at"`
+	DeletedAt          *time.Time     `json:"deleted_at,omitempty"` // Soft delete timestamp
 }
 
 // ChatMessage represents a chat message in a conversation
 type ChatMessage struct {
 	ID               string    `json:"id"`
-	IdentificationID string    `json:"identification_id"`
+	IdentificationID string    `json:"identification_id"` // empty for general advice without a plant context
 	Message          string    `json:"message"`
-	Sender           string    `json:"sender"` // "user" or "llm"
+	Sender           string    `json:"sender"`                    // "user" or "llm"
+	Model            string    `json:"model,omitempty"`           // LLM that produced an "llm" message, empty for user messages
+	Truncated        bool      `json:"truncated,omitempty"`       // "llm" message cut off by the max token cap
+	CareReferences   []string  `json:"care_references,omitempty"` // care fields an "llm" message drew on, e.g. "watering"
 	CreatedAt        time.Time `json:"created_at"`
 }
 
+// RequestTiming is how long the steps of an identify request took, in
+// milliseconds. Steps not broken out (e.g. duplicate lookups) only count
+// toward the total.
+type RequestTiming struct {
+	UploadMs    float64 `json:"upload_ms"`    // reading, saving, hashing and optimizing the upload
+	InferenceMs float64 `json:"inference_ms"` // ML inference, including a retry
+	CareMs      float64 `json:"care_ms"`      // care lookup and generation
+	SaveMs      float64 `json:"save_ms"`      // saving the identification
+	TotalMs     float64 `json:"total_ms"`
+}
+
+// FailedIdentification records an identification attempt whose inference failed
+type FailedIdentification struct {
+	ID        string    `json:"id"`
+	ImagePath string    `json:"image_path"`
+	Error     string    `json:"error"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
 // IdentificationWithChats represents an identification with its chat history
 type IdentificationWithChats struct {
 	Identification Identification `json:"identification"`
 	ChatMessages   []ChatMessage  `json:"chat_messages"`
 }
 
+// SpeciesKey identifies a plant by genus and species as stored
+type SpeciesKey struct {
+	Genus   string
+	Species string
+}
+
+// CachedSpecies is a plant with cached care and the care's difficulty rating
+type CachedSpecies struct {
+	SpeciesKey
+	Difficulty string // empty when the cached care is unrated
+}
+
+// SpeciesCount is the number of non-deleted identifications of a plant
+type SpeciesCount struct {
+	SpeciesKey
+	Count int
+}
+
 // CareInstructionsCache represents cached LLM-generated care instructions
 type CareInstructionsCache struct {
-	ID         string     `json:"id"`
-	Genus      string     `json:"genus"`
-	Species    string     `json:"species"`
-	CareGuide  *CareGuide `json:"care_guide"` // Stored as JSONB in database
-	CreatedAt  time.Time  `json:"created_at"`
-	UpdatedAt  time.Time  `json:"updated_at"`
+	ID            string     `json:"id"`
+	Genus         string     `json:"genus"`
+	Species       string     `json:"species"`
+	CareGuide     *CareGuide `json:"care_guide"`     // Stored as JSONB in database
+	Verified      bool       `json:"verified"`       // Curated entries are never evicted
+	PromptVersion int        `json:"prompt_version"` // Care prompt version the entry was generated with
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+	AccessedAt    time.Time  `json:"accessed_at"` // Last read, used for LRU eviction
 }
@@ -0,0 +1,87 @@
+package db
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"log"
+	"syscall"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// Retry policy for transient database errors
+const maxQueryAttempts = 3
+
+// retryBaseDelay is the backoff before the first retry, doubled on each further attempt
+var retryBaseDelay = 50 * time.Millisecond
+
+// retryablePQCodes are Postgres error codes worth retrying: the same statement
+// may succeed once the conflicting transaction or connection problem is gone
+var retryablePQCodes = map[pq.ErrorCode]bool{
+	"40001": true, // serialization_failure
+	"40P01": true, // deadlock_detected
+	"08000": true, // connection_exception
+	"08003": true, // connection_does_not_exist
+	"08006": true, // connection_failure
+	"57P01": true, // admin_shutdown
+	"57P03": true, // cannot_connect_now
+}
+
+// isRetryable reports whether err is a transient failure
+func isRetryable(err error) bool {
+	if err == nil || errors.Is(err, sql.ErrNoRows) {
+		return false
+	}
+
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		return retryablePQCodes[pqErr.Code]
+	}
+
+	return errors.Is(err, sql.ErrConnDone) ||
+		errors.Is(err, driver.ErrBadConn) ||
+		errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.ECONNREFUSED)
+}
+
+// uniqueViolationCode is the Postgres error code of a unique constraint violation
+const uniqueViolationCode pq.ErrorCode = "23505"
+
+// isUniqueViolation reports whether err is a unique constraint violation
+func isUniqueViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == uniqueViolationCode
+}
+
+// withRetry runs op, retrying transient errors with exponential backoff.
+// Only use it for idempotent reads and inserts with a client-generated ID.
+func withRetry(op func() error) error {
+	delay := retryBaseDelay
+	var err error
+	for attempt := 1; attempt <= maxQueryAttempts; attempt++ {
+		err = op()
+		if !isRetryable(err) || attempt == maxQueryAttempts {
+			return err
+		}
+
+		log.Printf("Transient database error (attempt %d/%d), retrying: %v", attempt, maxQueryAttempts, err)
+		time.Sleep(delay)
+		delay *= 2
+	}
+	return err
+}
+
+// queryWithRetry runs a read query, retrying transient errors before any row is read
+func queryWithRetry(db *sql.DB, query string, args ...interface{}) (*sql.Rows, error) {
+	var rows *sql.Rows
+	err := withRetry(func() error {
+		var err error
+		rows, err = db.Query(query, args...)
+		return err
+	})
+	return rows, err
+}
@@ -0,0 +1,133 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/lib/pq"
+)
+
+// errDatabase is a non-retryable failure used by repository error cases
+var errDatabase = errors.New("database error")
+
+func init() {
+	// Retries still happen in tests, just without waiting
+	retryBaseDelay = 0
+}
+
+func TestIsRetryable(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		expected bool
+	}{
+		{name: "Nil", err: nil, expected: false},
+		{name: "No rows", err: sql.ErrNoRows, expected: false},
+		{name: "Connection done", err: sql.ErrConnDone, expected: true},
+		{name: "Serialization failure", err: &pq.Error{Code: "40001"}, expected: true},
+		{name: "Deadlock", err: &pq.Error{Code: "40P01"}, expected: true},
+		{name: "Unique violation", err: &pq.Error{Code: "23505"}, expected: false},
+		{name: "Generic error", err: errDatabase, expected: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isRetryable(tt.err); got != tt.expected {
+				t.Errorf("isRetryable(%v) = %v, expected %v", tt.err, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestRepositoryRetriesTransientErrors(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create mock database: %v", err)
+	}
+	defer db.Close()
+
+	repo := NewIdentificationRepository(db)
+
+	// The first attempt hits a dropped connection, the retry succeeds
+	mock.ExpectQuery("SELECT (.+) FROM identifications WHERE id").
+		WithArgs("test-uuid-1").
+		WillReturnError(sql.ErrConnDone)
+	mock.ExpectQuery("SELECT (.+) FROM identifications WHERE id").
+		WithArgs("test-uuid-1").
+		WillReturnRows(sqlmock.NewRows([]string{
+			"id", "genus", "species", "confidence", "image_path", "optimized_image_path", "care_guide", "care_status", "image_hash", "image_metadata", "created_at",
+		}).AddRow("test-uuid-1", "Haworthia", "zebrina", 0.95, "/uploads/test.jpg", "", nil, CareStatusReady, nil, nil, time.Now()))
+
+	identification, err := repo.GetByID("test-uuid-1")
+	if err != nil {
+		t.Fatalf("GetByID() unexpected error after retry: %v", err)
+	}
+	if identification.ID != "test-uuid-1" {
+		t.Errorf("GetByID() ID = %s, expected test-uuid-1", identification.ID)
+	}
+
+	// Reads through rows are retried the same way
+	mock.ExpectQuery("SELECT (.+) FROM identifications WHERE deleted_at IS NULL").
+		WillReturnError(sql.ErrConnDone)
+	mock.ExpectQuery("SELECT (.+) FROM identifications WHERE deleted_at IS NULL").
+		WillReturnRows(sqlmock.NewRows([]string{
+			"id", "genus", "species", "confidence", "image_path", "optimized_image_path", "care_guide", "created_at",
+		}).AddRow("test-uuid-1", "Haworthia", "zebrina", 0.95, "/uploads/test.jpg", "", nil, time.Now()))
+
+	identifications, err := repo.GetAll(20, 0)
+	if err != nil || len(identifications) != 1 {
+		t.Fatalf("GetAll() = %d items, %v; expected 1 item after retry", len(identifications), err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unfulfilled expectations: %v", err)
+	}
+}
+
+func TestRepositoryDoesNotRetryPermanentErrors(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create mock database: %v", err)
+	}
+	defer db.Close()
+
+	repo := NewChatRepository(db)
+
+	mock.ExpectQuery("SELECT COUNT\\(\\*\\) FROM chat_messages").
+		WithArgs("plant-1").
+		WillReturnError(&pq.Error{Code: "42P01"}) // undefined_table
+
+	if _, err := repo.CountByIdentificationID("plant-1"); err == nil {
+		t.Fatal("CountByIdentificationID() expected error")
+	}
+
+	// A retry would be an unexpected second query
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unfulfilled expectations: %v", err)
+	}
+}
+
+func TestRepositoryGivesUpAfterMaxAttempts(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create mock database: %v", err)
+	}
+	defer db.Close()
+
+	repo := NewIdentificationRepository(db)
+
+	for i := 0; i < maxQueryAttempts; i++ {
+		mock.ExpectQuery("SELECT COUNT\\(\\*\\) FROM identifications").
+			WillReturnError(&pq.Error{Code: "40001"})
+	}
+
+	if _, err := repo.Count(); err == nil {
+		t.Fatal("Count() expected error after exhausting retries")
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unfulfilled expectations: %v", err)
+	}
+}
@@ -0,0 +1,217 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"succulent-identifier-backend/models"
+	"succulent-identifier-backend/services"
+)
+
+// AdminHandler serves maintenance endpoints, registered behind admin token auth
+type AdminHandler struct {
+	careRepo CareCacheFlusherInterface
+	errorLog ErrorLogInterface       // nil when the error log is disabled
+	backfill CareBackfillerInterface // nil when care backfill is unavailable
+	timings  TimingListerInterface   // nil when request timings are not recorded
+}
+
+// Care backfill batch sizes
+const (
+	defaultBackfillLimit = 100
+	maxBackfillLimit     = 1000
+)
+
+// Number of request timings listed
+const (
+	defaultTimingsLimit = 50
+	maxTimingsLimit     = 500
+)
+
+// NewAdminHandler creates a new admin handler
+func NewAdminHandler(careRepo CareCacheFlusherInterface) *AdminHandler {
+	return &AdminHandler{careRepo: careRepo}
+}
+
+// SetErrorLog enables GET /admin/errors, listing the errors recorded in errorLog
+func (h *AdminHandler) SetErrorLog(errorLog ErrorLogInterface) {
+	h.errorLog = errorLog
+}
+
+// SetCareBackfiller enables POST /admin/care/backfill
+func (h *AdminHandler) SetCareBackfiller(backfill CareBackfillerInterface) {
+	h.backfill = backfill
+}
+
+// SetTimings enables GET /admin/timings, listing recorded identify request timings
+func (h *AdminHandler) SetTimings(timings TimingListerInterface) {
+	h.timings = timings
+}
+
+// HandleFlushCare deletes all non-verified cached care, or only that of the
+// genus given by ?genus=, so it is generated again on the next identification
+func (h *AdminHandler) HandleFlushCare(w http.ResponseWriter, r *http.Request) {
+	// Only accept DELETE requests
+	if r.Method != http.MethodDelete {
+		h.sendError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	// Cached genera are stored lowercase
+	genus := strings.ToLower(strings.TrimSpace(r.URL.Query().Get("genus")))
+
+	deleted, err := h.careRepo.FlushCache(genus)
+	if err != nil {
+		log.Printf("Failed to flush care cache: %v", err)
+		h.sendError(w, http.StatusInternalServerError, "Failed to flush care cache")
+		return
+	}
+	log.Printf("Flushed %d cached care entries (genus: %q)", deleted, genus)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(models.CareFlushResponse{Deleted: deleted, Genus: genus})
+}
+
+// HandleRecentErrors lists the most recent server errors, newest first
+func (h *AdminHandler) HandleRecentErrors(w http.ResponseWriter, r *http.Request) {
+	// Only accept GET requests
+	if r.Method != http.MethodGet {
+		h.sendError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	if h.errorLog == nil {
+		h.sendError(w, http.StatusNotFound, "Error log is disabled")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(models.RecentErrorsResponse{
+		Errors:   h.errorLog.Recent(),
+		Capacity: h.errorLog.Capacity(),
+	})
+}
+
+// HandleBackfillCare starts generating care in the background for up to
+// ?limit= identifications saved without it and reports how many were queued
+func (h *AdminHandler) HandleBackfillCare(w http.ResponseWriter, r *http.Request) {
+	// Only accept POST requests
+	if r.Method != http.MethodPost {
+		h.sendError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	if h.backfill == nil {
+		h.sendError(w, http.StatusNotFound, "Care backfill is unavailable")
+		return
+	}
+
+	limit := defaultBackfillLimit
+	if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
+		parsedLimit, err := strconv.Atoi(limitStr)
+		if err != nil || parsedLimit < 1 || parsedLimit > maxBackfillLimit {
+			h.sendError(w, http.StatusBadRequest, fmt.Sprintf("limit must be between 1 and %d", maxBackfillLimit))
+			return
+		}
+		limit = parsedLimit
+	}
+
+	queued, err := h.backfill.BackfillCare(limit)
+	if err != nil {
+		log.Printf("Failed to start care backfill: %v", err)
+		h.sendError(w, http.StatusInternalServerError, "Failed to start care backfill")
+		return
+	}
+	log.Printf("Queued care backfill for %d identifications", queued)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(models.CareBackfillResponse{Queued: queued})
+}
+
+// HandleCarePrompt returns the prompt care generation would send the LLM for
+// ?genus= and ?species=, without calling it, for prompt engineering
+func (h *AdminHandler) HandleCarePrompt(w http.ResponseWriter, r *http.Request) {
+	// Only accept GET requests
+	if r.Method != http.MethodGet {
+		h.sendError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	genus := strings.TrimSpace(r.URL.Query().Get("genus"))
+	species := strings.TrimSpace(r.URL.Query().Get("species"))
+	if genus == "" {
+		h.sendError(w, http.StatusBadRequest, "genus is required")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(models.CarePromptResponse{
+		Genus:   genus,
+		Species: species,
+		System:  services.CareSystemPrompt,
+		Prompt:  services.CarePrompt(genus, species),
+	})
+}
+
+// HandleTimings lists the step durations of the most recent ?limit= identify
+// requests, newest first, for diagnosing slow identifications
+func (h *AdminHandler) HandleTimings(w http.ResponseWriter, r *http.Request) {
+	// Only accept GET requests
+	if r.Method != http.MethodGet {
+		h.sendError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	if h.timings == nil {
+		h.sendError(w, http.StatusNotFound, "Request timings are not recorded")
+		return
+	}
+
+	limit := defaultTimingsLimit
+	if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
+		parsedLimit, err := strconv.Atoi(limitStr)
+		if err != nil || parsedLimit < 1 || parsedLimit > maxTimingsLimit {
+			h.sendError(w, http.StatusBadRequest, fmt.Sprintf("limit must be between 1 and %d", maxTimingsLimit))
+			return
+		}
+		limit = parsedLimit
+	}
+
+	identifications, err := h.timings.ListTimings(limit)
+	if err != nil {
+		log.Printf("Failed to list request timings: %v", err)
+		h.sendError(w, http.StatusInternalServerError, "Failed to list request timings")
+		return
+	}
+
+	response := models.TimingsResponse{Timings: make([]models.IdentificationTiming, 0, len(identifications))}
+	for _, identification := range identifications {
+		if identification.Timing == nil {
+			continue
+		}
+		response.Timings = append(response.Timings, models.IdentificationTiming{
+			ID:        identification.ID,
+			Genus:     identification.Genus,
+			Species:   identification.Species,
+			CreatedAt: identification.CreatedAt,
+			Timing:    models.RequestTiming(*identification.Timing),
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
+
+// sendError sends an error response
+func (h *AdminHandler) sendError(w http.ResponseWriter, statusCode int, message string) {
+	writeError(w, statusCode, message)
+}
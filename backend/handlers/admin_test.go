@@ -0,0 +1,363 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"succulent-identifier-backend/db"
+	"succulent-identifier-backend/models"
+	"succulent-identifier-backend/utils"
+)
+
+// mockCareCacheFlusher records flushed genera and returns a fixed count
+type mockCareCacheFlusher struct {
+	deleted int64
+	err     error
+	genera  []string
+}
+
+func (m *mockCareCacheFlusher) FlushCache(genus string) (int64, error) {
+	m.genera = append(m.genera, genus)
+	return m.deleted, m.err
+}
+
+func TestAdminHandlerHandleFlushCare(t *testing.T) {
+	tests := []struct {
+		name           string
+		method         string
+		path           string
+		repoErr        error
+		expectedStatus int
+		expectedGenus  string
+		expectFlush    bool
+	}{
+		{
+			name:           "Flush all non-verified care",
+			method:         http.MethodDelete,
+			path:           "/admin/care",
+			expectedStatus: http.StatusOK,
+			expectFlush:    true,
+		},
+		{
+			name:           "Flush one genus",
+			method:         http.MethodDelete,
+			path:           "/admin/care?genus=%20Haworthia%20",
+			expectedStatus: http.StatusOK,
+			expectedGenus:  "haworthia",
+			expectFlush:    true,
+		},
+		{
+			name:           "Repository error",
+			method:         http.MethodDelete,
+			path:           "/admin/care",
+			repoErr:        errors.New("database error"),
+			expectedStatus: http.StatusInternalServerError,
+			expectFlush:    true,
+		},
+		{
+			name:           "Method not allowed",
+			method:         http.MethodGet,
+			path:           "/admin/care",
+			expectedStatus: http.StatusMethodNotAllowed,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &mockCareCacheFlusher{deleted: 7, err: tt.repoErr}
+			handler := NewAdminHandler(repo)
+
+			rr := httptest.NewRecorder()
+			handler.HandleFlushCare(rr, httptest.NewRequest(tt.method, tt.path, nil))
+
+			if rr.Code != tt.expectedStatus {
+				t.Fatalf("Expected status %d, got %d: %s", tt.expectedStatus, rr.Code, rr.Body.String())
+			}
+			if !tt.expectFlush {
+				if len(repo.genera) != 0 {
+					t.Errorf("Expected no flush, got %v", repo.genera)
+				}
+				return
+			}
+			if len(repo.genera) != 1 || repo.genera[0] != tt.expectedGenus {
+				t.Fatalf("Expected one flush of genus %q, got %v", tt.expectedGenus, repo.genera)
+			}
+
+			if tt.expectedStatus == http.StatusOK {
+				var response models.CareFlushResponse
+				if err := json.NewDecoder(rr.Body).Decode(&response); err != nil {
+					t.Fatalf("Failed to decode response: %v", err)
+				}
+				if response.Deleted != 7 || response.Genus != tt.expectedGenus {
+					t.Errorf("Expected 7 deleted for genus %q, got %+v", tt.expectedGenus, response)
+				}
+			}
+		})
+	}
+}
+
+// mockCareBackfiller records backfill limits and queues a fixed count
+type mockCareBackfiller struct {
+	queued int
+	err    error
+	limits []int
+}
+
+func (m *mockCareBackfiller) BackfillCare(limit int) (int, error) {
+	m.limits = append(m.limits, limit)
+	return m.queued, m.err
+}
+
+func TestAdminHandlerHandleBackfillCare(t *testing.T) {
+	tests := []struct {
+		name           string
+		method         string
+		path           string
+		disabled       bool
+		backfillErr    error
+		expectedStatus int
+		expectedLimit  int // 0 when no backfill should start
+	}{
+		{
+			name:           "Default limit",
+			method:         http.MethodPost,
+			path:           "/admin/care/backfill",
+			expectedStatus: http.StatusAccepted,
+			expectedLimit:  defaultBackfillLimit,
+		},
+		{
+			name:           "Custom limit",
+			method:         http.MethodPost,
+			path:           "/admin/care/backfill?limit=20",
+			expectedStatus: http.StatusAccepted,
+			expectedLimit:  20,
+		},
+		{
+			name:           "Limit too large",
+			method:         http.MethodPost,
+			path:           "/admin/care/backfill?limit=5000",
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "Invalid limit",
+			method:         http.MethodPost,
+			path:           "/admin/care/backfill?limit=all",
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "Repository error",
+			method:         http.MethodPost,
+			path:           "/admin/care/backfill",
+			backfillErr:    errors.New("database error"),
+			expectedStatus: http.StatusInternalServerError,
+			expectedLimit:  defaultBackfillLimit,
+		},
+		{
+			name:           "Unavailable",
+			method:         http.MethodPost,
+			path:           "/admin/care/backfill",
+			disabled:       true,
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			name:           "Method not allowed",
+			method:         http.MethodGet,
+			path:           "/admin/care/backfill",
+			expectedStatus: http.StatusMethodNotAllowed,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			backfiller := &mockCareBackfiller{queued: 3, err: tt.backfillErr}
+			handler := NewAdminHandler(&mockCareCacheFlusher{})
+			if !tt.disabled {
+				handler.SetCareBackfiller(backfiller)
+			}
+
+			rr := httptest.NewRecorder()
+			handler.HandleBackfillCare(rr, httptest.NewRequest(tt.method, tt.path, nil))
+
+			if rr.Code != tt.expectedStatus {
+				t.Fatalf("Expected status %d, got %d: %s", tt.expectedStatus, rr.Code, rr.Body.String())
+			}
+			if tt.expectedLimit == 0 {
+				if len(backfiller.limits) != 0 {
+					t.Errorf("Expected no backfill, got %v", backfiller.limits)
+				}
+				return
+			}
+			if len(backfiller.limits) != 1 || backfiller.limits[0] != tt.expectedLimit {
+				t.Fatalf("Expected one backfill with limit %d, got %v", tt.expectedLimit, backfiller.limits)
+			}
+
+			if tt.expectedStatus == http.StatusAccepted {
+				var response models.CareBackfillResponse
+				if err := json.NewDecoder(rr.Body).Decode(&response); err != nil {
+					t.Fatalf("Failed to decode response: %v", err)
+				}
+				if response.Queued != 3 {
+					t.Errorf("Expected 3 queued, got %+v", response)
+				}
+			}
+		})
+	}
+}
+
+func TestAdminHandlerHandleRecentErrors(t *testing.T) {
+	errorLog := utils.NewErrorLog(2)
+	handler := NewAdminHandler(&mockCareCacheFlusher{err: errors.New("database error")})
+	handler.SetErrorLog(errorLog)
+
+	// Each failed flush writes a 500 through sendError
+	flush := utils.LoggingMiddleware(utils.ErrorLogMiddleware(errorLog, http.HandlerFunc(handler.HandleFlushCare)))
+	for _, id := range []string{"req-1", "req-2", "req-3"} {
+		req := httptest.NewRequest(http.MethodDelete, "/admin/care", nil)
+		req.Header.Set(utils.RequestIDHeader, id)
+		flush.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	rr := httptest.NewRecorder()
+	handler.HandleRecentErrors(rr, httptest.NewRequest(http.MethodGet, "/admin/errors", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+
+	var response models.RecentErrorsResponse
+	if err := json.NewDecoder(rr.Body).Decode(&response); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if response.Capacity != 2 || len(response.Errors) != 2 {
+		t.Fatalf("Expected 2 of 2 errors, got %+v", response)
+	}
+	for i, id := range []string{"req-3", "req-2"} {
+		entry := response.Errors[i]
+		if entry.RequestID != id || entry.Path != "/admin/care" || entry.Status != http.StatusInternalServerError ||
+			entry.Error != "Failed to flush care cache" {
+			t.Errorf("Errors[%d] = %+v, expected request %s", i, entry, id)
+		}
+	}
+
+	t.Run("Disabled", func(t *testing.T) {
+		rr := httptest.NewRecorder()
+		NewAdminHandler(&mockCareCacheFlusher{}).HandleRecentErrors(rr, httptest.NewRequest(http.MethodGet, "/admin/errors", nil))
+		if rr.Code != http.StatusNotFound {
+			t.Errorf("Expected status %d, got %d", http.StatusNotFound, rr.Code)
+		}
+	})
+}
+
+func TestAdminHandlerHandleCarePrompt(t *testing.T) {
+	handler := NewAdminHandler(&mockCareCacheFlusher{})
+
+	rr := httptest.NewRecorder()
+	handler.HandleCarePrompt(rr, httptest.NewRequest(http.MethodGet, "/admin/care/prompt?genus=Haworthia&species=zebrina", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+
+	var response models.CarePromptResponse
+	if err := json.NewDecoder(rr.Body).Decode(&response); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if !strings.Contains(response.Prompt, "Haworthia zebrina") {
+		t.Errorf("Expected the prompt to name Haworthia zebrina, got %q", response.Prompt)
+	}
+	if response.System == "" {
+		t.Error("Expected the system message")
+	}
+
+	t.Run("Missing genus", func(t *testing.T) {
+		rr := httptest.NewRecorder()
+		handler.HandleCarePrompt(rr, httptest.NewRequest(http.MethodGet, "/admin/care/prompt?species=zebrina", nil))
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("Expected status %d, got %d", http.StatusBadRequest, rr.Code)
+		}
+	})
+}
+
+func TestAdminHandlerHandleTimings(t *testing.T) {
+	createdAt := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	timings := &mockTimingRepository{listed: []db.Identification{
+		{ID: "id-1", Genus: "echeveria", Species: "echeveria_elegans", CreatedAt: createdAt,
+			Timing: &db.RequestTiming{UploadMs: 10, InferenceMs: 300, CareMs: 1500, SaveMs: 5, TotalMs: 1820}},
+	}}
+	handler := NewAdminHandler(&mockCareCacheFlusher{})
+	handler.SetTimings(timings)
+
+	rr := httptest.NewRecorder()
+	handler.HandleTimings(rr, httptest.NewRequest(http.MethodGet, "/admin/timings?limit=10", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+
+	var response models.TimingsResponse
+	if err := json.NewDecoder(rr.Body).Decode(&response); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	expected := models.IdentificationTiming{
+		ID: "id-1", Genus: "echeveria", Species: "echeveria_elegans", CreatedAt: createdAt,
+		Timing: models.RequestTiming{UploadMs: 10, InferenceMs: 300, CareMs: 1500, SaveMs: 5, TotalMs: 1820},
+	}
+	if len(response.Timings) != 1 || response.Timings[0] != expected {
+		t.Errorf("Timings = %+v, expected %+v", response.Timings, expected)
+	}
+
+	t.Run("Invalid limit", func(t *testing.T) {
+		rr := httptest.NewRecorder()
+		handler.HandleTimings(rr, httptest.NewRequest(http.MethodGet, "/admin/timings?limit=0", nil))
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("Expected status %d, got %d", http.StatusBadRequest, rr.Code)
+		}
+	})
+
+	t.Run("Disabled", func(t *testing.T) {
+		rr := httptest.NewRecorder()
+		NewAdminHandler(&mockCareCacheFlusher{}).HandleTimings(rr, httptest.NewRequest(http.MethodGet, "/admin/timings", nil))
+		if rr.Code != http.StatusNotFound {
+			t.Errorf("Expected status %d, got %d", http.StatusNotFound, rr.Code)
+		}
+	})
+}
+
+func TestRegisterRoutesAdmin(t *testing.T) {
+	tests := []struct {
+		name           string
+		withAdmin      bool
+		authorization  string
+		expectedStatus int
+	}{
+		{name: "Not registered without a token", expectedStatus: http.StatusNotFound},
+		{name: "Missing token", withAdmin: true, expectedStatus: http.StatusUnauthorized},
+		{name: "Wrong token", withAdmin: true, authorization: "Bearer wrong", expectedStatus: http.StatusUnauthorized},
+		{name: "Valid token", withAdmin: true, authorization: "Bearer admin-secret", expectedStatus: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			routes := newTestRoutes(t, false)
+			if tt.withAdmin {
+				routes.Admin = NewAdminHandler(&mockCareCacheFlusher{})
+				routes.AdminToken = "admin-secret"
+			}
+			mux := http.NewServeMux()
+			RegisterRoutes(mux, routes, utils.FeatureFlags{})
+
+			req := httptest.NewRequest(http.MethodDelete, "/admin/care", nil)
+			if tt.authorization != "" {
+				req.Header.Set("Authorization", tt.authorization)
+			}
+			rr := httptest.NewRecorder()
+			mux.ServeHTTP(rr, req)
+
+			if rr.Code != tt.expectedStatus {
+				t.Errorf("Expected status %d, got %d", tt.expectedStatus, rr.Code)
+			}
+		})
+	}
+}
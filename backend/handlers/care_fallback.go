@@ -0,0 +1,57 @@
+package handlers
+
+import (
+	"log"
+
+	"succulent-identifier-backend/db"
+)
+
+// fallbackCareGuide returns curated static care data for the plant, or generic
+// succulent guidelines when no static entry exists
+func (h *IdentifyHandler) fallbackCareGuide(genus, species string) *db.CareGuide {
+	if careGuide := h.staticCareGuide(genus, species); careGuide != nil {
+		return careGuide
+	}
+	return genericCareGuide()
+}
+
+// staticCareGuide returns curated static care data for the plant, or nil
+// when there is no static entry
+func (h *IdentifyHandler) staticCareGuide(genus, species string) *db.CareGuide {
+	if h.careData == nil {
+		return nil
+	}
+	care, err := h.careData.GetCareInstructions(species, genus)
+	if err != nil {
+		return nil
+	}
+	log.Printf("Using static care data for %s %s", genus, species)
+	return &db.CareGuide{
+		Sunlight: care.Sunlight,
+		Watering: care.Watering,
+		Soil:     care.Soil,
+		Notes:    care.Notes,
+		Trivia:   care.Trivia,
+
+		Difficulty: care.Difficulty,
+	}
+}
+
+// genericCareNotes marks the generic care guide
+const genericCareNotes = "Care information could not be generated. These are general succulent care guidelines."
+
+// genericCareGuide returns general succulent care guidelines
+func genericCareGuide() *db.CareGuide {
+	return &db.CareGuide{
+		Sunlight: "Provide bright, indirect light for most succulents.",
+		Watering: "Water when soil is completely dry. Succulents prefer infrequent, deep watering.",
+		Soil:     "Use well-draining cactus or succulent mix.",
+		Notes:    genericCareNotes,
+	}
+}
+
+// isGenericCare reports whether careGuide is the generic care guide rather
+// than care for the plant
+func isGenericCare(careGuide *db.CareGuide) bool {
+	return careGuide != nil && careGuide.Notes == genericCareNotes
+}
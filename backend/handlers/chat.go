@@ -3,21 +3,43 @@ package handlers
 import (
 	"context"
 	"encoding/json"
+	"fmt"
 	"log"
+	"log/slog"
 	"net/http"
+	"slices"
+	"strings"
+	"sync"
 	"time"
+	"unicode/utf8"
 
 	"github.com/google/uuid"
 	"succulent-identifier-backend/db"
 	"succulent-identifier-backend/models"
 	"succulent-identifier-backend/services"
+	"succulent-identifier-backend/utils"
 )
 
+// maxCompareModels caps the number of models queried by a single compare request
+const maxCompareModels = 4
+
+// maxEphemeralHistory caps the client-supplied turns of an ephemeral chat
+const maxEphemeralHistory = 100
+
 // ChatHandler handles chat requests
 type ChatHandler struct {
 	chatService        ChatServiceInterface
 	identificationRepo IdentificationRepositoryInterface
 	chatRepo           ChatRepositoryInterface
+	allowContextless   bool
+	logger             *slog.Logger
+
+	// maxUserMessageChars caps the length of user messages (0 disables)
+	maxUserMessageChars int
+
+	// compareModels are the models HandleCompare may query, since each runs
+	// on the operator's API key; empty rejects every comparison
+	compareModels []string
 }
 
 // NewChatHandler creates a new chat handler
@@ -30,9 +52,36 @@ func NewChatHandler(
 		chatService:        chatService,
 		identificationRepo: identificationRepo,
 		chatRepo:           chatRepo,
+		logger:             slog.Default(),
 	}
 }
 
+// SetLogger replaces the structured logger used for chat request logs
+func (h *ChatHandler) SetLogger(logger *slog.Logger) {
+	h.logger = logger
+}
+
+// SetAllowContextless allows chatting without an identification_id. Such
+// messages get general succulent advice and are stored without an
+// identification; each one is answered on its own, without earlier messages,
+// since they belong to no conversation. Clients wanting follow-ups without an
+// identification use ephemeral chats.
+func (h *ChatHandler) SetAllowContextless(allow bool) {
+	h.allowContextless = allow
+}
+
+// SetMaxUserMessageChars caps the length in characters of user chat messages,
+// which are otherwise unbounded unlike replies (0 disables)
+func (h *ChatHandler) SetMaxUserMessageChars(limit int) {
+	h.maxUserMessageChars = limit
+}
+
+// SetCompareModels sets the models POST /chat/compare may query; any other
+// requested model is rejected with 400
+func (h *ChatHandler) SetCompareModels(models []string) {
+	h.compareModels = models
+}
+
 // Handle processes chat requests
 func (h *ChatHandler) Handle(w http.ResponseWriter, r *http.Request) {
 	// Only accept POST requests
@@ -41,6 +90,10 @@ func (h *ChatHandler) Handle(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if !h.chatAvailable(w) {
+		return
+	}
+
 	// Parse request body
 	var req models.ChatRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -48,41 +101,44 @@ func (h *ChatHandler) Handle(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if !h.messageWithinLimit(w, req.Message) {
+		return
+	}
+
+	if req.Ephemeral {
+		h.handleEphemeral(w, r, req)
+		return
+	}
+
 	// Validate request
-	if req.IdentificationID == "" {
+	if req.IdentificationID == "" && !h.allowContextless {
 		h.sendError(w, http.StatusBadRequest, "identification_id is required")
 		return
 	}
+	if req.IdentificationID != "" && !isValidID(req.IdentificationID) {
+		h.sendError(w, http.StatusBadRequest, "identification_id must be a valid UUID")
+		return
+	}
 
 	if req.Message == "" {
 		h.sendError(w, http.StatusBadRequest, "message is required")
 		return
 	}
 
-	// Get identification from database
-	identification, err := h.identificationRepo.GetByID(req.IdentificationID)
+	conversationID, identification, chatHistory, err := h.loadConversation(req.IdentificationID)
 	if err != nil {
-		log.Printf("Failed to get identification: %v", err)
 		h.sendError(w, http.StatusNotFound, "Identification not found")
 		return
 	}
 
-	// Get chat history
-	chatHistory, err := h.chatRepo.GetByIdentificationID(req.IdentificationID)
-	if err != nil {
-		log.Printf("Failed to get chat history: %v", err)
-		// Continue even if history fetch fails
-		chatHistory = []db.ChatMessage{}
-	}
-
 	// Save user message to database
 	userMessageID := uuid.New().String()
 	userMessage := &db.ChatMessage{
 		ID:               userMessageID,
-		IdentificationID: req.IdentificationID,
+		IdentificationID: conversationID,
 		Message:          req.Message,
 		Sender:           "user",
-		CreatedAt:        time.Now(),
+		CreatedAt:        time.Now().UTC(),
 	}
 
 	if err := h.chatRepo.Create(userMessage); err != nil {
@@ -91,18 +147,12 @@ func (h *ChatHandler) Handle(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Call chat service
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-	defer cancel()
-
-	chatReq := services.ChatRequest{
+	chatResp, err := h.chat(r, conversationID, services.ChatRequest{
 		UserMessage:         req.Message,
 		Identification:      identification,
 		ConversationHistory: chatHistory,
-	}
-
-	chatResp, err := h.chatService.Chat(ctx, chatReq)
+	})
 	if err != nil {
-		log.Printf("Chat service error: %v", err)
 		h.sendError(w, http.StatusInternalServerError, "Failed to get response from assistant")
 		return
 	}
@@ -111,10 +161,13 @@ func (h *ChatHandler) Handle(w http.ResponseWriter, r *http.Request) {
 	llmMessageID := uuid.New().String()
 	llmMessage := &db.ChatMessage{
 		ID:               llmMessageID,
-		IdentificationID: req.IdentificationID,
+		IdentificationID: conversationID,
 		Message:          chatResp.Message,
 		Sender:           "llm",
-		CreatedAt:        time.Now(),
+		Model:            chatResp.Model,
+		Truncated:        chatResp.Truncated,
+		CareReferences:   chatResp.CareReferences,
+		CreatedAt:        time.Now().UTC(),
 	}
 
 	if err := h.chatRepo.Create(llmMessage); err != nil {
@@ -124,9 +177,11 @@ func (h *ChatHandler) Handle(w http.ResponseWriter, r *http.Request) {
 
 	// Send response
 	response := models.ChatResponse{
-		Message:   chatResp.Message,
-		MessageID: llmMessageID,
-		Timestamp: llmMessage.CreatedAt,
+		Message:        chatResp.Message,
+		MessageID:      llmMessageID,
+		Timestamp:      llmMessage.CreatedAt,
+		Truncated:      chatResp.Truncated,
+		CareReferences: chatResp.CareReferences,
 	}
 
 	w.Header().Set("Content-Type", "application/json")
@@ -134,12 +189,371 @@ func (h *ChatHandler) Handle(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(response)
 }
 
-// sendError sends an error response
-func (h *ChatHandler) sendError(w http.ResponseWriter, statusCode int, message string) {
+// handleEphemeral answers a chat whose prior turns are supplied by the client
+// in the request body. Nothing is read from or written to the database, so
+// stateless clients can chat without a server-side conversation.
+func (h *ChatHandler) handleEphemeral(w http.ResponseWriter, r *http.Request, req models.ChatRequest) {
+	if req.IdentificationID != "" {
+		h.sendError(w, http.StatusBadRequest, "identification_id cannot be combined with ephemeral, supply the context in history")
+		return
+	}
+	if req.Message == "" {
+		h.sendError(w, http.StatusBadRequest, "message is required")
+		return
+	}
+	if len(req.History) > maxEphemeralHistory {
+		h.sendError(w, http.StatusBadRequest, fmt.Sprintf("history cannot exceed %d turns", maxEphemeralHistory))
+		return
+	}
+
+	history := make([]db.ChatMessage, 0, len(req.History))
+	for i, turn := range req.History {
+		if turn.Sender != "user" && turn.Sender != "llm" {
+			h.sendError(w, http.StatusBadRequest, fmt.Sprintf("history[%d].sender must be \"user\" or \"llm\"", i))
+			return
+		}
+		if strings.TrimSpace(turn.Message) == "" {
+			h.sendError(w, http.StatusBadRequest, fmt.Sprintf("history[%d].message is required", i))
+			return
+		}
+		// Every turn is sent to the LLM, so client-supplied replies are capped too
+		if !h.messageWithinLimit(w, turn.Message) {
+			return
+		}
+		history = append(history, db.ChatMessage{Sender: turn.Sender, Message: turn.Message})
+	}
+
+	chatResp, err := h.chat(r, "ephemeral", services.ChatRequest{
+		UserMessage:         req.Message,
+		ConversationHistory: history,
+	})
+	if err != nil {
+		h.sendError(w, http.StatusInternalServerError, "Failed to get response from assistant")
+		return
+	}
+
+	// No message ID, the reply is not stored
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(models.ChatResponse{
+		Message:        chatResp.Message,
+		Timestamp:      time.Now().UTC(),
+		Truncated:      chatResp.Truncated,
+		CareReferences: chatResp.CareReferences,
+	})
+}
+
+// chat calls the chat service and logs the outcome with its token usage
+func (h *ChatHandler) chat(r *http.Request, conversationID string, chatReq services.ChatRequest) (*services.ChatResponse, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	start := time.Now()
+	chatResp, err := h.chatService.Chat(ctx, chatReq)
+	logAttrs := []any{
+		slog.String("request_id", utils.RequestIDFromContext(r.Context())),
+		slog.String("identification_id", conversationID),
+		slog.Int("message_length", len(chatReq.UserMessage)),
+		slog.Int64("duration_ms", time.Since(start).Milliseconds()),
+	}
+	if err != nil {
+		h.logger.Error("chat request failed", append(logAttrs, slog.String("error", err.Error()))...)
+		return nil, err
+	}
+	h.logger.Info("chat request completed", append(logAttrs,
+		slog.Int("prompt_tokens", chatResp.Usage.PromptTokens),
+		slog.Int("completion_tokens", chatResp.Usage.CompletionTokens),
+		slog.Int("total_tokens", chatResp.Usage.TotalTokens),
+	)...)
+	return chatResp, nil
+}
+
+// HandleCompare sends one message to several models and returns their replies
+// side by side. Nothing is persisted, so comparing does not alter the conversation.
+func (h *ChatHandler) HandleCompare(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		h.sendError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	if !h.chatAvailable(w) {
+		return
+	}
+
+	var req models.ChatCompareRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.sendError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	if req.IdentificationID == "" && !h.allowContextless {
+		h.sendError(w, http.StatusBadRequest, "identification_id is required")
+		return
+	}
+	if req.IdentificationID != "" && !isValidID(req.IdentificationID) {
+		h.sendError(w, http.StatusBadRequest, "identification_id must be a valid UUID")
+		return
+	}
+	if req.Message == "" {
+		h.sendError(w, http.StatusBadRequest, "message is required")
+		return
+	}
+	if !h.messageWithinLimit(w, req.Message) {
+		return
+	}
+
+	// Drop blank and repeated models, keeping the requested order
+	var compareModels []string
+	seen := make(map[string]bool)
+	for _, model := range req.Models {
+		model = strings.TrimSpace(model)
+		if model == "" || seen[model] {
+			continue
+		}
+		seen[model] = true
+		compareModels = append(compareModels, model)
+	}
+	if len(compareModels) == 0 {
+		h.sendError(w, http.StatusBadRequest, "models is required")
+		return
+	}
+	if len(compareModels) > maxCompareModels {
+		h.sendError(w, http.StatusBadRequest, fmt.Sprintf("At most %d models can be compared at once", maxCompareModels))
+		return
+	}
+	for _, model := range compareModels {
+		if !slices.Contains(h.compareModels, model) {
+			h.sendError(w, http.StatusBadRequest, fmt.Sprintf("Model %q is not available for comparison", model))
+			return
+		}
+	}
+
+	conversationID, identification, chatHistory, err := h.loadConversation(req.IdentificationID)
+	if err != nil {
+		h.sendError(w, http.StatusNotFound, "Identification not found")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	// Query all models in parallel; a failing model is reported in its own reply
+	replies := make([]models.ModelReply, len(compareModels))
+	var wg sync.WaitGroup
+	for i, model := range compareModels {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			replies[i].Model = model
+
+			chatResp, err := h.chatService.Chat(ctx, services.ChatRequest{
+				UserMessage:         req.Message,
+				Identification:      identification,
+				ConversationHistory: chatHistory,
+				Model:               model,
+			})
+			if err != nil {
+				h.logger.Error("chat compare request failed",
+					slog.String("request_id", utils.RequestIDFromContext(r.Context())),
+					slog.String("identification_id", conversationID),
+					slog.String("model", model),
+					slog.String("error", err.Error()),
+				)
+				replies[i].Error = "Failed to get response from assistant"
+				return
+			}
+			replies[i].Message = chatResp.Message
+		}()
+	}
+	wg.Wait()
+
 	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(statusCode)
-	json.NewEncoder(w).Encode(models.ErrorResponse{
-		Error:   http.StatusText(statusCode),
-		Message: message,
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(models.ChatCompareResponse{
+		Message: req.Message,
+		Replies: replies,
 	})
 }
+
+// HandleEditMessage corrects a user message and regenerates the assistant reply
+// that followed it. The new reply takes the old reply's place in the conversation.
+func (h *ChatHandler) HandleEditMessage(w http.ResponseWriter, r *http.Request) {
+	// Only accept PUT requests
+	if r.Method != http.MethodPut {
+		h.sendError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	if !h.chatAvailable(w) {
+		return
+	}
+
+	// Expecting /chat/message/:id
+	messageID := strings.TrimPrefix(strings.Trim(r.URL.Path, "/"), "chat/message/")
+	if !isValidID(messageID) {
+		h.sendError(w, http.StatusBadRequest, "Invalid message ID")
+		return
+	}
+
+	var req models.ChatEditRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.sendError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if req.Message == "" {
+		h.sendError(w, http.StatusBadRequest, "message is required")
+		return
+	}
+	if !h.messageWithinLimit(w, req.Message) {
+		return
+	}
+
+	message, err := h.chatRepo.GetByID(messageID)
+	if err != nil {
+		log.Printf("Failed to get chat message: %v", err)
+		h.sendError(w, http.StatusNotFound, "Message not found")
+		return
+	}
+	if message.Sender != "user" {
+		h.sendError(w, http.StatusForbidden, "Only user messages can be edited")
+		return
+	}
+	// General advice messages belong to no conversation, so their reply cannot be found
+	if message.IdentificationID == "" {
+		h.sendError(w, http.StatusForbidden, "Only messages about an identification can be edited")
+		return
+	}
+
+	conversationID, identification, conversation, err := h.loadConversation(message.IdentificationID)
+	if err != nil {
+		h.sendError(w, http.StatusNotFound, "Identification not found")
+		return
+	}
+
+	// The reply is regenerated from the conversation as it was when the message was sent
+	history := conversation
+	var reply *db.ChatMessage
+	for i := range conversation {
+		if conversation[i].ID != messageID {
+			continue
+		}
+		history = conversation[:i]
+		if i+1 < len(conversation) && conversation[i+1].Sender == "llm" {
+			reply = &conversation[i+1]
+		}
+		break
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	chatResp, err := h.chatService.Chat(ctx, services.ChatRequest{
+		UserMessage:         req.Message,
+		Identification:      identification,
+		ConversationHistory: history,
+	})
+	if err != nil {
+		log.Printf("Failed to regenerate chat reply: %v", err)
+		h.sendError(w, http.StatusInternalServerError, "Failed to get response from assistant")
+		return
+	}
+
+	// The edit is saved only once there is a reply for it, so a failed
+	// regeneration leaves the message and its old reply as they were
+	if err := h.chatRepo.UpdateMessage(messageID, req.Message); err != nil {
+		log.Printf("Failed to update chat message: %v", err)
+		h.sendError(w, http.StatusInternalServerError, "Failed to update message")
+		return
+	}
+
+	// Keep the new reply right after the edited message, before any later messages
+	replyCreatedAt := message.CreatedAt.Add(time.Millisecond)
+	if reply != nil {
+		replyCreatedAt = reply.CreatedAt
+		if err := h.chatRepo.Delete(reply.ID); err != nil {
+			log.Printf("Failed to delete previous reply %s: %v", reply.ID, err)
+		}
+	}
+
+	llmMessage := &db.ChatMessage{
+		ID:               uuid.New().String(),
+		IdentificationID: conversationID,
+		Message:          chatResp.Message,
+		Sender:           "llm",
+		Model:            chatResp.Model,
+		Truncated:        chatResp.Truncated,
+		CareReferences:   chatResp.CareReferences,
+		CreatedAt:        replyCreatedAt,
+	}
+	if err := h.chatRepo.Create(llmMessage); err != nil {
+		log.Printf("Failed to save LLM message: %v", err)
+		// Continue even if save fails - user still gets response
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(models.ChatResponse{
+		Message:        chatResp.Message,
+		MessageID:      llmMessage.ID,
+		Timestamp:      llmMessage.CreatedAt,
+		Truncated:      chatResp.Truncated,
+		CareReferences: chatResp.CareReferences,
+	})
+}
+
+// loadConversation returns the conversation ID, identification and chat history
+// for a chat request. Without an identification ID the assistant gives general
+// advice with no history and an empty conversation ID, so messages are stored
+// without an identification; an unknown or soft-deleted identification is an
+// error, checked before any chat history is read so callers can return before
+// saving a message. A failed history fetch is logged and treated as an empty history.
+func (h *ChatHandler) loadConversation(identificationID string) (string, *db.Identification, []db.ChatMessage, error) {
+	if identificationID == "" {
+		return "", nil, []db.ChatMessage{}, nil
+	}
+
+	// GetByID excludes soft-deleted identifications
+	identification, err := h.identificationRepo.GetByID(identificationID)
+	if err == nil && identification == nil {
+		err = db.ErrNotFound
+	}
+	if err != nil {
+		log.Printf("Failed to get identification: %v", err)
+		return "", nil, nil, err
+	}
+
+	chatHistory, err := h.chatRepo.GetByIdentificationID(identificationID)
+	if err != nil {
+		log.Printf("Failed to get chat history: %v", err)
+		// Continue even if history fetch fails
+		chatHistory = []db.ChatMessage{}
+	}
+
+	return identificationID, identification, chatHistory, nil
+}
+
+// messageWithinLimit answers 400 when a user message exceeds
+// maxUserMessageChars, so every path that sends user text to the LLM
+// enforces the same cap
+func (h *ChatHandler) messageWithinLimit(w http.ResponseWriter, message string) bool {
+	if h.maxUserMessageChars > 0 && utf8.RuneCountInString(message) > h.maxUserMessageChars {
+		h.sendError(w, http.StatusBadRequest, fmt.Sprintf("message cannot exceed %d characters", h.maxUserMessageChars))
+		return false
+	}
+	return true
+}
+
+// chatAvailable answers 503 when no chat service is configured, so a handler
+// wired without an LLM degrades instead of panicking
+func (h *ChatHandler) chatAvailable(w http.ResponseWriter) bool {
+	if h.chatService == nil {
+		h.sendError(w, http.StatusServiceUnavailable, "Chat unavailable")
+		return false
+	}
+	return true
+}
+
+// sendError sends an error response
+func (h *ChatHandler) sendError(w http.ResponseWriter, statusCode int, message string) {
+	writeError(w, statusCode, message)
+}
@@ -4,25 +4,73 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
+	"log/slog"
 	"net/http"
 	"net/http/httptest"
+	"slices"
 	"succulent-identifier-backend/db"
 	"succulent-identifier-backend/models"
 	"succulent-identifier-backend/services"
+	"succulent-identifier-backend/utils"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 )
 
 // mockChatService simulates chat service responses
 type mockChatService struct {
-	response *services.ChatResponse
-	err      error
+	response  *services.ChatResponse
+	err       error
+	careGuide *db.CareGuide
+	careErr   error
+	careDelay time.Duration // simulated LLM latency for care generation
+
+	mu          sync.Mutex
+	careCalls   map[string]int // genus/species -> number of care generations
+	inFlight    int
+	maxInFlight int
+
+	lastChatRequest *services.ChatRequest
+
+	// modelReplies, when set, answers each model override with its own reply;
+	// models missing from the map fail
+	modelReplies map[string]string
 }
 
 func (m *mockChatService) Chat(ctx context.Context, req services.ChatRequest) (*services.ChatResponse, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.lastChatRequest = &req
+	if m.modelReplies != nil {
+		reply, ok := m.modelReplies[req.Model]
+		if !ok {
+			return nil, errors.New("model not found")
+		}
+		return &services.ChatResponse{Message: reply, Model: req.Model}, nil
+	}
 	return m.response, m.err
 }
 
+func (m *mockChatService) GenerateCareInstructions(ctx context.Context, genus, species string) (*db.CareGuide, error) {
+	m.mu.Lock()
+	if m.careCalls == nil {
+		m.careCalls = make(map[string]int)
+	}
+	m.careCalls[genus+"/"+species]++
+	m.inFlight++
+	m.maxInFlight = max(m.maxInFlight, m.inFlight)
+	m.mu.Unlock()
+
+	time.Sleep(m.careDelay)
+
+	m.mu.Lock()
+	m.inFlight--
+	m.mu.Unlock()
+	return m.careGuide, m.careErr
+}
+
 func TestChatHandlerHandle(t *testing.T) {
 	tests := []struct {
 		name               string
@@ -42,11 +90,11 @@ func TestChatHandlerHandle(t *testing.T) {
 			name:   "Successful chat with plant context",
 			method: http.MethodPost,
 			requestBody: models.ChatRequest{
-				IdentificationID: "plant-id-1",
+				IdentificationID: "7c9e6679-7425-40de-944b-e07fc1f90ae7",
 				Message:          "How often should I water this plant?",
 			},
 			identification: &db.Identification{
-				ID:         "plant-id-1",
+				ID:         "7c9e6679-7425-40de-944b-e07fc1f90ae7",
 				Genus:      "Haworthia",
 				Species:    "zebrina",
 				Confidence: 0.95,
@@ -62,6 +110,7 @@ func TestChatHandlerHandle(t *testing.T) {
 			chatHistory: []db.ChatMessage{},
 			chatResponse: &services.ChatResponse{
 				Message: "Based on the care instructions, water this Haworthia zebrina when the soil is dry.",
+				Model:   "gpt-4o-mini",
 			},
 			expectedStatus:    http.StatusOK,
 			expectUserMessage: true,
@@ -71,11 +120,11 @@ func TestChatHandlerHandle(t *testing.T) {
 			name:   "Chat with conversation history",
 			method: http.MethodPost,
 			requestBody: models.ChatRequest{
-				IdentificationID: "plant-id-2",
+				IdentificationID: "2b1e4f6a-93d0-4c58-8e27-5f0a9c3d1b42",
 				Message:          "Can it survive in low light?",
 			},
 			identification: &db.Identification{
-				ID:         "plant-id-2",
+				ID:         "2b1e4f6a-93d0-4c58-8e27-5f0a9c3d1b42",
 				Genus:      "Aloe",
 				Species:    "vera",
 				Confidence: 0.90,
@@ -90,14 +139,14 @@ func TestChatHandlerHandle(t *testing.T) {
 			chatHistory: []db.ChatMessage{
 				{
 					ID:               "msg-1",
-					IdentificationID: "plant-id-2",
+					IdentificationID: "2b1e4f6a-93d0-4c58-8e27-5f0a9c3d1b42",
 					Message:          "What is this plant?",
 					Sender:           "user",
 					CreatedAt:        time.Now().Add(-5 * time.Minute),
 				},
 				{
 					ID:               "msg-2",
-					IdentificationID: "plant-id-2",
+					IdentificationID: "2b1e4f6a-93d0-4c58-8e27-5f0a9c3d1b42",
 					Message:          "This is Aloe vera.",
 					Sender:           "llm",
 					CreatedAt:        time.Now().Add(-4 * time.Minute),
@@ -127,7 +176,7 @@ func TestChatHandlerHandle(t *testing.T) {
 			name:   "Missing message",
 			method: http.MethodPost,
 			requestBody: models.ChatRequest{
-				IdentificationID: "plant-id-1",
+				IdentificationID: "7c9e6679-7425-40de-944b-e07fc1f90ae7",
 			},
 			expectedStatus: http.StatusBadRequest,
 		},
@@ -135,21 +184,30 @@ func TestChatHandlerHandle(t *testing.T) {
 			name:   "Identification not found",
 			method: http.MethodPost,
 			requestBody: models.ChatRequest{
-				IdentificationID: "non-existent",
+				IdentificationID: "00000000-0000-4000-8000-000000000000",
 				Message:          "Test message",
 			},
 			identificationErr: db.ErrNotFound,
 			expectedStatus:    http.StatusNotFound,
 		},
 		{
-			name:   "Chat service error",
+			name:   "Malformed identification ID",
 			method: http.MethodPost,
 			requestBody: models.ChatRequest{
 				IdentificationID: "plant-id-1",
 				Message:          "Test message",
 			},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:   "Chat service error",
+			method: http.MethodPost,
+			requestBody: models.ChatRequest{
+				IdentificationID: "7c9e6679-7425-40de-944b-e07fc1f90ae7",
+				Message:          "Test message",
+			},
 			identification: &db.Identification{
-				ID:    "plant-id-1",
+				ID:    "7c9e6679-7425-40de-944b-e07fc1f90ae7",
 				Genus: "Test",
 			},
 			chatHistory:       []db.ChatMessage{},
@@ -228,6 +286,13 @@ func TestChatHandlerHandle(t *testing.T) {
 					t.Errorf("Expected both user and LLM messages to be saved, got %d calls",
 						mockChatRepo.createCallCount)
 				}
+
+				// The LLM message records the model that produced it
+				if tt.expectLLMMessage {
+					if saved := mockChatRepo.lastCreated; saved.Sender != "llm" || saved.Model != tt.chatResponse.Model {
+						t.Errorf("Saved LLM message sender %q model %q, expected llm %q", saved.Sender, saved.Model, tt.chatResponse.Model)
+					}
+				}
 			}
 		})
 	}
@@ -241,10 +306,31 @@ type mockChatRepository struct {
 	createErr        error
 	getAllResult     []db.ChatMessage
 	getAllErr        error
+	getAllCalled     bool
 	getLatestResult  []db.ChatMessage
 	getLatestErr     error
 	countResult      int
 	countErr         error
+	getByIDResult    *db.ChatMessage
+	getByIDErr       error
+	updatedID        string
+	updatedMessage   string
+	deletedIDs       []string
+}
+
+func (m *mockChatRepository) GetByID(id string) (*db.ChatMessage, error) {
+	return m.getByIDResult, m.getByIDErr
+}
+
+func (m *mockChatRepository) UpdateMessage(id, message string) error {
+	m.updatedID = id
+	m.updatedMessage = message
+	return nil
+}
+
+func (m *mockChatRepository) Delete(id string) error {
+	m.deletedIDs = append(m.deletedIDs, id)
+	return nil
 }
 
 func (m *mockChatRepository) Create(message *db.ChatMessage) error {
@@ -255,6 +341,7 @@ func (m *mockChatRepository) Create(message *db.ChatMessage) error {
 }
 
 func (m *mockChatRepository) GetByIdentificationID(identificationID string) ([]db.ChatMessage, error) {
+	m.getAllCalled = true
 	return m.getAllResult, m.getAllErr
 }
 
@@ -266,6 +353,361 @@ func (m *mockChatRepository) CountByIdentificationID(identificationID string) (i
 	return m.countResult, m.countErr
 }
 
+func TestChatHandlerContextless(t *testing.T) {
+	body, _ := json.Marshal(models.ChatRequest{Message: "Which succulents suit a north-facing window?"})
+
+	t.Run("Rejected unless enabled", func(t *testing.T) {
+		handler := NewChatHandler(&mockChatService{}, &mockIdentificationRepository{}, &mockChatRepository{})
+
+		rr := httptest.NewRecorder()
+		handler.Handle(rr, httptest.NewRequest(http.MethodPost, "/chat", bytes.NewBuffer(body)))
+
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("Expected status %d, got %d", http.StatusBadRequest, rr.Code)
+		}
+	})
+
+	t.Run("General advice when enabled", func(t *testing.T) {
+		mockChatSvc := &mockChatService{
+			response: &services.ChatResponse{Message: "Haworthias tolerate lower light than most succulents."},
+		}
+		mockChatRepo := &mockChatRepository{}
+		handler := NewChatHandler(mockChatSvc, &mockIdentificationRepository{getByIDErr: db.ErrNotFound}, mockChatRepo)
+		handler.SetAllowContextless(true)
+
+		rr := httptest.NewRecorder()
+		handler.Handle(rr, httptest.NewRequest(http.MethodPost, "/chat", bytes.NewBuffer(body)))
+
+		if rr.Code != http.StatusOK {
+			t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+		}
+
+		var response models.ChatResponse
+		if err := json.NewDecoder(rr.Body).Decode(&response); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		if response.Message != mockChatSvc.response.Message || response.MessageID == "" {
+			t.Errorf("Unexpected response: %+v", response)
+		}
+
+		if mockChatSvc.lastChatRequest == nil || mockChatSvc.lastChatRequest.Identification != nil {
+			t.Error("Expected chat service to be called without an identification")
+		}
+		if mockChatRepo.createCallCount != 2 || mockChatRepo.lastCreated.IdentificationID != "" {
+			t.Errorf("Expected both messages stored without an identification, got %d calls (last %+v)",
+				mockChatRepo.createCallCount, mockChatRepo.lastCreated)
+		}
+		// Other clients' general messages are never sent as history
+		if mockChatRepo.getAllCalled || len(mockChatSvc.lastChatRequest.ConversationHistory) != 0 {
+			t.Error("Expected no chat history to be loaded for a contextless message")
+		}
+	})
+}
+
+func TestChatHandlerDeletedIdentification(t *testing.T) {
+	body, _ := json.Marshal(models.ChatRequest{
+		IdentificationID: "7c9e6679-7425-40de-944b-e07fc1f90ae7",
+		Message:          "How often should I water this plant?",
+	})
+
+	// The repository excludes soft-deleted rows, so GetByID fails for them;
+	// a repository returning no row without an error must be treated the same
+	repos := map[string]*mockIdentificationRepository{
+		"Not found error": {getByIDErr: db.ErrNotFound},
+		"No row":          {},
+	}
+	for name, identRepo := range repos {
+		t.Run(name, func(t *testing.T) {
+			mockChatSvc := &mockChatService{response: &services.ChatResponse{Message: "unused"}}
+			mockChatRepo := &mockChatRepository{}
+			handler := NewChatHandler(mockChatSvc, identRepo, mockChatRepo)
+
+			rr := httptest.NewRecorder()
+			handler.Handle(rr, httptest.NewRequest(http.MethodPost, "/chat", bytes.NewBuffer(body)))
+
+			if rr.Code != http.StatusNotFound {
+				t.Errorf("Expected status %d, got %d", http.StatusNotFound, rr.Code)
+			}
+			if mockChatRepo.getAllCalled {
+				t.Error("Expected chat history not to be fetched")
+			}
+			if mockChatRepo.createCalled {
+				t.Error("Expected no message to be saved")
+			}
+			if mockChatSvc.lastChatRequest != nil {
+				t.Error("Expected chat service not to be called")
+			}
+		})
+	}
+}
+
+func TestChatHandlerMessageLimit(t *testing.T) {
+	mockChatSvc := &mockChatService{response: &services.ChatResponse{Message: "Water sparingly."}}
+	mockChatRepo := &mockChatRepository{}
+	handler := NewChatHandler(mockChatSvc, &mockIdentificationRepository{}, mockChatRepo)
+	handler.SetAllowContextless(true)
+	handler.SetMaxUserMessageChars(10)
+
+	send := func(message string, ephemeral bool) *httptest.ResponseRecorder {
+		body, _ := json.Marshal(models.ChatRequest{Message: message, Ephemeral: ephemeral})
+		rr := httptest.NewRecorder()
+		handler.Handle(rr, httptest.NewRequest(http.MethodPost, "/chat", bytes.NewBuffer(body)))
+		return rr
+	}
+
+	for _, ephemeral := range []bool{false, true} {
+		if rr := send("How much water?", ephemeral); rr.Code != http.StatusBadRequest {
+			t.Errorf("Expected status %d for an over-limit message (ephemeral %v), got %d", http.StatusBadRequest, ephemeral, rr.Code)
+		}
+	}
+	if mockChatSvc.lastChatRequest != nil || mockChatRepo.createCalled {
+		t.Error("Expected an over-limit message to be rejected before chatting or saving")
+	}
+
+	// The limit counts characters, not bytes
+	if rr := send("Wässern?🌵", false); rr.Code != http.StatusOK {
+		t.Errorf("Expected status %d for a message at the limit, got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+
+	// Editing a message cannot bypass the limit
+	sent := db.ChatMessage{ID: "0b8f7c1e-2d3a-4b5c-8d9e-1f2a3b4c5d6e", IdentificationID: "7c9e6679-7425-40de-944b-e07fc1f90ae7", Message: "Water?", Sender: "user"}
+	mockChatRepo.getByIDResult = &sent
+	mockChatSvc.lastChatRequest = nil
+	body, _ := json.Marshal(models.ChatEditRequest{Message: "How much water?"})
+	rr := httptest.NewRecorder()
+	handler.HandleEditMessage(rr, httptest.NewRequest(http.MethodPut, "/chat/message/"+sent.ID, bytes.NewBuffer(body)))
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected status %d for an over-limit edit, got %d", http.StatusBadRequest, rr.Code)
+	}
+	if mockChatRepo.updatedID != "" || mockChatSvc.lastChatRequest != nil {
+		t.Error("Expected an over-limit edit to be rejected before updating or chatting")
+	}
+}
+
+func TestChatHandlerNilChatService(t *testing.T) {
+	mockChatRepo := &mockChatRepository{}
+	handler := NewChatHandler(nil, &mockIdentificationRepository{}, mockChatRepo)
+
+	tests := []struct {
+		name   string
+		handle http.HandlerFunc
+		method string
+		path   string
+		body   string
+	}{
+		{name: "Chat", handle: handler.Handle, method: http.MethodPost, path: "/chat",
+			body: `{"identification_id":"7c9e6679-7425-40de-944b-e07fc1f90ae7","message":"How much water?"}`},
+		{name: "Compare", handle: handler.HandleCompare, method: http.MethodPost, path: "/chat/compare",
+			body: `{"identification_id":"7c9e6679-7425-40de-944b-e07fc1f90ae7","message":"How much water?","models":["a","b"]}`},
+		{name: "Edit message", handle: handler.HandleEditMessage, method: http.MethodPut,
+			path: "/chat/message/3ebcaf4b-5a6d-4e8f-9ac1-4c5d6e7f8091", body: `{"message":"How much water?"}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			tt.handle(rr, httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body)))
+
+			if rr.Code != http.StatusServiceUnavailable {
+				t.Fatalf("Expected status %d, got %d: %s", http.StatusServiceUnavailable, rr.Code, rr.Body.String())
+			}
+			var response models.ErrorResponse
+			if err := json.NewDecoder(rr.Body).Decode(&response); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+			if response.Message != "Chat unavailable" {
+				t.Errorf("Expected message %q, got %q", "Chat unavailable", response.Message)
+			}
+		})
+	}
+	if mockChatRepo.createCalled {
+		t.Error("Expected nothing saved without a chat service")
+	}
+}
+
+func TestChatHandlerTruncatedReply(t *testing.T) {
+	mockChatSvc := &mockChatService{
+		response: &services.ChatResponse{Message: "Water deeply, then let the soil", Truncated: true},
+	}
+	mockChatRepo := &mockChatRepository{}
+	handler := NewChatHandler(mockChatSvc, &mockIdentificationRepository{}, mockChatRepo)
+	handler.SetAllowContextless(true)
+
+	body, _ := json.Marshal(models.ChatRequest{Message: "How should I water it?"})
+	rr := httptest.NewRecorder()
+	handler.Handle(rr, httptest.NewRequest(http.MethodPost, "/chat", bytes.NewBuffer(body)))
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+	var response models.ChatResponse
+	if err := json.NewDecoder(rr.Body).Decode(&response); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if !response.Truncated {
+		t.Error("Expected the response to flag the truncated reply")
+	}
+	if mockChatRepo.lastCreated == nil || mockChatRepo.lastCreated.Sender != "llm" || !mockChatRepo.lastCreated.Truncated {
+		t.Errorf("Expected the stored reply to be flagged as truncated, got %+v", mockChatRepo.lastCreated)
+	}
+}
+
+func TestChatHandlerCareReferences(t *testing.T) {
+	mockChatSvc := &mockChatService{
+		response: &services.ChatResponse{Message: "Water deeply, then let the soil dry out", CareReferences: []string{"watering", "soil"}},
+	}
+	mockChatRepo := &mockChatRepository{}
+	handler := NewChatHandler(mockChatSvc, &mockIdentificationRepository{}, mockChatRepo)
+	handler.SetAllowContextless(true)
+
+	body, _ := json.Marshal(models.ChatRequest{Message: "How should I water it?"})
+	rr := httptest.NewRecorder()
+	handler.Handle(rr, httptest.NewRequest(http.MethodPost, "/chat", bytes.NewBuffer(body)))
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+	var response models.ChatResponse
+	if err := json.NewDecoder(rr.Body).Decode(&response); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if !slices.Equal(response.CareReferences, []string{"watering", "soil"}) {
+		t.Errorf("Expected care references [watering soil], got %v", response.CareReferences)
+	}
+	if mockChatRepo.lastCreated == nil || !slices.Equal(mockChatRepo.lastCreated.CareReferences, []string{"watering", "soil"}) {
+		t.Errorf("Expected the stored reply to keep its care references, got %+v", mockChatRepo.lastCreated)
+	}
+}
+
+func TestChatHandlerEphemeral(t *testing.T) {
+	history := []models.ChatTurn{
+		{Sender: "user", Message: "What is this plant?"},
+		{Sender: "llm", Message: "It looks like a Haworthia fasciata."},
+	}
+
+	t.Run("Uses inline history without persisting", func(t *testing.T) {
+		mockChatSvc := &mockChatService{
+			response: &services.ChatResponse{Message: "Water it every two weeks in summer."},
+		}
+		mockIdentRepo := &mockIdentificationRepository{}
+		mockChatRepo := &mockChatRepository{}
+		handler := NewChatHandler(mockChatSvc, mockIdentRepo, mockChatRepo)
+
+		body, _ := json.Marshal(models.ChatRequest{Message: "How often should I water it?", Ephemeral: true, History: history})
+		rr := httptest.NewRecorder()
+		handler.Handle(rr, httptest.NewRequest(http.MethodPost, "/chat", bytes.NewBuffer(body)))
+
+		if rr.Code != http.StatusOK {
+			t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+		}
+
+		var response models.ChatResponse
+		if err := json.NewDecoder(rr.Body).Decode(&response); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		if response.Message != mockChatSvc.response.Message || response.MessageID != "" {
+			t.Errorf("Unexpected response: %+v", response)
+		}
+
+		got := mockChatSvc.lastChatRequest
+		if got == nil || got.Identification != nil || len(got.ConversationHistory) != len(history) {
+			t.Fatalf("Expected chat service to receive the inline history, got %+v", got)
+		}
+		for i, turn := range history {
+			if got.ConversationHistory[i].Sender != turn.Sender || got.ConversationHistory[i].Message != turn.Message {
+				t.Errorf("History[%d] = %+v, expected %+v", i, got.ConversationHistory[i], turn)
+			}
+		}
+		if mockChatRepo.createCalled {
+			t.Error("Expected nothing to be persisted for an ephemeral chat")
+		}
+	})
+
+	tooManyTurns := make([]models.ChatTurn, maxEphemeralHistory+1)
+	for i := range tooManyTurns {
+		tooManyTurns[i] = models.ChatTurn{Sender: "user", Message: "Hi"}
+	}
+
+	invalid := []struct {
+		name string
+		req  models.ChatRequest
+	}{
+		{"With identification_id", models.ChatRequest{IdentificationID: "7c9e6679-7425-40de-944b-e07fc1f90ae7", Message: "Hi", Ephemeral: true}},
+		{"Unknown sender", models.ChatRequest{Message: "Hi", Ephemeral: true, History: []models.ChatTurn{{Sender: "system", Message: "x"}}}},
+		{"Empty history message", models.ChatRequest{Message: "Hi", Ephemeral: true, History: []models.ChatTurn{{Sender: "user"}}}},
+		{"Too many history turns", models.ChatRequest{Message: "Hi", Ephemeral: true, History: tooManyTurns}},
+		{"Oversized history turn", models.ChatRequest{Message: "Hi", Ephemeral: true, History: []models.ChatTurn{{Sender: "llm", Message: strings.Repeat("a", 101)}}}},
+	}
+	for _, tt := range invalid {
+		t.Run(tt.name, func(t *testing.T) {
+			mockChatSvc := &mockChatService{}
+			handler := NewChatHandler(mockChatSvc, &mockIdentificationRepository{}, &mockChatRepository{})
+			handler.SetMaxUserMessageChars(100)
+
+			body, _ := json.Marshal(tt.req)
+			rr := httptest.NewRecorder()
+			handler.Handle(rr, httptest.NewRequest(http.MethodPost, "/chat", bytes.NewBuffer(body)))
+
+			if rr.Code != http.StatusBadRequest {
+				t.Errorf("Expected status %d, got %d", http.StatusBadRequest, rr.Code)
+			}
+			if mockChatSvc.lastChatRequest != nil {
+				t.Error("Expected chat service not to be called")
+			}
+		})
+	}
+}
+
+func TestChatHandlerLogsCorrelation(t *testing.T) {
+	mockChatSvc := &mockChatService{
+		response: &services.ChatResponse{
+			Message: "Water every two weeks.",
+			Usage:   services.TokenUsage{PromptTokens: 120, CompletionTokens: 30, TotalTokens: 150},
+		},
+	}
+	handler := NewChatHandler(
+		mockChatSvc,
+		&mockIdentificationRepository{getByIDResult: &db.Identification{ID: "7c9e6679-7425-40de-944b-e07fc1f90ae7", Genus: "Aloe"}},
+		&mockChatRepository{},
+	)
+
+	var logs bytes.Buffer
+	handler.SetLogger(slog.New(slog.NewJSONHandler(&logs, nil)))
+
+	body, _ := json.Marshal(models.ChatRequest{IdentificationID: "7c9e6679-7425-40de-944b-e07fc1f90ae7", Message: "How often?"})
+	req := httptest.NewRequest(http.MethodPost, "/chat", bytes.NewBuffer(body))
+	req.Header.Set(utils.RequestIDHeader, "req-123")
+	rr := httptest.NewRecorder()
+
+	// Run through the logging middleware so the request ID reaches the handler
+	utils.LoggingMiddleware(http.HandlerFunc(handler.Handle)).ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, rr.Code)
+	}
+
+	var entry map[string]interface{}
+	if err := json.Unmarshal(logs.Bytes(), &entry); err != nil {
+		t.Fatalf("Expected one JSON log entry, got %q: %v", logs.String(), err)
+	}
+
+	expected := map[string]interface{}{
+		"msg":               "chat request completed",
+		"request_id":        "req-123",
+		"identification_id": "7c9e6679-7425-40de-944b-e07fc1f90ae7",
+		"message_length":    float64(len("How often?")),
+		"total_tokens":      float64(150),
+	}
+	for key, value := range expected {
+		if entry[key] != value {
+			t.Errorf("Log field %s = %v, expected %v", key, entry[key], value)
+		}
+	}
+	if _, ok := entry["duration_ms"]; !ok {
+		t.Error("Expected duration_ms in log entry")
+	}
+}
+
 func TestChatHandlerIntegration(t *testing.T) {
 	tests := []struct {
 		name              string
@@ -277,7 +719,7 @@ func TestChatHandlerIntegration(t *testing.T) {
 		{
 			name: "Full chat flow with plant context",
 			identification: &db.Identification{
-				ID:         "plant-123",
+				ID:         "9a3f5b2c-7d1e-4f8a-b6c4-2e0d9f1a8b73",
 				Genus:      "Echeveria",
 				Species:    "elegans",
 				Confidence: 0.98,
@@ -352,3 +794,297 @@ func TestChatHandlerIntegration(t *testing.T) {
 		})
 	}
 }
+
+func TestChatHandlerHandleCompare(t *testing.T) {
+	identification := &db.Identification{ID: "7c9e6679-7425-40de-944b-e07fc1f90ae7", Genus: "Haworthia", Species: "zebrina", Confidence: 0.95}
+
+	tests := []struct {
+		name              string
+		method            string
+		requestBody       interface{}
+		identificationErr error
+		expectedStatus    int
+		expectedReplies   []models.ModelReply
+	}{
+		{
+			name:   "Replies from each model in request order",
+			method: http.MethodPost,
+			requestBody: models.ChatCompareRequest{
+				IdentificationID: "7c9e6679-7425-40de-944b-e07fc1f90ae7",
+				Message:          "How often should I water it?",
+				Models:           []string{"gpt-4o", "gpt-4o-mini", "gpt-4o"},
+			},
+			expectedStatus: http.StatusOK,
+			expectedReplies: []models.ModelReply{
+				{Model: "gpt-4o", Message: "Water every two weeks."},
+				{Model: "gpt-4o-mini", Message: "Water when the soil is dry."},
+			},
+		},
+		{
+			name:   "Failing model reported without failing the request",
+			method: http.MethodPost,
+			requestBody: models.ChatCompareRequest{
+				IdentificationID: "7c9e6679-7425-40de-944b-e07fc1f90ae7",
+				Message:          "How often should I water it?",
+				Models:           []string{"gpt-4o-mini", "o1-mini"},
+			},
+			expectedStatus: http.StatusOK,
+			expectedReplies: []models.ModelReply{
+				{Model: "gpt-4o-mini", Message: "Water when the soil is dry."},
+				{Model: "o1-mini", Error: "Failed to get response from assistant"},
+			},
+		},
+		{
+			name:   "Model not in the allowlist",
+			method: http.MethodPost,
+			requestBody: models.ChatCompareRequest{
+				IdentificationID: "7c9e6679-7425-40de-944b-e07fc1f90ae7",
+				Message:          "How often should I water it?",
+				Models:           []string{"gpt-4o-mini", "gpt-4-32k"},
+			},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "Method not allowed",
+			method:         http.MethodGet,
+			expectedStatus: http.StatusMethodNotAllowed,
+		},
+		{
+			name:   "Missing models",
+			method: http.MethodPost,
+			requestBody: models.ChatCompareRequest{
+				IdentificationID: "7c9e6679-7425-40de-944b-e07fc1f90ae7",
+				Message:          "Hello",
+				Models:           []string{" "},
+			},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:   "Too many models",
+			method: http.MethodPost,
+			requestBody: models.ChatCompareRequest{
+				IdentificationID: "7c9e6679-7425-40de-944b-e07fc1f90ae7",
+				Message:          "Hello",
+				Models:           []string{"a", "b", "c", "d", "e"},
+			},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:   "Missing message",
+			method: http.MethodPost,
+			requestBody: models.ChatCompareRequest{
+				IdentificationID: "7c9e6679-7425-40de-944b-e07fc1f90ae7",
+				Models:           []string{"gpt-4o"},
+			},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:   "Malformed identification ID",
+			method: http.MethodPost,
+			requestBody: models.ChatCompareRequest{
+				IdentificationID: "plant-id-1",
+				Message:          "Hello",
+				Models:           []string{"gpt-4o"},
+			},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:   "Identification not found",
+			method: http.MethodPost,
+			requestBody: models.ChatCompareRequest{
+				IdentificationID: "00000000-0000-4000-8000-000000000000",
+				Message:          "Hello",
+				Models:           []string{"gpt-4o"},
+			},
+			identificationErr: db.ErrNotFound,
+			expectedStatus:    http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chatRepo := &mockChatRepository{}
+			chatService := &mockChatService{modelReplies: map[string]string{
+				"gpt-4o":      "Water every two weeks.",
+				"gpt-4o-mini": "Water when the soil is dry.",
+			}}
+			handler := NewChatHandler(
+				chatService,
+				&mockIdentificationRepository{getByIDResult: identification, getByIDErr: tt.identificationErr},
+				chatRepo,
+			)
+			handler.SetCompareModels([]string{"gpt-4o", "gpt-4o-mini", "o1-mini", "a", "b", "c", "d", "e"})
+
+			var body bytes.Buffer
+			if tt.requestBody != nil {
+				json.NewEncoder(&body).Encode(tt.requestBody)
+			}
+			rr := httptest.NewRecorder()
+			handler.HandleCompare(rr, httptest.NewRequest(tt.method, "/chat/compare", &body))
+
+			if rr.Code != tt.expectedStatus {
+				t.Fatalf("Handler returned wrong status code: got %v, expected %v: %s", rr.Code, tt.expectedStatus, rr.Body.String())
+			}
+			if tt.expectedStatus != http.StatusOK {
+				if chatService.lastChatRequest != nil {
+					t.Error("Expected no model to be queried for a rejected comparison")
+				}
+				return
+			}
+
+			var response models.ChatCompareResponse
+			if err := json.NewDecoder(rr.Body).Decode(&response); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+			if len(response.Replies) != len(tt.expectedReplies) {
+				t.Fatalf("Expected %d replies, got %+v", len(tt.expectedReplies), response.Replies)
+			}
+			for i, expected := range tt.expectedReplies {
+				if response.Replies[i] != expected {
+					t.Errorf("Reply %d = %+v, expected %+v", i, response.Replies[i], expected)
+				}
+			}
+
+			// Comparisons are not persisted
+			if chatRepo.createCalled {
+				t.Error("Expected no chat messages to be saved")
+			}
+		})
+	}
+}
+
+func TestChatHandlerHandleEditMessage(t *testing.T) {
+	const identificationID = "7c9e6679-7425-40de-944b-e07fc1f90ae7"
+	sentAt := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	conversation := []db.ChatMessage{
+		{ID: "0b8f7c1e-2d3a-4b5c-8d9e-1f2a3b4c5d6e", IdentificationID: identificationID, Message: "Is it toxic?", Sender: "user", CreatedAt: sentAt},
+		{ID: "1c9a8d2f-3e4b-4c6d-9eaf-2a3b4c5d6e7f", IdentificationID: identificationID, Message: "No.", Sender: "llm", CreatedAt: sentAt.Add(time.Second)},
+		{ID: "2dab9e3a-4f5c-4d7e-8fb0-3b4c5d6e7f80", IdentificationID: identificationID, Message: "How often to water?", Sender: "user", CreatedAt: sentAt.Add(time.Minute)},
+		{ID: "3ebcaf4b-5a6d-4e8f-9ac1-4c5d6e7f8091", IdentificationID: identificationID, Message: "Every week.", Sender: "llm", CreatedAt: sentAt.Add(time.Minute + time.Second)},
+	}
+	edited := conversation[2]
+
+	t.Run("Editing a user message regenerates its reply", func(t *testing.T) {
+		chatRepo := &mockChatRepository{getByIDResult: &edited, getAllResult: conversation}
+		chatService := &mockChatService{response: &services.ChatResponse{Message: "Every two to three weeks.", Model: "gpt-4o"}}
+		identRepo := &mockIdentificationRepository{getByIDResult: &db.Identification{ID: identificationID, Genus: "haworthia"}}
+		handler := NewChatHandler(chatService, identRepo, chatRepo)
+
+		body, _ := json.Marshal(models.ChatEditRequest{Message: "How often should I water in winter?"})
+		rr := httptest.NewRecorder()
+		handler.HandleEditMessage(rr, httptest.NewRequest(http.MethodPut, "/chat/message/"+edited.ID, bytes.NewReader(body)))
+
+		if rr.Code != http.StatusOK {
+			t.Fatalf("Expected status 200, got %d: %s", rr.Code, rr.Body.String())
+		}
+		var response models.ChatResponse
+		if err := json.NewDecoder(rr.Body).Decode(&response); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		if response.Message != "Every two to three weeks." {
+			t.Errorf("Expected regenerated reply, got %q", response.Message)
+		}
+
+		if chatRepo.updatedID != edited.ID || chatRepo.updatedMessage != "How often should I water in winter?" {
+			t.Errorf("Expected message %s updated, got %s %q", edited.ID, chatRepo.updatedID, chatRepo.updatedMessage)
+		}
+		if len(chatRepo.deletedIDs) != 1 || chatRepo.deletedIDs[0] != conversation[3].ID {
+			t.Errorf("Expected previous reply deleted, got %v", chatRepo.deletedIDs)
+		}
+
+		req := chatService.lastChatRequest
+		if req.UserMessage != "How often should I water in winter?" || len(req.ConversationHistory) != 2 || req.Identification == nil {
+			t.Errorf("Expected corrected message with the preceding history, got %+v", req)
+		}
+
+		saved := chatRepo.lastCreated
+		if saved == nil || saved.Sender != "llm" || saved.Model != "gpt-4o" || !saved.CreatedAt.Equal(conversation[3].CreatedAt) {
+			t.Errorf("Expected new reply saved in place of the old one, got %+v", saved)
+		}
+	})
+
+	t.Run("Failed regeneration keeps the original message and reply", func(t *testing.T) {
+		chatRepo := &mockChatRepository{getByIDResult: &edited, getAllResult: conversation}
+		chatService := &mockChatService{err: errors.New("LLM unavailable")}
+		identRepo := &mockIdentificationRepository{getByIDResult: &db.Identification{ID: identificationID, Genus: "haworthia"}}
+		handler := NewChatHandler(chatService, identRepo, chatRepo)
+
+		body, _ := json.Marshal(models.ChatEditRequest{Message: "How often should I water in winter?"})
+		rr := httptest.NewRecorder()
+		handler.HandleEditMessage(rr, httptest.NewRequest(http.MethodPut, "/chat/message/"+edited.ID, bytes.NewReader(body)))
+
+		if rr.Code != http.StatusInternalServerError {
+			t.Fatalf("Expected status 500, got %d: %s", rr.Code, rr.Body.String())
+		}
+		if chatRepo.updatedID != "" || len(chatRepo.deletedIDs) != 0 || chatRepo.createCalled {
+			t.Errorf("Expected no changes when the reply fails, updated %q deleted %v", chatRepo.updatedID, chatRepo.deletedIDs)
+		}
+	})
+
+	t.Run("LLM messages cannot be edited", func(t *testing.T) {
+		reply := conversation[3]
+		chatRepo := &mockChatRepository{getByIDResult: &reply, getAllResult: conversation}
+		chatService := &mockChatService{response: &services.ChatResponse{Message: "Edited"}}
+		handler := NewChatHandler(chatService, &mockIdentificationRepository{}, chatRepo)
+
+		body, _ := json.Marshal(models.ChatEditRequest{Message: "Every day."})
+		rr := httptest.NewRecorder()
+		handler.HandleEditMessage(rr, httptest.NewRequest(http.MethodPut, "/chat/message/"+reply.ID, bytes.NewReader(body)))
+
+		if rr.Code != http.StatusForbidden {
+			t.Fatalf("Expected status 403, got %d: %s", rr.Code, rr.Body.String())
+		}
+		if chatRepo.updatedID != "" || len(chatRepo.deletedIDs) != 0 || chatService.lastChatRequest != nil {
+			t.Error("Expected no changes for a rejected edit")
+		}
+	})
+
+	t.Run("General advice messages cannot be edited", func(t *testing.T) {
+		general := db.ChatMessage{ID: edited.ID, Message: "Which succulents suit shade?", Sender: "user", CreatedAt: sentAt}
+		chatRepo := &mockChatRepository{getByIDResult: &general}
+		chatService := &mockChatService{response: &services.ChatResponse{Message: "Edited"}}
+		handler := NewChatHandler(chatService, &mockIdentificationRepository{}, chatRepo)
+		handler.SetAllowContextless(true)
+
+		body, _ := json.Marshal(models.ChatEditRequest{Message: "Which succulents suit full shade?"})
+		rr := httptest.NewRecorder()
+		handler.HandleEditMessage(rr, httptest.NewRequest(http.MethodPut, "/chat/message/"+general.ID, bytes.NewReader(body)))
+
+		if rr.Code != http.StatusForbidden {
+			t.Fatalf("Expected status 403, got %d: %s", rr.Code, rr.Body.String())
+		}
+		if chatRepo.updatedID != "" || chatRepo.getAllCalled || chatService.lastChatRequest != nil {
+			t.Error("Expected no changes for a rejected edit")
+		}
+	})
+
+	t.Run("Validation", func(t *testing.T) {
+		tests := []struct {
+			name           string
+			method         string
+			path           string
+			body           string
+			getByIDErr     error
+			expectedStatus int
+		}{
+			{name: "Wrong method", method: http.MethodPost, path: "/chat/message/" + edited.ID, body: `{"message":"Hi"}`, expectedStatus: http.StatusMethodNotAllowed},
+			{name: "Malformed ID", method: http.MethodPut, path: "/chat/message/not-a-uuid", body: `{"message":"Hi"}`, expectedStatus: http.StatusBadRequest},
+			{name: "Empty message", method: http.MethodPut, path: "/chat/message/" + edited.ID, body: `{"message":""}`, expectedStatus: http.StatusBadRequest},
+			{name: "Unknown message", method: http.MethodPut, path: "/chat/message/" + edited.ID, body: `{"message":"Hi"}`, getByIDErr: db.ErrNotFound, expectedStatus: http.StatusNotFound},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				chatRepo := &mockChatRepository{getByIDErr: tt.getByIDErr}
+				handler := NewChatHandler(&mockChatService{}, &mockIdentificationRepository{}, chatRepo)
+
+				rr := httptest.NewRecorder()
+				handler.HandleEditMessage(rr, httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body)))
+
+				if rr.Code != tt.expectedStatus {
+					t.Errorf("Expected status %d, got %d: %s", tt.expectedStatus, rr.Code, rr.Body.String())
+				}
+			})
+		}
+	})
+}
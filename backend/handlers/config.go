@@ -0,0 +1,59 @@
+package handlers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+
+	"succulent-identifier-backend/models"
+	"succulent-identifier-backend/utils"
+)
+
+// ConfigHandler serves the client-relevant subset of the server configuration
+type ConfigHandler struct {
+	body []byte
+	etag string
+}
+
+// NewConfigHandler creates a new config handler. The response is built once
+// from an explicit allowlist of fields so secrets can never leak into it.
+func NewConfigHandler(config *utils.Config) *ConfigHandler {
+	body, _ := json.Marshal(models.ClientConfigResponse{
+		SpeciesThreshold:  config.SpeciesThreshold,
+		AllowedExtensions: config.AllowedExtensions,
+		MaxFileSize:       config.MaxFileSize,
+		MaxBatchImages:    maxBatchImages,
+	})
+	sum := sha256.Sum256(body)
+
+	return &ConfigHandler{
+		body: body,
+		etag: `"` + hex.EncodeToString(sum[:8]) + `"`,
+	}
+}
+
+// Handle returns the client config; it only changes on restart, so clients may cache it
+func (h *ConfigHandler) Handle(w http.ResponseWriter, r *http.Request) {
+	// Only accept GET requests
+	if r.Method != http.MethodGet {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		json.NewEncoder(w).Encode(models.ErrorResponse{
+			Error:   http.StatusText(http.StatusMethodNotAllowed),
+			Message: "Method not allowed",
+		})
+		return
+	}
+
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	w.Header().Set("ETag", h.etag)
+	if r.Header.Get("If-None-Match") == h.etag {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(h.body)
+}
@@ -0,0 +1,62 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"succulent-identifier-backend/models"
+	"succulent-identifier-backend/utils"
+)
+
+func TestConfigHandlerHandle(t *testing.T) {
+	config := &utils.Config{
+		SpeciesThreshold:  0.4,
+		AllowedExtensions: []string{".jpg", ".jpeg", ".png"},
+		MaxFileSize:       5242880,
+		OpenAIAPIKey:      "sk-super-secret-key",
+		ShareSecret:       "share-signing-secret",
+	}
+	handler := NewConfigHandler(config)
+
+	rr := httptest.NewRecorder()
+	handler.Handle(rr, httptest.NewRequest(http.MethodGet, "/config", nil))
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Handler returned wrong status code: got %v, expected %v", rr.Code, http.StatusOK)
+	}
+
+	body := rr.Body.String()
+	for _, secret := range []string{config.OpenAIAPIKey, config.ShareSecret} {
+		if strings.Contains(body, secret) {
+			t.Errorf("Config response leaks secret %q: %s", secret, body)
+		}
+	}
+	if strings.Contains(strings.ToLower(body), "api_key") {
+		t.Errorf("Config response must not expose an API key field: %s", body)
+	}
+
+	var response models.ClientConfigResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if response.SpeciesThreshold != 0.4 || response.MaxFileSize != 5242880 || len(response.AllowedExtensions) != 3 {
+		t.Errorf("Unexpected client config: %+v", response)
+	}
+
+	if rr.Header().Get("Cache-Control") == "" || rr.Header().Get("ETag") == "" {
+		t.Error("Expected cache headers on config response")
+	}
+
+	// Revalidation with a matching ETag is answered without a body
+	req := httptest.NewRequest(http.MethodGet, "/config", nil)
+	req.Header.Set("If-None-Match", rr.Header().Get("ETag"))
+	rr = httptest.NewRecorder()
+	handler.Handle(rr, req)
+
+	if rr.Code != http.StatusNotModified || rr.Body.Len() != 0 {
+		t.Errorf("Expected 304 with empty body, got %d with %q", rr.Code, rr.Body.String())
+	}
+}
@@ -0,0 +1,31 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"succulent-identifier-backend/models"
+	"succulent-identifier-backend/utils"
+)
+
+// writeError sends a JSON error response. When the response writer records
+// errors (see utils.ErrorLogMiddleware) the error is reported to it first.
+func writeError(w http.ResponseWriter, statusCode int, message string) {
+	if recorder, ok := w.(utils.ErrorRecorder); ok {
+		recorder.RecordError(statusCode, message)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	json.NewEncoder(w).Encode(models.ErrorResponse{
+		Error:   http.StatusText(statusCode),
+		Message: message,
+	})
+}
+
+// notFound sends the JSON 404 for paths no route matches, including the
+// endpoints of disabled features, instead of http.NotFound's plain text
+func notFound(w http.ResponseWriter, r *http.Request) {
+	writeError(w, http.StatusNotFound, fmt.Sprintf("No endpoint at %s %s", r.Method, r.URL.Path))
+}
@@ -0,0 +1,258 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"runtime"
+	"sync"
+	"time"
+
+	"succulent-identifier-backend/models"
+)
+
+// Readiness statuses
+const (
+	ReadinessReady    = "ready"
+	ReadinessNotReady = "not_ready"
+)
+
+// HealthHandler handles health and readiness requests
+type HealthHandler struct {
+	careData CareDataStatusInterface
+	mlHealth MLHealthInterface       // nil when ML health is not tracked
+	database DatabasePingerInterface // nil when the database is not probed
+
+	version   string
+	startedAt time.Time
+
+	// readinessTTL is how long a readiness report is served before it is
+	// refreshed in the background (0 checks dependencies on every request)
+	readinessTTL time.Duration
+
+	readinessMu sync.Mutex
+	readiness   *models.ReadinessResponse // last report, nil before the first
+	refreshing  bool                      // a background refresh is running
+	firstCheck  chan struct{}             // closed once the first report is stored
+}
+
+// databasePingTimeout bounds the database ping of a readiness check
+var databasePingTimeout = 2 * time.Second
+
+// NewHealthHandler creates a new health handler
+func NewHealthHandler(careData CareDataStatusInterface) *HealthHandler {
+	return &HealthHandler{
+		careData:  careData,
+		version:   "dev",
+		startedAt: time.Now().UTC(),
+	}
+}
+
+// SetBuildInfo sets the version and process start time reported by /health
+func (h *HealthHandler) SetBuildInfo(version string, startedAt time.Time) {
+	h.version = version
+	h.startedAt = startedAt.UTC()
+}
+
+// HandleHealth reports liveness with version, uptime and Go runtime stats.
+// It never checks dependencies so it stays fast; those belong in readiness.
+func (h *HealthHandler) HandleHealth(w http.ResponseWriter, r *http.Request) {
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+
+	uptime := time.Since(h.startedAt)
+	response := models.HealthResponse{
+		Status:        "healthy",
+		Service:       "succulent-identifier-backend",
+		Version:       h.version,
+		StartedAt:     h.startedAt,
+		Uptime:        uptime.Round(time.Second).String(),
+		UptimeSeconds: uptime.Seconds(),
+		Runtime: models.RuntimeStats{
+			GoVersion:      runtime.Version(),
+			Goroutines:     runtime.NumGoroutine(),
+			HeapAllocBytes: mem.HeapAlloc,
+			SysBytes:       mem.Sys,
+			NumGC:          mem.NumGC,
+		},
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
+
+// HandlePing answers "pong" in plain text for load balancers that want a
+// minimal non-JSON health check
+func (h *HealthHandler) HandlePing(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	io.WriteString(w, "pong")
+}
+
+// SetMLHealth configures the ML service health source reported by readiness
+func (h *HealthHandler) SetMLHealth(mlHealth MLHealthInterface) {
+	h.mlHealth = mlHealth
+}
+
+// SetDatabase configures the database pinged by readiness
+func (h *HealthHandler) SetDatabase(database DatabasePingerInterface) {
+	h.database = database
+}
+
+// SetReadinessCacheTTL configures how long a readiness report is served
+// before its dependencies are probed again (0 probes on every request)
+func (h *HealthHandler) SetReadinessCacheTTL(ttl time.Duration) {
+	h.readinessTTL = ttl
+}
+
+// HandleReady reports whether the service is ready to serve requests.
+// A failed care data reload keeps the service ready as long as previously
+// loaded data is still being served; the failure is surfaced as "stale".
+func (h *HealthHandler) HandleReady(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		h.sendError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	response := h.readinessReport()
+
+	statusCode := http.StatusOK
+	if response.Status != ReadinessReady {
+		statusCode = http.StatusServiceUnavailable
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	json.NewEncoder(w).Encode(response)
+}
+
+// readinessReport returns the cached readiness report. Once it is older than
+// the TTL it keeps being served while a single background refresh replaces
+// it, so load balancer probes never wait for or pile up on dependencies.
+func (h *HealthHandler) readinessReport() models.ReadinessResponse {
+	if h.readinessTTL <= 0 {
+		return h.checkReadiness()
+	}
+
+	h.readinessMu.Lock()
+	if h.readiness == nil {
+		// The first check runs without the lock held; concurrent first
+		// requests wait for it instead of probing the dependencies themselves
+		first := h.firstCheck
+		if first == nil {
+			first = make(chan struct{})
+			h.firstCheck = first
+			h.readinessMu.Unlock()
+			h.refreshReadiness()
+			close(first)
+		} else {
+			h.readinessMu.Unlock()
+			<-first
+		}
+		h.readinessMu.Lock()
+	}
+	defer h.readinessMu.Unlock()
+
+	if time.Since(h.readiness.CheckedAt) >= h.readinessTTL && !h.refreshing {
+		h.refreshing = true
+		go h.refreshReadiness()
+	}
+	return *h.readiness
+}
+
+// refreshReadiness replaces the cached readiness report with a fresh one
+func (h *HealthHandler) refreshReadiness() {
+	report := h.checkReadiness()
+
+	h.readinessMu.Lock()
+	defer h.readinessMu.Unlock()
+	h.readiness = &report
+	h.refreshing = false
+}
+
+// checkReadiness probes the dependencies and builds a readiness report
+func (h *HealthHandler) checkReadiness() models.ReadinessResponse {
+	response := models.ReadinessResponse{
+		Status:    ReadinessReady,
+		CheckedAt: time.Now().UTC(),
+		CareData:  h.careDataHealth(),
+		MLService: h.mlServiceHealth(),
+		Database:  h.databaseHealth(),
+	}
+	if response.CareData.Status == "unavailable" {
+		response.Status = ReadinessNotReady
+	}
+	if response.MLService != nil && response.MLService.Status == "unavailable" {
+		response.Status = ReadinessNotReady
+	}
+	if response.Database != nil && response.Database.Status == "unavailable" {
+		response.Status = ReadinessNotReady
+	}
+	return response
+}
+
+// careDataHealth summarizes the care data load state
+func (h *HealthHandler) careDataHealth() models.CareDataHealth {
+	if h.careData == nil {
+		return models.CareDataHealth{Status: "unavailable"}
+	}
+
+	status := h.careData.Status()
+	health := models.CareDataHealth{
+		Status:     "ok",
+		Entries:    status.Entries,
+		LastLoaded: status.LoadedAt,
+	}
+
+	if status.LastError != nil {
+		health.LastError = status.LastError.Error()
+		health.Status = "stale"
+	}
+	if status.Entries == 0 {
+		health.Status = "unavailable"
+	}
+
+	return health
+}
+
+// mlServiceHealth summarizes the last ML service health check, or nil when it is not tracked
+func (h *HealthHandler) mlServiceHealth() *models.MLServiceHealth {
+	if h.mlHealth == nil {
+		return nil
+	}
+
+	status := h.mlHealth.Status()
+	health := &models.MLServiceHealth{
+		Status:      "ok",
+		LastChecked: status.CheckedAt,
+	}
+	if !status.Healthy {
+		health.Status = "unavailable"
+	}
+	if status.LastError != nil {
+		health.LastError = status.LastError.Error()
+	}
+
+	return health
+}
+
+// databaseHealth pings the database, or returns nil when it is not probed
+func (h *HealthHandler) databaseHealth() *models.DatabaseHealth {
+	if h.database == nil {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), databasePingTimeout)
+	defer cancel()
+	if err := h.database.PingContext(ctx); err != nil {
+		return &models.DatabaseHealth{Status: "unavailable", LastError: err.Error()}
+	}
+	return &models.DatabaseHealth{Status: "ok"}
+}
+
+// sendError sends an error response
+func (h *HealthHandler) sendError(w http.ResponseWriter, statusCode int, message string) {
+	writeError(w, statusCode, message)
+}
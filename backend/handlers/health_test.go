@@ -0,0 +1,343 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"succulent-identifier-backend/models"
+	"succulent-identifier-backend/services"
+)
+
+func TestHealthHandlerHandleReady(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "care_data.json")
+	content, err := os.ReadFile("../testdata/care_data_test.json")
+	if err != nil {
+		t.Fatalf("Failed to read fixture: %v", err)
+	}
+	os.WriteFile(path, content, 0644)
+
+	careData, err := services.NewCareDataService(path)
+	if err != nil {
+		t.Fatalf("Failed to create care data service: %v", err)
+	}
+	handler := NewHealthHandler(careData)
+
+	ready := func() (int, models.ReadinessResponse) {
+		req := httptest.NewRequest(http.MethodGet, "/ready", nil)
+		w := httptest.NewRecorder()
+		handler.HandleReady(w, req)
+
+		var response models.ReadinessResponse
+		if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		return w.Code, response
+	}
+
+	code, response := ready()
+	if code != http.StatusOK || response.Status != ReadinessReady {
+		t.Fatalf("Expected ready 200, got %d %+v", code, response)
+	}
+	if response.CareData.Status != "ok" || response.CareData.Entries != 2 || response.CareData.LastError != "" {
+		t.Errorf("Unexpected care data health: %+v", response.CareData)
+	}
+	loadedAt := response.CareData.LastLoaded
+
+	// Simulate a bad hot-reload: previous data must keep being served
+	os.WriteFile(path, []byte("{broken"), 0644)
+	if err := careData.Reload(); err == nil {
+		t.Fatal("Expected reload of malformed file to fail")
+	}
+
+	code, response = ready()
+	if code != http.StatusOK || response.Status != ReadinessReady {
+		t.Errorf("Expected stale care data to stay ready, got %d %+v", code, response)
+	}
+	if response.CareData.Status != "stale" {
+		t.Errorf("Expected care data status stale, got %s", response.CareData.Status)
+	}
+	if response.CareData.Entries != 2 {
+		t.Errorf("Expected stale entry count 2, got %d", response.CareData.Entries)
+	}
+	if response.CareData.LastError == "" {
+		t.Error("Expected last error to be reported")
+	}
+	if !response.CareData.LastLoaded.Equal(loadedAt) {
+		t.Errorf("Expected last load time to be unchanged, got %v", response.CareData.LastLoaded)
+	}
+}
+
+func TestHealthHandlerHandleReadyWithoutCareData(t *testing.T) {
+	handler := NewHealthHandler(nil)
+
+	req := httptest.NewRequest(http.MethodGet, "/ready", nil)
+	w := httptest.NewRecorder()
+	handler.HandleReady(w, req)
+
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected status 503, got %d", w.Code)
+	}
+}
+
+func TestHealthHandlerHandleReadyMLService(t *testing.T) {
+	careData, err := services.NewCareDataService("../testdata/care_data_test.json")
+	if err != nil {
+		t.Fatalf("Failed to create care data service: %v", err)
+	}
+	checkedAt := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name           string
+		status         services.MLHealthStatus
+		expectedCode   int
+		expectedStatus string
+		expectedML     string
+	}{
+		{
+			name:           "ML service healthy",
+			status:         services.MLHealthStatus{Healthy: true, CheckedAt: checkedAt},
+			expectedCode:   http.StatusOK,
+			expectedStatus: ReadinessReady,
+			expectedML:     "ok",
+		},
+		{
+			name:           "ML service down",
+			status:         services.MLHealthStatus{Healthy: false, CheckedAt: checkedAt, LastError: errors.New("connection refused")},
+			expectedCode:   http.StatusServiceUnavailable,
+			expectedStatus: ReadinessNotReady,
+			expectedML:     "unavailable",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := NewHealthHandler(careData)
+			handler.SetMLHealth(&mockMLHealth{status: tt.status})
+
+			req := httptest.NewRequest(http.MethodGet, "/ready", nil)
+			w := httptest.NewRecorder()
+			handler.HandleReady(w, req)
+
+			var response models.ReadinessResponse
+			if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+			if w.Code != tt.expectedCode || response.Status != tt.expectedStatus {
+				t.Errorf("Expected %d %s, got %d %s", tt.expectedCode, tt.expectedStatus, w.Code, response.Status)
+			}
+			if response.MLService == nil || response.MLService.Status != tt.expectedML {
+				t.Fatalf("Expected ml_service status %s, got %+v", tt.expectedML, response.MLService)
+			}
+			if !response.MLService.LastChecked.Equal(checkedAt) {
+				t.Errorf("Expected last checked %v, got %v", checkedAt, response.MLService.LastChecked)
+			}
+			if (response.MLService.LastError != "") != (tt.status.LastError != nil) {
+				t.Errorf("Unexpected last error %q", response.MLService.LastError)
+			}
+		})
+	}
+}
+
+// mockDatabasePinger counts pings and answers them with err, after delay
+// unless the ping times out first
+type mockDatabasePinger struct {
+	pings atomic.Int32
+	err   error
+	delay time.Duration
+}
+
+func (m *mockDatabasePinger) PingContext(ctx context.Context) error {
+	m.pings.Add(1)
+	select {
+	case <-time.After(m.delay):
+		return m.err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func TestHealthHandlerHandleReadyDatabase(t *testing.T) {
+	careData, err := services.NewCareDataService("../testdata/care_data_test.json")
+	if err != nil {
+		t.Fatalf("Failed to create care data service: %v", err)
+	}
+
+	tests := []struct {
+		name           string
+		pingErr        error
+		pingDelay      time.Duration
+		expectedCode   int
+		expectedStatus string
+	}{
+		{"Database reachable", nil, 0, http.StatusOK, "ok"},
+		{"Database down", errors.New("connection refused"), 0, http.StatusServiceUnavailable, "unavailable"},
+		{"Database ping times out", nil, time.Minute, http.StatusServiceUnavailable, "unavailable"},
+	}
+
+	originalTimeout := databasePingTimeout
+	databasePingTimeout = 10 * time.Millisecond
+	defer func() { databasePingTimeout = originalTimeout }()
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := NewHealthHandler(careData)
+			handler.SetDatabase(&mockDatabasePinger{err: tt.pingErr, delay: tt.pingDelay})
+
+			w := httptest.NewRecorder()
+			handler.HandleReady(w, httptest.NewRequest(http.MethodGet, "/ready", nil))
+
+			var response models.ReadinessResponse
+			json.NewDecoder(w.Body).Decode(&response)
+			if w.Code != tt.expectedCode || response.Database == nil || response.Database.Status != tt.expectedStatus {
+				t.Errorf("Expected %d with database %s, got %d %+v", tt.expectedCode, tt.expectedStatus, w.Code, response.Database)
+			}
+		})
+	}
+}
+
+func TestHealthHandlerHandleReadyCache(t *testing.T) {
+	careData, err := services.NewCareDataService("../testdata/care_data_test.json")
+	if err != nil {
+		t.Fatalf("Failed to create care data service: %v", err)
+	}
+	ready := func(handler *HealthHandler) {
+		w := httptest.NewRecorder()
+		handler.HandleReady(w, httptest.NewRequest(http.MethodGet, "/ready", nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected status 200, got %d", w.Code)
+		}
+	}
+
+	t.Run("Probes once within the TTL", func(t *testing.T) {
+		database := &mockDatabasePinger{}
+		handler := NewHealthHandler(careData)
+		handler.SetDatabase(database)
+		handler.SetReadinessCacheTTL(time.Minute)
+
+		for i := 0; i < 5; i++ {
+			ready(handler)
+		}
+		if pings := database.pings.Load(); pings != 1 {
+			t.Errorf("Expected 1 database ping, got %d", pings)
+		}
+	})
+
+	t.Run("Concurrent first requests probe once", func(t *testing.T) {
+		database := &mockDatabasePinger{delay: 20 * time.Millisecond}
+		handler := NewHealthHandler(careData)
+		handler.SetDatabase(database)
+		handler.SetReadinessCacheTTL(time.Minute)
+
+		var wg sync.WaitGroup
+		for i := 0; i < 5; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				w := httptest.NewRecorder()
+				handler.HandleReady(w, httptest.NewRequest(http.MethodGet, "/ready", nil))
+				if w.Code != http.StatusOK {
+					t.Errorf("Expected status 200, got %d", w.Code)
+				}
+			}()
+		}
+		wg.Wait()
+
+		if pings := database.pings.Load(); pings != 1 {
+			t.Errorf("Expected 1 database ping, got %d", pings)
+		}
+	})
+
+	t.Run("Refreshes in the background after the TTL", func(t *testing.T) {
+		database := &mockDatabasePinger{}
+		handler := NewHealthHandler(careData)
+		handler.SetDatabase(database)
+		handler.SetReadinessCacheTTL(time.Millisecond)
+
+		ready(handler)
+		time.Sleep(5 * time.Millisecond)
+		ready(handler)
+
+		deadline := time.Now().Add(time.Second)
+		for database.pings.Load() < 2 && time.Now().Before(deadline) {
+			time.Sleep(time.Millisecond)
+		}
+		if pings := database.pings.Load(); pings != 2 {
+			t.Errorf("Expected 2 database pings, got %d", pings)
+		}
+	})
+
+	t.Run("Caching disabled", func(t *testing.T) {
+		database := &mockDatabasePinger{}
+		handler := NewHealthHandler(careData)
+		handler.SetDatabase(database)
+
+		for i := 0; i < 3; i++ {
+			ready(handler)
+		}
+		if pings := database.pings.Load(); pings != 3 {
+			t.Errorf("Expected 3 database pings, got %d", pings)
+		}
+	})
+}
+
+func TestHealthHandlerHandlePing(t *testing.T) {
+	handler := NewHealthHandler(nil)
+
+	rr := httptest.NewRecorder()
+	handler.HandlePing(rr, httptest.NewRequest(http.MethodGet, "/ping", nil))
+
+	if rr.Code != http.StatusOK {
+		t.Errorf("Expected status 200, got %d", rr.Code)
+	}
+	if contentType := rr.Header().Get("Content-Type"); contentType != "text/plain; charset=utf-8" {
+		t.Errorf("Expected text/plain content type, got %q", contentType)
+	}
+	if rr.Body.String() != "pong" {
+		t.Errorf("Expected body %q, got %q", "pong", rr.Body.String())
+	}
+}
+
+func TestHealthHandlerHandleHealth(t *testing.T) {
+	handler := NewHealthHandler(nil)
+	handler.SetBuildInfo("1.2.3", time.Now().Add(-time.Minute))
+
+	health := func() models.HealthResponse {
+		req := httptest.NewRequest(http.MethodGet, "/health", nil)
+		w := httptest.NewRecorder()
+		handler.HandleHealth(w, req)
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected status 200, got %d", w.Code)
+		}
+		var response models.HealthResponse
+		if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		return response
+	}
+
+	first := health()
+	if first.Status != "healthy" || first.Version != "1.2.3" {
+		t.Errorf("Unexpected health response: %+v", first)
+	}
+	if first.UptimeSeconds < 60 {
+		t.Errorf("Expected uptime of at least 60s, got %f", first.UptimeSeconds)
+	}
+	if first.Runtime.Goroutines < 1 || first.Runtime.GoVersion == "" {
+		t.Errorf("Expected runtime stats, got %+v", first.Runtime)
+	}
+
+	time.Sleep(10 * time.Millisecond)
+	if second := health(); second.UptimeSeconds <= first.UptimeSeconds {
+		t.Errorf("Expected uptime to increase, got %f then %f", first.UptimeSeconds, second.UptimeSeconds)
+	}
+}
@@ -1,22 +1,50 @@
 package handlers
 
 import (
+	"encoding/csv"
 	"encoding/json"
+	"fmt"
+	"io"
 	"log"
+	"math"
 	"net/http"
+	"os"
 	"strconv"
 	"strings"
+	"time"
 
+	"github.com/google/uuid"
 	"succulent-identifier-backend/db"
 	"succulent-identifier-backend/models"
+	"succulent-identifier-backend/utils"
 )
 
 // HistoryHandler handles history-related requests
 type HistoryHandler struct {
 	identificationRepo IdentificationRepositoryInterface
 	chatRepo           ChatRepositoryInterface
+	publicBaseURL      string
+
+	// imageEndpoint makes image references point at /history/{id}/image, for
+	// when the /uploads/ file server is not registered
+	imageEndpoint bool
+
+	// exportPrecision is the number of decimals confidence is rounded to in
+	// exports (negative keeps full precision)
+	exportPrecision int
 }
 
+// defaultExportPrecision is the confidence decimals in exports when not configured
+const defaultExportPrecision = 4
+
+// maxExportPrecision is the most confidence decimals in exports; a float64
+// holds no more significant digits, and scaling by larger powers of ten
+// overflows to infinity
+const maxExportPrecision = 15
+
+// careSnippetLength is the max length in characters of a history item's care snippet
+const careSnippetLength = 120
+
 // NewHistoryHandler creates a new history handler
 func NewHistoryHandler(
 	identificationRepo IdentificationRepositoryInterface,
@@ -25,7 +53,47 @@ func NewHistoryHandler(
 	return &HistoryHandler{
 		identificationRepo: identificationRepo,
 		chatRepo:           chatRepo,
+		exportPrecision:    defaultExportPrecision,
+	}
+}
+
+// PublicBaseURLFromRequest makes image URLs absolute using the scheme and host of each request
+const PublicBaseURLFromRequest = "auto"
+
+// SetPublicBaseURL makes history responses return absolute image URLs under
+// baseURL + "/uploads/". PublicBaseURLFromRequest derives the base from the
+// request (including trusted forwarded headers and the base path); empty keeps
+// bare filenames.
+func (h *HistoryHandler) SetPublicBaseURL(baseURL string) {
+	h.publicBaseURL = strings.TrimRight(baseURL, "/")
+}
+
+// SetPublicUploads tells the handler whether uploads are served at /uploads/.
+// When they are not, image references point at GET /history/{id}/image
+// instead, relative to the base path unless a public base URL is configured.
+func (h *HistoryHandler) SetPublicUploads(enabled bool) {
+	h.imageEndpoint = !enabled
+}
+
+// SetExportPrecision sets the number of decimals confidence is rounded to in
+// CSV and JSON exports, at most maxExportPrecision; a negative value keeps
+// full precision
+func (h *HistoryHandler) SetExportPrecision(decimals int) {
+	h.exportPrecision = min(decimals, maxExportPrecision)
+}
+
+// formatExportConfidence renders a confidence for a CSV export
+func (h *HistoryHandler) formatExportConfidence(confidence float64) string {
+	return strconv.FormatFloat(confidence, 'f', h.exportPrecision, 64)
+}
+
+// roundExportConfidence rounds a confidence for a JSON export
+func (h *HistoryHandler) roundExportConfidence(confidence float64) float64 {
+	if h.exportPrecision < 0 {
+		return confidence
 	}
+	scale := math.Pow10(h.exportPrecision)
+	return math.Round(confidence*scale) / scale
 }
 
 // HandleList returns paginated list of identifications
@@ -36,28 +104,21 @@ func (h *HistoryHandler) HandleList(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Parse query parameters
-	limitStr := r.URL.Query().Get("limit")
-	offsetStr := r.URL.Query().Get("offset")
-
-	limit := 20 // default
-	offset := 0  // default
-
-	if limitStr != "" {
-		if parsedLimit, err := strconv.Atoi(limitStr); err == nil && parsedLimit > 0 {
-			limit = parsedLimit
-			if limit > 100 {
-				limit = 100 // max limit
-			}
-		}
+	// Care is omitted from list items unless a snippet is requested
+	includeCare := r.URL.Query().Get("include_care")
+	if includeCare != "" && includeCare != "snippet" {
+		h.sendError(w, http.StatusBadRequest, "include_care must be \"snippet\"")
+		return
 	}
-
-	if offsetStr != "" {
-		if parsedOffset, err := strconv.Atoi(offsetStr); err == nil && parsedOffset >= 0 {
-			offset = parsedOffset
-		}
+	original, err := originalImageRequested(r)
+	if err != nil {
+		h.sendError(w, http.StatusBadRequest, err.Error())
+		return
 	}
 
+	// Parse query parameters
+	limit, offset := parsePagination(r)
+
 	// Get identifications from database
 	identifications, err := h.identificationRepo.GetAll(limit, offset)
 	if err != nil {
@@ -77,20 +138,11 @@ func (h *HistoryHandler) HandleList(w http.ResponseWriter, r *http.Request) {
 	// Convert to response format
 	items := make([]models.HistoryItem, 0, len(identifications))
 	for _, ident := range identifications {
-		// Extract filename from full path for the API response
-		imagePath := ident.ImagePath
-		if idx := strings.LastIndex(imagePath, "/"); idx != -1 {
-			imagePath = imagePath[idx+1:]
+		item := h.historyItem(r, ident, original)
+		if includeCare == "snippet" {
+			item.CareSnippet = careSnippet(ident.CareGuide)
 		}
-
-		items = append(items, models.HistoryItem{
-			ID:         ident.ID,
-			Genus:      ident.Genus,
-			Species:    ident.Species,
-			Confidence: ident.Confidence,
-			ImagePath:  imagePath,
-			CreatedAt:  ident.CreatedAt,
-		})
+		items = append(items, item)
 	}
 
 	response := models.HistoryListResponse{
@@ -105,6 +157,305 @@ func (h *HistoryHandler) HandleList(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(response)
 }
 
+// HandleGrouped returns identifications nested under their genus with a count
+// and the most recent identification per genus, paginated over genera
+func (h *HistoryHandler) HandleGrouped(w http.ResponseWriter, r *http.Request) {
+	// Only accept GET requests
+	if r.Method != http.MethodGet {
+		h.sendError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	original, err := originalImageRequested(r)
+	if err != nil {
+		h.sendError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	limit, offset, err := parseStrictPagination(r)
+	if err != nil {
+		h.sendError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	identifications, err := h.identificationRepo.GetAllByGenus(limit, offset)
+	if err != nil {
+		log.Printf("Failed to get identifications by genus: %v", err)
+		h.sendError(w, http.StatusInternalServerError, "Failed to retrieve history")
+		return
+	}
+
+	totalGenera, err := h.identificationRepo.CountGenera()
+	if err != nil {
+		log.Printf("Failed to count genera: %v", err)
+		// Continue without total count
+		totalGenera = 0
+	}
+
+	// Rows arrive ordered by genus, newest first within each genus
+	groups := []models.HistoryGenusGroup{}
+	for _, ident := range identifications {
+		item := h.historyItem(r, ident, original)
+		if len(groups) == 0 || groups[len(groups)-1].Genus != ident.Genus {
+			groups = append(groups, models.HistoryGenusGroup{Genus: ident.Genus, MostRecent: item})
+		}
+		group := &groups[len(groups)-1]
+		group.Items = append(group.Items, item)
+		group.Count++
+	}
+
+	response := models.HistoryGroupedResponse{
+		Groups:      groups,
+		TotalGenera: totalGenera,
+		Limit:       limit,
+		Offset:      offset,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
+
+// parsePagination reads ?limit= (default 20, max 100) and ?offset= (default 0),
+// falling back to the default for a value that is invalid
+func parsePagination(r *http.Request) (limit, offset int) {
+	limit = 20
+	if parsedLimit, err := strconv.Atoi(r.URL.Query().Get("limit")); err == nil && parsedLimit > 0 {
+		limit = min(parsedLimit, 100)
+	}
+	if parsedOffset, err := strconv.Atoi(r.URL.Query().Get("offset")); err == nil && parsedOffset >= 0 {
+		offset = parsedOffset
+	}
+	return limit, offset
+}
+
+// parseStrictPagination reads ?limit= and ?offset= like parsePagination, but a
+// limit that is not a positive integer or an offset that is negative, not a
+// number or out of range is an error.
+func parseStrictPagination(r *http.Request) (limit, offset int, err error) {
+	limit = 20
+	if value := r.URL.Query().Get("limit"); value != "" {
+		parsedLimit, err := strconv.Atoi(value)
+		if err != nil || parsedLimit <= 0 {
+			return 0, 0, fmt.Errorf("limit must be a positive integer")
+		}
+		limit = min(parsedLimit, 100)
+	}
+	if value := r.URL.Query().Get("offset"); value != "" {
+		parsedOffset, err := strconv.Atoi(value)
+		if err != nil || parsedOffset < 0 {
+			return 0, 0, fmt.Errorf("offset must be a non-negative integer")
+		}
+		offset = parsedOffset
+	}
+	return limit, offset, nil
+}
+
+// historyItem converts an identification to a history list item
+func (h *HistoryHandler) historyItem(r *http.Request, ident db.Identification, original bool) models.HistoryItem {
+	imagePath, originalPath := h.historyImages(r, ident, original)
+	return models.HistoryItem{
+		ID:         ident.ID,
+		Genus:      ident.Genus,
+		Species:    ident.Species,
+		Confidence: ident.Confidence,
+		ImagePath:  imagePath,
+		CreatedAt:  ident.CreatedAt,
+
+		OriginalImagePath: originalPath,
+	}
+}
+
+// exportFlushInterval is how many rows are written between flushes during export
+const exportFlushInterval = 100
+
+// careExportFields is the canonical order of care fields in every export,
+// difficulty included. It matches the field order of db.CareGuide and
+// models.CareInstructions, which is the key order their JSON encodings use;
+// the rendered summary is not a stored field and is not exported.
+var careExportFields = []string{"sunlight", "watering", "soil", "notes", "trivia", "difficulty"}
+
+// csvExportHeader lists the columns of the CSV history export
+var csvExportHeader = append([]string{
+	"id", "genus", "species", "confidence", "image_path", "created_at",
+}, careExportFields...)
+
+// careExportValues returns the care fields of a guide in careExportFields order,
+// empty strings when there is no care
+func careExportValues(care *db.CareGuide) []string {
+	if care == nil {
+		return make([]string, len(careExportFields))
+	}
+	return []string{care.Sunlight, care.Watering, care.Soil, care.Notes, care.Trivia, care.Difficulty}
+}
+
+// HandleExport streams the full identification history as JSON (default) or CSV.
+// Rows are written as they are read from the database and flushed periodically,
+// so memory use does not grow with the size of the history.
+func (h *HistoryHandler) HandleExport(w http.ResponseWriter, r *http.Request) {
+	// Only accept GET requests
+	if r.Method != http.MethodGet {
+		h.sendError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = "json"
+	}
+	if format != "json" && format != "csv" {
+		h.sendError(w, http.StatusBadRequest, "format must be json or csv")
+		return
+	}
+
+	flusher, _ := w.(http.Flusher)
+	rowsWritten := 0
+	afterRow := func() {
+		rowsWritten++
+		if flusher != nil && rowsWritten%exportFlushInterval == 0 {
+			flusher.Flush()
+		}
+	}
+
+	var err error
+	if format == "csv" {
+		w.Header().Set("Content-Type", "text/csv")
+		w.Header().Set("Content-Disposition", `attachment; filename="history.csv"`)
+		w.WriteHeader(http.StatusOK)
+
+		writer := csv.NewWriter(w)
+		writer.Write(csvExportHeader)
+		err = h.identificationRepo.GetAllStream(func(ident db.Identification) error {
+			writer.Write(append([]string{
+				ident.ID,
+				ident.Genus,
+				ident.Species,
+				h.formatExportConfidence(ident.Confidence),
+				imageFilename(ident.ImagePath),
+				ident.CreatedAt.Format(time.RFC3339),
+			}, careExportValues(ident.CareGuide)...))
+			writer.Flush()
+			afterRow()
+			return writer.Error()
+		})
+	} else {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Content-Disposition", `attachment; filename="history.json"`)
+		w.WriteHeader(http.StatusOK)
+
+		// Write a JSON array one element at a time instead of encoding a slice
+		io.WriteString(w, "[")
+		err = h.identificationRepo.GetAllStream(func(ident db.Identification) error {
+			if rowsWritten > 0 {
+				io.WriteString(w, ",")
+			}
+			item, err := json.Marshal(models.HistoryDetailResponse{
+				ID:         ident.ID,
+				Genus:      ident.Genus,
+				Species:    ident.Species,
+				Confidence: h.roundExportConfidence(ident.Confidence),
+				ImagePath:  imageFilename(ident.ImagePath),
+				CareGuide:  careInstructionsFromGuide(ident.CareGuide),
+				CreatedAt:  ident.CreatedAt,
+			})
+			if err != nil {
+				return err
+			}
+			if _, err := w.Write(item); err != nil {
+				return err
+			}
+			afterRow()
+			return nil
+		})
+		io.WriteString(w, "]")
+	}
+
+	// Headers are already sent, so a failure can only truncate the export
+	if err != nil {
+		log.Printf("History export failed after %d rows: %v", rowsWritten, err)
+	}
+}
+
+// isValidID reports whether an identification ID is a canonical UUID, so
+// malformed IDs are rejected before they reach the database
+func isValidID(id string) bool {
+	_, err := uuid.Parse(id)
+	return err == nil && len(id) == 36
+}
+
+// imageFilename extracts the filename from a stored image path for API responses
+func imageFilename(imagePath string) string {
+	if idx := strings.LastIndex(imagePath, "/"); idx != -1 {
+		return imagePath[idx+1:]
+	}
+	return imagePath
+}
+
+// imageURL returns the image reference for history responses: a bare filename,
+// or an absolute URL when a public base URL is configured
+func (h *HistoryHandler) imageURL(r *http.Request, imagePath string) string {
+	filename := imageFilename(imagePath)
+	if h.publicBaseURL == "" {
+		return filename
+	}
+	return h.baseURL(r) + "/uploads/" + filename
+}
+
+// imageEndpointURL returns the URL of an identification's image at
+// GET /history/{id}/image, root-relative unless a public base URL is configured
+func (h *HistoryHandler) imageEndpointURL(r *http.Request, id string, original bool) string {
+	baseURL := utils.BasePathFromContext(r.Context())
+	if h.publicBaseURL != "" {
+		baseURL = h.baseURL(r)
+	}
+	imageURL := baseURL + "/history/" + id + "/image"
+	if original {
+		imageURL += "?image=original"
+	}
+	return imageURL
+}
+
+// baseURL returns the configured public base URL, derived from the request
+// for PublicBaseURLFromRequest
+func (h *HistoryHandler) baseURL(r *http.Request) string {
+	if h.publicBaseURL == PublicBaseURLFromRequest {
+		return utils.RequestBaseURL(r) + utils.BasePathFromContext(r.Context())
+	}
+	return h.publicBaseURL
+}
+
+// historyImages returns the image served to the history UI, the optimized copy
+// unless the original is requested, and the original's reference when the two differ
+func (h *HistoryHandler) historyImages(r *http.Request, ident db.Identification, original bool) (string, string) {
+	if h.imageEndpoint {
+		if ident.OptimizedImagePath == "" || original {
+			return h.imageEndpointURL(r, ident.ID, ident.OptimizedImagePath != ""), ""
+		}
+		return h.imageEndpointURL(r, ident.ID, false), h.imageEndpointURL(r, ident.ID, true)
+	}
+
+	if ident.OptimizedImagePath == "" {
+		return h.imageURL(r, ident.ImagePath), ""
+	}
+	originalPath := h.imageURL(r, ident.ImagePath)
+	if original {
+		return originalPath, ""
+	}
+	return h.imageURL(r, ident.OptimizedImagePath), originalPath
+}
+
+// originalImageRequested parses the ?image= flag choosing the served image
+// variant: "optimized" (default) or "original"
+func originalImageRequested(r *http.Request) (bool, error) {
+	switch r.URL.Query().Get("image") {
+	case "", "optimized":
+		return false, nil
+	case "original":
+		return true, nil
+	default:
+		return false, fmt.Errorf("image must be \"optimized\" or \"original\"")
+	}
+}
+
 // HandleGetByID returns detailed information about a specific identification
 func (h *HistoryHandler) HandleGetByID(w http.ResponseWriter, r *http.Request) {
 	// Only accept GET requests
@@ -121,6 +472,21 @@ func (h *HistoryHandler) HandleGetByID(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	id := pathParts[1]
+	if !isValidID(id) {
+		h.sendError(w, http.StatusBadRequest, "Invalid identification ID")
+		return
+	}
+
+	summary, err := careSummaryRequested(r)
+	if err != nil {
+		h.sendError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	original, err := originalImageRequested(r)
+	if err != nil {
+		h.sendError(w, http.StatusBadRequest, err.Error())
+		return
+	}
 
 	// Get identification from database
 	identification, err := h.identificationRepo.GetByID(id)
@@ -131,21 +497,12 @@ func (h *HistoryHandler) HandleGetByID(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Convert care guide to response format
-	var careGuide *models.CareInstructions
-	if identification.CareGuide != nil {
-		careGuide = &models.CareInstructions{
-			Sunlight: identification.CareGuide.Sunlight,
-			Watering: identification.CareGuide.Watering,
-			Soil:     identification.CareGuide.Soil,
-			Notes:    identification.CareGuide.Notes,
-		}
+	careGuide := careInstructionsFromGuide(identification.CareGuide)
+	if summary {
+		withCareSummary(careGuide)
 	}
 
-	// Extract filename from full path for the API response
-	imagePath := identification.ImagePath
-	if idx := strings.LastIndex(imagePath, "/"); idx != -1 {
-		imagePath = imagePath[idx+1:]
-	}
+	imagePath, originalPath := h.historyImages(r, *identification, original)
 
 	response := models.HistoryDetailResponse{
 		ID:         identification.ID,
@@ -155,6 +512,9 @@ func (h *HistoryHandler) HandleGetByID(w http.ResponseWriter, r *http.Request) {
 		ImagePath:  imagePath,
 		CareGuide:  careGuide,
 		CreatedAt:  identification.CreatedAt,
+
+		OriginalImagePath: originalPath,
+		ImageMetadata:     imageMetadataFromRecord(identification.ImageMetadata),
 	}
 
 	w.Header().Set("Content-Type", "application/json")
@@ -162,6 +522,168 @@ func (h *HistoryHandler) HandleGetByID(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(response)
 }
 
+// maxBatchIDs caps how many identifications can be requested in one batch
+const maxBatchIDs = 100
+
+// HandleBatch returns the identifications matching a list of IDs.
+// Missing or deleted IDs are skipped rather than reported as errors.
+func (h *HistoryHandler) HandleBatch(w http.ResponseWriter, r *http.Request) {
+	// Only accept POST requests
+	if r.Method != http.MethodPost {
+		h.sendError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	var req models.HistoryBatchRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.sendError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	if len(req.IDs) == 0 {
+		h.sendError(w, http.StatusBadRequest, "ids is required")
+		return
+	}
+	original, err := originalImageRequested(r)
+	if err != nil {
+		h.sendError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if len(req.IDs) > maxBatchIDs {
+		h.sendError(w, http.StatusBadRequest, fmt.Sprintf("At most %d ids can be requested at once", maxBatchIDs))
+		return
+	}
+
+	// Malformed IDs cannot match and would make the uuid query fail as a whole,
+	// so they are skipped like IDs that are not found
+	ids := make([]string, 0, len(req.IDs))
+	for _, id := range req.IDs {
+		if isValidID(id) {
+			ids = append(ids, id)
+		}
+	}
+
+	identifications := []db.Identification{}
+	if len(ids) > 0 {
+		identifications, err = h.identificationRepo.GetByIDs(ids)
+		if err != nil {
+			log.Printf("Failed to get identifications by IDs: %v", err)
+			h.sendError(w, http.StatusInternalServerError, "Failed to retrieve identifications")
+			return
+		}
+	}
+
+	items := make([]models.HistoryDetailResponse, 0, len(identifications))
+	for _, ident := range identifications {
+		imagePath, originalPath := h.historyImages(r, ident, original)
+
+		items = append(items, models.HistoryDetailResponse{
+			ID:         ident.ID,
+			Genus:      ident.Genus,
+			Species:    ident.Species,
+			Confidence: ident.Confidence,
+			ImagePath:  imagePath,
+			CareGuide:  careInstructionsFromGuide(ident.CareGuide),
+			CreatedAt:  ident.CreatedAt,
+
+			OriginalImagePath: originalPath,
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(models.HistoryBatchResponse{Items: items})
+}
+
+// HandleGetCare returns the care generation status and care guide of an identification
+func (h *HistoryHandler) HandleGetCare(w http.ResponseWriter, r *http.Request) {
+	// Only accept GET requests
+	if r.Method != http.MethodGet {
+		h.sendError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	// Extract ID from URL path
+	// Expecting /history/:id/care
+	pathParts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	if len(pathParts) < 2 {
+		h.sendError(w, http.StatusBadRequest, "Missing identification ID")
+		return
+	}
+	id := pathParts[1]
+	if !isValidID(id) {
+		h.sendError(w, http.StatusBadRequest, "Invalid identification ID")
+		return
+	}
+
+	identification, err := h.identificationRepo.GetCare(id)
+	if err != nil {
+		log.Printf("Failed to get identification care: %v", err)
+		h.sendError(w, http.StatusNotFound, "Identification not found")
+		return
+	}
+
+	response := models.CareStatusResponse{
+		ID:         identification.ID,
+		CareStatus: identification.CareStatus,
+		Care:       careInstructionsFromGuide(identification.CareGuide),
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
+
+// HandleGetImage serves the image of an identification: the optimized copy
+// when there is one, or the original with ?image=original. Unlike /uploads/
+// it goes through the history routes, so it can be served when the public
+// uploads file server is disabled. Like the rest of history it requires no
+// authentication; anyone with an identification ID can fetch its image.
+func (h *HistoryHandler) HandleGetImage(w http.ResponseWriter, r *http.Request) {
+	// Only accept GET requests
+	if r.Method != http.MethodGet {
+		h.sendError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	// Extract ID from URL path
+	// Expecting /history/:id/image
+	pathParts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	if len(pathParts) < 2 {
+		h.sendError(w, http.StatusBadRequest, "Missing identification ID")
+		return
+	}
+	id := pathParts[1]
+	if !isValidID(id) {
+		h.sendError(w, http.StatusBadRequest, "Invalid identification ID")
+		return
+	}
+	original, err := originalImageRequested(r)
+	if err != nil {
+		h.sendError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	identification, err := h.identificationRepo.GetByID(id)
+	if err != nil {
+		log.Printf("Failed to get identification: %v", err)
+		h.sendError(w, http.StatusNotFound, "Identification not found")
+		return
+	}
+
+	imagePath := identification.ImagePath
+	if identification.OptimizedImagePath != "" && !original {
+		imagePath = identification.OptimizedImagePath
+	}
+	if _, err := os.Stat(imagePath); err != nil {
+		log.Printf("Image of identification %s unavailable: %v", id, err)
+		h.sendError(w, http.StatusNotFound, "Image not found")
+		return
+	}
+
+	http.ServeFile(w, r, imagePath)
+}
+
 // HandleGetWithChat returns identification with its chat history
 func (h *HistoryHandler) HandleGetWithChat(w http.ResponseWriter, r *http.Request) {
 	// Only accept GET requests
@@ -178,6 +700,21 @@ func (h *HistoryHandler) HandleGetWithChat(w http.ResponseWriter, r *http.Reques
 		return
 	}
 	id := pathParts[1]
+	if !isValidID(id) {
+		h.sendError(w, http.StatusBadRequest, "Invalid identification ID")
+		return
+	}
+
+	summary, err := careSummaryRequested(r)
+	if err != nil {
+		h.sendError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	original, err := originalImageRequested(r)
+	if err != nil {
+		h.sendError(w, http.StatusBadRequest, err.Error())
+		return
+	}
 
 	// Get identification from database
 	identification, err := h.identificationRepo.GetByID(id)
@@ -196,29 +733,23 @@ func (h *HistoryHandler) HandleGetWithChat(w http.ResponseWriter, r *http.Reques
 	}
 
 	// Convert to response format
-	var careGuide *models.CareInstructions
-	if identification.CareGuide != nil {
-		careGuide = &models.CareInstructions{
-			Sunlight: identification.CareGuide.Sunlight,
-			Watering: identification.CareGuide.Watering,
-			Soil:     identification.CareGuide.Soil,
-			Notes:    identification.CareGuide.Notes,
-		}
+	careGuide := careInstructionsFromGuide(identification.CareGuide)
+	if summary {
+		withCareSummary(careGuide)
 	}
 
-	// Extract filename from full path for the API response
-	imagePath := identification.ImagePath
-	if idx := strings.LastIndex(imagePath, "/"); idx != -1 {
-		imagePath = imagePath[idx+1:]
-	}
+	imagePath, originalPath := h.historyImages(r, *identification, original)
 
 	messages := make([]models.ChatMessageResponse, 0, len(chatMessages))
 	for _, msg := range chatMessages {
 		messages = append(messages, models.ChatMessageResponse{
-			ID:        msg.ID,
-			Message:   msg.Message,
-			Sender:    msg.Sender,
-			CreatedAt: msg.CreatedAt,
+			ID:             msg.ID,
+			Message:        msg.Message,
+			Sender:         msg.Sender,
+			Model:          msg.Model,
+			Truncated:      msg.Truncated,
+			CareReferences: msg.CareReferences,
+			CreatedAt:      msg.CreatedAt,
 		})
 	}
 
@@ -231,6 +762,9 @@ func (h *HistoryHandler) HandleGetWithChat(w http.ResponseWriter, r *http.Reques
 			ImagePath:  imagePath,
 			CareGuide:  careGuide,
 			CreatedAt:  identification.CreatedAt,
+
+			OriginalImagePath: originalPath,
+			ImageMetadata:     imageMetadataFromRecord(identification.ImageMetadata),
 		},
 		ChatMessages: messages,
 	}
@@ -256,6 +790,15 @@ func (h *HistoryHandler) HandleGetChatHistory(w http.ResponseWriter, r *http.Req
 		return
 	}
 	identificationID := pathParts[1]
+	if !isValidID(identificationID) {
+		h.sendError(w, http.StatusBadRequest, "Invalid identification ID")
+		return
+	}
+	// The former shared general conversation is not anyone's chat history
+	if identificationID == db.GeneralConversationID {
+		h.sendError(w, http.StatusNotFound, "Chat history not found")
+		return
+	}
 
 	// Get chat messages
 	chatMessages, err := h.chatRepo.GetByIdentificationID(identificationID)
@@ -269,10 +812,13 @@ func (h *HistoryHandler) HandleGetChatHistory(w http.ResponseWriter, r *http.Req
 	messages := make([]models.ChatMessageResponse, 0, len(chatMessages))
 	for _, msg := range chatMessages {
 		messages = append(messages, models.ChatMessageResponse{
-			ID:        msg.ID,
-			Message:   msg.Message,
-			Sender:    msg.Sender,
-			CreatedAt: msg.CreatedAt,
+			ID:             msg.ID,
+			Message:        msg.Message,
+			Sender:         msg.Sender,
+			Model:          msg.Model,
+			Truncated:      msg.Truncated,
+			CareReferences: msg.CareReferences,
+			CreatedAt:      msg.CreatedAt,
 		})
 	}
 
@@ -303,6 +849,10 @@ func (h *HistoryHandler) HandleDelete(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	id := pathParts[1]
+	if !isValidID(id) {
+		h.sendError(w, http.StatusBadRequest, "Invalid identification ID")
+		return
+	}
 
 	// Soft delete from database
 	err := h.identificationRepo.Delete(id)
@@ -329,10 +879,23 @@ func (h *HistoryHandler) HandleDelete(w http.ResponseWriter, r *http.Request) {
 
 // sendError sends an error response
 func (h *HistoryHandler) sendError(w http.ResponseWriter, statusCode int, message string) {
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(statusCode)
-	json.NewEncoder(w).Encode(models.ErrorResponse{
-		Error:   http.StatusText(statusCode),
-		Message: message,
-	})
+	writeError(w, statusCode, message)
+}
+
+// careSnippet returns the care summary of a stored guide, cut at a word
+// boundary to careSnippetLength characters
+func careSnippet(careGuide *db.CareGuide) string {
+	if careGuide == nil {
+		return ""
+	}
+	summary := careSummary(careInstructionsFromGuide(careGuide))
+	runes := []rune(summary)
+	if len(runes) <= careSnippetLength {
+		return summary
+	}
+	snippet := string(runes[:careSnippetLength])
+	if i := strings.LastIndex(snippet, " "); i > 0 {
+		snippet = snippet[:i]
+	}
+	return strings.TrimRight(snippet, " .,;:") + "…"
 }
@@ -1,9 +1,17 @@
 package handlers
 
 import (
+	"bytes"
+	"encoding/csv"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
 	"succulent-identifier-backend/db"
 	"succulent-identifier-backend/models"
 	"testing"
@@ -71,6 +79,23 @@ func TestHistoryHandlerHandleList(t *testing.T) {
 			expectedStatus: http.StatusOK,
 			expectedItems:  1,
 		},
+		{
+			name:        "Invalid pagination falls back to defaults",
+			queryParams: "?limit=abc&offset=-1",
+			identifications: []db.Identification{
+				{
+					ID:         "id-1",
+					Genus:      "Haworthia",
+					Species:    "zebrina",
+					Confidence: 0.95,
+					ImagePath:  "/uploads/1.jpg",
+					CreatedAt:  time.Now(),
+				},
+			},
+			totalCount:     1,
+			expectedStatus: http.StatusOK,
+			expectedItems:  1,
+		},
 		{
 			name:           "Database error",
 			queryParams:    "",
@@ -133,6 +158,9 @@ func TestHistoryHandlerHandleList(t *testing.T) {
 				if response.Total != tt.totalCount {
 					t.Errorf("Expected total %d, got %d", tt.totalCount, response.Total)
 				}
+				if tt.queryParams == "?limit=abc&offset=-1" && (response.Limit != 20 || response.Offset != 0) {
+					t.Errorf("Expected default limit 20 and offset 0, got %d and %d", response.Limit, response.Offset)
+				}
 
 				// Verify item structure
 				for _, item := range response.Items {
@@ -161,9 +189,9 @@ func TestHistoryHandlerHandleGetByID(t *testing.T) {
 	}{
 		{
 			name: "Successful get by ID",
-			path: "/history/plant-id-1",
+			path: "/history/7c9e6679-7425-40de-944b-e07fc1f90ae7",
 			identification: &db.Identification{
-				ID:         "plant-id-1",
+				ID:         "7c9e6679-7425-40de-944b-e07fc1f90ae7",
 				Genus:      "Haworthia",
 				Species:    "zebrina",
 				Confidence: 0.95,
@@ -180,13 +208,13 @@ func TestHistoryHandlerHandleGetByID(t *testing.T) {
 		},
 		{
 			name:           "Not found",
-			path:           "/history/non-existent",
+			path:           "/history/00000000-0000-4000-8000-000000000000",
 			repoErr:        db.ErrNotFound,
 			expectedStatus: http.StatusNotFound,
 		},
 		{
 			name:           "Method not allowed",
-			path:           "/history/plant-id-1",
+			path:           "/history/7c9e6679-7425-40de-944b-e07fc1f90ae7",
 			expectedStatus: http.StatusMethodNotAllowed,
 		},
 	}
@@ -264,25 +292,26 @@ func TestHistoryHandlerHandleGetChatHistory(t *testing.T) {
 	}{
 		{
 			name: "Successful get chat history",
-			path: "/chat/plant-id-1",
+			path: "/chat/7c9e6679-7425-40de-944b-e07fc1f90ae7",
 			chatMessages: []db.ChatMessage{
 				{
 					ID:               "msg-1",
-					IdentificationID: "plant-id-1",
+					IdentificationID: "7c9e6679-7425-40de-944b-e07fc1f90ae7",
 					Message:          "How often should I water?",
 					Sender:           "user",
 					CreatedAt:        time.Now().Add(-10 * time.Minute),
 				},
 				{
 					ID:               "msg-2",
-					IdentificationID: "plant-id-1",
+					IdentificationID: "7c9e6679-7425-40de-944b-e07fc1f90ae7",
 					Message:          "Water when the soil is dry.",
 					Sender:           "llm",
+					Model:            "gpt-4o-mini",
 					CreatedAt:        time.Now().Add(-9 * time.Minute),
 				},
 				{
 					ID:               "msg-3",
-					IdentificationID: "plant-id-1",
+					IdentificationID: "7c9e6679-7425-40de-944b-e07fc1f90ae7",
 					Message:          "How much sunlight does it need?",
 					Sender:           "user",
 					CreatedAt:        time.Now().Add(-5 * time.Minute),
@@ -293,22 +322,30 @@ func TestHistoryHandlerHandleGetChatHistory(t *testing.T) {
 		},
 		{
 			name:           "Empty chat history",
-			path:           "/chat/plant-id-2",
+			path:           "/chat/2b1e4f6a-93d0-4c58-8e27-5f0a9c3d1b42",
 			chatMessages:   []db.ChatMessage{},
 			expectedStatus: http.StatusOK,
 			expectedCount:  0,
 		},
 		{
 			name:           "Database error",
-			path:           "/chat/plant-id-3",
+			path:           "/chat/e4a1c0d2-5b7f-4e93-a6c8-0d2f7b9e3a15",
 			repoErr:        db.ErrNotFound,
 			expectedStatus: http.StatusInternalServerError,
 		},
 		{
 			name:           "Method not allowed",
-			path:           "/chat/plant-id-1",
+			path:           "/chat/7c9e6679-7425-40de-944b-e07fc1f90ae7",
 			expectedStatus: http.StatusMethodNotAllowed,
 		},
+		{
+			name: "Former general conversation",
+			path: "/chat/" + db.GeneralConversationID,
+			chatMessages: []db.ChatMessage{
+				{ID: "msg-1", IdentificationID: db.GeneralConversationID, Message: "Someone else's question", Sender: "user"},
+			},
+			expectedStatus: http.StatusNotFound,
+		},
 	}
 
 	for _, tt := range tests {
@@ -370,6 +407,12 @@ func TestHistoryHandlerHandleGetChatHistory(t *testing.T) {
 					if msg.Sender != "user" && msg.Sender != "llm" {
 						t.Errorf("Invalid sender: %s", msg.Sender)
 					}
+					if msg.Sender == "llm" && msg.Model != "gpt-4o-mini" {
+						t.Errorf("Expected llm message model gpt-4o-mini, got %q", msg.Model)
+					}
+					if msg.Sender == "user" && msg.Model != "" {
+						t.Errorf("Expected no model on user message, got %q", msg.Model)
+					}
 				}
 			}
 		})
@@ -388,9 +431,9 @@ func TestHistoryHandlerHandleGetWithChat(t *testing.T) {
 	}{
 		{
 			name: "Successful get with chat",
-			path: "/history/plant-id-1/with-chat",
+			path: "/history/7c9e6679-7425-40de-944b-e07fc1f90ae7/with-chat",
 			identification: &db.Identification{
-				ID:         "plant-id-1",
+				ID:         "7c9e6679-7425-40de-944b-e07fc1f90ae7",
 				Genus:      "Echeveria",
 				Species:    "elegans",
 				Confidence: 0.98,
@@ -405,14 +448,14 @@ func TestHistoryHandlerHandleGetWithChat(t *testing.T) {
 			chatMessages: []db.ChatMessage{
 				{
 					ID:               "msg-1",
-					IdentificationID: "plant-id-1",
+					IdentificationID: "7c9e6679-7425-40de-944b-e07fc1f90ae7",
 					Message:          "Is this plant pet-safe?",
 					Sender:           "user",
 					CreatedAt:        time.Now().Add(-5 * time.Minute),
 				},
 				{
 					ID:               "msg-2",
-					IdentificationID: "plant-id-1",
+					IdentificationID: "7c9e6679-7425-40de-944b-e07fc1f90ae7",
 					Message:          "Yes, Echeveria is generally non-toxic to pets.",
 					Sender:           "llm",
 					CreatedAt:        time.Now().Add(-4 * time.Minute),
@@ -422,9 +465,9 @@ func TestHistoryHandlerHandleGetWithChat(t *testing.T) {
 		},
 		{
 			name: "Get with empty chat",
-			path: "/history/plant-id-2/with-chat",
+			path: "/history/2b1e4f6a-93d0-4c58-8e27-5f0a9c3d1b42/with-chat",
 			identification: &db.Identification{
-				ID:         "plant-id-2",
+				ID:         "2b1e4f6a-93d0-4c58-8e27-5f0a9c3d1b42",
 				Genus:      "Aloe",
 				Species:    "vera",
 				Confidence: 0.90,
@@ -436,15 +479,15 @@ func TestHistoryHandlerHandleGetWithChat(t *testing.T) {
 		},
 		{
 			name:           "Identification not found",
-			path:           "/history/non-existent/with-chat",
+			path:           "/history/00000000-0000-4000-8000-000000000000/with-chat",
 			identErr:       db.ErrNotFound,
 			expectedStatus: http.StatusNotFound,
 		},
 		{
 			name: "Chat fetch error (continues with empty)",
-			path: "/history/plant-id-3/with-chat",
+			path: "/history/e4a1c0d2-5b7f-4e93-a6c8-0d2f7b9e3a15/with-chat",
 			identification: &db.Identification{
-				ID:    "plant-id-3",
+				ID:    "e4a1c0d2-5b7f-4e93-a6c8-0d2f7b9e3a15",
 				Genus: "Test",
 			},
 			chatErr:        db.ErrNotFound,
@@ -509,3 +552,841 @@ func TestHistoryHandlerHandleGetWithChat(t *testing.T) {
 		})
 	}
 }
+
+func TestHistoryHandlerHandleGetCare(t *testing.T) {
+	tests := []struct {
+		name           string
+		identification *db.Identification
+		repoErr        error
+		expectedStatus int
+		expectedCare   bool
+	}{
+		{
+			name: "Care still generating",
+			identification: &db.Identification{
+				ID:         "7c9e6679-7425-40de-944b-e07fc1f90ae7",
+				CareStatus: db.CareStatusGenerating,
+			},
+			expectedStatus: http.StatusOK,
+			expectedCare:   false,
+		},
+		{
+			name: "Care ready",
+			identification: &db.Identification{
+				ID:         "7c9e6679-7425-40de-944b-e07fc1f90ae7",
+				CareStatus: db.CareStatusReady,
+				CareGuide:  &db.CareGuide{Sunlight: "Bright indirect light"},
+			},
+			expectedStatus: http.StatusOK,
+			expectedCare:   true,
+		},
+		{
+			name:           "Not found",
+			repoErr:        db.ErrNotFound,
+			expectedStatus: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockIdentRepo := &mockIdentificationRepository{
+				getCareResult: tt.identification,
+				getCareErr:    tt.repoErr,
+			}
+			handler := NewHistoryHandler(mockIdentRepo, &mockChatRepository{})
+
+			req := httptest.NewRequest(http.MethodGet, "/history/7c9e6679-7425-40de-944b-e07fc1f90ae7/care", nil)
+			rr := httptest.NewRecorder()
+			handler.HandleGetCare(rr, req)
+
+			if rr.Code != tt.expectedStatus {
+				t.Fatalf("Handler returned wrong status code: got %v, expected %v",
+					rr.Code, tt.expectedStatus)
+			}
+
+			if tt.expectedStatus != http.StatusOK {
+				return
+			}
+
+			var response models.CareStatusResponse
+			if err := json.NewDecoder(rr.Body).Decode(&response); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+
+			if response.CareStatus != tt.identification.CareStatus {
+				t.Errorf("Expected care_status %s, got %s", tt.identification.CareStatus, response.CareStatus)
+			}
+			if tt.expectedCare && (response.Care == nil || response.Care.Sunlight == "") {
+				t.Error("Expected care in response")
+			}
+			if !tt.expectedCare && response.Care != nil {
+				t.Errorf("Expected no care while generating, got %+v", response.Care)
+			}
+		})
+	}
+}
+
+func TestHistoryHandlerHandleBatch(t *testing.T) {
+	tests := []struct {
+		name           string
+		method         string
+		body           string
+		repoResult     []db.Identification
+		repoErr        error
+		expectedStatus int
+		expectedIDs    []string
+		queriedIDs     []string
+	}{
+		{
+			name:   "Mix of present, absent and malformed IDs",
+			method: http.MethodPost,
+			body:   `{"ids":["7c9e6679-7425-40de-944b-e07fc1f90ae7","missing-id","2b1e4f6a-93d0-4c58-8e27-5f0a9c3d1b42","e4a1c0d2-5b7f-4e93-a6c8-0d2f7b9e3a15"]}`,
+			repoResult: []db.Identification{
+				{ID: "2b1e4f6a-93d0-4c58-8e27-5f0a9c3d1b42", Genus: "echeveria", ImagePath: "/uploads/2.jpg", CareGuide: &db.CareGuide{Sunlight: "Full sun"}},
+				{ID: "7c9e6679-7425-40de-944b-e07fc1f90ae7", Genus: "haworthia", ImagePath: "/uploads/1.jpg"},
+			},
+			expectedStatus: http.StatusOK,
+			expectedIDs:    []string{"2b1e4f6a-93d0-4c58-8e27-5f0a9c3d1b42", "7c9e6679-7425-40de-944b-e07fc1f90ae7"},
+			queriedIDs:     []string{"7c9e6679-7425-40de-944b-e07fc1f90ae7", "2b1e4f6a-93d0-4c58-8e27-5f0a9c3d1b42", "e4a1c0d2-5b7f-4e93-a6c8-0d2f7b9e3a15"},
+		},
+		{
+			name:           "Only malformed IDs",
+			method:         http.MethodPost,
+			body:           `{"ids":["missing-id","x' OR '1'='1"]}`,
+			repoErr:        errors.New("invalid input syntax for type uuid"),
+			expectedStatus: http.StatusOK,
+			expectedIDs:    []string{},
+		},
+		{
+			name:           "Empty ID list",
+			method:         http.MethodPost,
+			body:           `{"ids":[]}`,
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "Invalid body",
+			method:         http.MethodPost,
+			body:           `not json`,
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "Repository error",
+			method:         http.MethodPost,
+			body:           `{"ids":["7c9e6679-7425-40de-944b-e07fc1f90ae7"]}`,
+			repoErr:        errors.New("database error"),
+			expectedStatus: http.StatusInternalServerError,
+		},
+		{
+			name:           "Wrong method",
+			method:         http.MethodGet,
+			expectedStatus: http.StatusMethodNotAllowed,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockIdentRepo := &mockIdentificationRepository{
+				getByIDsResult: tt.repoResult,
+				getByIDsErr:    tt.repoErr,
+			}
+			handler := NewHistoryHandler(mockIdentRepo, &mockChatRepository{})
+
+			req := httptest.NewRequest(tt.method, "/history/batch", strings.NewReader(tt.body))
+			rr := httptest.NewRecorder()
+			handler.HandleBatch(rr, req)
+
+			if rr.Code != tt.expectedStatus {
+				t.Fatalf("Handler returned wrong status code: got %v, expected %v",
+					rr.Code, tt.expectedStatus)
+			}
+
+			if tt.expectedStatus != http.StatusOK {
+				return
+			}
+
+			var response models.HistoryBatchResponse
+			if err := json.NewDecoder(rr.Body).Decode(&response); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+
+			if len(response.Items) != len(tt.expectedIDs) {
+				t.Fatalf("Expected %d items, got %d", len(tt.expectedIDs), len(response.Items))
+			}
+			for i, id := range tt.expectedIDs {
+				if response.Items[i].ID != id {
+					t.Errorf("Expected item %d to be %s, got %s", i, id, response.Items[i].ID)
+				}
+			}
+			if len(response.Items) > 0 && response.Items[0].ImagePath != "2.jpg" {
+				t.Errorf("Expected image filename only, got %s", response.Items[0].ImagePath)
+			}
+			if !reflect.DeepEqual(mockIdentRepo.getByIDsCalledWith, tt.queriedIDs) {
+				t.Errorf("Expected lookup of %v, got %v", tt.queriedIDs, mockIdentRepo.getByIDsCalledWith)
+			}
+		})
+	}
+}
+
+func TestHistoryHandlerHandleExport(t *testing.T) {
+	createdAt := time.Date(2026, 3, 1, 12, 30, 0, 0, time.UTC)
+	identifications := make([]db.Identification, 250)
+	for i := range identifications {
+		identifications[i] = db.Identification{
+			ID:         fmt.Sprintf("plant-id-%d", i),
+			Genus:      "haworthia",
+			Species:    "haworthia_zebrina",
+			Confidence: 0.9,
+			ImagePath:  "/app/uploads/plant.jpg",
+			CareGuide:  &db.CareGuide{Sunlight: "Bright, indirect light", Difficulty: db.CareDifficultyEasy},
+			CreatedAt:  createdAt,
+		}
+	}
+
+	t.Run("JSON export", func(t *testing.T) {
+		handler := NewHistoryHandler(&mockIdentificationRepository{getAllResult: identifications}, &mockChatRepository{})
+
+		rr := httptest.NewRecorder()
+		handler.HandleExport(rr, httptest.NewRequest(http.MethodGet, "/history/export", nil))
+
+		if rr.Code != http.StatusOK {
+			t.Fatalf("Handler returned wrong status code: got %v, expected %v", rr.Code, http.StatusOK)
+		}
+		if !rr.Flushed {
+			t.Error("Expected export to be flushed while streaming")
+		}
+
+		var items []models.HistoryDetailResponse
+		if err := json.NewDecoder(rr.Body).Decode(&items); err != nil {
+			t.Fatalf("Export is not a valid JSON array: %v", err)
+		}
+		if len(items) != len(identifications) {
+			t.Fatalf("Expected %d items, got %d", len(identifications), len(items))
+		}
+		if items[0].ImagePath != "plant.jpg" || items[0].CareGuide == nil {
+			t.Errorf("Unexpected first item: %+v", items[0])
+		}
+	})
+
+	t.Run("CSV export", func(t *testing.T) {
+		handler := NewHistoryHandler(&mockIdentificationRepository{getAllResult: identifications[:2]}, &mockChatRepository{})
+
+		rr := httptest.NewRecorder()
+		handler.HandleExport(rr, httptest.NewRequest(http.MethodGet, "/history/export?format=csv", nil))
+
+		if rr.Code != http.StatusOK {
+			t.Fatalf("Handler returned wrong status code: got %v, expected %v", rr.Code, http.StatusOK)
+		}
+		if rr.Header().Get("Content-Type") != "text/csv" {
+			t.Errorf("Expected text/csv content type, got %s", rr.Header().Get("Content-Type"))
+		}
+
+		records, err := csv.NewReader(rr.Body).ReadAll()
+		if err != nil {
+			t.Fatalf("Export is not valid CSV: %v", err)
+		}
+		if len(records) != 3 {
+			t.Fatalf("Expected header and 2 rows, got %d records", len(records))
+		}
+		expected := []string{"plant-id-0", "haworthia", "haworthia_zebrina", "0.9000", "plant.jpg", "2026-03-01T12:30:00Z",
+			"Bright, indirect light", "", "", "", "", "easy"}
+		if len(records[0]) != len(expected) || records[0][len(expected)-1] != "difficulty" {
+			t.Fatalf("Expected %d columns ending in difficulty, got %v", len(expected), records[0])
+		}
+		for i, value := range expected {
+			if records[1][i] != value {
+				t.Errorf("Column %s = %q, expected %q", records[0][i], records[1][i], value)
+			}
+		}
+	})
+
+	t.Run("Confidence precision", func(t *testing.T) {
+		precise := []db.Identification{{ID: "plant-id-0", Genus: "haworthia", Confidence: 0.8523471, CreatedAt: createdAt}}
+		export := func(precision int, format string) *httptest.ResponseRecorder {
+			handler := NewHistoryHandler(&mockIdentificationRepository{getAllResult: precise}, &mockChatRepository{})
+			handler.SetExportPrecision(precision)
+			rr := httptest.NewRecorder()
+			handler.HandleExport(rr, httptest.NewRequest(http.MethodGet, "/history/export?format="+format, nil))
+			return rr
+		}
+
+		for precision, expected := range map[int]string{4: "0.8523", 2: "0.85", -1: "0.8523471"} {
+			records, err := csv.NewReader(export(precision, "csv").Body).ReadAll()
+			if err != nil {
+				t.Fatalf("Export is not valid CSV: %v", err)
+			}
+			if records[1][3] != expected {
+				t.Errorf("CSV confidence with precision %d = %q, expected %q", precision, records[1][3], expected)
+			}
+		}
+
+		if body := export(4, "json").Body.String(); !strings.Contains(body, `"confidence":0.8523,`) {
+			t.Errorf("Expected JSON confidence rounded to 0.8523, got %s", body)
+		}
+
+		// Precisions beyond what a float64 holds are capped instead of overflowing
+		rr := export(400, "json")
+		if rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), `"confidence":0.8523471,`) {
+			t.Errorf("Expected JSON confidence 0.8523471 with precision 400, got %d %s", rr.Code, rr.Body.String())
+		}
+		records, err := csv.NewReader(export(400, "csv").Body).ReadAll()
+		if err != nil || records[1][3] != "0.852347100000000" {
+			t.Errorf("Expected CSV confidence with %d decimals, got %v (err %v)", maxExportPrecision, records, err)
+		}
+	})
+
+	t.Run("Empty history", func(t *testing.T) {
+		handler := NewHistoryHandler(&mockIdentificationRepository{}, &mockChatRepository{})
+
+		rr := httptest.NewRecorder()
+		handler.HandleExport(rr, httptest.NewRequest(http.MethodGet, "/history/export", nil))
+
+		if strings.TrimSpace(rr.Body.String()) != "[]" {
+			t.Errorf("Expected empty JSON array, got %s", rr.Body.String())
+		}
+	})
+
+	t.Run("Unsupported format", func(t *testing.T) {
+		handler := NewHistoryHandler(&mockIdentificationRepository{}, &mockChatRepository{})
+
+		rr := httptest.NewRecorder()
+		handler.HandleExport(rr, httptest.NewRequest(http.MethodGet, "/history/export?format=xml", nil))
+
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("Expected status 400, got %d", rr.Code)
+		}
+	})
+}
+
+func TestCareExportFieldOrder(t *testing.T) {
+	guide := &db.CareGuide{
+		Sunlight: "Bright light",
+		Watering: "Sparingly",
+		Soil:     "Gritty mix",
+		Notes:    "Slow grower",
+		Trivia:   "Native to South Africa",
+
+		Difficulty: db.CareDifficultyModerate,
+	}
+
+	// jsonKeys returns the top-level keys of a JSON object in encoded order
+	jsonKeys := func(v interface{}) []string {
+		data, err := json.Marshal(v)
+		if err != nil {
+			t.Fatalf("Failed to marshal care: %v", err)
+		}
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.Token() // opening brace
+		var keys []string
+		for decoder.More() {
+			key, _ := decoder.Token()
+			keys = append(keys, key.(string))
+			var value interface{}
+			decoder.Decode(&value)
+		}
+		return keys
+	}
+
+	for name, care := range map[string]interface{}{
+		"stored care guide": guide,
+		"care response":     careInstructionsFromGuide(guide),
+	} {
+		if keys := jsonKeys(care); !reflect.DeepEqual(keys, careExportFields) {
+			t.Errorf("%s: expected JSON keys %v, got %v", name, careExportFields, keys)
+		}
+	}
+
+	expected := []string{"Bright light", "Sparingly", "Gritty mix", "Slow grower", "Native to South Africa", "moderate"}
+	if values := careExportValues(guide); !reflect.DeepEqual(values, expected) {
+		t.Errorf("Expected CSV care values %v, got %v", expected, values)
+	}
+	if values := careExportValues(nil); len(values) != len(careExportFields) {
+		t.Errorf("Expected %d empty values without care, got %v", len(careExportFields), values)
+	}
+}
+
+func TestHistoryHandlerCareSummary(t *testing.T) {
+	guide := &db.CareGuide{
+		Sunlight: "Bright indirect light",
+		Watering: "Water when the soil is dry",
+		Soil:     "Well-draining cactus mix",
+		Notes:    "Protect from frost!",
+	}
+	mockIdentRepo := &mockIdentificationRepository{
+		getByIDResult: &db.Identification{
+			ID:        "7c9e6679-7425-40de-944b-e07fc1f90ae7",
+			Genus:     "Haworthia",
+			CareGuide: guide,
+			CreatedAt: time.Now(),
+		},
+	}
+	handler := NewHistoryHandler(mockIdentRepo, &mockChatRepository{})
+
+	get := func(query string) (*httptest.ResponseRecorder, models.HistoryDetailResponse) {
+		req := httptest.NewRequest(http.MethodGet, "/history/7c9e6679-7425-40de-944b-e07fc1f90ae7"+query, nil)
+		rr := httptest.NewRecorder()
+		handler.HandleGetByID(rr, req)
+
+		var response models.HistoryDetailResponse
+		if rr.Code == http.StatusOK {
+			if err := json.NewDecoder(rr.Body).Decode(&response); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+		}
+		return rr, response
+	}
+
+	rr, response := get("?care_summary=true")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", rr.Code)
+	}
+	summary := response.CareGuide.Summary
+	for _, field := range []string{guide.Sunlight, guide.Watering, guide.Soil, guide.Notes} {
+		if !strings.Contains(summary, field) {
+			t.Errorf("Expected summary to mention %q, got %q", field, summary)
+		}
+	}
+	if strings.Contains(summary, "Did you know") || strings.Contains(summary, "!.") {
+		t.Errorf("Expected summary without empty trivia or doubled punctuation, got %q", summary)
+	}
+
+	if _, response := get(""); response.CareGuide.Summary != "" {
+		t.Errorf("Expected no summary unless requested, got %q", response.CareGuide.Summary)
+	}
+
+	if rr, _ := get("?care_summary=maybe"); rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for an invalid care_summary, got %d", rr.Code)
+	}
+}
+
+func TestHistoryHandlerListCareSnippet(t *testing.T) {
+	mockIdentRepo := &mockIdentificationRepository{
+		getAllResult: []db.Identification{
+			{
+				ID:    "id-1",
+				Genus: "Haworthia",
+				CareGuide: &db.CareGuide{
+					Sunlight: "Bright indirect light",
+					Watering: "Water deeply when the soil has dried out completely, less often in winter",
+					Soil:     "Gritty, well-draining cactus mix with pumice or perlite",
+				},
+				CreatedAt: time.Now(),
+			},
+			{ID: "id-2", Genus: "Aloe", CreatedAt: time.Now()},
+		},
+		countResult: 2,
+	}
+	handler := NewHistoryHandler(mockIdentRepo, &mockChatRepository{})
+
+	list := func(query string) (*httptest.ResponseRecorder, models.HistoryListResponse) {
+		rr := httptest.NewRecorder()
+		handler.HandleList(rr, httptest.NewRequest(http.MethodGet, "/history"+query, nil))
+
+		var response models.HistoryListResponse
+		if rr.Code == http.StatusOK {
+			if err := json.NewDecoder(rr.Body).Decode(&response); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+		}
+		return rr, response
+	}
+
+	rr, response := list("?include_care=snippet")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", rr.Code)
+	}
+	snippet := response.Items[0].CareSnippet
+	if !strings.HasPrefix(snippet, "Sunlight: Bright indirect light.") || !strings.HasSuffix(snippet, "…") {
+		t.Errorf("Expected truncated care summary, got %q", snippet)
+	}
+	if n := len([]rune(snippet)); n > careSnippetLength+1 {
+		t.Errorf("Expected snippet of at most %d characters, got %d", careSnippetLength+1, n)
+	}
+	if response.Items[1].CareSnippet != "" {
+		t.Errorf("Expected no snippet without a care guide, got %q", response.Items[1].CareSnippet)
+	}
+
+	if _, response := list(""); response.Items[0].CareSnippet != "" {
+		t.Errorf("Expected no care snippet unless requested, got %q", response.Items[0].CareSnippet)
+	}
+
+	if rr, _ := list("?include_care=full"); rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for an invalid include_care, got %d", rr.Code)
+	}
+}
+
+func TestHistoryHandlerImageURL(t *testing.T) {
+	identification := &db.Identification{
+		ID:         "7c9e6679-7425-40de-944b-e07fc1f90ae7",
+		Genus:      "Haworthia",
+		Species:    "zebrina",
+		Confidence: 0.95,
+		ImagePath:  "/app/uploads/abc.jpg",
+		CreatedAt:  time.Now(),
+	}
+
+	tests := []struct {
+		name           string
+		publicBaseURL  string
+		forwarded      bool
+		privateUploads bool
+		expectedImage  string
+	}{
+		{
+			name:          "Bare filename by default",
+			publicBaseURL: "",
+			expectedImage: "abc.jpg",
+		},
+		{
+			name:           "Image endpoint without public uploads",
+			privateUploads: true,
+			expectedImage:  "/history/7c9e6679-7425-40de-944b-e07fc1f90ae7/image",
+		},
+		{
+			name:           "Absolute image endpoint without public uploads",
+			publicBaseURL:  "https://plants.example.com/",
+			privateUploads: true,
+			expectedImage:  "https://plants.example.com/history/7c9e6679-7425-40de-944b-e07fc1f90ae7/image",
+		},
+		{
+			name:          "Configured base URL",
+			publicBaseURL: "https://plants.example.com/",
+			expectedImage: "https://plants.example.com/uploads/abc.jpg",
+		},
+		{
+			name:          "Base URL derived from forwarded request",
+			publicBaseURL: PublicBaseURLFromRequest,
+			forwarded:     true,
+			expectedImage: "https://public.example.com/uploads/abc.jpg",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockRepo := &mockIdentificationRepository{
+				getByIDResult:  identification,
+				getAllResult:   []db.Identification{*identification},
+				getByIDsResult: []db.Identification{*identification},
+				countResult:    1,
+			}
+			handler := NewHistoryHandler(mockRepo, &mockChatRepository{})
+			handler.SetPublicBaseURL(tt.publicBaseURL)
+			handler.SetPublicUploads(!tt.privateUploads)
+
+			newRequest := func(method, target string, body string) *http.Request {
+				req := httptest.NewRequest(method, target, strings.NewReader(body))
+				if tt.forwarded {
+					// As rewritten by utils.ForwardedHeadersMiddleware behind a trusted proxy
+					req.Host = "public.example.com"
+					req.URL.Scheme = "https"
+				}
+				return req
+			}
+
+			w := httptest.NewRecorder()
+			handler.HandleList(w, newRequest(http.MethodGet, "/history", ""))
+			var list models.HistoryListResponse
+			json.NewDecoder(w.Body).Decode(&list)
+			if len(list.Items) != 1 || list.Items[0].ImagePath != tt.expectedImage {
+				t.Errorf("List image path = %+v, expected %s", list.Items, tt.expectedImage)
+			}
+
+			w = httptest.NewRecorder()
+			handler.HandleGetByID(w, newRequest(http.MethodGet, "/history/7c9e6679-7425-40de-944b-e07fc1f90ae7", ""))
+			var detail models.HistoryDetailResponse
+			json.NewDecoder(w.Body).Decode(&detail)
+			if detail.ImagePath != tt.expectedImage {
+				t.Errorf("Detail image path = %s, expected %s", detail.ImagePath, tt.expectedImage)
+			}
+
+			w = httptest.NewRecorder()
+			handler.HandleBatch(w, newRequest(http.MethodPost, "/history/batch", `{"ids":["7c9e6679-7425-40de-944b-e07fc1f90ae7"]}`))
+			var batch models.HistoryBatchResponse
+			json.NewDecoder(w.Body).Decode(&batch)
+			if len(batch.Items) != 1 || batch.Items[0].ImagePath != tt.expectedImage {
+				t.Errorf("Batch image path = %+v, expected %s", batch.Items, tt.expectedImage)
+			}
+		})
+	}
+}
+
+func TestHistoryHandlerOptimizedImage(t *testing.T) {
+	optimized := db.Identification{
+		ID:                 "7c9e6679-7425-40de-944b-e07fc1f90ae7",
+		Genus:              "Haworthia",
+		ImagePath:          "/app/uploads/abc.png",
+		OptimizedImagePath: "/app/uploads/abc.png.web.jpg",
+		CreatedAt:          time.Now(),
+	}
+	legacy := db.Identification{ID: "id-2", Genus: "Aloe", ImagePath: "/app/uploads/old.jpg", CreatedAt: time.Now()}
+
+	mockRepo := &mockIdentificationRepository{
+		getByIDResult:  &optimized,
+		getAllResult:   []db.Identification{optimized, legacy},
+		getByIDsResult: []db.Identification{optimized},
+		countResult:    2,
+	}
+	handler := NewHistoryHandler(mockRepo, &mockChatRepository{})
+
+	list := func(query string) (int, models.HistoryListResponse) {
+		w := httptest.NewRecorder()
+		handler.HandleList(w, httptest.NewRequest(http.MethodGet, "/history"+query, nil))
+		var response models.HistoryListResponse
+		json.NewDecoder(w.Body).Decode(&response)
+		return w.Code, response
+	}
+
+	_, response := list("")
+	if item := response.Items[0]; item.ImagePath != "abc.png.web.jpg" || item.OriginalImagePath != "abc.png" {
+		t.Errorf("Default list item = %+v, expected optimized image with original", item)
+	}
+	if item := response.Items[1]; item.ImagePath != "old.jpg" || item.OriginalImagePath != "" {
+		t.Errorf("List item without optimized copy = %+v, expected original only", item)
+	}
+
+	_, response = list("?image=original")
+	if item := response.Items[0]; item.ImagePath != "abc.png" || item.OriginalImagePath != "" {
+		t.Errorf("List item with image=original = %+v, expected original only", item)
+	}
+
+	w := httptest.NewRecorder()
+	handler.HandleGetByID(w, httptest.NewRequest(http.MethodGet, "/history/7c9e6679-7425-40de-944b-e07fc1f90ae7", nil))
+	var detail models.HistoryDetailResponse
+	json.NewDecoder(w.Body).Decode(&detail)
+	if detail.ImagePath != "abc.png.web.jpg" || detail.OriginalImagePath != "abc.png" {
+		t.Errorf("Detail = %+v, expected optimized image with original", detail)
+	}
+
+	w = httptest.NewRecorder()
+	handler.HandleBatch(w, httptest.NewRequest(http.MethodPost, "/history/batch?image=original", strings.NewReader(`{"ids":["7c9e6679-7425-40de-944b-e07fc1f90ae7"]}`)))
+	var batch models.HistoryBatchResponse
+	json.NewDecoder(w.Body).Decode(&batch)
+	if len(batch.Items) != 1 || batch.Items[0].ImagePath != "abc.png" {
+		t.Errorf("Batch with image=original = %+v, expected original image", batch.Items)
+	}
+
+	if code, _ := list("?image=thumbnail"); code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for an invalid image flag, got %d", code)
+	}
+
+	// Without public uploads both variants are fetched through the image endpoint
+	handler.SetPublicUploads(false)
+	_, response = list("")
+	imageURL := "/history/7c9e6679-7425-40de-944b-e07fc1f90ae7/image"
+	if item := response.Items[0]; item.ImagePath != imageURL || item.OriginalImagePath != imageURL+"?image=original" {
+		t.Errorf("List item without public uploads = %+v, expected image endpoint URLs", item)
+	}
+	if item := response.Items[1]; item.ImagePath != "/history/id-2/image" || item.OriginalImagePath != "" {
+		t.Errorf("List item without optimized copy or public uploads = %+v, expected image endpoint URL", item)
+	}
+	_, response = list("?image=original")
+	if item := response.Items[0]; item.ImagePath != imageURL+"?image=original" || item.OriginalImagePath != "" {
+		t.Errorf("List item with image=original without public uploads = %+v, expected original endpoint URL", item)
+	}
+}
+
+func TestHistoryHandlerRejectsMalformedIDs(t *testing.T) {
+	identification := &db.Identification{ID: "7c9e6679-7425-40de-944b-e07fc1f90ae7", Genus: "Haworthia"}
+	handler := NewHistoryHandler(&mockIdentificationRepository{getByIDResult: identification}, &mockChatRepository{})
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		handle http.HandlerFunc
+	}{
+		{name: "Get by ID", method: http.MethodGet, path: "/history/not-a-uuid", handle: handler.HandleGetByID},
+		{name: "Get care", method: http.MethodGet, path: "/history/12345/care", handle: handler.HandleGetCare},
+		{name: "Get with chat", method: http.MethodGet, path: "/history/x'%20OR%20'1'='1/with-chat", handle: handler.HandleGetWithChat},
+		{name: "Get chat history", method: http.MethodGet, path: "/chat/7c9e6679742540de944be07fc1f90ae7", handle: handler.HandleGetChatHistory},
+		{name: "Delete", method: http.MethodDelete, path: "/history/{7c9e6679-7425-40de-944b-e07fc1f90ae7}", handle: handler.HandleDelete},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			tt.handle(rr, httptest.NewRequest(tt.method, tt.path, nil))
+
+			if rr.Code != http.StatusBadRequest {
+				t.Fatalf("Expected status 400, got %d: %s", rr.Code, rr.Body.String())
+			}
+			var response models.ErrorResponse
+			if err := json.NewDecoder(rr.Body).Decode(&response); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+			if response.Message != "Invalid identification ID" {
+				t.Errorf("Expected invalid ID message, got %q", response.Message)
+			}
+		})
+	}
+}
+
+func TestHistoryHandlerHandleGetImage(t *testing.T) {
+	dir := t.TempDir()
+	originalPath := filepath.Join(dir, "abc.png")
+	optimizedPath := originalPath + ".web.jpg"
+	os.WriteFile(originalPath, []byte("original"), 0644)
+	os.WriteFile(optimizedPath, []byte("optimized"), 0644)
+
+	tests := []struct {
+		name           string
+		path           string
+		identification *db.Identification
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			name:           "Optimized copy by default",
+			path:           "/history/7c9e6679-7425-40de-944b-e07fc1f90ae7/image",
+			identification: &db.Identification{ImagePath: originalPath, OptimizedImagePath: optimizedPath},
+			expectedStatus: http.StatusOK,
+			expectedBody:   "optimized",
+		},
+		{
+			name:           "Original on request",
+			path:           "/history/7c9e6679-7425-40de-944b-e07fc1f90ae7/image?image=original",
+			identification: &db.Identification{ImagePath: originalPath, OptimizedImagePath: optimizedPath},
+			expectedStatus: http.StatusOK,
+			expectedBody:   "original",
+		},
+		{
+			name:           "Original without optimized copy",
+			path:           "/history/7c9e6679-7425-40de-944b-e07fc1f90ae7/image",
+			identification: &db.Identification{ImagePath: originalPath},
+			expectedStatus: http.StatusOK,
+			expectedBody:   "original",
+		},
+		{
+			name:           "Missing file",
+			path:           "/history/7c9e6679-7425-40de-944b-e07fc1f90ae7/image",
+			identification: &db.Identification{ImagePath: filepath.Join(dir, "gone.png")},
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			name:           "Invalid image variant",
+			path:           "/history/7c9e6679-7425-40de-944b-e07fc1f90ae7/image?image=thumbnail",
+			identification: &db.Identification{ImagePath: originalPath},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "Invalid ID",
+			path:           "/history/not-a-uuid/image",
+			expectedStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := NewHistoryHandler(&mockIdentificationRepository{getByIDResult: tt.identification}, &mockChatRepository{})
+
+			w := httptest.NewRecorder()
+			handler.HandleGetImage(w, httptest.NewRequest(http.MethodGet, tt.path, nil))
+
+			if w.Code != tt.expectedStatus {
+				t.Fatalf("Expected status %d, got %d: %s", tt.expectedStatus, w.Code, w.Body.String())
+			}
+			if tt.expectedBody != "" && w.Body.String() != tt.expectedBody {
+				t.Errorf("Expected body %q, got %q", tt.expectedBody, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestHistoryHandlerHandleGrouped(t *testing.T) {
+	identifications := []db.Identification{
+		{ID: "1", Genus: "Echeveria", Species: "echeveria_elegans", ImagePath: "/uploads/1.jpg", CreatedAt: time.Date(2026, 3, 2, 0, 0, 0, 0, time.UTC)},
+		{ID: "2", Genus: "Echeveria", Species: "echeveria_elegans", ImagePath: "/uploads/2.jpg", CreatedAt: time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)},
+		{ID: "3", Genus: "Haworthia", Species: "haworthia_zebrina", ImagePath: "/uploads/3.jpg", CreatedAt: time.Date(2026, 3, 3, 0, 0, 0, 0, time.UTC)},
+		{ID: "4", Genus: "Opuntia", ImagePath: "/uploads/4.jpg", CreatedAt: time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC)},
+	}
+
+	t.Run("Groups by genus", func(t *testing.T) {
+		handler := NewHistoryHandler(&mockIdentificationRepository{getAllResult: identifications}, &mockChatRepository{})
+
+		w := httptest.NewRecorder()
+		handler.HandleGrouped(w, httptest.NewRequest(http.MethodGet, "/history/grouped", nil))
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+		}
+		var response models.HistoryGroupedResponse
+		if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		if response.TotalGenera != 3 || len(response.Groups) != 3 {
+			t.Fatalf("Expected 3 genera, got total %d with %d groups", response.TotalGenera, len(response.Groups))
+		}
+
+		expected := []struct {
+			genus      string
+			count      int
+			mostRecent string
+		}{
+			{"Echeveria", 2, "1"},
+			{"Haworthia", 1, "3"},
+			{"Opuntia", 1, "4"},
+		}
+		for i, want := range expected {
+			group := response.Groups[i]
+			if group.Genus != want.genus || group.Count != want.count || len(group.Items) != want.count {
+				t.Errorf("Group %d: expected %s with %d items, got %s with count %d and %d items", i, want.genus, want.count, group.Genus, group.Count, len(group.Items))
+			}
+			if group.MostRecent.ID != want.mostRecent {
+				t.Errorf("Group %s: expected most recent %s, got %s", group.Genus, want.mostRecent, group.MostRecent.ID)
+			}
+		}
+		if response.Groups[0].Items[1].ID != "2" {
+			t.Errorf("Expected older Echeveria identification second, got %s", response.Groups[0].Items[1].ID)
+		}
+	})
+
+	t.Run("Paginates over genera", func(t *testing.T) {
+		handler := NewHistoryHandler(&mockIdentificationRepository{getAllResult: identifications}, &mockChatRepository{})
+
+		w := httptest.NewRecorder()
+		handler.HandleGrouped(w, httptest.NewRequest(http.MethodGet, "/history/grouped?limit=1&offset=1", nil))
+
+		var response models.HistoryGroupedResponse
+		json.NewDecoder(w.Body).Decode(&response)
+		if response.TotalGenera != 3 || len(response.Groups) != 1 || response.Groups[0].Genus != "Haworthia" {
+			t.Errorf("Expected only Haworthia of 3 genera, got %+v", response)
+		}
+	})
+
+	t.Run("Offset past the last genus", func(t *testing.T) {
+		handler := NewHistoryHandler(&mockIdentificationRepository{getAllResult: identifications}, &mockChatRepository{})
+
+		for _, offset := range []string{"10", "9223372036854775807"} {
+			w := httptest.NewRecorder()
+			handler.HandleGrouped(w, httptest.NewRequest(http.MethodGet, "/history/grouped?offset="+offset, nil))
+
+			var response models.HistoryGroupedResponse
+			json.NewDecoder(w.Body).Decode(&response)
+			if w.Code != http.StatusOK || response.Groups == nil || len(response.Groups) != 0 || response.TotalGenera != 3 {
+				t.Errorf("Expected 200 with empty groups for offset %s, got %d: %+v", offset, w.Code, response)
+			}
+		}
+	})
+
+	t.Run("Invalid pagination", func(t *testing.T) {
+		handler := NewHistoryHandler(&mockIdentificationRepository{getAllResult: identifications}, &mockChatRepository{})
+
+		for _, query := range []string{"offset=-1", "offset=99999999999999999999", "offset=abc", "limit=0", "limit=-5"} {
+			w := httptest.NewRecorder()
+			handler.HandleGrouped(w, httptest.NewRequest(http.MethodGet, "/history/grouped?"+query, nil))
+			if w.Code != http.StatusBadRequest {
+				t.Errorf("Expected status 400 for %s, got %d", query, w.Code)
+			}
+		}
+	})
+
+	t.Run("Database error", func(t *testing.T) {
+		handler := NewHistoryHandler(&mockIdentificationRepository{getAllErr: errors.New("db down")}, &mockChatRepository{})
+
+		w := httptest.NewRecorder()
+		handler.HandleGrouped(w, httptest.NewRequest(http.MethodGet, "/history/grouped", nil))
+
+		if w.Code != http.StatusInternalServerError {
+			t.Errorf("Expected status 500, got %d", w.Code)
+		}
+	})
+}
@@ -1,26 +1,211 @@
 package handlers
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"log"
+	"math"
 	"net/http"
+	"os"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/google/uuid"
 	"succulent-identifier-backend/db"
 	"succulent-identifier-backend/models"
+	"succulent-identifier-backend/services"
 	"succulent-identifier-backend/utils"
 )
 
 // IdentifyHandler handles plant identification requests
 type IdentifyHandler struct {
-	mlClient               MLClientInterface
-	chatService            ChatServiceInterface
-	careRepo               CareInstructionsRepositoryInterface
-	fileUploader           FileUploaderInterface
-	identificationRepo     IdentificationRepositoryInterface
-	speciesThreshold       float64
+	mlClient           MLClientInterface
+	chatService        ChatServiceInterface
+	careRepo           CareInstructionsRepositoryInterface
+	careData           CareDataServiceInterface
+	fileUploader       FileUploaderInterface
+	identificationRepo IdentificationRepositoryInterface
+	speciesThreshold   float64
+	labelDelimiter     string // separates genus and species in ML labels
+
+	// singleTokenSpecies lists labels without a delimiter that name a full
+	// species rather than a genus ("*" for all of them)
+	singleTokenSpecies []string
+
+	// similarImageDistance is the max Hamming distance between perceptual
+	// hashes for an upload to be flagged as a near-duplicate (0 disables)
+	similarImageDistance int
+
+	// asyncCare returns identifications before care is generated on a cache miss
+	asyncCare bool
+	careJobs  sync.WaitGroup // in-flight background care generations
+
+	// careWebhookURL is sent the care status of an identification once its
+	// care is generated in the background (empty disables)
+	careWebhookURL    string
+	careWebhookClient *http.Client
+
+	// careConcurrency bounds parallel care generations within a batch request
+	careConcurrency int
+
+	// siblingCareCount of the most identified other species of an identified
+	// plant's genus get care generated in the background (0 disables), only
+	// for siblingCareGenera when it is not empty. siblingWarming holds the
+	// canonical keys being warmed, so concurrent identifications of a genus
+	// do not generate the same care twice, and siblingWarmedAt when each
+	// genus was last warmed, so counting runs once per siblingWarmInterval.
+	siblingCareCount  int
+	siblingCareGenera map[string]bool
+	siblingCounts     SpeciesCountRepositoryInterface
+	siblingMu         sync.Mutex
+	siblingWarming    map[careKey]bool
+	siblingWarmedAt   map[string]time.Time
+
+	// pinnedGenera always use curated static care, bypassing the cache and LLM
+	pinnedGenera map[string]bool
+
+	// staticCarePatterns are lowercase label globs ("haworthia_*") whose care,
+	// like that of pinned genera, is never generated
+	staticCarePatterns []string
+
+	// careSourceOrder is the order care sources are tried in; sources after
+	// the LLM are its fallback when generation fails or is disabled
+	careSourceOrder []string
+
+	// blockedLabels are genera ("euphorbia") or species ("aloe_vera") that are
+	// never reported as an identification, e.g. non-succulents or offensive labels
+	blockedLabels []string
+
+	// confidenceFloor is the top confidence below which a result is reported
+	// as uncertain instead of as a genus (0 disables)
+	confidenceFloor float64
+
+	// mlHealth, when set, makes identify requests fail fast while the ML service is down
+	mlHealth MLHealthInterface
+
+	// defaultAlternatives is how many runner-up predictions a confident result
+	// lists when the request has no ?alternatives=N; maxAlternatives caps N
+	defaultAlternatives int
+	maxAlternatives     int
+
+	// regenerateCooldown is the minimum time between care regenerations of
+	// the same species, measured from the cache entry's updated_at (0 disables)
+	regenerateCooldown time.Duration
+
+	// reidentifyDedupe updates an identification's timestamp instead of saving
+	// a new record when re-identifying it gives the same species with a
+	// confidence within reidentifyTolerance of the stored one
+	reidentifyDedupe    bool
+	reidentifyTolerance float64
+
+	// dedupWindow returns a client's earlier identification of the same
+	// species instead of saving a new one when made within it (0 disables)
+	dedupWindow time.Duration
+
+	// retryInference runs inference once more on the saved image when the
+	// first attempt fails transiently, for ML clients without their own retries
+	retryInference bool
+
+	// careMetrics, when set, counts care cache hits and misses
+	careMetrics CareCacheMetricsInterface
+
+	// commonNames resolves common names to labels for care lookups (nil disables)
+	commonNames CommonNamesInterface
+
+	// failures, when set, records identification attempts whose inference failed
+	failures FailedIdentificationRepositoryInterface
+
+	// timings, when set, stores how long each step of an identify request took
+	timings TimingRecorderInterface
+}
+
+// notIdentifiedMessage is returned when the identified plant is on the blocklist
+const notIdentifiedMessage = "The plant in this image could not be identified"
+
+// mlUnavailableMessage is returned while the last ML service health check failed
+const mlUnavailableMessage = "Identification service temporarily unavailable, please try again later"
+
+// defaultRegenerateCooldown is the per-species care regeneration cooldown when not configured
+const defaultRegenerateCooldown = time.Hour
+
+// defaultReidentifyTolerance is the confidence difference under which a
+// re-identification counts as unchanged when not configured
+const defaultReidentifyTolerance = 0.01
+
+// inferenceRetryDelay is the pause before retrying a transiently failed inference
+var inferenceRetryDelay = 500 * time.Millisecond
+
+// careWebhookTimeout bounds a single care webhook delivery
+const careWebhookTimeout = 10 * time.Second
+
+// siblingWarmInterval is how often the siblings of a genus are warmed at most,
+// since finding them counts every identification
+const siblingWarmInterval = 10 * time.Minute
+
+// defaultCareConcurrency is the batch care generation worker count when not configured
+const defaultCareConcurrency = 4
+
+// Care sources, tried in the order configured with SetCareSourceOrder
+const (
+	careSourceStatic = "static" // curated static care data
+	careSourceCache  = "cache"  // cached LLM care
+	careSourceLLM    = "llm"    // newly generated LLM care, cached for later lookups
+)
+
+// defaultCareSourceOrder reads the cache, then generates care, falling back
+// to static care data when generation fails
+var defaultCareSourceOrder = []string{careSourceCache, careSourceLLM, careSourceStatic}
+
+// maxUncertainCandidates is how many predictions an uncertain response lists
+const maxUncertainCandidates = 3
+
+// maxBatchImages caps the number of images in a single batch identify request
+const maxBatchImages = 10
+
+// Error codes of images that failed within a batch identify request
+const (
+	batchErrorReadFailed       = "read_failed"       // the uploaded part could not be read
+	batchErrorUploadRejected   = "upload_rejected"   // file validation or virus scan rejected it
+	batchErrorScanUnavailable  = "scan_unavailable"  // the virus scanner could not be reached
+	batchErrorInferenceFailed  = "inference_failed"  // the ML service could not identify it
+	batchErrorProcessingFailed = "processing_failed" // the result could not be processed
+)
+
+// processOptions carries per-request inputs to processMLResponse beyond the ML output
+type processOptions struct {
+	imageHash     *int64            // perceptual hash of the uploaded image, nil if it could not be computed
+	imageMetadata *db.ImageMetadata // dimensions, format, size and capture time of the uploaded image
+	optimizedPath string            // optimized web copy of the uploaded image, "" if none
+	careGuide     *db.CareGuide     // care already resolved by the caller (batch mode), skips cache and LLM
+	alternatives  int               // runner-up predictions to list on a confident result
+	parentID      string            // first identification of the same multi-plant image, "" if none
+	boundingBox   *db.BoundingBox   // where the plant was detected in a multi-plant image
+	clientID      string            // IP of the uploading client, "" skips the dedup window
+	timing        *db.RequestTiming // accumulates care and save durations, nil when not recorded
+	keepImage     bool              // the image is referenced beyond this result and is never discarded
+}
+
+// batchItem is an image of a batch request that was saved and inferred
+type batchItem struct {
+	index            int // position among the uploaded images
+	imagePath        string
+	mlResponse       *models.MLInferenceResponse
+	opts             processOptions
+	key              careKey
+	duplicateWarning *models.DuplicateWarning
+}
+
+// careKey identifies a care guide by genus and species
+type careKey struct {
+	genus   string
+	species string
 }
 
 // NewIdentifyHandler creates a new identify handler
@@ -28,31 +213,327 @@ func NewIdentifyHandler(
 	mlClient MLClientInterface,
 	chatService ChatServiceInterface,
 	careRepo CareInstructionsRepositoryInterface,
+	careData CareDataServiceInterface,
 	fileUploader FileUploaderInterface,
 	identificationRepo IdentificationRepositoryInterface,
 	speciesThreshold float64,
 ) *IdentifyHandler {
 	return &IdentifyHandler{
-		mlClient:               mlClient,
-		chatService:            chatService,
-		careRepo:               careRepo,
-		fileUploader:           fileUploader,
-		identificationRepo:     identificationRepo,
-		speciesThreshold:       speciesThreshold,
+		mlClient:           mlClient,
+		chatService:        chatService,
+		careRepo:           careRepo,
+		careData:           careData,
+		fileUploader:       fileUploader,
+		identificationRepo: identificationRepo,
+		speciesThreshold:   speciesThreshold,
+		labelDelimiter:     utils.DefaultLabelDelimiter,
+		careConcurrency:    defaultCareConcurrency,
+		careSourceOrder:    defaultCareSourceOrder,
+		regenerateCooldown: defaultRegenerateCooldown,
+
+		reidentifyDedupe:    true,
+		reidentifyTolerance: defaultReidentifyTolerance,
+	}
+}
+
+// SetLabelDelimiter configures the separator between genus and species in ML labels
+func (h *IdentifyHandler) SetLabelDelimiter(delimiter string) {
+	if delimiter == "" {
+		delimiter = utils.DefaultLabelDelimiter
+	}
+	h.labelDelimiter = delimiter
+}
+
+// SetSingleTokenSpecies configures single-token labels ("lithops") treated as
+// full species names instead of genus-only labels; "*" matches every one
+func (h *IdentifyHandler) SetSingleTokenSpecies(labels []string) {
+	h.singleTokenSpecies = labels
+}
+
+// SetSimilarImageDistance configures near-duplicate detection (0 disables it)
+func (h *IdentifyHandler) SetSimilarImageDistance(distance int) {
+	h.similarImageDistance = distance
+}
+
+// SetAsyncCare enables returning identifications immediately while care is
+// generated in the background
+func (h *IdentifyHandler) SetAsyncCare(enabled bool) {
+	h.asyncCare = enabled
+}
+
+// SetCareWebhook configures a URL that is POSTed the care status of an
+// identification, like GET /history/{id}/care returns it, once care generated
+// in the background is stored. An empty URL disables it.
+func (h *IdentifyHandler) SetCareWebhook(url string) {
+	h.careWebhookURL = url
+	h.careWebhookClient = &http.Client{Timeout: careWebhookTimeout}
+}
+
+// WaitForCareJobs blocks until every background care generation has finished
+// or timeout passes, and reports whether they all finished
+func (h *IdentifyHandler) WaitForCareJobs(timeout time.Duration) bool {
+	done := make(chan struct{})
+	go func() {
+		h.careJobs.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return true
+	case <-time.After(timeout):
+		return false
+	}
+}
+
+// SetCareConcurrency configures how many care guides a batch request generates in parallel
+func (h *IdentifyHandler) SetCareConcurrency(workers int) {
+	if workers < 1 {
+		workers = 1
+	}
+	h.careConcurrency = workers
+}
+
+// SetConfidenceFloor configures the top confidence below which identifications
+// are reported as uncertain without care instructions (0 disables)
+func (h *IdentifyHandler) SetConfidenceFloor(floor float64) {
+	h.confidenceFloor = floor
+}
+
+// SetPinnedGenera configures genera whose care always comes from the curated
+// static data instead of the LLM cache, e.g. where generated advice is known to be poor
+func (h *IdentifyHandler) SetPinnedGenera(genera []string) {
+	h.pinnedGenera = make(map[string]bool, len(genera))
+	for _, genus := range genera {
+		h.pinnedGenera[strings.ToLower(strings.TrimSpace(genus))] = true
+	}
+}
+
+// SetBlockedLabels configures genera and species that are reported as not
+// identified, without care. Entries are labels in the ML label format; a bare
+// genus blocks every species of it.
+func (h *IdentifyHandler) SetBlockedLabels(labels []string) {
+	h.blockedLabels = make([]string, 0, len(labels))
+	for _, label := range labels {
+		if label = strings.ToLower(strings.TrimSpace(label)); label != "" {
+			h.blockedLabels = append(h.blockedLabels, label)
+		}
+	}
+}
+
+// SetCareSourceOrder configures the order care is resolved from the static
+// care data, the cache and the LLM, e.g. "static", "cache", "llm". Leaving
+// out "llm" never generates care. Generic care is used when no source has any.
+func (h *IdentifyHandler) SetCareSourceOrder(order []string) error {
+	if len(order) == 0 {
+		return fmt.Errorf("care source order is empty")
+	}
+	sources := make([]string, 0, len(order))
+	for _, source := range order {
+		source = strings.ToLower(strings.TrimSpace(source))
+		switch source {
+		case careSourceStatic, careSourceCache, careSourceLLM:
+		default:
+			return fmt.Errorf("unknown care source %q (expected static, cache or llm)", source)
+		}
+		if slices.Contains(sources, source) {
+			return fmt.Errorf("care source %q is listed twice", source)
+		}
+		sources = append(sources, source)
+	}
+	h.careSourceOrder = sources
+	return nil
+}
+
+// SetSiblingCareWarm enables generating care in the background for the count
+// most identified other species of a genus whenever a species of it is
+// identified, so browsing similar plants is instant. Only genera are warmed
+// when the list is not empty. A count of 0 disables it.
+func (h *IdentifyHandler) SetSiblingCareWarm(counts SpeciesCountRepositoryInterface, count int, genera []string) {
+	h.siblingCounts = counts
+	h.siblingCareCount = count
+	h.siblingCareGenera = make(map[string]bool, len(genera))
+	for _, genus := range genera {
+		if genus = strings.ToLower(strings.TrimSpace(genus)); genus != "" {
+			h.siblingCareGenera[genus] = true
+		}
+	}
+	h.siblingWarming = map[careKey]bool{}
+	h.siblingWarmedAt = map[string]time.Time{}
+}
+
+// SetStaticCarePatterns configures glob patterns (path.Match syntax, e.g.
+// "haworthia_*") of ML labels whose care always comes from the curated static
+// data or generic care, never from the LLM. Invalid patterns are ignored.
+func (h *IdentifyHandler) SetStaticCarePatterns(patterns []string) {
+	h.staticCarePatterns = make([]string, 0, len(patterns))
+	for _, pattern := range patterns {
+		pattern = strings.ToLower(strings.TrimSpace(pattern))
+		if pattern == "" {
+			continue
+		}
+		if _, err := path.Match(pattern, ""); err != nil {
+			log.Printf("Warning: ignoring invalid static care pattern %q: %v", pattern, err)
+			continue
+		}
+		h.staticCarePatterns = append(h.staticCarePatterns, pattern)
+	}
+}
+
+// SetFailureRecorder enables persisting identification attempts whose
+// inference failed, with the image path and error, for later analysis
+func (h *IdentifyHandler) SetFailureRecorder(failures FailedIdentificationRepositoryInterface) {
+	h.failures = failures
+}
+
+// SetTimingRecorder enables storing the step durations (upload, inference,
+// care, save) of each identify request with its identification
+func (h *IdentifyHandler) SetTimingRecorder(timings TimingRecorderInterface) {
+	h.timings = timings
+}
+
+// SetMLHealth configures the ML service health source used to reject identify
+// requests with 503 while the service is down
+func (h *IdentifyHandler) SetMLHealth(mlHealth MLHealthInterface) {
+	h.mlHealth = mlHealth
+}
+
+// SetRegenerateCooldown configures how often care for the same species can be
+// regenerated, bounding LLM costs of repeated regenerate requests (0 disables)
+func (h *IdentifyHandler) SetRegenerateCooldown(cooldown time.Duration) {
+	h.regenerateCooldown = cooldown
+}
+
+// SetCareCacheMetrics records care cache hits and misses in metrics
+func (h *IdentifyHandler) SetCareCacheMetrics(metrics CareCacheMetricsInterface) {
+	h.careMetrics = metrics
+}
+
+// SetCommonNames enables looking up care by a plant's common name
+func (h *IdentifyHandler) SetCommonNames(commonNames CommonNamesInterface) {
+	h.commonNames = commonNames
+}
+
+// SetInferenceRetry enables retrying a transiently failed inference once
+func (h *IdentifyHandler) SetInferenceRetry(enabled bool) {
+	h.retryInference = enabled
+}
+
+// SetReidentifyDedupe configures whether an unchanged re-identification
+// updates the existing record instead of creating a new one, and the max
+// confidence difference for a result to count as unchanged
+func (h *IdentifyHandler) SetReidentifyDedupe(enabled bool, tolerance float64) {
+	h.reidentifyDedupe = enabled
+	if tolerance >= 0 {
+		h.reidentifyTolerance = tolerance
+	}
+}
+
+// SetDedupWindow makes repeat identifications of the same species by the
+// same client within window return the earlier record (0 disables)
+func (h *IdentifyHandler) SetDedupWindow(window time.Duration) {
+	h.dedupWindow = window
+}
+
+// SetAlternatives configures how many runner-up predictions are returned by
+// default and the most a client can request with ?alternatives=N
+func (h *IdentifyHandler) SetAlternatives(defaultCount, maxCount int) {
+	h.maxAlternatives = max(maxCount, 0)
+	h.defaultAlternatives = min(max(defaultCount, 0), h.maxAlternatives)
+}
+
+// alternativesCount reads ?alternatives=N, falling back to the configured
+// default and capping N at the configured max
+func (h *IdentifyHandler) alternativesCount(r *http.Request) (int, error) {
+	value := r.URL.Query().Get("alternatives")
+	if value == "" {
+		return h.defaultAlternatives, nil
+	}
+	count, err := strconv.Atoi(value)
+	if err != nil || count < 0 {
+		return 0, fmt.Errorf("alternatives must be a non-negative integer")
+	}
+	return min(count, h.maxAlternatives), nil
+}
+
+// parseMultipartForm parses the multipart request body, sending an error
+// response and returning false when it cannot. Non-multipart bodies such as
+// JSON get 415 so clients can tell a wrong Content-Type from a corrupt upload.
+func (h *IdentifyHandler) parseMultipartForm(w http.ResponseWriter, r *http.Request, maxMemory int64) bool {
+	err := r.ParseMultipartForm(maxMemory)
+	switch {
+	case err == nil:
+		return true
+	case errors.Is(err, http.ErrNotMultipart):
+		contentType := r.Header.Get("Content-Type")
+		if contentType == "" {
+			contentType = "none"
+		}
+		h.sendError(w, http.StatusUnsupportedMediaType, fmt.Sprintf("Request body must be multipart/form-data with the image as a file part, got Content-Type %s", contentType))
+	case errors.Is(err, http.ErrMissingBoundary):
+		h.sendError(w, http.StatusBadRequest, "Malformed multipart body: Content-Type is missing the boundary parameter")
+	default:
+		log.Printf("Failed to parse multipart form: %v", err)
+		h.sendError(w, http.StatusBadRequest, "Malformed multipart body, could not read form data")
+	}
+	return false
+}
+
+// uploadErrorStatus returns the status for a failed upload save; an unavailable
+// virus scanner is not the client's fault
+func uploadErrorStatus(err error) int {
+	if errors.Is(err, utils.ErrScanFailed) {
+		return http.StatusServiceUnavailable
 	}
+	return http.StatusBadRequest
+}
+
+// mlAvailable reports whether the last ML service health check succeeded
+func (h *IdentifyHandler) mlAvailable() bool {
+	return h.mlHealth == nil || h.mlHealth.Status().Healthy
 }
 
 // Handle processes the identify request
 func (h *IdentifyHandler) Handle(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+
 	// Only accept POST requests
 	if r.Method != http.MethodPost {
 		h.sendError(w, http.StatusMethodNotAllowed, "Method not allowed")
 		return
 	}
 
+	// Don't save the upload when it cannot be identified anyway
+	if !h.mlAvailable() {
+		h.sendError(w, http.StatusServiceUnavailable, mlUnavailableMessage)
+		return
+	}
+
+	alternatives, err := h.alternativesCount(r)
+	if err != nil {
+		h.sendError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	schemaVersion, err := identifySchemaVersion(r)
+	if err != nil {
+		h.sendError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	summary, err := careSummaryRequested(r)
+	if err != nil {
+		h.sendError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
 	// Parse multipart form
-	if err := r.ParseMultipartForm(10 << 20); err != nil { // 10 MB max
-		h.sendError(w, http.StatusBadRequest, "Failed to parse form data")
+	uploadStart := time.Now()
+	if !h.parseMultipartForm(w, r, 10<<20) { // 10 MB max
+		return
+	}
+
+	// FormFile would silently use the first of several images
+	if len(r.MultipartForm.File["image"]) > 1 {
+		h.sendError(w, http.StatusBadRequest, "Only one image can be identified per request, use /identify/batch with \"images\" parts for several")
 		return
 	}
 
@@ -65,154 +546,1636 @@ func (h *IdentifyHandler) Handle(w http.ResponseWriter, r *http.Request) {
 	defer file.Close()
 
 	// Save uploaded file
-	imagePath, err := h.fileUploader.SaveFile(file, fileHeader)
+	imagePath, imageMetadata, err := h.fileUploader.SaveFile(file, fileHeader)
 	if err != nil {
 		log.Printf("File upload error: %v", err)
-		h.sendError(w, http.StatusBadRequest, err.Error())
+		h.sendError(w, uploadErrorStatus(err), err.Error())
 		return
 	}
 
 	// Optional: Clean up file after processing (can be configured)
 	// defer h.fileUploader.DeleteFile(imagePath)
 
+	// Compute perceptual hash so near-duplicate uploads can be detected
+	opts := processOptions{
+		imageHash:     hashImage(imagePath),
+		imageMetadata: imageMetadataRecord(imageMetadata),
+		optimizedPath: h.saveOptimized(imagePath),
+		alternatives:  alternatives,
+		clientID:      utils.ClientIP(r),
+	}
+	if h.timings != nil {
+		opts.timing = &db.RequestTiming{UploadMs: elapsedMs(uploadStart)}
+	}
+
 	// Call ML service for inference
-	mlResponse, err := h.mlClient.Infer(imagePath)
+	inferenceStart := time.Now()
+	mlResponse, err := h.infer(imagePath)
 	if err != nil {
 		log.Printf("ML inference error: %v", err)
+		h.recordFailure(imagePath, err)
 		h.sendError(w, http.StatusInternalServerError, "Failed to identify plant")
 		return
 	}
+	if opts.timing != nil {
+		opts.timing.InferenceMs = elapsedMs(inferenceStart)
+	}
+
+	// Look for earlier uploads of the same plant before saving this one
+	duplicateWarning := h.findSimilar(r, opts.imageHash)
 
 	// Process predictions with confidence threshold logic
-	response, err := h.processMLResponse(mlResponse, imagePath)
+	response, err := h.processMLResponse(mlResponse, imagePath, opts)
 	if err != nil {
 		log.Printf("Processing error: %v", err)
 		h.sendError(w, http.StatusInternalServerError, err.Error())
 		return
 	}
+	response.DuplicateWarning = duplicateWarning
+	if opts.timing != nil {
+		opts.timing.TotalMs = elapsedMs(start)
+		h.recordTiming(response, opts.timing)
+	}
+	if summary {
+		withCareSummary(response.Care)
+	}
 
 	// Send successful response
 	w.Header().Set("Content-Type", "application/json")
+	w.Header().Add("Vary", "Accept")
 	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(response)
+	json.NewEncoder(w).Encode(versionedIdentifyResponse(response, schemaVersion))
 }
 
-// processMLResponse processes ML predictions and applies confidence threshold logic
-func (h *IdentifyHandler) processMLResponse(mlResponse *models.MLInferenceResponse, imagePath string) (*models.IdentifyResponse, error) {
-	// Get top prediction
-	topPrediction := mlResponse.Predictions[0]
+// HandleQuick identifies only the genus of an image for a fast preview. The
+// upload is deleted after inference; nothing is saved and no care is resolved.
+func (h *IdentifyHandler) HandleQuick(w http.ResponseWriter, r *http.Request) {
+	// Only accept POST requests
+	if r.Method != http.MethodPost {
+		h.sendError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
 
-	// Parse label to extract genus and species
-	genus, species := utils.ParseLabel(topPrediction.Label)
+	// Don't save the upload when it cannot be identified anyway
+	if !h.mlAvailable() {
+		h.sendError(w, http.StatusServiceUnavailable, mlUnavailableMessage)
+		return
+	}
 
-	// Apply confidence threshold logic
-	var displaySpecies string
-	if topPrediction.Confidence >= h.speciesThreshold && species != "" {
-		// High confidence: show species
-		displaySpecies = utils.FormatSpecies(topPrediction.Label)
+	// Parse multipart form
+	if !h.parseMultipartForm(w, r, 10<<20) { // 10 MB max
+		return
 	}
 
-	// Get care instructions with caching strategy
-	var careGuide *db.CareGuide
+	// Get uploaded file
+	file, fileHeader, err := r.FormFile("image")
+	if err != nil {
+		h.sendError(w, http.StatusBadRequest, "No image file provided")
+		return
+	}
+	defer file.Close()
 
-	// Check cache first
-	cachedCare, err := h.careRepo.GetBySpecies(genus, species)
+	// The ML service reads the image from the upload directory
+	imagePath, _, err := h.fileUploader.SaveFile(file, fileHeader)
 	if err != nil {
-		log.Printf("Error checking care cache: %v", err)
+		log.Printf("File upload error: %v", err)
+		h.sendError(w, uploadErrorStatus(err), err.Error())
+		return
 	}
+	defer h.fileUploader.DeleteFile(imagePath)
 
-	if cachedCare != nil {
-		// Use cached care instructions
-		log.Printf("Using cached care instructions for %s %s", genus, species)
-		careGuide = cachedCare.CareGuide
-	} else {
-		// Generate new care instructions with LLM
-		log.Printf("Generating new care instructions for %s %s", genus, species)
-		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-		defer cancel()
+	mlResponse, err := h.infer(imagePath)
+	if err != nil {
+		log.Printf("ML inference error: %v", err)
+		h.sendError(w, http.StatusInternalServerError, "Failed to identify plant")
+		return
+	}
 
-		careGuide, err = h.chatService.GenerateCareInstructions(ctx, genus, species)
-		if err != nil {
-			log.Printf("Failed to generate care instructions: %v", err)
-			// Fallback to generic instructions if LLM fails
-			careGuide = &db.CareGuide{
-				Sunlight: "Provide bright, indirect light for most succulents.",
-				Watering: "Water when soil is completely dry. Succulents prefer infrequent, deep watering.",
-				Soil:     "Use well-draining cactus or succulent mix.",
-				Notes:    "Care information could not be generated. These are general succulent care guidelines.",
-			}
-		} else {
-			// Save to cache for future use
-			cacheEntry := &db.CareInstructionsCache{
-				ID:        uuid.New().String(),
-				Genus:     genus,
-				Species:   species,
-				CareGuide: careGuide,
-				CreatedAt: time.Now(),
-				UpdatedAt: time.Now(),
-			}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(h.quickResponse(mlResponse.Predictions))
+}
 
-			if err := h.careRepo.Create(cacheEntry); err != nil {
-				log.Printf("Failed to cache care instructions: %v", err)
-				// Don't fail the request, just log the error
-			} else {
-				log.Printf("Care instructions cached for %s %s", genus, species)
-			}
+// quickResponse reports the genus with the highest summed confidence over
+// its species' predictions
+func (h *IdentifyHandler) quickResponse(predictions []models.MLPrediction) models.QuickIdentifyResponse {
+	confidences := map[string]float64{}
+	var best string
+	for _, prediction := range predictions {
+		if h.isBlocked(prediction.Label) {
+			continue
+		}
+		genus, _ := h.parseLabel(prediction.Label)
+		confidences[genus] += prediction.Confidence
+		if best == "" || confidences[genus] > confidences[best] {
+			best = genus
 		}
 	}
+	if best == "" {
+		return models.QuickIdentifyResponse{Message: notIdentifiedMessage}
+	}
+	return models.QuickIdentifyResponse{
+		Identified: true,
+		Genus:      utils.FormatGenus(best),
+		Confidence: math.Min(confidences[best], 1),
+	}
+}
 
-	// Convert to response format
-	care := models.CareInstructions{
-		Sunlight: careGuide.Sunlight,
-		Watering: careGuide.Watering,
-		Soil:     careGuide.Soil,
-		Notes:    careGuide.Notes,
-		Trivia:   careGuide.Trivia,
+// HandleBatch identifies several images uploaded as "images" parts in one request.
+// Care is resolved once per distinct species, generating cache misses in parallel.
+// With ?combine=true the images are photos of one plant: a single identification
+// is saved from the image chosen by chooseBatchImage.
+func (h *IdentifyHandler) HandleBatch(w http.ResponseWriter, r *http.Request) {
+	// Only accept POST requests
+	if r.Method != http.MethodPost {
+		h.sendError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
 	}
 
-	// Generate UUID for identification
-	identificationID := uuid.New().String()
+	if !h.mlAvailable() {
+		h.sendError(w, http.StatusServiceUnavailable, mlUnavailableMessage)
+		return
+	}
 
-	// Create identification record for database
-	identification := &db.Identification{
-		ID:         identificationID,
-		Genus:      genus,
-		Species:    species,
-		Confidence: topPrediction.Confidence,
-		ImagePath:  imagePath,
-		CareGuide:  careGuide,
-		CreatedAt:  time.Now(),
+	alternatives, err := h.alternativesCount(r)
+	if err != nil {
+		h.sendError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	summary, err := careSummaryRequested(r)
+	if err != nil {
+		h.sendError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	combine := false
+	if value := r.URL.Query().Get("combine"); value != "" {
+		if combine, err = strconv.ParseBool(value); err != nil {
+			h.sendError(w, http.StatusBadRequest, "combine must be true or false")
+			return
+		}
 	}
 
-	// Save to database
-	if err := h.identificationRepo.Create(identification); err != nil {
-		log.Printf("Failed to save identification to database: %v", err)
-		// Note: We don't fail the request if DB save fails, just log the error
-		// The user still gets their identification result
-	} else {
-		log.Printf("Identification saved to database with ID: %s", identificationID)
+	// Parse multipart form
+	if !h.parseMultipartForm(w, r, 32<<20) { // 32 MB in memory, rest on disk
+		return
 	}
 
-	// Build response
-	response := &models.IdentifyResponse{
-		ID: identificationID,
-		Plant: models.PlantInfo{
-			Genus:      utils.FormatGenus(genus),
-			Species:    displaySpecies,
-			Confidence: topPrediction.Confidence,
-		},
-		Care: care,
+	fileHeaders := r.MultipartForm.File["images"]
+	if len(fileHeaders) == 0 {
+		h.sendError(w, http.StatusBadRequest, "No image files provided")
+		return
+	}
+	if len(fileHeaders) > maxBatchImages {
+		h.sendError(w, http.StatusBadRequest, fmt.Sprintf("At most %d images can be identified at once", maxBatchImages))
+		return
 	}
 
-	return response, nil
-}
+	// A failing image is reported in its own result instead of failing the batch
+	results := make([]models.BatchIdentifyResult, len(fileHeaders))
+	fail := func(index, status int, code, message string) {
+		results[index].Status = status
+		results[index].Error = &models.BatchItemError{Code: code, Message: message}
+	}
 
-// sendError sends an error response
-func (h *IdentifyHandler) sendError(w http.ResponseWriter, statusCode int, message string) {
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(statusCode)
-	json.NewEncoder(w).Encode(models.ErrorResponse{
-		Error:   http.StatusText(statusCode),
-		Message: message,
-	})
+	items := make([]batchItem, 0, len(fileHeaders))
+	for i, fileHeader := range fileHeaders {
+		results[i].Filename = fileHeader.Filename
+
+		file, err := fileHeader.Open()
+		if err != nil {
+			fail(i, http.StatusBadRequest, batchErrorReadFailed, "Failed to read image file")
+			continue
+		}
+		imagePath, imageMetadata, err := h.fileUploader.SaveFile(file, fileHeader)
+		file.Close()
+		if err != nil {
+			log.Printf("File upload error: %v", err)
+			code := batchErrorUploadRejected
+			if errors.Is(err, utils.ErrScanFailed) {
+				code = batchErrorScanUnavailable
+			}
+			fail(i, uploadErrorStatus(err), code, err.Error())
+			continue
+		}
+
+		opts := processOptions{
+			imageHash:     hashImage(imagePath),
+			imageMetadata: imageMetadataRecord(imageMetadata),
+			optimizedPath: h.saveOptimized(imagePath),
+			alternatives:  alternatives,
+		}
+
+		mlResponse, err := h.infer(imagePath)
+		if err != nil {
+			log.Printf("ML inference error for %s: %v", fileHeader.Filename, err)
+			h.recordFailure(imagePath, err)
+			fail(i, http.StatusInternalServerError, batchErrorInferenceFailed, "Failed to identify plant")
+			continue
+		}
+
+		genus, species := h.parseLabel(mlResponse.Predictions[0].Label)
+		items = append(items, batchItem{
+			index:            i,
+			imagePath:        imagePath,
+			mlResponse:       mlResponse,
+			opts:             opts,
+			key:              careKey{genus: genus, species: species},
+			duplicateWarning: h.findSimilar(r, opts.imageHash),
+		})
+	}
+
+	var chosen *models.ChosenImage
+	if combine && len(items) > 0 {
+		item, reason := chooseBatchImage(items)
+		chosen = &models.ChosenImage{Index: item.index, Filename: results[item.index].Filename, Reason: reason}
+
+		// Only the chosen image is kept; the others just took part in the choice
+		for _, other := range items {
+			if other.index != item.index {
+				h.discardUpload(other.imagePath, other.opts.optimizedPath)
+				results[other.index].Status = http.StatusOK
+			}
+		}
+		items = []batchItem{item}
+	}
+
+	// In async mode each identification schedules its own background generation
+	var guides map[careKey]*db.CareGuide
+	if !h.asyncCare {
+		keys := make([]careKey, 0, len(items))
+		for _, item := range items {
+			if h.isUncertain(item.mlResponse.Predictions[0]) || h.isBlocked(item.mlResponse.Predictions[0].Label) {
+				continue // uncertain and blocked results get no care
+			}
+			keys = append(keys, item.key)
+		}
+		guides = h.batchCareGuides(keys)
+	}
+
+	for _, item := range items {
+		item.opts.careGuide = guides[item.key]
+		response, err := h.processMLResponse(item.mlResponse, item.imagePath, item.opts)
+		if err != nil {
+			log.Printf("Processing error: %v", err)
+			fail(item.index, http.StatusInternalServerError, batchErrorProcessingFailed, err.Error())
+			continue
+		}
+		response.SchemaVersion = models.IdentifySchemaCurrent
+		response.DuplicateWarning = item.duplicateWarning
+		if summary {
+			withCareSummary(response.Care)
+		}
+		results[item.index].Status = http.StatusOK
+		results[item.index].IdentifyResponse = response
+	}
+
+	// 200 when every image was identified, 207 Multi-Status when any failed
+	batch := models.BatchIdentifyResponse{Results: results, Chosen: chosen}
+	for _, result := range results {
+		if result.Error != nil {
+			batch.Failed++
+		} else {
+			batch.Succeeded++
+		}
+	}
+	status := http.StatusOK
+	if batch.Failed > 0 {
+		status = http.StatusMultiStatus
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(batch)
+}
+
+// Reasons an image was chosen to represent a combined batch
+const (
+	chosenHighestConfidence = "highest_confidence" // its top prediction was the most confident
+	chosenHighestResolution = "highest_resolution" // tied on confidence, it had the most pixels
+)
+
+// chooseBatchImage picks the image of a combined batch whose top prediction is
+// the most confident, preferring the larger image on a tie, and says why
+func chooseBatchImage(items []batchItem) (batchItem, string) {
+	best, reason := items[0], chosenHighestConfidence
+	for _, item := range items[1:] {
+		confidence := item.mlResponse.Predictions[0].Confidence
+		bestConfidence := best.mlResponse.Predictions[0].Confidence
+		switch {
+		case confidence > bestConfidence:
+			best, reason = item, chosenHighestConfidence
+		case confidence == bestConfidence && imagePixels(item.opts.imageMetadata) > imagePixels(best.opts.imageMetadata):
+			best, reason = item, chosenHighestResolution
+		}
+	}
+	return best, reason
+}
+
+// imagePixels returns the pixel count of an uploaded image, 0 when unknown
+func imagePixels(metadata *db.ImageMetadata) int {
+	if metadata == nil {
+		return 0
+	}
+	return metadata.Width * metadata.Height
+}
+
+// HandleRegenerateCare discards the cached care of an identification's species,
+// generates it again with the LLM and stores it on the cache and the identification.
+// Each species can be regenerated at most once per cooldown window.
+func (h *IdentifyHandler) HandleRegenerateCare(w http.ResponseWriter, r *http.Request) {
+	// Only accept POST requests
+	if r.Method != http.MethodPost {
+		h.sendError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	// Extract ID from URL path
+	// Expecting /history/:id/care/regenerate
+	pathParts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	if len(pathParts) < 2 {
+		h.sendError(w, http.StatusBadRequest, "Missing identification ID")
+		return
+	}
+	id := pathParts[1]
+	if !isValidID(id) {
+		h.sendError(w, http.StatusBadRequest, "Invalid identification ID")
+		return
+	}
+
+	if h.chatService == nil {
+		h.sendError(w, http.StatusServiceUnavailable, "Care generation is not available")
+		return
+	}
+
+	identification, err := h.identificationRepo.GetByID(id)
+	if err != nil {
+		log.Printf("Failed to get identification: %v", err)
+		h.sendError(w, http.StatusNotFound, "Identification not found")
+		return
+	}
+
+	cacheGenus, cacheSpecies := utils.CareCacheKey(identification.Genus, identification.Species, h.labelDelimiter)
+	cached, err := h.careRepo.GetBySpecies(cacheGenus, cacheSpecies)
+	if err != nil {
+		log.Printf("Error checking care cache: %v", err)
+		h.sendError(w, http.StatusInternalServerError, "Failed to regenerate care instructions")
+		return
+	}
+	if cached != nil && cached.Verified {
+		h.sendError(w, http.StatusConflict, "Care for this plant is curated and cannot be regenerated")
+		return
+	}
+	if cached != nil && h.regenerateCooldown > 0 {
+		if remaining := h.regenerateCooldown - time.Since(cached.UpdatedAt); remaining > 0 {
+			remaining = remaining.Round(time.Second)
+			w.Header().Set("Retry-After", strconv.Itoa(int(remaining.Seconds())))
+			h.sendError(w, http.StatusTooManyRequests,
+				fmt.Sprintf("Care for this plant was regenerated recently, try again in %s", remaining))
+			return
+		}
+	}
+
+	log.Printf("Regenerating care instructions for %s %s", identification.Genus, identification.Species)
+	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
+	defer cancel()
+
+	careGuide, err := h.chatService.GenerateCareInstructions(ctx, identification.Genus, identification.Species)
+	if err != nil {
+		log.Printf("Failed to regenerate care instructions: %v", err)
+		h.sendError(w, http.StatusBadGateway, "Failed to regenerate care instructions")
+		return
+	}
+
+	now := time.Now().UTC()
+	if cached != nil {
+		cached.CareGuide = careGuide
+		cached.UpdatedAt = now
+		err = h.careRepo.Update(cached)
+	} else {
+		err = h.careRepo.Create(&db.CareInstructionsCache{
+			ID:        uuid.New().String(),
+			Genus:     cacheGenus,
+			Species:   cacheSpecies,
+			CareGuide: careGuide,
+			CreatedAt: now,
+			UpdatedAt: now,
+		})
+	}
+	if err != nil {
+		log.Printf("Failed to cache regenerated care instructions: %v", err)
+	}
+
+	if err := h.identificationRepo.UpdateCareGuide(id, careGuide, db.CareStatusReady); err != nil {
+		log.Printf("Failed to store regenerated care for identification %s: %v", id, err)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(models.CareStatusResponse{
+		ID:         id,
+		CareStatus: db.CareStatusReady,
+		Care:       careInstructionsFromGuide(careGuide),
+	})
+}
+
+// HandleCareByCommonName returns care for the plant a common name refers to,
+// generating it on a cache miss. A name shared by several plants returns them
+// as candidates without care, so the client can pick one.
+func (h *IdentifyHandler) HandleCareByCommonName(w http.ResponseWriter, r *http.Request) {
+	// Only accept GET requests
+	if r.Method != http.MethodGet {
+		h.sendError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	if h.commonNames == nil {
+		h.sendError(w, http.StatusNotFound, "Common name lookup is not available")
+		return
+	}
+
+	name := strings.TrimSpace(r.URL.Query().Get("name"))
+	if name == "" {
+		h.sendError(w, http.StatusBadRequest, "name is required")
+		return
+	}
+
+	var plants []models.CommonNamePlant
+	for _, label := range h.commonNames.Lookup(name) {
+		if !h.isBlocked(label) {
+			plants = append(plants, h.commonNamePlant(label))
+		}
+	}
+	if len(plants) == 0 {
+		h.sendError(w, http.StatusNotFound, "Unknown common name")
+		return
+	}
+
+	response := models.CommonNameCareResponse{Name: name}
+	if len(plants) > 1 {
+		response.Candidates = plants
+	} else {
+		genus, species := h.parseLabel(plants[0].Label)
+		response.Plant = &plants[0]
+		response.Care = careInstructionsFromGuide(h.careGuide(genus, species))
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
+
+// commonNamePlant describes the plant of a label resolved from a common name
+func (h *IdentifyHandler) commonNamePlant(label string) models.CommonNamePlant {
+	genus, species := h.parseLabel(label)
+	plant := models.CommonNamePlant{
+		Label: label,
+		Genus: utils.FormatGenus(genus),
+	}
+	if species != "" {
+		plant.Species = h.formatSpecies(label)
+	}
+	return plant
+}
+
+// careGuide resolves care for a plant outside of an identification: curated
+// care for pinned genera, otherwise the care sources in the configured order
+func (h *IdentifyHandler) careGuide(genus, species string) *db.CareGuide {
+	if h.staticCareOnly(genus, species) {
+		return h.fallbackCareGuide(genus, species)
+	}
+	if careGuide := h.careBeforeLLM(genus, species); careGuide != nil {
+		return careGuide
+	}
+	return h.generateCareGuide(genus, species)
+}
+
+// HandleReidentify runs the ML model again on the stored image of an
+// identification. A changed result is saved as a new identification; an
+// unchanged one (same species, confidence within tolerance) only moves the
+// existing record's timestamp so history does not fill with duplicates.
+func (h *IdentifyHandler) HandleReidentify(w http.ResponseWriter, r *http.Request) {
+	// Only accept POST requests
+	if r.Method != http.MethodPost {
+		h.sendError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	// Extract ID from URL path
+	// Expecting /history/:id/reidentify
+	pathParts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	if len(pathParts) < 2 {
+		h.sendError(w, http.StatusBadRequest, "Missing identification ID")
+		return
+	}
+	id := pathParts[1]
+	if !isValidID(id) {
+		h.sendError(w, http.StatusBadRequest, "Invalid identification ID")
+		return
+	}
+
+	if !h.mlAvailable() {
+		h.sendError(w, http.StatusServiceUnavailable, mlUnavailableMessage)
+		return
+	}
+
+	alternatives, err := h.alternativesCount(r)
+	if err != nil {
+		h.sendError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	schemaVersion, err := identifySchemaVersion(r)
+	if err != nil {
+		h.sendError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	identification, err := h.identificationRepo.GetByID(id)
+	if err != nil {
+		log.Printf("Failed to get identification: %v", err)
+		h.sendError(w, http.StatusNotFound, "Identification not found")
+		return
+	}
+
+	mlResponse, err := h.infer(identification.ImagePath)
+	if err != nil {
+		log.Printf("ML inference error: %v", err)
+		h.recordFailure(identification.ImagePath, err)
+		h.sendError(w, http.StatusInternalServerError, "Failed to identify plant")
+		return
+	}
+
+	var response *models.IdentifyResponse
+	if h.unchangedIdentification(identification, mlResponse.Predictions[0]) {
+		response = h.touchIdentification(identification, mlResponse, alternatives)
+	} else {
+		response, err = h.processMLResponse(mlResponse, identification.ImagePath, processOptions{
+			imageHash:     identification.ImageHash,
+			imageMetadata: identification.ImageMetadata,
+			optimizedPath: identification.OptimizedImagePath,
+			alternatives:  alternatives,
+			keepImage:     true, // still the image of the re-identified record
+		})
+		if err != nil {
+			log.Printf("Processing error: %v", err)
+			h.sendError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Add("Vary", "Accept")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(versionedIdentifyResponse(response, schemaVersion))
+}
+
+// unchangedIdentification reports whether a re-identification's top prediction
+// matches the stored identification closely enough to not save a new record
+func (h *IdentifyHandler) unchangedIdentification(identification *db.Identification, top models.MLPrediction) bool {
+	if !h.reidentifyDedupe || h.isBlocked(top.Label) || h.isUncertain(top) {
+		return false
+	}
+	genus, species := h.parseLabel(top.Label)
+	return genus == identification.Genus &&
+		species == identification.Species &&
+		math.Abs(top.Confidence-identification.Confidence) <= h.reidentifyTolerance
+}
+
+// touchIdentification moves an unchanged identification's timestamp to now
+// and builds the response from the stored record
+func (h *IdentifyHandler) touchIdentification(identification *db.Identification, mlResponse *models.MLInferenceResponse, alternatives int) *models.IdentifyResponse {
+	if err := h.identificationRepo.Touch(identification.ID, time.Now().UTC()); err != nil {
+		log.Printf("Failed to update timestamp of identification %s: %v", identification.ID, err)
+	} else {
+		log.Printf("Re-identification of %s unchanged, updated existing record", identification.ID)
+	}
+
+	response := h.storedResponse(identification, mlResponse, alternatives)
+	response.Unchanged = true
+	return response
+}
+
+// storedResponse builds the response for a stored identification that the
+// ML output matched, rather than for a newly saved one
+func (h *IdentifyHandler) storedResponse(identification *db.Identification, mlResponse *models.MLInferenceResponse, alternatives int) *models.IdentifyResponse {
+	careStatus := identification.CareStatus
+	if careStatus == "" {
+		careStatus = db.CareStatusReady
+	}
+	return &models.IdentifyResponse{
+		ID:           identification.ID,
+		Identified:   true,
+		Plant:        h.plantInfo(mlResponse.Predictions[0]),
+		Care:         careInstructionsFromGuide(identification.CareGuide),
+		CareStatus:   careStatus,
+		Alternatives: h.candidates(mlResponse.Predictions[1:min(len(mlResponse.Predictions), 1+alternatives)]),
+	}
+}
+
+// recentIdentification returns the client's identification of genus and
+// species made within the dedup window, or nil. Plants of a multi-plant
+// image are never deduplicated.
+func (h *IdentifyHandler) recentIdentification(genus, species string, opts processOptions) *db.Identification {
+	if h.dedupWindow <= 0 || opts.clientID == "" || opts.boundingBox != nil {
+		return nil
+	}
+	prior, err := h.identificationRepo.FindRecentSameSpecies(opts.clientID, genus, species, time.Now().UTC().Add(-h.dedupWindow))
+	if err != nil {
+		if !errors.Is(err, db.ErrNotFound) {
+			log.Printf("Failed to look up recent identifications: %v", err)
+		}
+		return nil
+	}
+	return prior
+}
+
+// discardUpload deletes an upload and its optimized copy that no record refers to
+func (h *IdentifyHandler) discardUpload(imagePath, optimizedPath string) {
+	for _, path := range []string{imagePath, optimizedPath} {
+		if path == "" {
+			continue
+		}
+		if err := h.fileUploader.DeleteFile(path); err != nil {
+			log.Printf("Failed to delete unused upload %s: %v", path, err)
+		}
+	}
+}
+
+// HandleValidate runs the upload validation pipeline on an image without
+// saving it or calling the ML service
+func (h *IdentifyHandler) HandleValidate(w http.ResponseWriter, r *http.Request) {
+	// Only accept POST requests
+	if r.Method != http.MethodPost {
+		h.sendError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	// Parse multipart form
+	if !h.parseMultipartForm(w, r, 10<<20) { // 10 MB max
+		return
+	}
+
+	// Get uploaded file
+	file, fileHeader, err := r.FormFile("image")
+	if err != nil {
+		h.sendError(w, http.StatusBadRequest, "No image file provided")
+		return
+	}
+	defer file.Close()
+
+	metadata, err := h.fileUploader.InspectFile(file, fileHeader)
+	if err != nil {
+		h.sendError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(models.ImageValidationResponse{
+		Valid: true,
+		Image: *metadata,
+	})
+}
+
+// infer calls the ML service on a saved image. With retryInference set, a
+// transient failure is retried once if the saved image can still be read.
+func (h *IdentifyHandler) infer(imagePath string) (*models.MLInferenceResponse, error) {
+	mlResponse, err := h.mlClient.Infer(imagePath)
+	if err == nil || !h.retryInference || !services.IsTransientMLError(err) {
+		return mlResponse, err
+	}
+
+	if _, statErr := os.Stat(imagePath); statErr != nil {
+		log.Printf("Not retrying inference, saved image is unreadable: %v", statErr)
+		return nil, err
+	}
+	log.Printf("ML inference failed transiently, retrying once: %v", err)
+	time.Sleep(inferenceRetryDelay)
+	return h.mlClient.Infer(imagePath)
+}
+
+// recordTiming stores the request timing with the identification it created.
+// Blocked and deduplicated results saved no new identification to store it with.
+func (h *IdentifyHandler) recordTiming(response *models.IdentifyResponse, timing *db.RequestTiming) {
+	if response.ID == "" || response.Deduplicated {
+		return
+	}
+	if err := h.timings.SaveTiming(response.ID, timing); err != nil {
+		log.Printf("Failed to record timing of identification %s: %v", response.ID, err)
+	}
+}
+
+// elapsedMs returns the milliseconds elapsed since start
+func elapsedMs(start time.Time) float64 {
+	return float64(time.Since(start).Microseconds()) / 1000
+}
+
+// recordFailure persists a failed inference when a failure recorder is set.
+// Recording is best effort: errors are logged, never returned to the client.
+func (h *IdentifyHandler) recordFailure(imagePath string, inferErr error) {
+	if h.failures == nil {
+		return
+	}
+	failure := &db.FailedIdentification{
+		ID:        uuid.New().String(),
+		ImagePath: imagePath,
+		Error:     inferErr.Error(),
+		CreatedAt: time.Now().UTC(),
+	}
+	if err := h.failures.Create(failure); err != nil {
+		log.Printf("Failed to record failed identification: %v", err)
+	}
+}
+
+// processMLResponse processes ML predictions and applies confidence threshold logic
+func (h *IdentifyHandler) processMLResponse(mlResponse *models.MLInferenceResponse, imagePath string, opts processOptions) (*models.IdentifyResponse, error) {
+	// Several plants in the image are identified and saved one by one
+	if len(mlResponse.Detections) > 1 {
+		return h.processDetections(mlResponse.Detections, imagePath, opts)
+	}
+
+	// Get top prediction
+	topPrediction := mlResponse.Predictions[0]
+
+	// Blocked labels are neither reported, saved nor given care
+	if h.isBlocked(topPrediction.Label) {
+		log.Printf("Identification withheld: label %q is blocked", topPrediction.Label)
+		if !opts.keepImage {
+			h.discardUpload(imagePath, opts.optimizedPath)
+		}
+		return &models.IdentifyResponse{
+			Identified: false,
+			Message:    notIdentifiedMessage,
+			CareStatus: db.CareStatusNone,
+		}, nil
+	}
+
+	// Parse label to extract genus and species
+	genus, species := h.parseLabel(topPrediction.Label)
+
+	// The model is unsure: list candidates rather than presenting a genus as fact
+	if h.isUncertain(topPrediction) {
+		return h.uncertainResponse(mlResponse, genus, species, imagePath, opts)
+	}
+
+	// The client already identified this species moments ago: return that record
+	if prior := h.recentIdentification(genus, species, opts); prior != nil {
+		log.Printf("Identification of %s %s deduplicated to %s", genus, species, prior.ID)
+		h.discardUpload(imagePath, opts.optimizedPath)
+		response := h.storedResponse(prior, mlResponse, opts.alternatives)
+		response.Deduplicated = true
+		return response, nil
+	}
+
+	// Get care instructions from the care sources in the configured order.
+	// In async mode, care left to the LLM is generated in the background after
+	// the record is saved.
+	careStart := time.Now()
+	careGuide := opts.careGuide
+	if careGuide == nil && h.staticCareOnly(genus, species) {
+		careGuide = h.fallbackCareGuide(genus, species)
+	}
+	if careGuide == nil {
+		careGuide = h.careBeforeLLM(genus, species)
+	}
+	careStatus := db.CareStatusReady
+	if careGuide == nil {
+		if h.asyncCare && h.generatesCare() {
+			careStatus = db.CareStatusGenerating
+		} else {
+			careGuide = h.generateCareGuide(genus, species)
+		}
+	}
+	if opts.timing != nil {
+		opts.timing.CareMs += elapsedMs(careStart)
+	}
+
+	// Generate UUID for identification
+	identificationID := uuid.New().String()
+
+	// Create identification record for database
+	identification := &db.Identification{
+		ID:                 identificationID,
+		Genus:              genus,
+		Species:            species,
+		Confidence:         topPrediction.Confidence,
+		ImagePath:          imagePath,
+		OptimizedImagePath: opts.optimizedPath,
+		CareGuide:          careGuide,
+		CareStatus:         careStatus,
+		ImageHash:          opts.imageHash,
+		ImageMetadata:      opts.imageMetadata,
+		ParentID:           opts.parentID,
+		BoundingBox:        opts.boundingBox,
+		ClientID:           opts.clientID,
+		CreatedAt:          time.Now().UTC(),
+	}
+
+	// Save to database
+	saveStart := time.Now()
+	saved := true
+	if err := h.identificationRepo.Create(identification); err != nil {
+		saved = false
+		log.Printf("Failed to save identification to database: %v", err)
+		// Note: We don't fail the request if DB save fails, just log the error
+		// The user still gets their identification result
+	} else {
+		log.Printf("Identification saved to database with ID: %s", identificationID)
+	}
+	if opts.timing != nil {
+		opts.timing.SaveMs += elapsedMs(saveStart)
+	}
+
+	// Background care is stored on the saved record, so without one there is
+	// nothing to generate it for
+	if careStatus == db.CareStatusGenerating && saved {
+		h.careJobs.Add(1)
+		go h.generateCareInBackground(identificationID, genus, species)
+	}
+	if species != "" {
+		h.warmSiblingCare(genus, species)
+	}
+
+	// Build response
+	response := &models.IdentifyResponse{
+		ID:           identificationID,
+		Identified:   true,
+		Plant:        h.plantInfo(topPrediction),
+		Care:         careInstructionsFromGuide(careGuide),
+		CareStatus:   careStatus,
+		Alternatives: h.candidates(mlResponse.Predictions[1:min(len(mlResponse.Predictions), 1+opts.alternatives)]),
+	}
+
+	return response, nil
+}
+
+// processDetections identifies each plant detected in an image as its own
+// record, linked to the first saved one. The response describes the first
+// plant, for clients expecting a single result, and lists all of them.
+func (h *IdentifyHandler) processDetections(detections []models.MLDetection, imagePath string, opts processOptions) (*models.IdentifyResponse, error) {
+	var parentID string
+	results := make([]models.DetectionResult, 0, len(detections))
+	for i, detection := range detections {
+		if len(detection.Predictions) == 0 {
+			continue
+		}
+
+		detectionOpts := opts
+		detectionOpts.boundingBox = &db.BoundingBox{
+			X:      detection.Box.X,
+			Y:      detection.Box.Y,
+			Width:  detection.Box.Width,
+			Height: detection.Box.Height,
+		}
+		if i > 0 {
+			detectionOpts.careGuide = nil // batch care was resolved for the first plant only
+		}
+		detectionOpts.parentID = parentID
+		detectionOpts.keepImage = true // shared by the other plants of the image
+
+		result, err := h.processMLResponse(&models.MLInferenceResponse{Predictions: detection.Predictions}, imagePath, detectionOpts)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, models.DetectionResult{IdentifyResponse: result, Box: detection.Box})
+		if parentID == "" {
+			parentID = result.ID // blocked plants are not saved
+		}
+	}
+	if len(results) == 0 {
+		return nil, fmt.Errorf("ML service returned no predictions")
+	}
+	if parentID == "" && !opts.keepImage {
+		h.discardUpload(imagePath, opts.optimizedPath) // every plant was blocked
+	}
+
+	// A copy, so the first detection does not list itself
+	response := *results[0].IdentifyResponse
+	response.Detections = results
+	return &response, nil
+}
+
+// plantInfo describes a confident prediction, showing the species only when
+// its confidence reaches the species threshold
+func (h *IdentifyHandler) plantInfo(prediction models.MLPrediction) models.PlantInfo {
+	genus, species := h.parseLabel(prediction.Label)
+	plant := models.PlantInfo{
+		Genus:      utils.FormatGenus(genus),
+		Confidence: prediction.Confidence,
+	}
+	if prediction.Confidence >= h.speciesThreshold && species != "" {
+		// High confidence: show species
+		plant.Species = h.formatSpecies(prediction.Label)
+		plant.SpeciesEpithet = utils.SpeciesEpithet(prediction.Label, h.labelDelimiter)
+	}
+	return plant
+}
+
+// isBlocked reports whether a label's genus or species is on the blocklist
+func (h *IdentifyHandler) isBlocked(label string) bool {
+	if len(h.blockedLabels) == 0 {
+		return false
+	}
+	genus, species := utils.ParseLabel(label, h.labelDelimiter)
+	genus, species = utils.CareCacheKey(genus, species, h.labelDelimiter)
+	for _, blocked := range h.blockedLabels {
+		blockedGenus, blockedSpecies := utils.ParseLabel(blocked, h.labelDelimiter)
+		blockedGenus, blockedSpecies = utils.CareCacheKey(blockedGenus, blockedSpecies, h.labelDelimiter)
+		if blockedGenus == genus && (blockedSpecies == "" || blockedSpecies == species) {
+			return true
+		}
+	}
+	return false
+}
+
+// isUncertain reports whether the top prediction is below the confidence floor
+func (h *IdentifyHandler) isUncertain(topPrediction models.MLPrediction) bool {
+	return h.confidenceFloor > 0 && topPrediction.Confidence < h.confidenceFloor
+}
+
+// uncertainResponse saves an identification without care and returns the top
+// candidates so the user can judge the result themselves
+func (h *IdentifyHandler) uncertainResponse(mlResponse *models.MLInferenceResponse, genus, species, imagePath string, opts processOptions) (*models.IdentifyResponse, error) {
+	topPrediction := mlResponse.Predictions[0]
+	candidates := h.candidates(mlResponse.Predictions[:min(len(mlResponse.Predictions), maxUncertainCandidates)])
+
+	identification := &db.Identification{
+		ID:                 uuid.New().String(),
+		Genus:              genus,
+		Species:            species,
+		Confidence:         topPrediction.Confidence,
+		ImagePath:          imagePath,
+		OptimizedImagePath: opts.optimizedPath,
+		CareStatus:         db.CareStatusNone,
+		ImageHash:          opts.imageHash,
+		ImageMetadata:      opts.imageMetadata,
+		ParentID:           opts.parentID,
+		BoundingBox:        opts.boundingBox,
+		ClientID:           opts.clientID,
+		CreatedAt:          time.Now().UTC(),
+	}
+
+	if err := h.identificationRepo.Create(identification); err != nil {
+		log.Printf("Failed to save identification to database: %v", err)
+	} else {
+		log.Printf("Uncertain identification saved to database with ID: %s", identification.ID)
+	}
+
+	return &models.IdentifyResponse{
+		ID:         identification.ID,
+		Identified: true,
+		Plant: models.PlantInfo{
+			Genus:      utils.FormatGenus(genus),
+			Confidence: topPrediction.Confidence,
+		},
+		CareStatus: db.CareStatusNone,
+		Uncertain:  true,
+		Candidates: candidates,
+	}, nil
+}
+
+// candidates converts ML predictions to response candidates, returning nil
+// when there are none so the field is omitted
+func (h *IdentifyHandler) candidates(predictions []models.MLPrediction) []models.CandidatePrediction {
+	if len(predictions) == 0 {
+		return nil
+	}
+	candidates := make([]models.CandidatePrediction, 0, len(predictions))
+	for _, prediction := range predictions {
+		if h.isBlocked(prediction.Label) {
+			continue
+		}
+		genus, species := h.parseLabel(prediction.Label)
+		candidate := models.CandidatePrediction{
+			Genus:      utils.FormatGenus(genus),
+			Confidence: prediction.Confidence,
+		}
+		if species != "" {
+			candidate.Species = h.formatSpecies(prediction.Label)
+			candidate.SpeciesEpithet = utils.SpeciesEpithet(prediction.Label, h.labelDelimiter)
+		}
+		candidates = append(candidates, candidate)
+	}
+	if len(candidates) == 0 {
+		return nil
+	}
+	return candidates
+}
+
+// parseLabel extracts genus and species from an ML label, truncating either to
+// fit the database columns so a malformed label cannot fail the insert
+func (h *IdentifyHandler) parseLabel(label string) (genus, species string) {
+	genus, species = utils.ParseLabel(label, h.labelDelimiter)
+	if species == "" && utils.IsSingleTokenSpecies(label, h.labelDelimiter, h.singleTokenSpecies) {
+		species = label
+	}
+	return truncateName("genus", genus), truncateName("species", species)
+}
+
+// formatSpecies formats a species label for display; a single-token species
+// label has no epithet and shows capitalized
+func (h *IdentifyHandler) formatSpecies(label string) string {
+	if species := utils.FormatSpecies(label, h.labelDelimiter); species != "" {
+		return species
+	}
+	return utils.FormatGenus(label)
+}
+
+// truncateName shortens a genus or species to db.MaxNameLength characters
+func truncateName(field, value string) string {
+	runes := []rune(value)
+	if len(runes) <= db.MaxNameLength {
+		return value
+	}
+	log.Printf("Warning: %s from ML label is %d characters, truncating to %d", field, len(runes), db.MaxNameLength)
+	return string(runes[:db.MaxNameLength])
+}
+
+// batchCareGuides resolves care for each distinct key once: care from the
+// sources ordered before the LLM is used directly and the rest is generated
+// by a bounded pool of workers
+func (h *IdentifyHandler) batchCareGuides(keys []careKey) map[careKey]*db.CareGuide {
+	guides := make(map[careKey]*db.CareGuide, len(keys))
+	var missing []careKey
+	aliases := make(map[careKey][]careKey) // canonical key -> keys of the same plant in other forms
+	for _, key := range keys {
+		if _, seen := guides[key]; seen {
+			continue
+		}
+		if h.staticCareOnly(key.genus, key.species) {
+			guides[key] = h.fallbackCareGuide(key.genus, key.species)
+			continue
+		}
+
+		// Generate each plant once even when it appears under both the full label and the epithet
+		canonicalGenus, canonicalSpecies := utils.CareCacheKey(key.genus, key.species, h.labelDelimiter)
+		canonical := careKey{genus: canonicalGenus, species: canonicalSpecies}
+		if others, pending := aliases[canonical]; pending {
+			aliases[canonical] = append(others, key)
+			guides[key] = nil
+			continue
+		}
+
+		guide := h.careBeforeLLM(key.genus, key.species)
+		guides[key] = guide
+		if guide == nil {
+			missing = append(missing, key)
+			aliases[canonical] = nil
+		}
+	}
+
+	if len(missing) == 0 {
+		return guides
+	}
+
+	jobs := make(chan careKey)
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for i := 0; i < min(h.careConcurrency, len(missing)); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for key := range jobs {
+				guide := h.generateCareGuide(key.genus, key.species)
+				mu.Lock()
+				guides[key] = guide
+				mu.Unlock()
+			}
+		}()
+	}
+
+	for _, key := range missing {
+		jobs <- key
+	}
+	close(jobs)
+	wg.Wait()
+
+	for _, key := range missing {
+		canonicalGenus, canonicalSpecies := utils.CareCacheKey(key.genus, key.species, h.labelDelimiter)
+		for _, alias := range aliases[careKey{genus: canonicalGenus, species: canonicalSpecies}] {
+			guides[alias] = guides[key]
+		}
+	}
+
+	return guides
+}
+
+// saveOptimized saves the optimized web copy of an upload, returning "" when
+// disabled or on failure; the history UI then falls back to the original
+func (h *IdentifyHandler) saveOptimized(imagePath string) string {
+	optimizedPath, err := h.fileUploader.SaveOptimized(imagePath)
+	if err != nil {
+		log.Printf("Failed to save optimized image: %v", err)
+		return ""
+	}
+	return optimizedPath
+}
+
+// hashImage computes the perceptual hash of an uploaded image for duplicate
+// detection, returning nil if the image cannot be decoded
+func hashImage(imagePath string) *int64 {
+	hash, err := utils.ComputeImageHash(imagePath)
+	if err != nil {
+		log.Printf("Failed to compute image hash: %v", err)
+		return nil
+	}
+	signedHash := int64(hash)
+	return &signedHash
+}
+
+// isPinned reports whether a genus is pinned to static care data
+func (h *IdentifyHandler) isPinned(genus string) bool {
+	return h.pinnedGenera[strings.ToLower(genus)]
+}
+
+// staticCareOnly reports whether care for a plant must not be generated or
+// read from the LLM cache: its genus is pinned or its label matches a static
+// care pattern. species is the full label, or empty for genus-only results.
+func (h *IdentifyHandler) staticCareOnly(genus, species string) bool {
+	if h.isPinned(genus) {
+		return true
+	}
+	label := species
+	if label == "" {
+		label = genus
+	}
+	label = strings.ToLower(label)
+	for _, pattern := range h.staticCarePatterns {
+		if matched, _ := path.Match(pattern, label); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// cachedCareGuide returns cached care instructions, or nil on a cache miss
+func (h *IdentifyHandler) cachedCareGuide(genus, species string) *db.CareGuide {
+	cacheGenus, cacheSpecies := utils.CareCacheKey(genus, species, h.labelDelimiter)
+	cachedCare, err := h.careRepo.GetBySpecies(cacheGenus, cacheSpecies)
+	if err != nil {
+		log.Printf("Error checking care cache: %v", err)
+	}
+	if cachedCare == nil {
+		h.recordCareLookup(cacheGenus, false)
+		return nil
+	}
+
+	h.recordCareLookup(cacheGenus, true)
+	log.Printf("Using cached care instructions for %s %s", genus, species)
+	return cachedCare.CareGuide
+}
+
+// recordCareLookup counts a care cache lookup when metrics are enabled
+func (h *IdentifyHandler) recordCareLookup(genus string, hit bool) {
+	if h.careMetrics == nil {
+		return
+	}
+	if hit {
+		h.careMetrics.RecordHit(genus)
+	} else {
+		h.careMetrics.RecordMiss(genus)
+	}
+}
+
+// generatesCare reports whether care can be generated: the LLM is configured
+// and is one of the care sources
+func (h *IdentifyHandler) generatesCare() bool {
+	return h.chatService != nil && slices.Contains(h.careSourceOrder, careSourceLLM)
+}
+
+// careBeforeLLM returns care from the sources ordered before the LLM (all of
+// them when the LLM is not a source), or nil when none has care for the plant
+func (h *IdentifyHandler) careBeforeLLM(genus, species string) *db.CareGuide {
+	sources := h.careSourceOrder
+	if i := slices.Index(sources, careSourceLLM); i >= 0 {
+		sources = sources[:i]
+	}
+	return h.careFromSources(genus, species, sources)
+}
+
+// careFromSources returns care from the first of the static and cache
+// sources that has care for the plant, or nil
+func (h *IdentifyHandler) careFromSources(genus, species string, sources []string) *db.CareGuide {
+	for _, source := range sources {
+		var careGuide *db.CareGuide
+		switch source {
+		case careSourceStatic:
+			careGuide = h.staticCareGuide(genus, species)
+		case careSourceCache:
+			careGuide = h.cachedCareGuide(genus, species)
+		}
+		if careGuide != nil {
+			return careGuide
+		}
+	}
+	return nil
+}
+
+// generateCareGuide generates care instructions with the LLM and caches them,
+// falling back to the sources ordered after the LLM, then generic care, if
+// generation fails
+func (h *IdentifyHandler) generateCareGuide(genus, species string) *db.CareGuide {
+	var fallbackSources []string
+	if i := slices.Index(h.careSourceOrder, careSourceLLM); i >= 0 {
+		fallbackSources = h.careSourceOrder[i+1:]
+	}
+	fallback := func() *db.CareGuide {
+		if careGuide := h.careFromSources(genus, species, fallbackSources); careGuide != nil {
+			return careGuide
+		}
+		return genericCareGuide()
+	}
+
+	// LLM disabled (no OpenAI key or not a care source): go straight to the fallback
+	if !h.generatesCare() {
+		return fallback()
+	}
+
+	log.Printf("Generating new care instructions for %s %s", genus, species)
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	careGuide, err := h.chatService.GenerateCareInstructions(ctx, genus, species)
+	if err != nil {
+		log.Printf("Failed to generate care instructions: %v", err)
+		return fallback()
+	}
+
+	// Save to cache for future use, under the same canonical key it is read with
+	cacheGenus, cacheSpecies := utils.CareCacheKey(genus, species, h.labelDelimiter)
+	cacheEntry := &db.CareInstructionsCache{
+		ID:        uuid.New().String(),
+		Genus:     cacheGenus,
+		Species:   cacheSpecies,
+		CareGuide: careGuide,
+		CreatedAt: time.Now().UTC(),
+		UpdatedAt: time.Now().UTC(),
+	}
+
+	if err := h.careRepo.Create(cacheEntry); err != nil {
+		log.Printf("Failed to cache care instructions: %v", err)
+		// Don't fail the request, just log the error
+	} else {
+		log.Printf("Care instructions cached for %s %s", genus, species)
+	}
+
+	return careGuide
+}
+
+// generateCareInBackground generates care for an identification saved without
+// it and stores the result so clients polling /history/{id}/care can pick it up
+func (h *IdentifyHandler) generateCareInBackground(identificationID, genus, species string) {
+	defer h.careJobs.Done()
+
+	careGuide := h.generateCareGuide(genus, species)
+	if err := h.identificationRepo.UpdateCareGuide(identificationID, careGuide, db.CareStatusReady); err != nil {
+		log.Printf("Failed to store generated care for identification %s: %v", identificationID, err)
+		return
+	}
+	log.Printf("Background care generation completed for identification %s", identificationID)
+
+	h.notifyCareReady(identificationID, careGuide)
+}
+
+// notifyCareReady POSTs the generated care of an identification to the care
+// webhook. Delivery is best effort: failures are logged, not retried.
+func (h *IdentifyHandler) notifyCareReady(identificationID string, careGuide *db.CareGuide) {
+	if h.careWebhookURL == "" {
+		return
+	}
+
+	body, err := json.Marshal(models.CareStatusResponse{
+		ID:         identificationID,
+		CareStatus: db.CareStatusReady,
+		Care:       careInstructionsFromGuide(careGuide),
+	})
+	if err != nil {
+		log.Printf("Failed to encode care webhook for identification %s: %v", identificationID, err)
+		return
+	}
+
+	resp, err := h.careWebhookClient.Post(h.careWebhookURL, "application/json", bytes.NewReader(body))
+	if err != nil {
+		log.Printf("Failed to deliver care webhook for identification %s: %v", identificationID, err)
+		return
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		log.Printf("Care webhook for identification %s returned status %d", identificationID, resp.StatusCode)
+	}
+}
+
+// warmSiblingCare generates care in the background for the most identified
+// other species of the genus, through the batch care worker pool so only the
+// ones without care are generated
+func (h *IdentifyHandler) warmSiblingCare(genus, species string) {
+	if h.siblingCareCount <= 0 || h.siblingCounts == nil || !h.generatesCare() {
+		return
+	}
+	if len(h.siblingCareGenera) > 0 && !h.siblingCareGenera[strings.ToLower(genus)] {
+		return
+	}
+	if !h.claimSiblingWarm(genus) {
+		return
+	}
+
+	h.careJobs.Add(1)
+	go func() {
+		defer h.careJobs.Done()
+
+		keys, claimed := h.claimSiblings(genus, species)
+		if len(keys) == 0 {
+			return
+		}
+		defer h.releaseSiblings(claimed)
+
+		h.batchCareGuides(keys)
+		log.Printf("Warmed care for %d sibling species of %s", len(keys), genus)
+	}()
+}
+
+// claimSiblingWarm reports whether the siblings of genus are due to be warmed,
+// recording the attempt so the genus is skipped for siblingWarmInterval. The
+// attempt is released again if the species counts cannot be read.
+func (h *IdentifyHandler) claimSiblingWarm(genus string) bool {
+	genus = strings.ToLower(strings.TrimSpace(genus))
+	now := time.Now()
+
+	h.siblingMu.Lock()
+	defer h.siblingMu.Unlock()
+	if last, ok := h.siblingWarmedAt[genus]; ok && now.Sub(last) < siblingWarmInterval {
+		return false
+	}
+	h.siblingWarmedAt[genus] = now
+	return true
+}
+
+// releaseSiblingWarm forgets the warm attempt recorded for genus by
+// claimSiblingWarm
+func (h *IdentifyHandler) releaseSiblingWarm(genus string) {
+	genus = strings.ToLower(strings.TrimSpace(genus))
+
+	h.siblingMu.Lock()
+	defer h.siblingMu.Unlock()
+	delete(h.siblingWarmedAt, genus)
+}
+
+// claimSiblings returns the most identified other species of genus, up to
+// siblingCareCount, skipping (but counting) those already being warmed. It
+// also returns the canonical keys it claimed, to release when done.
+func (h *IdentifyHandler) claimSiblings(genus, species string) ([]careKey, []careKey) {
+	counts, err := h.siblingCounts.CountBySpecies()
+	if err != nil {
+		log.Printf("Failed to count identifications for sibling care: %v", err)
+		// Nothing was warmed, so the next identification of the genus tries again
+		h.releaseSiblingWarm(genus)
+		return nil, nil
+	}
+
+	genus, epithet := utils.CareCacheKey(genus, species, h.labelDelimiter)
+
+	h.siblingMu.Lock()
+	defer h.siblingMu.Unlock()
+
+	var keys, claimed []careKey
+	siblings := 0
+	// Counts are most identified first
+	for _, count := range counts {
+		if siblings == h.siblingCareCount {
+			break
+		}
+		siblingGenus, siblingEpithet := utils.CareCacheKey(count.Genus, count.Species, h.labelDelimiter)
+		if siblingGenus != genus || siblingEpithet == "" || siblingEpithet == epithet {
+			continue
+		}
+		siblings++
+
+		canonical := careKey{genus: siblingGenus, species: siblingEpithet}
+		if h.siblingWarming[canonical] {
+			continue
+		}
+		h.siblingWarming[canonical] = true
+		claimed = append(claimed, canonical)
+		keys = append(keys, careKey{genus: count.Genus, species: count.Species})
+	}
+	return keys, claimed
+}
+
+// releaseSiblings marks sibling care as no longer being warmed
+func (h *IdentifyHandler) releaseSiblings(claimed []careKey) {
+	h.siblingMu.Lock()
+	defer h.siblingMu.Unlock()
+	for _, key := range claimed {
+		delete(h.siblingWarming, key)
+	}
+}
+
+// BackfillCare queues care generation for up to limit identifications saved
+// without care (e.g. because generation failed) and returns how many were
+// queued. The records are filled one at a time in the background.
+func (h *IdentifyHandler) BackfillCare(limit int) (int, error) {
+	identifications, err := h.identificationRepo.GetMissingCare(limit)
+	if err != nil {
+		return 0, err
+	}
+	if len(identifications) == 0 {
+		return 0, nil
+	}
+
+	h.careJobs.Add(1)
+	go h.backfillCare(identifications)
+	return len(identifications), nil
+}
+
+// backfillCare resolves and stores care for each identification; species
+// repeated in the batch are served from the cache after the first generation.
+// Generic care is not stored, so the identification stays missing care and a
+// later backfill tries again once real care can be generated.
+func (h *IdentifyHandler) backfillCare(identifications []db.Identification) {
+	defer h.careJobs.Done()
+
+	filled := 0
+	for _, identification := range identifications {
+		careGuide := h.careGuide(identification.Genus, identification.Species)
+		if isGenericCare(careGuide) {
+			log.Printf("No care available to backfill identification %s, leaving it pending", identification.ID)
+			continue
+		}
+		if err := h.identificationRepo.UpdateCareGuide(identification.ID, careGuide, db.CareStatusReady); err != nil {
+			log.Printf("Failed to store backfilled care for identification %s: %v", identification.ID, err)
+			continue
+		}
+		filled++
+	}
+	log.Printf("Care backfill completed: %d of %d identifications updated", filled, len(identifications))
+}
+
+// careInstructionsFromGuide converts a stored care guide to the response format
+func careInstructionsFromGuide(careGuide *db.CareGuide) *models.CareInstructions {
+	if careGuide == nil {
+		return nil
+	}
+	return &models.CareInstructions{
+		Sunlight: careGuide.Sunlight,
+		Watering: careGuide.Watering,
+		Soil:     careGuide.Soil,
+		Notes:    careGuide.Notes,
+		Trivia:   careGuide.Trivia,
+
+		Difficulty: careGuide.Difficulty,
+	}
+}
+
+// imageMetadataRecord converts upload metadata to its database form
+func imageMetadataRecord(metadata *models.ImageMetadata) *db.ImageMetadata {
+	if metadata == nil {
+		return nil
+	}
+	return &db.ImageMetadata{
+		Width:      metadata.Width,
+		Height:     metadata.Height,
+		Format:     metadata.Format,
+		Size:       metadata.Size,
+		CapturedAt: metadata.CapturedAt,
+	}
+}
+
+// imageMetadataFromRecord converts stored image metadata to the response format
+func imageMetadataFromRecord(metadata *db.ImageMetadata) *models.ImageMetadata {
+	if metadata == nil {
+		return nil
+	}
+	return &models.ImageMetadata{
+		Width:      metadata.Width,
+		Height:     metadata.Height,
+		Format:     metadata.Format,
+		Size:       metadata.Size,
+		CapturedAt: metadata.CapturedAt,
+	}
+}
+
+// identifySchemaVersion reads the requested identify response schema version
+// from ?api_version=N or else an "application/vnd.succulent.vN+json" Accept
+// header, defaulting to the current version
+func identifySchemaVersion(r *http.Request) (int, error) {
+	value := r.URL.Query().Get("api_version")
+	if value == "" {
+		for _, accept := range strings.Split(r.Header.Get("Accept"), ",") {
+			mediaType, _, _ := strings.Cut(strings.TrimSpace(accept), ";")
+			if version, ok := strings.CutPrefix(mediaType, "application/vnd.succulent.v"); ok {
+				value, _ = strings.CutSuffix(version, "+json")
+				break
+			}
+		}
+	}
+	if value == "" {
+		return models.IdentifySchemaCurrent, nil
+	}
+
+	version, err := strconv.Atoi(value)
+	if err != nil || version < models.IdentifySchemaV1 || version > models.IdentifySchemaCurrent {
+		return 0, fmt.Errorf("api_version must be between %d and %d", models.IdentifySchemaV1, models.IdentifySchemaCurrent)
+	}
+	return version, nil
+}
+
+// versionedIdentifyResponse returns response in the shape of schema version
+func versionedIdentifyResponse(response *models.IdentifyResponse, version int) any {
+	if version == models.IdentifySchemaV1 {
+		return models.IdentifyResponseV1{
+			SchemaVersion: models.IdentifySchemaV1,
+			ID:            response.ID,
+			Identified:    response.Identified,
+			Message:       response.Message,
+			Plant:         response.Plant,
+			Care:          response.Care,
+			CareStatus:    response.CareStatus,
+		}
+	}
+	response.SchemaVersion = version
+	return response
+}
+
+// careSummaryRequested reports whether ?care_summary=true asks for care to
+// include a rendered summary
+func careSummaryRequested(r *http.Request) (bool, error) {
+	value := r.URL.Query().Get("care_summary")
+	if value == "" {
+		return false, nil
+	}
+	summary, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, fmt.Errorf("care_summary must be true or false")
+	}
+	return summary, nil
+}
+
+// withCareSummary sets the rendered summary of care, which may be nil
+func withCareSummary(care *models.CareInstructions) {
+	if care != nil {
+		care.Summary = careSummary(care)
+	}
+}
+
+// careSummary renders the populated care fields as one readable paragraph,
+// without calling the LLM
+func careSummary(care *models.CareInstructions) string {
+	parts := []struct{ format, value string }{
+		{"Sunlight: %s", care.Sunlight},
+		{"Watering: %s", care.Watering},
+		{"Soil: %s", care.Soil},
+		{"%s", care.Notes},
+		{"Did you know? %s", care.Trivia},
+	}
+
+	sentences := make([]string, 0, len(parts))
+	for _, part := range parts {
+		value := strings.TrimSpace(part.value)
+		if value == "" {
+			continue
+		}
+		if !strings.ContainsAny(value[len(value)-1:], ".!?") {
+			value += "."
+		}
+		sentences = append(sentences, fmt.Sprintf(part.format, value))
+	}
+	return strings.Join(sentences, " ")
+}
+
+// findSimilar returns a warning listing earlier identifications whose image is
+// perceptually similar to the upload, or nil when there are none
+func (h *IdentifyHandler) findSimilar(r *http.Request, imageHash *int64) *models.DuplicateWarning {
+	if imageHash == nil || h.similarImageDistance <= 0 {
+		return nil
+	}
+
+	similar, err := h.identificationRepo.FindSimilar(*imageHash, h.similarImageDistance)
+	if err != nil {
+		log.Printf("Failed to check for similar images: %v", err)
+		return nil
+	}
+	if len(similar) == 0 {
+		return nil
+	}
+
+	matches := make([]models.SimilarIdentification, 0, len(similar))
+	for _, ident := range similar {
+		matches = append(matches, models.SimilarIdentification{
+			ID:        ident.ID,
+			Genus:     utils.FormatGenus(ident.Genus),
+			Species:   utils.FormatSpecies(ident.Species, h.labelDelimiter),
+			Link:      utils.BasePathFromContext(r.Context()) + "/history/" + ident.ID,
+			CreatedAt: ident.CreatedAt,
+		})
+	}
+
+	return &models.DuplicateWarning{
+		Message: "You may have already identified this plant",
+		Matches: matches,
+	}
+}
+
+// sendError sends an error response
+func (h *IdentifyHandler) sendError(w http.ResponseWriter, statusCode int, message string) {
+	writeError(w, statusCode, message)
 }
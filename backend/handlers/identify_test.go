@@ -3,24 +3,48 @@ package handlers
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"maps"
+	"math"
 	"mime/multipart"
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
 	"succulent-identifier-backend/db"
 	"succulent-identifier-backend/models"
 	"succulent-identifier-backend/services"
 	"succulent-identifier-backend/utils"
+	"sync"
 	"testing"
+	"time"
+	"unicode/utf8"
 )
 
 // mockMLClient simulates ML service responses
 type mockMLClient struct {
-	response *models.MLInferenceResponse
-	err      error
+	response  *models.MLInferenceResponse
+	err       error
+	responses []*models.MLInferenceResponse // returned in order when set, one per call
+	errs      []error                       // returned in order when set, one per call
+	calls     int
 }
 
 func (m *mockMLClient) Infer(imagePath string) (*models.MLInferenceResponse, error) {
+	defer func() { m.calls++ }()
+	if m.calls < len(m.errs) && m.errs[m.calls] != nil {
+		return nil, m.errs[m.calls]
+	}
+	if m.calls < len(m.responses) {
+		return m.responses[m.calls], m.err
+	}
 	return m.response, m.err
 }
 
@@ -40,20 +64,45 @@ func (m *mockCareDataService) GetCareInstructions(species, genus string) (models
 
 // mockIdentificationRepository simulates database operations
 type mockIdentificationRepository struct {
-	createCalled    bool
-	lastCreated     *db.Identification
-	createErr       error
-	getByIDResult   *db.Identification
-	getByIDErr      error
-	getAllResult    []db.Identification
-	getAllErr       error
-	countResult     int
-	countErr        error
+	createCalled       bool
+	lastCreated        *db.Identification
+	created            []*db.Identification
+	createErr          error
+	getByIDResult      *db.Identification
+	getByIDsCalledWith []string
+	getByIDsResult     []db.Identification
+	getByIDsErr        error
+	getByIDErr         error
+	getAllResult       []db.Identification
+	getAllErr          error
+	countResult        int
+	countErr           error
+	deleteErr          error
+	findSimilarCalled  bool
+	findSimilarResult  []db.Identification
+	findSimilarErr     error
+	getCareResult      *db.Identification
+	getCareErr         error
+	speciesCounts      []db.SpeciesCount
+	speciesCountsErr   error
+	touchedID          string
+	touchedAt          time.Time
+	missingCare        []db.Identification
+	missingCareErr     error
+	missingCareLimit   int
+
+	mu                sync.Mutex // guards fields written by background care generation
+	updatedCareID     string
+	updatedCareGuide  *db.CareGuide
+	updatedCareStatus string
+	updatedCare       map[string]*db.CareGuide // every care guide stored, by identification ID
+	speciesCountCalls int
 }
 
 func (m *mockIdentificationRepository) Create(identification *db.Identification) error {
 	m.createCalled = true
 	m.lastCreated = identification
+	m.created = append(m.created, identification)
 	return m.createErr
 }
 
@@ -61,14 +110,166 @@ func (m *mockIdentificationRepository) GetByID(id string) (*db.Identification, e
 	return m.getByIDResult, m.getByIDErr
 }
 
+func (m *mockIdentificationRepository) GetByIDs(ids []string) ([]db.Identification, error) {
+	m.getByIDsCalledWith = ids
+	return m.getByIDsResult, m.getByIDsErr
+}
+
 func (m *mockIdentificationRepository) GetAll(limit, offset int) ([]db.Identification, error) {
 	return m.getAllResult, m.getAllErr
 }
 
+// GetAllByGenus pages over the genera of getAllResult, which is ordered by genus
+func (m *mockIdentificationRepository) GetAllByGenus(limit, offset int) ([]db.Identification, error) {
+	if m.getAllErr != nil {
+		return nil, m.getAllErr
+	}
+	page := []db.Identification{}
+	genera := 0
+	for i, identification := range m.getAllResult {
+		if i == 0 || m.getAllResult[i-1].Genus != identification.Genus {
+			genera++
+		}
+		if genera > offset && genera-offset <= limit {
+			page = append(page, identification)
+		}
+	}
+	return page, nil
+}
+
+func (m *mockIdentificationRepository) GetAllStream(fn func(db.Identification) error) error {
+	if m.getAllErr != nil {
+		return m.getAllErr
+	}
+	for _, identification := range m.getAllResult {
+		if err := fn(identification); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *mockIdentificationRepository) GetCare(id string) (*db.Identification, error) {
+	return m.getCareResult, m.getCareErr
+}
+
+func (m *mockIdentificationRepository) UpdateCareGuide(id string, careGuide *db.CareGuide, careStatus string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.updatedCareID = id
+	m.updatedCareGuide = careGuide
+	m.updatedCareStatus = careStatus
+	if m.updatedCare == nil {
+		m.updatedCare = make(map[string]*db.CareGuide)
+	}
+	m.updatedCare[id] = careGuide
+	return nil
+}
+
+func (m *mockIdentificationRepository) Touch(id string, at time.Time) error {
+	m.touchedID = id
+	m.touchedAt = at
+	return nil
+}
+
+func (m *mockIdentificationRepository) FindSimilar(hash int64, distance int) ([]db.Identification, error) {
+	m.findSimilarCalled = true
+	return m.findSimilarResult, m.findSimilarErr
+}
+
+func (m *mockIdentificationRepository) FindRecentSameSpecies(clientID, genus, species string, since time.Time) (*db.Identification, error) {
+	for i := len(m.created) - 1; i >= 0; i-- {
+		identification := m.created[i]
+		if identification.ClientID == clientID && identification.Genus == genus &&
+			identification.Species == species && !identification.CreatedAt.Before(since) {
+			return identification, nil
+		}
+	}
+	return nil, db.ErrNotFound
+}
+
 func (m *mockIdentificationRepository) Count() (int, error) {
 	return m.countResult, m.countErr
 }
 
+func (m *mockIdentificationRepository) CountGenera() (int, error) {
+	genera := map[string]bool{}
+	for _, identification := range m.getAllResult {
+		genera[identification.Genus] = true
+	}
+	return len(genera), m.countErr
+}
+
+func (m *mockIdentificationRepository) CountBySpecies() ([]db.SpeciesCount, error) {
+	m.mu.Lock()
+	m.speciesCountCalls++
+	m.mu.Unlock()
+	return m.speciesCounts, m.speciesCountsErr
+}
+
+func (m *mockIdentificationRepository) GetMissingCare(limit int) ([]db.Identification, error) {
+	m.missingCareLimit = limit
+	return m.missingCare, m.missingCareErr
+}
+
+func (m *mockIdentificationRepository) Delete(id string) error {
+	return m.deleteErr
+}
+
+// mockCareInstructionsRepository simulates the care instructions cache
+type mockCareInstructionsRepository struct {
+	getResult   *db.CareInstructionsCache
+	getErr      error
+	getCalls    int
+	mu          sync.Mutex
+	createCalls int
+	createErr   error
+
+	// entries, when set, makes the mock a real keyed cache: reads return what
+	// was created under the exact same genus and species
+	entries map[careKey]*db.CareInstructionsCache
+}
+
+func (m *mockCareInstructionsRepository) GetBySpecies(genus, species string) (*db.CareInstructionsCache, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.getCalls++
+	if m.entries != nil {
+		return m.entries[careKey{genus: genus, species: species}], m.getErr
+	}
+	return m.getResult, m.getErr
+}
+
+func (m *mockCareInstructionsRepository) Create(cache *db.CareInstructionsCache) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.createCalls++
+	if m.entries != nil && m.createErr == nil {
+		m.entries[careKey{genus: cache.Genus, species: cache.Species}] = cache
+	}
+	return m.createErr
+}
+
+func (m *mockCareInstructionsRepository) Update(cache *db.CareInstructionsCache) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.entries != nil {
+		m.entries[careKey{genus: cache.Genus, species: cache.Species}] = cache
+	}
+	return nil
+}
+
+// toCareGuide converts response care instructions into the stored care guide format
+func toCareGuide(care models.CareInstructions) *db.CareGuide {
+	return &db.CareGuide{
+		Sunlight: care.Sunlight,
+		Watering: care.Watering,
+		Soil:     care.Soil,
+		Notes:    care.Notes,
+		Trivia:   care.Trivia,
+	}
+}
+
 func TestIdentifyHandlerHandle(t *testing.T) {
 	// Setup test environment
 	uploadDir := "../testdata/uploads_handler_test"
@@ -78,16 +279,16 @@ func TestIdentifyHandlerHandle(t *testing.T) {
 	fileUploader, _ := utils.NewFileUploader(uploadDir, 5*1024*1024, []string{".jpg", ".png"})
 
 	tests := []struct {
-		name               string
-		method             string
-		mlResponse         *models.MLInferenceResponse
-		mlError            error
-		careInstructions   models.CareInstructions
-		careError          error
-		speciesThreshold   float64
-		expectedStatus     int
-		expectSpecies      bool
-		setupRequest       func() *http.Request
+		name             string
+		method           string
+		mlResponse       *models.MLInferenceResponse
+		mlError          error
+		careInstructions models.CareInstructions
+		careError        error
+		speciesThreshold float64
+		expectedStatus   int
+		expectSpecies    bool
+		setupRequest     func() *http.Request
 	}{
 		{
 			name:   "Successful identification with high confidence",
@@ -160,17 +361,25 @@ func TestIdentifyHandlerHandle(t *testing.T) {
 				err:      tt.mlError,
 			}
 
+			chatService := &mockChatService{
+				careGuide: toCareGuide(tt.careInstructions),
+				careErr:   tt.careError,
+			}
+
 			careService := &mockCareDataService{
 				care: tt.careInstructions,
 				err:  tt.careError,
 			}
 
-			// Create mock repository
+			// Create mock repositories
 			mockRepo := &mockIdentificationRepository{}
+			careRepo := &mockCareInstructionsRepository{}
 
 			// Create handler
 			handler := NewIdentifyHandler(
 				mlClient,
+				chatService,
+				careRepo,
 				careService,
 				fileUploader,
 				mockRepo,
@@ -240,6 +449,9 @@ func TestIdentifyHandlerHandle(t *testing.T) {
 					if mockRepo.lastCreated.CareGuide == nil {
 						t.Error("Saved identification missing care guide")
 					}
+					if mockRepo.lastCreated.CreatedAt.Location() != time.UTC {
+						t.Errorf("Saved identification created_at should be UTC, got %v", mockRepo.lastCreated.CreatedAt.Location())
+					}
 				}
 			}
 		})
@@ -286,12 +498,16 @@ func TestProcessMLResponse(t *testing.T) {
 	// Mock ML client (not used in this test but required for handler)
 	mlClient := &mockMLClient{}
 
+	// LLM generation fails so care falls back to the static data
+	chatService := &mockChatService{careErr: db.ErrNotFound}
+
 	tests := []struct {
 		name             string
 		mlResponse       *models.MLInferenceResponse
 		speciesThreshold float64
 		expectSpecies    bool
 		expectedGenus    string
+		expectedEpithet  string
 	}{
 		{
 			name: "High confidence shows species",
@@ -303,6 +519,7 @@ func TestProcessMLResponse(t *testing.T) {
 			speciesThreshold: 0.4,
 			expectSpecies:    true,
 			expectedGenus:    "Test_genus",
+			expectedEpithet:  "genus species",
 		},
 		{
 			name: "Low confidence shows genus only",
@@ -325,23 +542,27 @@ func TestProcessMLResponse(t *testing.T) {
 			speciesThreshold: 0.4,
 			expectSpecies:    true,
 			expectedGenus:    "Test_genus",
+			expectedEpithet:  "genus species",
 		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			// Create mock repository
+			// Create mock repositories
 			mockRepo := &mockIdentificationRepository{}
+			careRepo := &mockCareInstructionsRepository{}
 
 			handler := NewIdentifyHandler(
 				mlClient,
+				chatService,
+				careRepo,
 				careService,
 				fileUploader,
 				mockRepo,
 				tt.speciesThreshold,
 			)
 
-			response, err := handler.processMLResponse(tt.mlResponse, "/test/image.jpg")
+			response, err := handler.processMLResponse(tt.mlResponse, "/test/image.jpg", processOptions{})
 
 			if err != nil {
 				t.Errorf("processMLResponse() unexpected error: %v", err)
@@ -360,6 +581,10 @@ func TestProcessMLResponse(t *testing.T) {
 				t.Errorf("processMLResponse() expected no species, got %v", response.Plant.Species)
 			}
 
+			if response.Plant.SpeciesEpithet != tt.expectedEpithet {
+				t.Errorf("processMLResponse() species epithet = %q, expected %q", response.Plant.SpeciesEpithet, tt.expectedEpithet)
+			}
+
 			if response.Plant.Confidence != tt.mlResponse.Predictions[0].Confidence {
 				t.Errorf("processMLResponse() confidence = %v, expected %v",
 					response.Plant.Confidence, tt.mlResponse.Predictions[0].Confidence)
@@ -369,6 +594,16 @@ func TestProcessMLResponse(t *testing.T) {
 			if response.ID == "" {
 				t.Error("processMLResponse() missing identification ID")
 			}
+
+			// Verify static care data is used when generation fails
+			if response.Care.Sunlight != "Species-level sunlight" {
+				t.Errorf("processMLResponse() sunlight = %v, expected static species care", response.Care.Sunlight)
+			}
+
+			// Fallback care is never cached
+			if careRepo.createCalls != 0 {
+				t.Errorf("processMLResponse() cached fallback care %d times", careRepo.createCalls)
+			}
 		})
 	}
 }
@@ -382,12 +617,12 @@ func TestIdentifyHandlerDatabaseIntegration(t *testing.T) {
 	fileUploader, _ := utils.NewFileUploader(uploadDir, 5*1024*1024, []string{".jpg", ".png"})
 
 	tests := []struct {
-		name               string
-		mlResponse         *models.MLInferenceResponse
-		careInstructions   models.CareInstructions
-		createErr          error
-		expectRepoCall     bool
-		expectedGenusInDB  string
+		name                string
+		mlResponse          *models.MLInferenceResponse
+		careInstructions    models.CareInstructions
+		createErr           error
+		expectRepoCall      bool
+		expectedGenusInDB   string
 		expectedSpeciesInDB string
 	}{
 		{
@@ -436,6 +671,10 @@ func TestIdentifyHandlerDatabaseIntegration(t *testing.T) {
 				err:      nil,
 			}
 
+			chatService := &mockChatService{
+				careGuide: toCareGuide(tt.careInstructions),
+			}
+
 			careService := &mockCareDataService{
 				care: tt.careInstructions,
 				err:  nil,
@@ -444,10 +683,13 @@ func TestIdentifyHandlerDatabaseIntegration(t *testing.T) {
 			mockRepo := &mockIdentificationRepository{
 				createErr: tt.createErr,
 			}
+			careRepo := &mockCareInstructionsRepository{}
 
 			// Create handler
 			handler := NewIdentifyHandler(
 				mlClient,
+				chatService,
+				careRepo,
 				careService,
 				fileUploader,
 				mockRepo,
@@ -517,3 +759,2957 @@ func TestIdentifyHandlerDatabaseIntegration(t *testing.T) {
 	}
 }
 
+// encodeTestPNG returns a solid-color PNG of the given dimensions
+func encodeTestPNG(t *testing.T, width, height int) []byte {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for x := 0; x < width; x++ {
+		for y := 0; y < height; y++ {
+			img.Set(x, y, color.RGBA{R: 60, G: 140, B: 80, A: 255})
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatalf("Failed to encode test PNG: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func TestIdentifyHandlerHandleValidate(t *testing.T) {
+	uploadDir := "../testdata/uploads_validate_test"
+	os.MkdirAll(uploadDir, 0755)
+	defer os.RemoveAll(uploadDir)
+
+	fileUploader, _ := utils.NewFileUploader(uploadDir, 5*1024*1024, []string{".jpg", ".png"})
+
+	tests := []struct {
+		name           string
+		setupRequest   func() *http.Request
+		expectedStatus int
+		expectedWidth  int
+		expectedHeight int
+	}{
+		{
+			name: "Valid PNG image",
+			setupRequest: func() *http.Request {
+				req := createMultipartRequest(t, "plant.png", encodeTestPNG(t, 32, 24))
+				req.URL.Path = "/identify/validate"
+				return req
+			},
+			expectedStatus: http.StatusOK,
+			expectedWidth:  32,
+			expectedHeight: 24,
+		},
+		{
+			name: "Corrupt image content",
+			setupRequest: func() *http.Request {
+				return createMultipartRequest(t, "plant.png", []byte("not really a png"))
+			},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name: "Disallowed extension",
+			setupRequest: func() *http.Request {
+				return createMultipartRequest(t, "plant.gif", encodeTestPNG(t, 8, 8))
+			},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name: "Method not allowed",
+			setupRequest: func() *http.Request {
+				req, _ := http.NewRequest(http.MethodGet, "/identify/validate", nil)
+				return req
+			},
+			expectedStatus: http.StatusMethodNotAllowed,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mlClient := &mockMLClient{err: db.ErrNotFound}
+			mockRepo := &mockIdentificationRepository{}
+
+			handler := NewIdentifyHandler(
+				mlClient,
+				&mockChatService{},
+				&mockCareInstructionsRepository{},
+				&mockCareDataService{},
+				fileUploader,
+				mockRepo,
+				0.4,
+			)
+			// Validation does not need the ML service
+			handler.SetMLHealth(&mockMLHealth{status: services.MLHealthStatus{Healthy: false}})
+
+			rr := httptest.NewRecorder()
+			handler.HandleValidate(rr, tt.setupRequest())
+
+			if rr.Code != tt.expectedStatus {
+				t.Fatalf("Handler returned wrong status code: got %v, expected %v",
+					rr.Code, tt.expectedStatus)
+			}
+
+			// Validation never saves anything
+			if mockRepo.createCalled {
+				t.Error("Expected no identification to be saved")
+			}
+			entries, _ := os.ReadDir(uploadDir)
+			if len(entries) != 0 {
+				t.Errorf("Expected no files saved, found %d", len(entries))
+			}
+
+			if tt.expectedStatus == http.StatusOK {
+				var response models.ImageValidationResponse
+				if err := json.NewDecoder(rr.Body).Decode(&response); err != nil {
+					t.Fatalf("Failed to decode response: %v", err)
+				}
+
+				if !response.Valid {
+					t.Error("Expected valid to be true")
+				}
+				if response.Image.Width != tt.expectedWidth || response.Image.Height != tt.expectedHeight {
+					t.Errorf("Expected %dx%d, got %dx%d", tt.expectedWidth, tt.expectedHeight,
+						response.Image.Width, response.Image.Height)
+				}
+				if response.Image.Format != "png" {
+					t.Errorf("Expected format png, got %v", response.Image.Format)
+				}
+				if response.Image.Size == 0 {
+					t.Error("Expected non-zero size")
+				}
+			} else {
+				var response models.ErrorResponse
+				if err := json.NewDecoder(rr.Body).Decode(&response); err != nil {
+					t.Fatalf("Failed to decode error response: %v", err)
+				}
+				if response.Message == "" {
+					t.Error("Expected descriptive error message")
+				}
+			}
+		})
+	}
+}
+
+func TestIdentifyHandlerDuplicateWarning(t *testing.T) {
+	uploadDir := "../testdata/uploads_similar_test"
+	os.MkdirAll(uploadDir, 0755)
+	defer os.RemoveAll(uploadDir)
+
+	fileUploader, _ := utils.NewFileUploader(uploadDir, 5*1024*1024, []string{".jpg", ".png"})
+
+	tests := []struct {
+		name          string
+		content       []byte
+		similar       []db.Identification
+		distance      int
+		expectLookup  bool
+		expectWarning bool
+	}{
+		{
+			name:    "Similar image previously identified",
+			content: encodeTestPNG(t, 32, 32),
+			similar: []db.Identification{
+				{ID: "earlier-id", Genus: "haworthia", Species: "haworthia_zebrina"},
+			},
+			distance:      10,
+			expectLookup:  true,
+			expectWarning: true,
+		},
+		{
+			name:          "No similar images",
+			content:       encodeTestPNG(t, 32, 32),
+			distance:      10,
+			expectLookup:  true,
+			expectWarning: false,
+		},
+		{
+			name:          "Detection disabled",
+			content:       encodeTestPNG(t, 32, 32),
+			similar:       []db.Identification{{ID: "earlier-id", Genus: "haworthia"}},
+			distance:      0,
+			expectLookup:  false,
+			expectWarning: false,
+		},
+		{
+			name:          "Undecodable image skips detection",
+			content:       []byte("fake image"),
+			similar:       []db.Identification{{ID: "earlier-id", Genus: "haworthia"}},
+			distance:      10,
+			expectLookup:  false,
+			expectWarning: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mlClient := &mockMLClient{
+				response: &models.MLInferenceResponse{
+					Predictions: []models.MLPrediction{{Label: "haworthia_zebrina", Confidence: 0.9}},
+				},
+			}
+			mockRepo := &mockIdentificationRepository{findSimilarResult: tt.similar}
+
+			handler := NewIdentifyHandler(
+				mlClient,
+				&mockChatService{careGuide: &db.CareGuide{Sunlight: "Bright light"}},
+				&mockCareInstructionsRepository{},
+				&mockCareDataService{},
+				fileUploader,
+				mockRepo,
+				0.4,
+			)
+			handler.SetSimilarImageDistance(tt.distance)
+
+			rr := httptest.NewRecorder()
+			handler.Handle(rr, createMultipartRequest(t, "plant.png", tt.content))
+
+			if rr.Code != http.StatusOK {
+				t.Fatalf("Handler returned wrong status code: got %v, expected %v", rr.Code, http.StatusOK)
+			}
+
+			if mockRepo.findSimilarCalled != tt.expectLookup {
+				t.Errorf("Expected FindSimilar called = %v, got %v", tt.expectLookup, mockRepo.findSimilarCalled)
+			}
+
+			if tt.expectLookup && mockRepo.lastCreated.ImageHash == nil {
+				t.Error("Expected image hash to be stored with the identification")
+			}
+
+			var response models.IdentifyResponse
+			if err := json.NewDecoder(rr.Body).Decode(&response); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+
+			if !tt.expectWarning {
+				if response.DuplicateWarning != nil {
+					t.Errorf("Expected no duplicate warning, got %+v", response.DuplicateWarning)
+				}
+				return
+			}
+
+			if response.DuplicateWarning == nil || len(response.DuplicateWarning.Matches) != 1 {
+				t.Fatalf("Expected duplicate warning with one match, got %+v", response.DuplicateWarning)
+			}
+			if response.DuplicateWarning.Matches[0].Link != "/history/earlier-id" {
+				t.Errorf("Expected link to earlier identification, got %v", response.DuplicateWarning.Matches[0].Link)
+			}
+		})
+	}
+}
+
+func TestIdentifyHandlerAsyncCare(t *testing.T) {
+	uploadDir := "../testdata/uploads_async_test"
+	os.MkdirAll(uploadDir, 0755)
+	defer os.RemoveAll(uploadDir)
+
+	fileUploader, _ := utils.NewFileUploader(uploadDir, 5*1024*1024, []string{".jpg", ".png"})
+
+	mlClient := &mockMLClient{
+		response: &models.MLInferenceResponse{
+			Predictions: []models.MLPrediction{{Label: "haworthia_zebrina", Confidence: 0.9}},
+		},
+	}
+	mockRepo := &mockIdentificationRepository{}
+	careRepo := &mockCareInstructionsRepository{}
+
+	handler := NewIdentifyHandler(
+		mlClient,
+		&mockChatService{careGuide: &db.CareGuide{Sunlight: "Generated sunlight"}},
+		careRepo,
+		&mockCareDataService{},
+		fileUploader,
+		mockRepo,
+		0.4,
+	)
+	handler.SetAsyncCare(true)
+
+	rr := httptest.NewRecorder()
+	handler.Handle(rr, createMultipartRequest(t, "test.jpg", []byte("fake image")))
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Handler returned wrong status code: got %v, expected %v", rr.Code, http.StatusOK)
+	}
+
+	// Immediate response has plant info but no care yet
+	var response models.IdentifyResponse
+	if err := json.NewDecoder(rr.Body).Decode(&response); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if response.Plant.Genus == "" {
+		t.Error("Expected plant info in immediate response")
+	}
+	if response.Care != nil {
+		t.Errorf("Expected care omitted from immediate response, got %+v", response.Care)
+	}
+	if response.CareStatus != db.CareStatusGenerating {
+		t.Errorf("Expected care_status %q, got %q", db.CareStatusGenerating, response.CareStatus)
+	}
+	if mockRepo.lastCreated.CareStatus != db.CareStatusGenerating || mockRepo.lastCreated.CareGuide != nil {
+		t.Error("Expected identification saved without care and with generating status")
+	}
+
+	// Background job populates care on the saved record
+	handler.careJobs.Wait()
+
+	mockRepo.mu.Lock()
+	defer mockRepo.mu.Unlock()
+	if mockRepo.updatedCareID != response.ID {
+		t.Errorf("Expected care stored for %s, got %s", response.ID, mockRepo.updatedCareID)
+	}
+	if mockRepo.updatedCareStatus != db.CareStatusReady {
+		t.Errorf("Expected care status %q after generation, got %q", db.CareStatusReady, mockRepo.updatedCareStatus)
+	}
+	if mockRepo.updatedCareGuide == nil || mockRepo.updatedCareGuide.Sunlight != "Generated sunlight" {
+		t.Errorf("Expected generated care guide stored, got %+v", mockRepo.updatedCareGuide)
+	}
+	if careRepo.createCalls != 1 {
+		t.Errorf("Expected generated care cached once, got %d", careRepo.createCalls)
+	}
+}
+
+func TestIdentifyHandlerAsyncCareCacheHit(t *testing.T) {
+	mockRepo := &mockIdentificationRepository{}
+	careRepo := &mockCareInstructionsRepository{
+		getResult: &db.CareInstructionsCache{CareGuide: &db.CareGuide{Sunlight: "Cached sunlight"}},
+	}
+
+	handler := NewIdentifyHandler(
+		&mockMLClient{},
+		&mockChatService{},
+		careRepo,
+		&mockCareDataService{},
+		nil,
+		mockRepo,
+		0.4,
+	)
+	handler.SetAsyncCare(true)
+
+	response, err := handler.processMLResponse(&models.MLInferenceResponse{
+		Predictions: []models.MLPrediction{{Label: "haworthia_zebrina", Confidence: 0.9}},
+	}, "/test/image.jpg", processOptions{})
+	if err != nil {
+		t.Fatalf("processMLResponse() unexpected error: %v", err)
+	}
+
+	// Cached care is returned immediately, no background job needed
+	if response.CareStatus != db.CareStatusReady || response.Care == nil || response.Care.Sunlight != "Cached sunlight" {
+		t.Errorf("Expected cached care returned immediately, got status %q care %+v", response.CareStatus, response.Care)
+	}
+	handler.careJobs.Wait()
+	if mockRepo.updatedCareID != "" {
+		t.Error("Expected no background care generation on cache hit")
+	}
+}
+
+func TestIdentifyHandlerAsyncCareWebhook(t *testing.T) {
+	received := make(chan models.CareStatusResponse, 1)
+	webhook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload models.CareStatusResponse
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+			t.Errorf("Failed to decode webhook body: %v", err)
+		}
+		received <- payload
+	}))
+	defer webhook.Close()
+
+	handler := NewIdentifyHandler(
+		&mockMLClient{},
+		&mockChatService{careGuide: &db.CareGuide{Sunlight: "Generated sunlight"}},
+		&mockCareInstructionsRepository{},
+		&mockCareDataService{},
+		nil,
+		&mockIdentificationRepository{},
+		0.4,
+	)
+	handler.SetAsyncCare(true)
+	handler.SetCareWebhook(webhook.URL)
+
+	response, err := handler.processMLResponse(&models.MLInferenceResponse{
+		Predictions: []models.MLPrediction{{Label: "haworthia_zebrina", Confidence: 0.9}},
+	}, "/test/image.jpg", processOptions{})
+	if err != nil {
+		t.Fatalf("processMLResponse() unexpected error: %v", err)
+	}
+	if !handler.WaitForCareJobs(5 * time.Second) {
+		t.Fatal("Background care generation did not finish")
+	}
+
+	select {
+	case payload := <-received:
+		if payload.ID != response.ID || payload.CareStatus != db.CareStatusReady {
+			t.Errorf("Webhook got id %q status %q, expected %q ready", payload.ID, payload.CareStatus, response.ID)
+		}
+		if payload.Care == nil || payload.Care.Sunlight != "Generated sunlight" {
+			t.Errorf("Webhook got care %+v, expected the generated care", payload.Care)
+		}
+	default:
+		t.Error("Expected the webhook to be called")
+	}
+}
+
+func TestIdentifyHandlerAsyncCareUnsaved(t *testing.T) {
+	mockRepo := &mockIdentificationRepository{createErr: errors.New("database unavailable")}
+	chatService := &mockChatService{careGuide: &db.CareGuide{Sunlight: "Generated sunlight"}}
+
+	handler := NewIdentifyHandler(
+		&mockMLClient{},
+		chatService,
+		&mockCareInstructionsRepository{},
+		&mockCareDataService{},
+		nil,
+		mockRepo,
+		0.4,
+	)
+	handler.SetAsyncCare(true)
+
+	if _, err := handler.processMLResponse(&models.MLInferenceResponse{
+		Predictions: []models.MLPrediction{{Label: "haworthia_zebrina", Confidence: 0.9}},
+	}, "/test/image.jpg", processOptions{}); err != nil {
+		t.Fatalf("processMLResponse() unexpected error: %v", err)
+	}
+	handler.careJobs.Wait()
+
+	// Without a saved record no care is generated in the background
+	if len(chatService.careCalls) != 0 || mockRepo.updatedCareID != "" {
+		t.Errorf("Expected no background care generation, got %d LLM calls", len(chatService.careCalls))
+	}
+}
+
+func TestIdentifyHandlerWaitForCareJobs(t *testing.T) {
+	handler := NewIdentifyHandler(&mockMLClient{}, nil, &mockCareInstructionsRepository{}, &mockCareDataService{}, nil, &mockIdentificationRepository{}, 0.4)
+
+	if !handler.WaitForCareJobs(time.Second) {
+		t.Error("Expected no jobs to finish immediately")
+	}
+
+	release := make(chan struct{})
+	handler.careJobs.Add(1)
+	go func() {
+		defer handler.careJobs.Done()
+		<-release
+	}()
+
+	if handler.WaitForCareJobs(10 * time.Millisecond) {
+		t.Error("Expected a running job to exceed the timeout")
+	}
+	close(release)
+	if !handler.WaitForCareJobs(time.Second) {
+		t.Error("Expected the released job to finish")
+	}
+}
+
+func TestIdentifyHandlerBackfillCare(t *testing.T) {
+	mockRepo := &mockIdentificationRepository{missingCare: []db.Identification{
+		{ID: "ident-1", Genus: "haworthia", Species: "haworthia_zebrina", CareStatus: db.CareStatusReady},
+		{ID: "ident-2", Genus: "lithops", Species: "", CareStatus: db.CareStatusGenerating},
+		{ID: "ident-3", Genus: "haworthia", Species: "haworthia_zebrina", CareStatus: db.CareStatusReady},
+	}}
+	chatService := &mockChatService{careGuide: &db.CareGuide{Sunlight: "Generated sunlight"}}
+	careRepo := &mockCareInstructionsRepository{entries: map[careKey]*db.CareInstructionsCache{}}
+
+	handler := NewIdentifyHandler(
+		&mockMLClient{},
+		chatService,
+		careRepo,
+		&mockCareDataService{},
+		nil,
+		mockRepo,
+		0.4,
+	)
+
+	queued, err := handler.BackfillCare(50)
+	if err != nil {
+		t.Fatalf("BackfillCare() unexpected error: %v", err)
+	}
+	if queued != 3 || mockRepo.missingCareLimit != 50 {
+		t.Errorf("Expected 3 queued with limit 50, got %d with limit %d", queued, mockRepo.missingCareLimit)
+	}
+	handler.careJobs.Wait()
+
+	mockRepo.mu.Lock()
+	defer mockRepo.mu.Unlock()
+	for _, id := range []string{"ident-1", "ident-2", "ident-3"} {
+		if careGuide := mockRepo.updatedCare[id]; careGuide == nil || careGuide.Sunlight != "Generated sunlight" {
+			t.Errorf("Expected generated care stored for %s, got %+v", id, careGuide)
+		}
+	}
+	if mockRepo.updatedCareStatus != db.CareStatusReady {
+		t.Errorf("Expected care status %q, got %q", db.CareStatusReady, mockRepo.updatedCareStatus)
+	}
+	// The repeated species is generated once and then served from the cache
+	if chatService.careCalls["haworthia/haworthia_zebrina"] != 1 || chatService.careCalls["lithops/"] != 1 {
+		t.Errorf("Expected one generation per species, got %v", chatService.careCalls)
+	}
+
+	// Nothing missing: nothing queued
+	mockRepo.missingCare = nil
+	if queued, err := handler.BackfillCare(50); err != nil || queued != 0 {
+		t.Errorf("Expected nothing queued, got %d (err %v)", queued, err)
+	}
+
+	mockRepo.missingCareErr = errors.New("database error")
+	if _, err := handler.BackfillCare(50); err == nil {
+		t.Error("Expected error but got none")
+	}
+}
+
+func TestIdentifyHandlerBackfillCareGenericFallback(t *testing.T) {
+	mockRepo := &mockIdentificationRepository{missingCare: []db.Identification{
+		{ID: "ident-1", Genus: "haworthia", Species: "haworthia_zebrina", CareStatus: db.CareStatusReady},
+	}}
+
+	// The LLM fails and there is no static care, so only generic care is available
+	handler := NewIdentifyHandler(
+		&mockMLClient{},
+		&mockChatService{careErr: errors.New("llm unavailable")},
+		&mockCareInstructionsRepository{},
+		&mockCareDataService{err: errors.New("not found")},
+		nil,
+		mockRepo,
+		0.4,
+	)
+
+	if _, err := handler.BackfillCare(50); err != nil {
+		t.Fatalf("BackfillCare() unexpected error: %v", err)
+	}
+	handler.careJobs.Wait()
+
+	// Generic care is not stored, so a later backfill retries the record
+	mockRepo.mu.Lock()
+	defer mockRepo.mu.Unlock()
+	if mockRepo.updatedCareID != "" {
+		t.Errorf("Expected generic care not to be stored, got care for %s", mockRepo.updatedCareID)
+	}
+}
+
+func TestProcessMLResponseWithoutChatService(t *testing.T) {
+	fileUploader, _ := utils.NewFileUploader("../testdata/uploads", 5*1024*1024, []string{".jpg"})
+	mlResponse := &models.MLInferenceResponse{
+		Predictions: []models.MLPrediction{
+			{Label: "unknown_genus_species", Confidence: 0.9},
+		},
+	}
+
+	tests := []struct {
+		name             string
+		careData         *mockCareDataService
+		asyncCare        bool
+		expectedSunlight string
+	}{
+		{
+			name:             "No static match uses generic fallback",
+			careData:         &mockCareDataService{err: errors.New("no care data")},
+			expectedSunlight: "Provide bright, indirect light for most succulents.",
+		},
+		{
+			name:             "Static match is used",
+			careData:         &mockCareDataService{care: models.CareInstructions{Sunlight: "Static sunlight"}},
+			expectedSunlight: "Static sunlight",
+		},
+		{
+			name:             "Async mode answers synchronously without LLM",
+			careData:         &mockCareDataService{err: errors.New("no care data")},
+			asyncCare:        true,
+			expectedSunlight: "Provide bright, indirect light for most succulents.",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockIdentRepo := &mockIdentificationRepository{}
+			mockCareRepo := &mockCareInstructionsRepository{}
+			handler := NewIdentifyHandler(&mockMLClient{}, nil, mockCareRepo, tt.careData, fileUploader, mockIdentRepo, 0.4)
+			handler.SetAsyncCare(tt.asyncCare)
+
+			response, err := handler.processMLResponse(mlResponse, "/uploads/test.jpg", processOptions{})
+			if err != nil {
+				t.Fatalf("processMLResponse() unexpected error: %v", err)
+			}
+
+			if response.Care == nil {
+				t.Fatal("Expected care instructions in response")
+			}
+			if response.Care.Sunlight != tt.expectedSunlight {
+				t.Errorf("Expected sunlight %q, got %q", tt.expectedSunlight, response.Care.Sunlight)
+			}
+			if response.CareStatus != db.CareStatusReady {
+				t.Errorf("Expected care status %s, got %s", db.CareStatusReady, response.CareStatus)
+			}
+			if mockCareRepo.createCalls != 0 {
+				t.Error("Fallback care should not be cached")
+			}
+		})
+	}
+}
+
+// createBatchMultipartRequest builds a batch identify request with one "images" part per filename
+func createBatchMultipartRequest(t *testing.T, filenames ...string) *http.Request {
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+
+	for _, filename := range filenames {
+		part, err := writer.CreateFormFile("images", filename)
+		if err != nil {
+			t.Fatalf("Failed to create form file: %v", err)
+		}
+		part.Write([]byte("fake image"))
+	}
+	writer.Close()
+
+	req := httptest.NewRequest(http.MethodPost, "/identify/batch", body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	return req
+}
+
+func TestIdentifyHandlerHandleQuick(t *testing.T) {
+	uploadDir := t.TempDir()
+	fileUploader, _ := utils.NewFileUploader(uploadDir, 5*1024*1024, []string{".jpg"})
+
+	tests := []struct {
+		name               string
+		predictions        []models.MLPrediction
+		blocked            []string
+		expectedIdentified bool
+		expectedGenus      string
+		expectedConfidence float64
+	}{
+		{
+			name: "Sums confidence over the species of a genus",
+			predictions: []models.MLPrediction{
+				{Label: "echeveria_elegans", Confidence: 0.4},
+				{Label: "haworthia_zebrina", Confidence: 0.35},
+				{Label: "haworthia_cooperi", Confidence: 0.25},
+			},
+			expectedIdentified: true,
+			expectedGenus:      "Haworthia",
+			expectedConfidence: 0.6,
+		},
+		{
+			name:               "Blocked labels are not reported",
+			predictions:        []models.MLPrediction{{Label: "aloe_vera", Confidence: 0.9}},
+			blocked:            []string{"aloe"},
+			expectedIdentified: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockRepo := &mockIdentificationRepository{}
+			careRepo := &mockCareInstructionsRepository{}
+			chatService := &mockChatService{}
+
+			handler := NewIdentifyHandler(
+				&mockMLClient{response: &models.MLInferenceResponse{Predictions: tt.predictions}},
+				chatService,
+				careRepo,
+				&mockCareDataService{},
+				fileUploader,
+				mockRepo,
+				0.4,
+			)
+			handler.SetBlockedLabels(tt.blocked)
+
+			rr := httptest.NewRecorder()
+			handler.HandleQuick(rr, createMultipartRequest(t, "plant.jpg", []byte("fake image content")))
+
+			if rr.Code != http.StatusOK {
+				t.Fatalf("Handler returned wrong status code: got %v, expected %v: %s", rr.Code, http.StatusOK, rr.Body.String())
+			}
+			var response models.QuickIdentifyResponse
+			if err := json.NewDecoder(rr.Body).Decode(&response); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+			if response.Identified != tt.expectedIdentified || response.Genus != tt.expectedGenus {
+				t.Errorf("Expected identified=%v genus %q, got %+v", tt.expectedIdentified, tt.expectedGenus, response)
+			}
+			if math.Abs(response.Confidence-tt.expectedConfidence) > 1e-9 {
+				t.Errorf("Expected confidence %v, got %v", tt.expectedConfidence, response.Confidence)
+			}
+
+			// No DB, care cache or LLM access, and the upload is not kept
+			if mockRepo.createCalled || mockRepo.findSimilarCalled {
+				t.Error("Quick identify should not touch the identification repository")
+			}
+			if careRepo.getCalls != 0 || careRepo.createCalls != 0 {
+				t.Errorf("Quick identify should not touch the care cache, got %d reads and %d writes", careRepo.getCalls, careRepo.createCalls)
+			}
+			if len(chatService.careCalls) != 0 {
+				t.Errorf("Quick identify should not call the LLM, got %v", chatService.careCalls)
+			}
+			if entries, _ := os.ReadDir(uploadDir); len(entries) != 0 {
+				t.Errorf("Expected the upload to be deleted, found %d files", len(entries))
+			}
+		})
+	}
+}
+
+func TestIdentifyHandlerHandleBatch(t *testing.T) {
+	fileUploader, _ := utils.NewFileUploader(t.TempDir(), 5*1024*1024, []string{".jpg"})
+
+	prediction := func(label string) *models.MLInferenceResponse {
+		return &models.MLInferenceResponse{
+			Predictions: []models.MLPrediction{{Label: label, Confidence: 0.9}},
+		}
+	}
+	labels := []string{
+		"haworthia_zebrina",
+		"echeveria_elegans",
+		"haworthia_zebrina",
+		"aloe_vera",
+		"echeveria_elegans",
+	}
+	mlClient := &mockMLClient{}
+	for _, label := range labels {
+		mlClient.responses = append(mlClient.responses, prediction(label))
+	}
+
+	chatService := &mockChatService{
+		careGuide: &db.CareGuide{Sunlight: "Generated sunlight"},
+		careDelay: 20 * time.Millisecond,
+	}
+	careRepo := &mockCareInstructionsRepository{}
+
+	handler := NewIdentifyHandler(
+		mlClient,
+		chatService,
+		careRepo,
+		&mockCareDataService{},
+		fileUploader,
+		&mockIdentificationRepository{},
+		0.4,
+	)
+	handler.SetCareConcurrency(2)
+
+	rr := httptest.NewRecorder()
+	handler.HandleBatch(rr, createBatchMultipartRequest(t, "1.jpg", "2.jpg", "3.jpg", "4.jpg", "5.jpg"))
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Handler returned wrong status code: got %v, expected %v: %s", rr.Code, http.StatusOK, rr.Body.String())
+	}
+
+	var response models.BatchIdentifyResponse
+	if err := json.NewDecoder(rr.Body).Decode(&response); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if len(response.Results) != len(labels) {
+		t.Fatalf("Expected %d results, got %d", len(labels), len(response.Results))
+	}
+	for i, result := range response.Results {
+		if result.Care == nil || result.Care.Sunlight != "Generated sunlight" {
+			t.Errorf("Result %d missing generated care: %+v", i, result.Care)
+		}
+	}
+	if response.Results[0].Plant.Genus != "Haworthia" || response.Results[3].Plant.Genus != "Aloe" {
+		t.Error("Expected results in upload order")
+	}
+
+	// One LLM call per distinct species, never more than the configured workers at once
+	if len(chatService.careCalls) != 3 {
+		t.Errorf("Expected care generated for 3 distinct species, got %v", chatService.careCalls)
+	}
+	for key, calls := range chatService.careCalls {
+		if calls != 1 {
+			t.Errorf("Expected 1 care generation for %s, got %d", key, calls)
+		}
+	}
+	if chatService.maxInFlight > 2 {
+		t.Errorf("Expected at most 2 concurrent generations, got %d", chatService.maxInFlight)
+	}
+	if careRepo.createCalls != 3 {
+		t.Errorf("Expected 3 cache writes, got %d", careRepo.createCalls)
+	}
+}
+
+func TestIdentifyHandlerHandleBatchCombine(t *testing.T) {
+	uploadDir := t.TempDir()
+	fileUploader, _ := utils.NewFileUploader(uploadDir, 5*1024*1024, []string{".jpg"})
+	mlClient := &mockMLClient{
+		responses: []*models.MLInferenceResponse{
+			{Predictions: []models.MLPrediction{{Label: "haworthia_zebrina", Confidence: 0.6}}},
+			{Predictions: []models.MLPrediction{{Label: "echeveria_elegans", Confidence: 0.95}}},
+			{Predictions: []models.MLPrediction{{Label: "haworthia_zebrina", Confidence: 0.7}}},
+		},
+	}
+	identRepo := &mockIdentificationRepository{}
+	handler := NewIdentifyHandler(
+		mlClient,
+		nil,
+		&mockCareInstructionsRepository{},
+		&mockCareDataService{care: models.CareInstructions{Sunlight: "Full sun"}},
+		fileUploader,
+		identRepo,
+		0.4,
+	)
+
+	req := createBatchMultipartRequest(t, "1.jpg", "2.jpg", "3.jpg")
+	req.URL.RawQuery = "combine=true"
+	rr := httptest.NewRecorder()
+	handler.HandleBatch(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+	var response models.BatchIdentifyResponse
+	if err := json.NewDecoder(rr.Body).Decode(&response); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+
+	// The most confident inference is chosen and is the only record saved
+	expected := models.ChosenImage{Index: 1, Filename: "2.jpg", Reason: chosenHighestConfidence}
+	if response.Chosen == nil || *response.Chosen != expected {
+		t.Fatalf("Expected chosen image %+v, got %+v", expected, response.Chosen)
+	}
+	if len(identRepo.created) != 1 || identRepo.created[0].Genus != "echeveria" {
+		t.Fatalf("Expected one Echeveria identification, got %d records", len(identRepo.created))
+	}
+	chosen := response.Results[1]
+	if chosen.IdentifyResponse == nil || chosen.ID != identRepo.created[0].ID {
+		t.Errorf("Expected the chosen result to carry the saved identification, got %+v", chosen)
+	}
+	if response.Results[0].IdentifyResponse != nil || response.Results[2].IdentifyResponse != nil {
+		t.Error("Expected only the chosen result to carry an identification")
+	}
+	if response.Succeeded != 3 || response.Failed != 0 {
+		t.Errorf("Expected 3 successes, got %d succeeded and %d failed", response.Succeeded, response.Failed)
+	}
+
+	// The record keeps the chosen upload, the others are deleted
+	entries, _ := os.ReadDir(uploadDir)
+	if len(entries) != 1 || filepath.Join(uploadDir, entries[0].Name()) != identRepo.created[0].ImagePath {
+		t.Errorf("Expected only the chosen upload %s to remain, found %d files", identRepo.created[0].ImagePath, len(entries))
+	}
+
+	t.Run("Ties go to the larger image", func(t *testing.T) {
+		item := func(index, width int) batchItem {
+			return batchItem{
+				index:      index,
+				mlResponse: &models.MLInferenceResponse{Predictions: []models.MLPrediction{{Label: "aloe_vera", Confidence: 0.8}}},
+				opts:       processOptions{imageMetadata: &db.ImageMetadata{Width: width, Height: width}},
+			}
+		}
+		best, reason := chooseBatchImage([]batchItem{item(0, 640), item(1, 1024), item(2, 800)})
+		if best.index != 1 || reason != chosenHighestResolution {
+			t.Errorf("Expected image 1 for its resolution, got %d (%s)", best.index, reason)
+		}
+	})
+}
+
+func TestIdentifyHandlerHandleBatchPartialFailure(t *testing.T) {
+	fileUploader, _ := utils.NewFileUploader(t.TempDir(), 5*1024*1024, []string{".jpg"})
+	// The ML service cannot decode the corrupt second image
+	mlClient := &mockMLClient{
+		responses: []*models.MLInferenceResponse{
+			{Predictions: []models.MLPrediction{{Label: "haworthia_zebrina", Confidence: 0.9}}},
+		},
+		errs: []error{nil, errors.New("cannot identify image file")},
+	}
+	identRepo := &mockIdentificationRepository{}
+
+	handler := NewIdentifyHandler(
+		mlClient,
+		&mockChatService{careGuide: &db.CareGuide{Sunlight: "Generated sunlight"}},
+		&mockCareInstructionsRepository{},
+		&mockCareDataService{},
+		fileUploader,
+		identRepo,
+		0.4,
+	)
+
+	rr := httptest.NewRecorder()
+	handler.HandleBatch(rr, createBatchMultipartRequest(t, "plant.jpg", "corrupt.jpg", "plant.gif"))
+
+	if rr.Code != http.StatusMultiStatus {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusMultiStatus, rr.Code, rr.Body.String())
+	}
+	var response models.BatchIdentifyResponse
+	if err := json.NewDecoder(rr.Body).Decode(&response); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if len(response.Results) != 3 || response.Succeeded != 1 || response.Failed != 2 {
+		t.Fatalf("Expected 1 success and 2 failures, got %+v", response)
+	}
+
+	valid := response.Results[0]
+	if valid.Filename != "plant.jpg" || valid.Status != http.StatusOK || valid.Error != nil {
+		t.Errorf("Unexpected result for the valid image: %+v", valid)
+	}
+	if valid.IdentifyResponse == nil || valid.ID != identRepo.lastCreated.ID || valid.Care == nil || valid.Care.Sunlight != "Generated sunlight" {
+		t.Errorf("Expected the saved identification with care, got %+v", valid.IdentifyResponse)
+	}
+
+	failures := []struct {
+		filename string
+		status   int
+		code     string
+	}{
+		{"corrupt.jpg", http.StatusInternalServerError, batchErrorInferenceFailed},
+		{"plant.gif", http.StatusBadRequest, batchErrorUploadRejected},
+	}
+	for i, expected := range failures {
+		result := response.Results[i+1]
+		if result.Filename != expected.filename || result.Status != expected.status || result.IdentifyResponse != nil {
+			t.Errorf("Unexpected result for %s: %+v", expected.filename, result)
+		}
+		if result.Error == nil || result.Error.Code != expected.code || result.Error.Message == "" {
+			t.Errorf("Expected error code %q for %s, got %+v", expected.code, expected.filename, result.Error)
+		}
+	}
+}
+
+func TestIdentifyHandlerHandleBatchValidation(t *testing.T) {
+	fileUploader, _ := utils.NewFileUploader(t.TempDir(), 5*1024*1024, []string{".jpg"})
+	handler := NewIdentifyHandler(
+		&mockMLClient{},
+		&mockChatService{},
+		&mockCareInstructionsRepository{},
+		&mockCareDataService{},
+		fileUploader,
+		&mockIdentificationRepository{},
+		0.4,
+	)
+
+	tooMany := make([]string, maxBatchImages+1)
+	for i := range tooMany {
+		tooMany[i] = "plant.jpg"
+	}
+
+	tests := []struct {
+		name           string
+		req            *http.Request
+		expectedStatus int
+	}{
+		{name: "No images", req: createBatchMultipartRequest(t), expectedStatus: http.StatusBadRequest},
+		{name: "Too many images", req: createBatchMultipartRequest(t, tooMany...), expectedStatus: http.StatusBadRequest},
+		// Per-image failures are reported in the results, see TestIdentifyHandlerHandleBatchPartialFailure
+		{name: "Invalid file type", req: createBatchMultipartRequest(t, "plant.gif"), expectedStatus: http.StatusMultiStatus},
+		{name: "Wrong method", req: httptest.NewRequest(http.MethodGet, "/identify/batch", nil), expectedStatus: http.StatusMethodNotAllowed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			handler.HandleBatch(rr, tt.req)
+
+			if rr.Code != tt.expectedStatus {
+				t.Errorf("Handler returned wrong status code: got %v, expected %v", rr.Code, tt.expectedStatus)
+			}
+		})
+	}
+}
+
+func TestProcessMLResponseDetections(t *testing.T) {
+	var mlResponse models.MLInferenceResponse
+	body := `{
+		"predictions": [],
+		"detections": [
+			{"box": {"x": 0, "y": 0, "width": 120, "height": 100}, "predictions": [{"label": "haworthia_zebrina", "confidence": 0.9}]},
+			{"box": {"x": 130, "y": 10, "width": 90, "height": 90}, "predictions": [{"label": "echeveria_elegans", "confidence": 0.8}]},
+			{"box": {"x": 240, "y": 5, "width": 60, "height": 70}, "predictions": [{"label": "aloe_vera", "confidence": 0.7}]}
+		]
+	}`
+	if err := json.Unmarshal([]byte(body), &mlResponse); err != nil {
+		t.Fatalf("Failed to decode ML response: %v", err)
+	}
+
+	fileUploader, _ := utils.NewFileUploader(t.TempDir(), 5*1024*1024, []string{".jpg"})
+	mockRepo := &mockIdentificationRepository{}
+	handler := NewIdentifyHandler(
+		&mockMLClient{},
+		&mockChatService{careGuide: &db.CareGuide{Sunlight: "Bright light"}},
+		&mockCareInstructionsRepository{},
+		&mockCareDataService{},
+		fileUploader,
+		mockRepo,
+		0.4,
+	)
+
+	response, err := handler.processMLResponse(&mlResponse, "/uploads/group.jpg", processOptions{})
+	if err != nil {
+		t.Fatalf("processMLResponse() error = %v", err)
+	}
+
+	if len(mockRepo.created) != 3 {
+		t.Fatalf("Expected 3 saved identifications, got %d", len(mockRepo.created))
+	}
+	parent := mockRepo.created[0]
+	if parent.ParentID != "" || parent.Genus != "haworthia" {
+		t.Errorf("Expected the first plant saved as parent, got %+v", parent)
+	}
+	for i, child := range mockRepo.created[1:] {
+		if child.ParentID != parent.ID {
+			t.Errorf("Detection %d: expected parent %s, got %q", i+1, parent.ID, child.ParentID)
+		}
+		if child.ImagePath != "/uploads/group.jpg" || child.BoundingBox == nil {
+			t.Errorf("Detection %d: expected image path and bounding box, got %+v", i+1, child)
+		}
+	}
+	if box := mockRepo.created[1].BoundingBox; box.X != 130 || box.Width != 90 {
+		t.Errorf("Unexpected bounding box %+v", box)
+	}
+
+	// Single-result fields describe the first plant
+	if response.ID != parent.ID || response.Plant.Genus != "Haworthia" {
+		t.Errorf("Expected response for the first plant, got %+v", response)
+	}
+	if len(response.Detections) != 3 {
+		t.Fatalf("Expected 3 detections in response, got %d", len(response.Detections))
+	}
+	for i, detection := range response.Detections {
+		if detection.ID != mockRepo.created[i].ID || detection.Care == nil || len(detection.Detections) != 0 {
+			t.Errorf("Detection %d: unexpected result %+v", i, detection.IdentifyResponse)
+		}
+	}
+	if response.Detections[2].Plant.Genus != "Aloe" || response.Detections[2].Box.X != 240 {
+		t.Errorf("Unexpected last detection %+v", response.Detections[2])
+	}
+}
+
+func TestProcessMLResponseTruncatesLongNames(t *testing.T) {
+	fileUploader, _ := utils.NewFileUploader(t.TempDir(), 5*1024*1024, []string{".jpg"})
+	mockIdentRepo := &mockIdentificationRepository{}
+	handler := NewIdentifyHandler(
+		&mockMLClient{},
+		&mockChatService{careGuide: &db.CareGuide{Sunlight: "Generated sunlight"}},
+		&mockCareInstructionsRepository{},
+		&mockCareDataService{},
+		fileUploader,
+		mockIdentRepo,
+		0.4,
+	)
+
+	// Multi-byte characters must be counted as characters, not bytes
+	longGenus := strings.Repeat("é", 300)
+	mlResponse := &models.MLInferenceResponse{
+		Predictions: []models.MLPrediction{
+			{Label: longGenus + "_zebrina", Confidence: 0.9},
+		},
+	}
+
+	if _, err := handler.processMLResponse(mlResponse, "/uploads/test.jpg", processOptions{}); err != nil {
+		t.Fatalf("processMLResponse() unexpected error: %v", err)
+	}
+
+	saved := mockIdentRepo.lastCreated
+	if saved == nil {
+		t.Fatal("Expected identification to be saved")
+	}
+	if n := utf8.RuneCountInString(saved.Genus); n != db.MaxNameLength {
+		t.Errorf("Expected genus truncated to %d characters, got %d", db.MaxNameLength, n)
+	}
+	if n := utf8.RuneCountInString(saved.Species); n != db.MaxNameLength {
+		t.Errorf("Expected species truncated to %d characters, got %d", db.MaxNameLength, n)
+	}
+	if !utf8.ValidString(saved.Genus) || !utf8.ValidString(saved.Species) {
+		t.Error("Truncation must not split multi-byte characters")
+	}
+}
+
+func TestProcessMLResponseSingleTokenLabels(t *testing.T) {
+	tests := []struct {
+		name               string
+		singleTokenSpecies []string
+		expectedSpecies    string
+	}{
+		{name: "Genus by default", singleTokenSpecies: nil, expectedSpecies: ""},
+		{name: "Unlisted label stays genus", singleTokenSpecies: []string{"echeveria"}, expectedSpecies: ""},
+		{name: "Listed label is species", singleTokenSpecies: []string{"lithops"}, expectedSpecies: "Lithops"},
+		{name: "Wildcard makes every label species", singleTokenSpecies: []string{"*"}, expectedSpecies: "Lithops"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fileUploader, _ := utils.NewFileUploader(t.TempDir(), 5*1024*1024, []string{".jpg"})
+			mockIdentRepo := &mockIdentificationRepository{}
+			handler := NewIdentifyHandler(
+				&mockMLClient{},
+				&mockChatService{careGuide: &db.CareGuide{Sunlight: "Generated sunlight"}},
+				&mockCareInstructionsRepository{},
+				&mockCareDataService{},
+				fileUploader,
+				mockIdentRepo,
+				0.4,
+			)
+			handler.SetSingleTokenSpecies(tt.singleTokenSpecies)
+
+			mlResponse := &models.MLInferenceResponse{
+				Predictions: []models.MLPrediction{
+					{Label: "lithops", Confidence: 0.9},
+				},
+			}
+
+			response, err := handler.processMLResponse(mlResponse, "/uploads/test.jpg", processOptions{})
+			if err != nil {
+				t.Fatalf("processMLResponse() unexpected error: %v", err)
+			}
+
+			if response.Plant.Genus != "Lithops" {
+				t.Errorf("Expected genus Lithops, got %q", response.Plant.Genus)
+			}
+			if response.Plant.Species != tt.expectedSpecies {
+				t.Errorf("Expected species %q, got %q", tt.expectedSpecies, response.Plant.Species)
+			}
+			if response.Plant.SpeciesEpithet != "" {
+				t.Errorf("Expected no epithet for a single-token label, got %q", response.Plant.SpeciesEpithet)
+			}
+
+			saved := mockIdentRepo.lastCreated
+			if saved == nil {
+				t.Fatal("Expected identification to be saved")
+			}
+			expectedStored := ""
+			if tt.expectedSpecies != "" {
+				expectedStored = "lithops"
+			}
+			if saved.Species != expectedStored {
+				t.Errorf("Expected stored species %q, got %q", expectedStored, saved.Species)
+			}
+		})
+	}
+}
+
+func TestProcessMLResponseUncertain(t *testing.T) {
+	fileUploader, _ := utils.NewFileUploader(t.TempDir(), 5*1024*1024, []string{".jpg"})
+	mockIdentRepo := &mockIdentificationRepository{}
+	careRepo := &mockCareInstructionsRepository{}
+	chatService := &mockChatService{careGuide: &db.CareGuide{Sunlight: "Generated sunlight"}}
+	handler := NewIdentifyHandler(
+		&mockMLClient{},
+		chatService,
+		careRepo,
+		&mockCareDataService{},
+		fileUploader,
+		mockIdentRepo,
+		0.4,
+	)
+	handler.SetConfidenceFloor(0.15)
+
+	mlResponse := &models.MLInferenceResponse{
+		Predictions: []models.MLPrediction{
+			{Label: "haworthia_zebrina", Confidence: 0.09},
+			{Label: "aloe_vera", Confidence: 0.08},
+			{Label: "gasteria", Confidence: 0.07},
+			{Label: "echeveria_elegans", Confidence: 0.06},
+		},
+	}
+
+	response, err := handler.processMLResponse(mlResponse, "/uploads/test.jpg", processOptions{})
+	if err != nil {
+		t.Fatalf("processMLResponse() unexpected error: %v", err)
+	}
+
+	if !response.Uncertain {
+		t.Error("Expected uncertain response")
+	}
+	if response.Care != nil || response.CareStatus != db.CareStatusNone {
+		t.Errorf("Expected no care, got %+v (status %s)", response.Care, response.CareStatus)
+	}
+	if len(chatService.careCalls) != 0 || careRepo.createCalls != 0 {
+		t.Error("Expected no care generation for an uncertain identification")
+	}
+
+	expected := []models.CandidatePrediction{
+		{Genus: "Haworthia", Species: "Haworthia zebrina", SpeciesEpithet: "zebrina", Confidence: 0.09},
+		{Genus: "Aloe", Species: "Aloe vera", SpeciesEpithet: "vera", Confidence: 0.08},
+		{Genus: "Gasteria", Confidence: 0.07},
+	}
+	if len(response.Candidates) != len(expected) {
+		t.Fatalf("Expected %d candidates, got %+v", len(expected), response.Candidates)
+	}
+	for i, candidate := range response.Candidates {
+		if candidate != expected[i] {
+			t.Errorf("Candidate %d = %+v, expected %+v", i, candidate, expected[i])
+		}
+	}
+
+	if saved := mockIdentRepo.lastCreated; saved == nil || saved.CareStatus != db.CareStatusNone || saved.CareGuide != nil {
+		t.Errorf("Expected identification saved without care, got %+v", saved)
+	}
+
+	// At the floor the result is treated normally
+	mlResponse.Predictions[0].Confidence = 0.15
+	response, err = handler.processMLResponse(mlResponse, "/uploads/test.jpg", processOptions{})
+	if err != nil {
+		t.Fatalf("processMLResponse() unexpected error: %v", err)
+	}
+	if response.Uncertain || response.Candidates != nil || response.Care == nil {
+		t.Errorf("Expected a regular response at the floor, got %+v", response)
+	}
+}
+
+func TestProcessMLResponsePinnedGenus(t *testing.T) {
+	careService, err := services.NewCareDataService("../testdata/care_data_test.json")
+	if err != nil {
+		t.Fatalf("Failed to create care service: %v", err)
+	}
+	fileUploader, _ := utils.NewFileUploader(t.TempDir(), 5*1024*1024, []string{".jpg"})
+
+	tests := []struct {
+		name             string
+		label            string
+		expectedSunlight string
+		expectCacheRead  bool
+	}{
+		{
+			name:             "Pinned genus uses static data",
+			label:            "test_genus_species",
+			expectedSunlight: "Species-level sunlight",
+			expectCacheRead:  false,
+		},
+		{
+			name:             "Other genera use the cache",
+			label:            "aloe_vera",
+			expectedSunlight: "Cached sunlight",
+			expectCacheRead:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			careRepo := &mockCareInstructionsRepository{
+				getResult: &db.CareInstructionsCache{CareGuide: &db.CareGuide{Sunlight: "Cached sunlight"}},
+			}
+			chatService := &mockChatService{careGuide: &db.CareGuide{Sunlight: "Generated sunlight"}}
+			handler := NewIdentifyHandler(
+				&mockMLClient{},
+				chatService,
+				careRepo,
+				careService,
+				fileUploader,
+				&mockIdentificationRepository{},
+				0.4,
+			)
+			handler.SetPinnedGenera([]string{" Test "})
+			handler.SetAsyncCare(true) // pinned genera never wait on background generation
+
+			mlResponse := &models.MLInferenceResponse{
+				Predictions: []models.MLPrediction{{Label: tt.label, Confidence: 0.9}},
+			}
+			response, err := handler.processMLResponse(mlResponse, "/uploads/test.jpg", processOptions{})
+			if err != nil {
+				t.Fatalf("processMLResponse() unexpected error: %v", err)
+			}
+
+			if response.Care == nil || response.Care.Sunlight != tt.expectedSunlight {
+				t.Errorf("Care = %+v, expected sunlight %q", response.Care, tt.expectedSunlight)
+			}
+			if response.CareStatus != db.CareStatusReady {
+				t.Errorf("CareStatus = %s, expected %s", response.CareStatus, db.CareStatusReady)
+			}
+			if (careRepo.getCalls > 0) != tt.expectCacheRead {
+				t.Errorf("Cache read %d times, expected read: %v", careRepo.getCalls, tt.expectCacheRead)
+			}
+			if len(chatService.careCalls) != 0 || careRepo.createCalls != 0 {
+				t.Error("Expected no LLM generation or caching")
+			}
+		})
+	}
+}
+
+func TestProcessMLResponseCareSourceOrder(t *testing.T) {
+	careService, err := services.NewCareDataService("../testdata/care_data_test.json")
+	if err != nil {
+		t.Fatalf("Failed to create care service: %v", err)
+	}
+	fileUploader, _ := utils.NewFileUploader(t.TempDir(), 5*1024*1024, []string{".jpg"})
+
+	// The plant has both static care data and cached care
+	tests := []struct {
+		name             string
+		order            []string
+		careErr          error
+		expectedSunlight string
+		expectCacheRead  bool
+		expectGenerated  bool
+	}{
+		{
+			name:             "Cache before static",
+			order:            []string{"cache", "static", "llm"},
+			expectedSunlight: "Cached sunlight",
+			expectCacheRead:  true,
+		},
+		{
+			name:             "Static before cache",
+			order:            []string{"static", "cache", "llm"},
+			expectedSunlight: "Species-level sunlight",
+			expectCacheRead:  false,
+		},
+		{
+			name:             "LLM first falls back to the next sources",
+			order:            []string{"llm", "static"},
+			careErr:          errors.New("llm unavailable"),
+			expectedSunlight: "Species-level sunlight",
+			expectCacheRead:  false,
+			expectGenerated:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			careRepo := &mockCareInstructionsRepository{
+				getResult: &db.CareInstructionsCache{CareGuide: &db.CareGuide{Sunlight: "Cached sunlight"}},
+			}
+			chatService := &mockChatService{careGuide: &db.CareGuide{Sunlight: "Generated sunlight"}, careErr: tt.careErr}
+			handler := NewIdentifyHandler(
+				&mockMLClient{},
+				chatService,
+				careRepo,
+				careService,
+				fileUploader,
+				&mockIdentificationRepository{},
+				0.4,
+			)
+			if err := handler.SetCareSourceOrder(tt.order); err != nil {
+				t.Fatalf("SetCareSourceOrder() unexpected error: %v", err)
+			}
+
+			mlResponse := &models.MLInferenceResponse{
+				Predictions: []models.MLPrediction{{Label: "test_genus_species", Confidence: 0.9}},
+			}
+			response, err := handler.processMLResponse(mlResponse, "/uploads/test.jpg", processOptions{})
+			if err != nil {
+				t.Fatalf("processMLResponse() unexpected error: %v", err)
+			}
+
+			if response.Care == nil || response.Care.Sunlight != tt.expectedSunlight {
+				t.Errorf("Care = %+v, expected sunlight %q", response.Care, tt.expectedSunlight)
+			}
+			if (careRepo.getCalls > 0) != tt.expectCacheRead {
+				t.Errorf("Cache read %d times, expected read: %v", careRepo.getCalls, tt.expectCacheRead)
+			}
+			if (len(chatService.careCalls) > 0) != tt.expectGenerated {
+				t.Errorf("LLM called %d times, expected generation: %v", len(chatService.careCalls), tt.expectGenerated)
+			}
+		})
+	}
+
+	t.Run("Invalid orders", func(t *testing.T) {
+		handler := NewIdentifyHandler(&mockMLClient{}, nil, &mockCareInstructionsRepository{}, careService, fileUploader, &mockIdentificationRepository{}, 0.4)
+		for _, order := range [][]string{nil, {"cache", "database"}, {"cache", "llm", "cache"}} {
+			if err := handler.SetCareSourceOrder(order); err == nil {
+				t.Errorf("SetCareSourceOrder(%v) expected an error", order)
+			}
+		}
+		if err := handler.SetCareSourceOrder([]string{" Static ", "LLM"}); err != nil {
+			t.Errorf("SetCareSourceOrder() unexpected error: %v", err)
+		}
+	})
+}
+
+func TestProcessMLResponseWarmsSiblingCare(t *testing.T) {
+	speciesCounts := []db.SpeciesCount{
+		{SpeciesKey: db.SpeciesKey{Genus: "echeveria", Species: ""}, Count: 12},
+		{SpeciesKey: db.SpeciesKey{Genus: "echeveria", Species: "echeveria_elegans"}, Count: 9},
+		{SpeciesKey: db.SpeciesKey{Genus: "echeveria", Species: "echeveria_lola"}, Count: 7},
+		{SpeciesKey: db.SpeciesKey{Genus: "haworthia", Species: "haworthia_attenuata"}, Count: 6},
+		{SpeciesKey: db.SpeciesKey{Genus: "echeveria", Species: "echeveria_agavoides"}, Count: 5},
+		{SpeciesKey: db.SpeciesKey{Genus: "echeveria", Species: "echeveria_pulvinata"}, Count: 2},
+		{SpeciesKey: db.SpeciesKey{Genus: "haworthia", Species: "haworthia_zebrina"}, Count: 1},
+	}
+
+	tests := []struct {
+		name              string
+		label             string
+		genera            []string
+		expectedGenerated []string
+	}{
+		{
+			name:   "Configured genus warms its most identified siblings",
+			label:  "echeveria_elegans",
+			genera: []string{"Echeveria"},
+			// echeveria_lola is already cached, so only echeveria_agavoides is generated
+			expectedGenerated: []string{"echeveria/echeveria_agavoides", "echeveria/echeveria_elegans"},
+		},
+		{
+			name:              "Other genera are not warmed",
+			label:             "haworthia_zebrina",
+			genera:            []string{"echeveria"},
+			expectedGenerated: []string{"haworthia/haworthia_zebrina"},
+		},
+		{
+			name:              "Every genus when none are configured",
+			label:             "haworthia_zebrina",
+			expectedGenerated: []string{"haworthia/haworthia_attenuata", "haworthia/haworthia_zebrina"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fileUploader, _ := utils.NewFileUploader(t.TempDir(), 5*1024*1024, []string{".jpg"})
+			identRepo := &mockIdentificationRepository{speciesCounts: speciesCounts}
+			careRepo := &mockCareInstructionsRepository{entries: map[careKey]*db.CareInstructionsCache{
+				{genus: "echeveria", species: "lola"}: {CareGuide: &db.CareGuide{Sunlight: "Cached lola sunlight"}},
+			}}
+			chatService := &mockChatService{careGuide: &db.CareGuide{Sunlight: "Generated sunlight"}}
+			handler := NewIdentifyHandler(
+				&mockMLClient{},
+				chatService,
+				careRepo,
+				&mockCareDataService{},
+				fileUploader,
+				identRepo,
+				0.4,
+			)
+			handler.SetSiblingCareWarm(identRepo, 2, tt.genera)
+
+			mlResponse := &models.MLInferenceResponse{
+				Predictions: []models.MLPrediction{{Label: tt.label, Confidence: 0.9}},
+			}
+			if _, err := handler.processMLResponse(mlResponse, "/uploads/test.jpg", processOptions{}); err != nil {
+				t.Fatalf("processMLResponse() unexpected error: %v", err)
+			}
+			handler.careJobs.Wait()
+
+			generated := slices.Sorted(maps.Keys(chatService.careCalls))
+			if !slices.Equal(generated, tt.expectedGenerated) {
+				t.Errorf("Generated care for %v, expected %v", generated, tt.expectedGenerated)
+			}
+		})
+	}
+
+	t.Run("A genus is warmed once per interval", func(t *testing.T) {
+		fileUploader, _ := utils.NewFileUploader(t.TempDir(), 5*1024*1024, []string{".jpg"})
+		identRepo := &mockIdentificationRepository{speciesCounts: speciesCounts}
+		chatService := &mockChatService{careGuide: &db.CareGuide{Sunlight: "Generated sunlight"}}
+		handler := NewIdentifyHandler(
+			&mockMLClient{},
+			chatService,
+			&mockCareInstructionsRepository{},
+			&mockCareDataService{},
+			fileUploader,
+			identRepo,
+			0.4,
+		)
+		handler.SetSiblingCareWarm(identRepo, 2, nil)
+
+		for _, label := range []string{"echeveria_elegans", "echeveria_lola", "haworthia_zebrina"} {
+			mlResponse := &models.MLInferenceResponse{
+				Predictions: []models.MLPrediction{{Label: label, Confidence: 0.9}},
+			}
+			if _, err := handler.processMLResponse(mlResponse, "/uploads/test.jpg", processOptions{}); err != nil {
+				t.Fatalf("processMLResponse() unexpected error: %v", err)
+			}
+			handler.careJobs.Wait()
+		}
+
+		if identRepo.speciesCountCalls != 2 {
+			t.Errorf("Counted species %d times, expected once per genus", identRepo.speciesCountCalls)
+		}
+	})
+
+	t.Run("A failed species count does not hold the genus back", func(t *testing.T) {
+		fileUploader, _ := utils.NewFileUploader(t.TempDir(), 5*1024*1024, []string{".jpg"})
+		identRepo := &mockIdentificationRepository{speciesCounts: speciesCounts, speciesCountsErr: errors.New("db down")}
+		chatService := &mockChatService{careGuide: &db.CareGuide{Sunlight: "Generated sunlight"}}
+		handler := NewIdentifyHandler(
+			&mockMLClient{},
+			chatService,
+			&mockCareInstructionsRepository{},
+			&mockCareDataService{},
+			fileUploader,
+			identRepo,
+			0.4,
+		)
+		handler.SetSiblingCareWarm(identRepo, 2, nil)
+
+		identify := func() {
+			mlResponse := &models.MLInferenceResponse{
+				Predictions: []models.MLPrediction{{Label: "haworthia_zebrina", Confidence: 0.9}},
+			}
+			if _, err := handler.processMLResponse(mlResponse, "/uploads/test.jpg", processOptions{}); err != nil {
+				t.Fatalf("processMLResponse() unexpected error: %v", err)
+			}
+			handler.careJobs.Wait()
+		}
+
+		identify()
+		identRepo.speciesCountsErr = nil
+		identify()
+
+		if identRepo.speciesCountCalls != 2 {
+			t.Errorf("Counted species %d times, expected a retry after the failed count", identRepo.speciesCountCalls)
+		}
+		if _, ok := chatService.careCalls["haworthia/haworthia_attenuata"]; !ok {
+			t.Errorf("Expected sibling care warmed after the failed count, generated %v", slices.Sorted(maps.Keys(chatService.careCalls)))
+		}
+	})
+}
+
+func TestProcessMLResponseStaticCarePatterns(t *testing.T) {
+	careService, err := services.NewCareDataService("../testdata/care_data_test.json")
+	if err != nil {
+		t.Fatalf("Failed to create care service: %v", err)
+	}
+	fileUploader, _ := utils.NewFileUploader(t.TempDir(), 5*1024*1024, []string{".jpg"})
+
+	tests := []struct {
+		name             string
+		label            string
+		expectedSunlight string
+		expectGenerated  bool
+	}{
+		{
+			name:             "Matching label uses static data",
+			label:            "test_genus_species",
+			expectedSunlight: "Species-level sunlight",
+		},
+		{
+			name:             "Other labels are generated",
+			label:            "aloe_vera",
+			expectedSunlight: "Generated sunlight",
+			expectGenerated:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			careRepo := &mockCareInstructionsRepository{}
+			chatService := &mockChatService{careGuide: &db.CareGuide{Sunlight: "Generated sunlight"}}
+			handler := NewIdentifyHandler(
+				&mockMLClient{},
+				chatService,
+				careRepo,
+				careService,
+				fileUploader,
+				&mockIdentificationRepository{},
+				0.4,
+			)
+			// Invalid patterns are ignored
+			handler.SetStaticCarePatterns([]string{" Test_* ", "[invalid"})
+
+			mlResponse := &models.MLInferenceResponse{
+				Predictions: []models.MLPrediction{{Label: tt.label, Confidence: 0.9}},
+			}
+			response, err := handler.processMLResponse(mlResponse, "/uploads/test.jpg", processOptions{})
+			if err != nil {
+				t.Fatalf("processMLResponse() unexpected error: %v", err)
+			}
+
+			if response.Care == nil || response.Care.Sunlight != tt.expectedSunlight {
+				t.Errorf("Care = %+v, expected sunlight %q", response.Care, tt.expectedSunlight)
+			}
+			if generated := len(chatService.careCalls) > 0; generated != tt.expectGenerated {
+				t.Errorf("LLM care generated = %v, expected %v", generated, tt.expectGenerated)
+			}
+			if !tt.expectGenerated && careRepo.getCalls != 0 {
+				t.Errorf("Expected the LLM cache not to be read, got %d reads", careRepo.getCalls)
+			}
+		})
+	}
+}
+
+func TestCareCacheKeyedConsistently(t *testing.T) {
+	careRepo := &mockCareInstructionsRepository{entries: make(map[careKey]*db.CareInstructionsCache)}
+	chatService := &mockChatService{careGuide: &db.CareGuide{Sunlight: "Generated sunlight"}}
+	fileUploader, _ := utils.NewFileUploader(t.TempDir(), 5*1024*1024, []string{".jpg"})
+	handler := NewIdentifyHandler(
+		&mockMLClient{},
+		chatService,
+		careRepo,
+		&mockCareDataService{},
+		fileUploader,
+		&mockIdentificationRepository{},
+		0.4,
+	)
+
+	// Single identify stores care for the full label "echeveria_elegans"
+	mlResponse := &models.MLInferenceResponse{
+		Predictions: []models.MLPrediction{{Label: "echeveria_elegans", Confidence: 0.9}},
+	}
+	if _, err := handler.processMLResponse(mlResponse, "/uploads/test.jpg", processOptions{}); err != nil {
+		t.Fatalf("processMLResponse() unexpected error: %v", err)
+	}
+
+	if len(careRepo.entries) != 1 {
+		t.Fatalf("Expected 1 cache entry, got %d", len(careRepo.entries))
+	}
+	if careRepo.entries[careKey{genus: "echeveria", species: "elegans"}] == nil {
+		t.Errorf("Expected entry keyed (echeveria, elegans), got %v", careRepo.entries)
+	}
+
+	// The same plant passed as an epithet, in another case, or through batch resolves to that entry
+	for _, key := range []careKey{
+		{genus: "echeveria", species: "elegans"},
+		{genus: "Echeveria", species: "Echeveria_Elegans"},
+	} {
+		if guide := handler.cachedCareGuide(key.genus, key.species); guide == nil {
+			t.Errorf("cachedCareGuide(%q, %q) missed the cache", key.genus, key.species)
+		}
+	}
+	guides := handler.batchCareGuides([]careKey{{genus: "echeveria", species: "echeveria_elegans"}, {genus: "echeveria", species: "elegans"}})
+	for key, guide := range guides {
+		if guide == nil {
+			t.Errorf("batchCareGuides missed the cache for %+v", key)
+		}
+	}
+
+	if careRepo.createCalls != 1 || len(chatService.careCalls) != 1 {
+		t.Errorf("Expected one generation and cache write, got %d writes and calls %v", careRepo.createCalls, chatService.careCalls)
+	}
+
+	// An uncached plant appearing in both forms within one batch is generated once
+	guides = handler.batchCareGuides([]careKey{{genus: "aloe", species: "aloe_vera"}, {genus: "aloe", species: "vera"}})
+	if guides[careKey{genus: "aloe", species: "aloe_vera"}] == nil || guides[careKey{genus: "aloe", species: "vera"}] == nil {
+		t.Errorf("Expected care for both forms, got %v", guides)
+	}
+	if careRepo.createCalls != 2 || len(careRepo.entries) != 2 {
+		t.Errorf("Expected one more cache entry, got %d writes and %d entries", careRepo.createCalls, len(careRepo.entries))
+	}
+}
+
+// mockMLHealth reports a fixed ML service health status
+type mockMLHealth struct {
+	status services.MLHealthStatus
+}
+
+func (m *mockMLHealth) Status() services.MLHealthStatus {
+	return m.status
+}
+
+func TestIdentifyHandlerMLUnavailable(t *testing.T) {
+	unhealthy := &mockMLHealth{status: services.MLHealthStatus{
+		Healthy:   false,
+		CheckedAt: time.Now().UTC(),
+		LastError: errors.New("connection refused"),
+	}}
+
+	tests := []struct {
+		name    string
+		request func(t *testing.T) *http.Request
+		handle  func(h *IdentifyHandler) http.HandlerFunc
+	}{
+		{
+			name:    "Single identify",
+			request: func(t *testing.T) *http.Request { return createMultipartRequest(t, "test.jpg", []byte("fake image")) },
+			handle:  func(h *IdentifyHandler) http.HandlerFunc { return h.Handle },
+		},
+		{
+			name:    "Batch identify",
+			request: func(t *testing.T) *http.Request { return createBatchMultipartRequest(t, "1.jpg", "2.jpg") },
+			handle:  func(h *IdentifyHandler) http.HandlerFunc { return h.HandleBatch },
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			uploadDir := t.TempDir()
+			fileUploader, _ := utils.NewFileUploader(uploadDir, 5*1024*1024, []string{".jpg"})
+			mlClient := &mockMLClient{response: &models.MLInferenceResponse{
+				Predictions: []models.MLPrediction{{Label: "aloe_vera", Confidence: 0.9}},
+			}}
+			handler := NewIdentifyHandler(
+				mlClient,
+				nil,
+				&mockCareInstructionsRepository{},
+				&mockCareDataService{},
+				fileUploader,
+				&mockIdentificationRepository{},
+				0.4,
+			)
+			handler.SetMLHealth(unhealthy)
+
+			rr := httptest.NewRecorder()
+			tt.handle(handler)(rr, tt.request(t))
+
+			if rr.Code != http.StatusServiceUnavailable {
+				t.Fatalf("Expected status 503, got %d: %s", rr.Code, rr.Body.String())
+			}
+			var response models.ErrorResponse
+			if err := json.NewDecoder(rr.Body).Decode(&response); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+			if !strings.Contains(response.Message, "temporarily unavailable") {
+				t.Errorf("Expected unavailable message, got %q", response.Message)
+			}
+			if mlClient.calls != 0 {
+				t.Errorf("Expected no inference attempts, got %d", mlClient.calls)
+			}
+			if entries, _ := os.ReadDir(uploadDir); len(entries) != 0 {
+				t.Errorf("Expected no uploads saved, found %d", len(entries))
+			}
+
+			// Once the ML service recovers requests go through again
+			handler.SetMLHealth(&mockMLHealth{status: services.MLHealthStatus{Healthy: true}})
+			rr = httptest.NewRecorder()
+			tt.handle(handler)(rr, tt.request(t))
+			if rr.Code != http.StatusOK {
+				t.Errorf("Expected status 200 after recovery, got %d: %s", rr.Code, rr.Body.String())
+			}
+		})
+	}
+}
+
+func TestIdentifyHandlerAlternatives(t *testing.T) {
+	predictions := []models.MLPrediction{
+		{Label: "haworthia_zebrina", Confidence: 0.7},
+		{Label: "haworthia_attenuata", Confidence: 0.15},
+		{Label: "gasteria", Confidence: 0.08},
+		{Label: "aloe_aristata", Confidence: 0.04},
+		{Label: "aloe_vera", Confidence: 0.03},
+	}
+
+	tests := []struct {
+		name           string
+		query          string
+		expectedStatus int
+		expectedLabels []string
+	}{
+		{
+			name:           "Configured default",
+			query:          "",
+			expectedStatus: http.StatusOK,
+			expectedLabels: []string{"Haworthia attenuata", "Gasteria"},
+		},
+		{
+			name:           "No alternatives",
+			query:          "alternatives=0",
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "Custom count",
+			query:          "alternatives=1",
+			expectedStatus: http.StatusOK,
+			expectedLabels: []string{"Haworthia attenuata"},
+		},
+		{
+			name:           "Count over the cap is capped",
+			query:          "alternatives=50",
+			expectedStatus: http.StatusOK,
+			expectedLabels: []string{"Haworthia attenuata", "Gasteria", "Aloe aristata"},
+		},
+		{
+			name:           "Negative count",
+			query:          "alternatives=-1",
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "Non-numeric count",
+			query:          "alternatives=many",
+			expectedStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fileUploader, _ := utils.NewFileUploader(t.TempDir(), 5*1024*1024, []string{".jpg"})
+			handler := NewIdentifyHandler(
+				&mockMLClient{response: &models.MLInferenceResponse{Predictions: predictions}},
+				nil,
+				&mockCareInstructionsRepository{},
+				&mockCareDataService{},
+				fileUploader,
+				&mockIdentificationRepository{},
+				0.4,
+			)
+			handler.SetAlternatives(2, 3)
+
+			req := createMultipartRequest(t, "test.jpg", []byte("fake image"))
+			req.URL.RawQuery = tt.query
+			rr := httptest.NewRecorder()
+			handler.Handle(rr, req)
+
+			if rr.Code != tt.expectedStatus {
+				t.Fatalf("Expected status %d, got %d: %s", tt.expectedStatus, rr.Code, rr.Body.String())
+			}
+			if tt.expectedStatus != http.StatusOK {
+				return
+			}
+
+			var response models.IdentifyResponse
+			if err := json.NewDecoder(rr.Body).Decode(&response); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+			if len(response.Alternatives) != len(tt.expectedLabels) {
+				t.Fatalf("Expected %d alternatives, got %+v", len(tt.expectedLabels), response.Alternatives)
+			}
+			for i, alternative := range response.Alternatives {
+				label := alternative.Genus
+				if alternative.Species != "" {
+					label = alternative.Species
+				}
+				if label != tt.expectedLabels[i] {
+					t.Errorf("Alternative %d: expected %q, got %q", i, tt.expectedLabels[i], label)
+				}
+			}
+		})
+	}
+}
+
+func TestIdentifyHandlerSchemaVersion(t *testing.T) {
+	predictions := []models.MLPrediction{
+		{Label: "haworthia_zebrina", Confidence: 0.8},
+		{Label: "haworthia_attenuata", Confidence: 0.2},
+	}
+
+	tests := []struct {
+		name             string
+		query            string
+		accept           string
+		expectedStatus   int
+		expectedVersion  float64
+		expectedFields   []string
+		unexpectedFields []string
+	}{
+		{
+			name:            "Current version by default",
+			expectedStatus:  http.StatusOK,
+			expectedVersion: 2,
+			expectedFields:  []string{"id", "plant", "care_status", "alternatives"},
+		},
+		{
+			name:             "Version 1 by query",
+			query:            "api_version=1",
+			expectedStatus:   http.StatusOK,
+			expectedVersion:  1,
+			expectedFields:   []string{"id", "identified", "plant", "care", "care_status"},
+			unexpectedFields: []string{"alternatives"},
+		},
+		{
+			name:             "Version 1 by Accept header",
+			accept:           "application/vnd.succulent.v1+json",
+			expectedStatus:   http.StatusOK,
+			expectedVersion:  1,
+			unexpectedFields: []string{"alternatives"},
+		},
+		{
+			name:            "Version 2 by query",
+			query:           "api_version=2",
+			expectedStatus:  http.StatusOK,
+			expectedVersion: 2,
+			expectedFields:  []string{"alternatives"},
+		},
+		{
+			name:            "Query takes precedence over Accept",
+			query:           "api_version=2",
+			accept:          "application/vnd.succulent.v1+json",
+			expectedStatus:  http.StatusOK,
+			expectedVersion: 2,
+		},
+		{
+			name:           "Unknown version",
+			query:          "api_version=3",
+			expectedStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fileUploader, _ := utils.NewFileUploader(t.TempDir(), 5*1024*1024, []string{".jpg"})
+			handler := NewIdentifyHandler(
+				&mockMLClient{response: &models.MLInferenceResponse{Predictions: predictions}},
+				nil,
+				&mockCareInstructionsRepository{},
+				&mockCareDataService{care: models.CareInstructions{Sunlight: "Bright light"}},
+				fileUploader,
+				&mockIdentificationRepository{},
+				0.4,
+			)
+			handler.SetAlternatives(1, 3)
+
+			req := createMultipartRequest(t, "test.jpg", []byte("fake image"))
+			req.URL.RawQuery = tt.query
+			if tt.accept != "" {
+				req.Header.Set("Accept", tt.accept)
+			}
+			rr := httptest.NewRecorder()
+			handler.Handle(rr, req)
+
+			if rr.Code != tt.expectedStatus {
+				t.Fatalf("Expected status %d, got %d: %s", tt.expectedStatus, rr.Code, rr.Body.String())
+			}
+			if tt.expectedStatus != http.StatusOK {
+				return
+			}
+
+			var response map[string]any
+			if err := json.NewDecoder(rr.Body).Decode(&response); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+			if response["schema_version"] != tt.expectedVersion {
+				t.Errorf("Expected schema_version %v, got %v", tt.expectedVersion, response["schema_version"])
+			}
+			for _, field := range tt.expectedFields {
+				if _, ok := response[field]; !ok {
+					t.Errorf("Expected field %q in %v", field, response)
+				}
+			}
+			for _, field := range tt.unexpectedFields {
+				if _, ok := response[field]; ok {
+					t.Errorf("Unexpected field %q in version %v response", field, tt.expectedVersion)
+				}
+			}
+		})
+	}
+}
+
+func TestIdentifyHandlerRejectsMultipleImages(t *testing.T) {
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	for _, filename := range []string{"first.jpg", "second.jpg"} {
+		part, err := writer.CreateFormFile("image", filename)
+		if err != nil {
+			t.Fatalf("Failed to create form file: %v", err)
+		}
+		part.Write([]byte("fake image"))
+	}
+	writer.Close()
+
+	req := httptest.NewRequest(http.MethodPost, "/identify", body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+
+	uploadDir := t.TempDir()
+	fileUploader, _ := utils.NewFileUploader(uploadDir, 5*1024*1024, []string{".jpg"})
+	mlClient := &mockMLClient{response: &models.MLInferenceResponse{
+		Predictions: []models.MLPrediction{{Label: "aloe_vera", Confidence: 0.9}},
+	}}
+	handler := NewIdentifyHandler(
+		mlClient,
+		nil,
+		&mockCareInstructionsRepository{},
+		&mockCareDataService{},
+		fileUploader,
+		&mockIdentificationRepository{},
+		0.4,
+	)
+
+	rr := httptest.NewRecorder()
+	handler.Handle(rr, req)
+
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("Expected status 400, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var response models.ErrorResponse
+	if err := json.NewDecoder(rr.Body).Decode(&response); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if !strings.Contains(response.Message, "/identify/batch") {
+		t.Errorf("Expected message pointing to the batch endpoint, got %q", response.Message)
+	}
+	if mlClient.calls != 0 {
+		t.Errorf("Expected no inference attempts, got %d", mlClient.calls)
+	}
+	if entries, _ := os.ReadDir(uploadDir); len(entries) != 0 {
+		t.Errorf("Expected no uploads saved, found %d", len(entries))
+	}
+}
+
+func TestIdentifyHandlerHandleReidentify(t *testing.T) {
+	const id = "7c9e6679-7425-40de-944b-e07fc1f90ae7"
+	stored := &db.Identification{
+		ID:         id,
+		Genus:      "haworthia",
+		Species:    "haworthia_zebrina",
+		Confidence: 0.82,
+		ImagePath:  "/uploads/zebrina.jpg",
+		CareGuide:  &db.CareGuide{Sunlight: "Bright indirect light"},
+		CareStatus: db.CareStatusReady,
+		CreatedAt:  time.Now().Add(-24 * time.Hour),
+	}
+
+	tests := []struct {
+		name          string
+		prediction    models.MLPrediction
+		expectCreated bool
+	}{
+		{
+			name:          "Unchanged result updates the existing record",
+			prediction:    models.MLPrediction{Label: "haworthia_zebrina", Confidence: 0.825},
+			expectCreated: false,
+		},
+		{
+			name:          "Different species creates a new record",
+			prediction:    models.MLPrediction{Label: "haworthia_attenuata", Confidence: 0.82},
+			expectCreated: true,
+		},
+		{
+			name:          "Confidence beyond tolerance creates a new record",
+			prediction:    models.MLPrediction{Label: "haworthia_zebrina", Confidence: 0.91},
+			expectCreated: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			identRepo := &mockIdentificationRepository{getByIDResult: stored}
+			handler := NewIdentifyHandler(
+				&mockMLClient{response: &models.MLInferenceResponse{Predictions: []models.MLPrediction{tt.prediction}}},
+				nil,
+				&mockCareInstructionsRepository{},
+				&mockCareDataService{},
+				nil,
+				identRepo,
+				0.4,
+			)
+
+			rr := httptest.NewRecorder()
+			handler.HandleReidentify(rr, httptest.NewRequest(http.MethodPost, "/history/"+id+"/reidentify", nil))
+			if rr.Code != http.StatusOK {
+				t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+			}
+
+			var response models.IdentifyResponse
+			if err := json.NewDecoder(rr.Body).Decode(&response); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+
+			if tt.expectCreated {
+				if !identRepo.createCalled || identRepo.touchedID != "" {
+					t.Errorf("Expected a new record and no timestamp update, created=%v touched=%q", identRepo.createCalled, identRepo.touchedID)
+				}
+				if response.ID == id || response.Unchanged {
+					t.Errorf("Expected response for the new record, got %+v", response)
+				}
+				return
+			}
+
+			if identRepo.createCalled {
+				t.Error("Expected no new record for an unchanged re-identification")
+			}
+			if identRepo.touchedID != id || time.Since(identRepo.touchedAt) > time.Minute {
+				t.Errorf("Expected timestamp of %s updated to now, got %q at %v", id, identRepo.touchedID, identRepo.touchedAt)
+			}
+			if response.ID != id || !response.Unchanged || response.Care == nil || response.Care.Sunlight != "Bright indirect light" {
+				t.Errorf("Expected response for the existing record, got %+v", response)
+			}
+		})
+	}
+
+	t.Run("Blocked result keeps the stored image", func(t *testing.T) {
+		uploadDir := t.TempDir()
+		fileUploader, _ := utils.NewFileUploader(uploadDir, 5*1024*1024, []string{".jpg"})
+		imagePath := filepath.Join(uploadDir, "zebrina.jpg")
+		os.WriteFile(imagePath, []byte("image"), 0644)
+		withImage := *stored
+		withImage.ImagePath = imagePath
+
+		handler := NewIdentifyHandler(
+			&mockMLClient{response: &models.MLInferenceResponse{Predictions: []models.MLPrediction{{Label: "aloe_vera", Confidence: 0.9}}}},
+			nil,
+			&mockCareInstructionsRepository{},
+			&mockCareDataService{},
+			fileUploader,
+			&mockIdentificationRepository{getByIDResult: &withImage},
+			0.4,
+		)
+		handler.SetBlockedLabels([]string{"aloe"})
+
+		rr := httptest.NewRecorder()
+		handler.HandleReidentify(rr, httptest.NewRequest(http.MethodPost, "/history/"+id+"/reidentify", nil))
+		if rr.Code != http.StatusOK {
+			t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+		}
+		if _, err := os.Stat(imagePath); err != nil {
+			t.Errorf("Expected the image of the existing record to be kept: %v", err)
+		}
+	})
+
+	t.Run("Dedupe disabled always creates a new record", func(t *testing.T) {
+		identRepo := &mockIdentificationRepository{getByIDResult: stored}
+		handler := NewIdentifyHandler(
+			&mockMLClient{response: &models.MLInferenceResponse{Predictions: []models.MLPrediction{{Label: "haworthia_zebrina", Confidence: 0.82}}}},
+			nil,
+			&mockCareInstructionsRepository{},
+			&mockCareDataService{},
+			nil,
+			identRepo,
+			0.4,
+		)
+		handler.SetReidentifyDedupe(false, 0)
+
+		rr := httptest.NewRecorder()
+		handler.HandleReidentify(rr, httptest.NewRequest(http.MethodPost, "/history/"+id+"/reidentify", nil))
+		if rr.Code != http.StatusOK || !identRepo.createCalled || identRepo.touchedID != "" {
+			t.Errorf("Expected a new record, got status %d created=%v touched=%q", rr.Code, identRepo.createCalled, identRepo.touchedID)
+		}
+	})
+
+	t.Run("Stored image hash and care status are carried over", func(t *testing.T) {
+		hash := int64(-8512981244239201365)
+		generating := *stored
+		generating.ImageHash = &hash
+		generating.CareStatus = db.CareStatusGenerating
+
+		reidentify := func(prediction models.MLPrediction) (*mockIdentificationRepository, models.IdentifyResponse) {
+			identRepo := &mockIdentificationRepository{getByIDResult: &generating}
+			handler := NewIdentifyHandler(
+				&mockMLClient{response: &models.MLInferenceResponse{Predictions: []models.MLPrediction{prediction}}},
+				nil,
+				&mockCareInstructionsRepository{},
+				&mockCareDataService{},
+				nil,
+				identRepo,
+				0.4,
+			)
+
+			rr := httptest.NewRecorder()
+			handler.HandleReidentify(rr, httptest.NewRequest(http.MethodPost, "/history/"+id+"/reidentify", nil))
+			if rr.Code != http.StatusOK {
+				t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+			}
+			var response models.IdentifyResponse
+			if err := json.NewDecoder(rr.Body).Decode(&response); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+			return identRepo, response
+		}
+
+		identRepo, _ := reidentify(models.MLPrediction{Label: "haworthia_attenuata", Confidence: 0.82})
+		if identRepo.lastCreated == nil || identRepo.lastCreated.ImageHash == nil || *identRepo.lastCreated.ImageHash != hash {
+			t.Errorf("Expected the new record to keep image hash %d, got %+v", hash, identRepo.lastCreated)
+		}
+
+		_, response := reidentify(models.MLPrediction{Label: "haworthia_zebrina", Confidence: 0.82})
+		if !response.Unchanged || response.CareStatus != db.CareStatusGenerating {
+			t.Errorf("Expected unchanged response with care status %q, got unchanged=%v status %q", db.CareStatusGenerating, response.Unchanged, response.CareStatus)
+		}
+	})
+}
+
+func TestIdentifyHandlerHandleRegenerateCare(t *testing.T) {
+	const id = "7c9e6679-7425-40de-944b-e07fc1f90ae7"
+	regenerate := func(handler *IdentifyHandler) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		handler.HandleRegenerateCare(rr, httptest.NewRequest(http.MethodPost, "/history/"+id+"/care/regenerate", nil))
+		return rr
+	}
+
+	newHandler := func(careRepo *mockCareInstructionsRepository, identRepo *mockIdentificationRepository) *IdentifyHandler {
+		return NewIdentifyHandler(
+			&mockMLClient{},
+			&mockChatService{careGuide: &db.CareGuide{Sunlight: "Regenerated sunlight"}},
+			careRepo,
+			&mockCareDataService{},
+			nil,
+			identRepo,
+			0.4,
+		)
+	}
+
+	t.Run("Second regeneration within cooldown is rejected", func(t *testing.T) {
+		careRepo := &mockCareInstructionsRepository{entries: map[careKey]*db.CareInstructionsCache{}}
+		identRepo := &mockIdentificationRepository{
+			getByIDResult: &db.Identification{ID: id, Genus: "haworthia", Species: "haworthia_zebrina"},
+		}
+		handler := newHandler(careRepo, identRepo)
+		handler.SetRegenerateCooldown(time.Hour)
+
+		rr := regenerate(handler)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("Expected first regeneration to succeed, got %d: %s", rr.Code, rr.Body.String())
+		}
+		var response models.CareStatusResponse
+		if err := json.NewDecoder(rr.Body).Decode(&response); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		if response.Care == nil || response.Care.Sunlight != "Regenerated sunlight" {
+			t.Errorf("Expected regenerated care, got %+v", response.Care)
+		}
+		if identRepo.updatedCareID != id || identRepo.updatedCareStatus != db.CareStatusReady {
+			t.Errorf("Expected regenerated care stored on identification, got %s %s", identRepo.updatedCareID, identRepo.updatedCareStatus)
+		}
+		if careRepo.entries[careKey{genus: "haworthia", species: "zebrina"}] == nil {
+			t.Error("Expected regenerated care cached under the canonical key")
+		}
+
+		rr = regenerate(handler)
+		if rr.Code != http.StatusTooManyRequests {
+			t.Fatalf("Expected second regeneration to be rate limited, got %d: %s", rr.Code, rr.Body.String())
+		}
+		retryAfter, err := strconv.Atoi(rr.Header().Get("Retry-After"))
+		if err != nil || retryAfter <= 0 || retryAfter > 3600 {
+			t.Errorf("Expected Retry-After within the cooldown, got %q", rr.Header().Get("Retry-After"))
+		}
+		var errResponse models.ErrorResponse
+		json.NewDecoder(rr.Body).Decode(&errResponse)
+		if !strings.Contains(errResponse.Message, "try again in") {
+			t.Errorf("Expected time remaining in message, got %q", errResponse.Message)
+		}
+	})
+
+	t.Run("Regeneration after cooldown updates the cache entry", func(t *testing.T) {
+		stale := &db.CareInstructionsCache{
+			Genus:     "haworthia",
+			Species:   "zebrina",
+			CareGuide: &db.CareGuide{Sunlight: "Old sunlight"},
+			UpdatedAt: time.Now().Add(-2 * time.Hour),
+		}
+		careRepo := &mockCareInstructionsRepository{entries: map[careKey]*db.CareInstructionsCache{
+			{genus: "haworthia", species: "zebrina"}: stale,
+		}}
+		identRepo := &mockIdentificationRepository{
+			getByIDResult: &db.Identification{ID: id, Genus: "haworthia", Species: "haworthia_zebrina"},
+		}
+		handler := newHandler(careRepo, identRepo)
+		handler.SetRegenerateCooldown(time.Hour)
+
+		if rr := regenerate(handler); rr.Code != http.StatusOK {
+			t.Fatalf("Expected regeneration to succeed, got %d: %s", rr.Code, rr.Body.String())
+		}
+		entry := careRepo.entries[careKey{genus: "haworthia", species: "zebrina"}]
+		if entry.CareGuide.Sunlight != "Regenerated sunlight" || time.Since(entry.UpdatedAt) > time.Minute {
+			t.Errorf("Expected cache entry updated, got %+v", entry)
+		}
+		if careRepo.createCalls != 0 {
+			t.Errorf("Expected existing entry updated rather than created, got %d creates", careRepo.createCalls)
+		}
+	})
+
+	t.Run("Verified care is not regenerated", func(t *testing.T) {
+		careRepo := &mockCareInstructionsRepository{getResult: &db.CareInstructionsCache{
+			Genus:     "haworthia",
+			Species:   "zebrina",
+			Verified:  true,
+			UpdatedAt: time.Now().Add(-48 * time.Hour),
+		}}
+		identRepo := &mockIdentificationRepository{
+			getByIDResult: &db.Identification{ID: id, Genus: "haworthia", Species: "haworthia_zebrina"},
+		}
+
+		if rr := regenerate(newHandler(careRepo, identRepo)); rr.Code != http.StatusConflict {
+			t.Errorf("Expected status 409, got %d", rr.Code)
+		}
+	})
+}
+
+func TestIdentifyHandlerBlockedLabels(t *testing.T) {
+	tests := []struct {
+		name               string
+		label              string
+		expectedIdentified bool
+	}{
+		{name: "Blocked genus", label: "euphorbia_obesa", expectedIdentified: false},
+		{name: "Blocked species", label: "aloe_vera", expectedIdentified: false},
+		{name: "Allowed species of a genus with a blocked species", label: "aloe_aristata", expectedIdentified: true},
+		{name: "Allowed label", label: "haworthia_zebrina", expectedIdentified: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			uploadDir := t.TempDir()
+			fileUploader, _ := utils.NewFileUploader(uploadDir, 5*1024*1024, []string{".jpg"})
+			mockIdentRepo := &mockIdentificationRepository{}
+			careRepo := &mockCareInstructionsRepository{}
+			chatService := &mockChatService{careGuide: &db.CareGuide{Sunlight: "Generated sunlight"}}
+			handler := NewIdentifyHandler(
+				&mockMLClient{response: &models.MLInferenceResponse{
+					Predictions: []models.MLPrediction{
+						{Label: tt.label, Confidence: 0.9},
+						{Label: "euphorbia_milii", Confidence: 0.05},
+					},
+				}},
+				chatService,
+				careRepo,
+				&mockCareDataService{},
+				fileUploader,
+				mockIdentRepo,
+				0.4,
+			)
+			handler.SetBlockedLabels([]string{" Euphorbia ", "aloe_vera"})
+			handler.SetAlternatives(1, 1)
+
+			rr := httptest.NewRecorder()
+			handler.Handle(rr, createMultipartRequest(t, "test.jpg", []byte("fake image")))
+
+			if rr.Code != http.StatusOK {
+				t.Fatalf("Expected status 200, got %d: %s", rr.Code, rr.Body.String())
+			}
+			var response models.IdentifyResponse
+			if err := json.NewDecoder(rr.Body).Decode(&response); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+			if response.Identified != tt.expectedIdentified {
+				t.Fatalf("Expected identified=%v, got %+v", tt.expectedIdentified, response)
+			}
+			if response.Alternatives != nil {
+				t.Errorf("Expected blocked alternative to be dropped, got %+v", response.Alternatives)
+			}
+
+			// Only the upload of a saved identification is kept
+			entries, _ := os.ReadDir(uploadDir)
+			if tt.expectedIdentified != (len(entries) == 1) {
+				t.Errorf("Expected upload kept=%v, found %d files", tt.expectedIdentified, len(entries))
+			}
+
+			if tt.expectedIdentified {
+				if response.Care == nil || mockIdentRepo.lastCreated == nil {
+					t.Error("Expected allowed label to be saved with care")
+				}
+				return
+			}
+			if response.Plant.Genus != "" || response.Care != nil || response.CareStatus != db.CareStatusNone {
+				t.Errorf("Expected no plant or care for a blocked label, got %+v", response)
+			}
+			if response.Message == "" {
+				t.Error("Expected a not identified message")
+			}
+			if len(chatService.careCalls) != 0 || careRepo.getCalls != 0 {
+				t.Error("Expected no care lookup or generation for a blocked label")
+			}
+			if mockIdentRepo.lastCreated != nil {
+				t.Errorf("Expected blocked identification not to be saved, got %+v", mockIdentRepo.lastCreated)
+			}
+		})
+	}
+}
+
+// mockScanner reports every scanned file as infected
+type mockScanner struct {
+	scanned []string
+}
+
+func (m *mockScanner) Scan(path string) error {
+	m.scanned = append(m.scanned, path)
+	return fmt.Errorf("%w: Eicar-Test-Signature", utils.ErrInfected)
+}
+
+func TestIdentifyHandlerRejectsInfectedUpload(t *testing.T) {
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	part, err := writer.CreateFormFile("image", "plant.jpg")
+	if err != nil {
+		t.Fatalf("Failed to create form file: %v", err)
+	}
+	part.Write([]byte("X5O!P%@AP[4\\PZX54(P^)7CC)7}$EICAR-STANDARD-ANTIVIRUS-TEST-FILE!$H+H*"))
+	writer.Close()
+
+	req := httptest.NewRequest(http.MethodPost, "/identify", body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+
+	uploadDir := t.TempDir()
+	fileUploader, _ := utils.NewFileUploader(uploadDir, 5*1024*1024, []string{".jpg"})
+	scanner := &mockScanner{}
+	if err := fileUploader.SetScanner(scanner, ""); err != nil {
+		t.Fatalf("Failed to set scanner: %v", err)
+	}
+	mlClient := &mockMLClient{response: &models.MLInferenceResponse{
+		Predictions: []models.MLPrediction{{Label: "aloe_vera", Confidence: 0.9}},
+	}}
+	handler := NewIdentifyHandler(
+		mlClient,
+		nil,
+		&mockCareInstructionsRepository{},
+		&mockCareDataService{},
+		fileUploader,
+		&mockIdentificationRepository{},
+		0.4,
+	)
+
+	rr := httptest.NewRecorder()
+	handler.Handle(rr, req)
+
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("Expected status 400, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if len(scanner.scanned) != 1 {
+		t.Fatalf("Expected the upload to be scanned once, got %d scans", len(scanner.scanned))
+	}
+	if _, err := os.Stat(scanner.scanned[0]); !os.IsNotExist(err) {
+		t.Errorf("Expected infected upload %s to be removed", scanner.scanned[0])
+	}
+	if entries, _ := os.ReadDir(uploadDir); len(entries) != 0 {
+		t.Errorf("Expected no uploads kept, found %d", len(entries))
+	}
+	if mlClient.calls != 0 {
+		t.Errorf("Expected no inference attempts, got %d", mlClient.calls)
+	}
+}
+
+func TestIdentifyHandlerNonMultipartBody(t *testing.T) {
+	tests := []struct {
+		name            string
+		batch           bool
+		contentType     string
+		body            string
+		expectedStatus  int
+		expectedMessage string
+	}{
+		{
+			name:            "JSON body",
+			contentType:     "application/json",
+			body:            `{"image":"aGVsbG8="}`,
+			expectedStatus:  http.StatusUnsupportedMediaType,
+			expectedMessage: "must be multipart/form-data",
+		},
+		{
+			name:            "Missing Content-Type",
+			body:            "raw image bytes",
+			expectedStatus:  http.StatusUnsupportedMediaType,
+			expectedMessage: "got Content-Type none",
+		},
+		{
+			name:            "JSON body to batch",
+			batch:           true,
+			contentType:     "application/json",
+			body:            `{"images":[]}`,
+			expectedStatus:  http.StatusUnsupportedMediaType,
+			expectedMessage: "must be multipart/form-data",
+		},
+		{
+			name:            "Missing boundary",
+			contentType:     "multipart/form-data",
+			body:            "--x\r\n",
+			expectedStatus:  http.StatusBadRequest,
+			expectedMessage: "boundary",
+		},
+		{
+			name:            "Truncated multipart body",
+			contentType:     "multipart/form-data; boundary=xyz",
+			body:            "--xyz\r\nContent-Disposition: form-data; name=\"image\"; filename=\"a.jpg\"\r\n\r\nabc",
+			expectedStatus:  http.StatusBadRequest,
+			expectedMessage: "Malformed multipart body",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			uploadDir := t.TempDir()
+			fileUploader, _ := utils.NewFileUploader(uploadDir, 5*1024*1024, []string{".jpg"})
+			mlClient := &mockMLClient{}
+			handler := NewIdentifyHandler(
+				mlClient,
+				nil,
+				&mockCareInstructionsRepository{},
+				&mockCareDataService{},
+				fileUploader,
+				&mockIdentificationRepository{},
+				0.4,
+			)
+
+			path := "/identify"
+			if tt.batch {
+				path = "/identify/batch"
+			}
+			req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(tt.body))
+			if tt.contentType != "" {
+				req.Header.Set("Content-Type", tt.contentType)
+			}
+			rr := httptest.NewRecorder()
+			if tt.batch {
+				handler.HandleBatch(rr, req)
+			} else {
+				handler.Handle(rr, req)
+			}
+
+			if rr.Code != tt.expectedStatus {
+				t.Fatalf("Expected status %d, got %d: %s", tt.expectedStatus, rr.Code, rr.Body.String())
+			}
+			var response models.ErrorResponse
+			if err := json.NewDecoder(rr.Body).Decode(&response); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+			if !strings.Contains(response.Message, tt.expectedMessage) {
+				t.Errorf("Expected message containing %q, got %q", tt.expectedMessage, response.Message)
+			}
+			if mlClient.calls != 0 {
+				t.Errorf("Expected no inference attempts, got %d", mlClient.calls)
+			}
+			if entries, _ := os.ReadDir(uploadDir); len(entries) != 0 {
+				t.Errorf("Expected no uploads saved, found %d", len(entries))
+			}
+		})
+	}
+}
+
+func TestIdentifyHandlerStoresImageMetadata(t *testing.T) {
+	var fixture bytes.Buffer
+	if err := png.Encode(&fixture, image.NewRGBA(image.Rect(0, 0, 40, 30))); err != nil {
+		t.Fatalf("Failed to encode fixture: %v", err)
+	}
+
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	part, err := writer.CreateFormFile("image", "plant.png")
+	if err != nil {
+		t.Fatalf("Failed to create form file: %v", err)
+	}
+	part.Write(fixture.Bytes())
+	writer.Close()
+
+	req := httptest.NewRequest(http.MethodPost, "/identify", body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+
+	fileUploader, _ := utils.NewFileUploader(t.TempDir(), 5*1024*1024, []string{".png"})
+	identRepo := &mockIdentificationRepository{}
+	handler := NewIdentifyHandler(
+		&mockMLClient{response: &models.MLInferenceResponse{
+			Predictions: []models.MLPrediction{{Label: "aloe_vera", Confidence: 0.9}},
+		}},
+		nil,
+		&mockCareInstructionsRepository{},
+		&mockCareDataService{care: models.CareInstructions{Sunlight: "Full sun"}},
+		fileUploader,
+		identRepo,
+		0.4,
+	)
+
+	rr := httptest.NewRecorder()
+	handler.Handle(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	if identRepo.lastCreated == nil {
+		t.Fatal("Expected the identification to be saved")
+	}
+	stored := identRepo.lastCreated.ImageMetadata
+	if stored == nil || stored.Width != 40 || stored.Height != 30 || stored.Format != "png" || stored.Size != int64(fixture.Len()) {
+		t.Fatalf("Expected 40x30 png metadata of %d bytes, got %+v", fixture.Len(), stored)
+	}
+
+	// The detail response surfaces what was stored
+	identRepo.getByIDResult = identRepo.lastCreated
+	rr = httptest.NewRecorder()
+	NewHistoryHandler(identRepo, &mockChatRepository{}).HandleGetByID(rr, httptest.NewRequest(http.MethodGet, "/history/"+identRepo.lastCreated.ID, nil))
+
+	var detail models.HistoryDetailResponse
+	if err := json.NewDecoder(rr.Body).Decode(&detail); err != nil {
+		t.Fatalf("Failed to decode detail response: %v", err)
+	}
+	if detail.ImageMetadata == nil || detail.ImageMetadata.Width != 40 || detail.ImageMetadata.Format != "png" {
+		t.Errorf("Expected image metadata in detail response, got %+v", detail.ImageMetadata)
+	}
+}
+
+func TestIdentifyHandlerRetriesTransientInferenceFailure(t *testing.T) {
+	defer func(delay time.Duration) { inferenceRetryDelay = delay }(inferenceRetryDelay)
+	inferenceRetryDelay = 0
+
+	tests := []struct {
+		name           string
+		retry          bool
+		firstErr       error
+		expectedStatus int
+		expectedCalls  int
+	}{
+		{
+			name:           "Transient failure is retried",
+			retry:          true,
+			firstErr:       &services.MLStatusError{StatusCode: http.StatusServiceUnavailable},
+			expectedStatus: http.StatusOK,
+			expectedCalls:  2,
+		},
+		{
+			name:           "Non-transient failure is not retried",
+			retry:          true,
+			firstErr:       errors.New("ML service returned no predictions"),
+			expectedStatus: http.StatusInternalServerError,
+			expectedCalls:  1,
+		},
+		{
+			name:           "Retry disabled",
+			retry:          false,
+			firstErr:       &services.MLStatusError{StatusCode: http.StatusServiceUnavailable},
+			expectedStatus: http.StatusInternalServerError,
+			expectedCalls:  1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body := &bytes.Buffer{}
+			writer := multipart.NewWriter(body)
+			part, _ := writer.CreateFormFile("image", "plant.jpg")
+			part.Write([]byte("fake image data"))
+			writer.Close()
+
+			req := httptest.NewRequest(http.MethodPost, "/identify", body)
+			req.Header.Set("Content-Type", writer.FormDataContentType())
+
+			mlClient := &mockMLClient{
+				response: &models.MLInferenceResponse{Predictions: []models.MLPrediction{{Label: "aloe_vera", Confidence: 0.9}}},
+				errs:     []error{tt.firstErr},
+			}
+			fileUploader, _ := utils.NewFileUploader(t.TempDir(), 5*1024*1024, []string{".jpg"})
+			handler := NewIdentifyHandler(
+				mlClient,
+				nil,
+				&mockCareInstructionsRepository{},
+				&mockCareDataService{care: models.CareInstructions{Sunlight: "Full sun"}},
+				fileUploader,
+				&mockIdentificationRepository{},
+				0.4,
+			)
+			handler.SetInferenceRetry(tt.retry)
+
+			rr := httptest.NewRecorder()
+			handler.Handle(rr, req)
+
+			if rr.Code != tt.expectedStatus {
+				t.Errorf("Expected status %d, got %d: %s", tt.expectedStatus, rr.Code, rr.Body.String())
+			}
+			if mlClient.calls != tt.expectedCalls {
+				t.Errorf("Expected %d inference calls, got %d", tt.expectedCalls, mlClient.calls)
+			}
+		})
+	}
+
+	// Batch and re-identification infer through the same retry
+	newRetryingHandler := func(uploadDir string, identRepo *mockIdentificationRepository) (*IdentifyHandler, *mockMLClient) {
+		mlClient := &mockMLClient{
+			response: &models.MLInferenceResponse{Predictions: []models.MLPrediction{{Label: "aloe_vera", Confidence: 0.9}}},
+			errs:     []error{&services.MLStatusError{StatusCode: http.StatusServiceUnavailable}},
+		}
+		fileUploader, _ := utils.NewFileUploader(uploadDir, 5*1024*1024, []string{".jpg"})
+		handler := NewIdentifyHandler(
+			mlClient,
+			nil,
+			&mockCareInstructionsRepository{},
+			&mockCareDataService{care: models.CareInstructions{Sunlight: "Full sun"}},
+			fileUploader,
+			identRepo,
+			0.4,
+		)
+		handler.SetInferenceRetry(true)
+		return handler, mlClient
+	}
+
+	t.Run("Batch retries a transient failure", func(t *testing.T) {
+		handler, mlClient := newRetryingHandler(t.TempDir(), &mockIdentificationRepository{})
+
+		rr := httptest.NewRecorder()
+		handler.HandleBatch(rr, createBatchMultipartRequest(t, "1.jpg"))
+
+		var response models.BatchIdentifyResponse
+		if err := json.NewDecoder(rr.Body).Decode(&response); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		if response.Succeeded != 1 || mlClient.calls != 2 {
+			t.Errorf("Expected the image identified on retry, got %d succeeded with %d inference calls", response.Succeeded, mlClient.calls)
+		}
+	})
+
+	t.Run("Re-identification retries a transient failure", func(t *testing.T) {
+		uploadDir := t.TempDir()
+		imagePath := filepath.Join(uploadDir, "plant.jpg")
+		os.WriteFile(imagePath, []byte("image"), 0644)
+		const id = "7c9e6679-7425-40de-944b-e07fc1f90ae7"
+		handler, mlClient := newRetryingHandler(uploadDir, &mockIdentificationRepository{
+			getByIDResult: &db.Identification{ID: id, Genus: "aloe", Species: "aloe_vera", Confidence: 0.5, ImagePath: imagePath},
+		})
+
+		rr := httptest.NewRecorder()
+		handler.HandleReidentify(rr, httptest.NewRequest(http.MethodPost, "/history/"+id+"/reidentify", nil))
+		if rr.Code != http.StatusOK || mlClient.calls != 2 {
+			t.Errorf("Expected status %d after a retry, got %d with %d inference calls: %s", http.StatusOK, rr.Code, mlClient.calls, rr.Body.String())
+		}
+	})
+}
+
+// mockFailedIdentificationRepository records failed identifications in memory
+type mockFailedIdentificationRepository struct {
+	failures []*db.FailedIdentification
+}
+
+func (m *mockFailedIdentificationRepository) Create(failure *db.FailedIdentification) error {
+	m.failures = append(m.failures, failure)
+	return nil
+}
+
+func TestIdentifyHandlerRecordsFailedIdentifications(t *testing.T) {
+	tests := []struct {
+		name          string
+		enabled       bool
+		mlErr         error
+		expectedCount int
+	}{
+		{
+			name:          "Failed inference is recorded",
+			enabled:       true,
+			mlErr:         errors.New("ML service returned no predictions"),
+			expectedCount: 1,
+		},
+		{
+			name:          "Successful inference is not recorded",
+			enabled:       true,
+			expectedCount: 0,
+		},
+		{
+			name:          "Recording disabled",
+			enabled:       false,
+			mlErr:         errors.New("ML service returned no predictions"),
+			expectedCount: 0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mlClient := &mockMLClient{
+				response: &models.MLInferenceResponse{Predictions: []models.MLPrediction{{Label: "aloe_vera", Confidence: 0.9}}},
+				err:      tt.mlErr,
+			}
+			fileUploader, _ := utils.NewFileUploader(t.TempDir(), 5*1024*1024, []string{".jpg"})
+			handler := NewIdentifyHandler(
+				mlClient,
+				nil,
+				&mockCareInstructionsRepository{},
+				&mockCareDataService{care: models.CareInstructions{Sunlight: "Full sun"}},
+				fileUploader,
+				&mockIdentificationRepository{},
+				0.4,
+			)
+			failures := &mockFailedIdentificationRepository{}
+			if tt.enabled {
+				handler.SetFailureRecorder(failures)
+			}
+
+			rr := httptest.NewRecorder()
+			handler.Handle(rr, createMultipartRequest(t, "plant.jpg", []byte("fake image data")))
+
+			if len(failures.failures) != tt.expectedCount {
+				t.Fatalf("Expected %d recorded failures, got %d", tt.expectedCount, len(failures.failures))
+			}
+			if tt.expectedCount == 0 {
+				return
+			}
+			if rr.Code != http.StatusInternalServerError {
+				t.Errorf("Expected status 500, got %d", rr.Code)
+			}
+			failure := failures.failures[0]
+			if failure.ID == "" || failure.ImagePath == "" || failure.Error != tt.mlErr.Error() {
+				t.Errorf("Expected failure with ID, image path and error %q, got %+v", tt.mlErr, failure)
+			}
+		})
+	}
+}
+
+// mockTimingRepository stores request timings by identification ID
+type mockTimingRepository struct {
+	timings map[string]*db.RequestTiming
+	listed  []db.Identification
+	err     error
+}
+
+func (m *mockTimingRepository) SaveTiming(id string, timing *db.RequestTiming) error {
+	if m.timings == nil {
+		m.timings = map[string]*db.RequestTiming{}
+	}
+	m.timings[id] = timing
+	return m.err
+}
+
+func (m *mockTimingRepository) ListTimings(limit int) ([]db.Identification, error) {
+	return m.listed, m.err
+}
+
+func TestIdentifyHandlerRecordsTiming(t *testing.T) {
+	mlClient := &mockMLClient{
+		response: &models.MLInferenceResponse{Predictions: []models.MLPrediction{{Label: "aloe_vera", Confidence: 0.9}}},
+	}
+	fileUploader, _ := utils.NewFileUploader(t.TempDir(), 5*1024*1024, []string{".jpg"})
+	handler := NewIdentifyHandler(
+		mlClient,
+		&mockChatService{careGuide: &db.CareGuide{Sunlight: "Generated sunlight"}, careDelay: 30 * time.Millisecond},
+		&mockCareInstructionsRepository{},
+		&mockCareDataService{},
+		fileUploader,
+		&mockIdentificationRepository{},
+		0.4,
+	)
+	timings := &mockTimingRepository{}
+	handler.SetTimingRecorder(timings)
+
+	rr := httptest.NewRecorder()
+	handler.Handle(rr, createMultipartRequest(t, "plant.jpg", []byte("fake image data")))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	var response models.IdentifyResponse
+	if err := json.NewDecoder(rr.Body).Decode(&response); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	timing := timings.timings[response.ID]
+	if timing == nil {
+		t.Fatalf("Expected timing recorded for %s, got %v", response.ID, timings.timings)
+	}
+
+	for name, ms := range map[string]float64{
+		"upload": timing.UploadMs, "inference": timing.InferenceMs, "care": timing.CareMs, "save": timing.SaveMs,
+	} {
+		if ms < 0 {
+			t.Errorf("Expected non-negative %s duration, got %v", name, ms)
+		}
+	}
+	// Care generation is the slow step here
+	if timing.CareMs < 30 {
+		t.Errorf("Expected care to take at least 30ms, got %v", timing.CareMs)
+	}
+
+	// The steps account for nearly all of the request
+	sum := timing.UploadMs + timing.InferenceMs + timing.CareMs + timing.SaveMs
+	if sum > timing.TotalMs+0.01 {
+		t.Errorf("Steps sum to %vms, more than the %vms total", sum, timing.TotalMs)
+	}
+	if sum < 0.8*timing.TotalMs {
+		t.Errorf("Steps sum to %vms, far below the %vms total", sum, timing.TotalMs)
+	}
+}
+
+func TestIdentifyHandlerRecordsCareCacheMetrics(t *testing.T) {
+	careRepo := &mockCareInstructionsRepository{entries: map[careKey]*db.CareInstructionsCache{
+		{genus: "aloe", species: "vera"}: {Genus: "aloe", Species: "vera", CareGuide: &db.CareGuide{Sunlight: "Full sun"}},
+	}}
+	metrics := utils.NewCareCacheMetrics()
+	handler := NewIdentifyHandler(&mockMLClient{}, nil, careRepo, &mockCareDataService{}, nil, &mockIdentificationRepository{}, 0.4)
+	handler.SetCareCacheMetrics(metrics)
+
+	for _, label := range []string{"aloe_vera", "aloe_vera", "lithops_karasmontana"} {
+		mlResponse := &models.MLInferenceResponse{Predictions: []models.MLPrediction{{Label: label, Confidence: 0.9}}}
+		if _, err := handler.processMLResponse(mlResponse, "/test/image.jpg", processOptions{}); err != nil {
+			t.Fatalf("processMLResponse() unexpected error: %v", err)
+		}
+	}
+
+	if ratio := metrics.HitRatio(); math.Abs(ratio-2.0/3) > 1e-9 {
+		t.Errorf("Expected hit ratio 2/3, got %v", ratio)
+	}
+
+	rr := httptest.NewRecorder()
+	NewMetricsHandler(metrics).Handle(rr, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if !strings.Contains(rr.Body.String(), `care_cache_genus_hits_total{genus="aloe"} 2`) {
+		t.Errorf("Expected per-genus hits in metrics, got:\n%s", rr.Body.String())
+	}
+}
+
+func TestIdentifyHandlerHandleCareByCommonName(t *testing.T) {
+	careRepo := &mockCareInstructionsRepository{entries: map[careKey]*db.CareInstructionsCache{}}
+	chatSvc := &mockChatService{careGuide: &db.CareGuide{Sunlight: "Bright light", Watering: "Sparingly"}}
+	handler := NewIdentifyHandler(&mockMLClient{}, chatSvc, careRepo, &mockCareDataService{}, nil, &mockIdentificationRepository{}, 0.4)
+
+	lookup := func(query string) (*httptest.ResponseRecorder, models.CommonNameCareResponse) {
+		rr := httptest.NewRecorder()
+		handler.HandleCareByCommonName(rr, httptest.NewRequest(http.MethodGet, "/care/by-common-name"+query, nil))
+		var response models.CommonNameCareResponse
+		if rr.Code == http.StatusOK {
+			if err := json.NewDecoder(rr.Body).Decode(&response); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+		}
+		return rr, response
+	}
+
+	if rr, _ := lookup("?name=jade+plant"); rr.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 without common names, got %d", rr.Code)
+	}
+
+	handler.SetCommonNames(utils.NewCommonNames(map[string][]string{
+		"echeveria_minima":             {"hens and chicks"},
+		"echeveria_perle_von_nurnberg": {"Hens and Chicks", "perle von nurnberg"},
+		"crassula_ovata":               {"jade plant"},
+	}))
+
+	t.Run("Unique common name", func(t *testing.T) {
+		rr, response := lookup("?name=Jade+Plant")
+		if rr.Code != http.StatusOK {
+			t.Fatalf("Expected status 200, got %d: %s", rr.Code, rr.Body.String())
+		}
+		if response.Plant == nil || response.Plant.Label != "crassula_ovata" || response.Plant.Species != "Crassula ovata" {
+			t.Errorf("Unexpected plant: %+v", response.Plant)
+		}
+		if response.Care == nil || response.Care.Sunlight != "Bright light" || len(response.Candidates) != 0 {
+			t.Errorf("Expected generated care and no candidates, got %+v", response)
+		}
+
+		// The generated care is cached, so a second lookup does not generate again
+		lookup("?name=jade+plant")
+		if calls := chatSvc.careCalls["crassula/crassula_ovata"]; calls != 1 {
+			t.Errorf("Expected care to be generated once, got %d", calls)
+		}
+	})
+
+	t.Run("Ambiguous common name", func(t *testing.T) {
+		rr, response := lookup("?name=hens+and+chicks")
+		if rr.Code != http.StatusOK {
+			t.Fatalf("Expected status 200, got %d: %s", rr.Code, rr.Body.String())
+		}
+		if response.Plant != nil || response.Care != nil {
+			t.Errorf("Expected no plant or care for an ambiguous name, got %+v", response)
+		}
+		if len(response.Candidates) != 2 ||
+			response.Candidates[0].Label != "echeveria_minima" ||
+			response.Candidates[1].Label != "echeveria_perle_von_nurnberg" {
+			t.Errorf("Unexpected candidates: %+v", response.Candidates)
+		}
+	})
+
+	for query, status := range map[string]int{
+		"?name=snake+plant": http.StatusNotFound,
+		"?name=+":           http.StatusBadRequest,
+		"":                  http.StatusBadRequest,
+	} {
+		if rr, _ := lookup(query); rr.Code != status {
+			t.Errorf("Lookup %q: expected status %d, got %d", query, status, rr.Code)
+		}
+	}
+}
+
+func TestIdentifyHandlerDedupWindow(t *testing.T) {
+	uploadDir := t.TempDir()
+	fileUploader, _ := utils.NewFileUploader(uploadDir, 5*1024*1024, []string{".jpg"})
+	identRepo := &mockIdentificationRepository{}
+	handler := NewIdentifyHandler(
+		&mockMLClient{response: &models.MLInferenceResponse{Predictions: []models.MLPrediction{{Label: "aloe_vera", Confidence: 0.9}}}},
+		nil,
+		&mockCareInstructionsRepository{},
+		&mockCareDataService{care: models.CareInstructions{Sunlight: "Full sun"}},
+		fileUploader,
+		identRepo,
+		0.4,
+	)
+	handler.SetDedupWindow(10 * time.Minute)
+
+	identify := func(remoteAddr string) models.IdentifyResponse {
+		t.Helper()
+		req := createMultipartRequest(t, "plant.jpg", []byte("fake image data"))
+		req.RemoteAddr = remoteAddr
+		rr := httptest.NewRecorder()
+		handler.Handle(rr, req)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("Expected status 200, got %d: %s", rr.Code, rr.Body.String())
+		}
+		var response models.IdentifyResponse
+		if err := json.NewDecoder(rr.Body).Decode(&response); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		return response
+	}
+
+	first := identify("203.0.113.7:5000")
+	if first.Deduplicated || len(identRepo.created) != 1 {
+		t.Fatalf("Expected a new record, got deduplicated=%v with %d records", first.Deduplicated, len(identRepo.created))
+	}
+	if identRepo.created[0].ClientID != "203.0.113.7" {
+		t.Errorf("Expected client ID 203.0.113.7, got %q", identRepo.created[0].ClientID)
+	}
+
+	t.Run("Same client within the window gets the earlier record", func(t *testing.T) {
+		response := identify("203.0.113.7:5001")
+		if !response.Deduplicated || response.ID != first.ID {
+			t.Errorf("Expected deduplicated record %s, got %s (deduplicated=%v)", first.ID, response.ID, response.Deduplicated)
+		}
+		if len(identRepo.created) != 1 {
+			t.Errorf("Expected no new record, got %d records", len(identRepo.created))
+		}
+		if entries, _ := os.ReadDir(uploadDir); len(entries) != 1 {
+			t.Errorf("Expected the duplicate upload to be deleted, %d files remain", len(entries))
+		}
+	})
+
+	t.Run("Another client gets a new record", func(t *testing.T) {
+		response := identify("198.51.100.2:5000")
+		if response.Deduplicated || len(identRepo.created) != 2 {
+			t.Errorf("Expected a new record, got deduplicated=%v with %d records", response.Deduplicated, len(identRepo.created))
+		}
+	})
+
+	t.Run("Same client outside the window gets a new record", func(t *testing.T) {
+		identRepo.created[0].CreatedAt = time.Now().UTC().Add(-11 * time.Minute)
+
+		response := identify("203.0.113.7:5002")
+		if response.Deduplicated || response.ID == first.ID {
+			t.Errorf("Expected a new record, got %s (deduplicated=%v)", response.ID, response.Deduplicated)
+		}
+		if len(identRepo.created) != 3 {
+			t.Errorf("Expected 3 records, got %d", len(identRepo.created))
+		}
+	})
+}
@@ -2,7 +2,10 @@ package handlers
 
 import (
 	"context"
+	"io"
 	"mime/multipart"
+	"time"
+
 	"succulent-identifier-backend/db"
 	"succulent-identifier-backend/models"
 	"succulent-identifier-backend/services"
@@ -17,7 +20,9 @@ type MLClientInterface interface {
 // FileUploaderInterface defines the interface for file uploader
 type FileUploaderInterface interface {
 	ValidateFile(fileHeader *multipart.FileHeader) error
-	SaveFile(file multipart.File, fileHeader *multipart.FileHeader) (string, error)
+	InspectFile(file multipart.File, fileHeader *multipart.FileHeader) (*models.ImageMetadata, error)
+	SaveFile(file multipart.File, fileHeader *multipart.FileHeader) (string, *models.ImageMetadata, error)
+	SaveOptimized(path string) (string, error)
 	DeleteFile(filepath string) error
 }
 
@@ -25,14 +30,76 @@ type FileUploaderInterface interface {
 type IdentificationRepositoryInterface interface {
 	Create(identification *db.Identification) error
 	GetByID(id string) (*db.Identification, error)
+	GetByIDs(ids []string) ([]db.Identification, error)
+	GetCare(id string) (*db.Identification, error)
+	UpdateCareGuide(id string, careGuide *db.CareGuide, careStatus string) error
+	Touch(id string, at time.Time) error
 	GetAll(limit, offset int) ([]db.Identification, error)
+	GetAllByGenus(limit, offset int) ([]db.Identification, error)
+	GetAllStream(fn func(db.Identification) error) error
+	FindSimilar(hash int64, distance int) ([]db.Identification, error)
+	FindRecentSameSpecies(clientID, genus, species string, since time.Time) (*db.Identification, error)
 	Count() (int, error)
+	CountGenera() (int, error)
+	GetMissingCare(limit int) ([]db.Identification, error)
 	Delete(id string) error
 }
 
+// SpeciesCountRepositoryInterface defines the interface for per-species identification counts
+type SpeciesCountRepositoryInterface interface {
+	CountBySpecies() ([]db.SpeciesCount, error)
+}
+
+// CareCacheListerInterface defines the interface for listing cached care species
+type CareCacheListerInterface interface {
+	ListSpecies() ([]db.CachedSpecies, error)
+	ListCare() ([]db.CareInstructionsCache, error)
+}
+
+// CareCacheFlusherInterface defines the interface for flushing cached care
+type CareCacheFlusherInterface interface {
+	FlushCache(genus string) (int64, error)
+}
+
+// CareCacheMetricsInterface defines the interface for care cache hit/miss metrics
+type CareCacheMetricsInterface interface {
+	RecordHit(genus string)
+	RecordMiss(genus string)
+	WritePrometheus(w io.Writer) error
+}
+
+// CareBackfillerInterface defines the interface for backfilling missing care
+type CareBackfillerInterface interface {
+	BackfillCare(limit int) (int, error)
+}
+
+// FailedIdentificationRepositoryInterface defines the interface for recording failed identifications
+type FailedIdentificationRepositoryInterface interface {
+	Create(failure *db.FailedIdentification) error
+}
+
+// TimingRecorderInterface defines the interface for storing identify request timing
+type TimingRecorderInterface interface {
+	SaveTiming(id string, timing *db.RequestTiming) error
+}
+
+// TimingListerInterface defines the interface for listing recorded identify request timing
+type TimingListerInterface interface {
+	ListTimings(limit int) ([]db.Identification, error)
+}
+
+// ErrorLogInterface defines the interface for reading recently recorded errors
+type ErrorLogInterface interface {
+	Recent() []models.ErrorEntry
+	Capacity() int
+}
+
 // ChatRepositoryInterface defines the interface for chat repository
 type ChatRepositoryInterface interface {
 	Create(message *db.ChatMessage) error
+	GetByID(id string) (*db.ChatMessage, error)
+	UpdateMessage(id, message string) error
+	Delete(id string) error
 	GetByIdentificationID(identificationID string) ([]db.ChatMessage, error)
 	GetLatestMessages(identificationID string, limit int) ([]db.ChatMessage, error)
 	CountByIdentificationID(identificationID string) (int, error)
@@ -50,3 +117,33 @@ type CareInstructionsRepositoryInterface interface {
 	Create(cache *db.CareInstructionsCache) error
 	Update(cache *db.CareInstructionsCache) error
 }
+
+// CareDataServiceInterface defines the interface for curated static care data
+type CareDataServiceInterface interface {
+	GetCareInstructions(species, genus string) (models.CareInstructions, error)
+}
+
+// CareDataKeysInterface defines the interface for listing curated static care keys
+type CareDataKeysInterface interface {
+	Keys() []string
+}
+
+// CareDataStatusInterface defines the interface for reporting care data load state
+type CareDataStatusInterface interface {
+	Status() services.CareDataStatus
+}
+
+// DatabasePingerInterface defines the interface for checking database connectivity
+type DatabasePingerInterface interface {
+	PingContext(ctx context.Context) error
+}
+
+// MLHealthInterface defines the interface for reporting ML service health
+type MLHealthInterface interface {
+	Status() services.MLHealthStatus
+}
+
+// CommonNamesInterface defines the interface for resolving plant common names to ML labels
+type CommonNamesInterface interface {
+	Lookup(name string) []string
+}
@@ -0,0 +1,31 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+)
+
+// MetricsHandler serves metrics in the Prometheus text exposition format
+type MetricsHandler struct {
+	careCache CareCacheMetricsInterface
+}
+
+// NewMetricsHandler creates a new metrics handler
+func NewMetricsHandler(careCache CareCacheMetricsInterface) *MetricsHandler {
+	return &MetricsHandler{careCache: careCache}
+}
+
+// Handle writes the current metrics for a Prometheus scrape
+func (h *MetricsHandler) Handle(w http.ResponseWriter, r *http.Request) {
+	// Only accept GET requests
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	w.WriteHeader(http.StatusOK)
+	if err := h.careCache.WritePrometheus(w); err != nil {
+		log.Printf("Failed to write metrics: %v", err)
+	}
+}
@@ -0,0 +1,189 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"maps"
+	"net/http"
+	"strings"
+
+	"succulent-identifier-backend/models"
+	"succulent-identifier-backend/utils"
+)
+
+// Routes groups the handlers served by the API
+type Routes struct {
+	Identify  *IdentifyHandler
+	History   *HistoryHandler
+	Share     *ShareHandler
+	Chat      *ChatHandler // nil when the LLM is not configured
+	Health    *HealthHandler
+	Config    *ConfigHandler
+	Stats     *StatsHandler
+	Metrics   *MetricsHandler // nil disables GET /metrics
+	Admin     *AdminHandler   // nil when no admin token is configured
+	UploadDir string          // empty disables the public /uploads/ file server
+
+	// Caps concurrent uploads per client IP on the identify endpoints (nil disables)
+	UploadLimiter *utils.UploadLimiter
+
+	// Bearer token required by the admin endpoints
+	AdminToken string
+}
+
+// RegisterRoutes registers every API endpoint on mux. Endpoints of disabled
+// features are left unregistered so they respond 404 like any unknown path,
+// and GET /features reports the effective flags so the frontend can adapt.
+func RegisterRoutes(mux *http.ServeMux, routes Routes, features utils.FeatureFlags) {
+	// Chat also needs a configured LLM
+	effective := maps.Clone(features)
+	if effective == nil {
+		effective = utils.FeatureFlags{}
+	}
+	effective[utils.FeatureChat] = features.Enabled(utils.FeatureChat) && routes.Chat != nil
+
+	// Health check endpoint
+	mux.HandleFunc("/health", routes.Health.HandleHealth)
+
+	// Plain text health endpoint for load balancers
+	mux.HandleFunc("/ping", routes.Health.HandlePing)
+
+	// Readiness endpoint
+	mux.HandleFunc("/ready", routes.Health.HandleReady)
+
+	// Client configuration endpoint
+	mux.HandleFunc("/config", routes.Config.Handle)
+
+	// Care lookups by common name, of cached species and the full care catalog
+	mux.HandleFunc("/care/by-common-name", routes.Identify.HandleCareByCommonName)
+	mux.HandleFunc("/care/species", routes.Stats.HandleCareSpecies)
+	mux.HandleFunc("/care/catalog", routes.Stats.HandleCareCatalog)
+
+	// Statistics endpoints
+	mux.HandleFunc("/stats/care-coverage", routes.Stats.HandleCareCoverage)
+
+	// Prometheus metrics endpoint
+	if routes.Metrics != nil {
+		mux.HandleFunc("/metrics", routes.Metrics.Handle)
+	}
+
+	// Feature flags endpoint
+	mux.HandleFunc("/features", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(models.FeaturesResponse{Features: effective})
+	})
+
+	// Root endpoint, and the JSON 404 of every path no other route matches
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" {
+			notFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		fmt.Fprintf(w, `{"service":"Succulent Identifier Backend","version":"1.0.0","endpoints":["/identify","/health","/ping","/ready","/config","/features","/stats/care-coverage"]}`)
+	})
+
+	// Admin endpoints
+	if routes.Admin != nil {
+		mux.Handle("/admin/care", utils.AdminAuthMiddleware(routes.AdminToken, http.HandlerFunc(routes.Admin.HandleFlushCare)))
+		mux.Handle("/admin/care/backfill", utils.AdminAuthMiddleware(routes.AdminToken, http.HandlerFunc(routes.Admin.HandleBackfillCare)))
+		mux.Handle("/admin/care/prompt", utils.AdminAuthMiddleware(routes.AdminToken, http.HandlerFunc(routes.Admin.HandleCarePrompt)))
+		mux.Handle("/admin/errors", utils.AdminAuthMiddleware(routes.AdminToken, http.HandlerFunc(routes.Admin.HandleRecentErrors)))
+		mux.Handle("/admin/timings", utils.AdminAuthMiddleware(routes.AdminToken, http.HandlerFunc(routes.Admin.HandleTimings)))
+		log.Println("Admin endpoints registered")
+	}
+
+	// Identify endpoints, all taking uploads
+	upload := func(handler http.HandlerFunc) http.Handler {
+		if routes.UploadLimiter == nil {
+			return handler
+		}
+		return utils.UploadLimitMiddleware(routes.UploadLimiter, handler)
+	}
+	mux.Handle("/identify", upload(routes.Identify.Handle))
+	mux.Handle("/identify/validate", upload(routes.Identify.HandleValidate))
+	mux.Handle("/identify/quick", upload(routes.Identify.HandleQuick))
+	if effective.Enabled(utils.FeatureBatchIdentify) {
+		mux.Handle("/identify/batch", upload(routes.Identify.HandleBatch))
+	}
+
+	// Chat endpoints
+	if effective.Enabled(utils.FeatureChat) {
+		mux.HandleFunc("/chat", routes.Chat.Handle)
+		mux.HandleFunc("/chat/compare", routes.Chat.HandleCompare)
+		mux.HandleFunc("/chat/message/", routes.Chat.HandleEditMessage)
+		mux.HandleFunc("/chat/", routes.History.HandleGetChatHistory)
+		log.Println("Chat endpoints registered")
+	}
+
+	// History endpoints
+	historyRouteHandler := func(w http.ResponseWriter, r *http.Request) {
+		// Route based on path and method
+		path := r.URL.Path
+
+		// Handle bulk fetch by IDs
+		if path == "/history/batch" {
+			routes.History.HandleBatch(w, r)
+			return
+		}
+
+		// Handle full history export
+		if path == "/history/export" {
+			routes.History.HandleExport(w, r)
+			return
+		}
+
+		// Handle history grouped by genus
+		if path == "/history/grouped" {
+			routes.History.HandleGrouped(w, r)
+			return
+		}
+
+		// Handle DELETE requests for specific identification
+		if r.Method == http.MethodDelete && path != "/history" && path != "/history/" {
+			routes.History.HandleDelete(w, r)
+			return
+		}
+
+		// Handle GET requests
+		if path == "/history" || path == "/history/" {
+			routes.History.HandleList(w, r)
+		} else if strings.HasSuffix(path, "/with-chat") {
+			routes.History.HandleGetWithChat(w, r)
+		} else if strings.HasSuffix(path, "/reidentify") {
+			routes.Identify.HandleReidentify(w, r)
+		} else if strings.HasSuffix(path, "/care/regenerate") {
+			routes.Identify.HandleRegenerateCare(w, r)
+		} else if strings.HasSuffix(path, "/care") {
+			routes.History.HandleGetCare(w, r)
+		} else if strings.HasSuffix(path, "/image") {
+			routes.History.HandleGetImage(w, r)
+		} else if strings.HasSuffix(path, "/share") {
+			if !effective.Enabled(utils.FeatureShare) {
+				notFound(w, r)
+				return
+			}
+			routes.Share.HandleShare(w, r)
+		} else {
+			routes.History.HandleGetByID(w, r)
+		}
+	}
+	// Register both /history and /history/ patterns to handle all history routes
+	mux.HandleFunc("/history", historyRouteHandler)
+	mux.HandleFunc("/history/", historyRouteHandler)
+	if effective.Enabled(utils.FeatureShare) {
+		mux.HandleFunc("/shared/", routes.Share.HandleShared)
+	}
+	log.Println("History endpoints registered")
+
+	// Serve uploaded images as static files, unless images must go through
+	// GET /history/{id}/image
+	if routes.UploadDir != "" {
+		fileServer := http.FileServer(http.Dir(routes.UploadDir))
+		mux.Handle("/uploads/", http.StripPrefix("/uploads/", fileServer))
+		log.Println("Static file server registered for uploads")
+	}
+}
@@ -0,0 +1,248 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"succulent-identifier-backend/db"
+	"succulent-identifier-backend/models"
+	"succulent-identifier-backend/utils"
+)
+
+// newTestRoutes builds the route handlers backed by mocks
+func newTestRoutes(t *testing.T, withChat bool) Routes {
+	identRepo := &mockIdentificationRepository{
+		getByIDResult: &db.Identification{ID: "7c9e6679-7425-40de-944b-e07fc1f90ae7", Genus: "haworthia", ImagePath: "/missing.jpg"},
+	}
+	chatRepo := &mockChatRepository{}
+	fileUploader, _ := utils.NewFileUploader(t.TempDir(), 5*1024*1024, []string{".jpg"})
+
+	routes := Routes{
+		Identify: NewIdentifyHandler(
+			&mockMLClient{},
+			&mockChatService{},
+			&mockCareInstructionsRepository{},
+			&mockCareDataService{},
+			fileUploader,
+			identRepo,
+			0.4,
+		),
+		History:   NewHistoryHandler(identRepo, chatRepo),
+		Share:     NewShareHandler(identRepo, []byte("test-secret"), time.Hour),
+		Health:    NewHealthHandler(nil),
+		Config:    NewConfigHandler(&utils.Config{}),
+		Stats:     NewStatsHandler(identRepo, &mockCareCacheLister{}, &mockCareDataService{}),
+		UploadDir: t.TempDir(),
+	}
+	if withChat {
+		routes.Chat = NewChatHandler(&mockChatService{}, identRepo, chatRepo)
+	}
+	return routes
+}
+
+func TestRegisterRoutesFeatureFlags(t *testing.T) {
+	tests := []struct {
+		name             string
+		features         utils.FeatureFlags
+		withChat         bool
+		expectedFeatures map[string]bool
+		requests         map[string]int // "METHOD path" -> expected status
+	}{
+		{
+			name: "All features enabled",
+			features: utils.FeatureFlags{
+				utils.FeatureChat:          true,
+				utils.FeatureShare:         true,
+				utils.FeatureBatchIdentify: true,
+			},
+			withChat: true,
+			expectedFeatures: map[string]bool{
+				utils.FeatureChat:          true,
+				utils.FeatureShare:         true,
+				utils.FeatureBatchIdentify: true,
+			},
+			requests: map[string]int{
+				"GET /history/7c9e6679-7425-40de-944b-e07fc1f90ae7/share":           http.StatusOK,
+				"GET /history/7c9e6679-7425-40de-944b-e07fc1f90ae7/care/regenerate": http.StatusMethodNotAllowed,
+				"GET /identify/batch": http.StatusMethodNotAllowed,
+				"GET /chat":           http.StatusMethodNotAllowed,
+				"GET /chat/compare":   http.StatusMethodNotAllowed,
+				"GET /chat/message/3ebcaf4b-5a6d-4e8f-9ac1-4c5d6e7f8091": http.StatusMethodNotAllowed,
+			},
+		},
+		{
+			name: "Disabled features return 404",
+			features: utils.FeatureFlags{
+				utils.FeatureChat:          false,
+				utils.FeatureShare:         false,
+				utils.FeatureBatchIdentify: false,
+			},
+			withChat: true,
+			expectedFeatures: map[string]bool{
+				utils.FeatureChat:          false,
+				utils.FeatureShare:         false,
+				utils.FeatureBatchIdentify: false,
+			},
+			requests: map[string]int{
+				"GET /history/7c9e6679-7425-40de-944b-e07fc1f90ae7/share": http.StatusNotFound,
+				"GET /shared/some-token":                                  http.StatusNotFound,
+				"POST /identify/batch":                                    http.StatusNotFound,
+				"POST /chat":                                              http.StatusNotFound,
+				"POST /chat/compare":                                      http.StatusNotFound,
+				"GET /chat/7c9e6679-7425-40de-944b-e07fc1f90ae7":          http.StatusNotFound,
+				"GET /history/7c9e6679-7425-40de-944b-e07fc1f90ae7":       http.StatusOK,
+			},
+		},
+		{
+			name: "Chat disabled without an LLM",
+			features: utils.FeatureFlags{
+				utils.FeatureChat: true,
+			},
+			withChat: false,
+			expectedFeatures: map[string]bool{
+				utils.FeatureChat: false,
+			},
+			requests: map[string]int{
+				"POST /chat": http.StatusNotFound,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mux := http.NewServeMux()
+			RegisterRoutes(mux, newTestRoutes(t, tt.withChat), tt.features)
+
+			rr := httptest.NewRecorder()
+			mux.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/features", nil))
+			if rr.Code != http.StatusOK {
+				t.Fatalf("GET /features returned %d", rr.Code)
+			}
+
+			var response models.FeaturesResponse
+			if err := json.NewDecoder(rr.Body).Decode(&response); err != nil {
+				t.Fatalf("Failed to decode features: %v", err)
+			}
+			for feature, enabled := range tt.expectedFeatures {
+				if response.Features[feature] != enabled {
+					t.Errorf("Feature %s = %v, expected %v", feature, response.Features[feature], enabled)
+				}
+			}
+
+			for request, expectedStatus := range tt.requests {
+				method, path, _ := strings.Cut(request, " ")
+				rr := httptest.NewRecorder()
+				mux.ServeHTTP(rr, httptest.NewRequest(method, path, nil))
+				if rr.Code != expectedStatus {
+					t.Errorf("%s returned %d, expected %d", request, rr.Code, expectedStatus)
+				}
+			}
+		})
+	}
+}
+
+func TestRegisterRoutesUnderBasePath(t *testing.T) {
+	mux := http.NewServeMux()
+	RegisterRoutes(mux, newTestRoutes(t, false), utils.FeatureFlags{utils.FeatureShare: true})
+	handler := utils.BasePathMiddleware("/api/succulent", mux)
+
+	requests := map[string]int{
+		"/api/succulent/health":   http.StatusOK,
+		"/api/succulent/features": http.StatusOK,
+		"/api/succulent/":         http.StatusOK,
+		"/health":                 http.StatusNotFound,
+	}
+	for path, expectedStatus := range requests {
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, path, nil))
+		if rr.Code != expectedStatus {
+			t.Errorf("GET %s returned %d, expected %d", path, rr.Code, expectedStatus)
+		}
+	}
+
+	// Generated links go through the prefix
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "http://example.com/api/succulent/history/7c9e6679-7425-40de-944b-e07fc1f90ae7/share", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Share returned %d: %s", rr.Code, rr.Body.String())
+	}
+	var share models.ShareResponse
+	if err := json.NewDecoder(rr.Body).Decode(&share); err != nil {
+		t.Fatalf("Failed to decode share response: %v", err)
+	}
+	if !strings.HasPrefix(share.URL, "http://example.com/api/succulent/shared/") {
+		t.Errorf("Expected share URL under the base path, got %s", share.URL)
+	}
+}
+
+func TestRegisterRoutesUnknownPathJSON404(t *testing.T) {
+	mux := http.NewServeMux()
+	RegisterRoutes(mux, newTestRoutes(t, false), utils.FeatureFlags{})
+
+	tests := []struct {
+		name    string
+		handler http.Handler
+		path    string
+	}{
+		{"unknown path", mux, "/does-not-exist"},
+		{"disabled share", mux, "/history/7c9e6679-7425-40de-944b-e07fc1f90ae7/share"},
+		{"outside base path", utils.BasePathMiddleware("/api/succulent", mux), "/health"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			tt.handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, tt.path, nil))
+
+			if rr.Code != http.StatusNotFound {
+				t.Fatalf("GET %s returned %d, expected 404", tt.path, rr.Code)
+			}
+			if contentType := rr.Header().Get("Content-Type"); contentType != "application/json" {
+				t.Errorf("Expected JSON content type, got %q", contentType)
+			}
+			var response models.ErrorResponse
+			if err := json.NewDecoder(rr.Body).Decode(&response); err != nil {
+				t.Fatalf("Failed to decode error response: %v", err)
+			}
+			if response.Error != "Not Found" {
+				t.Errorf("Expected error 'Not Found', got %q", response.Error)
+			}
+			if !strings.Contains(response.Message, tt.path) {
+				t.Errorf("Expected message to name %s, got %q", tt.path, response.Message)
+			}
+		})
+	}
+}
+
+func TestRegisterRoutesPublicUploads(t *testing.T) {
+	routes := newTestRoutes(t, false)
+	os.WriteFile(filepath.Join(routes.UploadDir, "abc.jpg"), []byte("image"), 0644)
+
+	tests := []struct {
+		name           string
+		uploadDir      string
+		expectedStatus int
+	}{
+		{name: "Enabled", uploadDir: routes.UploadDir, expectedStatus: http.StatusOK},
+		{name: "Disabled", expectedStatus: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			routes.UploadDir = tt.uploadDir
+			mux := http.NewServeMux()
+			RegisterRoutes(mux, routes, utils.FeatureFlags{})
+
+			rr := httptest.NewRecorder()
+			mux.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/uploads/abc.jpg", nil))
+			if rr.Code != tt.expectedStatus {
+				t.Errorf("GET /uploads/abc.jpg returned %d, expected %d", rr.Code, tt.expectedStatus)
+			}
+		})
+	}
+}
@@ -0,0 +1,150 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"succulent-identifier-backend/models"
+	"succulent-identifier-backend/utils"
+)
+
+// shareThumbnailSize is the longest side in pixels of embedded thumbnails
+const shareThumbnailSize = 256
+
+// ShareHandler handles sharing identifications through signed links
+type ShareHandler struct {
+	identificationRepo IdentificationRepositoryInterface
+	secret             []byte
+	tokenTTL           time.Duration
+}
+
+// NewShareHandler creates a new share handler
+func NewShareHandler(
+	identificationRepo IdentificationRepositoryInterface,
+	secret []byte,
+	tokenTTL time.Duration,
+) *ShareHandler {
+	return &ShareHandler{
+		identificationRepo: identificationRepo,
+		secret:             secret,
+		tokenTTL:           tokenTTL,
+	}
+}
+
+// HandleShare creates a share token and bundle for an identification
+func (h *ShareHandler) HandleShare(w http.ResponseWriter, r *http.Request) {
+	// Only accept GET requests
+	if r.Method != http.MethodGet {
+		h.sendError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	// Extract ID from URL path
+	// Expecting /history/:id/share
+	pathParts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	if len(pathParts) < 3 {
+		h.sendError(w, http.StatusBadRequest, "Missing identification ID")
+		return
+	}
+	id := pathParts[1]
+
+	bundle, err := h.buildBundle(id)
+	if err != nil {
+		log.Printf("Failed to get identification for sharing: %v", err)
+		h.sendError(w, http.StatusNotFound, "Identification not found")
+		return
+	}
+
+	expiresAt := time.Now().UTC().Add(h.tokenTTL)
+	token, err := utils.GenerateShareToken(h.secret, id, expiresAt)
+	if err != nil {
+		log.Printf("Failed to generate share token: %v", err)
+		h.sendError(w, http.StatusInternalServerError, "Failed to create share link")
+		return
+	}
+
+	response := models.ShareResponse{
+		Token:     token,
+		URL:       utils.RequestBaseURL(r) + utils.BasePathFromContext(r.Context()) + "/shared/" + token,
+		ExpiresAt: expiresAt,
+		Bundle:    *bundle,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
+
+// HandleShared serves the bundle of a shared identification to anyone holding a valid token
+func (h *ShareHandler) HandleShared(w http.ResponseWriter, r *http.Request) {
+	// Only accept GET requests
+	if r.Method != http.MethodGet {
+		h.sendError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	// Expecting /shared/:token
+	token := strings.TrimPrefix(r.URL.Path, "/shared/")
+	if token == "" {
+		h.sendError(w, http.StatusBadRequest, "Missing share token")
+		return
+	}
+
+	id, err := utils.ValidateShareToken(h.secret, token, time.Now())
+	if errors.Is(err, utils.ErrShareTokenExpired) {
+		h.sendError(w, http.StatusGone, "Share link has expired")
+		return
+	}
+	if err != nil {
+		h.sendError(w, http.StatusUnauthorized, "Invalid share link")
+		return
+	}
+
+	bundle, err := h.buildBundle(id)
+	if err != nil {
+		log.Printf("Failed to get shared identification: %v", err)
+		h.sendError(w, http.StatusNotFound, "Identification not found")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(bundle)
+}
+
+// buildBundle loads an identification and packages it with an embedded thumbnail
+func (h *ShareHandler) buildBundle(id string) (*models.ShareBundle, error) {
+	identification, err := h.identificationRepo.GetByID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	bundle := &models.ShareBundle{
+		ID:         identification.ID,
+		Genus:      identification.Genus,
+		Species:    identification.Species,
+		Confidence: identification.Confidence,
+		CareGuide:  careInstructionsFromGuide(identification.CareGuide),
+		CreatedAt:  identification.CreatedAt,
+	}
+
+	// A missing image shouldn't prevent sharing the identification itself
+	thumbnail, err := utils.GenerateThumbnail(identification.ImagePath, shareThumbnailSize)
+	if err != nil {
+		log.Printf("Failed to generate share thumbnail for %s: %v", id, err)
+	} else {
+		bundle.Thumbnail = "data:image/jpeg;base64," + base64.StdEncoding.EncodeToString(thumbnail)
+	}
+
+	return bundle, nil
+}
+
+// sendError sends an error response
+func (h *ShareHandler) sendError(w http.ResponseWriter, statusCode int, message string) {
+	writeError(w, statusCode, message)
+}
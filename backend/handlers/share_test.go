@@ -0,0 +1,136 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"succulent-identifier-backend/db"
+	"succulent-identifier-backend/models"
+	"succulent-identifier-backend/utils"
+)
+
+func TestShareHandlerRoundTrip(t *testing.T) {
+	imagePath := filepath.Join(t.TempDir(), "plant.png")
+	os.WriteFile(imagePath, encodeTestPNG(t, 400, 300), 0644)
+
+	mockIdentRepo := &mockIdentificationRepository{
+		getByIDResult: &db.Identification{
+			ID:         "7c9e6679-7425-40de-944b-e07fc1f90ae7",
+			Genus:      "haworthia",
+			Species:    "haworthia_zebrina",
+			Confidence: 0.92,
+			ImagePath:  imagePath,
+			CareGuide:  &db.CareGuide{Sunlight: "Bright indirect light"},
+			CreatedAt:  time.Now(),
+		},
+	}
+	secret := []byte("test-share-secret")
+	handler := NewShareHandler(mockIdentRepo, secret, time.Hour)
+
+	req := httptest.NewRequest(http.MethodGet, "/history/7c9e6679-7425-40de-944b-e07fc1f90ae7/share", nil)
+	rr := httptest.NewRecorder()
+	handler.HandleShare(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("HandleShare returned %d, expected %d", rr.Code, http.StatusOK)
+	}
+
+	var share models.ShareResponse
+	if err := json.NewDecoder(rr.Body).Decode(&share); err != nil {
+		t.Fatalf("Failed to decode share response: %v", err)
+	}
+	if share.Token == "" || share.URL != "http://example.com/shared/"+share.Token {
+		t.Errorf("Unexpected share link: %+v", share)
+	}
+	if !strings.HasPrefix(share.Bundle.Thumbnail, "data:image/jpeg;base64,") {
+		t.Error("Expected base64-embedded JPEG thumbnail in bundle")
+	}
+	if share.Bundle.CareGuide == nil || share.Bundle.CareGuide.Sunlight != "Bright indirect light" {
+		t.Errorf("Expected care guide in bundle, got %+v", share.Bundle.CareGuide)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, share.URL, nil)
+	rr = httptest.NewRecorder()
+	handler.HandleShared(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("HandleShared returned %d, expected %d", rr.Code, http.StatusOK)
+	}
+
+	var bundle models.ShareBundle
+	if err := json.NewDecoder(rr.Body).Decode(&bundle); err != nil {
+		t.Fatalf("Failed to decode bundle: %v", err)
+	}
+	if bundle.ID != "7c9e6679-7425-40de-944b-e07fc1f90ae7" || bundle.Species != "haworthia_zebrina" {
+		t.Errorf("Unexpected shared bundle: %+v", bundle)
+	}
+}
+
+func TestShareHandlerHandleShared(t *testing.T) {
+	secret := []byte("test-share-secret")
+
+	validToken, _ := utils.GenerateShareToken(secret, "7c9e6679-7425-40de-944b-e07fc1f90ae7", time.Now().Add(time.Hour))
+	expiredToken, _ := utils.GenerateShareToken(secret, "7c9e6679-7425-40de-944b-e07fc1f90ae7", time.Now().Add(-time.Minute))
+	foreignToken, _ := utils.GenerateShareToken([]byte("other-secret"), "7c9e6679-7425-40de-944b-e07fc1f90ae7", time.Now().Add(time.Hour))
+
+	tests := []struct {
+		name           string
+		token          string
+		identification *db.Identification
+		repoErr        error
+		expectedStatus int
+	}{
+		{
+			name:           "Valid token",
+			token:          validToken,
+			identification: &db.Identification{ID: "7c9e6679-7425-40de-944b-e07fc1f90ae7", Genus: "haworthia", ImagePath: "/missing.jpg"},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "Expired token",
+			token:          expiredToken,
+			expectedStatus: http.StatusGone,
+		},
+		{
+			name:           "Token signed with another secret",
+			token:          foreignToken,
+			expectedStatus: http.StatusUnauthorized,
+		},
+		{
+			name:           "Garbage token",
+			token:          "garbage",
+			expectedStatus: http.StatusUnauthorized,
+		},
+		{
+			name:           "Identification deleted since sharing",
+			token:          validToken,
+			repoErr:        db.ErrNotFound,
+			expectedStatus: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockIdentRepo := &mockIdentificationRepository{
+				getByIDResult: tt.identification,
+				getByIDErr:    tt.repoErr,
+			}
+			handler := NewShareHandler(mockIdentRepo, secret, time.Hour)
+
+			req := httptest.NewRequest(http.MethodGet, "/shared/"+tt.token, nil)
+			rr := httptest.NewRecorder()
+			handler.HandleShared(rr, req)
+
+			if rr.Code != tt.expectedStatus {
+				t.Errorf("Handler returned wrong status code: got %v, expected %v",
+					rr.Code, tt.expectedStatus)
+			}
+		})
+	}
+}
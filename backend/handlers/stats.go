@@ -0,0 +1,308 @@
+package handlers
+
+import (
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"succulent-identifier-backend/db"
+	"succulent-identifier-backend/models"
+	"succulent-identifier-backend/utils"
+)
+
+// maxUncoveredSample caps how many uncovered species the coverage report lists
+const maxUncoveredSample = 20
+
+// StatsHandler serves aggregate statistics about identifications
+type StatsHandler struct {
+	identificationRepo SpeciesCountRepositoryInterface
+	careRepo           CareCacheListerInterface
+	careData           CareDataServiceInterface // nil when no curated care data is loaded
+	careKeys           CareDataKeysInterface    // lists the curated care for the catalog, nil leaves it out
+	labelDelimiter     string
+}
+
+// NewStatsHandler creates a new stats handler
+func NewStatsHandler(
+	identificationRepo SpeciesCountRepositoryInterface,
+	careRepo CareCacheListerInterface,
+	careData CareDataServiceInterface,
+) *StatsHandler {
+	return &StatsHandler{
+		identificationRepo: identificationRepo,
+		careRepo:           careRepo,
+		careData:           careData,
+		labelDelimiter:     utils.DefaultLabelDelimiter,
+	}
+}
+
+// SetLabelDelimiter configures the separator between genus and species in stored species names
+func (h *StatsHandler) SetLabelDelimiter(delimiter string) {
+	if delimiter == "" {
+		delimiter = utils.DefaultLabelDelimiter
+	}
+	h.labelDelimiter = delimiter
+}
+
+// SetCareDataKeys configures the source of the curated care keys listed in
+// the care catalog
+func (h *StatsHandler) SetCareDataKeys(keys CareDataKeysInterface) {
+	h.careKeys = keys
+}
+
+// HandleCareCoverage reports how many distinct identified species have curated
+// care data or cached LLM care, and lists the most identified ones with neither
+func (h *StatsHandler) HandleCareCoverage(w http.ResponseWriter, r *http.Request) {
+	// Only accept GET requests
+	if r.Method != http.MethodGet {
+		h.sendError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	counts, err := h.identificationRepo.CountBySpecies()
+	if err != nil {
+		log.Printf("Failed to count identifications by species: %v", err)
+		h.sendError(w, http.StatusInternalServerError, "Failed to compute care coverage")
+		return
+	}
+
+	cachedKeys, err := h.careRepo.ListSpecies()
+	if err != nil {
+		log.Printf("Failed to list cached care: %v", err)
+		h.sendError(w, http.StatusInternalServerError, "Failed to compute care coverage")
+		return
+	}
+	cached := make(map[careKey]bool, len(cachedKeys))
+	for _, key := range cachedKeys {
+		cached[careKey{genus: key.Genus, species: key.Species}] = true
+	}
+
+	response := models.CareCoverageResponse{
+		TotalSpecies:    len(counts),
+		UncoveredSample: []models.UncoveredSpecies{},
+	}
+	for _, count := range counts {
+		cacheGenus, cacheSpecies := utils.CareCacheKey(count.Genus, count.Species, h.labelDelimiter)
+		switch {
+		case h.hasCuratedCare(count.Genus, count.Species):
+			response.Curated++
+		case cached[careKey{genus: cacheGenus, species: cacheSpecies}]:
+			response.CachedOnly++
+		default:
+			response.Uncovered++
+			// Counts are most identified first, so the sample shows the biggest gaps
+			if len(response.UncoveredSample) < maxUncoveredSample {
+				response.UncoveredSample = append(response.UncoveredSample, models.UncoveredSpecies{
+					Genus:           utils.FormatGenus(count.Genus),
+					Species:         utils.FormatSpecies(count.Species, h.labelDelimiter),
+					Identifications: count.Count,
+				})
+			}
+		}
+	}
+	response.Covered = response.Curated + response.CachedOnly
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
+
+// HandleCareSpecies lists the plants with cached care and their difficulty,
+// optionally only those of one difficulty with ?difficulty=easy|moderate|hard
+func (h *StatsHandler) HandleCareSpecies(w http.ResponseWriter, r *http.Request) {
+	// Only accept GET requests
+	if r.Method != http.MethodGet {
+		h.sendError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	difficulty := r.URL.Query().Get("difficulty")
+	if difficulty != "" && !db.IsValidCareDifficulty(difficulty) {
+		h.sendError(w, http.StatusBadRequest, "difficulty must be easy, moderate or hard")
+		return
+	}
+
+	cached, err := h.careRepo.ListSpecies()
+	if err != nil {
+		log.Printf("Failed to list cached care: %v", err)
+		h.sendError(w, http.StatusInternalServerError, "Failed to list species")
+		return
+	}
+
+	response := models.CareSpeciesResponse{Species: []models.CareSpecies{}}
+	for _, entry := range cached {
+		if difficulty != "" && entry.Difficulty != difficulty {
+			continue
+		}
+		// Cache keys hold the species epithet, labels the full "genus_epithet"
+		species := ""
+		if entry.Species != "" {
+			species = utils.FormatSpecies(entry.Genus+h.labelDelimiter+entry.Species, h.labelDelimiter)
+		}
+		response.Species = append(response.Species, models.CareSpecies{
+			Genus:      utils.FormatGenus(entry.Genus),
+			Species:    species,
+			Difficulty: entry.Difficulty,
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
+
+// catalogMaxAge is how long clients and CDNs may cache the care catalog
+const catalogMaxAge = 5 * 60
+
+// HandleCareCatalog returns all care as one JSON object keyed by plant label:
+// curated static care, plus cached LLM care for plants without it. Responses
+// carry an ETag for conditional requests and are gzipped when the client
+// accepts it, so the catalog can be served from a CDN.
+func (h *StatsHandler) HandleCareCatalog(w http.ResponseWriter, r *http.Request) {
+	// Only accept GET requests
+	if r.Method != http.MethodGet {
+		h.sendError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	catalog := models.CareCatalog{}
+	if h.careData != nil && h.careKeys != nil {
+		for _, key := range h.careKeys.Keys() {
+			genus, species := utils.ParseLabel(key, h.labelDelimiter)
+			care, err := h.careData.GetCareInstructions(species, genus)
+			if err != nil {
+				continue
+			}
+			h.addCatalogEntry(catalog, genus, species, models.CareCatalogSourceStatic, care)
+		}
+	}
+
+	cached, err := h.careRepo.ListCare()
+	if err != nil {
+		log.Printf("Failed to list cached care: %v", err)
+		h.sendError(w, http.StatusInternalServerError, "Failed to build care catalog")
+		return
+	}
+	for _, entry := range cached {
+		if care := careInstructionsFromGuide(entry.CareGuide); care != nil {
+			h.addCatalogEntry(catalog, entry.Genus, entry.Species, models.CareCatalogSourceCached, *care)
+		}
+	}
+
+	body, err := json.Marshal(catalog)
+	if err != nil {
+		log.Printf("Failed to encode care catalog: %v", err)
+		h.sendError(w, http.StatusInternalServerError, "Failed to build care catalog")
+		return
+	}
+	writeCacheableJSON(w, r, body, catalogMaxAge)
+}
+
+// addCatalogEntry adds care under the plant's canonical label unless the
+// catalog already has an entry for it, so static care wins over cached care
+// and a plant cached under both label forms is listed once
+func (h *StatsHandler) addCatalogEntry(catalog models.CareCatalog, genus, species, source string, care models.CareInstructions) {
+	genus, epithet := utils.CareCacheKey(genus, species, h.labelDelimiter)
+	if genus == "" {
+		return
+	}
+	label := genus
+	if epithet != "" {
+		label = genus + h.labelDelimiter + epithet
+	}
+	if _, ok := catalog[label]; ok {
+		return
+	}
+
+	entry := models.CareCatalogEntry{
+		Genus:  utils.FormatGenus(genus),
+		Source: source,
+		Care:   care,
+	}
+	if epithet != "" {
+		entry.Species = utils.FormatSpecies(label, h.labelDelimiter)
+	}
+	catalog[label] = entry
+}
+
+// writeCacheableJSON writes a JSON body with a strong ETag derived from its
+// content, answering 304 when If-None-Match matches. The body is gzipped when
+// the client accepts it; the gzipped variant has its own ETag.
+func writeCacheableJSON(w http.ResponseWriter, r *http.Request, body []byte, maxAge int) {
+	sum := sha256.Sum256(body)
+	etag := hex.EncodeToString(sum[:16])
+	gzipped := acceptsGzip(r.Header.Get("Accept-Encoding"))
+	if gzipped {
+		etag += "-gzip"
+	}
+	etag = `"` + etag + `"`
+
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Cache-Control", "public, max-age="+strconv.Itoa(maxAge))
+	w.Header().Set("Vary", "Accept-Encoding")
+	if etagMatches(r.Header.Get("If-None-Match"), etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if !gzipped {
+		w.WriteHeader(http.StatusOK)
+		w.Write(body)
+		return
+	}
+	w.Header().Set("Content-Encoding", "gzip")
+	w.WriteHeader(http.StatusOK)
+	gz := gzip.NewWriter(w)
+	gz.Write(body)
+	gz.Close()
+}
+
+// acceptsGzip reports whether an Accept-Encoding header allows gzip
+func acceptsGzip(acceptEncoding string) bool {
+	for _, part := range strings.Split(acceptEncoding, ",") {
+		coding, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		if !strings.EqualFold(strings.TrimSpace(coding), "gzip") {
+			continue
+		}
+		// "gzip;q=0" explicitly refuses it
+		if q, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			if weight, err := strconv.ParseFloat(q, 64); err == nil && weight == 0 {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// etagMatches reports whether an If-None-Match header matches etag, using
+// the weak comparison conditional GETs call for
+func etagMatches(ifNoneMatch, etag string) bool {
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// hasCuratedCare reports whether the static care data has an entry for the species or its genus
+func (h *StatsHandler) hasCuratedCare(genus, species string) bool {
+	if h.careData == nil {
+		return false
+	}
+	_, err := h.careData.GetCareInstructions(species, genus)
+	return err == nil
+}
+
+// sendError sends an error response
+func (h *StatsHandler) sendError(w http.ResponseWriter, statusCode int, message string) {
+	writeError(w, statusCode, message)
+}
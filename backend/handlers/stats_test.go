@@ -0,0 +1,310 @@
+package handlers
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+
+	"succulent-identifier-backend/db"
+	"succulent-identifier-backend/models"
+)
+
+// mockCareCacheLister simulates listing the care cache
+type mockCareCacheLister struct {
+	species []db.CachedSpecies
+	care    []db.CareInstructionsCache
+	err     error
+}
+
+func (m *mockCareCacheLister) ListSpecies() ([]db.CachedSpecies, error) {
+	return m.species, m.err
+}
+
+func (m *mockCareCacheLister) ListCare() ([]db.CareInstructionsCache, error) {
+	return m.care, m.err
+}
+
+// mockCuratedCareData has static care for a fixed set of species and genus keys
+type mockCuratedCareData map[string]bool
+
+func (m mockCuratedCareData) GetCareInstructions(species, genus string) (models.CareInstructions, error) {
+	if m[species] || m[genus] {
+		return models.CareInstructions{Sunlight: "Bright light"}, nil
+	}
+	return models.CareInstructions{}, fmt.Errorf("no care data found for species '%s' or genus '%s'", species, genus)
+}
+
+// mockStaticCareData is curated care keyed by species label or genus
+type mockStaticCareData map[string]models.CareInstructions
+
+func (m mockStaticCareData) GetCareInstructions(species, genus string) (models.CareInstructions, error) {
+	if care, ok := m[species]; ok {
+		return care, nil
+	}
+	if care, ok := m[genus]; ok {
+		return care, nil
+	}
+	return models.CareInstructions{}, fmt.Errorf("no care data found for species '%s' or genus '%s'", species, genus)
+}
+
+func (m mockStaticCareData) Keys() []string {
+	return slices.Sorted(maps.Keys(m))
+}
+
+func TestStatsHandlerHandleCareCoverage(t *testing.T) {
+	identRepo := &mockIdentificationRepository{speciesCounts: []db.SpeciesCount{
+		{SpeciesKey: db.SpeciesKey{Genus: "lithops", Species: "lithops_karasmontana"}, Count: 9},
+		{SpeciesKey: db.SpeciesKey{Genus: "haworthia", Species: "haworthia_zebrina"}, Count: 7},
+		{SpeciesKey: db.SpeciesKey{Genus: "echeveria", Species: "echeveria_elegans"}, Count: 5},
+		{SpeciesKey: db.SpeciesKey{Genus: "aloe", Species: "aloe_vera"}, Count: 3},
+		{SpeciesKey: db.SpeciesKey{Genus: "conophytum", Species: ""}, Count: 1},
+	}}
+	// Cached under the canonical (genus, epithet) key
+	careRepo := &mockCareCacheLister{species: []db.CachedSpecies{{SpeciesKey: db.SpeciesKey{Genus: "echeveria", Species: "elegans"}}}}
+	careData := mockCuratedCareData{"haworthia_zebrina": true, "aloe": true}
+
+	handler := NewStatsHandler(identRepo, careRepo, careData)
+
+	rr := httptest.NewRecorder()
+	handler.HandleCareCoverage(rr, httptest.NewRequest(http.MethodGet, "/stats/care-coverage", nil))
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var response models.CareCoverageResponse
+	if err := json.NewDecoder(rr.Body).Decode(&response); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+
+	if response.TotalSpecies != 5 || response.Covered != 3 || response.Curated != 2 || response.CachedOnly != 1 || response.Uncovered != 2 {
+		t.Errorf("Unexpected coverage counts: %+v", response)
+	}
+
+	expected := []models.UncoveredSpecies{
+		{Genus: "Lithops", Species: "Lithops karasmontana", Identifications: 9},
+		{Genus: "Conophytum", Identifications: 1},
+	}
+	if len(response.UncoveredSample) != len(expected) {
+		t.Fatalf("Expected %d uncovered species, got %+v", len(expected), response.UncoveredSample)
+	}
+	for i, species := range response.UncoveredSample {
+		if species != expected[i] {
+			t.Errorf("Uncovered species %d = %+v, expected %+v", i, species, expected[i])
+		}
+	}
+}
+
+func TestStatsHandlerHandleCareCoverageErrors(t *testing.T) {
+	tests := []struct {
+		name           string
+		method         string
+		identRepo      *mockIdentificationRepository
+		careRepo       *mockCareCacheLister
+		expectedStatus int
+	}{
+		{
+			name:           "Method not allowed",
+			method:         http.MethodPost,
+			identRepo:      &mockIdentificationRepository{},
+			careRepo:       &mockCareCacheLister{},
+			expectedStatus: http.StatusMethodNotAllowed,
+		},
+		{
+			name:           "Identification query fails",
+			method:         http.MethodGet,
+			identRepo:      &mockIdentificationRepository{speciesCountsErr: errors.New("connection refused")},
+			careRepo:       &mockCareCacheLister{},
+			expectedStatus: http.StatusInternalServerError,
+		},
+		{
+			name:           "Cache query fails",
+			method:         http.MethodGet,
+			identRepo:      &mockIdentificationRepository{},
+			careRepo:       &mockCareCacheLister{err: errors.New("connection refused")},
+			expectedStatus: http.StatusInternalServerError,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := NewStatsHandler(tt.identRepo, tt.careRepo, nil)
+
+			rr := httptest.NewRecorder()
+			handler.HandleCareCoverage(rr, httptest.NewRequest(tt.method, "/stats/care-coverage", nil))
+
+			if rr.Code != tt.expectedStatus {
+				t.Errorf("Expected status %d, got %d", tt.expectedStatus, rr.Code)
+			}
+		})
+	}
+}
+
+func TestStatsHandlerHandleCareSpecies(t *testing.T) {
+	careRepo := &mockCareCacheLister{species: []db.CachedSpecies{
+		{SpeciesKey: db.SpeciesKey{Genus: "echeveria", Species: "elegans"}, Difficulty: db.CareDifficultyEasy},
+		{SpeciesKey: db.SpeciesKey{Genus: "lithops", Species: ""}, Difficulty: db.CareDifficultyHard},
+		{SpeciesKey: db.SpeciesKey{Genus: "haworthia", Species: "zebrina"}, Difficulty: db.CareDifficultyEasy},
+		{SpeciesKey: db.SpeciesKey{Genus: "aloe", Species: "vera"}},
+	}}
+
+	tests := []struct {
+		name           string
+		query          string
+		expectedStatus int
+		expected       []models.CareSpecies
+	}{
+		{
+			name:           "All species",
+			expectedStatus: http.StatusOK,
+			expected: []models.CareSpecies{
+				{Genus: "Echeveria", Species: "Echeveria elegans", Difficulty: "easy"},
+				{Genus: "Lithops", Difficulty: "hard"},
+				{Genus: "Haworthia", Species: "Haworthia zebrina", Difficulty: "easy"},
+				{Genus: "Aloe", Species: "Aloe vera"},
+			},
+		},
+		{
+			name:           "Filtered by difficulty",
+			query:          "?difficulty=easy",
+			expectedStatus: http.StatusOK,
+			expected: []models.CareSpecies{
+				{Genus: "Echeveria", Species: "Echeveria elegans", Difficulty: "easy"},
+				{Genus: "Haworthia", Species: "Haworthia zebrina", Difficulty: "easy"},
+			},
+		},
+		{
+			name:           "Invalid difficulty",
+			query:          "?difficulty=expert",
+			expectedStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := NewStatsHandler(&mockIdentificationRepository{}, careRepo, nil)
+
+			rr := httptest.NewRecorder()
+			handler.HandleCareSpecies(rr, httptest.NewRequest(http.MethodGet, "/care/species"+tt.query, nil))
+
+			if rr.Code != tt.expectedStatus {
+				t.Fatalf("Expected status %d, got %d: %s", tt.expectedStatus, rr.Code, rr.Body.String())
+			}
+			if tt.expectedStatus != http.StatusOK {
+				return
+			}
+			var response models.CareSpeciesResponse
+			if err := json.NewDecoder(rr.Body).Decode(&response); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+			if len(response.Species) != len(tt.expected) {
+				t.Fatalf("Expected %d species, got %+v", len(tt.expected), response.Species)
+			}
+			for i, species := range response.Species {
+				if species != tt.expected[i] {
+					t.Errorf("Species %d = %+v, expected %+v", i, species, tt.expected[i])
+				}
+			}
+		})
+	}
+}
+
+func TestStatsHandlerHandleCareCatalog(t *testing.T) {
+	staticCare := mockStaticCareData{
+		"echeveria":         {Sunlight: "Static echeveria sunlight"},
+		"echeveria_elegans": {Sunlight: "Static elegans sunlight"},
+	}
+	careRepo := &mockCareCacheLister{care: []db.CareInstructionsCache{
+		// Also in the static data, which wins
+		{Genus: "echeveria", Species: "elegans", CareGuide: &db.CareGuide{Sunlight: "Cached elegans sunlight"}},
+		{Genus: "haworthia", Species: "zebrina", CareGuide: &db.CareGuide{Sunlight: "Cached zebrina sunlight"}},
+		// The same plant cached under its full label
+		{Genus: "haworthia", Species: "haworthia_zebrina", CareGuide: &db.CareGuide{Sunlight: "Duplicate zebrina sunlight"}},
+		{Genus: "aloe", Species: "", CareGuide: &db.CareGuide{Sunlight: "Cached aloe sunlight"}},
+	}}
+	handler := NewStatsHandler(&mockIdentificationRepository{}, careRepo, staticCare)
+	handler.SetCareDataKeys(staticCare)
+
+	rr := httptest.NewRecorder()
+	handler.HandleCareCatalog(rr, httptest.NewRequest(http.MethodGet, "/care/catalog", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	var catalog models.CareCatalog
+	if err := json.NewDecoder(rr.Body).Decode(&catalog); err != nil {
+		t.Fatalf("Failed to decode catalog: %v", err)
+	}
+	expected := models.CareCatalog{
+		"echeveria":         {Genus: "Echeveria", Source: models.CareCatalogSourceStatic, Care: models.CareInstructions{Sunlight: "Static echeveria sunlight"}},
+		"echeveria_elegans": {Genus: "Echeveria", Species: "Echeveria elegans", Source: models.CareCatalogSourceStatic, Care: models.CareInstructions{Sunlight: "Static elegans sunlight"}},
+		"haworthia_zebrina": {Genus: "Haworthia", Species: "Haworthia zebrina", Source: models.CareCatalogSourceCached, Care: models.CareInstructions{Sunlight: "Cached zebrina sunlight"}},
+		"aloe":              {Genus: "Aloe", Source: models.CareCatalogSourceCached, Care: models.CareInstructions{Sunlight: "Cached aloe sunlight"}},
+	}
+	if !maps.Equal(catalog, expected) {
+		t.Errorf("Catalog = %+v, expected %+v", catalog, expected)
+	}
+
+	etag := rr.Header().Get("ETag")
+	if etag == "" {
+		t.Fatal("Expected an ETag")
+	}
+
+	t.Run("Not modified", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/care/catalog", nil)
+		req.Header.Set("If-None-Match", etag)
+		rr := httptest.NewRecorder()
+		handler.HandleCareCatalog(rr, req)
+
+		if rr.Code != http.StatusNotModified {
+			t.Errorf("Expected status 304, got %d", rr.Code)
+		}
+		if rr.Body.Len() != 0 {
+			t.Errorf("Expected an empty body, got %q", rr.Body.String())
+		}
+	})
+
+	t.Run("Gzip", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/care/catalog", nil)
+		req.Header.Set("Accept-Encoding", "br, gzip")
+		req.Header.Set("If-None-Match", etag) // the plain variant's ETag
+		rr := httptest.NewRecorder()
+		handler.HandleCareCatalog(rr, req)
+
+		if rr.Code != http.StatusOK {
+			t.Fatalf("Expected status 200, got %d", rr.Code)
+		}
+		if rr.Header().Get("Content-Encoding") != "gzip" {
+			t.Fatalf("Expected gzip encoding, got %q", rr.Header().Get("Content-Encoding"))
+		}
+		if rr.Header().Get("ETag") == etag {
+			t.Error("Expected the gzipped variant to have its own ETag")
+		}
+		reader, err := gzip.NewReader(rr.Body)
+		if err != nil {
+			t.Fatalf("Failed to open gzipped body: %v", err)
+		}
+		var gzipped models.CareCatalog
+		if err := json.NewDecoder(reader).Decode(&gzipped); err != nil {
+			t.Fatalf("Failed to decode gzipped catalog: %v", err)
+		}
+		if !maps.Equal(gzipped, expected) {
+			t.Errorf("Gzipped catalog = %+v, expected %+v", gzipped, expected)
+		}
+	})
+
+	t.Run("Cache error", func(t *testing.T) {
+		handler := NewStatsHandler(&mockIdentificationRepository{}, &mockCareCacheLister{err: errors.New("db down")}, staticCare)
+		rr := httptest.NewRecorder()
+		handler.HandleCareCatalog(rr, httptest.NewRequest(http.MethodGet, "/care/catalog", nil))
+
+		if rr.Code != http.StatusInternalServerError {
+			t.Errorf("Expected status 500, got %d", rr.Code)
+		}
+	})
+}
@@ -1,10 +1,17 @@
 package main
 
 import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"flag"
 	"fmt"
 	"log"
 	"net/http"
-	"strings"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/joho/godotenv"
 	"succulent-identifier-backend/db"
@@ -13,7 +20,17 @@ import (
 	"succulent-identifier-backend/utils"
 )
 
+// version is the build version reported by /health, set with
+// -ldflags "-X main.version=<version>"
+var version = "1.0.0"
+
 func main() {
+	startedAt := time.Now()
+
+	prewarmCare := flag.Bool("prewarm-care", false, "generate and cache LLM care for every plant in the care data, then exit")
+	prewarmRate := flag.Float64("prewarm-rate", 1, "max care generations started per second while pre-warming (0 = unlimited)")
+	flag.Parse()
+
 	// Load .env file
 	if err := godotenv.Load(); err != nil {
 		log.Println("No .env file found or error loading it")
@@ -36,7 +53,7 @@ func main() {
 	log.Println("Database connected successfully")
 
 	// Run database migrations
-	if err := db.RunMigrations(db.DB); err != nil {
+	if err := db.RunMigrations(db.DB, config.LabelDelimiter); err != nil {
 		log.Fatalf("Failed to run migrations: %v", err)
 	}
 	log.Println("Database migrations completed")
@@ -45,26 +62,106 @@ func main() {
 	identificationRepo := db.NewIdentificationRepository(db.DB)
 	chatRepo := db.NewChatRepository(db.DB)
 	careInstructionsRepo := db.NewCareInstructionsRepository(db.DB)
+	careInstructionsRepo.SetMaxEntries(config.CareCacheMaxEntries)
+	careInstructionsRepo.SetPromptVersion(config.CarePromptVersion)
+	careInstructionsRepo.StartAccessFlush(context.Background(), time.Minute)
 	log.Println("Repositories initialized")
 
 	// Initialize services
 	mlClient := services.NewMLClient(config.MLServiceURL)
+	if err := mlClient.SetTransferMode(config.MLTransferMode); err != nil {
+		log.Fatalf("Invalid ML client configuration: %v", err)
+	}
+	mlClient.SetRequestKey(config.MLRequestKey)
+	mlClient.SetImageField(config.MLImageField, config.MLImageContentType)
+	mlClient.SetUploadCompression(config.MLUploadGzip)
 	log.Println("ML Client initialized")
 
-	// Check ML service health
-	if err := mlClient.HealthCheck(); err != nil {
+	// Check ML service health now and keep tracking it; identify requests get
+	// a 503 while the last check failed instead of failing at inference
+	mlHealth := services.NewMLHealthMonitor(mlClient)
+	if err := mlHealth.Check(); err != nil {
 		log.Printf("Warning: ML service health check failed: %v", err)
-		log.Println("Service will start anyway, but requests may fail until ML service is available")
+		log.Println("Service will start anyway, identify requests return 503 until ML service is available")
 	} else {
 		log.Println("ML service is healthy")
 	}
+	mlHealth.Start(context.Background(), 30*time.Second)
 
-	// Initialize chat service (required for LLM-generated care instructions)
-	if config.OpenAIAPIKey == "" {
-		log.Fatalf("Error: OPENAI_API_KEY is required for LLM-generated care instructions")
+	// Initialize chat service (optional; without it care comes from static data only)
+	var chatService handlers.ChatServiceInterface
+	if config.OpenAIAPIKey != "" {
+		openAIChat := services.NewChatService(config.OpenAIAPIKey)
+		openAIChat.SetContextTokenBudget(config.ChatContextTokenBudget)
+		openAIChat.SetCareRetries(config.CareGenerationRetries)
+		openAIChat.SetLanguageDetection(config.ChatLanguageDetection)
+		openAIChat.SetCareReferences(config.ChatCareReferences)
+		chatService = openAIChat
+		log.Println("Chat service initialized with OpenAI")
+	} else {
+		log.Println("Warning: OPENAI_API_KEY not set, chat and LLM care generation are disabled")
 	}
-	chatService := services.NewChatService(config.OpenAIAPIKey)
-	log.Println("Chat service initialized with OpenAI")
+
+	// Initialize static care data (fallback when LLM generation fails), from
+	// CARE_DATA_URL when set, otherwise from the local file
+	var careDataService *services.CareDataService
+	var err error
+	if config.CareDataURL != "" {
+		careDataService, err = services.NewRemoteCareDataService(config.CareDataURL)
+	} else {
+		careDataService, err = services.NewCareDataService(config.CareDataPath)
+	}
+	if err != nil {
+		log.Fatalf("Failed to load care data: %v", err)
+	}
+	log.Printf("Care data loaded from %s", careDataService.Status().Path)
+	careDataService.SetMergeSpecies(config.CareDataMergeSpecies)
+
+	if *prewarmCare {
+		if chatService == nil {
+			log.Fatal("Cannot pre-warm care: OPENAI_API_KEY is not set")
+		}
+		prewarmer := services.NewCarePrewarmer(
+			chatService,
+			careInstructionsRepo,
+			careDataService,
+			config.CareGenerationConcurrency,
+			*prewarmRate,
+		)
+		prewarmer.SetLabelDelimiter(config.LabelDelimiter)
+
+		log.Printf("Pre-warming care cache for %d care data entries...", careDataService.Status().Entries)
+		result := prewarmer.Run(context.Background())
+		log.Printf("Care pre-warming finished: %d generated, %d already cached, %d failed", result.Generated, result.Cached, result.Failed)
+		return
+	}
+
+	// Refresh remote care data periodically; a failed refresh keeps serving the previous data
+	if config.CareDataURL != "" && config.CareDataRefreshInterval > 0 {
+		go func() {
+			ticker := time.NewTicker(config.CareDataRefreshInterval)
+			defer ticker.Stop()
+			for range ticker.C {
+				if err := careDataService.Reload(); err != nil {
+					log.Printf("Warning: care data refresh failed, serving previous data: %v", err)
+				}
+			}
+		}()
+		log.Printf("Care data refresh enabled (every %s)", config.CareDataRefreshInterval)
+	}
+
+	// Reload care data on SIGHUP; a failed reload keeps serving the previous data
+	reloadSignals := make(chan os.Signal, 1)
+	signal.Notify(reloadSignals, syscall.SIGHUP)
+	go func() {
+		for range reloadSignals {
+			if err := careDataService.Reload(); err != nil {
+				log.Printf("Warning: care data reload failed, serving previous data: %v", err)
+				continue
+			}
+			log.Printf("Care data reloaded (%d entries)", careDataService.Status().Entries)
+		}
+	}()
 
 	// Initialize file uploader
 	fileUploader, err := utils.NewFileUploader(
@@ -75,88 +172,207 @@ func main() {
 	if err != nil {
 		log.Fatalf("Failed to initialize file uploader: %v", err)
 	}
-	log.Printf("File uploader initialized (Max size: %d bytes)", config.MaxFileSize)
+	if err := fileUploader.SetNaming(config.UploadNaming); err != nil {
+		log.Fatalf("Invalid UPLOAD_NAMING: %v", err)
+	}
+	log.Printf("File uploader initialized (Max size: %d bytes, naming: %s)", config.MaxFileSize, config.UploadNaming)
+	if config.OptimizedImagesEnabled && config.OptimizedImageMaxSize > 0 {
+		fileUploader.SetOptimizedImages(config.OptimizedImageMaxSize)
+		log.Printf("Optimized images enabled (max size: %dpx)", config.OptimizedImageMaxSize)
+	}
+	if config.UploadScanEnabled {
+		var scanner utils.FileScanner = utils.NewClamdScanner(config.UploadScanClamdAddress, config.UploadScanTimeout)
+		scannerName := "clamd at " + config.UploadScanClamdAddress
+		if config.UploadScanCommand != "" {
+			commandScanner, err := utils.NewCommandScanner(config.UploadScanCommand, config.UploadScanTimeout)
+			if err != nil {
+				log.Fatalf("Invalid UPLOAD_SCAN_COMMAND: %v", err)
+			}
+			scanner = commandScanner
+			scannerName = "command " + config.UploadScanCommand
+		}
+		if err := fileUploader.SetScanner(scanner, config.UploadQuarantineDir); err != nil {
+			log.Fatalf("Failed to enable upload scanning: %v", err)
+		}
+		log.Printf("Upload scanning enabled (%s)", scannerName)
+	}
+
+	// Auto-archive identifications past their TTL (disabled unless configured)
+	retentionService := services.NewRetentionService(
+		identificationRepo,
+		fileUploader,
+		time.Duration(config.IdentificationTTLDays)*24*time.Hour,
+	)
+	if retentionService.Enabled() {
+		retentionService.Start(context.Background(), time.Hour)
+		log.Printf("Identification retention enabled (TTL: %d days)", config.IdentificationTTLDays)
+	}
 
 	// Initialize handlers
 	identifyHandler := handlers.NewIdentifyHandler(
 		mlClient,
 		chatService,
 		careInstructionsRepo,
+		careDataService,
 		fileUploader,
 		identificationRepo,
 		config.SpeciesThreshold,
 	)
+	identifyHandler.SetLabelDelimiter(config.LabelDelimiter)
+	identifyHandler.SetSingleTokenSpecies(config.SingleTokenSpecies)
+	identifyHandler.SetSimilarImageDistance(config.SimilarImageDistance)
+	identifyHandler.SetAsyncCare(config.Features.Enabled(utils.FeatureAsyncCare))
+	if config.CareWebhookURL != "" {
+		identifyHandler.SetCareWebhook(config.CareWebhookURL)
+		log.Println("Background care completion is posted to CARE_WEBHOOK_URL")
+	}
+	identifyHandler.SetCareConcurrency(config.CareGenerationConcurrency)
+	if config.SiblingCareWarmCount > 0 {
+		identifyHandler.SetSiblingCareWarm(identificationRepo, config.SiblingCareWarmCount, config.SiblingCareWarmGenera)
+		log.Printf("Sibling care warming enabled for the %d most identified species of each genus", config.SiblingCareWarmCount)
+	}
+	identifyHandler.SetRegenerateCooldown(config.CareRegenerateCooldown)
+	identifyHandler.SetConfidenceFloor(config.ConfidenceFloor)
+	identifyHandler.SetAlternatives(config.IdentifyAlternatives, config.MaxIdentifyAlternatives)
+	identifyHandler.SetInferenceRetry(config.IdentifyRetryTransient)
+	identifyHandler.SetReidentifyDedupe(config.ReidentifyDedupe, config.ReidentifyTolerance)
+	identifyHandler.SetDedupWindow(config.DedupWindow)
+	identifyHandler.SetPinnedGenera(config.PinnedCareGenera)
+	identifyHandler.SetBlockedLabels(config.BlockedLabels)
+	identifyHandler.SetStaticCarePatterns(config.StaticCareLabelPatterns)
+	if err := identifyHandler.SetCareSourceOrder(config.CareSourceOrder); err != nil {
+		log.Fatalf("Invalid CARE_SOURCE_ORDER: %v", err)
+	}
+	identifyHandler.SetMLHealth(mlHealth)
+	if config.RecordFailures {
+		identifyHandler.SetFailureRecorder(db.NewFailedIdentificationRepository(db.DB))
+		log.Println("Failed identifications are recorded in failed_identifications")
+	}
+	if config.RecordTimings {
+		identifyHandler.SetTimingRecorder(identificationRepo)
+		log.Println("Identify request timings are recorded")
+	}
+	if commonNames, err := utils.LoadCommonNames(config.CommonNamesPath); err != nil {
+		log.Printf("Warning: common names not loaded, care lookup by common name is disabled: %v", err)
+	} else {
+		identifyHandler.SetCommonNames(commonNames)
+		log.Printf("Loaded %d common names from %s", commonNames.Len(), config.CommonNamesPath)
+	}
+	careCacheMetrics := utils.NewCareCacheMetrics()
+	identifyHandler.SetCareCacheMetrics(careCacheMetrics)
 
-	// Setup routes
-	mux := http.NewServeMux()
-
-	// Health check endpoint
-	mux.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusOK)
-		fmt.Fprintf(w, `{"status":"healthy","service":"succulent-identifier-backend"}`)
-	})
-
-	// Root endpoint
-	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
-		if r.URL.Path != "/" {
-			http.NotFound(w, r)
-			return
+	// Share links are signed with SHARE_SECRET; without it a random per-process
+	// secret is used, so links stop working after a restart
+	shareSecret := []byte(config.ShareSecret)
+	if len(shareSecret) == 0 {
+		shareSecret = make([]byte, 32)
+		if _, err := rand.Read(shareSecret); err != nil {
+			log.Fatalf("Failed to generate share secret: %v", err)
 		}
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusOK)
-		fmt.Fprintf(w, `{"service":"Succulent Identifier Backend","version":"1.0.0","endpoints":["/identify","/health"]}`)
-	})
-
-	// Identify endpoint
-	mux.HandleFunc("/identify", identifyHandler.Handle)
+		log.Println("Warning: SHARE_SECRET not set, share links will not survive a restart")
+	}
 
-	// Chat endpoint
-	chatHandler := handlers.NewChatHandler(chatService, identificationRepo, chatRepo)
-	mux.HandleFunc("/chat", chatHandler.Handle)
-	log.Println("Chat endpoint registered")
+	healthHandler := handlers.NewHealthHandler(careDataService)
+	healthHandler.SetMLHealth(mlHealth)
+	healthHandler.SetDatabase(db.DB)
+	healthHandler.SetReadinessCacheTTL(config.ReadinessCacheTTL)
+	healthHandler.SetBuildInfo(version, startedAt)
 
-	// History endpoints
 	historyHandler := handlers.NewHistoryHandler(identificationRepo, chatRepo)
-	historyRouteHandler := func(w http.ResponseWriter, r *http.Request) {
-		// Route based on path and method
-		path := r.URL.Path
-
-		// Handle DELETE requests for specific identification
-		if r.Method == http.MethodDelete && path != "/history" && path != "/history/" {
-			historyHandler.HandleDelete(w, r)
-			return
-		}
+	historyHandler.SetPublicBaseURL(config.PublicBaseURL)
+	historyHandler.SetPublicUploads(config.PublicUploadsEnabled)
+	historyHandler.SetExportPrecision(config.ExportConfidencePrecision)
 
-		// Handle GET requests
-		if path == "/history" || path == "/history/" {
-			historyHandler.HandleList(w, r)
-		} else if strings.HasSuffix(path, "/with-chat") {
-			historyHandler.HandleGetWithChat(w, r)
-		} else {
-			historyHandler.HandleGetByID(w, r)
+	statsHandler := handlers.NewStatsHandler(identificationRepo, careInstructionsRepo, careDataService)
+	statsHandler.SetLabelDelimiter(config.LabelDelimiter)
+	statsHandler.SetCareDataKeys(careDataService)
+
+	routes := handlers.Routes{
+		Identify: identifyHandler,
+		History:  historyHandler,
+		Share:    handlers.NewShareHandler(identificationRepo, shareSecret, config.ShareTokenTTL),
+		Health:   healthHandler,
+		Config:   handlers.NewConfigHandler(config),
+		Stats:    statsHandler,
+		Metrics:  handlers.NewMetricsHandler(careCacheMetrics),
+	}
+	if config.PublicUploadsEnabled {
+		routes.UploadDir = config.UploadDir
+	}
+	if config.MaxUploadsPerIP > 0 {
+		routes.UploadLimiter = utils.NewUploadLimiter(config.MaxUploadsPerIP)
+		log.Printf("Concurrent uploads capped at %d per client", config.MaxUploadsPerIP)
+	}
+	// Recent errors are only kept when they can be read at GET /admin/errors
+	var errorLog *utils.ErrorLog
+	if config.AdminToken != "" {
+		routes.Admin = handlers.NewAdminHandler(careInstructionsRepo)
+		routes.Admin.SetCareBackfiller(identifyHandler)
+		if config.RecordTimings {
+			routes.Admin.SetTimings(identificationRepo)
+		}
+		routes.AdminToken = config.AdminToken
+		if config.ErrorLogSize > 0 {
+			errorLog = utils.NewErrorLog(config.ErrorLogSize)
+			routes.Admin.SetErrorLog(errorLog)
 		}
 	}
-	// Register both /history and /history/ patterns to handle all history routes
-	mux.HandleFunc("/history", historyRouteHandler)
-	mux.HandleFunc("/history/", historyRouteHandler)
-	mux.HandleFunc("/chat/", historyHandler.HandleGetChatHistory)
-	log.Println("History endpoints registered")
+	if chatService != nil {
+		chatHandler := handlers.NewChatHandler(chatService, identificationRepo, chatRepo)
+		chatHandler.SetAllowContextless(config.ChatAllowContextless)
+		chatHandler.SetMaxUserMessageChars(config.MaxUserMessageChars)
+		chatHandler.SetCompareModels(config.CompareModels)
+		routes.Chat = chatHandler
+	}
 
-	// Serve uploaded images as static files
-	fileServer := http.FileServer(http.Dir(config.UploadDir))
-	mux.Handle("/uploads/", http.StripPrefix("/uploads/", fileServer))
-	log.Println("Static file server registered for uploads")
+	// Setup routes
+	mux := http.NewServeMux()
+	handlers.RegisterRoutes(mux, routes, config.Features)
 
 	// Apply middleware
-	handler := utils.CORSMiddleware(mux)
+	trustedProxies, err := utils.ParseTrustedProxies(config.TrustedProxies)
+	if err != nil {
+		log.Fatalf("Invalid TRUSTED_PROXIES: %v", err)
+	}
+	var handler http.Handler = utils.BasePathMiddleware(config.BasePath, utils.CORSMiddleware(mux))
+	if errorLog != nil {
+		handler = utils.ErrorLogMiddleware(errorLog, handler)
+	}
+	handler = utils.ForwardedHeadersMiddleware(trustedProxies, utils.LoggingMiddleware(handler))
 
 	// Start server
 	addr := fmt.Sprintf(":%s", config.ServerPort)
 	log.Printf("Server listening on %s", addr)
+	if config.BasePath != "" {
+		log.Printf("Routes served under %s", config.BasePath)
+	}
 	log.Println("Ready to accept requests!")
 
-	if err := http.ListenAndServe(addr, handler); err != nil {
-		log.Fatalf("Server failed to start: %v", err)
+	server := &http.Server{Addr: addr, Handler: handler}
+	go func() {
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatalf("Server failed to start: %v", err)
+		}
+	}()
+
+	// On SIGINT/SIGTERM finish in-flight requests, then let background care
+	// generations complete so their identifications are not left generating
+	shutdownSignals := make(chan os.Signal, 1)
+	signal.Notify(shutdownSignals, os.Interrupt, syscall.SIGTERM)
+	<-shutdownSignals
+	log.Println("Shutting down...")
+
+	ctx, cancel := context.WithTimeout(context.Background(), config.ShutdownTimeout)
+	defer cancel()
+	if err := server.Shutdown(ctx); err != nil {
+		log.Printf("Warning: in-flight requests did not finish: %v", err)
+	}
+	deadline, _ := ctx.Deadline()
+	if !identifyHandler.WaitForCareJobs(time.Until(deadline)) {
+		log.Println("Warning: background care generations did not finish before shutdown")
+	}
+	if err := careInstructionsRepo.FlushAccessTimes(); err != nil {
+		log.Printf("Warning: %v", err)
 	}
+	log.Println("Server stopped")
 }
@@ -8,13 +8,40 @@ type MLPrediction struct {
 	Confidence float64 `json:"confidence"`
 }
 
-// MLInferenceRequest represents the request to ML service
+// MLInferenceRequest represents the request to ML service in path mode with
+// the default request key; the key is configurable with ML_REQUEST_KEY
 type MLInferenceRequest struct {
 	ImagePath string `json:"image_path"`
 }
 
 // MLInferenceResponse represents the response from ML service
+// An ensemble service also reports each model's predictions; when present they
+// are combined into Predictions, otherwise the flat Predictions list is used as is.
+// A detection model reports one entry in Detections per plant in the image.
 type MLInferenceResponse struct {
+	Predictions []MLPrediction     `json:"predictions"`
+	Models      []ModelPredictions `json:"models,omitempty"`
+	Detections  []MLDetection      `json:"detections,omitempty"`
+}
+
+// MLDetection represents one plant detected in an image and its predictions
+type MLDetection struct {
+	Box         BoundingBox    `json:"box"`
+	Predictions []MLPrediction `json:"predictions"`
+}
+
+// BoundingBox locates a detected plant in an image, in the units the ML service reports
+type BoundingBox struct {
+	X      float64 `json:"x"`
+	Y      float64 `json:"y"`
+	Width  float64 `json:"width"`
+	Height float64 `json:"height"`
+}
+
+// ModelPredictions represents the predictions of one model in an ensemble
+type ModelPredictions struct {
+	Model       string         `json:"model"`
+	Weight      float64        `json:"weight,omitempty"` // vote weight, 1 when unset
 	Predictions []MLPrediction `json:"predictions"`
 }
 
@@ -25,20 +52,171 @@ type CareInstructions struct {
 	Soil     string `json:"soil"`
 	Notes    string `json:"notes"`
 	Trivia   string `json:"trivia,omitempty"`
+	Summary  string `json:"summary,omitempty"` // rendered from the fields above, see ?care_summary=true
+
+	// Difficulty for a beginner: easy, moderate or hard; empty when unrated
+	Difficulty string `json:"difficulty,omitempty"`
 }
 
 // PlantInfo represents identified plant information
 type PlantInfo struct {
-	Genus      string  `json:"genus"`
-	Species    string  `json:"species,omitempty"`
-	Confidence float64 `json:"confidence"`
+	Genus          string  `json:"genus"`
+	Species        string  `json:"species,omitempty"`         // display binomial, e.g. "Echeveria elegans"
+	SpeciesEpithet string  `json:"species_epithet,omitempty"` // epithet only, e.g. "elegans"
+	Confidence     float64 `json:"confidence"`
+}
+
+// CommonNamePlant is a plant a common name refers to
+type CommonNamePlant struct {
+	Label   string `json:"label"`             // ML label, e.g. "echeveria_minima"
+	Genus   string `json:"genus"`             // display genus, e.g. "Echeveria"
+	Species string `json:"species,omitempty"` // display binomial, e.g. "Echeveria minima"
+}
+
+// CommonNameCareResponse is the care for a plant looked up by common name.
+// An ambiguous name lists its candidates instead of a plant and care.
+type CommonNameCareResponse struct {
+	Name       string            `json:"name"`
+	Plant      *CommonNamePlant  `json:"plant,omitempty"`
+	Care       *CareInstructions `json:"care,omitempty"`
+	Candidates []CommonNamePlant `json:"candidates,omitempty"`
 }
 
+// Identify response schema versions; clients pick one with ?api_version=N or
+// an "application/vnd.succulent.vN+json" Accept header
+const (
+	IdentifySchemaV1      = 1 // id, identified, message, plant, care and care_status only
+	IdentifySchemaV2      = 2 // every field of IdentifyResponse
+	IdentifySchemaCurrent = IdentifySchemaV2
+)
+
 // IdentifyResponse represents the response to the client
 type IdentifyResponse struct {
-	ID    string           `json:"id"`
-	Plant PlantInfo        `json:"plant"`
-	Care  CareInstructions `json:"care"`
+	SchemaVersion    int               `json:"schema_version,omitempty"`
+	ID               string            `json:"id,omitempty"`
+	Identified       bool              `json:"identified"` // false when the result was withheld, e.g. a blocked label
+	Message          string            `json:"message,omitempty"`
+	Plant            PlantInfo         `json:"plant"`
+	Care             *CareInstructions `json:"care,omitempty"`
+	CareStatus       string            `json:"care_status"` // "ready", "generating" or "none"
+	DuplicateWarning *DuplicateWarning `json:"duplicate_warning,omitempty"`
+
+	// Set when every prediction is below the confidence floor; care is not generated
+	Uncertain  bool                  `json:"uncertain,omitempty"`
+	Candidates []CandidatePrediction `json:"candidates,omitempty"`
+
+	// Runner-up predictions of a confident identification, see ?alternatives=N
+	Alternatives []CandidatePrediction `json:"alternatives,omitempty"`
+
+	// Set when a re-identification matched the existing record, which was
+	// updated instead of saving a new one
+	Unchanged bool `json:"unchanged,omitempty"`
+
+	// Set when the same client identified the same species within the dedup
+	// window; the earlier record is returned and no new one is saved
+	Deduplicated bool `json:"deduplicated,omitempty"`
+
+	// Every plant detected when the image holds several, in ML order; the
+	// fields above describe the first
+	Detections []DetectionResult `json:"detections,omitempty"`
+}
+
+// IdentifyResponseV1 is the stable identify response shape of schema version 1
+type IdentifyResponseV1 struct {
+	SchemaVersion int               `json:"schema_version"`
+	ID            string            `json:"id,omitempty"`
+	Identified    bool              `json:"identified"`
+	Message       string            `json:"message,omitempty"`
+	Plant         PlantInfo         `json:"plant"`
+	Care          *CareInstructions `json:"care,omitempty"`
+	CareStatus    string            `json:"care_status"`
+}
+
+// DetectionResult is the identification of one plant detected in an image
+type DetectionResult struct {
+	*IdentifyResponse
+	Box BoundingBox `json:"box"`
+}
+
+// CandidatePrediction is one of the top predictions of an uncertain
+// identification, or a runner-up of a confident one
+type CandidatePrediction struct {
+	Genus          string  `json:"genus"`
+	Species        string  `json:"species,omitempty"`
+	SpeciesEpithet string  `json:"species_epithet,omitempty"`
+	Confidence     float64 `json:"confidence"`
+}
+
+// QuickIdentifyResponse represents a genus-only identification preview
+type QuickIdentifyResponse struct {
+	Identified bool    `json:"identified"`
+	Genus      string  `json:"genus,omitempty"`
+	Confidence float64 `json:"confidence,omitempty"` // summed over the genus' species
+	Message    string  `json:"message,omitempty"`
+}
+
+// BatchIdentifyResponse represents the identification results of a batch request
+// in the same order as the uploaded images
+type BatchIdentifyResponse struct {
+	Results   []BatchIdentifyResult `json:"results"`
+	Succeeded int                   `json:"succeeded"`
+	Failed    int                   `json:"failed"`
+
+	// With ?combine=true, the image the single identification was saved
+	// from; only its result carries the identify response
+	Chosen *ChosenImage `json:"chosen,omitempty"`
+}
+
+// ChosenImage identifies the image chosen to represent a combined batch
+type ChosenImage struct {
+	Index    int    `json:"index"` // position among the uploaded images
+	Filename string `json:"filename"`
+	Reason   string `json:"reason"` // "highest_confidence" or "highest_resolution"
+}
+
+// BatchIdentifyResult is the outcome for one image of a batch: the identify
+// response fields on success, an error otherwise
+type BatchIdentifyResult struct {
+	*IdentifyResponse
+	Filename string          `json:"filename"`
+	Status   int             `json:"status"` // HTTP status the image would have had on its own
+	Error    *BatchItemError `json:"error,omitempty"`
+}
+
+// BatchItemError describes why an image of a batch failed
+type BatchItemError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// SimilarIdentification references an earlier identification of a similar image
+type SimilarIdentification struct {
+	ID        string    `json:"id"`
+	Genus     string    `json:"genus"`
+	Species   string    `json:"species,omitempty"`
+	Link      string    `json:"link"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// DuplicateWarning indicates the uploaded image resembles previously identified images
+type DuplicateWarning struct {
+	Message string                  `json:"message"`
+	Matches []SimilarIdentification `json:"matches"`
+}
+
+// ImageMetadata describes an uploaded image
+type ImageMetadata struct {
+	Width      int        `json:"width"`
+	Height     int        `json:"height"`
+	Format     string     `json:"format"`
+	Size       int64      `json:"size"`
+	CapturedAt *time.Time `json:"captured_at,omitempty"` // EXIF capture time, camera local time
+}
+
+// ImageValidationResponse represents the response to an image pre-validation request
+type ImageValidationResponse struct {
+	Valid bool          `json:"valid"`
+	Image ImageMetadata `json:"image"`
 }
 
 // ErrorResponse represents an error response
@@ -51,6 +229,22 @@ type ErrorResponse struct {
 type ChatRequest struct {
 	IdentificationID string `json:"identification_id"`
 	Message          string `json:"message"`
+
+	// Ephemeral chats take their context from History instead of a stored
+	// conversation and are not persisted; identification_id must be omitted
+	Ephemeral bool       `json:"ephemeral,omitempty"`
+	History   []ChatTurn `json:"history,omitempty"`
+}
+
+// ChatTurn is one prior message of an ephemeral chat
+type ChatTurn struct {
+	Sender  string `json:"sender"` // "user" or "llm"
+	Message string `json:"message"`
+}
+
+// ChatEditRequest represents a correction of a user chat message
+type ChatEditRequest struct {
+	Message string `json:"message"`
 }
 
 // ChatResponse represents a chat response to the client
@@ -58,6 +252,11 @@ type ChatResponse struct {
 	Message   string    `json:"message"`
 	MessageID string    `json:"message_id"`
 	Timestamp time.Time `json:"timestamp"`
+	Truncated bool      `json:"truncated,omitempty"` // reply was cut off by the max token cap
+
+	// Care fields of the identification the reply drew on, e.g. "watering",
+	// so the UI can highlight them; only with CHAT_CARE_REFERENCES
+	CareReferences []string `json:"care_references,omitempty"`
 }
 
 // HistoryItem represents a single identification in the history list
@@ -68,6 +267,12 @@ type HistoryItem struct {
 	Confidence float64   `json:"confidence"`
 	ImagePath  string    `json:"image_path"`
 	CreatedAt  time.Time `json:"created_at"`
+
+	// Truncated care summary, only with ?include_care=snippet
+	CareSnippet string `json:"care_snippet,omitempty"`
+
+	// Full-quality upload when image_path is its optimized copy
+	OriginalImagePath string `json:"original_image_path,omitempty"`
 }
 
 // HistoryListResponse represents the paginated history list response
@@ -78,23 +283,99 @@ type HistoryListResponse struct {
 	Offset int           `json:"offset"`
 }
 
+// HistoryGroupedResponse represents identifications grouped by genus, paginated over genera
+type HistoryGroupedResponse struct {
+	Groups      []HistoryGenusGroup `json:"groups"`
+	TotalGenera int                 `json:"total_genera"`
+	Limit       int                 `json:"limit"`
+	Offset      int                 `json:"offset"`
+}
+
+// HistoryGenusGroup lists the identifications of one genus, newest first
+type HistoryGenusGroup struct {
+	Genus      string        `json:"genus"`
+	Count      int           `json:"count"`
+	MostRecent HistoryItem   `json:"most_recent"`
+	Items      []HistoryItem `json:"items"`
+}
+
 // HistoryDetailResponse represents detailed information about an identification
 type HistoryDetailResponse struct {
-	ID         string              `json:"id"`
-	Genus      string              `json:"genus"`
-	Species    string              `json:"species,omitempty"`
-	Confidence float64             `json:"confidence"`
-	ImagePath  string              `json:"image_path"`
-	CareGuide  *CareInstructions   `json:"care_guide,omitempty"`
-	CreatedAt  time.Time           `json:"created_at"`
+	ID         string            `json:"id"`
+	Genus      string            `json:"genus"`
+	Species    string            `json:"species,omitempty"`
+	Confidence float64           `json:"confidence"`
+	ImagePath  string            `json:"image_path"`
+	CareGuide  *CareInstructions `json:"care_guide,omitempty"`
+	CreatedAt  time.Time         `json:"created_at"`
+
+	// Full-quality upload when image_path is its optimized copy
+	OriginalImagePath string `json:"original_image_path,omitempty"`
+
+	// Metadata of the uploaded image, absent for identifications saved before it was recorded
+	ImageMetadata *ImageMetadata `json:"image_metadata,omitempty"`
+}
+
+// HistoryBatchRequest represents a request to fetch several identifications by ID
+type HistoryBatchRequest struct {
+	IDs []string `json:"ids"`
+}
+
+// HistoryBatchResponse represents the identifications found for a batch request
+type HistoryBatchResponse struct {
+	Items []HistoryDetailResponse `json:"items"`
+}
+
+// ShareBundle represents a self-contained, read-only snapshot of an identification
+type ShareBundle struct {
+	ID         string            `json:"id"`
+	Genus      string            `json:"genus"`
+	Species    string            `json:"species,omitempty"`
+	Confidence float64           `json:"confidence"`
+	CareGuide  *CareInstructions `json:"care_guide,omitempty"`
+	Thumbnail  string            `json:"thumbnail,omitempty"` // data URI with base64-encoded JPEG
+	CreatedAt  time.Time         `json:"created_at"`
+}
+
+// ShareResponse represents a generated share link for an identification
+type ShareResponse struct {
+	Token     string      `json:"token"`
+	URL       string      `json:"url"`
+	ExpiresAt time.Time   `json:"expires_at"`
+	Bundle    ShareBundle `json:"bundle"`
+}
+
+// ChatCompareRequest represents a request to answer one message with several models
+type ChatCompareRequest struct {
+	IdentificationID string   `json:"identification_id"`
+	Message          string   `json:"message"`
+	Models           []string `json:"models"`
+}
+
+// ModelReply represents one model's answer in a chat comparison
+type ModelReply struct {
+	Model   string `json:"model"`
+	Message string `json:"message,omitempty"`
+	Error   string `json:"error,omitempty"` // set instead of message when the model failed
+}
+
+// ChatCompareResponse represents the replies of each compared model, in request order
+type ChatCompareResponse struct {
+	Message string       `json:"message"`
+	Replies []ModelReply `json:"replies"`
 }
 
 // ChatMessageResponse represents a single chat message
 type ChatMessageResponse struct {
 	ID        string    `json:"id"`
 	Message   string    `json:"message"`
-	Sender    string    `json:"sender"` // "user" or "llm"
+	Sender    string    `json:"sender"`              // "user" or "llm"
+	Model     string    `json:"model,omitempty"`     // LLM that produced an "llm" message
+	Truncated bool      `json:"truncated,omitempty"` // "llm" message cut off by the max token cap
 	CreatedAt time.Time `json:"created_at"`
+
+	// Care fields an "llm" message drew on, see ChatResponse
+	CareReferences []string `json:"care_references,omitempty"`
 }
 
 // ChatHistoryResponse represents the chat history for an identification
@@ -104,8 +385,185 @@ type ChatHistoryResponse struct {
 	Total            int                   `json:"total"`
 }
 
+// CareStatusResponse represents the care generation state of an identification
+type CareStatusResponse struct {
+	ID         string            `json:"id"`
+	CareStatus string            `json:"care_status"`
+	Care       *CareInstructions `json:"care,omitempty"`
+}
+
 // HistoryWithChatResponse represents identification with its chat history
 type HistoryWithChatResponse struct {
 	Identification HistoryDetailResponse `json:"identification"`
 	ChatMessages   []ChatMessageResponse `json:"chat_messages"`
 }
+
+// ClientConfigResponse represents the configuration the frontend needs for upload guidance
+type ClientConfigResponse struct {
+	SpeciesThreshold  float64  `json:"species_threshold"`
+	AllowedExtensions []string `json:"allowed_extensions"`
+	MaxFileSize       int64    `json:"max_file_size"` // in bytes
+	MaxBatchImages    int      `json:"max_batch_images"`
+}
+
+// FeaturesResponse represents which optional features are enabled
+type FeaturesResponse struct {
+	Features map[string]bool `json:"features"`
+}
+
+// CareCoverageResponse reports how many distinct identified species have care
+// data. Curated species have static care data for the species or its genus;
+// cached-only species have LLM care in the cache but no curated data.
+type CareCoverageResponse struct {
+	TotalSpecies    int                `json:"total_species"`
+	Covered         int                `json:"covered"`
+	Curated         int                `json:"curated"`
+	CachedOnly      int                `json:"cached_only"`
+	Uncovered       int                `json:"uncovered"`
+	UncoveredSample []UncoveredSpecies `json:"uncovered_sample"` // most identified first
+}
+
+// CareSpeciesResponse lists the plants with cached care
+type CareSpeciesResponse struct {
+	Species []CareSpecies `json:"species"`
+}
+
+// CareSpecies is a plant with cached care and the care's difficulty
+type CareSpecies struct {
+	Genus      string `json:"genus"`
+	Species    string `json:"species,omitempty"`
+	Difficulty string `json:"difficulty,omitempty"` // easy, moderate or hard; empty when unrated
+}
+
+// Sources of care catalog entries
+const (
+	CareCatalogSourceStatic = "static" // curated care data
+	CareCatalogSourceCached = "cached" // cached LLM care
+)
+
+// CareCatalog maps plant labels ("echeveria_elegans", or "echeveria" for
+// genus-level care) to their care, for hosting as a static file
+type CareCatalog map[string]CareCatalogEntry
+
+// CareCatalogEntry is the care of one plant in the care catalog
+type CareCatalogEntry struct {
+	Genus   string           `json:"genus"`
+	Species string           `json:"species,omitempty"` // empty for genus-level care
+	Source  string           `json:"source"`            // static or cached
+	Care    CareInstructions `json:"care"`
+}
+
+// UncoveredSpecies is an identified species with neither curated nor cached care
+type UncoveredSpecies struct {
+	Genus           string `json:"genus"`
+	Species         string `json:"species,omitempty"`
+	Identifications int    `json:"identifications"`
+}
+
+// HealthResponse represents the liveness report of the service
+type HealthResponse struct {
+	Status        string       `json:"status"`
+	Service       string       `json:"service"`
+	Version       string       `json:"version"`
+	StartedAt     time.Time    `json:"started_at"`
+	Uptime        string       `json:"uptime"` // human readable, e.g. "2h3m4s"
+	UptimeSeconds float64      `json:"uptime_seconds"`
+	Runtime       RuntimeStats `json:"runtime"`
+}
+
+// RuntimeStats represents Go runtime statistics of the running process
+type RuntimeStats struct {
+	GoVersion      string `json:"go_version"`
+	Goroutines     int    `json:"goroutines"`
+	HeapAllocBytes uint64 `json:"heap_alloc_bytes"`
+	SysBytes       uint64 `json:"sys_bytes"`
+	NumGC          uint32 `json:"num_gc"`
+}
+
+// ReadinessResponse represents the readiness report of the service
+type ReadinessResponse struct {
+	Status    string           `json:"status"`     // "ready" or "not_ready"
+	CheckedAt time.Time        `json:"checked_at"` // when dependencies were probed; reports are cached briefly
+	CareData  CareDataHealth   `json:"care_data"`
+	MLService *MLServiceHealth `json:"ml_service,omitempty"`
+	Database  *DatabaseHealth  `json:"database,omitempty"`
+}
+
+// CareDataHealth represents the load state of the static care data
+type CareDataHealth struct {
+	Status     string    `json:"status"` // "ok", "stale" (last reload failed, previous data served) or "unavailable"
+	Entries    int       `json:"entries"`
+	LastLoaded time.Time `json:"last_loaded"`
+	LastError  string    `json:"last_error,omitempty"`
+}
+
+// MLServiceHealth represents the result of the last ML service health check
+type MLServiceHealth struct {
+	Status      string    `json:"status"` // "ok" or "unavailable"
+	LastChecked time.Time `json:"last_checked"`
+	LastError   string    `json:"last_error,omitempty"`
+}
+
+// DatabaseHealth represents the result of a database ping
+type DatabaseHealth struct {
+	Status    string `json:"status"` // "ok" or "unavailable"
+	LastError string `json:"last_error,omitempty"`
+}
+
+// ErrorEntry is a server error recorded in the in-memory error log
+type ErrorEntry struct {
+	Time      time.Time `json:"time"`
+	RequestID string    `json:"request_id,omitempty"`
+	Method    string    `json:"method"`
+	Path      string    `json:"path"`
+	Status    int       `json:"status"`
+	Error     string    `json:"error"`
+}
+
+// RecentErrorsResponse lists the most recent server errors, newest first
+type RecentErrorsResponse struct {
+	Errors   []ErrorEntry `json:"errors"`
+	Capacity int          `json:"capacity"`
+}
+
+// RequestTiming is how long the steps of an identify request took, in milliseconds
+type RequestTiming struct {
+	UploadMs    float64 `json:"upload_ms"`
+	InferenceMs float64 `json:"inference_ms"`
+	CareMs      float64 `json:"care_ms"`
+	SaveMs      float64 `json:"save_ms"`
+	TotalMs     float64 `json:"total_ms"` // includes steps not broken out, e.g. duplicate lookups
+}
+
+// IdentificationTiming is the recorded request timing of an identification
+type IdentificationTiming struct {
+	ID        string        `json:"id"`
+	Genus     string        `json:"genus"`
+	Species   string        `json:"species,omitempty"`
+	CreatedAt time.Time     `json:"created_at"`
+	Timing    RequestTiming `json:"timing"`
+}
+
+// TimingsResponse lists the most recently recorded identify request timings, newest first
+type TimingsResponse struct {
+	Timings []IdentificationTiming `json:"timings"`
+}
+
+// CareFlushResponse reports how many cached care entries were deleted
+type CareFlushResponse struct {
+	Deleted int64  `json:"deleted"`
+	Genus   string `json:"genus,omitempty"`
+}
+
+// CarePromptResponse is the care generation prompt an admin previewed
+type CarePromptResponse struct {
+	Genus   string `json:"genus"`
+	Species string `json:"species,omitempty"`
+	System  string `json:"system"` // system message sent with the prompt
+	Prompt  string `json:"prompt"`
+}
+
+// CareBackfillResponse reports how many identifications were queued for care backfill
+type CareBackfillResponse struct {
+	Queued int `json:"queued"`
+}
@@ -0,0 +1,210 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"succulent-identifier-backend/models"
+)
+
+// CareDataService provides curated care instructions loaded from a JSON file
+// or, when created with NewRemoteCareDataService, downloaded from a URL
+type CareDataService struct {
+	path string
+
+	// Remote source; empty url means path is a local file
+	url        string
+	httpClient *http.Client
+
+	// Fill empty fields of species-level care from its genus instead of
+	// using the species entry alone
+	mergeSpecies bool
+
+	mu        sync.RWMutex
+	careData  map[string]models.CareInstructions
+	etag      string // validator of the last download, sent as If-None-Match
+	loadedAt  time.Time
+	lastError error
+}
+
+// CareDataStatus reports the load state of the care data
+type CareDataStatus struct {
+	Path      string
+	Entries   int
+	LoadedAt  time.Time
+	LastError error // most recent reload failure, nil if the last load succeeded
+}
+
+// NewCareDataService creates a new care data service from the given file path
+// The file may be plain JSON or gzip-compressed JSON
+func NewCareDataService(path string) (*CareDataService, error) {
+	if path == "" {
+		return nil, fmt.Errorf("care data path is empty")
+	}
+
+	careData, err := loadCareData(path)
+	if err != nil {
+		return nil, err
+	}
+
+	// Pin a relative path to the startup working directory so reloads keep
+	// reading the same file
+	if absPath, err := filepath.Abs(path); err == nil {
+		path = absPath
+	}
+
+	return &CareDataService{
+		path:     path,
+		careData: careData,
+		loadedAt: time.Now().UTC(),
+	}, nil
+}
+
+// Reload re-reads the care data file, or re-downloads it for a remote source.
+// On failure the previously loaded data keeps being served and the error is
+// recorded in Status.
+func (s *CareDataService) Reload() error {
+	if s.url != "" {
+		return s.reloadRemote()
+	}
+
+	careData, err := loadCareData(s.path)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err != nil {
+		s.lastError = err
+		return err
+	}
+
+	s.careData = careData
+	s.loadedAt = time.Now().UTC()
+	s.lastError = nil
+	return nil
+}
+
+// Status returns the current load state of the care data
+func (s *CareDataService) Status() CareDataStatus {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return CareDataStatus{
+		Path:      s.path,
+		Entries:   len(s.careData),
+		LoadedAt:  s.loadedAt,
+		LastError: s.lastError,
+	}
+}
+
+// loadCareData reads and parses care data keyed by species or genus
+// Gzip input is detected by a .gz extension or the gzip magic bytes
+func loadCareData(path string) (map[string]models.CareInstructions, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read care data file %s: %w", describeCareDataPath(path), err)
+	}
+
+	return parseCareData(data, strings.HasSuffix(strings.ToLower(path), ".gz"))
+}
+
+// describeCareDataPath names the file a care data path resolves to. Relative
+// paths depend on the working directory, which differs between "go run" and
+// a container, so it is included for them.
+func describeCareDataPath(path string) string {
+	if filepath.IsAbs(path) {
+		return path
+	}
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return path
+	}
+	cwd, _ := os.Getwd()
+	return fmt.Sprintf("%q (resolved to %s from working directory %s, set CARE_DATA_PATH to an absolute path to avoid this)", path, absPath, cwd)
+}
+
+// parseCareData decodes care data JSON, decompressing it first when gzipped
+// is set or the data starts with the gzip magic bytes
+func parseCareData(data []byte, gzipped bool) (map[string]models.CareInstructions, error) {
+	data, err := decompressCareData(data, gzipped)
+	if err != nil {
+		return nil, err
+	}
+
+	careData := make(map[string]models.CareInstructions)
+	if err := json.Unmarshal(data, &careData); err != nil {
+		return nil, fmt.Errorf("failed to parse care data: %w", err)
+	}
+
+	return careData, nil
+}
+
+// SetMergeSpecies sets whether a species entry only overrides the fields it
+// sets, inheriting the rest from its genus entry. By default a species entry
+// replaces the genus entry entirely.
+func (s *CareDataService) SetMergeSpecies(merge bool) {
+	s.mergeSpecies = merge
+}
+
+// GetCareInstructions returns care instructions for a species, falling back to its genus
+func (s *CareDataService) GetCareInstructions(species, genus string) (models.CareInstructions, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if species != "" {
+		if care, ok := s.careData[species]; ok {
+			if genusCare, ok := s.careData[genus]; ok && s.mergeSpecies && genus != "" {
+				return mergeCareInstructions(genusCare, care), nil
+			}
+			return care, nil
+		}
+	}
+
+	if genus != "" {
+		if care, ok := s.careData[genus]; ok {
+			return care, nil
+		}
+	}
+
+	return models.CareInstructions{}, fmt.Errorf("no care data found for species '%s' or genus '%s'", species, genus)
+}
+
+// mergeCareInstructions returns genus care with every field that species care
+// sets replaced by the species value
+func mergeCareInstructions(genus, species models.CareInstructions) models.CareInstructions {
+	merged := genus
+	for _, field := range []struct{ dst, src *string }{
+		{&merged.Sunlight, &species.Sunlight},
+		{&merged.Watering, &species.Watering},
+		{&merged.Soil, &species.Soil},
+		{&merged.Notes, &species.Notes},
+		{&merged.Trivia, &species.Trivia},
+		{&merged.Summary, &species.Summary},
+		{&merged.Difficulty, &species.Difficulty},
+	} {
+		if *field.src != "" {
+			*field.dst = *field.src
+		}
+	}
+	return merged
+}
+
+// Keys returns the species and genus keys of the loaded care data in sorted order
+func (s *CareDataService) Keys() []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	keys := make([]string, 0, len(s.careData))
+	for key := range s.careData {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,31 @@
+package services
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+)
+
+// gzipMagic is the two-byte header that starts every gzip stream
+var gzipMagic = []byte{0x1f, 0x8b}
+
+// decompressCareData returns care data decompressed when gzipped is set or
+// the data starts with the gzip magic bytes, and unchanged otherwise
+func decompressCareData(data []byte, gzipped bool) ([]byte, error) {
+	if !gzipped && !bytes.HasPrefix(data, gzipMagic) {
+		return data, nil
+	}
+
+	reader, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open gzipped care data: %w", err)
+	}
+	defer reader.Close()
+
+	data, err = io.ReadAll(reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decompress care data: %w", err)
+	}
+	return data, nil
+}
@@ -0,0 +1,157 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+
+	"succulent-identifier-backend/models"
+)
+
+// maxRemoteCareDataSize caps a care data download so a misbehaving server
+// cannot exhaust memory
+const maxRemoteCareDataSize = 20 << 20 // 20 MB
+
+// errPrivateAddress is returned when a care data URL resolves to an internal address
+var errPrivateAddress = errors.New("care data URL resolves to a private or local address")
+
+// NewRemoteCareDataService creates a care data service that downloads care data
+// from an http(s) URL. Requests to loopback, private and link-local addresses
+// are refused, including after redirects, so the URL cannot reach internal services.
+func NewRemoteCareDataService(rawURL string) (*CareDataService, error) {
+	return newRemoteCareDataService(rawURL, newGuardedHTTPClient())
+}
+
+// newRemoteCareDataService creates a remote care data service using client and
+// performs the initial download
+func newRemoteCareDataService(rawURL string, client *http.Client) (*CareDataService, error) {
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid care data URL: %w", err)
+	}
+	if parsed.Scheme != "http" && parsed.Scheme != "https" {
+		return nil, fmt.Errorf("care data URL must use http or https, got %q", parsed.Scheme)
+	}
+	if parsed.Host == "" {
+		return nil, fmt.Errorf("care data URL has no host")
+	}
+
+	service := &CareDataService{
+		path:       parsed.Redacted(),
+		url:        rawURL,
+		httpClient: client,
+	}
+	if err := service.reloadRemote(); err != nil {
+		return nil, err
+	}
+	return service, nil
+}
+
+// reloadRemote downloads the care data, sending the previous ETag so an
+// unchanged file is answered with 304 and not transferred again
+func (s *CareDataService) reloadRemote() error {
+	careData, etag, err := s.fetchRemote()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err != nil {
+		s.lastError = err
+		return err
+	}
+
+	if careData != nil {
+		s.careData = careData
+		s.etag = etag
+	}
+	s.loadedAt = time.Now().UTC()
+	s.lastError = nil
+	return nil
+}
+
+// fetchRemote downloads and parses the care data. It returns nil data when the
+// server reports the file unchanged since the last download.
+func (s *CareDataService) fetchRemote() (map[string]models.CareInstructions, string, error) {
+	req, err := http.NewRequest(http.MethodGet, s.url, nil)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to create care data request: %w", err)
+	}
+
+	s.mu.RLock()
+	etag := s.etag
+	s.mu.RUnlock()
+	if etag != "" {
+		req.Header.Set("If-None-Match", etag)
+	}
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to download care data: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotModified {
+		return nil, etag, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, "", fmt.Errorf("care data download returned status %d", resp.StatusCode)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxRemoteCareDataSize+1))
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to read care data download: %w", err)
+	}
+	if len(data) > maxRemoteCareDataSize {
+		return nil, "", fmt.Errorf("care data download exceeds %d bytes", maxRemoteCareDataSize)
+	}
+
+	careData, err := parseCareData(data, strings.HasSuffix(strings.ToLower(req.URL.Path), ".gz"))
+	if err != nil {
+		return nil, "", err
+	}
+	return careData, resp.Header.Get("ETag"), nil
+}
+
+// newGuardedHTTPClient returns an HTTP client that refuses to connect to
+// non-public addresses
+func newGuardedHTTPClient() *http.Client {
+	dialer := &net.Dialer{
+		Timeout: 10 * time.Second,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			ip := net.ParseIP(host)
+			if ip == nil || !isPublicIP(ip) {
+				return fmt.Errorf("%w: %s", errPrivateAddress, host)
+			}
+			return nil
+		},
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil // a proxy would dial on our behalf and bypass the address check
+	transport.DialContext = func(ctx context.Context, network, address string) (net.Conn, error) {
+		return dialer.DialContext(ctx, network, address)
+	}
+
+	return &http.Client{
+		Timeout:   30 * time.Second,
+		Transport: transport,
+	}
+}
+
+// isPublicIP reports whether ip is a globally routable unicast address
+func isPublicIP(ip net.IP) bool {
+	return !(ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast())
+}
@@ -0,0 +1,380 @@
+package services
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"succulent-identifier-backend/models"
+)
+
+func TestNewCareDataService(t *testing.T) {
+	tests := []struct {
+		name    string
+		path    string
+		wantErr bool
+	}{
+		{
+			name:    "Valid care data file",
+			path:    "../testdata/care_data_test.json",
+			wantErr: false,
+		},
+		{
+			name:    "Valid gzipped care data file",
+			path:    "../testdata/care_data_test.json.gz",
+			wantErr: false,
+		},
+		{
+			name:    "Non-existent file",
+			path:    "../testdata/does_not_exist.json",
+			wantErr: true,
+		},
+		{
+			name:    "Empty path",
+			path:    "",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service, err := NewCareDataService(tt.path)
+
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("NewCareDataService() expected error, got nil")
+				}
+				return
+			}
+
+			if err != nil {
+				t.Errorf("NewCareDataService() unexpected error: %v", err)
+				return
+			}
+
+			if service == nil {
+				t.Error("NewCareDataService() returned nil service")
+			}
+		})
+	}
+}
+
+func TestNewCareDataServiceMissingFileMessage(t *testing.T) {
+	cwd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("Failed to get working directory: %v", err)
+	}
+
+	_, err = NewCareDataService("../testdata/does_not_exist.json")
+	if err == nil {
+		t.Fatal("NewCareDataService() expected error, got nil")
+	}
+	resolved := filepath.Join(filepath.Dir(cwd), "testdata", "does_not_exist.json")
+	for _, expected := range []string{resolved, cwd, "CARE_DATA_PATH"} {
+		if !strings.Contains(err.Error(), expected) {
+			t.Errorf("Expected error to mention %q, got %q", expected, err.Error())
+		}
+	}
+
+	// Absolute paths are unambiguous and reported as given
+	_, err = NewCareDataService(resolved)
+	if err == nil || !strings.Contains(err.Error(), resolved) || strings.Contains(err.Error(), "working directory") {
+		t.Errorf("Expected error naming only %s, got %v", resolved, err)
+	}
+}
+
+func TestGetCareInstructions(t *testing.T) {
+	service, err := NewCareDataService("../testdata/care_data_test.json")
+	if err != nil {
+		t.Fatalf("Failed to create care data service: %v", err)
+	}
+
+	tests := []struct {
+		name             string
+		species          string
+		genus            string
+		wantErr          bool
+		expectedSunlight string
+	}{
+		{
+			name:             "Species-level care",
+			species:          "test_genus_species",
+			genus:            "test_genus",
+			expectedSunlight: "Species-level sunlight",
+		},
+		{
+			name:             "Fallback to genus-level care",
+			species:          "test_genus_unknown",
+			genus:            "test_genus",
+			expectedSunlight: "Genus-level sunlight",
+		},
+		{
+			name:             "Empty species uses genus",
+			species:          "",
+			genus:            "test_genus",
+			expectedSunlight: "Genus-level sunlight",
+		},
+		{
+			name:    "No matching data",
+			species: "unknown_species",
+			genus:   "unknown",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			care, err := service.GetCareInstructions(tt.species, tt.genus)
+
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("GetCareInstructions() expected error, got nil")
+				}
+				return
+			}
+
+			if err != nil {
+				t.Errorf("GetCareInstructions() unexpected error: %v", err)
+				return
+			}
+
+			if care.Sunlight != tt.expectedSunlight {
+				t.Errorf("GetCareInstructions() sunlight = %v, expected %v", care.Sunlight, tt.expectedSunlight)
+			}
+		})
+	}
+}
+
+func TestGetCareInstructionsMergeSpecies(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "care_data.json")
+	data := `{
+		"test_genus": {"sunlight": "Genus sunlight", "watering": "Genus watering", "soil": "Genus soil", "notes": "Genus notes"},
+		"test_genus_species": {"watering": "Species watering", "notes": "Species notes"}
+	}`
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatalf("Failed to write care data: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		merge    bool
+		species  string
+		expected models.CareInstructions
+	}{
+		{
+			name:     "Replace uses the species entry alone",
+			merge:    false,
+			species:  "test_genus_species",
+			expected: models.CareInstructions{Watering: "Species watering", Notes: "Species notes"},
+		},
+		{
+			name:     "Merge inherits empty fields from the genus",
+			merge:    true,
+			species:  "test_genus_species",
+			expected: models.CareInstructions{Sunlight: "Genus sunlight", Watering: "Species watering", Soil: "Genus soil", Notes: "Species notes"},
+		},
+		{
+			name:     "Merge without species entry uses the genus",
+			merge:    true,
+			species:  "test_genus_unknown",
+			expected: models.CareInstructions{Sunlight: "Genus sunlight", Watering: "Genus watering", Soil: "Genus soil", Notes: "Genus notes"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service, err := NewCareDataService(path)
+			if err != nil {
+				t.Fatalf("Failed to create care data service: %v", err)
+			}
+			service.SetMergeSpecies(tt.merge)
+
+			care, err := service.GetCareInstructions(tt.species, "test_genus")
+			if err != nil {
+				t.Fatalf("GetCareInstructions() unexpected error: %v", err)
+			}
+			if care != tt.expected {
+				t.Errorf("GetCareInstructions() = %+v, expected %+v", care, tt.expected)
+			}
+		})
+	}
+}
+
+func TestLoadCareDataGzip(t *testing.T) {
+	plain, err := loadCareData("../testdata/care_data_test.json")
+	if err != nil {
+		t.Fatalf("Failed to load plain care data: %v", err)
+	}
+
+	gzipped, err := loadCareData("../testdata/care_data_test.json.gz")
+	if err != nil {
+		t.Fatalf("Failed to load gzipped care data: %v", err)
+	}
+
+	// A gzipped file without the .gz extension is detected by its magic bytes
+	content, err := os.ReadFile("../testdata/care_data_test.json.gz")
+	if err != nil {
+		t.Fatalf("Failed to read gzipped fixture: %v", err)
+	}
+	noExtPath := filepath.Join(t.TempDir(), "care_data.json")
+	if err := os.WriteFile(noExtPath, content, 0644); err != nil {
+		t.Fatalf("Failed to write fixture copy: %v", err)
+	}
+	sniffed, err := loadCareData(noExtPath)
+	if err != nil {
+		t.Fatalf("Failed to load gzipped care data without extension: %v", err)
+	}
+
+	for name, loaded := range map[string]map[string]models.CareInstructions{
+		"gzip by extension":   gzipped,
+		"gzip by magic bytes": sniffed,
+	} {
+		t.Run(name, func(t *testing.T) {
+			if len(loaded) != len(plain) {
+				t.Fatalf("Expected %d entries, got %d", len(plain), len(loaded))
+			}
+			for key, care := range plain {
+				if loaded[key] != care {
+					t.Errorf("Entry %s mismatch: got %+v, expected %+v", key, loaded[key], care)
+				}
+			}
+		})
+	}
+}
+
+func TestCareDataServiceReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "care_data.json")
+	content, _ := os.ReadFile("../testdata/care_data_test.json")
+	os.WriteFile(path, content, 0644)
+
+	service, err := NewCareDataService(path)
+	if err != nil {
+		t.Fatalf("Failed to create care data service: %v", err)
+	}
+
+	initial := service.Status()
+	if initial.Entries != 2 || initial.LastError != nil || initial.LoadedAt.IsZero() {
+		t.Fatalf("Unexpected initial status: %+v", initial)
+	}
+
+	// A malformed file fails the reload but keeps serving the previous data
+	os.WriteFile(path, []byte("{not json"), 0644)
+	if err := service.Reload(); err == nil {
+		t.Fatal("Reload() expected error for malformed file")
+	}
+
+	stale := service.Status()
+	if stale.LastError == nil {
+		t.Error("Status() expected last error after failed reload")
+	}
+	if stale.Entries != initial.Entries || !stale.LoadedAt.Equal(initial.LoadedAt) {
+		t.Errorf("Status() expected previous data to be kept, got %+v", stale)
+	}
+	if _, err := service.GetCareInstructions("test_genus_species", "test_genus"); err != nil {
+		t.Errorf("GetCareInstructions() expected stale data to be served: %v", err)
+	}
+
+	// A successful reload clears the error and picks up new data
+	os.WriteFile(path, []byte(`{"aloe": {"sunlight": "Full sun"}}`), 0644)
+	if err := service.Reload(); err != nil {
+		t.Fatalf("Reload() unexpected error: %v", err)
+	}
+
+	reloaded := service.Status()
+	if reloaded.LastError != nil || reloaded.Entries != 1 {
+		t.Errorf("Status() after successful reload = %+v", reloaded)
+	}
+}
+
+func TestRemoteCareDataRefresh(t *testing.T) {
+	var mu sync.Mutex
+	content := `{"aloe": {"sunlight": "Full sun", "watering": "Sparingly", "soil": "Sandy"}}`
+	etag := `"v1"`
+	downloads := 0
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		if r.Header.Get("If-None-Match") == etag {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		downloads++
+		w.Header().Set("ETag", etag)
+		w.Write([]byte(content))
+	}))
+	defer server.Close()
+
+	service, err := newRemoteCareDataService(server.URL+"/care_data.json", server.Client())
+	if err != nil {
+		t.Fatalf("newRemoteCareDataService() unexpected error: %v", err)
+	}
+	if care, err := service.GetCareInstructions("", "aloe"); err != nil || care.Sunlight != "Full sun" {
+		t.Fatalf("Expected downloaded care data, got %+v, %v", care, err)
+	}
+
+	// Unchanged data is revalidated with the ETag, not downloaded again
+	if err := service.Reload(); err != nil {
+		t.Fatalf("Reload() unexpected error: %v", err)
+	}
+	if downloads != 1 {
+		t.Errorf("Expected 1 download for unchanged data, got %d", downloads)
+	}
+
+	// Changed data is picked up on the next refresh
+	mu.Lock()
+	content = `{"aloe": {"sunlight": "Partial shade", "watering": "Sparingly", "soil": "Sandy"}, "sedum": {"sunlight": "Full sun"}}`
+	etag = `"v2"`
+	mu.Unlock()
+
+	if err := service.Reload(); err != nil {
+		t.Fatalf("Reload() unexpected error: %v", err)
+	}
+	if care, _ := service.GetCareInstructions("", "aloe"); care.Sunlight != "Partial shade" {
+		t.Errorf("Expected refreshed care data, got sunlight %q", care.Sunlight)
+	}
+	if status := service.Status(); status.Entries != 2 || status.LastError != nil {
+		t.Errorf("Unexpected status after refresh: %+v", status)
+	}
+
+	// A failed refresh keeps serving the previous data
+	server.Close()
+	if err := service.Reload(); err == nil {
+		t.Fatal("Reload() expected error when the server is down")
+	}
+	if care, _ := service.GetCareInstructions("", "aloe"); care.Sunlight != "Partial shade" {
+		t.Errorf("Expected previous care data after failed refresh, got sunlight %q", care.Sunlight)
+	}
+	if service.Status().LastError == nil {
+		t.Error("Expected failed refresh to be reported in status")
+	}
+}
+
+func TestNewRemoteCareDataServiceRejectsUnsafeURLs(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"aloe": {"sunlight": "Full sun"}}`))
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name string
+		url  string
+	}{
+		{name: "Loopback server", url: server.URL + "/care_data.json"},
+		{name: "Cloud metadata address", url: "http://169.254.169.254/latest/meta-data"},
+		{name: "Unsupported scheme", url: "file:///etc/passwd"},
+		{name: "Missing host", url: "https:///care_data.json"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewRemoteCareDataService(tt.url); err == nil {
+				t.Errorf("NewRemoteCareDataService(%q) expected error", tt.url)
+			}
+		})
+	}
+}
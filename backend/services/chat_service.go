@@ -5,42 +5,188 @@ import (
 	"encoding/json"
 	"fmt"
 	"log"
+	"reflect"
+	"slices"
+	"strings"
 
 	"github.com/sashabaranov/go-openai"
 	"succulent-identifier-backend/db"
 )
 
+// chatCompletionClient is the subset of the OpenAI client used by ChatService
+type chatCompletionClient interface {
+	CreateChatCompletion(ctx context.Context, req openai.ChatCompletionRequest) (openai.ChatCompletionResponse, error)
+}
+
 // ChatService handles LLM chat interactions
 type ChatService struct {
-	client *openai.Client
-	model  string
+	client             chatCompletionClient
+	model              string
+	contextTokenBudget int
+	careRetries        int
+	detectLanguage     bool
+	careReferences     bool
 }
 
 // NewChatService creates a new chat service
 func NewChatService(apiKey string) *ChatService {
 	return &ChatService{
-		client: openai.NewClient(apiKey),
-		model:  openai.GPT4oMini, // Using GPT-4o-mini for cost efficiency
+		client:             openai.NewClient(apiKey),
+		model:              openai.GPT4oMini, // Using GPT-4o-mini for cost efficiency
+		contextTokenBudget: defaultContextTokenBudget,
+		careRetries:        defaultCareGenerationRetries,
+		detectLanguage:     true,
+	}
+}
+
+// SetContextTokenBudget sets the estimated prompt size that chat history is trimmed to fit
+func (s *ChatService) SetContextTokenBudget(budget int) {
+	if budget > 0 {
+		s.contextTokenBudget = budget
+	}
+}
+
+// SetCareRetries sets how many times a transiently failing care generation is retried (0 disables)
+func (s *ChatService) SetCareRetries(retries int) {
+	if retries >= 0 {
+		s.careRetries = retries
 	}
 }
 
+// SetLanguageDetection toggles replying in the language detected in the user's message
+func (s *ChatService) SetLanguageDetection(enabled bool) {
+	s.detectLanguage = enabled
+}
+
+// SetCareReferences toggles reporting which care fields a reply drew on
+func (s *ChatService) SetCareReferences(enabled bool) {
+	s.careReferences = enabled
+}
+
 // ChatRequest represents a chat request with context
 type ChatRequest struct {
 	UserMessage      string
 	Identification   *db.Identification
 	ConversationHistory []db.ChatMessage
+	Model            string // overrides the configured model when set
 }
 
 // ChatResponse represents the LLM response
 type ChatResponse struct {
 	Message string
 	Error   error
+	Usage   TokenUsage
+	Model   string // model that produced the reply
+
+	// Truncated is set when the reply was cut off by the max token cap
+	Truncated bool
+
+	// CareReferences lists the care fields of the plant context the exchange
+	// is about, e.g. "watering"; only set with care references enabled
+	CareReferences []string
+}
+
+// TokenUsage reports the tokens consumed by an LLM call
+type TokenUsage struct {
+	PromptTokens     int
+	CompletionTokens int
+	TotalTokens      int
 }
 
 // Chat sends a message to OpenAI with plant identification context
 func (s *ChatService) Chat(ctx context.Context, req ChatRequest) (*ChatResponse, error) {
+	messages := s.buildMessages(req)
+
+	model := s.model
+	if req.Model != "" {
+		model = req.Model
+	}
+
+	// Call OpenAI API
+	resp, err := s.client.CreateChatCompletion(
+		ctx,
+		openai.ChatCompletionRequest{
+			Model:       model,
+			Messages:    messages,
+			Temperature: 0.7,
+			MaxTokens:   500,
+		},
+	)
+
+	if err != nil {
+		log.Printf("OpenAI API error: %v", err)
+		return nil, fmt.Errorf("failed to get response from LLM: %w", err)
+	}
+
+	if len(resp.Choices) == 0 {
+		return nil, fmt.Errorf("no response from LLM")
+	}
+
+	reply := resp.Choices[0].Message.Content
+	var references []string
+	if s.careReferences {
+		references = careReferences(req.Identification, req.UserMessage, reply)
+	}
+
+	return &ChatResponse{
+		Message: reply,
+		Usage: TokenUsage{
+			PromptTokens:     resp.Usage.PromptTokens,
+			CompletionTokens: resp.Usage.CompletionTokens,
+			TotalTokens:      resp.Usage.TotalTokens,
+		},
+		Model:          model,
+		Truncated:      resp.Choices[0].FinishReason == openai.FinishReasonLength,
+		CareReferences: references,
+	}, nil
+}
+
+// careReferenceKeywords ties each care field given to the LLM in the system
+// prompt to words showing a question or reply is about it
+var careReferenceKeywords = []struct {
+	field    string
+	keywords []string
+}{
+	{"sunlight", []string{"sun", "light", "shade", "bright"}},
+	{"watering", []string{"water", "drought", "moist", "soak"}},
+	{"soil", []string{"soil", "potting", "drainage", "draining", "perlite", "pumice"}},
+	{"notes", []string{"propagat", "dorman", "toxic", "bloom", "flower"}},
+}
+
+// careReferences returns the care fields in the plant context that the user
+// message or the reply mentions, in care guide order
+func careReferences(identification *db.Identification, userMessage, reply string) []string {
+	if identification == nil || identification.CareGuide == nil {
+		return nil
+	}
+	care := identification.CareGuide
+	inPrompt := map[string]bool{
+		"sunlight": care.Sunlight != "",
+		"watering": care.Watering != "",
+		"soil":     care.Soil != "",
+		"notes":    care.Notes != "",
+	}
+
+	text := strings.ToLower(userMessage + "\n" + reply)
+	var references []string
+	for _, entry := range careReferenceKeywords {
+		if !inPrompt[entry.field] {
+			continue
+		}
+		if slices.ContainsFunc(entry.keywords, func(keyword string) bool { return strings.Contains(text, keyword) }) {
+			references = append(references, entry.field)
+		}
+	}
+	return references
+}
+
+// buildMessages assembles the system prompt, recent history and the user message
+func (s *ChatService) buildMessages(req ChatRequest) []openai.ChatCompletionMessage {
 	// Build system prompt with plant context
 	systemPrompt := s.buildSystemPrompt(req.Identification)
+	if s.detectLanguage {
+		systemPrompt += languageInstruction(DetectLanguage(req.UserMessage))
+	}
 
 	// Build messages array
 	messages := []openai.ChatCompletionMessage{
@@ -74,34 +220,22 @@ func (s *ChatService) Chat(ctx context.Context, req ChatRequest) (*ChatResponse,
 		Content: req.UserMessage,
 	})
 
-	// Call OpenAI API
-	resp, err := s.client.CreateChatCompletion(
-		ctx,
-		openai.ChatCompletionRequest{
-			Model:       s.model,
-			Messages:    messages,
-			Temperature: 0.7,
-			MaxTokens:   500,
-		},
-	)
-
-	if err != nil {
-		log.Printf("OpenAI API error: %v", err)
-		return nil, fmt.Errorf("failed to get response from LLM: %w", err)
-	}
-
-	if len(resp.Choices) == 0 {
-		return nil, fmt.Errorf("no response from LLM")
+	// Drop the oldest history until the estimated prompt fits the budget.
+	// The system prompt and the current message are always kept.
+	for len(messages) > 2 && estimateMessagesTokens(messages) > s.contextTokenBudget {
+		messages = append(messages[:1], messages[2:]...)
 	}
 
-	return &ChatResponse{
-		Message: resp.Choices[0].Message.Content,
-	}, nil
+	return messages
 }
 
-// GenerateCareInstructions uses LLM to generate care instructions for a plant
-func (s *ChatService) GenerateCareInstructions(ctx context.Context, genus, species string) (*db.CareGuide, error) {
-	prompt := fmt.Sprintf(
+// CareSystemPrompt is the system message of care generation requests
+const CareSystemPrompt = "You are an expert botanist specializing in succulent plants. Provide accurate, detailed care instructions in JSON format."
+
+// CarePrompt returns the user message GenerateCareInstructions sends to the
+// LLM for a plant
+func CarePrompt(genus, species string) string {
+	return fmt.Sprintf(
 		`Generate care instructions for the succulent plant: %s %s
 
 Please provide specific care guidance in the following format (respond ONLY with valid JSON, no markdown formatting):
@@ -111,34 +245,52 @@ Please provide specific care guidance in the following format (respond ONLY with
   "watering": "<detailed watering schedule and tips>",
   "soil": "<detailed soil requirements and recommendations>",
   "notes": "<additional care tips, growth patterns, or common issues>",
-  "trivia": "<interesting facts, origin, cultural significance, or fun botanical trivia about this plant>"
+  "trivia": "<interesting facts, origin, cultural significance, or fun botanical trivia about this plant>",
+  "difficulty": "<exactly one of easy, moderate or hard: how hard this plant is for a beginner to keep alive>"
 }
 
 Be specific, practical, and helpful. Include measurements and frequencies where relevant.`,
 		genus,
 		species,
 	)
+}
 
+// GenerateCareInstructions uses LLM to generate care instructions for a plant
+func (s *ChatService) GenerateCareInstructions(ctx context.Context, genus, species string) (*db.CareGuide, error) {
 	messages := []openai.ChatCompletionMessage{
 		{
 			Role:    openai.ChatMessageRoleSystem,
-			Content: "You are an expert botanist specializing in succulent plants. Provide accurate, detailed care instructions in JSON format.",
+			Content: CareSystemPrompt,
 		},
 		{
 			Role:    openai.ChatMessageRoleUser,
-			Content: prompt,
+			Content: CarePrompt(genus, species),
 		},
 	}
 
-	resp, err := s.client.CreateChatCompletion(
-		ctx,
-		openai.ChatCompletionRequest{
-			Model:       s.model,
-			Messages:    messages,
-			Temperature: 0.7,
-			MaxTokens:   400,
-		},
-	)
+	request := openai.ChatCompletionRequest{
+		Model:       s.model,
+		Messages:    messages,
+		Temperature: 0.7,
+		MaxTokens:   400,
+	}
+
+	// Retry transient failures (5xx, rate limits, timeouts) with exponential backoff
+	var resp openai.ChatCompletionResponse
+	var err error
+	delay := llmRetryBaseDelay
+	for attempt := 0; ; attempt++ {
+		resp, err = s.client.CreateChatCompletion(ctx, request)
+		if err == nil || attempt >= s.careRetries || !isRetryableLLMError(err) {
+			break
+		}
+
+		log.Printf("OpenAI API error while generating care instructions (attempt %d/%d), retrying: %v", attempt+1, s.careRetries+1, err)
+		if sleepErr := sleepContext(ctx, delay); sleepErr != nil {
+			break
+		}
+		delay *= 2
+	}
 
 	if err != nil {
 		log.Printf("OpenAI API error while generating care instructions: %v", err)
@@ -159,11 +311,82 @@ Be specific, practical, and helpful. Include measurements and frequencies where
 		log.Printf("Failed to parse care instructions JSON: %v\nContent: %s", err, content)
 		return nil, fmt.Errorf("failed to parse care instructions: %w", err)
 	}
+	normalizeCareDifficulty(careGuide)
+	captureExtraCareFields(careGuide, content, genus, species)
 
 	log.Printf("Generated care instructions for %s %s", genus, species)
 	return careGuide, nil
 }
 
+// normalizeCareDifficulty lowercases the generated difficulty and drops it
+// when it is not an allowed rating; the rest of the care is still usable
+func normalizeCareDifficulty(careGuide *db.CareGuide) {
+	difficulty := strings.ToLower(strings.TrimSpace(careGuide.Difficulty))
+	if difficulty != "" && !db.IsValidCareDifficulty(difficulty) {
+		log.Printf("Ignoring invalid care difficulty %q", careGuide.Difficulty)
+		difficulty = ""
+	}
+	careGuide.Difficulty = difficulty
+}
+
+// careGuideFields are the JSON keys db.CareGuide decodes
+var careGuideFields = jsonFieldNames(reflect.TypeOf(db.CareGuide{}))
+
+// captureExtraCareFields keeps generated fields db.CareGuide does not decode in
+// ExtraFields, logging them so operators know to extend the struct
+func captureExtraCareFields(careGuide *db.CareGuide, content, genus, species string) {
+	var fields map[string]any
+	if err := json.Unmarshal([]byte(content), &fields); err != nil {
+		return
+	}
+	for key, value := range fields {
+		if careGuideFields[key] {
+			continue
+		}
+		if careGuide.ExtraFields == nil {
+			careGuide.ExtraFields = map[string]any{}
+		}
+		careGuide.ExtraFields[key] = value
+	}
+	if len(careGuide.ExtraFields) == 0 {
+		return
+	}
+
+	keys := make([]string, 0, len(careGuide.ExtraFields))
+	for key := range careGuide.ExtraFields {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	log.Printf("Generated care for %s %s has fields CareGuide does not capture, kept in extra_fields: %s",
+		genus, species, strings.Join(keys, ", "))
+}
+
+// jsonFieldNames returns the JSON keys of a struct type's exported fields
+func jsonFieldNames(t reflect.Type) map[string]bool {
+	names := map[string]bool{}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" || !field.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		names[name] = true
+	}
+	return names
+}
+
+// languageInstruction asks the assistant to reply in the user's language when
+// it was detected as something other than the default
+func languageInstruction(language string) string {
+	if language == "" || language == defaultLanguage {
+		return ""
+	}
+	return fmt.Sprintf("\n\nThe user is writing in %s. Always respond in the user's language (%s).", language, language)
+}
+
 // buildSystemPrompt creates a system prompt with plant identification context
 func (s *ChatService) buildSystemPrompt(identification *db.Identification) string {
 	prompt := "You are a helpful succulent plant expert assistant. "
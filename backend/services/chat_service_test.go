@@ -0,0 +1,450 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/sashabaranov/go-openai"
+	"succulent-identifier-backend/db"
+)
+
+func TestEstimateTokens(t *testing.T) {
+	tests := []struct {
+		text     string
+		expected int
+	}{
+		{text: "", expected: 0},
+		{text: "abc", expected: 1},
+		{text: "abcd", expected: 1},
+		{text: "abcde", expected: 2},
+		{text: strings.Repeat("x", 400), expected: 100},
+	}
+
+	for _, tt := range tests {
+		if got := EstimateTokens(tt.text); got != tt.expected {
+			t.Errorf("EstimateTokens(%d chars) = %d, expected %d", len(tt.text), got, tt.expected)
+		}
+	}
+}
+
+func TestBuildMessagesTrimsHistoryToBudget(t *testing.T) {
+	service := NewChatService("test-key")
+	service.SetContextTokenBudget(1000)
+
+	// Six ~300 token messages cannot all fit in a 1000 token budget
+	history := make([]db.ChatMessage, 0, 6)
+	for i := 0; i < 6; i++ {
+		sender := "user"
+		if i%2 == 1 {
+			sender = "llm"
+		}
+		history = append(history, db.ChatMessage{
+			Message: strings.Repeat(string(rune('a'+i)), 1200),
+			Sender:  sender,
+		})
+	}
+
+	req := ChatRequest{
+		UserMessage:         "How much light does it need?",
+		Identification:      &db.Identification{Genus: "Haworthia", Species: "zebrina", Confidence: 0.9},
+		ConversationHistory: history,
+	}
+	messages := service.buildMessages(req)
+
+	if tokens := estimateMessagesTokens(messages); tokens > 1000 {
+		t.Errorf("Estimated prompt of %d tokens exceeds budget", tokens)
+	}
+	if messages[0].Content != service.buildSystemPrompt(req.Identification) {
+		t.Error("Expected system prompt to be kept first")
+	}
+	if last := messages[len(messages)-1]; last.Content != req.UserMessage {
+		t.Errorf("Expected current user message last, got %q", last.Content)
+	}
+
+	// Kept history must be the newest messages, in order
+	kept := messages[1 : len(messages)-1]
+	if len(kept) == 0 || len(kept) >= len(history) {
+		t.Fatalf("Expected history to be partially trimmed, kept %d of %d", len(kept), len(history))
+	}
+	offset := len(history) - len(kept)
+	for i, msg := range kept {
+		if msg.Content != history[offset+i].Message {
+			t.Errorf("History message %d is not the expected recent message", i)
+		}
+	}
+}
+
+func TestBuildMessagesKeepsHistoryWithinBudget(t *testing.T) {
+	service := NewChatService("test-key")
+
+	history := []db.ChatMessage{
+		{Message: "What is this plant?", Sender: "user"},
+		{Message: "This is Aloe vera.", Sender: "llm"},
+	}
+	messages := service.buildMessages(ChatRequest{
+		UserMessage:         "Is it pet-safe?",
+		ConversationHistory: history,
+	})
+
+	if len(messages) != len(history)+2 {
+		t.Errorf("Expected all %d history messages kept, got %d messages", len(history), len(messages))
+	}
+}
+
+// mockCompletionClient returns the queued errors in order, then a care guide response
+type mockCompletionClient struct {
+	errs         []error
+	calls        int
+	lastModel    string
+	lastMessages []openai.ChatCompletionMessage
+	finishReason openai.FinishReason
+	content      string // reply content, a fixed care guide when empty
+}
+
+func (m *mockCompletionClient) CreateChatCompletion(ctx context.Context, req openai.ChatCompletionRequest) (openai.ChatCompletionResponse, error) {
+	m.calls++
+	m.lastModel = req.Model
+	m.lastMessages = req.Messages
+	if len(m.errs) > 0 {
+		err := m.errs[0]
+		m.errs = m.errs[1:]
+		return openai.ChatCompletionResponse{}, err
+	}
+	content := m.content
+	if content == "" {
+		content = `{"sunlight":"Bright light","watering":"Sparingly","soil":"Gritty mix","notes":"","trivia":""}`
+	}
+	return openai.ChatCompletionResponse{
+		Choices: []openai.ChatCompletionChoice{
+			{
+				Message:      openai.ChatCompletionMessage{Content: content},
+				FinishReason: m.finishReason,
+			},
+		},
+	}, nil
+}
+
+func TestCarePrompt(t *testing.T) {
+	prompt := CarePrompt("Echeveria", "elegans")
+	if !strings.Contains(prompt, "Echeveria elegans") {
+		t.Errorf("Expected the prompt to name Echeveria elegans, got %q", prompt)
+	}
+
+	// The preview must match what is actually sent
+	client := &mockCompletionClient{}
+	service := NewChatService("test-key")
+	service.client = client
+	if _, err := service.GenerateCareInstructions(context.Background(), "Echeveria", "elegans"); err != nil {
+		t.Fatalf("GenerateCareInstructions() unexpected error: %v", err)
+	}
+	sent := client.lastMessages
+	if len(sent) != 2 || sent[0].Content != CareSystemPrompt || sent[1].Content != prompt {
+		t.Errorf("Expected the system message and CarePrompt to be sent, got %+v", sent)
+	}
+}
+
+func TestGenerateCareInstructionsRetries(t *testing.T) {
+	llmRetryBaseDelay = 0
+
+	tests := []struct {
+		name          string
+		retries       int
+		errs          []error
+		expectError   bool
+		expectedCalls int
+	}{
+		{
+			name:          "Server error then success",
+			retries:       2,
+			errs:          []error{&openai.APIError{HTTPStatusCode: 503, Message: "overloaded"}},
+			expectError:   false,
+			expectedCalls: 2,
+		},
+		{
+			name:          "Timeout then success",
+			retries:       2,
+			errs:          []error{context.DeadlineExceeded},
+			expectError:   false,
+			expectedCalls: 2,
+		},
+		{
+			name:          "Client error is not retried",
+			retries:       2,
+			errs:          []error{&openai.APIError{HTTPStatusCode: 400, Message: "bad request"}},
+			expectError:   true,
+			expectedCalls: 1,
+		},
+		{
+			name:    "Gives up after configured retries",
+			retries: 1,
+			errs: []error{
+				&openai.RequestError{HTTPStatusCode: 502},
+				&openai.RequestError{HTTPStatusCode: 502},
+			},
+			expectError:   true,
+			expectedCalls: 2,
+		},
+		{
+			name:          "Retries disabled",
+			retries:       0,
+			errs:          []error{&openai.APIError{HTTPStatusCode: 500}},
+			expectError:   true,
+			expectedCalls: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &mockCompletionClient{errs: tt.errs}
+			service := NewChatService("test-key")
+			service.client = client
+			service.SetCareRetries(tt.retries)
+
+			careGuide, err := service.GenerateCareInstructions(context.Background(), "echeveria", "elegans")
+
+			if tt.expectError {
+				if err == nil {
+					t.Error("Expected error but got none")
+				}
+			} else {
+				if err != nil {
+					t.Fatalf("Unexpected error: %v", err)
+				}
+				if careGuide == nil || careGuide.Sunlight != "Bright light" {
+					t.Errorf("Expected generated care guide, got %+v", careGuide)
+				}
+			}
+
+			if client.calls != tt.expectedCalls {
+				t.Errorf("Expected %d calls, got %d", tt.expectedCalls, client.calls)
+			}
+		})
+	}
+}
+
+func TestGenerateCareInstructionsDifficulty(t *testing.T) {
+	tests := []struct {
+		name       string
+		difficulty string
+		expected   string
+	}{
+		{name: "Allowed rating", difficulty: "moderate", expected: db.CareDifficultyModerate},
+		{name: "Normalized case and spacing", difficulty: " Easy ", expected: db.CareDifficultyEasy},
+		{name: "Unknown rating is dropped", difficulty: "impossible", expected: ""},
+		{name: "Missing rating", difficulty: "", expected: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service := NewChatService("test-key")
+			service.client = &mockCompletionClient{
+				content: fmt.Sprintf(`{"sunlight":"Full sun","watering":"Rarely","soil":"Gritty mix","difficulty":%q}`, tt.difficulty),
+			}
+
+			careGuide, err := service.GenerateCareInstructions(context.Background(), "lithops", "")
+			if err != nil {
+				t.Fatalf("GenerateCareInstructions() unexpected error: %v", err)
+			}
+			if careGuide.Difficulty != tt.expected {
+				t.Errorf("Difficulty = %q, expected %q", careGuide.Difficulty, tt.expected)
+			}
+			if careGuide.Sunlight != "Full sun" {
+				t.Errorf("Expected the rest of the care to be kept, got %+v", careGuide)
+			}
+		})
+	}
+}
+
+func TestGenerateCareInstructionsExtraFields(t *testing.T) {
+	var logs bytes.Buffer
+	log.SetOutput(&logs)
+	defer log.SetOutput(os.Stderr)
+
+	service := NewChatService("test-key")
+	service.client = &mockCompletionClient{
+		content: `{"sunlight":"Full sun","watering":"Rarely","soil":"Gritty mix","toxicity":"Toxic to cats","hardiness_zone":9}`,
+	}
+
+	careGuide, err := service.GenerateCareInstructions(context.Background(), "euphorbia", "euphorbia_obesa")
+	if err != nil {
+		t.Fatalf("GenerateCareInstructions() unexpected error: %v", err)
+	}
+
+	expected := map[string]any{"toxicity": "Toxic to cats", "hardiness_zone": float64(9)}
+	if !reflect.DeepEqual(careGuide.ExtraFields, expected) {
+		t.Errorf("ExtraFields = %v, expected %v", careGuide.ExtraFields, expected)
+	}
+	if careGuide.Sunlight != "Full sun" {
+		t.Errorf("Expected known fields to be decoded, got %+v", careGuide)
+	}
+	if !strings.Contains(logs.String(), "kept in extra_fields: hardiness_zone, toxicity") {
+		t.Errorf("Expected the extra fields to be logged, got %q", logs.String())
+	}
+
+	// Stored care keeps the extra fields
+	stored, _ := json.Marshal(careGuide)
+	var roundTrip db.CareGuide
+	json.Unmarshal(stored, &roundTrip)
+	if roundTrip.ExtraFields["toxicity"] != "Toxic to cats" {
+		t.Errorf("Expected extra fields to survive storage, got %s", stored)
+	}
+}
+
+func TestGenerateCareInstructionsNoExtraFields(t *testing.T) {
+	service := NewChatService("test-key")
+	service.client = &mockCompletionClient{
+		content: `{"sunlight":"Full sun","watering":"Rarely","soil":"Gritty mix","notes":"","difficulty":"easy"}`,
+	}
+
+	careGuide, err := service.GenerateCareInstructions(context.Background(), "lithops", "")
+	if err != nil {
+		t.Fatalf("GenerateCareInstructions() unexpected error: %v", err)
+	}
+	if careGuide.ExtraFields != nil {
+		t.Errorf("Expected no extra fields, got %v", careGuide.ExtraFields)
+	}
+}
+
+func TestChatModelOverride(t *testing.T) {
+	client := &mockCompletionClient{}
+	service := NewChatService("test-key")
+	service.client = client
+
+	resp, err := service.Chat(context.Background(), ChatRequest{UserMessage: "Hello"})
+	if err != nil {
+		t.Fatalf("Chat() unexpected error: %v", err)
+	}
+	if client.lastModel != openai.GPT4oMini || resp.Model != openai.GPT4oMini {
+		t.Errorf("Expected default model %s, requested %s and reported %s", openai.GPT4oMini, client.lastModel, resp.Model)
+	}
+
+	resp, err = service.Chat(context.Background(), ChatRequest{UserMessage: "Hello", Model: openai.GPT4o})
+	if err != nil {
+		t.Fatalf("Chat() unexpected error: %v", err)
+	}
+	if client.lastModel != openai.GPT4o || resp.Model != openai.GPT4o {
+		t.Errorf("Expected override model %s, requested %s and reported %s", openai.GPT4o, client.lastModel, resp.Model)
+	}
+}
+
+func TestChatReportsTruncatedReply(t *testing.T) {
+	client := &mockCompletionClient{finishReason: openai.FinishReasonStop}
+	service := NewChatService("test-key")
+	service.client = client
+
+	resp, err := service.Chat(context.Background(), ChatRequest{UserMessage: "Hello"})
+	if err != nil {
+		t.Fatalf("Chat() unexpected error: %v", err)
+	}
+	if resp.Truncated {
+		t.Error("Expected a completed reply not to be truncated")
+	}
+
+	client.finishReason = openai.FinishReasonLength
+	resp, err = service.Chat(context.Background(), ChatRequest{UserMessage: "Hello"})
+	if err != nil {
+		t.Fatalf("Chat() unexpected error: %v", err)
+	}
+	if !resp.Truncated {
+		t.Error("Expected a reply cut off by the token cap to be truncated")
+	}
+}
+
+func TestChatCareReferences(t *testing.T) {
+	client := &mockCompletionClient{content: "Let the soil dry out completely, then give it a deep drink."}
+	service := NewChatService("test-key")
+	service.client = client
+	identification := &db.Identification{
+		Genus:   "Haworthia",
+		Species: "zebrina",
+		CareGuide: &db.CareGuide{
+			Sunlight: "Bright indirect light",
+			Watering: "Water when the soil is completely dry",
+			Soil:     "Gritty cactus mix",
+		},
+	}
+	req := ChatRequest{UserMessage: "How often should I water it?", Identification: identification}
+
+	resp, err := service.Chat(context.Background(), req)
+	if err != nil {
+		t.Fatalf("Chat() unexpected error: %v", err)
+	}
+	if resp.CareReferences != nil {
+		t.Errorf("Expected no care references while disabled, got %v", resp.CareReferences)
+	}
+
+	service.SetCareReferences(true)
+	resp, err = service.Chat(context.Background(), req)
+	if err != nil {
+		t.Fatalf("Chat() unexpected error: %v", err)
+	}
+	if !slices.Equal(resp.CareReferences, []string{"watering", "soil"}) {
+		t.Errorf("Expected care references [watering soil], got %v", resp.CareReferences)
+	}
+
+	// Fields missing from the plant context are never referenced
+	identification.CareGuide.Soil = ""
+	resp, err = service.Chat(context.Background(), req)
+	if err != nil {
+		t.Fatalf("Chat() unexpected error: %v", err)
+	}
+	if !slices.Equal(resp.CareReferences, []string{"watering"}) {
+		t.Errorf("Expected care references [watering], got %v", resp.CareReferences)
+	}
+}
+
+func TestDetectLanguage(t *testing.T) {
+	tests := []struct {
+		text     string
+		expected string
+	}{
+		{text: "How often should I water this plant?", expected: "English"},
+		{text: "¿Con qué frecuencia debo regar mi planta?", expected: "Spanish"},
+		{text: "Comment dois-je arroser ma plante ?", expected: "French"},
+		{text: "Wie oft soll ich meine Pflanze gießen?", expected: "German"},
+		{text: "この植物はどのくらい水が必要ですか？", expected: "Japanese"},
+		{text: "Как часто поливать это растение?", expected: "Russian"},
+		{text: "Echeveria?", expected: ""},
+		{text: "", expected: ""},
+	}
+
+	for _, tt := range tests {
+		if got := DetectLanguage(tt.text); got != tt.expected {
+			t.Errorf("DetectLanguage(%q) = %q, expected %q", tt.text, got, tt.expected)
+		}
+	}
+}
+
+func TestBuildMessagesAddsLanguageHint(t *testing.T) {
+	service := NewChatService("test-key")
+	req := ChatRequest{
+		UserMessage:    "¿Con qué frecuencia debo regar mi planta?",
+		Identification: &db.Identification{Genus: "Echeveria", Species: "elegans", Confidence: 0.9},
+	}
+
+	systemPrompt := service.buildMessages(req)[0].Content
+	if !strings.Contains(systemPrompt, "Spanish") || !strings.Contains(systemPrompt, "respond in the user's language") {
+		t.Errorf("Expected Spanish language hint in system prompt, got %q", systemPrompt)
+	}
+
+	// English messages keep the default prompt
+	req.UserMessage = "How often should I water this plant?"
+	if systemPrompt := service.buildMessages(req)[0].Content; systemPrompt != service.buildSystemPrompt(req.Identification) {
+		t.Errorf("Expected no language hint for English, got %q", systemPrompt)
+	}
+
+	// Disabled detection never adds the hint
+	service.SetLanguageDetection(false)
+	req.UserMessage = "¿Con qué frecuencia debo regar mi planta?"
+	if systemPrompt := service.buildMessages(req)[0].Content; systemPrompt != service.buildSystemPrompt(req.Identification) {
+		t.Errorf("Expected no language hint when detection is disabled, got %q", systemPrompt)
+	}
+}
@@ -0,0 +1,44 @@
+package services
+
+import (
+	"sort"
+
+	"succulent-identifier-backend/models"
+)
+
+// CombineEnsemble merges per-model predictions by weighted vote: each label
+// scores the weighted mean of its confidence across models (0 where a model did
+// not predict it), and the result is sorted by score, highest first
+func CombineEnsemble(ensemble []models.ModelPredictions) []models.MLPrediction {
+	scores := make(map[string]float64)
+	totalWeight := 0.0
+	for _, model := range ensemble {
+		weight := model.Weight
+		if weight <= 0 {
+			weight = 1
+		}
+		totalWeight += weight
+
+		for _, prediction := range model.Predictions {
+			scores[prediction.Label] += weight * prediction.Confidence
+		}
+	}
+
+	combined := make([]models.MLPrediction, 0, len(scores))
+	for label, score := range scores {
+		combined = append(combined, models.MLPrediction{
+			Label:      label,
+			Confidence: score / totalWeight,
+		})
+	}
+
+	// Ties are broken by label so the top prediction is deterministic
+	sort.Slice(combined, func(i, j int) bool {
+		if combined[i].Confidence != combined[j].Confidence {
+			return combined[i].Confidence > combined[j].Confidence
+		}
+		return combined[i].Label < combined[j].Label
+	})
+
+	return combined
+}
@@ -0,0 +1,83 @@
+package services
+
+import (
+	"strings"
+	"unicode"
+)
+
+// defaultLanguage is the language replies use when no other language is detected
+const defaultLanguage = "English"
+
+// minStopwordHits is how many common words a Latin-script message needs before a
+// language is trusted; short or ambiguous messages are left undetected
+const minStopwordHits = 2
+
+// scriptLanguages maps writing systems that identify a language on their own
+var scriptLanguages = []struct {
+	table    *unicode.RangeTable
+	language string
+}{
+	{unicode.Hiragana, "Japanese"},
+	{unicode.Katakana, "Japanese"},
+	{unicode.Hangul, "Korean"},
+	{unicode.Han, "Chinese"},
+	{unicode.Cyrillic, "Russian"},
+	{unicode.Arabic, "Arabic"},
+	{unicode.Greek, "Greek"},
+	{unicode.Hebrew, "Hebrew"},
+	{unicode.Thai, "Thai"},
+	{unicode.Devanagari, "Hindi"},
+}
+
+// stopwords are frequent short words that distinguish Latin-script languages
+var stopwords = map[string][]string{
+	"English":    {"the", "is", "are", "and", "how", "what", "my", "it", "does", "do", "should", "of", "to", "this", "with"},
+	"Spanish":    {"el", "la", "los", "las", "es", "y", "que", "cómo", "como", "qué", "mi", "con", "para", "por", "una", "debo", "planta"},
+	"French":     {"le", "la", "les", "est", "et", "que", "comment", "mon", "ma", "avec", "pour", "une", "des", "dois", "plante", "je"},
+	"German":     {"der", "die", "das", "ist", "und", "wie", "was", "mein", "meine", "mit", "für", "eine", "soll", "ich", "pflanze"},
+	"Portuguese": {"o", "os", "as", "é", "e", "que", "como", "minha", "meu", "com", "para", "uma", "devo", "não", "planta"},
+	"Italian":    {"il", "lo", "gli", "è", "e", "che", "come", "mio", "mia", "con", "per", "una", "devo", "non", "pianta"},
+	"Dutch":      {"de", "het", "is", "en", "hoe", "wat", "mijn", "met", "voor", "een", "moet", "ik", "plant"},
+}
+
+// DetectLanguage returns the likely language of a chat message, or "" when it
+// cannot be told with reasonable confidence. Non-Latin scripts are recognized
+// by their characters, Latin-script languages by counting common words.
+func DetectLanguage(text string) string {
+	for _, r := range text {
+		for _, script := range scriptLanguages {
+			if unicode.Is(script.table, r) {
+				return script.language
+			}
+		}
+	}
+
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r)
+	})
+
+	best, bestHits, tied := "", 0, false
+	for language, common := range stopwords {
+		hits := 0
+		for _, word := range words {
+			for _, stopword := range common {
+				if word == stopword {
+					hits++
+					break
+				}
+			}
+		}
+
+		switch {
+		case hits > bestHits:
+			best, bestHits, tied = language, hits, false
+		case hits == bestHits:
+			tied = true
+		}
+	}
+
+	if bestHits < minStopwordHits || tied {
+		return ""
+	}
+	return best
+}
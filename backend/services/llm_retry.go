@@ -0,0 +1,56 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+// defaultCareGenerationRetries is how many times a failed care generation is retried
+const defaultCareGenerationRetries = 2
+
+// llmRetryBaseDelay is the backoff before the first retry, doubled on each further attempt
+var llmRetryBaseDelay = 500 * time.Millisecond
+
+// isRetryableLLMError reports whether an OpenAI call failed transiently: server
+// errors, rate limiting and timeouts are retried, other client errors are not
+func isRetryableLLMError(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) {
+		return false
+	}
+
+	statusCode := 0
+	var apiErr *openai.APIError
+	var reqErr *openai.RequestError
+	switch {
+	case errors.As(err, &apiErr):
+		statusCode = apiErr.HTTPStatusCode
+	case errors.As(err, &reqErr):
+		statusCode = reqErr.HTTPStatusCode
+	}
+	if statusCode != 0 {
+		return statusCode >= 500 || statusCode == http.StatusTooManyRequests || statusCode == http.StatusRequestTimeout
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+	return errors.Is(err, context.DeadlineExceeded)
+}
+
+// sleepContext waits for d, returning early with the context error if ctx is done
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
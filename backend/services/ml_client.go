@@ -2,17 +2,78 @@ package services
 
 import (
 	"bytes"
+	"compress/gzip"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"mime/multipart"
+	"net"
 	"net/http"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strings"
 	"succulent-identifier-backend/models"
 	"time"
 )
 
+// Transfer modes for sending images to the ML service
+const (
+	// TransferModePath sends the path of the saved image, for ML services sharing the upload volume
+	TransferModePath = "path"
+	// TransferModeUpload sends the image bytes as a multipart upload
+	TransferModeUpload = "upload"
+)
+
+// DefaultMLImageField is the multipart field the image is uploaded in
+const DefaultMLImageField = "image"
+
+// DefaultMLRequestKey is the JSON key of the image path in path mode
+const DefaultMLRequestKey = "image_path"
+
+// compressedImageTypes are image formats already compressed by their encoding,
+// which gzip would only make larger
+var compressedImageTypes = map[string]bool{
+	"image/jpeg": true,
+	"image/png":  true,
+	"image/gif":  true,
+	"image/webp": true,
+}
+
+// MLStatusError is returned when the ML service answers with a non-200 status
+type MLStatusError struct {
+	StatusCode int
+}
+
+func (e *MLStatusError) Error() string {
+	return fmt.Sprintf("ML service returned error: status %d", e.StatusCode)
+}
+
+// IsTransientMLError reports whether an inference failed in a way that may
+// succeed when retried: the service was unreachable or timed out, or answered
+// with a server error, 429 or 408. Bad responses and unreadable images are not.
+func IsTransientMLError(err error) bool {
+	var statusErr *MLStatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.StatusCode >= 500 ||
+			statusErr.StatusCode == http.StatusTooManyRequests ||
+			statusErr.StatusCode == http.StatusRequestTimeout
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
+
 // MLClient handles communication with the ML inference service
 type MLClient struct {
 	baseURL    string
 	httpClient *http.Client
+
+	transferMode     string
+	requestKey       string // JSON key of the image path in path mode
+	imageField       string // multipart field name in upload mode
+	imageContentType string // part content type in upload mode, detected from the image when empty
+	gzipUploads      bool   // gzip upload bodies of images not compressed by their format
 }
 
 // NewMLClient creates a new ML service client
@@ -22,24 +83,72 @@ func NewMLClient(baseURL string) *MLClient {
 		httpClient: &http.Client{
 			Timeout: 30 * time.Second,
 		},
+		transferMode: TransferModePath,
+		requestKey:   DefaultMLRequestKey,
+		imageField:   DefaultMLImageField,
 	}
 }
 
+// SetTransferMode configures whether images are sent by path or uploaded
+func (c *MLClient) SetTransferMode(mode string) error {
+	switch mode {
+	case "", TransferModePath:
+		c.transferMode = TransferModePath
+	case TransferModeUpload:
+		c.transferMode = TransferModeUpload
+	default:
+		return fmt.Errorf("unknown ML transfer mode %q, expected %q or %q", mode, TransferModePath, TransferModeUpload)
+	}
+	return nil
+}
+
+// SetRequestKey configures the JSON key the image path is sent under in path
+// mode, for ML servers expecting e.g. "image" or "path" instead of "image_path"
+func (c *MLClient) SetRequestKey(key string) {
+	if key == "" {
+		key = DefaultMLRequestKey
+	}
+	c.requestKey = key
+}
+
+// SetImageField configures the multipart field name and content type used in
+// upload mode to match the ML server's contract. An empty content type is
+// detected from the image contents.
+func (c *MLClient) SetImageField(field, contentType string) {
+	if field == "" {
+		field = DefaultMLImageField
+	}
+	c.imageField = field
+	c.imageContentType = contentType
+}
+
+// SetUploadCompression enables gzip of the multipart body in upload mode, sent
+// with "Content-Encoding: gzip", for ML servers that accept compressed
+// requests. Images in already compressed formats (JPEG, PNG, GIF, WebP) are
+// sent as is.
+func (c *MLClient) SetUploadCompression(enabled bool) {
+	c.gzipUploads = enabled
+}
+
 // Infer sends an image to the ML service for inference
 func (c *MLClient) Infer(imagePath string) (*models.MLInferenceResponse, error) {
 	// Prepare request
-	reqBody := models.MLInferenceRequest{
-		ImagePath: imagePath,
-	}
-
-	jsonData, err := json.Marshal(reqBody)
+	body, contentType, contentEncoding, err := c.inferRequestBody(imagePath)
 	if err != nil {
-		return nil, fmt.Errorf("failed to marshal request: %w", err)
+		return nil, err
 	}
 
 	// Send request to ML service
 	url := fmt.Sprintf("%s/infer", c.baseURL)
-	resp, err := c.httpClient.Post(url, "application/json", bytes.NewBuffer(jsonData))
+	req, err := http.NewRequest(http.MethodPost, url, body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create ML request: %w", err)
+	}
+	req.Header.Set("Content-Type", contentType)
+	if contentEncoding != "" {
+		req.Header.Set("Content-Encoding", contentEncoding)
+	}
+	resp, err := c.httpClient.Do(req)
 	if err != nil {
 		return nil, fmt.Errorf("failed to call ML service: %w", err)
 	}
@@ -47,7 +156,7 @@ func (c *MLClient) Infer(imagePath string) (*models.MLInferenceResponse, error)
 
 	// Check response status
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("ML service returned error: status %d", resp.StatusCode)
+		return nil, &MLStatusError{StatusCode: resp.StatusCode}
 	}
 
 	// Parse response
@@ -56,6 +165,16 @@ func (c *MLClient) Infer(imagePath string) (*models.MLInferenceResponse, error)
 		return nil, fmt.Errorf("failed to decode ML response: %w", err)
 	}
 
+	// Ensemble responses are reduced to a single ranked list; flat responses pass through
+	if len(mlResponse.Models) > 0 {
+		mlResponse.Predictions = CombineEnsemble(mlResponse.Models)
+	}
+
+	// Detection responses without a flat list are described by their first plant
+	if len(mlResponse.Predictions) == 0 && len(mlResponse.Detections) > 0 {
+		mlResponse.Predictions = mlResponse.Detections[0].Predictions
+	}
+
 	if len(mlResponse.Predictions) == 0 {
 		return nil, fmt.Errorf("ML service returned no predictions")
 	}
@@ -63,6 +182,63 @@ func (c *MLClient) Infer(imagePath string) (*models.MLInferenceResponse, error)
 	return &mlResponse, nil
 }
 
+// inferRequestBody builds the inference request body, its content type and
+// content encoding ("" when not compressed) for the configured transfer mode
+func (c *MLClient) inferRequestBody(imagePath string) (io.Reader, string, string, error) {
+	if c.transferMode != TransferModeUpload {
+		jsonData, err := json.Marshal(map[string]string{c.requestKey: imagePath})
+		if err != nil {
+			return nil, "", "", fmt.Errorf("failed to marshal request: %w", err)
+		}
+		return bytes.NewBuffer(jsonData), "application/json", "", nil
+	}
+
+	image, err := os.ReadFile(imagePath)
+	if err != nil {
+		return nil, "", "", fmt.Errorf("failed to read image: %w", err)
+	}
+
+	detectedType := http.DetectContentType(image)
+	contentType := c.imageContentType
+	if contentType == "" {
+		contentType = detectedType
+	}
+
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	header := make(textproto.MIMEHeader)
+	header.Set("Content-Disposition", fmt.Sprintf(`form-data; name="%s"; filename="%s"`,
+		escapeQuotes(c.imageField), escapeQuotes(filepath.Base(imagePath))))
+	header.Set("Content-Type", contentType)
+	part, err := writer.CreatePart(header)
+	if err != nil {
+		return nil, "", "", fmt.Errorf("failed to create image part: %w", err)
+	}
+	part.Write(image)
+	if err := writer.Close(); err != nil {
+		return nil, "", "", fmt.Errorf("failed to build upload: %w", err)
+	}
+
+	if !c.gzipUploads || compressedImageTypes[detectedType] {
+		return body, writer.FormDataContentType(), "", nil
+	}
+
+	compressed := &bytes.Buffer{}
+	gz := gzip.NewWriter(compressed)
+	if _, err := gz.Write(body.Bytes()); err != nil {
+		return nil, "", "", fmt.Errorf("failed to compress upload: %w", err)
+	}
+	if err := gz.Close(); err != nil {
+		return nil, "", "", fmt.Errorf("failed to compress upload: %w", err)
+	}
+	return compressed, writer.FormDataContentType(), "gzip", nil
+}
+
+// escapeQuotes escapes a Content-Disposition parameter value, as mime/multipart does
+func escapeQuotes(s string) string {
+	return strings.NewReplacer("\\", "\\\\", `"`, "\\\"").Replace(s)
+}
+
 // HealthCheck checks if the ML service is available
 func (c *MLClient) HealthCheck() error {
 	url := fmt.Sprintf("%s/health", c.baseURL)
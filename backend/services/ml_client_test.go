@@ -1,9 +1,16 @@
 package services
 
 import (
+	"compress/gzip"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"succulent-identifier-backend/models"
 	"testing"
 )
@@ -195,3 +202,323 @@ func TestInferServerDown(t *testing.T) {
 		t.Error("Infer() expected error when server is down, got nil")
 	}
 }
+
+func TestIsTransientMLError(t *testing.T) {
+	_, downErr := NewMLClient("http://127.0.0.1:1").Infer("/test/image.jpg")
+
+	tests := []struct {
+		name     string
+		err      error
+		expected bool
+	}{
+		{name: "Service unreachable", err: downErr, expected: true},
+		{name: "Server error", err: &MLStatusError{StatusCode: http.StatusBadGateway}, expected: true},
+		{name: "Rate limited", err: fmt.Errorf("wrapped: %w", &MLStatusError{StatusCode: http.StatusTooManyRequests}), expected: true},
+		{name: "Bad request", err: &MLStatusError{StatusCode: http.StatusBadRequest}, expected: false},
+		{name: "No predictions", err: errors.New("ML service returned no predictions"), expected: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsTransientMLError(tt.err); got != tt.expected {
+				t.Errorf("IsTransientMLError(%v) = %v, expected %v", tt.err, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestInferEnsembleResponse(t *testing.T) {
+	// The primary model alone would pick echeveria_perle; the weighted vote picks elegans
+	body := `{
+		"predictions": [{"label": "echeveria_perle", "confidence": 0.6}],
+		"models": [
+			{"model": "vit", "weight": 1, "predictions": [
+				{"label": "echeveria_perle", "confidence": 0.6},
+				{"label": "echeveria_elegans", "confidence": 0.4}
+			]},
+			{"model": "convnext", "weight": 2, "predictions": [
+				{"label": "echeveria_elegans", "confidence": 0.9},
+				{"label": "graptopetalum_paraguayense", "confidence": 0.1}
+			]}
+		]
+	}`
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(body))
+	}))
+	defer server.Close()
+
+	response, err := NewMLClient(server.URL).Infer("/test/image.jpg")
+	if err != nil {
+		t.Fatalf("Infer() unexpected error: %v", err)
+	}
+
+	if len(response.Models) != 2 || response.Models[1].Model != "convnext" {
+		t.Errorf("Infer() models = %+v, expected both ensemble members decoded", response.Models)
+	}
+	if len(response.Predictions) != 3 {
+		t.Fatalf("Infer() returned %d combined predictions, expected 3", len(response.Predictions))
+	}
+
+	// elegans: (1*0.4 + 2*0.9) / 3, perle: 1*0.6 / 3
+	top := response.Predictions[0]
+	if top.Label != "echeveria_elegans" {
+		t.Errorf("Infer() top label = %v, expected echeveria_elegans", top.Label)
+	}
+	if math.Abs(top.Confidence-2.2/3) > 1e-9 {
+		t.Errorf("Infer() top confidence = %v, expected %v", top.Confidence, 2.2/3)
+	}
+	if response.Predictions[1].Label != "echeveria_perle" {
+		t.Errorf("Infer() second label = %v, expected echeveria_perle", response.Predictions[1].Label)
+	}
+}
+
+func TestInferDetectionResponse(t *testing.T) {
+	body := `{
+		"detections": [
+			{"box": {"x": 0, "y": 0, "width": 120, "height": 100}, "predictions": [{"label": "haworthia_zebrina", "confidence": 0.9}]},
+			{"box": {"x": 130, "y": 10, "width": 90, "height": 90}, "predictions": [{"label": "echeveria_elegans", "confidence": 0.8}]}
+		]
+	}`
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(body))
+	}))
+	defer server.Close()
+
+	response, err := NewMLClient(server.URL).Infer("/test/image.jpg")
+	if err != nil {
+		t.Fatalf("Infer() unexpected error: %v", err)
+	}
+
+	if len(response.Detections) != 2 {
+		t.Fatalf("Infer() returned %d detections, expected 2", len(response.Detections))
+	}
+	second := response.Detections[1]
+	if second.Box != (models.BoundingBox{X: 130, Y: 10, Width: 90, Height: 90}) || second.Predictions[0].Label != "echeveria_elegans" {
+		t.Errorf("Infer() second detection = %+v", second)
+	}
+
+	// Single-result callers see the first plant
+	if len(response.Predictions) != 1 || response.Predictions[0].Label != "haworthia_zebrina" {
+		t.Errorf("Infer() predictions = %+v, expected those of the first detection", response.Predictions)
+	}
+}
+
+func TestCombineEnsemble(t *testing.T) {
+	tests := []struct {
+		name          string
+		ensemble      []models.ModelPredictions
+		expectedLabel string
+		expectedConf  float64
+	}{
+		{
+			name: "Unset weights count equally",
+			ensemble: []models.ModelPredictions{
+				{Model: "a", Predictions: []models.MLPrediction{{Label: "aloe_vera", Confidence: 0.8}}},
+				{Model: "b", Predictions: []models.MLPrediction{{Label: "aloe_vera", Confidence: 0.4}}},
+			},
+			expectedLabel: "aloe_vera",
+			expectedConf:  0.6,
+		},
+		{
+			name: "Heavier model wins disagreement",
+			ensemble: []models.ModelPredictions{
+				{Model: "a", Weight: 1, Predictions: []models.MLPrediction{{Label: "aloe_vera", Confidence: 0.9}}},
+				{Model: "b", Weight: 3, Predictions: []models.MLPrediction{{Label: "haworthia_zebrina", Confidence: 0.7}}},
+			},
+			expectedLabel: "haworthia_zebrina",
+			expectedConf:  0.525,
+		},
+		{
+			name: "Ties broken by label",
+			ensemble: []models.ModelPredictions{
+				{Model: "a", Predictions: []models.MLPrediction{{Label: "sedum", Confidence: 0.5}}},
+				{Model: "b", Predictions: []models.MLPrediction{{Label: "aloe", Confidence: 0.5}}},
+			},
+			expectedLabel: "aloe",
+			expectedConf:  0.25,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			combined := CombineEnsemble(tt.ensemble)
+			if len(combined) == 0 {
+				t.Fatal("CombineEnsemble() returned no predictions")
+			}
+			if combined[0].Label != tt.expectedLabel || math.Abs(combined[0].Confidence-tt.expectedConf) > 1e-9 {
+				t.Errorf("CombineEnsemble() top = %+v, expected %s at %v", combined[0], tt.expectedLabel, tt.expectedConf)
+			}
+		})
+	}
+}
+
+func TestInferRequestKey(t *testing.T) {
+	tests := []struct {
+		name        string
+		key         string
+		expectedKey string
+	}{
+		{name: "Default key", expectedKey: "image_path"},
+		{name: "Configured key", key: "path", expectedKey: "path"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				var body map[string]string
+				if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+					t.Errorf("Failed to decode request: %v", err)
+				}
+				if len(body) != 1 || body[tt.expectedKey] != "/uploads/plant.jpg" {
+					t.Errorf("Expected only %q set to the image path, got %v", tt.expectedKey, body)
+				}
+				json.NewEncoder(w).Encode(models.MLInferenceResponse{
+					Predictions: []models.MLPrediction{{Label: "echeveria_elegans", Confidence: 0.9}},
+				})
+			}))
+			defer server.Close()
+
+			client := NewMLClient(server.URL)
+			client.SetRequestKey(tt.key)
+
+			if _, err := client.Infer("/uploads/plant.jpg"); err != nil {
+				t.Fatalf("Infer() error = %v", err)
+			}
+		})
+	}
+}
+
+func TestInferUploadMode(t *testing.T) {
+	imagePath := filepath.Join(t.TempDir(), "plant.jpg")
+	image := []byte("\xff\xd8\xff\xe0 fake jpeg")
+	os.WriteFile(imagePath, image, 0644)
+
+	tests := []struct {
+		name                string
+		field               string
+		contentType         string
+		expectedField       string
+		expectedContentType string
+	}{
+		{
+			name:                "Defaults",
+			expectedField:       "image",
+			expectedContentType: "image/jpeg",
+		},
+		{
+			name:                "Configured field and content type",
+			field:               "file",
+			contentType:         "application/octet-stream",
+			expectedField:       "file",
+			expectedContentType: "application/octet-stream",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if err := r.ParseMultipartForm(1 << 20); err != nil {
+					t.Errorf("Expected multipart request: %v", err)
+					w.WriteHeader(http.StatusBadRequest)
+					return
+				}
+				files := r.MultipartForm.File[tt.expectedField]
+				if len(files) != 1 {
+					t.Errorf("Expected one file in field %q, got form %v", tt.expectedField, r.MultipartForm.File)
+					w.WriteHeader(http.StatusBadRequest)
+					return
+				}
+				if got := files[0].Header.Get("Content-Type"); got != tt.expectedContentType {
+					t.Errorf("Expected part content type %q, got %q", tt.expectedContentType, got)
+				}
+				if files[0].Filename != "plant.jpg" || files[0].Size != int64(len(image)) {
+					t.Errorf("Unexpected uploaded file %q of %d bytes", files[0].Filename, files[0].Size)
+				}
+				json.NewEncoder(w).Encode(models.MLInferenceResponse{
+					Predictions: []models.MLPrediction{{Label: "echeveria_elegans", Confidence: 0.9}},
+				})
+			}))
+			defer server.Close()
+
+			client := NewMLClient(server.URL)
+			if err := client.SetTransferMode(TransferModeUpload); err != nil {
+				t.Fatalf("SetTransferMode() error = %v", err)
+			}
+			client.SetImageField(tt.field, tt.contentType)
+
+			response, err := client.Infer(imagePath)
+			if err != nil {
+				t.Fatalf("Infer() error = %v", err)
+			}
+			if response.Predictions[0].Label != "echeveria_elegans" {
+				t.Errorf("Unexpected predictions: %+v", response.Predictions)
+			}
+		})
+	}
+}
+
+func TestInferUploadCompression(t *testing.T) {
+	dir := t.TempDir()
+	bmpPath := filepath.Join(dir, "plant.bmp")
+	bmp := append([]byte("BM"), make([]byte, 4096)...) // uncompressed bitmap
+	os.WriteFile(bmpPath, bmp, 0644)
+	jpegPath := filepath.Join(dir, "plant.jpg")
+	os.WriteFile(jpegPath, []byte("\xff\xd8\xff\xe0 fake jpeg"), 0644)
+
+	tests := []struct {
+		name             string
+		enabled          bool
+		imagePath        string
+		expectedEncoding string
+	}{
+		{name: "Uncompressed image is gzipped", enabled: true, imagePath: bmpPath, expectedEncoding: "gzip"},
+		{name: "JPEG is sent as is", enabled: true, imagePath: jpegPath},
+		{name: "Disabled", imagePath: bmpPath},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				encoding := r.Header.Get("Content-Encoding")
+				if encoding != tt.expectedEncoding {
+					t.Errorf("Expected Content-Encoding %q, got %q", tt.expectedEncoding, encoding)
+				}
+				if encoding == "gzip" {
+					gz, err := gzip.NewReader(r.Body)
+					if err != nil {
+						t.Fatalf("Expected a gzip body: %v", err)
+					}
+					r.Body = io.NopCloser(gz)
+				}
+				if err := r.ParseMultipartForm(1 << 20); err != nil {
+					t.Errorf("Expected multipart request: %v", err)
+					w.WriteHeader(http.StatusBadRequest)
+					return
+				}
+				if files := r.MultipartForm.File["image"]; len(files) != 1 || files[0].Filename != filepath.Base(tt.imagePath) {
+					t.Errorf("Expected the uploaded image, got form %v", r.MultipartForm.File)
+				}
+				json.NewEncoder(w).Encode(models.MLInferenceResponse{
+					Predictions: []models.MLPrediction{{Label: "echeveria_elegans", Confidence: 0.9}},
+				})
+			}))
+			defer server.Close()
+
+			client := NewMLClient(server.URL)
+			client.SetTransferMode(TransferModeUpload)
+			client.SetUploadCompression(tt.enabled)
+
+			if _, err := client.Infer(tt.imagePath); err != nil {
+				t.Fatalf("Infer() error = %v", err)
+			}
+		})
+	}
+}
+
+func TestSetTransferModeRejectsUnknownMode(t *testing.T) {
+	if err := NewMLClient("http://localhost:8000").SetTransferMode("ftp"); err == nil {
+		t.Error("Expected unknown transfer mode to be rejected")
+	}
+}
@@ -0,0 +1,83 @@
+package services
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+)
+
+// HealthChecker reports whether a dependency is reachable
+type HealthChecker interface {
+	HealthCheck() error
+}
+
+// MLHealthStatus reports the result of the most recent ML service health check
+type MLHealthStatus struct {
+	Healthy   bool
+	CheckedAt time.Time // zero until the first check
+	LastError error     // nil when the last check succeeded
+}
+
+// MLHealthMonitor tracks ML service health with periodic background checks so
+// requests can fail fast while the service is down. Until the first check
+// completes the service is assumed healthy.
+type MLHealthMonitor struct {
+	checker HealthChecker
+
+	mu     sync.RWMutex
+	status MLHealthStatus
+}
+
+// NewMLHealthMonitor creates a new ML health monitor
+func NewMLHealthMonitor(checker HealthChecker) *MLHealthMonitor {
+	return &MLHealthMonitor{
+		checker: checker,
+		status:  MLHealthStatus{Healthy: true},
+	}
+}
+
+// Check runs one health check, records the result and returns its error
+func (m *MLHealthMonitor) Check() error {
+	err := m.checker.HealthCheck()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err != nil && m.status.Healthy {
+		log.Printf("Warning: ML service became unhealthy: %v", err)
+	} else if err == nil && !m.status.Healthy {
+		log.Println("ML service recovered")
+	}
+
+	m.status = MLHealthStatus{
+		Healthy:   err == nil,
+		CheckedAt: time.Now().UTC(),
+		LastError: err,
+	}
+	return err
+}
+
+// Status returns the result of the most recent health check
+func (m *MLHealthMonitor) Status() MLHealthStatus {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.status
+}
+
+// Start runs Check every interval until ctx is cancelled
+func (m *MLHealthMonitor) Start(ctx context.Context, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				m.Check()
+			}
+		}
+	}()
+}
@@ -0,0 +1,40 @@
+package services
+
+import (
+	"errors"
+	"testing"
+)
+
+type mockHealthChecker struct {
+	err error
+}
+
+func (m *mockHealthChecker) HealthCheck() error {
+	return m.err
+}
+
+func TestMLHealthMonitorCheck(t *testing.T) {
+	checker := &mockHealthChecker{}
+	monitor := NewMLHealthMonitor(checker)
+
+	if status := monitor.Status(); !status.Healthy || !status.CheckedAt.IsZero() {
+		t.Errorf("Expected healthy before the first check, got %+v", status)
+	}
+
+	checker.err = errors.New("connection refused")
+	if err := monitor.Check(); err == nil {
+		t.Error("Check() expected error")
+	}
+	status := monitor.Status()
+	if status.Healthy || status.LastError == nil || status.CheckedAt.IsZero() {
+		t.Errorf("Expected unhealthy status with error, got %+v", status)
+	}
+
+	checker.err = nil
+	if err := monitor.Check(); err != nil {
+		t.Errorf("Check() unexpected error: %v", err)
+	}
+	if status := monitor.Status(); !status.Healthy || status.LastError != nil {
+		t.Errorf("Expected recovered status, got %+v", status)
+	}
+}
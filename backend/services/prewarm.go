@@ -0,0 +1,181 @@
+package services
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"succulent-identifier-backend/db"
+	"succulent-identifier-backend/utils"
+)
+
+// CareGenerator generates care instructions with the LLM
+type CareGenerator interface {
+	GenerateCareInstructions(ctx context.Context, genus, species string) (*db.CareGuide, error)
+}
+
+// CareCache stores generated care instructions by genus and species
+type CareCache interface {
+	GetBySpecies(genus, species string) (*db.CareInstructionsCache, error)
+	Create(cache *db.CareInstructionsCache) error
+}
+
+// CareKeySource lists the species and genus keys that have curated care data
+type CareKeySource interface {
+	Keys() []string
+}
+
+// PrewarmResult summarizes a care pre-warming run
+type PrewarmResult struct {
+	Generated int
+	Cached    int // already in the cache, skipped
+	Failed    int
+}
+
+// prewarmJob is a plant whose care is missing from the cache
+type prewarmJob struct {
+	genus   string
+	species string
+}
+
+// CarePrewarmer populates the care cache for every plant in the care data so
+// the first users of a new deployment do not wait on LLM generation
+type CarePrewarmer struct {
+	generator      CareGenerator
+	cache          CareCache
+	keys           CareKeySource
+	labelDelimiter string
+	concurrency    int
+	interval       time.Duration // minimum time between generation starts (0 disables rate limiting)
+}
+
+// NewCarePrewarmer creates a new care prewarmer with the given worker count and
+// generation rate in generations per second (0 or less means unlimited)
+func NewCarePrewarmer(generator CareGenerator, cache CareCache, keys CareKeySource, concurrency int, ratePerSecond float64) *CarePrewarmer {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	var interval time.Duration
+	if ratePerSecond > 0 {
+		interval = time.Duration(float64(time.Second) / ratePerSecond)
+	}
+
+	return &CarePrewarmer{
+		generator:      generator,
+		cache:          cache,
+		keys:           keys,
+		labelDelimiter: utils.DefaultLabelDelimiter,
+		concurrency:    concurrency,
+		interval:       interval,
+	}
+}
+
+// SetLabelDelimiter configures the separator between genus and species in care data keys
+func (p *CarePrewarmer) SetLabelDelimiter(delimiter string) {
+	if delimiter == "" {
+		delimiter = utils.DefaultLabelDelimiter
+	}
+	p.labelDelimiter = delimiter
+}
+
+// Run generates and caches care for each care data key missing from the cache.
+// Generation failures are counted and logged without stopping the run.
+func (p *CarePrewarmer) Run(ctx context.Context) PrewarmResult {
+	var result PrewarmResult
+	var mu sync.Mutex
+	count := func(field *int) {
+		mu.Lock()
+		*field++
+		mu.Unlock()
+	}
+
+	jobs := make(chan prewarmJob)
+	var wg sync.WaitGroup
+	for i := 0; i < p.concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for job := range jobs {
+				if p.generate(ctx, job.genus, job.species) {
+					count(&result.Generated)
+				} else {
+					count(&result.Failed)
+				}
+			}
+		}()
+	}
+
+	var limiter <-chan time.Time
+	if p.interval > 0 {
+		ticker := time.NewTicker(p.interval)
+		defer ticker.Stop()
+		limiter = ticker.C
+	}
+
+	first := true
+dispatch:
+	for _, key := range p.keys.Keys() {
+		genus, species := utils.ParseLabel(key, p.labelDelimiter)
+
+		cacheGenus, cacheSpecies := utils.CareCacheKey(genus, species, p.labelDelimiter)
+		if cached, err := p.cache.GetBySpecies(cacheGenus, cacheSpecies); err != nil {
+			log.Printf("Error checking care cache for %s: %v", key, err)
+		} else if cached != nil {
+			count(&result.Cached)
+			continue
+		}
+
+		// The first generation starts immediately, later ones wait for the limiter
+		if limiter != nil && !first {
+			select {
+			case <-ctx.Done():
+				break dispatch
+			case <-limiter:
+			}
+		}
+		first = false
+
+		select {
+		case <-ctx.Done():
+			break dispatch
+		case jobs <- prewarmJob{genus: genus, species: species}:
+		}
+	}
+	close(jobs)
+	wg.Wait()
+
+	return result
+}
+
+// generate creates care for one plant and stores it in the cache, reporting success
+func (p *CarePrewarmer) generate(ctx context.Context, genus, species string) bool {
+	genCtx, cancel := context.WithTimeout(ctx, 60*time.Second)
+	defer cancel()
+
+	careGuide, err := p.generator.GenerateCareInstructions(genCtx, genus, species)
+	if err != nil {
+		log.Printf("Failed to pre-generate care for %s %s: %v", genus, species, err)
+		return false
+	}
+
+	now := time.Now().UTC()
+	cacheGenus, cacheSpecies := utils.CareCacheKey(genus, species, p.labelDelimiter)
+	cacheEntry := &db.CareInstructionsCache{
+		ID:        uuid.New().String(),
+		Genus:     cacheGenus,
+		Species:   cacheSpecies,
+		CareGuide: careGuide,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if err := p.cache.Create(cacheEntry); err != nil {
+		log.Printf("Failed to cache pre-generated care for %s %s: %v", genus, species, err)
+		return false
+	}
+
+	log.Printf("Pre-generated care for %s %s", genus, species)
+	return true
+}
@@ -0,0 +1,134 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"succulent-identifier-backend/db"
+)
+
+type mockCareGenerator struct {
+	mu        sync.Mutex
+	generated []string
+	fail      string // genus/species key whose generation errors
+	active    int32
+	maxActive int32
+}
+
+func (m *mockCareGenerator) GenerateCareInstructions(ctx context.Context, genus, species string) (*db.CareGuide, error) {
+	active := atomic.AddInt32(&m.active, 1)
+	defer atomic.AddInt32(&m.active, -1)
+	for {
+		peak := atomic.LoadInt32(&m.maxActive)
+		if active <= peak || atomic.CompareAndSwapInt32(&m.maxActive, peak, active) {
+			break
+		}
+	}
+	time.Sleep(5 * time.Millisecond)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.generated = append(m.generated, genus+"/"+species)
+	if m.fail != "" && genus+"/"+species == m.fail {
+		return nil, errors.New("LLM error")
+	}
+	return &db.CareGuide{Sunlight: "Bright light for " + genus}, nil
+}
+
+type mockCareCache struct {
+	mu      sync.Mutex
+	entries map[string]*db.CareInstructionsCache
+}
+
+func (m *mockCareCache) GetBySpecies(genus, species string) (*db.CareInstructionsCache, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.entries[genus+"/"+species], nil
+}
+
+func (m *mockCareCache) Create(cache *db.CareInstructionsCache) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.entries[cache.Genus+"/"+cache.Species] = cache
+	return nil
+}
+
+type staticKeys []string
+
+func (k staticKeys) Keys() []string {
+	return k
+}
+
+func TestCarePrewarmerRun(t *testing.T) {
+	generator := &mockCareGenerator{fail: "haworthia/haworthia_broken"}
+	cache := &mockCareCache{entries: map[string]*db.CareInstructionsCache{
+		"aloe/vera": {Genus: "aloe", Species: "vera"},
+	}}
+	keys := staticKeys{"echeveria", "echeveria_elegans", "aloe_vera", "haworthia_broken", "sedum", "crassula_ovata"}
+
+	prewarmer := NewCarePrewarmer(generator, cache, keys, 2, 0)
+	result := prewarmer.Run(context.Background())
+
+	if result.Generated != 4 || result.Cached != 1 || result.Failed != 1 {
+		t.Errorf("Result = %+v, expected 4 generated, 1 cached, 1 failed", result)
+	}
+	if len(generator.generated) != 5 {
+		t.Errorf("Expected 5 generations, got %d: %v", len(generator.generated), generator.generated)
+	}
+	if generator.maxActive > 2 {
+		t.Errorf("Expected at most 2 concurrent generations, got %d", generator.maxActive)
+	}
+
+	// Keys are cached under the canonical (genus, epithet) form identify also uses
+	for _, key := range []string{"echeveria/", "echeveria/elegans", "sedum/", "crassula/ovata"} {
+		if cache.entries[key] == nil || cache.entries[key].CareGuide == nil {
+			t.Errorf("Expected cache entry for %s", key)
+		}
+	}
+	if cache.entries["haworthia/broken"] != nil {
+		t.Error("Expected no cache entry for failed generation")
+	}
+
+	// A second run finds everything but the failure cached
+	generator.generated = nil
+	result = prewarmer.Run(context.Background())
+	if result.Generated != 0 || result.Cached != 5 || result.Failed != 1 {
+		t.Errorf("Second run result = %+v, expected 0 generated, 5 cached, 1 failed", result)
+	}
+}
+
+func TestCarePrewarmerRateLimit(t *testing.T) {
+	generator := &mockCareGenerator{}
+	cache := &mockCareCache{entries: map[string]*db.CareInstructionsCache{}}
+	keys := staticKeys{"aloe", "echeveria", "sedum"}
+
+	// 50 per second: three generations need at least two 20ms gaps
+	prewarmer := NewCarePrewarmer(generator, cache, keys, 3, 50)
+	start := time.Now()
+	result := prewarmer.Run(context.Background())
+
+	if result.Generated != 3 {
+		t.Errorf("Expected 3 generated, got %d", result.Generated)
+	}
+	if elapsed := time.Since(start); elapsed < 40*time.Millisecond {
+		t.Errorf("Expected rate limiting to take at least 40ms, took %v", elapsed)
+	}
+}
+
+func TestCarePrewarmerStopsOnCancel(t *testing.T) {
+	generator := &mockCareGenerator{}
+	cache := &mockCareCache{entries: map[string]*db.CareInstructionsCache{}}
+	keys := staticKeys{"aloe", "echeveria", "sedum"}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	result := NewCarePrewarmer(generator, cache, keys, 1, 0).Run(ctx)
+	if result.Generated != 0 {
+		t.Errorf("Expected no generations after cancel, got %d", result.Generated)
+	}
+}
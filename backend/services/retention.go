@@ -0,0 +1,87 @@
+package services
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// ExpiringIdentificationRepository soft deletes identifications past their TTL
+type ExpiringIdentificationRepository interface {
+	DeleteOlderThan(cutoff time.Time) ([]string, error)
+}
+
+// ImageDeleter removes uploaded image files
+type ImageDeleter interface {
+	DeleteFile(path string) error
+}
+
+// RetentionService soft deletes identifications older than a TTL and removes
+// their uploaded images. A zero TTL disables it.
+type RetentionService struct {
+	repo   ExpiringIdentificationRepository
+	images ImageDeleter
+	ttl    time.Duration
+}
+
+// NewRetentionService creates a new retention service
+func NewRetentionService(repo ExpiringIdentificationRepository, images ImageDeleter, ttl time.Duration) *RetentionService {
+	return &RetentionService{
+		repo:   repo,
+		images: images,
+		ttl:    ttl,
+	}
+}
+
+// Enabled reports whether identifications expire
+func (s *RetentionService) Enabled() bool {
+	return s.ttl > 0
+}
+
+// RunOnce archives identifications created more than the TTL before now and
+// returns how many were archived. Image removal failures are only logged.
+func (s *RetentionService) RunOnce(now time.Time) (int, error) {
+	if !s.Enabled() {
+		return 0, nil
+	}
+
+	imagePaths, err := s.repo.DeleteOlderThan(now.Add(-s.ttl))
+	if err != nil {
+		return 0, err
+	}
+
+	for _, imagePath := range imagePaths {
+		if err := s.images.DeleteFile(imagePath); err != nil {
+			log.Printf("Failed to remove image of expired identification: %v", err)
+		}
+	}
+
+	return len(imagePaths), nil
+}
+
+// Start runs RunOnce immediately and then every interval until ctx is cancelled.
+// It does nothing when retention is disabled.
+func (s *RetentionService) Start(ctx context.Context, interval time.Duration) {
+	if !s.Enabled() {
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			if archived, err := s.RunOnce(time.Now().UTC()); err != nil {
+				log.Printf("Failed to archive expired identifications: %v", err)
+			} else if archived > 0 {
+				log.Printf("Archived %d expired identifications", archived)
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
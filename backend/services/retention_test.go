@@ -0,0 +1,109 @@
+package services
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+type mockExpiringRepository struct {
+	called     bool
+	cutoff     time.Time
+	imagePaths []string
+	err        error
+}
+
+func (m *mockExpiringRepository) DeleteOlderThan(cutoff time.Time) ([]string, error) {
+	m.called = true
+	m.cutoff = cutoff
+	return m.imagePaths, m.err
+}
+
+type mockImageDeleter struct {
+	deleted []string
+	err     error
+}
+
+func (m *mockImageDeleter) DeleteFile(path string) error {
+	m.deleted = append(m.deleted, path)
+	return m.err
+}
+
+func TestRetentionServiceRunOnce(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name             string
+		ttl              time.Duration
+		repo             *mockExpiringRepository
+		images           *mockImageDeleter
+		expectError      bool
+		expectRepoCalled bool
+		expectedArchived int
+	}{
+		{
+			name:             "Disabled by default",
+			ttl:              0,
+			repo:             &mockExpiringRepository{imagePaths: []string{"/uploads/1.jpg"}},
+			images:           &mockImageDeleter{},
+			expectRepoCalled: false,
+			expectedArchived: 0,
+		},
+		{
+			name:             "Expired identifications archived and images removed",
+			ttl:              30 * 24 * time.Hour,
+			repo:             &mockExpiringRepository{imagePaths: []string{"/uploads/1.jpg", "/uploads/2.jpg"}},
+			images:           &mockImageDeleter{},
+			expectRepoCalled: true,
+			expectedArchived: 2,
+		},
+		{
+			name:             "Image removal failure does not fail the run",
+			ttl:              24 * time.Hour,
+			repo:             &mockExpiringRepository{imagePaths: []string{"/uploads/gone.jpg"}},
+			images:           &mockImageDeleter{err: errors.New("no such file")},
+			expectRepoCalled: true,
+			expectedArchived: 1,
+		},
+		{
+			name:             "Repository error",
+			ttl:              24 * time.Hour,
+			repo:             &mockExpiringRepository{err: errors.New("database error")},
+			images:           &mockImageDeleter{},
+			expectError:      true,
+			expectRepoCalled: true,
+			expectedArchived: 0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service := NewRetentionService(tt.repo, tt.images, tt.ttl)
+
+			archived, err := service.RunOnce(now)
+
+			if tt.expectError && err == nil {
+				t.Error("Expected error but got none")
+			}
+			if !tt.expectError && err != nil {
+				t.Errorf("Unexpected error: %v", err)
+			}
+			if tt.repo.called != tt.expectRepoCalled {
+				t.Errorf("Repository called = %v, expected %v", tt.repo.called, tt.expectRepoCalled)
+			}
+			if archived != tt.expectedArchived {
+				t.Errorf("Expected %d archived, got %d", tt.expectedArchived, archived)
+			}
+
+			if !tt.expectRepoCalled {
+				return
+			}
+			if expected := now.Add(-tt.ttl); !tt.repo.cutoff.Equal(expected) {
+				t.Errorf("Cutoff = %v, expected %v", tt.repo.cutoff, expected)
+			}
+			if !tt.expectError && len(tt.images.deleted) != len(tt.repo.imagePaths) {
+				t.Errorf("Expected %d images removed, got %d", len(tt.repo.imagePaths), len(tt.images.deleted))
+			}
+		})
+	}
+}
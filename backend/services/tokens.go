@@ -0,0 +1,30 @@
+package services
+
+import (
+	"unicode/utf8"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+// Token estimation heuristics for OpenAI chat models: roughly four characters
+// per token, plus a fixed overhead for the role and separators of each message.
+const (
+	charsPerToken             = 4
+	tokensPerMessage          = 4
+	defaultContextTokenBudget = 8000
+)
+
+// EstimateTokens returns an approximate token count for a piece of text
+func EstimateTokens(text string) int {
+	chars := utf8.RuneCountInString(text)
+	return (chars + charsPerToken - 1) / charsPerToken
+}
+
+// estimateMessagesTokens returns the approximate prompt size of a chat completion request
+func estimateMessagesTokens(messages []openai.ChatCompletionMessage) int {
+	total := 0
+	for _, msg := range messages {
+		total += tokensPerMessage + EstimateTokens(msg.Content)
+	}
+	return total
+}
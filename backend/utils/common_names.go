@@ -0,0 +1,81 @@
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"unicode"
+)
+
+// CommonNames resolves plant common names ("hens and chicks") to the ML
+// labels they may refer to. One common name can cover several labels.
+type CommonNames struct {
+	labels map[string][]string // normalized common name -> sorted labels
+}
+
+// NewCommonNames creates a resolver from a label -> common names map
+func NewCommonNames(namesByLabel map[string][]string) *CommonNames {
+	labels := map[string][]string{}
+	for label, names := range namesByLabel {
+		for _, name := range names {
+			key := NormalizeCommonName(name)
+			if key == "" || slices.Contains(labels[key], label) {
+				continue
+			}
+			labels[key] = append(labels[key], label)
+		}
+	}
+	for _, list := range labels {
+		slices.Sort(list)
+	}
+	return &CommonNames{labels: labels}
+}
+
+// LoadCommonNames reads a JSON file mapping each ML label to its common names
+func LoadCommonNames(path string) (*CommonNames, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read common names file: %w", err)
+	}
+
+	var namesByLabel map[string][]string
+	if err := json.Unmarshal(data, &namesByLabel); err != nil {
+		return nil, fmt.Errorf("failed to parse common names JSON: %w", err)
+	}
+	return NewCommonNames(namesByLabel), nil
+}
+
+// Lookup returns the labels a common name may refer to, sorted, or nil if it is unknown
+func (c *CommonNames) Lookup(name string) []string {
+	return slices.Clone(c.labels[NormalizeCommonName(name)])
+}
+
+// Len returns the number of distinct common names
+func (c *CommonNames) Len() int {
+	return len(c.labels)
+}
+
+// NormalizeCommonName lowercases a common name, drops apostrophes and turns
+// any other run of punctuation or whitespace into a single space, so
+// "Burro's-Tail" and "burros tail" match
+func NormalizeCommonName(name string) string {
+	var b strings.Builder
+	space := false
+	for _, r := range strings.ToLower(name) {
+		switch {
+		case r == '\'' || r == '’':
+			continue
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			if space && b.Len() > 0 {
+				b.WriteRune(' ')
+			}
+			b.WriteRune(r)
+			space = false
+		default:
+			space = true
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,57 @@
+package utils
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestNormalizeCommonName(t *testing.T) {
+	tests := map[string]string{
+		"Hens and Chicks":   "hens and chicks",
+		"  burro's   tail ": "burros tail",
+		"Burro’s-Tail":      "burros tail",
+		"narrow-leaf chalk": "narrow leaf chalk",
+		"!!!":               "",
+	}
+	for input, expected := range tests {
+		if got := NormalizeCommonName(input); got != expected {
+			t.Errorf("NormalizeCommonName(%q) = %q, expected %q", input, got, expected)
+		}
+	}
+}
+
+func TestLoadCommonNames(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "common_names.json")
+	data := `{
+		"echeveria_perle_von_nurnberg": ["hens and chicks"],
+		"echeveria_minima": ["Hens and Chicks", "miniature echeveria"],
+		"sedum_morganianum_burrito": ["burro's tail"]
+	}`
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatalf("Failed to write common names: %v", err)
+	}
+
+	commonNames, err := LoadCommonNames(path)
+	if err != nil {
+		t.Fatalf("LoadCommonNames() unexpected error: %v", err)
+	}
+
+	if got := commonNames.Lookup("Hens-and-Chicks"); !slices.Equal(got, []string{"echeveria_minima", "echeveria_perle_von_nurnberg"}) {
+		t.Errorf("Lookup(ambiguous) = %v, expected both echeverias sorted", got)
+	}
+	if got := commonNames.Lookup("Burros Tail"); !slices.Equal(got, []string{"sedum_morganianum_burrito"}) {
+		t.Errorf("Lookup(unique) = %v", got)
+	}
+	if got := commonNames.Lookup("snake plant"); got != nil {
+		t.Errorf("Lookup(unknown) = %v, expected nil", got)
+	}
+	if commonNames.Len() != 3 {
+		t.Errorf("Len() = %d, expected 3", commonNames.Len())
+	}
+
+	if _, err := LoadCommonNames(filepath.Join(t.TempDir(), "missing.json")); err == nil {
+		t.Error("LoadCommonNames() expected error for a missing file")
+	}
+}
@@ -3,46 +3,327 @@ package utils
 import (
 	"os"
 	"strconv"
+	"strings"
+	"time"
 )
 
+// DefaultCarePromptVersion is the version of the current care generation
+// prompt; bump it whenever the prompt changes
+const DefaultCarePromptVersion = 2
+
 // Config holds application configuration
 type Config struct {
 	// Server configuration
 	ServerPort string
 
+	// How long shutdown waits for in-flight requests and background care generations
+	ShutdownTimeout time.Duration
+
 	// ML Service configuration
 	MLServiceURL string
 
+	// How images reach the ML service: "path" (shared volume) or "upload" (multipart),
+	// the JSON key of the path in path mode, and the multipart field name and part
+	// content type used in upload mode
+	MLTransferMode     string
+	MLRequestKey       string
+	MLImageField       string
+	MLImageContentType string // detected from the image when empty
+	MLUploadGzip       bool   // gzip upload bodies of images not compressed by their format
+
 	// File upload configuration
 	UploadDir         string
 	MaxFileSize       int64 // in bytes
 	AllowedExtensions []string
+	UploadNaming      string // "uuid" or "original"
+
+	// Uploads a single client IP can have in flight at once (0 disables)
+	MaxUploadsPerIP int
+
+	// Save an optimized JPEG copy of each upload, at most OptimizedImageMaxSize
+	// pixels on its longest side, and serve it to the history UI
+	OptimizedImagesEnabled bool
+	OptimizedImageMaxSize  int
+
+	// Serve uploads publicly at /uploads/; when false images are only served
+	// through GET /history/{id}/image
+	PublicUploadsEnabled bool
+
+	// Scan saved uploads for malware with clamd, or with UploadScanCommand when set.
+	// Infected files are moved to UploadQuarantineDir, or deleted when it is empty.
+	UploadScanEnabled      bool
+	UploadScanClamdAddress string
+	UploadScanCommand      string
+	UploadScanTimeout      time.Duration
+	UploadQuarantineDir    string
 
 	// Confidence threshold
 	SpeciesThreshold float64
 
+	// Top confidence below which results are reported as uncertain (0 disables)
+	ConfidenceFloor float64
+
+	// Runner-up predictions returned by default with an identification, and
+	// the most a client can request with ?alternatives=N
+	IdentifyAlternatives    int
+	MaxIdentifyAlternatives int
+
+	// Separator between genus and species in ML labels (e.g. "_", "-" or " ")
+	LabelDelimiter string
+
+	// Single-token labels that name a full species, not a genus ("*" for all)
+	SingleTokenSpecies []string
+
+	// Retry inference once when the ML service fails transiently (5xx, timeouts)
+	IdentifyRetryTransient bool
+
+	// Re-identifications giving the same species with a confidence within the
+	// tolerance update the existing record instead of creating a new one
+	ReidentifyDedupe    bool
+	ReidentifyTolerance float64
+
+	// The same client identifying the same species within this window gets
+	// its earlier record instead of a new one (0 disables)
+	DedupWindow time.Duration
+
+	// Max perceptual hash distance for near-duplicate upload warnings (0 disables)
+	SimilarImageDistance int
+
+	// Persist identification attempts whose inference failed to failed_identifications
+	RecordFailures bool
+
+	// Store the step durations of identify requests, listed at GET /admin/timings
+	RecordTimings bool
+
 	// Care data path
 	CareDataPath string
 
+	// Common names JSON (label -> common names) for GET /care/by-common-name
+	CommonNamesPath string
+
+	// Remote care data URL, used instead of CareDataPath when set, and how often it is re-fetched
+	CareDataURL             string
+	CareDataRefreshInterval time.Duration
+
+	// Species care data entries inherit the fields they leave empty from their genus
+	CareDataMergeSpecies bool
+
+	// Genera that always use curated static care data, never the LLM cache
+	PinnedCareGenera []string
+
+	// Genera or species labels never reported as an identification
+	BlockedLabels []string
+
+	// Label glob patterns whose care is never generated, only static or generic
+	StaticCareLabelPatterns []string
+
+	// Order care is resolved from "static", "cache" and "llm"; sources after
+	// "llm" are its fallback when generation fails
+	CareSourceOrder []string
+
+	// Max cached LLM care entries before LRU eviction (0 means unlimited)
+	CareCacheMaxEntries int
+
+	// Version of the care generation prompt; bumping it regenerates non-verified
+	// cached care generated with an older version
+	CarePromptVersion int
+
+	// Return identifications immediately and generate care in the background
+	AsyncCareGeneration bool
+
+	// URL POSTed the care of an identification once generated in the background (empty disables)
+	CareWebhookURL string
+
+	// Parallel care generations per batch identify request
+	CareGenerationConcurrency int
+
+	// Retries for transiently failing LLM care generations (5xx, timeouts)
+	CareGenerationRetries int
+
+	// Most identified other species of a genus whose care is generated in the
+	// background on identification (0 disables), optionally only these genera
+	SiblingCareWarmCount  int
+	SiblingCareWarmGenera []string
+
+	// Minimum time between care regenerations of the same species (0 disables)
+	CareRegenerateCooldown time.Duration
+
+	// Auto soft-delete identifications older than this many days (0 disables)
+	IdentificationTTLDays int
+
+	// Share links: HMAC secret for signing tokens and how long links stay valid
+	ShareSecret   string
+	ShareTokenTTL time.Duration
+
+	// Bearer token for the /admin endpoints, which are disabled when empty
+	AdminToken string
+
+	// Recent server errors kept in memory for GET /admin/errors (0 disables)
+	ErrorLogSize int
+
+	// How long GET /ready serves a cached report before probing dependencies again
+	ReadinessCacheTTL time.Duration
+
+	// Comma-separated IPs/CIDRs of reverse proxies whose X-Forwarded-* headers are trusted
+	TrustedProxies string
+
+	// Base URL for absolute image URLs in history responses ("auto" uses the request host, empty returns filenames)
+	PublicBaseURL string
+
+	// URL prefix all routes are served under, e.g. "/api/succulent" (empty serves them at the root)
+	BasePath string
+
+	// Decimals confidence is rounded to in history exports (negative keeps full precision)
+	ExportConfidencePrecision int
+
 	// OpenAI configuration
 	OpenAIAPIKey string
+
+	// Estimated prompt tokens that chat history is trimmed to fit
+	ChatContextTokenBudget int
+
+	// Allow chat requests without an identification_id (general succulent advice)
+	ChatAllowContextless bool
+
+	// Max characters of a user chat message (0 disables)
+	MaxUserMessageChars int
+
+	// Models POST /chat/compare may query
+	CompareModels []string
+
+	// Reply in the language detected in the user's chat message
+	ChatLanguageDetection bool
+
+	// Report which care fields a chat reply drew on in care_references
+	ChatCareReferences bool
+
+	// Optional features toggled by FEATURE_* environment variables
+	Features FeatureFlags
 }
 
 // LoadConfig loads configuration from environment variables
 func LoadConfig() *Config {
 	maxFileSize, _ := strconv.ParseInt(getEnv("MAX_FILE_SIZE", "5242880"), 10, 64) // Default 5MB
 	speciesThreshold, _ := strconv.ParseFloat(getEnv("SPECIES_THRESHOLD", "0.4"), 64)
+	confidenceFloor, _ := strconv.ParseFloat(getEnv("CONFIDENCE_FLOOR", "0.1"), 64)
+	reidentifyTolerance, _ := strconv.ParseFloat(getEnv("REIDENTIFY_CONFIDENCE_TOLERANCE", "0.01"), 64)
+	identifyAlternatives, _ := strconv.Atoi(getEnv("IDENTIFY_ALTERNATIVES", "2"))
+	maxIdentifyAlternatives, _ := strconv.Atoi(getEnv("MAX_IDENTIFY_ALTERNATIVES", "5"))
+	similarImageDistance, _ := strconv.Atoi(getEnv("SIMILAR_IMAGE_DISTANCE", "10"))
+	careCacheMaxEntries, _ := strconv.Atoi(getEnv("CARE_CACHE_MAX_ENTRIES", "0"))
+	carePromptVersion, _ := strconv.Atoi(getEnv("CARE_PROMPT_VERSION", strconv.Itoa(DefaultCarePromptVersion)))
+	careGenerationConcurrency, _ := strconv.Atoi(getEnv("CARE_GENERATION_CONCURRENCY", "4"))
+	careGenerationRetries, _ := strconv.Atoi(getEnv("CARE_GENERATION_RETRIES", "2"))
+	siblingCareWarmCount, _ := strconv.Atoi(getEnv("SIBLING_CARE_WARM_COUNT", "0"))
+	careRegenerateCooldownMinutes, _ := strconv.Atoi(getEnv("CARE_REGENERATE_COOLDOWN_MINUTES", "60"))
+	chatContextTokenBudget, _ := strconv.Atoi(getEnv("CHAT_CONTEXT_TOKEN_BUDGET", "8000"))
+	maxUserMessageChars, _ := strconv.Atoi(getEnv("MAX_USER_MESSAGE_CHARS", "2000"))
+	identificationTTLDays, _ := strconv.Atoi(getEnv("IDENTIFICATION_TTL_DAYS", "0"))
+	careDataRefreshMinutes, _ := strconv.Atoi(getEnv("CARE_DATA_REFRESH_MINUTES", "60"))
+	shareTokenTTLHours, _ := strconv.Atoi(getEnv("SHARE_TOKEN_TTL_HOURS", "168")) // Default 7 days
+	exportConfidencePrecision, _ := strconv.Atoi(getEnv("EXPORT_CONFIDENCE_PRECISION", "4"))
+	errorLogSize, _ := strconv.Atoi(getEnv("ERROR_LOG_SIZE", "100"))
+	dedupWindowMinutes, _ := strconv.Atoi(getEnv("DEDUP_WINDOW", "0"))
+	readinessCacheSeconds, _ := strconv.Atoi(getEnv("READINESS_CACHE_SECONDS", "5"))
+	uploadScanTimeoutSeconds, _ := strconv.Atoi(getEnv("UPLOAD_SCAN_TIMEOUT_SECONDS", "30"))
+	maxUploadsPerIP, _ := strconv.Atoi(getEnv("MAX_UPLOADS_PER_IP", "0"))
+	optimizedImageMaxSize, _ := strconv.Atoi(getEnv("OPTIMIZED_IMAGE_MAX_SIZE", "1600"))
+	shutdownTimeoutSeconds, _ := strconv.Atoi(getEnv("SHUTDOWN_TIMEOUT_SECONDS", "30"))
+
+	asyncCareGeneration := getEnvBool("ASYNC_CARE_GENERATION", false)
 
 	return &Config{
-		ServerPort:        getEnv("SERVER_PORT", "8080"),
-		MLServiceURL:      getEnv("ML_SERVICE_URL", "http://localhost:8000"),
-		UploadDir:         getEnv("UPLOAD_DIR", "./uploads"),
-		MaxFileSize:       maxFileSize,
-		AllowedExtensions: []string{".jpg", ".jpeg", ".png"},
-		SpeciesThreshold:  speciesThreshold,
-		CareDataPath:      getEnv("CARE_DATA_PATH", "../care_data.json"),
-		OpenAIAPIKey:      getEnv("OPENAI_API_KEY", ""),
+		ServerPort:                getEnv("SERVER_PORT", "8080"),
+		ShutdownTimeout:           time.Duration(shutdownTimeoutSeconds) * time.Second,
+		MLServiceURL:              getEnv("ML_SERVICE_URL", "http://localhost:8000"),
+		MLTransferMode:            getEnv("ML_TRANSFER_MODE", "path"),
+		MLRequestKey:              getEnv("ML_REQUEST_KEY", "image_path"),
+		MLImageField:              getEnv("ML_IMAGE_FIELD", "image"),
+		MLImageContentType:        getEnv("ML_IMAGE_CONTENT_TYPE", ""),
+		MLUploadGzip:              getEnvBool("ML_UPLOAD_GZIP", false),
+		UploadDir:                 getEnv("UPLOAD_DIR", "./uploads"),
+		MaxFileSize:               maxFileSize,
+		AllowedExtensions:         []string{".jpg", ".jpeg", ".png"},
+		UploadNaming:              getEnv("UPLOAD_NAMING", NamingUUID),
+		MaxUploadsPerIP:           maxUploadsPerIP,
+		OptimizedImagesEnabled:    getEnvBool("OPTIMIZED_IMAGES_ENABLED", false),
+		PublicUploadsEnabled:      getEnvBool("PUBLIC_UPLOADS_ENABLED", true),
+		OptimizedImageMaxSize:     optimizedImageMaxSize,
+		UploadScanEnabled:         getEnvBool("UPLOAD_SCAN_ENABLED", false),
+		UploadScanClamdAddress:    getEnv("UPLOAD_SCAN_CLAMD_ADDRESS", "localhost:3310"),
+		UploadScanCommand:         getEnv("UPLOAD_SCAN_COMMAND", ""),
+		UploadScanTimeout:         time.Duration(uploadScanTimeoutSeconds) * time.Second,
+		UploadQuarantineDir:       getEnv("UPLOAD_QUARANTINE_DIR", ""),
+		SpeciesThreshold:          speciesThreshold,
+		ConfidenceFloor:           confidenceFloor,
+		IdentifyAlternatives:      identifyAlternatives,
+		MaxIdentifyAlternatives:   maxIdentifyAlternatives,
+		LabelDelimiter:            getEnv("LABEL_DELIMITER", DefaultLabelDelimiter),
+		SingleTokenSpecies:        splitList(getEnv("SINGLE_TOKEN_SPECIES", "")),
+		IdentifyRetryTransient:    getEnvBool("IDENTIFY_RETRY_TRANSIENT", false),
+		ReidentifyDedupe:          getEnvBool("REIDENTIFY_DEDUPE", true),
+		ReidentifyTolerance:       reidentifyTolerance,
+		DedupWindow:               time.Duration(dedupWindowMinutes) * time.Minute,
+		SimilarImageDistance:      similarImageDistance,
+		RecordFailures:            getEnvBool("RECORD_FAILED_IDENTIFICATIONS", false),
+		RecordTimings:             getEnvBool("RECORD_REQUEST_TIMINGS", false),
+		CareDataPath:              getEnv("CARE_DATA_PATH", "../care_data.json"),
+		CommonNamesPath:           getEnv("COMMON_NAMES_PATH", "../common_names.json"),
+		CareDataURL:               getEnv("CARE_DATA_URL", ""),
+		CareDataRefreshInterval:   time.Duration(careDataRefreshMinutes) * time.Minute,
+		CareDataMergeSpecies:      getEnvBool("CARE_DATA_MERGE_SPECIES", false),
+		PinnedCareGenera:          splitList(getEnv("PINNED_CARE_GENERA", "")),
+		BlockedLabels:             splitList(getEnv("BLOCKED_LABELS", "")),
+		StaticCareLabelPatterns:   splitList(getEnv("STATIC_CARE_LABEL_PATTERNS", "")),
+		CareSourceOrder:           splitList(getEnv("CARE_SOURCE_ORDER", "cache,llm,static")),
+		CareCacheMaxEntries:       careCacheMaxEntries,
+		CarePromptVersion:         carePromptVersion,
+		AsyncCareGeneration:       asyncCareGeneration,
+		CareWebhookURL:            getEnv("CARE_WEBHOOK_URL", ""),
+		CareGenerationConcurrency: careGenerationConcurrency,
+		CareGenerationRetries:     careGenerationRetries,
+		SiblingCareWarmCount:      siblingCareWarmCount,
+		SiblingCareWarmGenera:     splitList(getEnv("SIBLING_CARE_WARM_GENERA", "")),
+		CareRegenerateCooldown:    time.Duration(careRegenerateCooldownMinutes) * time.Minute,
+		IdentificationTTLDays:     identificationTTLDays,
+		ShareSecret:               getEnv("SHARE_SECRET", ""),
+		AdminToken:                getEnv("ADMIN_TOKEN", ""),
+		ErrorLogSize:              errorLogSize,
+		ReadinessCacheTTL:         time.Duration(readinessCacheSeconds) * time.Second,
+		ShareTokenTTL:             time.Duration(shareTokenTTLHours) * time.Hour,
+		TrustedProxies:            getEnv("TRUSTED_PROXIES", ""),
+		PublicBaseURL:             getEnv("PUBLIC_BASE_URL", ""),
+		BasePath:                  NormalizeBasePath(getEnv("BASE_PATH", "")),
+		ExportConfidencePrecision: exportConfidencePrecision,
+		ChatContextTokenBudget:    chatContextTokenBudget,
+		ChatAllowContextless:      getEnvBool("CHAT_ALLOW_CONTEXTLESS", false),
+		MaxUserMessageChars:       maxUserMessageChars,
+		CompareModels:             splitList(getEnv("COMPARE_MODELS", "gpt-4o-mini")),
+		ChatLanguageDetection:     getEnvBool("CHAT_LANGUAGE_DETECTION", true),
+		ChatCareReferences:        getEnvBool("CHAT_CARE_REFERENCES", false),
+		OpenAIAPIKey:              getEnv("OPENAI_API_KEY", ""),
+		Features:                  loadFeatureFlags(asyncCareGeneration),
+	}
+}
+
+// splitList splits a comma-separated value, dropping empty entries
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
 	}
+	return items
+}
+
+// getEnvBool gets a boolean environment variable or returns a default value
+func getEnvBool(key string, defaultValue bool) bool {
+	value, err := strconv.ParseBool(getEnv(key, ""))
+	if err != nil {
+		return defaultValue
+	}
+	return value
 }
 
 // getEnv gets an environment variable or returns a default value
@@ -0,0 +1,110 @@
+package utils
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"succulent-identifier-backend/models"
+)
+
+// ErrorLog keeps the most recent server errors in a fixed-size ring buffer so
+// operators without log aggregation can inspect them at GET /admin/errors
+type ErrorLog struct {
+	mu      sync.Mutex
+	entries []models.ErrorEntry
+	next    int  // index the next entry is written to
+	full    bool // every slot holds an entry, next is the oldest
+}
+
+// NewErrorLog creates an error log holding up to size entries
+func NewErrorLog(size int) *ErrorLog {
+	if size < 1 {
+		size = 1
+	}
+	return &ErrorLog{entries: make([]models.ErrorEntry, size)}
+}
+
+// Add records an entry, evicting the oldest one when the log is full
+func (l *ErrorLog) Add(entry models.ErrorEntry) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.entries[l.next] = entry
+	l.next = (l.next + 1) % len(l.entries)
+	if l.next == 0 {
+		l.full = true
+	}
+}
+
+// Recent returns the recorded entries, newest first
+func (l *ErrorLog) Recent() []models.ErrorEntry {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	count := l.next
+	if l.full {
+		count = len(l.entries)
+	}
+	recent := make([]models.ErrorEntry, 0, count)
+	for i := 1; i <= count; i++ {
+		recent = append(recent, l.entries[(l.next-i+len(l.entries))%len(l.entries)])
+	}
+	return recent
+}
+
+// Capacity returns the max number of entries kept
+func (l *ErrorLog) Capacity() int {
+	return len(l.entries)
+}
+
+// ErrorRecorder is implemented by response writers that capture the errors
+// written through them; error-writing helpers report to it when available
+type ErrorRecorder interface {
+	RecordError(status int, message string)
+}
+
+// errorLogWriter records server errors written to a request's response
+type errorLogWriter struct {
+	http.ResponseWriter
+	log       *ErrorLog
+	requestID string
+	method    string
+	path      string
+}
+
+// RecordError adds 5xx errors to the log; client errors are not recorded
+func (w *errorLogWriter) RecordError(status int, message string) {
+	if status < http.StatusInternalServerError {
+		return
+	}
+	w.log.Add(models.ErrorEntry{
+		Time:      time.Now().UTC(),
+		RequestID: w.requestID,
+		Method:    w.method,
+		Path:      w.path,
+		Status:    status,
+		Error:     message,
+	})
+}
+
+// Flush lets streaming handlers flush through the writer
+func (w *errorLogWriter) Flush() {
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// ErrorLogMiddleware makes the response writer an ErrorRecorder feeding
+// errorLog. It must run inside LoggingMiddleware to see the request ID.
+func ErrorLogMiddleware(errorLog *ErrorLog, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(&errorLogWriter{
+			ResponseWriter: w,
+			log:            errorLog,
+			requestID:      RequestIDFromContext(r.Context()),
+			method:         r.Method,
+			path:           r.URL.Path,
+		}, r)
+	})
+}
@@ -0,0 +1,57 @@
+package utils
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"succulent-identifier-backend/models"
+)
+
+func TestErrorLogEvictsOldest(t *testing.T) {
+	errorLog := NewErrorLog(3)
+	for _, id := range []string{"req-1", "req-2", "req-3", "req-4", "req-5"} {
+		errorLog.Add(models.ErrorEntry{RequestID: id})
+	}
+
+	recent := errorLog.Recent()
+	expected := []string{"req-5", "req-4", "req-3"}
+	if len(recent) != len(expected) {
+		t.Fatalf("Expected %d entries, got %+v", len(expected), recent)
+	}
+	for i, id := range expected {
+		if recent[i].RequestID != id {
+			t.Errorf("Recent()[%d] = %s, expected %s", i, recent[i].RequestID, id)
+		}
+	}
+
+	if empty := NewErrorLog(3).Recent(); len(empty) != 0 {
+		t.Errorf("Expected no entries in a new log, got %+v", empty)
+	}
+}
+
+func TestErrorLogMiddleware(t *testing.T) {
+	errorLog := NewErrorLog(10)
+	handler := LoggingMiddleware(ErrorLogMiddleware(errorLog, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		recorder, ok := w.(ErrorRecorder)
+		if !ok {
+			t.Fatal("Expected response writer to record errors")
+		}
+		recorder.RecordError(http.StatusBadRequest, "client error")
+		recorder.RecordError(http.StatusBadGateway, "upstream failed")
+	})))
+
+	req := httptest.NewRequest(http.MethodPost, "/identify", nil)
+	req.Header.Set(RequestIDHeader, "req-42")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	recent := errorLog.Recent()
+	if len(recent) != 1 {
+		t.Fatalf("Expected only the server error to be recorded, got %+v", recent)
+	}
+	entry := recent[0]
+	if entry.RequestID != "req-42" || entry.Method != http.MethodPost || entry.Path != "/identify" ||
+		entry.Status != http.StatusBadGateway || entry.Error != "upstream failed" || entry.Time.IsZero() {
+		t.Errorf("Unexpected entry: %+v", entry)
+	}
+}
@@ -0,0 +1,131 @@
+package utils
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"io"
+	"os"
+	"time"
+)
+
+// EXIF tags read for the capture time
+const (
+	exifTagDateTime         = 0x0132 // IFD0: last modification time
+	exifTagExifIFD          = 0x8769 // IFD0: offset of the Exif sub-IFD
+	exifTagDateTimeOriginal = 0x9003 // Exif IFD: time the photo was taken
+)
+
+// exifTimeLayout is the EXIF date format; it carries no time zone
+const exifTimeLayout = "2006:01:02 15:04:05"
+
+// ExifCaptureTime returns when a JPEG photo was taken according to its EXIF
+// data, preferring DateTimeOriginal over DateTime. EXIF times are camera local
+// time without a zone and are returned as if they were UTC. Returns nil when
+// the file is not a JPEG or carries no usable EXIF time.
+func ExifCaptureTime(path string) *time.Time {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil
+	}
+	defer file.Close()
+
+	tiff := readExifSegment(bufio.NewReader(file))
+	if tiff == nil {
+		return nil
+	}
+	return parseExifTime(tiff)
+}
+
+// readExifSegment scans the JPEG markers before the image data and returns
+// the TIFF structure of the "Exif" APP1 segment, or nil if there is none
+func readExifSegment(r *bufio.Reader) []byte {
+	var soi [2]byte
+	if _, err := io.ReadFull(r, soi[:]); err != nil || soi != [2]byte{0xFF, 0xD8} {
+		return nil
+	}
+
+	for {
+		var header [4]byte
+		if _, err := io.ReadFull(r, header[:]); err != nil || header[0] != 0xFF {
+			return nil
+		}
+		marker := header[1]
+		length := int(binary.BigEndian.Uint16(header[2:])) - 2
+		if marker == 0xDA || length < 0 { // start of scan, no metadata follows
+			return nil
+		}
+
+		segment := make([]byte, length)
+		if _, err := io.ReadFull(r, segment); err != nil {
+			return nil
+		}
+		if marker == 0xE1 && bytes.HasPrefix(segment, []byte("Exif\x00\x00")) {
+			return segment[6:]
+		}
+	}
+}
+
+// parseExifTime reads the capture time from a TIFF structure
+func parseExifTime(tiff []byte) *time.Time {
+	if len(tiff) < 8 {
+		return nil
+	}
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return nil
+	}
+
+	ifd0 := readIFD(tiff, order, order.Uint32(tiff[4:]))
+	if entry, ok := ifd0[exifTagExifIFD]; ok {
+		exifIFD := readIFD(tiff, order, order.Uint32(entry[6:]))
+		if t := exifASCIITime(tiff, order, exifIFD[exifTagDateTimeOriginal]); t != nil {
+			return t
+		}
+	}
+	return exifASCIITime(tiff, order, ifd0[exifTagDateTime])
+}
+
+// readIFD returns the type, count and value fields (10 bytes) of each entry
+// of the IFD at offset, keyed by tag
+func readIFD(tiff []byte, order binary.ByteOrder, offset uint32) map[uint16][]byte {
+	entries := map[uint16][]byte{}
+	if uint64(offset)+2 > uint64(len(tiff)) {
+		return entries
+	}
+	count := int(order.Uint16(tiff[offset:]))
+	start := int(offset) + 2
+	for i := 0; i < count; i++ {
+		entry := start + i*12
+		if entry+12 > len(tiff) {
+			break
+		}
+		entries[order.Uint16(tiff[entry:])] = tiff[entry+2 : entry+12]
+	}
+	return entries
+}
+
+// exifASCIITime parses an ASCII date entry (type, count, value offset)
+func exifASCIITime(tiff []byte, order binary.ByteOrder, entry []byte) *time.Time {
+	const asciiType = 2
+	if len(entry) != 10 || order.Uint16(entry) != asciiType {
+		return nil
+	}
+	count := order.Uint32(entry[2:])
+	offset := order.Uint32(entry[6:])
+	if count < uint32(len(exifTimeLayout)) || uint64(offset)+uint64(count) > uint64(len(tiff)) {
+		return nil
+	}
+
+	value := string(tiff[offset : offset+uint32(len(exifTimeLayout))])
+	t, err := time.Parse(exifTimeLayout, value)
+	if err != nil {
+		return nil
+	}
+	return &t
+}
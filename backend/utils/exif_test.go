@@ -0,0 +1,106 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/jpeg"
+	"mime/multipart"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// jpegWithExif encodes a small JPEG carrying an Exif APP1 segment whose
+// Exif IFD holds DateTimeOriginal
+func jpegWithExif(t *testing.T, dateTimeOriginal string) []byte {
+	t.Helper()
+	var img bytes.Buffer
+	if err := jpeg.Encode(&img, image.NewRGBA(image.Rect(0, 0, 16, 12)), nil); err != nil {
+		t.Fatalf("Failed to encode JPEG: %v", err)
+	}
+
+	// Little-endian TIFF: IFD0 at 8 points to the Exif IFD at 26, whose
+	// DateTimeOriginal string starts at 44
+	le := binary.LittleEndian
+	tiff := []byte("II*\x00")
+	tiff = le.AppendUint32(tiff, 8)
+	tiff = le.AppendUint16(tiff, 1)
+	tiff = le.AppendUint16(tiff, exifTagExifIFD)
+	tiff = le.AppendUint16(tiff, 4) // LONG
+	tiff = le.AppendUint32(tiff, 1)
+	tiff = le.AppendUint32(tiff, 26)
+	tiff = le.AppendUint32(tiff, 0)
+	tiff = le.AppendUint16(tiff, 1)
+	tiff = le.AppendUint16(tiff, exifTagDateTimeOriginal)
+	tiff = le.AppendUint16(tiff, 2) // ASCII
+	tiff = le.AppendUint32(tiff, uint32(len(dateTimeOriginal)+1))
+	tiff = le.AppendUint32(tiff, 44)
+	tiff = le.AppendUint32(tiff, 0)
+	tiff = append(tiff, dateTimeOriginal+"\x00"...)
+
+	segment := append([]byte("Exif\x00\x00"), tiff...)
+	app1 := []byte{0xFF, 0xE1}
+	app1 = binary.BigEndian.AppendUint16(app1, uint16(len(segment)+2))
+	app1 = append(app1, segment...)
+
+	data := img.Bytes()
+	return append(append(append([]byte{}, data[:2]...), app1...), data[2:]...)
+}
+
+func TestExifCaptureTime(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name string, content []byte) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, content, 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+		return path
+	}
+
+	var plain bytes.Buffer
+	jpeg.Encode(&plain, image.NewRGBA(image.Rect(0, 0, 4, 4)), nil)
+
+	captured := ExifCaptureTime(write("exif.jpg", jpegWithExif(t, "2024:05:17 09:30:00")))
+	expected := time.Date(2024, 5, 17, 9, 30, 0, 0, time.UTC)
+	if captured == nil || !captured.Equal(expected) {
+		t.Errorf("Expected capture time %v, got %v", expected, captured)
+	}
+
+	for name, content := range map[string][]byte{
+		"plain.jpg":    plain.Bytes(),
+		"garbage.jpg":  []byte("not an image"),
+		"bad-date.jpg": jpegWithExif(t, "yesterday at noon!!"),
+	} {
+		if captured := ExifCaptureTime(write(name, content)); captured != nil {
+			t.Errorf("%s: expected no capture time, got %v", name, captured)
+		}
+	}
+}
+
+func TestSaveFileImageMetadata(t *testing.T) {
+	uploader, _ := NewFileUploader(t.TempDir(), 1024*1024, []string{".jpg"})
+
+	content := jpegWithExif(t, "2024:05:17 09:30:00")
+	_, metadata, err := uploader.SaveFile(newMockFile(content), &multipart.FileHeader{Filename: "plant.jpg", Size: int64(len(content))})
+	if err != nil {
+		t.Fatalf("SaveFile() unexpected error: %v", err)
+	}
+	if metadata.Width != 16 || metadata.Height != 12 || metadata.Format != "jpeg" || metadata.Size != int64(len(content)) {
+		t.Errorf("Expected 16x12 jpeg of %d bytes, got %+v", len(content), metadata)
+	}
+	if metadata.CapturedAt == nil || metadata.CapturedAt.Year() != 2024 {
+		t.Errorf("Expected EXIF capture time, got %v", metadata.CapturedAt)
+	}
+
+	// Undecodable uploads still record their size
+	fake := []byte("fake image content")
+	_, metadata, err = uploader.SaveFile(newMockFile(fake), &multipart.FileHeader{Filename: "fake.jpg", Size: int64(len(fake))})
+	if err != nil {
+		t.Fatalf("SaveFile() unexpected error: %v", err)
+	}
+	if metadata.Size != int64(len(fake)) || metadata.Format != "" || metadata.Width != 0 {
+		t.Errorf("Expected size-only metadata, got %+v", metadata)
+	}
+}
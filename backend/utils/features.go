@@ -0,0 +1,28 @@
+package utils
+
+// Optional features that can be toggled with FEATURE_<NAME> environment variables
+const (
+	FeatureChat          = "chat"
+	FeatureShare         = "share"
+	FeatureBatchIdentify = "batch_identify"
+	FeatureAsyncCare     = "async_care"
+)
+
+// FeatureFlags reports which optional features are enabled
+type FeatureFlags map[string]bool
+
+// Enabled reports whether a feature is turned on; unknown features are off
+func (f FeatureFlags) Enabled(feature string) bool {
+	return f[feature]
+}
+
+// loadFeatureFlags reads the feature toggles from the environment.
+// Optional endpoints default to enabled; async care keeps its own setting.
+func loadFeatureFlags(asyncCare bool) FeatureFlags {
+	return FeatureFlags{
+		FeatureChat:          getEnvBool("FEATURE_CHAT", true),
+		FeatureShare:         getEnvBool("FEATURE_SHARE", true),
+		FeatureBatchIdentify: getEnvBool("FEATURE_BATCH_IDENTIFY", true),
+		FeatureAsyncCare:     asyncCare,
+	}
+}
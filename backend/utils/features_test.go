@@ -0,0 +1,26 @@
+package utils
+
+import "testing"
+
+func TestLoadFeatureFlags(t *testing.T) {
+	t.Setenv("FEATURE_SHARE", "false")
+	t.Setenv("FEATURE_BATCH_IDENTIFY", "")
+
+	features := loadFeatureFlags(true)
+
+	expected := map[string]bool{
+		FeatureChat:          true,  // default on
+		FeatureShare:         false, // disabled by env
+		FeatureBatchIdentify: true,  // empty value keeps default
+		FeatureAsyncCare:     true,
+	}
+	for feature, enabled := range expected {
+		if features.Enabled(feature) != enabled {
+			t.Errorf("Enabled(%s) = %v, expected %v", feature, features.Enabled(feature), enabled)
+		}
+	}
+
+	if features.Enabled("unknown") {
+		t.Error("Unknown features should be disabled")
+	}
+}
@@ -1,37 +1,107 @@
 package utils
 
 import (
+	"errors"
 	"fmt"
+	"image"
+	_ "image/jpeg" // register JPEG decoder
+	_ "image/png"  // register PNG decoder
 	"io"
+	"io/fs"
+	"log"
 	"mime/multipart"
 	"os"
 	"path/filepath"
 	"strings"
+	"syscall"
+	"unicode"
 
 	"github.com/google/uuid"
+	"succulent-identifier-backend/models"
 )
 
+// Upload naming strategies
+const (
+	NamingUUID     = "uuid"     // random UUID filenames (default)
+	NamingOriginal = "original" // sanitized original filenames, deduplicated
+)
+
+// maxFilenameLength caps the sanitized base name length
+const maxFilenameLength = 100
+
 // FileUploader handles file upload operations
 type FileUploader struct {
 	uploadDir         string
 	maxFileSize       int64
 	allowedExtensions []string
+	naming            string
+
+	// Optional malware scan of saved files; infected files are moved to
+	// quarantineDir, or deleted when it is empty
+	scanner       FileScanner
+	quarantineDir string
+
+	// Longest side of the optimized web copy saved next to each upload (0 disables)
+	optimizedMaxSize int
 }
 
 // NewFileUploader creates a new file uploader
 func NewFileUploader(uploadDir string, maxFileSize int64, allowedExtensions []string) (*FileUploader, error) {
+	// MkdirAll fails with a bare "not a directory" when the path is a file
+	if info, err := os.Stat(uploadDir); err == nil && !info.IsDir() {
+		return nil, fmt.Errorf("upload directory %q exists and is not a directory, check UPLOAD_DIR", uploadDir)
+	}
+
 	// Create upload directory if it doesn't exist
 	if err := os.MkdirAll(uploadDir, 0755); err != nil {
-		return nil, fmt.Errorf("failed to create upload directory: %w", err)
+		switch {
+		case errors.Is(err, fs.ErrPermission):
+			return nil, fmt.Errorf("permission denied creating upload directory %q: %w", uploadDir, err)
+		case errors.Is(err, syscall.ENOTDIR):
+			return nil, fmt.Errorf("upload directory %q has a parent that is a file, not a directory: %w", uploadDir, err)
+		default:
+			return nil, fmt.Errorf("failed to create upload directory %q: %w", uploadDir, err)
+		}
 	}
 
 	return &FileUploader{
 		uploadDir:         uploadDir,
 		maxFileSize:       maxFileSize,
 		allowedExtensions: allowedExtensions,
+		naming:            NamingUUID,
 	}, nil
 }
 
+// SetNaming configures how saved files are named (uuid or original)
+func (fu *FileUploader) SetNaming(naming string) error {
+	switch naming {
+	case NamingUUID, NamingOriginal:
+		fu.naming = naming
+		return nil
+	default:
+		return fmt.Errorf("unknown upload naming strategy '%s'", naming)
+	}
+}
+
+// SetScanner enables scanning saved files with scanner before they are used.
+// Infected files are moved to quarantineDir, or deleted when it is empty.
+func (fu *FileUploader) SetScanner(scanner FileScanner, quarantineDir string) error {
+	if quarantineDir != "" {
+		if err := os.MkdirAll(quarantineDir, 0700); err != nil {
+			return fmt.Errorf("failed to create quarantine directory: %w", err)
+		}
+	}
+	fu.scanner = scanner
+	fu.quarantineDir = quarantineDir
+	return nil
+}
+
+// SetOptimizedImages enables saving an optimized JPEG copy of each upload
+// whose longest side is at most maxSize pixels (0 disables)
+func (fu *FileUploader) SetOptimizedImages(maxSize int) {
+	fu.optimizedMaxSize = maxSize
+}
+
 // ValidateFile validates the uploaded file
 func (fu *FileUploader) ValidateFile(fileHeader *multipart.FileHeader) error {
 	// Check file size
@@ -52,38 +122,203 @@ func (fu *FileUploader) ValidateFile(fileHeader *multipart.FileHeader) error {
 	return nil
 }
 
-// SaveFile saves an uploaded file and returns the file path
-func (fu *FileUploader) SaveFile(file multipart.File, fileHeader *multipart.FileHeader) (string, error) {
-	// Validate file first
+// InspectFile validates the uploaded file and decodes its image header
+// without saving it, returning the image dimensions, format and size
+func (fu *FileUploader) InspectFile(file multipart.File, fileHeader *multipart.FileHeader) (*models.ImageMetadata, error) {
 	if err := fu.ValidateFile(fileHeader); err != nil {
-		return "", err
+		return nil, err
 	}
 
-	// Generate unique filename
-	ext := filepath.Ext(fileHeader.Filename)
-	filename := uuid.New().String() + ext
-	filePath := filepath.Join(fu.uploadDir, filename)
-
-	// Get absolute path
-	absPath, err := filepath.Abs(filePath)
+	config, format, err := image.DecodeConfig(file)
 	if err != nil {
-		return "", fmt.Errorf("failed to get absolute path: %w", err)
+		return nil, fmt.Errorf("file is not a valid image: %w", err)
 	}
 
-	// Create destination file
-	dst, err := os.Create(absPath)
+	return &models.ImageMetadata{
+		Width:  config.Width,
+		Height: config.Height,
+		Format: format,
+		Size:   fileHeader.Size,
+	}, nil
+}
+
+// SaveFile saves an uploaded file and returns the file path along with the
+// image metadata read from the saved file
+func (fu *FileUploader) SaveFile(file multipart.File, fileHeader *multipart.FileHeader) (string, *models.ImageMetadata, error) {
+	// Validate file first
+	if err := fu.ValidateFile(fileHeader); err != nil {
+		return "", nil, err
+	}
+
+	// Create destination file with a unique name
+	dst, absPath, err := fu.createDestination(fileHeader.Filename)
 	if err != nil {
-		return "", fmt.Errorf("failed to create destination file: %w", err)
+		return "", nil, err
 	}
 	defer dst.Close()
 
 	// Copy uploaded file to destination
-	if _, err := io.Copy(dst, file); err != nil {
+	size, err := io.Copy(dst, file)
+	if err != nil {
 		os.Remove(absPath) // Clean up on error
-		return "", fmt.Errorf("failed to save file: %w", err)
+		return "", nil, fmt.Errorf("failed to save file: %w", err)
+	}
+	if err := dst.Close(); err != nil {
+		os.Remove(absPath)
+		return "", nil, fmt.Errorf("failed to save file: %w", err)
+	}
+
+	if err := fu.scanFile(absPath); err != nil {
+		return "", nil, err
+	}
+
+	return absPath, readImageMetadata(absPath, size), nil
+}
+
+// SaveOptimized writes an optimized JPEG copy of a saved upload next to it and
+// returns its path, or "" when optimized images are disabled. The original is
+// kept untouched for re-identification at full quality.
+func (fu *FileUploader) SaveOptimized(path string) (string, error) {
+	if fu.optimizedMaxSize <= 0 {
+		return "", nil
 	}
 
-	return absPath, nil
+	optimized, err := GenerateThumbnail(path, fu.optimizedMaxSize)
+	if err != nil {
+		return "", err
+	}
+
+	// Upload names never contain a second dot, so the copy cannot collide
+	optimizedPath := path + ".web.jpg"
+	if err := os.WriteFile(optimizedPath, optimized, 0644); err != nil {
+		return "", fmt.Errorf("failed to save optimized image: %w", err)
+	}
+	return optimizedPath, nil
+}
+
+// readImageMetadata decodes the image header and EXIF capture time of a saved
+// file. Dimensions and format stay empty when the header cannot be decoded.
+func readImageMetadata(path string, size int64) *models.ImageMetadata {
+	metadata := &models.ImageMetadata{Size: size, CapturedAt: ExifCaptureTime(path)}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return metadata
+	}
+	defer file.Close()
+
+	if config, format, err := image.DecodeConfig(file); err == nil {
+		metadata.Width = config.Width
+		metadata.Height = config.Height
+		metadata.Format = format
+	}
+	return metadata
+}
+
+// scanFile scans a saved file when a scanner is configured, quarantining
+// infected files. Files that could not be scanned are deleted, never used.
+func (fu *FileUploader) scanFile(path string) error {
+	if fu.scanner == nil {
+		return nil
+	}
+
+	err := fu.scanner.Scan(path)
+	if err == nil {
+		return nil
+	}
+	if !errors.Is(err, ErrInfected) {
+		os.Remove(path)
+		log.Printf("Upload scan failed for %s: %v", filepath.Base(path), err)
+		return ErrScanFailed
+	}
+
+	log.Printf("Upload %s rejected: %v", filepath.Base(path), err)
+	if fu.quarantineDir != "" {
+		err := os.Rename(path, filepath.Join(fu.quarantineDir, filepath.Base(path)))
+		if err == nil {
+			return ErrInfected
+		}
+		log.Printf("Failed to quarantine %s, deleting it: %v", filepath.Base(path), err)
+	}
+	os.Remove(path)
+	return ErrInfected
+}
+
+// createDestination creates the destination file according to the naming strategy
+func (fu *FileUploader) createDestination(originalName string) (*os.File, string, error) {
+	ext := filepath.Ext(originalName)
+
+	uploadDir, err := filepath.Abs(fu.uploadDir)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to get absolute path: %w", err)
+	}
+
+	if fu.naming != NamingOriginal {
+		absPath := filepath.Join(uploadDir, uuid.New().String()+ext)
+		dst, err := os.Create(absPath)
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to create destination file: %w", err)
+		}
+		return dst, absPath, nil
+	}
+
+	// Keep the sanitized original name, appending a counter on collision.
+	// O_EXCL makes the existence check and creation atomic.
+	base := SanitizeFilename(strings.TrimSuffix(originalName, ext))
+	ext = strings.ToLower(ext)
+	for i := 1; i <= 1000; i++ {
+		name := base + ext
+		if i > 1 {
+			name = fmt.Sprintf("%s-%d%s", base, i, ext)
+		}
+		absPath := filepath.Join(uploadDir, name)
+
+		dst, err := os.OpenFile(absPath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+		if err == nil {
+			return dst, absPath, nil
+		}
+		if !os.IsExist(err) {
+			return nil, "", fmt.Errorf("failed to create destination file: %w", err)
+		}
+	}
+
+	return nil, "", fmt.Errorf("failed to create destination file: too many files named '%s'", base)
+}
+
+// SanitizeFilename converts a user-supplied file name into a safe slug.
+// Directory components, control characters and anything other than letters,
+// digits and hyphens are removed, so the result can never escape the upload directory.
+func SanitizeFilename(name string) string {
+	// Drop any directory components (both separator styles)
+	if idx := strings.LastIndexAny(name, "/\\"); idx != -1 {
+		name = name[idx+1:]
+	}
+
+	var b strings.Builder
+	lastHyphen := false
+	for _, r := range strings.ToLower(name) {
+		switch {
+		case unicode.IsControl(r):
+			continue
+		case (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9'):
+			b.WriteRune(r)
+			lastHyphen = false
+		default:
+			if !lastHyphen && b.Len() > 0 {
+				b.WriteRune('-')
+				lastHyphen = true
+			}
+		}
+	}
+
+	slug := strings.Trim(b.String(), "-")
+	if len(slug) > maxFilenameLength {
+		slug = strings.Trim(slug[:maxFilenameLength], "-")
+	}
+	if slug == "" {
+		slug = "upload"
+	}
+	return slug
 }
 
 // DeleteFile deletes a file from the upload directory
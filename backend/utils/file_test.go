@@ -2,10 +2,19 @@ package utils
 
 import (
 	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"math/rand"
 	"mime/multipart"
 	"os"
+	"os/exec"
 	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 )
 
 // mockFile implements multipart.File interface for testing
@@ -73,6 +82,23 @@ func TestNewFileUploader(t *testing.T) {
 	}
 }
 
+func TestNewFileUploaderPathIsFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "uploads")
+	if err := os.WriteFile(path, []byte("not a directory"), 0644); err != nil {
+		t.Fatalf("Failed to create file: %v", err)
+	}
+
+	_, err := NewFileUploader(path, 1024, []string{".jpg"})
+	if err == nil || !strings.Contains(err.Error(), "exists and is not a directory") {
+		t.Errorf("Expected error describing a file at the upload path, got %v", err)
+	}
+
+	_, err = NewFileUploader(filepath.Join(path, "nested"), 1024, []string{".jpg"})
+	if err == nil || !strings.Contains(err.Error(), "parent that is a file") {
+		t.Errorf("Expected error describing a file as parent, got %v", err)
+	}
+}
+
 func TestValidateFile(t *testing.T) {
 	uploader, _ := NewFileUploader("../testdata/uploads", 1024*1024, []string{".jpg", ".jpeg", ".png"})
 	defer os.RemoveAll("../testdata/uploads")
@@ -194,7 +220,7 @@ func TestSaveFile(t *testing.T) {
 			}
 
 			// Save the file
-			savedPath, err := uploader.SaveFile(mockFile, fileHeader)
+			savedPath, _, err := uploader.SaveFile(mockFile, fileHeader)
 
 			if tt.wantErr {
 				if err == nil {
@@ -285,3 +311,281 @@ func TestDeleteFile(t *testing.T) {
 func contains(s, substr string) bool {
 	return bytes.Contains([]byte(s), []byte(substr))
 }
+
+func TestInspectFile(t *testing.T) {
+	uploadDir := "../testdata/uploads_inspect"
+	uploader, _ := NewFileUploader(uploadDir, 1024*1024, []string{".jpg", ".png"})
+	defer os.RemoveAll(uploadDir)
+
+	var pngBuf bytes.Buffer
+	png.Encode(&pngBuf, image.NewRGBA(image.Rect(0, 0, 40, 30)))
+
+	var jpegBuf bytes.Buffer
+	jpeg.Encode(&jpegBuf, image.NewRGBA(image.Rect(0, 0, 16, 12)), nil)
+
+	tests := []struct {
+		name           string
+		filename       string
+		content        []byte
+		wantErr        bool
+		expectedFormat string
+		expectedWidth  int
+		expectedHeight int
+	}{
+		{
+			name:           "Valid PNG",
+			filename:       "plant.png",
+			content:        pngBuf.Bytes(),
+			expectedFormat: "png",
+			expectedWidth:  40,
+			expectedHeight: 30,
+		},
+		{
+			name:           "Valid JPEG",
+			filename:       "plant.jpg",
+			content:        jpegBuf.Bytes(),
+			expectedFormat: "jpeg",
+			expectedWidth:  16,
+			expectedHeight: 12,
+		},
+		{
+			name:     "Undecodable content",
+			filename: "plant.jpg",
+			content:  []byte("fake image content"),
+			wantErr:  true,
+		},
+		{
+			name:     "Invalid extension",
+			filename: "plant.pdf",
+			content:  pngBuf.Bytes(),
+			wantErr:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fileHeader := &multipart.FileHeader{
+				Filename: tt.filename,
+				Size:     int64(len(tt.content)),
+			}
+
+			metadata, err := uploader.InspectFile(newMockFile(tt.content), fileHeader)
+
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("InspectFile() expected error, got nil")
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("InspectFile() unexpected error: %v", err)
+			}
+
+			if metadata.Format != tt.expectedFormat {
+				t.Errorf("InspectFile() format = %v, expected %v", metadata.Format, tt.expectedFormat)
+			}
+			if metadata.Width != tt.expectedWidth || metadata.Height != tt.expectedHeight {
+				t.Errorf("InspectFile() dimensions = %dx%d, expected %dx%d",
+					metadata.Width, metadata.Height, tt.expectedWidth, tt.expectedHeight)
+			}
+			if metadata.Size != int64(len(tt.content)) {
+				t.Errorf("InspectFile() size = %v, expected %v", metadata.Size, len(tt.content))
+			}
+		})
+	}
+}
+
+func TestSanitizeFilename(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{name: "Simple name", input: "My Plant", expected: "my-plant"},
+		{name: "Unix path traversal", input: "../../etc/passwd", expected: "passwd"},
+		{name: "Windows path traversal", input: "..\\..\\windows\\system32", expected: "system32"},
+		{name: "Control characters", input: "plant\x00\x1f\nname\x7f", expected: "plantname"},
+		{name: "Punctuation collapsed", input: "echeveria!!__elegans..", expected: "echeveria-elegans"},
+		{name: "Only dots", input: "..", expected: "upload"},
+		{name: "Empty", input: "", expected: "upload"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := SanitizeFilename(tt.input)
+			if result != tt.expected {
+				t.Errorf("SanitizeFilename(%q) = %q, expected %q", tt.input, result, tt.expected)
+			}
+			if strings.ContainsAny(result, "/\\") {
+				t.Errorf("SanitizeFilename(%q) contains a path separator: %q", tt.input, result)
+			}
+		})
+	}
+}
+
+func TestSaveFileOriginalNaming(t *testing.T) {
+	uploadDir := "../testdata/uploads_original"
+	uploader, _ := NewFileUploader(uploadDir, 1024*1024, []string{".jpg", ".png"})
+	defer os.RemoveAll(uploadDir)
+
+	if err := uploader.SetNaming(NamingOriginal); err != nil {
+		t.Fatalf("SetNaming() unexpected error: %v", err)
+	}
+
+	save := func(filename string) string {
+		fileHeader := &multipart.FileHeader{Filename: filename, Size: 4}
+		savedPath, _, err := uploader.SaveFile(newMockFile([]byte("test")), fileHeader)
+		if err != nil {
+			t.Fatalf("SaveFile(%q) unexpected error: %v", filename, err)
+		}
+		return savedPath
+	}
+
+	first := save("../My Echeveria.JPG")
+	second := save("My Echeveria.jpg")
+
+	if filepath.Base(first) != "my-echeveria.jpg" {
+		t.Errorf("SaveFile() first name = %v, expected my-echeveria.jpg", filepath.Base(first))
+	}
+	if filepath.Base(second) != "my-echeveria-2.jpg" {
+		t.Errorf("SaveFile() duplicate name = %v, expected my-echeveria-2.jpg", filepath.Base(second))
+	}
+
+	absDir, _ := filepath.Abs(uploadDir)
+	for _, p := range []string{first, second} {
+		if filepath.Dir(p) != absDir {
+			t.Errorf("SaveFile() wrote outside upload dir: %v", p)
+		}
+	}
+
+	if err := uploader.SetNaming("random"); err == nil {
+		t.Error("SetNaming() expected error for unknown strategy")
+	}
+}
+
+func TestSaveOptimized(t *testing.T) {
+	uploadDir := "../testdata/uploads_optimized"
+	uploader, _ := NewFileUploader(uploadDir, 10*1024*1024, []string{".png"})
+	defer os.RemoveAll(uploadDir)
+
+	// A noisy photo-sized PNG compresses poorly, like a camera upload
+	img := image.NewRGBA(image.Rect(0, 0, 1200, 900))
+	rand.New(rand.NewSource(1)).Read(img.Pix)
+	var buf bytes.Buffer
+	png.Encode(&buf, img)
+
+	fileHeader := &multipart.FileHeader{Filename: "plant.png", Size: int64(buf.Len())}
+	savedPath, _, err := uploader.SaveFile(newMockFile(buf.Bytes()), fileHeader)
+	if err != nil {
+		t.Fatalf("SaveFile() unexpected error: %v", err)
+	}
+
+	if optimizedPath, err := uploader.SaveOptimized(savedPath); err != nil || optimizedPath != "" {
+		t.Errorf("SaveOptimized() disabled = %q, %v, expected no copy", optimizedPath, err)
+	}
+
+	uploader.SetOptimizedImages(600)
+	optimizedPath, err := uploader.SaveOptimized(savedPath)
+	if err != nil {
+		t.Fatalf("SaveOptimized() unexpected error: %v", err)
+	}
+
+	original, err := os.Stat(savedPath)
+	if err != nil {
+		t.Fatalf("Original file missing: %v", err)
+	}
+	optimized, err := os.Stat(optimizedPath)
+	if err != nil {
+		t.Fatalf("Optimized file missing: %v", err)
+	}
+	if optimized.Size() >= original.Size() {
+		t.Errorf("Optimized size = %d, expected smaller than original %d", optimized.Size(), original.Size())
+	}
+	if filepath.Dir(optimizedPath) != filepath.Dir(savedPath) {
+		t.Errorf("SaveOptimized() wrote %v outside the upload dir", optimizedPath)
+	}
+
+	file, _ := os.Open(optimizedPath)
+	defer file.Close()
+	config, format, err := image.DecodeConfig(file)
+	if err != nil || format != "jpeg" || config.Width != 600 || config.Height != 450 {
+		t.Errorf("Optimized image = %s %dx%d (%v), expected jpeg 600x450", format, config.Width, config.Height, err)
+	}
+}
+
+// stubScanner returns err for every scanned file
+type stubScanner struct {
+	err error
+}
+
+func (s stubScanner) Scan(path string) error {
+	return s.err
+}
+
+func TestSaveFileScan(t *testing.T) {
+	tests := []struct {
+		name       string
+		scanErr    error
+		quarantine bool
+		wantErr    error
+	}{
+		{name: "Clean file is kept"},
+		{name: "Infected file is deleted", scanErr: fmt.Errorf("%w: Eicar", ErrInfected), wantErr: ErrInfected},
+		{name: "Infected file is quarantined", scanErr: fmt.Errorf("%w: Eicar", ErrInfected), quarantine: true, wantErr: ErrInfected},
+		{name: "Scanner failure deletes file", scanErr: errors.New("connection refused"), wantErr: ErrScanFailed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			uploadDir := t.TempDir()
+			quarantineDir := ""
+			if tt.quarantine {
+				quarantineDir = filepath.Join(t.TempDir(), "quarantine")
+			}
+			uploader, _ := NewFileUploader(uploadDir, 1024, []string{".jpg"})
+			if err := uploader.SetScanner(stubScanner{err: tt.scanErr}, quarantineDir); err != nil {
+				t.Fatalf("SetScanner() unexpected error: %v", err)
+			}
+
+			content := []byte("fake image content")
+			_, _, err := uploader.SaveFile(newMockFile(content), &multipart.FileHeader{Filename: "plant.jpg", Size: int64(len(content))})
+			if !errors.Is(err, tt.wantErr) || (tt.wantErr == nil && err != nil) {
+				t.Fatalf("SaveFile() error = %v, expected %v", err, tt.wantErr)
+			}
+
+			kept, _ := os.ReadDir(uploadDir)
+			if tt.wantErr == nil && len(kept) != 1 {
+				t.Errorf("Expected the clean upload to be kept, found %d files", len(kept))
+			}
+			if tt.wantErr != nil && len(kept) != 0 {
+				t.Errorf("Expected the rejected upload to be removed, found %d files", len(kept))
+			}
+			if tt.quarantine {
+				if quarantined, _ := os.ReadDir(quarantineDir); len(quarantined) != 1 {
+					t.Errorf("Expected the infected upload in quarantine, found %d files", len(quarantined))
+				}
+			}
+		})
+	}
+}
+
+func TestCommandScanner(t *testing.T) {
+	if _, err := exec.LookPath("false"); err != nil {
+		t.Skip("true/false commands not available")
+	}
+	path := filepath.Join(t.TempDir(), "plant.jpg")
+	os.WriteFile(path, []byte("fake image content"), 0644)
+
+	clean, _ := NewCommandScanner("true", time.Second)
+	if err := clean.Scan(path); err != nil {
+		t.Errorf("Expected exit status 0 to be clean, got %v", err)
+	}
+	infected, _ := NewCommandScanner("false", time.Second)
+	if err := infected.Scan(path); !errors.Is(err, ErrInfected) {
+		t.Errorf("Expected exit status 1 to be infected, got %v", err)
+	}
+	if _, err := NewCommandScanner("  ", time.Second); err == nil {
+		t.Error("Expected an error for an empty command")
+	}
+}
@@ -0,0 +1,88 @@
+package utils
+
+import (
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// CareCacheMetrics counts care cache lookups during identification, overall
+// and per genus, and renders them in the Prometheus text exposition format
+type CareCacheMetrics struct {
+	mu        sync.Mutex
+	hits      uint64
+	misses    uint64
+	genusHits map[string]uint64
+}
+
+// NewCareCacheMetrics creates empty care cache metrics
+func NewCareCacheMetrics() *CareCacheMetrics {
+	return &CareCacheMetrics{genusHits: map[string]uint64{}}
+}
+
+// RecordHit counts a lookup answered from the cache
+func (m *CareCacheMetrics) RecordHit(genus string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.hits++
+	m.genusHits[strings.ToLower(genus)]++
+}
+
+// RecordMiss counts a lookup that had to generate care
+func (m *CareCacheMetrics) RecordMiss(genus string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.misses++
+}
+
+// HitRatio returns the share of lookups answered from the cache, 0 before any lookup
+func (m *CareCacheMetrics) HitRatio() float64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.hitRatio()
+}
+
+func (m *CareCacheMetrics) hitRatio() float64 {
+	if m.hits+m.misses == 0 {
+		return 0
+	}
+	return float64(m.hits) / float64(m.hits+m.misses)
+}
+
+// WritePrometheus writes the metrics in the Prometheus text exposition format
+func (m *CareCacheMetrics) WritePrometheus(w io.Writer) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var b strings.Builder
+	fmt.Fprintln(&b, "# HELP care_cache_hits_total Care lookups answered from the cache.")
+	fmt.Fprintln(&b, "# TYPE care_cache_hits_total counter")
+	fmt.Fprintf(&b, "care_cache_hits_total %d\n", m.hits)
+	fmt.Fprintln(&b, "# HELP care_cache_misses_total Care lookups not in the cache.")
+	fmt.Fprintln(&b, "# TYPE care_cache_misses_total counter")
+	fmt.Fprintf(&b, "care_cache_misses_total %d\n", m.misses)
+	fmt.Fprintln(&b, "# HELP care_cache_hit_ratio Share of care lookups answered from the cache.")
+	fmt.Fprintln(&b, "# TYPE care_cache_hit_ratio gauge")
+	fmt.Fprintf(&b, "care_cache_hit_ratio %g\n", m.hitRatio())
+	fmt.Fprintln(&b, "# HELP care_cache_genus_hits_total Care lookups answered from the cache, by genus.")
+	fmt.Fprintln(&b, "# TYPE care_cache_genus_hits_total counter")
+
+	genera := make([]string, 0, len(m.genusHits))
+	for genus := range m.genusHits {
+		genera = append(genera, genus)
+	}
+	slices.Sort(genera)
+	for _, genus := range genera {
+		fmt.Fprintf(&b, "care_cache_genus_hits_total{genus=\"%s\"} %d\n", escapeLabelValue(genus), m.genusHits[genus])
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// escapeLabelValue escapes a Prometheus label value
+func escapeLabelValue(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(value)
+}
@@ -0,0 +1,42 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCareCacheMetrics(t *testing.T) {
+	metrics := NewCareCacheMetrics()
+	if ratio := metrics.HitRatio(); ratio != 0 {
+		t.Errorf("Expected ratio 0 before any lookup, got %v", ratio)
+	}
+
+	metrics.RecordHit("haworthia")
+	metrics.RecordHit("Haworthia")
+	metrics.RecordHit("aloe")
+	metrics.RecordMiss("lithops")
+
+	if ratio := metrics.HitRatio(); ratio != 0.75 {
+		t.Errorf("Expected ratio 0.75 after 3 hits and 1 miss, got %v", ratio)
+	}
+
+	var out strings.Builder
+	if err := metrics.WritePrometheus(&out); err != nil {
+		t.Fatalf("WritePrometheus() error: %v", err)
+	}
+	for _, line := range []string{
+		"care_cache_hits_total 3",
+		"care_cache_misses_total 1",
+		"# TYPE care_cache_hit_ratio gauge",
+		"care_cache_hit_ratio 0.75",
+		`care_cache_genus_hits_total{genus="aloe"} 1`,
+		`care_cache_genus_hits_total{genus="haworthia"} 2`,
+	} {
+		if !strings.Contains(out.String(), line+"\n") {
+			t.Errorf("Expected line %q in output:\n%s", line, out.String())
+		}
+	}
+	if strings.Contains(out.String(), `genus="lithops"`) {
+		t.Errorf("Expected no hit counter for a genus without hits:\n%s", out.String())
+	}
+}
@@ -1,7 +1,18 @@
 package utils
 
 import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"log"
 	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"succulent-identifier-backend/models"
 )
 
 // CORSMiddleware adds CORS headers to responses
@@ -24,11 +35,124 @@ func CORSMiddleware(next http.Handler) http.Handler {
 	})
 }
 
-// LoggingMiddleware logs HTTP requests
+// RequestIDHeader carries the request ID between clients, proxies and the server
+const RequestIDHeader = "X-Request-ID"
+
+// requestIDKey is the context key of the request ID
+type requestIDKey struct{}
+
+// RequestIDFromContext returns the request ID set by LoggingMiddleware, or "" if none
+func RequestIDFromContext(ctx context.Context) string {
+	requestID, _ := ctx.Value(requestIDKey{}).(string)
+	return requestID
+}
+
+// basePathKey is the context key of the base path
+type basePathKey struct{}
+
+// BasePathFromContext returns the URL prefix stripped by BasePathMiddleware, or
+// "" if none, so handlers can build links that go through the same prefix
+func BasePathFromContext(ctx context.Context) string {
+	basePath, _ := ctx.Value(basePathKey{}).(string)
+	return basePath
+}
+
+// NormalizeBasePath turns a configured base path such as "api/succulent/"
+// into "/api/succulent"; empty and "/" mean no prefix
+func NormalizeBasePath(basePath string) string {
+	basePath = strings.Trim(strings.TrimSpace(basePath), "/")
+	if basePath == "" {
+		return ""
+	}
+	return "/" + basePath
+}
+
+// BasePathMiddleware serves next under a normalized basePath for deployments
+// behind a path-based reverse proxy: the prefix is stripped before next sees
+// the request, and requests outside it get 404. An empty basePath serves next
+// unchanged.
+func BasePathMiddleware(basePath string, next http.Handler) http.Handler {
+	if basePath == "" {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path, ok := strings.CutPrefix(r.URL.Path, basePath)
+		if !ok || (path != "" && !strings.HasPrefix(path, "/")) {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusNotFound)
+			json.NewEncoder(w).Encode(models.ErrorResponse{
+				Error:   http.StatusText(http.StatusNotFound),
+				Message: fmt.Sprintf("No endpoint at %s %s, the API is served under %s", r.Method, r.URL.Path, basePath),
+			})
+			return
+		}
+		if path == "" {
+			path = "/"
+		}
+
+		stripped := r.WithContext(context.WithValue(r.Context(), basePathKey{}, basePath))
+		stripped.URL = new(url.URL)
+		*stripped.URL = *r.URL
+		stripped.URL.Path = path
+		stripped.URL.RawPath = ""
+		next.ServeHTTP(w, stripped)
+	})
+}
+
+// statusRecorder captures the status code written by a handler
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+// Flush lets streaming handlers flush through the recorder
+func (r *statusRecorder) Flush() {
+	if flusher, ok := r.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// LoggingMiddleware assigns each request an ID (reusing a client-supplied
+// X-Request-ID), exposes it through the request context and response header,
+// and logs the request once it completes
 func LoggingMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		// Log request
-		// You can expand this to include more details
+		requestID := r.Header.Get(RequestIDHeader)
+		if requestID == "" || len(requestID) > 128 {
+			requestID = uuid.New().String()
+		}
+		w.Header().Set(RequestIDHeader, requestID)
+
+		start := time.Now()
+		recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(recorder, r.WithContext(context.WithValue(r.Context(), requestIDKey{}, requestID)))
+
+		log.Printf("request_id=%s method=%s path=%s status=%d duration_ms=%d client_ip=%s",
+			requestID, r.Method, r.URL.Path, recorder.status, time.Since(start).Milliseconds(), ClientIP(r))
+	})
+}
+
+// AdminAuthMiddleware only passes requests carrying "Authorization: Bearer <token>"
+// through to next; anything else is answered with 401
+func AdminAuthMiddleware(token string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		provided, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || token == "" || subtle.ConstantTimeCompare([]byte(provided), []byte(token)) != 1 {
+			w.Header().Set("Content-Type", "application/json")
+			w.Header().Set("WWW-Authenticate", `Bearer realm="admin"`)
+			w.WriteHeader(http.StatusUnauthorized)
+			json.NewEncoder(w).Encode(models.ErrorResponse{
+				Error:   http.StatusText(http.StatusUnauthorized),
+				Message: "Admin token required",
+			})
+			return
+		}
+
 		next.ServeHTTP(w, r)
 	})
 }
@@ -0,0 +1,107 @@
+package utils
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestLoggingMiddlewareRequestID(t *testing.T) {
+	tests := []struct {
+		name     string
+		incoming string
+	}{
+		{name: "Client request ID is propagated", incoming: "client-req-1"},
+		{name: "Request ID is generated when missing", incoming: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var seen string
+			handler := LoggingMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				seen = RequestIDFromContext(r.Context())
+				w.WriteHeader(http.StatusTeapot)
+			}))
+
+			req := httptest.NewRequest(http.MethodGet, "/health", nil)
+			if tt.incoming != "" {
+				req.Header.Set(RequestIDHeader, tt.incoming)
+			}
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+
+			if seen == "" {
+				t.Fatal("Expected request ID in context")
+			}
+			if tt.incoming != "" && seen != tt.incoming {
+				t.Errorf("Request ID = %s, expected %s", seen, tt.incoming)
+			}
+			if rr.Header().Get(RequestIDHeader) != seen {
+				t.Errorf("Response header %s = %s, expected %s", RequestIDHeader, rr.Header().Get(RequestIDHeader), seen)
+			}
+			if rr.Code != http.StatusTeapot {
+				t.Errorf("Expected handler status to pass through, got %d", rr.Code)
+			}
+		})
+	}
+}
+
+func TestNormalizeBasePath(t *testing.T) {
+	tests := map[string]string{
+		"":                "",
+		"/":               "",
+		"api/succulent":   "/api/succulent",
+		"/api/succulent/": "/api/succulent",
+		" /api/ ":         "/api",
+	}
+	for input, expected := range tests {
+		if got := NormalizeBasePath(input); got != expected {
+			t.Errorf("NormalizeBasePath(%q) = %q, expected %q", input, got, expected)
+		}
+	}
+}
+
+func TestBasePathMiddleware(t *testing.T) {
+	var seenPath, seenBasePath string
+	handler := BasePathMiddleware("/api/succulent", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seenPath = r.URL.Path
+		seenBasePath = BasePathFromContext(r.Context())
+	}))
+
+	tests := []struct {
+		path           string
+		expectedStatus int
+		expectedPath   string
+	}{
+		{path: "/api/succulent/health", expectedStatus: http.StatusOK, expectedPath: "/health"},
+		{path: "/api/succulent", expectedStatus: http.StatusOK, expectedPath: "/"},
+		{path: "/health", expectedStatus: http.StatusNotFound},
+		{path: "/api/succulents/health", expectedStatus: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			seenPath, seenBasePath = "", ""
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, tt.path, nil))
+
+			if rr.Code != tt.expectedStatus {
+				t.Fatalf("Expected status %d, got %d", tt.expectedStatus, rr.Code)
+			}
+			if seenPath != tt.expectedPath {
+				t.Errorf("Handler saw path %q, expected %q", seenPath, tt.expectedPath)
+			}
+			if tt.expectedPath != "" && seenBasePath != "/api/succulent" {
+				t.Errorf("Expected base path in context, got %q", seenBasePath)
+			}
+		})
+	}
+
+	// No prefix: the handler is served unchanged
+	unprefixed := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	rr := httptest.NewRecorder()
+	BasePathMiddleware("", unprefixed).ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/health", nil))
+	if rr.Code != http.StatusOK {
+		t.Errorf("Expected status 200 without a base path, got %d", rr.Code)
+	}
+}
@@ -0,0 +1,85 @@
+package utils
+
+import (
+	"fmt"
+	"image"
+	"math/bits"
+	"os"
+)
+
+// dHash grid: 9 columns are compared pairwise to produce 8 bits per row
+const (
+	dHashWidth  = 9
+	dHashHeight = 8
+)
+
+// ComputeImageHash opens an image file and returns its perceptual difference hash
+func ComputeImageHash(path string) (uint64, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, fmt.Errorf("failed to open image: %w", err)
+	}
+	defer file.Close()
+
+	img, _, err := image.Decode(file)
+	if err != nil {
+		return 0, fmt.Errorf("failed to decode image: %w", err)
+	}
+
+	return DHash(img), nil
+}
+
+// DHash computes a 64-bit difference hash of an image.
+// The image is reduced to a 9x8 grayscale grid by block averaging and each bit
+// records whether a cell is brighter than its right-hand neighbour, so small
+// re-compressions or resizes change only a few bits.
+func DHash(img image.Image) uint64 {
+	bounds := img.Bounds()
+	var grid [dHashHeight][dHashWidth]float64
+
+	for gy := 0; gy < dHashHeight; gy++ {
+		y0 := bounds.Min.Y + gy*bounds.Dy()/dHashHeight
+		y1 := bounds.Min.Y + (gy+1)*bounds.Dy()/dHashHeight
+		if y1 <= y0 {
+			y1 = y0 + 1
+		}
+
+		for gx := 0; gx < dHashWidth; gx++ {
+			x0 := bounds.Min.X + gx*bounds.Dx()/dHashWidth
+			x1 := bounds.Min.X + (gx+1)*bounds.Dx()/dHashWidth
+			if x1 <= x0 {
+				x1 = x0 + 1
+			}
+
+			var sum float64
+			var count int
+			for y := y0; y < y1 && y < bounds.Max.Y; y++ {
+				for x := x0; x < x1 && x < bounds.Max.X; x++ {
+					r, g, b, _ := img.At(x, y).RGBA()
+					sum += 0.299*float64(r) + 0.587*float64(g) + 0.114*float64(b)
+					count++
+				}
+			}
+			if count > 0 {
+				grid[gy][gx] = sum / float64(count)
+			}
+		}
+	}
+
+	var hash uint64
+	for gy := 0; gy < dHashHeight; gy++ {
+		for gx := 0; gx < dHashWidth-1; gx++ {
+			hash <<= 1
+			if grid[gy][gx] > grid[gy][gx+1] {
+				hash |= 1
+			}
+		}
+	}
+
+	return hash
+}
+
+// HammingDistance returns the number of differing bits between two hashes
+func HammingDistance(a, b uint64) int {
+	return bits.OnesCount64(a ^ b)
+}
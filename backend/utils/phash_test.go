@@ -0,0 +1,114 @@
+package utils
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// newPatternImage draws a deterministic pattern with distinct light and dark regions
+func newPatternImage(width, height int) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			v := uint8((x*255/width + y*128/height) % 256)
+			if (x/(width/4)+y/(height/4))%2 == 0 {
+				v = 255 - v
+			}
+			img.Set(x, y, color.RGBA{R: v, G: v / 2, B: 255 - v, A: 255})
+		}
+	}
+	return img
+}
+
+func TestDHashSimilarImages(t *testing.T) {
+	original := newPatternImage(200, 160)
+
+	// Slightly modified copy: brighten a little and re-compress as low-quality JPEG
+	modified := image.NewRGBA(original.Bounds())
+	for y := 0; y < 160; y++ {
+		for x := 0; x < 200; x++ {
+			c := original.RGBAAt(x, y)
+			modified.Set(x, y, color.RGBA{
+				R: uint8(min(int(c.R)+6, 255)),
+				G: uint8(min(int(c.G)+6, 255)),
+				B: uint8(min(int(c.B)+6, 255)),
+				A: 255,
+			})
+		}
+	}
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, modified, &jpeg.Options{Quality: 40}); err != nil {
+		t.Fatalf("Failed to encode JPEG: %v", err)
+	}
+	recompressed, err := jpeg.Decode(&buf)
+	if err != nil {
+		t.Fatalf("Failed to decode JPEG: %v", err)
+	}
+
+	// Inverted image is perceptually unrelated
+	inverted := image.NewRGBA(original.Bounds())
+	for y := 0; y < 160; y++ {
+		for x := 0; x < 200; x++ {
+			c := original.RGBAAt(x, y)
+			inverted.Set(x, y, color.RGBA{R: 255 - c.R, G: 255 - c.G, B: 255 - c.B, A: 255})
+		}
+	}
+
+	originalHash := DHash(original)
+	threshold := 10
+
+	if d := HammingDistance(originalHash, DHash(recompressed)); d > threshold {
+		t.Errorf("Expected modified copy within distance %d, got %d", threshold, d)
+	}
+
+	if d := HammingDistance(originalHash, DHash(inverted)); d <= threshold {
+		t.Errorf("Expected inverted image beyond distance %d, got %d", threshold, d)
+	}
+}
+
+func TestComputeImageHash(t *testing.T) {
+	dir := t.TempDir()
+
+	pngPath := filepath.Join(dir, "plant.png")
+	var buf bytes.Buffer
+	png.Encode(&buf, newPatternImage(64, 48))
+	os.WriteFile(pngPath, buf.Bytes(), 0644)
+
+	hash, err := ComputeImageHash(pngPath)
+	if err != nil {
+		t.Fatalf("ComputeImageHash() unexpected error: %v", err)
+	}
+	if hash != DHash(newPatternImage(64, 48)) {
+		t.Error("ComputeImageHash() does not match DHash of the same image")
+	}
+
+	badPath := filepath.Join(dir, "fake.jpg")
+	os.WriteFile(badPath, []byte("fake image content"), 0644)
+	if _, err := ComputeImageHash(badPath); err == nil {
+		t.Error("ComputeImageHash() expected error for undecodable file")
+	}
+}
+
+func TestHammingDistance(t *testing.T) {
+	tests := []struct {
+		a, b     uint64
+		expected int
+	}{
+		{a: 0, b: 0, expected: 0},
+		{a: 0, b: 1, expected: 1},
+		{a: 0xFF, b: 0x0F, expected: 4},
+		{a: 0, b: ^uint64(0), expected: 64},
+	}
+
+	for _, tt := range tests {
+		if d := HammingDistance(tt.a, tt.b); d != tt.expected {
+			t.Errorf("HammingDistance(%x, %x) = %d, expected %d", tt.a, tt.b, d, tt.expected)
+		}
+	}
+}
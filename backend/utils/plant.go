@@ -4,10 +4,14 @@ import (
 	"strings"
 )
 
+// DefaultLabelDelimiter separates genus and species in model labels
+const DefaultLabelDelimiter = "_"
+
 // ParseLabel extracts genus and species from a label
-// Label format: "genus_species" (e.g., "echeveria_elegans")
-func ParseLabel(label string) (genus string, species string) {
-	parts := strings.Split(label, "_")
+// Label format: "genus<delimiter>species" (e.g., "echeveria_elegans")
+// An empty delimiter falls back to DefaultLabelDelimiter
+func ParseLabel(label, delimiter string) (genus string, species string) {
+	parts := strings.Split(label, labelDelimiter(delimiter))
 
 	if len(parts) >= 1 {
 		genus = parts[0]
@@ -20,6 +24,43 @@ func ParseLabel(label string) (genus string, species string) {
 	return genus, species
 }
 
+// AllSingleTokenSpecies in a single-token species list treats every label
+// without a delimiter as a full species name
+const AllSingleTokenSpecies = "*"
+
+// IsSingleTokenSpecies reports whether a label without a delimiter names a
+// full species rather than a genus, because speciesLabels contains the label
+// (case-insensitively) or AllSingleTokenSpecies. Labels with a delimiter
+// always report false.
+func IsSingleTokenSpecies(label, delimiter string, speciesLabels []string) bool {
+	label = strings.TrimSpace(label)
+	if label == "" || strings.Contains(label, labelDelimiter(delimiter)) {
+		return false
+	}
+	for _, speciesLabel := range speciesLabels {
+		speciesLabel = strings.TrimSpace(speciesLabel)
+		if speciesLabel == AllSingleTokenSpecies || strings.EqualFold(speciesLabel, label) {
+			return true
+		}
+	}
+	return false
+}
+
+// CareCacheKey returns the canonical (genus, species epithet) form used to key
+// cached care instructions, so a plant maps to one entry whether the caller
+// passes the full label ("echeveria_elegans") or just the epithet ("elegans")
+func CareCacheKey(genus, species, delimiter string) (string, string) {
+	genus = strings.ToLower(strings.TrimSpace(genus))
+	species = strings.ToLower(strings.TrimSpace(species))
+
+	if species == genus {
+		return genus, ""
+	}
+	species = strings.TrimPrefix(species, genus+labelDelimiter(delimiter))
+
+	return genus, species
+}
+
 // FormatGenus formats genus name for display (capitalize first letter)
 func FormatGenus(genus string) string {
 	if genus == "" {
@@ -29,9 +70,9 @@ func FormatGenus(genus string) string {
 }
 
 // FormatSpecies formats species name for display
-// Converts "genus_species" to "Genus species"
-func FormatSpecies(label string) string {
-	parts := strings.Split(label, "_")
+// Converts "genus<delimiter>species" to "Genus species"
+func FormatSpecies(label, delimiter string) string {
+	parts := strings.Split(label, labelDelimiter(delimiter))
 	if len(parts) < 2 {
 		return ""
 	}
@@ -41,3 +82,22 @@ func FormatSpecies(label string) string {
 
 	return genus + " " + species
 }
+
+// SpeciesEpithet returns the specific epithet of a label
+// Converts "genus<delimiter>species" to "species", or "" for a genus-only label
+func SpeciesEpithet(label, delimiter string) string {
+	parts := strings.Split(label, labelDelimiter(delimiter))
+	if len(parts) < 2 {
+		return ""
+	}
+
+	return strings.Join(parts[1:], " ")
+}
+
+// labelDelimiter returns the delimiter to use, defaulting when empty
+func labelDelimiter(delimiter string) string {
+	if delimiter == "" {
+		return DefaultLabelDelimiter
+	}
+	return delimiter
+}
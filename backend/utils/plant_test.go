@@ -39,7 +39,7 @@ func TestParseLabel(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			genus, species := ParseLabel(tt.label)
+			genus, species := ParseLabel(tt.label, "_")
 
 			if genus != tt.expectedGenus {
 				t.Errorf("ParseLabel() genus = %v, expected %v", genus, tt.expectedGenus)
@@ -120,10 +120,156 @@ func TestFormatSpecies(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			result := FormatSpecies(tt.label)
+			result := FormatSpecies(tt.label, "_")
 			if result != tt.expected {
 				t.Errorf("FormatSpecies() = %v, expected %v", result, tt.expected)
 			}
 		})
 	}
 }
+
+func TestParseLabelDelimiters(t *testing.T) {
+	tests := []struct {
+		name                   string
+		label                  string
+		delimiter              string
+		expectedGenus          string
+		expectedSpecies        string
+		expectedDisplaySpecies string
+	}{
+		{
+			name:                   "Hyphen-delimited label",
+			label:                  "echeveria-elegans",
+			delimiter:              "-",
+			expectedGenus:          "echeveria",
+			expectedSpecies:        "echeveria-elegans",
+			expectedDisplaySpecies: "Echeveria elegans",
+		},
+		{
+			name:                   "Space-delimited label",
+			label:                  "haworthia zebra plant",
+			delimiter:              " ",
+			expectedGenus:          "haworthia",
+			expectedSpecies:        "haworthia zebra plant",
+			expectedDisplaySpecies: "Haworthia zebra plant",
+		},
+		{
+			name:                   "Hyphen-delimited genus only",
+			label:                  "aloe",
+			delimiter:              "-",
+			expectedGenus:          "aloe",
+			expectedSpecies:        "",
+			expectedDisplaySpecies: "",
+		},
+		{
+			name:                   "Underscores are not split with hyphen delimiter",
+			label:                  "echeveria_elegans",
+			delimiter:              "-",
+			expectedGenus:          "echeveria_elegans",
+			expectedSpecies:        "",
+			expectedDisplaySpecies: "",
+		},
+		{
+			name:                   "Empty delimiter defaults to underscore",
+			label:                  "echeveria_elegans",
+			delimiter:              "",
+			expectedGenus:          "echeveria",
+			expectedSpecies:        "echeveria_elegans",
+			expectedDisplaySpecies: "Echeveria elegans",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			genus, species := ParseLabel(tt.label, tt.delimiter)
+
+			if genus != tt.expectedGenus {
+				t.Errorf("ParseLabel() genus = %v, expected %v", genus, tt.expectedGenus)
+			}
+
+			if species != tt.expectedSpecies {
+				t.Errorf("ParseLabel() species = %v, expected %v", species, tt.expectedSpecies)
+			}
+
+			if display := FormatSpecies(tt.label, tt.delimiter); display != tt.expectedDisplaySpecies {
+				t.Errorf("FormatSpecies() = %v, expected %v", display, tt.expectedDisplaySpecies)
+			}
+		})
+	}
+}
+
+func TestCareCacheKey(t *testing.T) {
+	tests := []struct {
+		name            string
+		genus           string
+		species         string
+		delimiter       string
+		expectedGenus   string
+		expectedSpecies string
+	}{
+		{name: "Full label", genus: "echeveria", species: "echeveria_elegans", delimiter: "_", expectedGenus: "echeveria", expectedSpecies: "elegans"},
+		{name: "Epithet", genus: "echeveria", species: "elegans", delimiter: "_", expectedGenus: "echeveria", expectedSpecies: "elegans"},
+		{name: "Mixed case", genus: "Echeveria", species: "Echeveria_Elegans", delimiter: "_", expectedGenus: "echeveria", expectedSpecies: "elegans"},
+		{name: "Multi-word epithet", genus: "haworthia", species: "haworthia_zebra_plant", delimiter: "_", expectedGenus: "haworthia", expectedSpecies: "zebra_plant"},
+		{name: "Custom delimiter", genus: "aloe", species: "aloe-vera", delimiter: "-", expectedGenus: "aloe", expectedSpecies: "vera"},
+		{name: "Genus only", genus: "sedum", species: "", delimiter: "_", expectedGenus: "sedum", expectedSpecies: ""},
+		{name: "Species equal to genus", genus: "sedum", species: "sedum", delimiter: "_", expectedGenus: "sedum", expectedSpecies: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			genus, species := CareCacheKey(tt.genus, tt.species, tt.delimiter)
+			if genus != tt.expectedGenus || species != tt.expectedSpecies {
+				t.Errorf("CareCacheKey() = (%q, %q), expected (%q, %q)", genus, species, tt.expectedGenus, tt.expectedSpecies)
+			}
+		})
+	}
+}
+
+func TestSpeciesEpithet(t *testing.T) {
+	tests := []struct {
+		name      string
+		label     string
+		delimiter string
+		expected  string
+	}{
+		{name: "Genus and species", label: "echeveria_elegans", delimiter: "_", expected: "elegans"},
+		{name: "Multi-word epithet", label: "haworthia_zebra_plant", delimiter: "_", expected: "zebra plant"},
+		{name: "Custom delimiter", label: "aloe-vera", delimiter: "-", expected: "vera"},
+		{name: "Genus only", label: "echeveria", delimiter: "_", expected: ""},
+		{name: "Empty label", label: "", delimiter: "_", expected: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := SpeciesEpithet(tt.label, tt.delimiter); got != tt.expected {
+				t.Errorf("SpeciesEpithet(%q) = %q, expected %q", tt.label, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestIsSingleTokenSpecies(t *testing.T) {
+	tests := []struct {
+		name          string
+		label         string
+		speciesLabels []string
+		expected      bool
+	}{
+		{name: "Not configured", label: "echeveria", speciesLabels: nil, expected: false},
+		{name: "Listed label", label: "lithops", speciesLabels: []string{"lithops"}, expected: true},
+		{name: "Listed case-insensitively", label: "Lithops", speciesLabels: []string{"lithops"}, expected: true},
+		{name: "Unlisted label", label: "echeveria", speciesLabels: []string{"lithops"}, expected: false},
+		{name: "Wildcard", label: "echeveria", speciesLabels: []string{"*"}, expected: true},
+		{name: "Delimited label", label: "echeveria_elegans", speciesLabels: []string{"*"}, expected: false},
+		{name: "Empty label", label: "", speciesLabels: []string{"*"}, expected: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsSingleTokenSpecies(tt.label, "_", tt.speciesLabels); got != tt.expected {
+				t.Errorf("IsSingleTokenSpecies(%q, %v) = %v, expected %v", tt.label, tt.speciesLabels, got, tt.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,160 @@
+package utils
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// TrustedProxies is the set of proxy addresses whose X-Forwarded-* headers are honoured
+type TrustedProxies struct {
+	networks []*net.IPNet
+}
+
+// ParseTrustedProxies parses a comma-separated list of IPs and CIDR ranges
+func ParseTrustedProxies(spec string) (*TrustedProxies, error) {
+	proxies := &TrustedProxies{}
+
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		if !strings.Contains(entry, "/") {
+			ip := net.ParseIP(entry)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid trusted proxy address: %s", entry)
+			}
+			bits := 128
+			if ip.To4() != nil {
+				ip = ip.To4()
+				bits = 32
+			}
+			proxies.networks = append(proxies.networks, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+
+		_, network, err := net.ParseCIDR(entry)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy range: %s", entry)
+		}
+		proxies.networks = append(proxies.networks, network)
+	}
+
+	return proxies, nil
+}
+
+// Trusts reports whether the given IP belongs to a trusted proxy
+func (p *TrustedProxies) Trusts(ip net.IP) bool {
+	if p == nil || ip == nil {
+		return false
+	}
+	for _, network := range p.networks {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// ClientIP returns the real client IP of a request. X-Forwarded-For is read
+// right to left, skipping trusted proxies, so entries prepended by the client
+// itself are never believed.
+func (p *TrustedProxies) ClientIP(r *http.Request) string {
+	remote := hostOnly(r.RemoteAddr)
+	if !p.Trusts(net.ParseIP(remote)) {
+		return remote
+	}
+
+	hops := strings.Split(r.Header.Get("X-Forwarded-For"), ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := strings.TrimSpace(hops[i])
+		ip := net.ParseIP(hop)
+		if ip == nil {
+			// Garbage in the chain: stop and fall back to the last trusted hop
+			break
+		}
+		remote = hop
+		if !p.Trusts(ip) {
+			break
+		}
+	}
+
+	return remote
+}
+
+// Scheme returns the scheme the client used, honouring X-Forwarded-Proto from trusted proxies
+func (p *TrustedProxies) Scheme(r *http.Request) string {
+	if p.Trusts(net.ParseIP(hostOnly(r.RemoteAddr))) {
+		if proto := strings.ToLower(firstForwardedValue(r.Header.Get("X-Forwarded-Proto"))); proto == "http" || proto == "https" {
+			return proto
+		}
+	}
+	if r.TLS != nil {
+		return "https"
+	}
+	return "http"
+}
+
+// Host returns the host the client requested, honouring X-Forwarded-Host from trusted proxies
+func (p *TrustedProxies) Host(r *http.Request) string {
+	if p.Trusts(net.ParseIP(hostOnly(r.RemoteAddr))) {
+		if host := firstForwardedValue(r.Header.Get("X-Forwarded-Host")); host != "" {
+			return host
+		}
+	}
+	return r.Host
+}
+
+// ForwardedHeadersMiddleware rewrites RemoteAddr, Host and URL.Scheme to the
+// client-facing values so handlers never need to inspect X-Forwarded-* headers.
+// Headers sent by untrusted peers are ignored.
+func ForwardedHeadersMiddleware(proxies *TrustedProxies, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		scheme := proxies.Scheme(r)
+		host := proxies.Host(r)
+		clientIP := proxies.ClientIP(r)
+
+		r = r.Clone(r.Context())
+		r.RemoteAddr = clientIP
+		r.Host = host
+		r.URL.Scheme = scheme
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// ClientIP returns the client IP of a request without its port
+func ClientIP(r *http.Request) string {
+	return hostOnly(r.RemoteAddr)
+}
+
+// RequestBaseURL returns scheme://host for a request, e.g. for building absolute links
+func RequestBaseURL(r *http.Request) string {
+	scheme := r.URL.Scheme
+	if scheme == "" {
+		scheme = "http"
+		if r.TLS != nil {
+			scheme = "https"
+		}
+	}
+	return scheme + "://" + r.Host
+}
+
+// hostOnly strips the port from an address if present
+func hostOnly(addr string) string {
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		return host
+	}
+	return addr
+}
+
+// firstForwardedValue returns the first entry of a comma-separated forwarded header
+func firstForwardedValue(value string) string {
+	if i := strings.Index(value, ","); i >= 0 {
+		value = value[:i]
+	}
+	return strings.TrimSpace(value)
+}
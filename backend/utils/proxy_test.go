@@ -0,0 +1,113 @@
+package utils
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestParseTrustedProxies(t *testing.T) {
+	if _, err := ParseTrustedProxies("10.0.0.0/8, 127.0.0.1,::1"); err != nil {
+		t.Fatalf("ParseTrustedProxies() unexpected error: %v", err)
+	}
+	if _, err := ParseTrustedProxies("not-an-ip"); err == nil {
+		t.Error("ParseTrustedProxies() expected error for invalid address")
+	}
+	if _, err := ParseTrustedProxies("10.0.0.0/99"); err == nil {
+		t.Error("ParseTrustedProxies() expected error for invalid range")
+	}
+
+	empty, _ := ParseTrustedProxies("")
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.RemoteAddr = "10.0.0.1:1234"
+	req.Header.Set("X-Forwarded-For", "1.2.3.4")
+	if ip := empty.ClientIP(req); ip != "10.0.0.1" {
+		t.Errorf("ClientIP() with no trusted proxies = %s, expected 10.0.0.1", ip)
+	}
+}
+
+func TestForwardedHeadersMiddleware(t *testing.T) {
+	proxies, err := ParseTrustedProxies("10.0.0.0/8")
+	if err != nil {
+		t.Fatalf("ParseTrustedProxies() unexpected error: %v", err)
+	}
+
+	tests := []struct {
+		name           string
+		remoteAddr     string
+		headers        map[string]string
+		expectedIP     string
+		expectedScheme string
+		expectedHost   string
+	}{
+		{
+			name:       "Trusted proxy forwards client details",
+			remoteAddr: "10.1.2.3:5000",
+			headers: map[string]string{
+				"X-Forwarded-For":   "203.0.113.7",
+				"X-Forwarded-Proto": "https",
+				"X-Forwarded-Host":  "plants.example.com",
+			},
+			expectedIP:     "203.0.113.7",
+			expectedScheme: "https",
+			expectedHost:   "plants.example.com",
+		},
+		{
+			name:       "Spoofed headers from untrusted client are ignored",
+			remoteAddr: "198.51.100.9:4000",
+			headers: map[string]string{
+				"X-Forwarded-For":   "203.0.113.7",
+				"X-Forwarded-Proto": "https",
+				"X-Forwarded-Host":  "evil.example.com",
+			},
+			expectedIP:     "198.51.100.9",
+			expectedScheme: "http",
+			expectedHost:   "backend.local",
+		},
+		{
+			name:       "Client-prepended entries behind trusted proxies are skipped",
+			remoteAddr: "10.0.0.2:5000",
+			headers: map[string]string{
+				"X-Forwarded-For": "6.6.6.6, 203.0.113.7, 10.0.0.5",
+			},
+			expectedIP:     "203.0.113.7",
+			expectedScheme: "http",
+			expectedHost:   "backend.local",
+		},
+		{
+			name:       "Invalid forwarded proto is ignored",
+			remoteAddr: "10.0.0.2:5000",
+			headers: map[string]string{
+				"X-Forwarded-Proto": "javascript",
+			},
+			expectedIP:     "10.0.0.2",
+			expectedScheme: "http",
+			expectedHost:   "backend.local",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var gotIP, gotBaseURL string
+			handler := ForwardedHeadersMiddleware(proxies, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				gotIP = ClientIP(r)
+				gotBaseURL = RequestBaseURL(r)
+			}))
+
+			req := httptest.NewRequest(http.MethodGet, "/history", nil)
+			req.Host = "backend.local"
+			req.RemoteAddr = tt.remoteAddr
+			for key, value := range tt.headers {
+				req.Header.Set(key, value)
+			}
+			handler.ServeHTTP(httptest.NewRecorder(), req)
+
+			if gotIP != tt.expectedIP {
+				t.Errorf("ClientIP() = %s, expected %s", gotIP, tt.expectedIP)
+			}
+			if expected := tt.expectedScheme + "://" + tt.expectedHost; gotBaseURL != expected {
+				t.Errorf("RequestBaseURL() = %s, expected %s", gotBaseURL, expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,145 @@
+package utils
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// ErrInfected is returned when a scanner reports a saved upload as infected
+var ErrInfected = errors.New("file rejected by virus scan")
+
+// ErrScanFailed is returned when a saved upload could not be scanned
+var ErrScanFailed = errors.New("virus scan unavailable")
+
+// FileScanner checks a saved file for malware. Scan returns an error
+// wrapping ErrInfected for infected files and any other error when the
+// file could not be scanned.
+type FileScanner interface {
+	Scan(path string) error
+}
+
+// clamdChunkSize is the size of the chunks streamed to clamd
+const clamdChunkSize = 64 << 10
+
+// ClamdScanner scans files by streaming them to a clamd daemon (INSTREAM)
+type ClamdScanner struct {
+	address string
+	timeout time.Duration
+}
+
+// NewClamdScanner creates a scanner for the clamd daemon at address, either
+// host:port or the path of a unix socket
+func NewClamdScanner(address string, timeout time.Duration) *ClamdScanner {
+	return &ClamdScanner{address: address, timeout: timeout}
+}
+
+// Scan streams the file to clamd and interprets its verdict
+func (s *ClamdScanner) Scan(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open file for scanning: %w", err)
+	}
+	defer file.Close()
+
+	network := "tcp"
+	if strings.HasPrefix(s.address, "/") {
+		network = "unix"
+	}
+	conn, err := net.DialTimeout(network, s.address, s.timeout)
+	if err != nil {
+		return fmt.Errorf("failed to connect to clamd: %w", err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(s.timeout))
+
+	if _, err := conn.Write([]byte("zINSTREAM\x00")); err != nil {
+		return fmt.Errorf("failed to send clamd command: %w", err)
+	}
+
+	// Each chunk is prefixed with its length; a zero length ends the stream
+	buf := make([]byte, clamdChunkSize)
+	size := make([]byte, 4)
+	for {
+		n, err := file.Read(buf)
+		if n > 0 {
+			binary.BigEndian.PutUint32(size, uint32(n))
+			if _, err := conn.Write(append(size, buf[:n]...)); err != nil {
+				return fmt.Errorf("failed to stream file to clamd: %w", err)
+			}
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read file for scanning: %w", err)
+		}
+	}
+	if _, err := conn.Write([]byte{0, 0, 0, 0}); err != nil {
+		return fmt.Errorf("failed to stream file to clamd: %w", err)
+	}
+
+	reply, err := bufio.NewReader(conn).ReadString(0)
+	if err != nil && reply == "" {
+		return fmt.Errorf("failed to read clamd reply: %w", err)
+	}
+	reply = strings.TrimRight(reply, "\x00\n")
+
+	// Replies look like "stream: OK" or "stream: Eicar-Signature FOUND"
+	switch {
+	case strings.HasSuffix(reply, " OK"):
+		return nil
+	case strings.HasSuffix(reply, " FOUND"):
+		signature := strings.TrimSuffix(strings.TrimPrefix(reply, "stream: "), " FOUND")
+		return fmt.Errorf("%w: %s", ErrInfected, signature)
+	default:
+		return fmt.Errorf("unexpected clamd reply %q", reply)
+	}
+}
+
+// CommandScanner scans files by running an external command with the file
+// path as its last argument. Following clamscan, exit status 0 means clean
+// and 1 means infected; anything else is a scan failure.
+type CommandScanner struct {
+	command []string
+	timeout time.Duration
+}
+
+// NewCommandScanner creates a scanner running command, split on whitespace
+func NewCommandScanner(command string, timeout time.Duration) (*CommandScanner, error) {
+	fields := strings.Fields(command)
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("scan command is empty")
+	}
+	return &CommandScanner{command: fields, timeout: timeout}, nil
+}
+
+// Scan runs the command on the file and interprets its exit status
+func (s *CommandScanner) Scan(path string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), s.timeout)
+	defer cancel()
+
+	args := append(append([]string{}, s.command[1:]...), path)
+	output, err := exec.CommandContext(ctx, s.command[0], args...).CombinedOutput()
+	if ctx.Err() != nil {
+		return fmt.Errorf("scan command timed out after %s", s.timeout)
+	}
+
+	var exitErr *exec.ExitError
+	switch {
+	case err == nil:
+		return nil
+	case errors.As(err, &exitErr) && exitErr.ExitCode() == 1:
+		return fmt.Errorf("%w: %s", ErrInfected, strings.TrimSpace(string(output)))
+	default:
+		return fmt.Errorf("scan command failed: %w", err)
+	}
+}
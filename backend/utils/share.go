@@ -0,0 +1,77 @@
+package utils
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Share token validation errors
+var (
+	ErrShareTokenInvalid = errors.New("invalid share token")
+	ErrShareTokenExpired = errors.New("share token expired")
+)
+
+// shareClaims is the signed payload of a share token
+type shareClaims struct {
+	ID        string `json:"id"`
+	ExpiresAt int64  `json:"exp"` // Unix seconds
+}
+
+// GenerateShareToken creates a signed token granting read-only access to an
+// identification until expiresAt. The token is "<payload>.<signature>", both
+// base64url-encoded, where the signature is an HMAC-SHA256 of the payload.
+func GenerateShareToken(secret []byte, id string, expiresAt time.Time) (string, error) {
+	if len(secret) == 0 {
+		return "", fmt.Errorf("share secret is empty")
+	}
+
+	payload, err := json.Marshal(shareClaims{ID: id, ExpiresAt: expiresAt.Unix()})
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal share claims: %w", err)
+	}
+
+	encoded := base64.RawURLEncoding.EncodeToString(payload)
+	return encoded + "." + signShareToken(secret, encoded), nil
+}
+
+// ValidateShareToken verifies a share token's signature and expiry and
+// returns the identification ID it grants access to
+func ValidateShareToken(secret []byte, token string, now time.Time) (string, error) {
+	encoded, signature, ok := strings.Cut(token, ".")
+	if !ok || len(secret) == 0 {
+		return "", ErrShareTokenInvalid
+	}
+
+	if !hmac.Equal([]byte(signature), []byte(signShareToken(secret, encoded))) {
+		return "", ErrShareTokenInvalid
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", ErrShareTokenInvalid
+	}
+
+	var claims shareClaims
+	if err := json.Unmarshal(payload, &claims); err != nil || claims.ID == "" {
+		return "", ErrShareTokenInvalid
+	}
+
+	if now.Unix() >= claims.ExpiresAt {
+		return "", ErrShareTokenExpired
+	}
+
+	return claims.ID, nil
+}
+
+// signShareToken returns the base64url HMAC-SHA256 signature of a token payload
+func signShareToken(secret []byte, encodedPayload string) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(encodedPayload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
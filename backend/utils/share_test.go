@@ -0,0 +1,98 @@
+package utils
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestShareToken(t *testing.T) {
+	secret := []byte("test-share-secret")
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	token, err := GenerateShareToken(secret, "plant-id-1", now.Add(time.Hour))
+	if err != nil {
+		t.Fatalf("GenerateShareToken() unexpected error: %v", err)
+	}
+
+	tampered := strings.Replace(token, token[:4], "AAAA", 1)
+
+	tests := []struct {
+		name        string
+		secret      []byte
+		token       string
+		now         time.Time
+		expectedID  string
+		expectedErr error
+	}{
+		{
+			name:       "Valid token",
+			secret:     secret,
+			token:      token,
+			now:        now,
+			expectedID: "plant-id-1",
+		},
+		{
+			name:       "Valid just before expiry",
+			secret:     secret,
+			token:      token,
+			now:        now.Add(time.Hour - time.Second),
+			expectedID: "plant-id-1",
+		},
+		{
+			name:        "Expired token",
+			secret:      secret,
+			token:       token,
+			now:         now.Add(time.Hour),
+			expectedErr: ErrShareTokenExpired,
+		},
+		{
+			name:        "Wrong secret",
+			secret:      []byte("other-secret"),
+			token:       token,
+			now:         now,
+			expectedErr: ErrShareTokenInvalid,
+		},
+		{
+			name:        "Tampered payload",
+			secret:      secret,
+			token:       tampered,
+			now:         now,
+			expectedErr: ErrShareTokenInvalid,
+		},
+		{
+			name:        "Malformed token",
+			secret:      secret,
+			token:       "not-a-token",
+			now:         now,
+			expectedErr: ErrShareTokenInvalid,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			id, err := ValidateShareToken(tt.secret, tt.token, tt.now)
+
+			if tt.expectedErr != nil {
+				if !errors.Is(err, tt.expectedErr) {
+					t.Errorf("ValidateShareToken() error = %v, expected %v", err, tt.expectedErr)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("ValidateShareToken() unexpected error: %v", err)
+			}
+			if id != tt.expectedID {
+				t.Errorf("ValidateShareToken() id = %s, expected %s", id, tt.expectedID)
+			}
+		})
+	}
+}
+
+func TestGenerateShareTokenEmptySecret(t *testing.T) {
+	if _, err := GenerateShareToken(nil, "plant-id-1", time.Now().Add(time.Hour)); err == nil {
+		t.Error("GenerateShareToken() expected error for empty secret")
+	}
+}
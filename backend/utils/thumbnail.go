@@ -0,0 +1,56 @@
+package utils
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"os"
+)
+
+// thumbnailQuality is the JPEG quality used for generated thumbnails
+const thumbnailQuality = 80
+
+// GenerateThumbnail decodes an image file and returns a JPEG thumbnail whose
+// longest side is at most maxSize pixels. Smaller images are re-encoded as-is.
+func GenerateThumbnail(path string, maxSize int) ([]byte, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open image: %w", err)
+	}
+	defer file.Close()
+
+	src, _, err := image.Decode(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode image: %w", err)
+	}
+
+	bounds := src.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	if width > maxSize || height > maxSize {
+		if width >= height {
+			height = max(height*maxSize/width, 1)
+			width = maxSize
+		} else {
+			width = max(width*maxSize/height, 1)
+			height = maxSize
+		}
+	}
+
+	// Nearest-neighbour sampling is good enough for a small preview
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		sy := bounds.Min.Y + y*bounds.Dy()/height
+		for x := 0; x < width; x++ {
+			sx := bounds.Min.X + x*bounds.Dx()/width
+			dst.Set(x, y, src.At(sx, sy))
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, dst, &jpeg.Options{Quality: thumbnailQuality}); err != nil {
+		return nil, fmt.Errorf("failed to encode thumbnail: %w", err)
+	}
+
+	return buf.Bytes(), nil
+}
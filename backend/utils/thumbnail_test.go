@@ -0,0 +1,55 @@
+package utils
+
+import (
+	"bytes"
+	"image/jpeg"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestGenerateThumbnail(t *testing.T) {
+	dir := t.TempDir()
+
+	tests := []struct {
+		name           string
+		width, height  int
+		maxSize        int
+		expectedWidth  int
+		expectedHeight int
+	}{
+		{name: "Landscape is scaled down", width: 400, height: 200, maxSize: 100, expectedWidth: 100, expectedHeight: 50},
+		{name: "Portrait is scaled down", width: 150, height: 300, maxSize: 100, expectedWidth: 50, expectedHeight: 100},
+		{name: "Small image keeps its size", width: 64, height: 48, maxSize: 100, expectedWidth: 64, expectedHeight: 48},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(dir, tt.name+".png")
+			var buf bytes.Buffer
+			png.Encode(&buf, newPatternImage(tt.width, tt.height))
+			os.WriteFile(path, buf.Bytes(), 0644)
+
+			thumbnail, err := GenerateThumbnail(path, tt.maxSize)
+			if err != nil {
+				t.Fatalf("GenerateThumbnail() unexpected error: %v", err)
+			}
+
+			config, err := jpeg.DecodeConfig(bytes.NewReader(thumbnail))
+			if err != nil {
+				t.Fatalf("Thumbnail is not a valid JPEG: %v", err)
+			}
+			if config.Width != tt.expectedWidth || config.Height != tt.expectedHeight {
+				t.Errorf("Thumbnail size = %dx%d, expected %dx%d",
+					config.Width, config.Height, tt.expectedWidth, tt.expectedHeight)
+			}
+		})
+	}
+
+	badPath := filepath.Join(dir, "fake.jpg")
+	os.WriteFile(badPath, []byte("fake image content"), 0644)
+	if _, err := GenerateThumbnail(badPath, 100); err == nil {
+		t.Error("GenerateThumbnail() expected error for undecodable file")
+	}
+}
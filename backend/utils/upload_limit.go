@@ -0,0 +1,77 @@
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+
+	"succulent-identifier-backend/models"
+)
+
+// UploadLimiter caps how many uploads a single client IP can have in flight
+// at once, so one client cannot monopolize upload processing
+type UploadLimiter struct {
+	mu       sync.Mutex
+	maxPerIP int
+	inFlight map[string]int // client IP -> uploads being processed
+}
+
+// NewUploadLimiter creates a limiter allowing maxPerIP concurrent uploads per IP
+func NewUploadLimiter(maxPerIP int) *UploadLimiter {
+	if maxPerIP < 1 {
+		maxPerIP = 1
+	}
+	return &UploadLimiter{maxPerIP: maxPerIP, inFlight: map[string]int{}}
+}
+
+// Acquire reserves an upload slot for ip, reporting false when it has none left
+func (l *UploadLimiter) Acquire(ip string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.inFlight[ip] >= l.maxPerIP {
+		return false
+	}
+	l.inFlight[ip]++
+	return true
+}
+
+// Release frees a slot reserved by Acquire
+func (l *UploadLimiter) Release(ip string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.inFlight[ip] <= 1 {
+		delete(l.inFlight, ip) // keep the map from growing with every client seen
+		return
+	}
+	l.inFlight[ip]--
+}
+
+// InFlight returns the number of uploads ip has in flight
+func (l *UploadLimiter) InFlight(ip string) int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.inFlight[ip]
+}
+
+// UploadLimitMiddleware rejects a request with 429 while its client IP already
+// has the maximum number of uploads in flight. It relies on
+// ForwardedHeadersMiddleware for the client IP behind proxies.
+func UploadLimitMiddleware(limiter *UploadLimiter, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ip := ClientIP(r)
+		if !limiter.Acquire(ip) {
+			w.Header().Set("Content-Type", "application/json")
+			w.Header().Set("Retry-After", "1")
+			w.WriteHeader(http.StatusTooManyRequests)
+			json.NewEncoder(w).Encode(models.ErrorResponse{
+				Error:   http.StatusText(http.StatusTooManyRequests),
+				Message: fmt.Sprintf("At most %d uploads per client can be processed at once, please retry when one finishes", limiter.maxPerIP),
+			})
+			return
+		}
+		defer limiter.Release(ip)
+
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,71 @@
+package utils
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+func TestUploadLimitMiddleware(t *testing.T) {
+	limiter := NewUploadLimiter(2)
+	started := make(chan struct{}, 10)
+	release := make(chan struct{})
+	handler := UploadLimitMiddleware(limiter, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		started <- struct{}{}
+		<-release
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	upload := func(remoteAddr string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/identify", nil)
+		req.RemoteAddr = remoteAddr
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr
+	}
+
+	// Fill the cap of one client with uploads that stay in flight
+	var wg sync.WaitGroup
+	codes := make([]int, 3)
+	for i, remoteAddr := range []string{"203.0.113.7:5000", "203.0.113.7:5001"} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			codes[i] = upload(remoteAddr).Code
+		}()
+	}
+	<-started
+	<-started
+
+	rr := upload("203.0.113.7:5002")
+	if rr.Code != http.StatusTooManyRequests {
+		t.Errorf("Expected status 429 past the cap, got %d", rr.Code)
+	}
+	if rr.Header().Get("Retry-After") == "" {
+		t.Error("Expected a Retry-After header on 429")
+	}
+
+	// Other clients are not affected
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		codes[2] = upload("198.51.100.2:5000").Code
+	}()
+	<-started
+
+	close(release)
+	wg.Wait()
+	for i, code := range codes {
+		if code != http.StatusOK {
+			t.Errorf("Upload %d: expected status 200, got %d", i, code)
+		}
+	}
+	if n := limiter.InFlight("203.0.113.7"); n != 0 {
+		t.Errorf("Expected no uploads in flight after they finished, got %d", n)
+	}
+
+	if rr := upload("203.0.113.7:5003"); rr.Code != http.StatusOK {
+		t.Errorf("Expected status 200 once slots were released, got %d", rr.Code)
+	}
+}